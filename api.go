@@ -0,0 +1,406 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Local API defaults
+const (
+	defaultAPIPort    = 18090
+	defaultUsageLimit = 100
+	maxAPIRequestBody = 64 * 1024
+)
+
+// APIServer serves the local control API. Every request must carry the
+// bearer token from cfg.APITokenFile and a localhost Host header.
+type APIServer struct {
+	mu    sync.RWMutex
+	cfg   *Config // replaced when the config file changes
+	token string
+}
+
+// apiBudget is one budget period in API responses
+type apiBudget struct {
+	Spent   float64 `json:"spent"`
+	Limit   float64 `json:"limit"`
+	Percent float64 `json:"percent"`
+}
+
+// apiBackend describes a backend in the status response
+type apiBackend struct {
+	Name       string `json:"name"`
+	Display    string `json:"display_name"`
+	Configured bool   `json:"configured"`
+}
+
+// loadOrCreateAPIToken returns the API token, generating it on first use
+func loadOrCreateAPIToken(cfg *Config) (string, error) {
+	if data, err := os.ReadFile(cfg.APITokenFile); err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	if err := writeFileAtomic(cfg.APITokenFile, []byte(token), 0600); err != nil {
+		return "", fmt.Errorf("failed to save API token: %w", err)
+	}
+	return token, nil
+}
+
+// NewAPIServer creates an API server requiring token
+func NewAPIServer(cfg *Config, token string) *APIServer {
+	return &APIServer{cfg: cfg, token: token}
+}
+
+// config returns the configuration requests are served with
+func (s *APIServer) config() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// setConfig replaces the configuration for later requests
+func (s *APIServer) setConfig(cfg *Config) {
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+}
+
+// Handler returns the API routes wrapped in host and token checks
+func (s *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/status", s.handleStatus)
+	mux.HandleFunc("/v1/backend", s.handleBackend)
+	mux.HandleFunc("/v1/cost", s.handleCost)
+	mux.HandleFunc("/v1/budgets", s.handleBudgets)
+	mux.HandleFunc("/v1/sessions", s.handleSessions)
+	mux.HandleFunc("/v1/usage", s.handleUsage)
+	return s.authorize(mux)
+}
+
+// authorize rejects non-localhost Host headers, which blocks DNS rebinding
+// from browsers, and requests without the bearer token
+func (s *APIServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			writeAPIError(w, http.StatusForbidden, "host not allowed")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeAPIJSON(w, status, map[string]string{"error": msg})
+}
+
+// decodeAPIBody decodes a bounded JSON request body into v
+func decodeAPIBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAPIRequestBody)).Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func (s *APIServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	cfg := s.config()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]apiBackend, 0, len(names))
+	for _, name := range names {
+		be := backends[name]
+		list = append(list, apiBackend{
+			Name:       name,
+			Display:    be.DisplayName,
+			Configured: be.Name == "ollama" || cfg.Keys[be.AuthVar] != "",
+		})
+	}
+
+	writeAPIJSON(w, http.StatusOK, map[string]interface{}{
+		"version":     getVersion(),
+		"environment": cfg.Environment,
+		"backend":     getCurrentBackend(cfg),
+		"session":     getCurrentSession(cfg),
+		"backends":    list,
+	})
+}
+
+// handleBackend returns the current backend on GET and records a new one on
+// POST. Switching only updates state; launching stays with the CLI.
+func (s *APIServer) handleBackend(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	cfg := s.config()
+	if r.Method == http.MethodGet {
+		writeAPIJSON(w, http.StatusOK, map[string]string{"backend": getCurrentBackend(cfg)})
+		return
+	}
+
+	var body struct {
+		Backend string `json:"backend"`
+	}
+	if err := decodeAPIBody(r, &body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name := strings.ToLower(strings.TrimSpace(body.Backend))
+	be, ok := backends[name]
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("unknown backend '%s'", body.Backend))
+		return
+	}
+	if be.Name != "ollama" && cfg.Keys[be.AuthVar] == "" {
+		writeAPIError(w, http.StatusConflict, fmt.Sprintf("%s not set in .env.local", be.AuthVar))
+		return
+	}
+	git := currentGitInfo()
+	if err := checkTrainingPolicy(cfg, be, git); err != nil {
+		writeAPIError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err := enforceRegionPolicy(cfg, be, git); err != nil {
+		writeAPIError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err := setCurrentBackend(cfg, name); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to save state")
+		return
+	}
+	auditLog(cfg, fmt.Sprintf("SWITCH: %s (api)", name))
+	writeAPIJSON(w, http.StatusOK, map[string]string{"backend": name})
+}
+
+func (s *APIServer) handleCost(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	daily, weekly, monthly, byBackend := calculateCosts(s.config())
+	writeAPIJSON(w, http.StatusOK, map[string]interface{}{
+		"daily":      daily,
+		"weekly":     weekly,
+		"monthly":    monthly,
+		"by_backend": byBackend,
+	})
+}
+
+func newAPIBudget(spent, limit float64) apiBudget {
+	b := apiBudget{Spent: spent, Limit: limit}
+	if limit > 0 {
+		b.Percent = spent / limit * 100
+	}
+	return b
+}
+
+func (s *APIServer) handleBudgets(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	cfg := s.config()
+	daily, weekly, monthly, _ := calculateCosts(cfg)
+	writeAPIJSON(w, http.StatusOK, map[string]apiBudget{
+		"daily":   newAPIBudget(daily, cfg.DailyBudget),
+		"weekly":  newAPIBudget(weekly, cfg.WeeklyBudget),
+		"monthly": newAPIBudget(monthly, cfg.MonthlyBudget),
+	})
+}
+
+// handleSessions lists sessions on GET and starts a named session on POST
+func (s *APIServer) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	cfg := s.config()
+	sessions := loadSessions(cfg)
+	if r.Method == http.MethodGet {
+		if sessions == nil {
+			sessions = []*Session{}
+		}
+		writeAPIJSON(w, http.StatusOK, sessions)
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := decodeAPIBody(r, &body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Name == "" || len(body.Name) > 64 || strings.ContainsAny(body.Name, "\r\n\t/") {
+		writeAPIError(w, http.StatusBadRequest, "invalid session name")
+		return
+	}
+	for _, existing := range sessions {
+		if existing.Name == body.Name && existing.Status != "closed" {
+			writeAPIError(w, http.StatusConflict, fmt.Sprintf("session '%s' already exists (status: %s)", body.Name, existing.Status))
+			return
+		}
+	}
+	session, err := createSession(cfg, body.Name)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIJSON(w, http.StatusCreated, session)
+}
+
+// filterUsage returns the most recent records matching the query, oldest
+// first. since accepts an RFC 3339 time or a duration such as 24h.
+func filterUsage(records usageSource, backend, session, since string, limit int) ([]UsageRecord, error) {
+	var after time.Time
+	if since != "" {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			after = t
+		} else if d, err := time.ParseDuration(since); err == nil {
+			after = time.Now().Add(-d)
+		} else {
+			return nil, fmt.Errorf("invalid since '%s': use RFC 3339 or a duration", since)
+		}
+	}
+
+	matched := []UsageRecord{}
+	records(func(rec UsageRecord) {
+		if backend != "" && rec.Backend != backend {
+			return
+		}
+		if session != "" && rec.SessionID != session {
+			return
+		}
+		if !after.IsZero() && !rec.Timestamp.After(after) {
+			return
+		}
+		matched = append(matched, rec)
+	})
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.Before(matched[j].Timestamp) })
+	if limit > 0 && len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+	return matched, nil
+}
+
+func (s *APIServer) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	limit := defaultUsageLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit '%s'", v))
+			return
+		}
+		limit = n
+	}
+	records, err := filterUsage(ledgerUsage(s.config()), q.Get("backend"), q.Get("session"), q.Get("since"), limit)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, records)
+}
+
+func handleAPICommand(args []string) {
+	if len(args) == 0 || args[0] != "serve" {
+		fmt.Fprintln(os.Stderr, "Usage: promptops api serve [--port <port>]")
+		os.Exit(1)
+	}
+	port := defaultAPIPort
+	if rest := args[1:]; len(rest) > 0 {
+		if len(rest) != 2 || rest[0] != "--port" {
+			fmt.Fprintln(os.Stderr, "Usage: promptops api serve [--port <port>]")
+			os.Exit(1)
+		}
+		p, err := strconv.Atoi(rest[1])
+		if err != nil || p < 1024 || p > 65535 {
+			fmt.Fprintf(os.Stderr, "Error: invalid port '%s'\n", rest[1])
+			os.Exit(1)
+		}
+		port = p
+	}
+
+	cfg := loadConfig()
+	token, err := loadOrCreateAPIToken(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	api := NewAPIServer(cfg, token)
+	server := &http.Server{
+		Handler:           api.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	auditLog(cfg, fmt.Sprintf("API_SERVE: port %d", port))
+	fmt.Printf("[OK] PromptOps API listening on http://localhost:%d\n", port)
+	fmt.Printf("     Send 'Authorization: Bearer <token>' with the token from %s\n", cfg.APITokenFile)
+	fmt.Printf("     Reloading %s when it changes\n", cfg.EnvFile)
+
+	// Budgets, policy and keys reported by the API follow edits to the env file
+	stop := make(chan struct{})
+	defer close(stop)
+	go watchConfig(cfg.EnvFile, stop, func() { api.reloadConfig(loadConfig) })
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAPI(t *testing.T) (*Config, http.Handler) {
+	t.Helper()
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.APITokenFile = filepath.Join(filepath.Dir(cfg.StateFile), "api-token")
+	cfg.Keys["DEEPSEEK_API_KEY"] = "test-key"
+	return cfg, NewAPIServer(cfg, "secret").Handler()
+}
+
+func apiRequest(h http.Handler, method, path, body string, authorized bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://localhost:18090"+path, strings.NewReader(body))
+	if authorized {
+		req.Header.Set("Authorization", "Bearer secret")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIAuthorization(t *testing.T) {
+	_, h := newTestAPI(t)
+
+	if rec := apiRequest(h, "GET", "/v1/status", "", false); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("GET", "http://evil.example:18090/v1/status", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-localhost host, got %d", rec.Code)
+	}
+
+	if rec := apiRequest(h, "GET", "/v1/status", "", true); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 with token, got %d", rec.Code)
+	}
+}
+
+func TestAPISwitchBackend(t *testing.T) {
+	cfg, h := newTestAPI(t)
+
+	rec := apiRequest(h, "POST", "/v1/backend", `{"backend": "DeepSeek"}`, true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := getCurrentBackend(cfg); got != "deepseek" {
+		t.Errorf("Expected state deepseek, got %q", got)
+	}
+
+	tests := map[string]struct {
+		body string
+		code int
+	}{
+		"unknown backend": {`{"backend": "nope"}`, http.StatusBadRequest},
+		"missing key":     {`{"backend": "openai"}`, http.StatusConflict},
+		"bad json":        {`{`, http.StatusBadRequest},
+	}
+	for name, tt := range tests {
+		if rec := apiRequest(h, "POST", "/v1/backend", tt.body, true); rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d", name, tt.code, rec.Code)
+		}
+	}
+	if rec := apiRequest(h, "DELETE", "/v1/backend", "", true); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for DELETE, got %d", rec.Code)
+	}
+}
+
+func TestAPIBudgets(t *testing.T) {
+	cfg, h := newTestAPI(t)
+	data, _ := json.Marshal(UsageRecord{Timestamp: time.Now(), Backend: "claude", CostUSD: 2.5})
+	if err := os.WriteFile(cfg.UsageFile, append(data, '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := apiRequest(h, "GET", "/v1/budgets", "", true)
+	var budgets map[string]apiBudget
+	if err := json.NewDecoder(rec.Body).Decode(&budgets); err != nil {
+		t.Fatal(err)
+	}
+	if d := budgets["daily"]; d.Spent != 2.5 || d.Limit != 10 || d.Percent != 25 {
+		t.Errorf("Unexpected daily budget: %+v", d)
+	}
+}
+
+func TestAPISessions(t *testing.T) {
+	cfg, h := newTestAPI(t)
+
+	rec := apiRequest(h, "POST", "/v1/sessions", `{"name": "feature-x"}`, true)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if s := getCurrentSession(cfg); s == nil || s.Name != "feature-x" {
+		t.Errorf("Expected feature-x to be the current session, got %+v", s)
+	}
+	if rec := apiRequest(h, "POST", "/v1/sessions", `{"name": "feature-x"}`, true); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for duplicate session, got %d", rec.Code)
+	}
+	if rec := apiRequest(h, "POST", "/v1/sessions", `{"name": ""}`, true); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for empty name, got %d", rec.Code)
+	}
+
+	var sessions []Session
+	json.NewDecoder(apiRequest(h, "GET", "/v1/sessions", "", true).Body).Decode(&sessions)
+	if len(sessions) != 1 {
+		t.Errorf("Expected 1 session, got %d", len(sessions))
+	}
+}
+
+func TestFilterUsage(t *testing.T) {
+	now := time.Now()
+	records := []UsageRecord{
+		{Timestamp: now.Add(-48 * time.Hour), Backend: "claude", SessionID: "a"},
+		{Timestamp: now.Add(-2 * time.Hour), Backend: "deepseek", SessionID: "a"},
+		{Timestamp: now.Add(-time.Hour), Backend: "claude", SessionID: "b"},
+		{Timestamp: now, Backend: "claude", SessionID: "a"},
+	}
+
+	got, err := filterUsage(usageRecords(records), "claude", "", "24h", 0)
+	if err != nil || len(got) != 2 {
+		t.Errorf("Expected 2 recent claude records, got %d (err=%v)", len(got), err)
+	}
+	got, _ = filterUsage(usageRecords(records), "", "a", "", 2)
+	if len(got) != 2 || !got[1].Timestamp.Equal(now) {
+		t.Errorf("Expected the 2 newest session records, got %+v", got)
+	}
+	got, _ = filterUsage(usageRecords(records), "", "", now.Add(-90*time.Minute).Format(time.RFC3339), 0)
+	if len(got) != 2 {
+		t.Errorf("Expected 2 records after an RFC 3339 time, got %d", len(got))
+	}
+	if _, err := filterUsage(usageRecords(records), "", "", "yesterday", 0); err == nil {
+		t.Error("Expected error for invalid since")
+	}
+}
+
+func TestLoadOrCreateAPIToken(t *testing.T) {
+	cfg := &Config{APITokenFile: filepath.Join(t.TempDir(), "token")}
+
+	token, err := loadOrCreateAPIToken(cfg)
+	if err != nil || len(token) != 64 {
+		t.Fatalf("Expected a 64 character token, got %q (err=%v)", token, err)
+	}
+	info, err := os.Stat(cfg.APITokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %o", info.Mode().Perm())
+	}
+	if again, _ := loadOrCreateAPIToken(cfg); again != token {
+		t.Error("Expected the existing token to be reused")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+)
+
+// APIVersion is a provider API version this build sends with its own
+// requests (health checks, model catalogs, alias resolution). Claude Code
+// sends its own headers; these only cover requests made by promptops.
+type APIVersion struct {
+	Provider string
+	Header   string // request header carrying the version, if any
+	Query    string // query parameter carrying the version, if any
+	Default  string // version this build was tested against; empty sends none
+	EnvVar   string // env file key overriding Default
+}
+
+// apiVersions lists the versioned provider APIs, keyed by backend
+var apiVersions = map[string]APIVersion{
+	"claude": {Provider: "Anthropic", Header: "anthropic-version", Default: "2023-06-01", EnvVar: "ANTHROPIC_VERSION"},
+	// OpenAI's public API is unversioned; Azure-style deployments require api-version
+	"openai": {Provider: "OpenAI", Query: "api-version", EnvVar: "OPENAI_API_VERSION"},
+}
+
+var apiVersionPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// setAPIVersionOverride records an env file override such as
+// ANTHROPIC_VERSION=2023-06-01. It reports whether key names an API version.
+func setAPIVersionOverride(cfg *Config, key, value string) (bool, error) {
+	for backend, v := range apiVersions {
+		if v.EnvVar != key {
+			continue
+		}
+		if !apiVersionPattern.MatchString(value) {
+			return true, fmt.Errorf("version must match %s", apiVersionPattern)
+		}
+		if cfg.APIVersions == nil {
+			cfg.APIVersions = make(map[string]string)
+		}
+		cfg.APIVersions[backend] = value
+		return true, nil
+	}
+	return false, nil
+}
+
+// apiVersion returns the version sent to backend's API and whether it comes
+// from an override
+func (c *Config) apiVersion(backend string) (string, bool) {
+	if v, ok := c.APIVersions[backend]; ok {
+		return v, true
+	}
+	return apiVersions[backend].Default, false
+}
+
+// applyAPIVersion adds backend's API version to req, if it has one
+func applyAPIVersion(req *http.Request, cfg *Config, backend string) {
+	v, ok := apiVersions[backend]
+	if !ok {
+		return
+	}
+	version, _ := cfg.apiVersion(backend)
+	if version == "" {
+		return
+	}
+	if v.Header != "" {
+		req.Header.Set(v.Header, version)
+	}
+	if v.Query != "" {
+		q := req.URL.Query()
+		q.Set(v.Query, version)
+		req.URL.RawQuery = q.Encode()
+	}
+}
+
+// writeAPIVersions prints one line per versioned provider API
+func writeAPIVersions(cfg *Config, out io.Writer) {
+	names := make([]string, 0, len(apiVersions))
+	for name := range apiVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := apiVersions[name]
+		param := v.Header
+		if param == "" {
+			param = v.Query
+		}
+		version, overridden := cfg.apiVersion(name)
+		source := "default"
+		if overridden {
+			source = v.EnvVar
+		}
+		if version == "" {
+			version, source = "not sent", "set "+v.EnvVar+" to pin"
+		}
+		fmt.Fprintf(out, "  %-10s %-18s %-12s %s\n", v.Provider, param, version, styleMuted.Render("("+source+")"))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSetAPIVersionOverride(t *testing.T) {
+	cfg := &Config{}
+	if ok, err := setAPIVersionOverride(cfg, "ANTHROPIC_VERSION", "2024-01-01"); !ok || err != nil {
+		t.Fatalf("Expected override, got ok=%v err=%v", ok, err)
+	}
+	if v, overridden := cfg.apiVersion("claude"); v != "2024-01-01" || !overridden {
+		t.Errorf("Expected override, got %q %v", v, overridden)
+	}
+	if ok, _ := setAPIVersionOverride(cfg, "NEXUS_YOLO_MODE", "true"); ok {
+		t.Error("Expected unrelated key to be ignored")
+	}
+	if _, err := setAPIVersionOverride(cfg, "OPENAI_API_VERSION", "bad value"); err == nil {
+		t.Error("Expected error for invalid version")
+	}
+	if v, overridden := (&Config{}).apiVersion("claude"); v != "2023-06-01" || overridden {
+		t.Errorf("Expected default, got %q %v", v, overridden)
+	}
+}
+
+func TestApplyAPIVersion(t *testing.T) {
+	cfg := &Config{}
+	req, _ := http.NewRequest("GET", "https://api.anthropic.com/v1/models", nil)
+	applyAPIVersion(req, cfg, "claude")
+	if got := req.Header.Get("anthropic-version"); got != "2023-06-01" {
+		t.Errorf("Expected default header, got %q", got)
+	}
+
+	req, _ = http.NewRequest("GET", "https://api.openai.com/v1/models", nil)
+	applyAPIVersion(req, cfg, "openai")
+	if req.URL.RawQuery != "" {
+		t.Errorf("Expected no api-version by default, got %q", req.URL.RawQuery)
+	}
+	setAPIVersionOverride(cfg, "OPENAI_API_VERSION", "2024-10-21")
+	applyAPIVersion(req, cfg, "openai")
+	if got := req.URL.Query().Get("api-version"); got != "2024-10-21" {
+		t.Errorf("Expected api-version parameter, got %q", got)
+	}
+}
+
+func TestWriteAPIVersions(t *testing.T) {
+	cfg := &Config{}
+	setAPIVersionOverride(cfg, "ANTHROPIC_VERSION", "2024-01-01")
+	var out bytes.Buffer
+	writeAPIVersions(cfg, &out)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", out.String())
+	}
+	if !strings.Contains(lines[0], "2024-01-01") || !strings.Contains(lines[0], "ANTHROPIC_VERSION") {
+		t.Errorf("Expected Anthropic override, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "not sent") {
+		t.Errorf("Expected unpinned OpenAI version, got %q", lines[1])
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthStrategy applies a backend credential to a request promptops makes:
+// health checks, usage fetchers and the Grok proxy. The credential is the
+// value of the backend's AuthVar.
+type AuthStrategy interface {
+	Apply(req *http.Request, credential string) error
+	// String describes the strategy for `which`, never the credential
+	String() string
+}
+
+// BearerAuth sends "Authorization: Bearer <key>", the default for
+// OpenAI-compatible providers
+type BearerAuth struct{}
+
+func (BearerAuth) Apply(req *http.Request, credential string) error {
+	req.Header.Set("Authorization", "Bearer "+credential)
+	return nil
+}
+
+func (BearerAuth) String() string { return "bearer token" }
+
+// HeaderAuth sends the key in a named header, such as Anthropic's x-api-key
+type HeaderAuth struct {
+	Header string
+}
+
+func (a HeaderAuth) Apply(req *http.Request, credential string) error {
+	req.Header.Set(a.Header, credential)
+	return nil
+}
+
+func (a HeaderAuth) String() string { return a.Header + " header" }
+
+// QueryAuth sends the key as a query parameter, such as Google's ?key=
+type QueryAuth struct {
+	Param string
+}
+
+func (a QueryAuth) Apply(req *http.Request, credential string) error {
+	q := req.URL.Query()
+	q.Set(a.Param, credential)
+	req.URL.RawQuery = q.Encode()
+	return nil
+}
+
+func (a QueryAuth) String() string { return a.Param + " query parameter" }
+
+// AuthChain applies several strategies, for providers that accept either of
+// two schemes and are sent both
+type AuthChain []AuthStrategy
+
+func (c AuthChain) Apply(req *http.Request, credential string) error {
+	for _, a := range c {
+		if err := a.Apply(req, credential); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c AuthChain) String() string {
+	parts := make([]string, len(c))
+	for i, a := range c {
+		parts[i] = a.String()
+	}
+	return strings.Join(parts, " + ")
+}
+
+// SigV4Auth signs requests with AWS Signature Version 4, for providers
+// fronted by AWS such as Bedrock. The credential is
+// ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN].
+type SigV4Auth struct {
+	Region  string
+	Service string
+	now     func() time.Time
+}
+
+func (a SigV4Auth) String() string {
+	return fmt.Sprintf("AWS SigV4 (%s/%s)", a.Region, a.Service)
+}
+
+func (a SigV4Auth) Apply(req *http.Request, credential string) error {
+	parts := strings.SplitN(credential, ":", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("SigV4 credential must be ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]")
+	}
+	accessKey, secretKey := parts[0], parts[1]
+
+	now := time.Now
+	if a.now != nil {
+		now = a.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+
+	payload, err := requestPayload(req)
+	if err != nil {
+		return err
+	}
+	payloadHash := sha256.Sum256(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if len(parts) == 3 && parts[2] != "" {
+		req.Header.Set("X-Amz-Security-Token", parts[2])
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for key := range req.Header {
+		if lower := strings.ToLower(key); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(key))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{day, a.Region, a.Service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{day, a.Region, a.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name and value, with
+// spaces as %20 as SigV4 requires
+func canonicalQuery(q url.Values) string {
+	var pairs []string
+	for key, values := range q {
+		for _, v := range values {
+			pairs = append(pairs, strings.ReplaceAll(url.QueryEscape(key), "+", "%20")+"="+strings.ReplaceAll(url.QueryEscape(v), "+", "%20"))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// requestPayload returns the request body without consuming it
+func requestPayload(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// OAuthAuth exchanges a client ID and secret (credential CLIENT_ID:SECRET)
+// for an access token with the client credentials grant, and sends it as a
+// bearer token. Tokens are cached until shortly before they expire.
+type OAuthAuth struct {
+	TokenURL string
+	Scope    string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (a *OAuthAuth) String() string {
+	return "OAuth client credentials (" + a.TokenURL + ")"
+}
+
+func (a *OAuthAuth) Apply(req *http.Request, credential string) error {
+	token, err := a.accessToken(credential)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *OAuthAuth) accessToken(credential string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Now().Before(a.expires) {
+		return a.token, nil
+	}
+	clientID, secret, ok := strings.Cut(credential, ":")
+	if !ok || clientID == "" || secret == "" {
+		return "", fmt.Errorf("OAuth credential must be CLIENT_ID:CLIENT_SECRET")
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if a.Scope != "" {
+		form.Set("scope", a.Scope)
+	}
+	req, err := http.NewRequest("POST", a.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("OAuth token request failed: %s", sanitizeError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OAuth token request returned HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil || result.AccessToken == "" {
+		return "", fmt.Errorf("invalid OAuth token response")
+	}
+	a.token = result.AccessToken
+	// Refresh a minute early so a token never expires mid-request
+	lifetime := time.Duration(result.ExpiresIn)*time.Second - time.Minute
+	if lifetime < 0 {
+		lifetime = 0
+	}
+	a.expires = time.Now().Add(lifetime)
+	return a.token, nil
+}
+
+// parseAuthStrategy parses an NEXUS_AUTH_<BACKEND> value:
+//
+//	bearer
+//	header[:<name>]            default x-api-key
+//	query:<param>
+//	sigv4:<region>/<service>
+//	oauth:<token-url> [scope]
+func parseAuthStrategy(value string) (AuthStrategy, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(value), ":")
+	arg = strings.TrimSpace(arg)
+	switch strings.ToLower(kind) {
+	case "bearer":
+		return BearerAuth{}, nil
+	case "header":
+		if arg == "" {
+			arg = "x-api-key"
+		}
+		return HeaderAuth{Header: arg}, nil
+	case "query":
+		if arg == "" {
+			return nil, fmt.Errorf("query auth needs a parameter name, e.g. query:key")
+		}
+		return QueryAuth{Param: arg}, nil
+	case "sigv4":
+		region, service, ok := strings.Cut(arg, "/")
+		if !ok || region == "" || service == "" {
+			return nil, fmt.Errorf("sigv4 auth needs a region and service, e.g. sigv4:us-east-1/bedrock")
+		}
+		return SigV4Auth{Region: region, Service: service}, nil
+	case "oauth":
+		tokenURL, scope, _ := strings.Cut(arg, " ")
+		if u, err := url.Parse(tokenURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("oauth auth needs an https token URL, e.g. oauth:https://login.example.com/token")
+		}
+		return &OAuthAuth{TokenURL: tokenURL, Scope: strings.TrimSpace(scope)}, nil
+	}
+	return nil, fmt.Errorf("unknown auth strategy '%s': use bearer, header, query, sigv4 or oauth", kind)
+}
+
+// authStrategy returns how promptops authenticates to be: an NEXUS_AUTH_
+// override, the backend's own strategy, or a bearer token
+func (c *Config) authStrategy(be Backend) AuthStrategy {
+	if a, ok := c.AuthStrategies[be.Name]; ok {
+		return a
+	}
+	if be.Auth != nil {
+		return be.Auth
+	}
+	return BearerAuth{}
+}
+
+// withAuth returns be with its configured auth strategy resolved
+func withAuth(cfg *Config, be Backend) Backend {
+	be.Auth = cfg.authStrategy(be)
+	return be
+}
+
+// applyAuth authenticates req to be. Backends without a strategy use a
+// bearer token.
+func applyAuth(req *http.Request, be Backend, credential string) error {
+	if be.Auth == nil {
+		return BearerAuth{}.Apply(req, credential)
+	}
+	return be.Auth.Apply(req, credential)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthStrategiesApply(t *testing.T) {
+	tests := []struct {
+		auth  AuthStrategy
+		check func(*http.Request) bool
+	}{
+		{BearerAuth{}, func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer secret" }},
+		{HeaderAuth{Header: "x-api-key"}, func(r *http.Request) bool { return r.Header.Get("x-api-key") == "secret" }},
+		{QueryAuth{Param: "key"}, func(r *http.Request) bool {
+			return r.URL.Query().Get("key") == "secret" && r.URL.Query().Get("a") == "1"
+		}},
+		{AuthChain{HeaderAuth{Header: "X-Api-Key"}, BearerAuth{}}, func(r *http.Request) bool {
+			return r.Header.Get("X-Api-Key") == "secret" && r.Header.Get("Authorization") == "Bearer secret"
+		}},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "https://api.example.com/v1/models?a=1", nil)
+		if err := tt.auth.Apply(req, "secret"); err != nil {
+			t.Fatalf("%s: %v", tt.auth, err)
+		}
+		if !tt.check(req) {
+			t.Errorf("%s: unexpected request %v %v", tt.auth, req.URL, req.Header)
+		}
+		if strings.Contains(tt.auth.String(), "secret") {
+			t.Errorf("%s: description must not include the credential", tt.auth)
+		}
+	}
+}
+
+func TestSigV4Auth(t *testing.T) {
+	// AWS Signature Version 4 test suite: get-vanilla and
+	// get-vanilla-query-order-key-case
+	fixed := func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+	auth := SigV4Auth{Region: "us-east-1", Service: "service", now: fixed}
+	credential := "AKIDEXAMPLE:wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+
+	tests := map[string]string{
+		"https://example.amazonaws.com/":                             "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		"https://example.amazonaws.com/?Param2=value2&Param1=value1": "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+	}
+	for url, signature := range tests {
+		req, _ := http.NewRequest("GET", url, nil)
+		if err := auth.Apply(req, credential); err != nil {
+			t.Fatal(err)
+		}
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Errorf("%s:\ngot  %s\nwant %s", url, got, want)
+		}
+	}
+
+	// Session tokens are sent and signed
+	req, _ := http.NewRequest("POST", "https://bedrock.us-east-1.amazonaws.com/model", strings.NewReader(`{}`))
+	if err := auth.Apply(req, credential+":session"); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "session" || !strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token") {
+		t.Errorf("Unexpected headers %v", req.Header)
+	}
+
+	if err := auth.Apply(req, "only-an-id"); err == nil {
+		t.Error("Expected an error for a malformed credential")
+	}
+}
+
+func TestOAuthAuth(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if id != "client" || secret != "s3cret" || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "models.read" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"access_token":"tok-1","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	auth := &OAuthAuth{TokenURL: server.URL, Scope: "models.read"}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "https://api.example.com/v1/models", nil)
+		if err := auth.Apply(req, "client:s3cret"); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer tok-1" {
+			t.Errorf("Unexpected Authorization %q", got)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected the token to be cached, got %d token requests", n)
+	}
+
+	req, _ := http.NewRequest("GET", "https://api.example.com/v1/models", nil)
+	err := (&OAuthAuth{TokenURL: server.URL}).Apply(req, "client:wrong")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 401") || strings.Contains(err.Error(), "wrong") {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestParseAuthStrategy(t *testing.T) {
+	valid := map[string]string{
+		"bearer":                                "bearer token",
+		"header":                                "x-api-key header",
+		"header:api-key":                        "api-key header",
+		"query:key":                             "key query parameter",
+		"sigv4:us-east-1/bedrock":               "AWS SigV4 (us-east-1/bedrock)",
+		"oauth:https://login.example.com/token": "OAuth client credentials (https://login.example.com/token)",
+	}
+	for value, want := range valid {
+		a, err := parseAuthStrategy(value)
+		if err != nil {
+			t.Errorf("parseAuthStrategy(%q) error: %v", value, err)
+			continue
+		}
+		if a.String() != want {
+			t.Errorf("parseAuthStrategy(%q) = %s, want %s", value, a, want)
+		}
+	}
+	if a, _ := parseAuthStrategy("oauth:https://login.example.com/token api.read"); a.(*OAuthAuth).Scope != "api.read" {
+		t.Error("Expected the OAuth scope to be parsed")
+	}
+	for _, value := range []string{"", "basic", "query", "sigv4:us-east-1", "oauth:http://login.example.com/token"} {
+		if _, err := parseAuthStrategy(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestModelsRequestUsesAuthStrategy(t *testing.T) {
+	cfg := &Config{}
+	req, _, err := modelsRequest(cfg, backends["claude"], "sk-ant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("x-api-key") != "sk-ant" || req.Header.Get("Authorization") != "" {
+		t.Errorf("Expected Claude to use x-api-key, got %v", req.Header)
+	}
+
+	req, _, _ = modelsRequest(cfg, backends["deepseek"], "sk-ds")
+	if req.Header.Get("Authorization") != "Bearer sk-ds" {
+		t.Errorf("Expected a bearer token by default, got %v", req.Header)
+	}
+
+	cfg.AuthStrategies = map[string]AuthStrategy{"gemini": QueryAuth{Param: "key"}}
+	req, _, _ = modelsRequest(cfg, backends["gemini"], "g-key")
+	if req.URL.Query().Get("key") != "g-key" || req.Header.Get("Authorization") != "" {
+		t.Errorf("Expected the override to be used, got %v", req.URL)
+	}
+
+	// Ollama sends no credentials unless one is configured
+	req, _, _ = modelsRequest(cfg, backends["ollama"], "")
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("Expected no Authorization for Ollama, got %v", req.Header)
+	}
+}
+
+func TestGrokProxyUsesAuthStrategy(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	p := NewGrokProxy(upstream.URL, "xai-key")
+	rec := httptest.NewRecorder()
+	p.handle(rec, httptest.NewRequest("GET", "/v1/models", nil))
+	if got.Get("X-Api-Key") != "xai-key" || got.Get("Authorization") != "Bearer xai-key" {
+		t.Errorf("Expected both default headers, got %v", got)
+	}
+
+	p.SetAuth(HeaderAuth{Header: "api-key"})
+	p.handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/models", nil))
+	if got.Get("api-key") != "xai-key" || got.Get("X-Api-Key") != "" {
+		t.Errorf("Expected the configured header only, got %v", got)
+	}
+}
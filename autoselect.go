@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// autoBackend is the NEXUS_DEFAULT_BACKEND value that enables health-based selection
+const autoBackend = "auto"
+
+// defaultAutoPreference is the order backends are tried in when no
+// NEXUS_AUTO_BACKENDS preference list is configured
+var defaultAutoPreference = []string{"claude", "openai", "deepseek", "gemini", "mistral", "zai", "kimi", "grok", "groq", "together", "openrouter", "ollama"}
+
+// parseBackendList parses a comma-separated list of backend names, dropping
+// unknown names and duplicates
+func parseBackendList(value string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := backends[name]; !ok {
+			return nil, fmt.Errorf("unknown backend '%s'", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("empty backend list")
+	}
+	return names, nil
+}
+
+// loadAutoChoice returns the backend chosen by auto selection on the given day
+func loadAutoChoice(cfg *Config, day time.Time) string {
+	chosen, backend := readAutoChoice(cfg)
+	if chosen != day.Format("2006-01-02") {
+		return ""
+	}
+	return backend
+}
+
+// readAutoChoice returns the day and backend of the last auto selection
+func readAutoChoice(cfg *Config) (day, backend string) {
+	data, err := os.ReadFile(cfg.AutoChoiceFile)
+	if err != nil {
+		return "", ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return "", ""
+	}
+	if _, ok := backends[fields[1]]; !ok {
+		return "", ""
+	}
+	return fields[0], fields[1]
+}
+
+// autoManaged reports whether auto selection may choose the backend: when
+// none is set, or the current one is what auto selection last chose. A
+// backend the user switched to stays until they switch again.
+func autoManaged(cfg *Config) bool {
+	current := getCurrentBackend(cfg)
+	if current == "" {
+		return true
+	}
+	_, chosen := readAutoChoice(cfg)
+	return current == chosen
+}
+
+// saveAutoChoice remembers the auto-selected backend for the given day
+func saveAutoChoice(cfg *Config, day time.Time, backend string) error {
+	return writeFileAtomic(cfg.AutoChoiceFile, []byte(day.Format("2006-01-02")+" "+backend), 0600)
+}
+
+// autoCandidates returns the preferred backends that have credentials configured
+// and that the data region policy allows
+func autoCandidates(cfg *Config) []string {
+	preference := cfg.AutoPreference
+	if len(preference) == 0 {
+		preference = defaultAutoPreference
+	}
+	// Backends the data region policy refuses are never picked
+	var git GitInfo
+	if len(cfg.DataRegions) > 0 && !cfg.DataRegionWarn {
+		git = currentGitInfo()
+	}
+	var candidates []string
+	for _, name := range preference {
+		be, ok := backends[name]
+		if !ok {
+			continue
+		}
+		if cfg.Keys[be.AuthVar] == "" && be.Name != "ollama" {
+			continue
+		}
+		if !cfg.DataRegionWarn && checkRegionPolicy(cfg, be, git) != nil {
+			continue
+		}
+		candidates = append(candidates, name)
+	}
+	return candidates
+}
+
+// selectAutoBackend picks the first healthy backend from the preference list.
+// The choice is remembered for the rest of the day so health checks only run
+// on the first launch.
+func selectAutoBackend(cfg *Config, check func(*Config, Backend) HealthResult) (string, error) {
+	today := time.Now()
+	if choice := loadAutoChoice(cfg, today); choice != "" {
+		return choice, nil
+	}
+
+	candidates := autoCandidates(cfg)
+	if len(candidates) == 0 {
+		return "", errors.New("no backends with configured keys in auto preference list")
+	}
+
+	for _, name := range candidates {
+		result := check(cfg, backends[name])
+		if result.Status != "ok" {
+			fmt.Printf("INFO: Skipping %s (%s)\n", name, truncate(result.Message, 60))
+			continue
+		}
+		if err := saveAutoChoice(cfg, today, name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remember auto backend: %v\n", err)
+		}
+		auditLog(cfg, fmt.Sprintf("AUTO_SELECT: %s", name))
+		return name, nil
+	}
+
+	return "", fmt.Errorf("no healthy backend among: %s", strings.Join(candidates, ", "))
+}
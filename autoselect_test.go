@@ -0,0 +1,133 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseBackendList(t *testing.T) {
+	list, err := parseBackendList(" Claude, deepseek,,ollama,claude ")
+	if err != nil {
+		t.Fatalf("parseBackendList failed: %v", err)
+	}
+	expected := []string{"claude", "deepseek", "ollama"}
+	if len(list) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, list)
+	}
+	for i := range expected {
+		if list[i] != expected[i] {
+			t.Errorf("list[%d] = %q, want %q", i, list[i], expected[i])
+		}
+	}
+
+	if _, err := parseBackendList("claude,bogus"); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+	if _, err := parseBackendList(" , "); err == nil {
+		t.Error("Expected error for empty list")
+	}
+}
+
+func TestAutoChoicePersistence(t *testing.T) {
+	cfg := &Config{AutoChoiceFile: filepath.Join(t.TempDir(), "auto")}
+	today := time.Now()
+
+	if got := loadAutoChoice(cfg, today); got != "" {
+		t.Errorf("Expected no choice before save, got %q", got)
+	}
+	if err := saveAutoChoice(cfg, today, "deepseek"); err != nil {
+		t.Fatalf("saveAutoChoice failed: %v", err)
+	}
+	if got := loadAutoChoice(cfg, today); got != "deepseek" {
+		t.Errorf("loadAutoChoice = %q, want deepseek", got)
+	}
+	// Choice expires the next day
+	if got := loadAutoChoice(cfg, today.AddDate(0, 0, 1)); got != "" {
+		t.Errorf("Expected choice to expire, got %q", got)
+	}
+}
+
+func TestSelectAutoBackend(t *testing.T) {
+	cfg := &Config{
+		AutoChoiceFile: filepath.Join(t.TempDir(), "auto"),
+		AutoPreference: []string{"claude", "deepseek", "ollama"},
+		Keys: map[string]string{
+			"ANTHROPIC_API_KEY": "sk-ant-test",
+			"DEEPSEEK_API_KEY":  "sk-ds-test",
+		},
+	}
+
+	checked := []string{}
+	check := func(cfg *Config, be Backend) HealthResult {
+		checked = append(checked, be.Name)
+		if be.Name == "claude" {
+			return HealthResult{Backend: be.Name, Status: "error", Message: "HTTP 529"}
+		}
+		return HealthResult{Backend: be.Name, Status: "ok"}
+	}
+
+	name, err := selectAutoBackend(cfg, check)
+	if err != nil {
+		t.Fatalf("selectAutoBackend failed: %v", err)
+	}
+	if name != "deepseek" {
+		t.Errorf("Expected deepseek, got %q", name)
+	}
+	if len(checked) != 2 {
+		t.Errorf("Expected 2 health checks, got %v", checked)
+	}
+
+	// Second call uses the remembered choice without health checks
+	checked = nil
+	name, err = selectAutoBackend(cfg, check)
+	if err != nil || name != "deepseek" {
+		t.Errorf("Expected remembered deepseek, got %q (%v)", name, err)
+	}
+	if len(checked) != 0 {
+		t.Errorf("Expected no health checks, got %v", checked)
+	}
+}
+
+func TestSelectAutoBackendNoneHealthy(t *testing.T) {
+	cfg := &Config{
+		AutoChoiceFile: filepath.Join(t.TempDir(), "auto"),
+		AutoPreference: []string{"claude", "openai"},
+		Keys:           map[string]string{},
+	}
+	check := func(cfg *Config, be Backend) HealthResult {
+		return HealthResult{Backend: be.Name, Status: "error"}
+	}
+
+	if _, err := selectAutoBackend(cfg, check); err == nil {
+		t.Error("Expected error when no backends have keys")
+	}
+
+	cfg.Keys["OPENAI_API_KEY"] = "sk-test"
+	if _, err := selectAutoBackend(cfg, check); err == nil {
+		t.Error("Expected error when no backend is healthy")
+	}
+}
+
+func TestAutoManaged(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		AutoChoiceFile: filepath.Join(dir, "auto"),
+		StateFile:      filepath.Join(dir, "state"),
+	}
+	if !autoManaged(cfg) {
+		t.Error("Expected auto selection without a current backend")
+	}
+
+	saveAutoChoice(cfg, time.Now().AddDate(0, 0, -1), "deepseek")
+	setCurrentBackend(cfg, "deepseek")
+	if !autoManaged(cfg) {
+		t.Error("Expected yesterday's auto choice to be re-selected")
+	}
+
+	// An explicit switch is kept
+	setCurrentBackend(cfg, "kimi")
+	if autoManaged(cfg) {
+		t.Error("Expected the switched-to backend kept")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxBackendPromptBytes caps each configured prefix and suffix, since they are
+// sent with every request
+const maxBackendPromptBytes = 4 * 1024
+
+// BackendPrompt is provider-specific system text some models need to behave
+// well as a coding agent. Proxies put Prefix ahead of the client's system
+// prompt and Suffix after it (and after the project primer).
+type BackendPrompt struct {
+	Prefix string
+	Suffix string
+}
+
+// Empty reports whether there is nothing to inject
+func (bp BackendPrompt) Empty() bool {
+	return bp.Prefix == "" && bp.Suffix == ""
+}
+
+// Wrap returns system with the prefix before it and the suffix after it
+func (bp BackendPrompt) Wrap(system string) string {
+	return appendSystemText(appendSystemText(bp.Prefix, system), bp.Suffix)
+}
+
+// Tokens estimates what the prefix and suffix add to each request for model
+func (bp BackendPrompt) Tokens(model string) int {
+	n := 0
+	for _, text := range []string{bp.Prefix, bp.Suffix} {
+		if text != "" {
+			n += countTokens(model, text)
+		}
+	}
+	return n
+}
+
+// setBackendPromptKey parses NEXUS_SYSTEM_PREFIX_<BACKEND> and
+// NEXUS_SYSTEM_SUFFIX_<BACKEND>. A literal \n in the value is a newline.
+func setBackendPromptKey(cfg *Config, key, value string) error {
+	var name string
+	var suffix bool
+	switch {
+	case strings.HasPrefix(key, "NEXUS_SYSTEM_PREFIX_"):
+		name = strings.TrimPrefix(key, "NEXUS_SYSTEM_PREFIX_")
+	case strings.HasPrefix(key, "NEXUS_SYSTEM_SUFFIX_"):
+		name, suffix = strings.TrimPrefix(key, "NEXUS_SYSTEM_SUFFIX_"), true
+	}
+	name = strings.ToLower(name)
+	if _, ok := backends[name]; !ok {
+		return fmt.Errorf("unknown backend '%s'", name)
+	}
+	text := strings.TrimSpace(strings.ReplaceAll(value, `\n`, "\n"))
+	if len(text) > maxBackendPromptBytes {
+		return fmt.Errorf("longer than %d bytes", maxBackendPromptBytes)
+	}
+	if cfg.BackendPrompts == nil {
+		cfg.BackendPrompts = make(map[string]BackendPrompt)
+	}
+	bp := cfg.BackendPrompts[name]
+	if suffix {
+		bp.Suffix = text
+	} else {
+		bp.Prefix = text
+	}
+	cfg.BackendPrompts[name] = bp
+	return nil
+}
+
+// launchBackendPrompt returns the prompt a launch on be injects, or an empty
+// one when none is configured or the current session turned it off
+func launchBackendPrompt(cfg *Config, be Backend) BackendPrompt {
+	bp := cfg.BackendPrompts[be.Name]
+	if bp.Empty() {
+		return BackendPrompt{}
+	}
+	if s := getCurrentSession(cfg); s != nil && s.NoBackendPrompt {
+		if cfg.verbose() {
+			fmt.Printf("INFO: Session '%s' skips the %s system prompt prefix/suffix\n", s.Name, be.DisplayName)
+		}
+		return BackendPrompt{}
+	}
+	_, sonnet, _ := resolveTierModels(cfg, be)
+	auditLog(cfg, fmt.Sprintf("BACKEND_PROMPT: %s (prefix %d bytes, suffix %d bytes)", be.Name, len(bp.Prefix), len(bp.Suffix)))
+	if cfg.verbose() {
+		fmt.Printf("INFO: %s system prompt prefix/suffix adds ~%d tokens per request\n", be.DisplayName, bp.Tokens(sonnet))
+	}
+	return bp
+}
+
+// appendPromptArgs passes a backend prompt, with any project context primer
+// in its suffix, to Claude Code itself for backends it talks to without a
+// local proxy. Claude Code can only append to its system prompt, so the
+// prefix goes ahead of the suffix at the end. User-supplied system prompt
+// flags win.
+func appendPromptArgs(bp BackendPrompt, args []string) []string {
+	if bp.Empty() {
+		return args
+	}
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		if name == "--append-system-prompt" || name == "--system-prompt" {
+			fmt.Fprintf(os.Stderr, "Warning: %s given, not adding the backend system prompt prefix/suffix or project context\n", name)
+			return args
+		}
+	}
+	return append(args, "--append-system-prompt", appendSystemText(bp.Prefix, bp.Suffix))
+}
+
+// setSessionBackendPrompt turns a session's backend prompt on or off:
+// promptops session backend-prompt <on|off> [name]
+func setSessionBackendPrompt(args []string) {
+	if len(args) < 1 || len(args) > 2 || (args[0] != "on" && args[0] != "off") {
+		fmt.Fprintln(os.Stderr, "Usage: promptops session backend-prompt <on|off> [name]")
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+	name := ""
+	if len(args) == 2 {
+		name = args[1]
+	}
+	session := findSession(cfg, name, "backend-prompt "+args[0])
+	off := args[0] == "off"
+	if err := updateSession(cfg, session.ID, func(s *Session) { s.NoBackendPrompt = off }); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("[OK] Backend system prompt prefix/suffix %s for session '%s'\n", args[0], session.Name)
+}
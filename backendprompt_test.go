@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetBackendPromptKey(t *testing.T) {
+	cfg := &Config{}
+	if err := setBackendPromptKey(cfg, "NEXUS_SYSTEM_PREFIX_KIMI", `Be careful.\nAnswer in English.`); err != nil {
+		t.Fatal(err)
+	}
+	if err := setBackendPromptKey(cfg, "NEXUS_SYSTEM_SUFFIX_KIMI", "Use tools."); err != nil {
+		t.Fatal(err)
+	}
+	bp := cfg.BackendPrompts["kimi"]
+	if bp.Prefix != "Be careful.\nAnswer in English." || bp.Suffix != "Use tools." {
+		t.Errorf("Unexpected prompt %+v", bp)
+	}
+	if err := setBackendPromptKey(cfg, "NEXUS_SYSTEM_PREFIX_NOPE", "x"); err == nil {
+		t.Error("Expected an unknown backend rejected")
+	}
+	if err := setBackendPromptKey(cfg, "NEXUS_SYSTEM_SUFFIX_ZAI", strings.Repeat("x", maxBackendPromptBytes+1)); err == nil {
+		t.Error("Expected an oversized suffix rejected")
+	}
+}
+
+func TestBackendPromptWrap(t *testing.T) {
+	bp := BackendPrompt{Prefix: "P", Suffix: "S"}
+	if got := bp.Wrap("client"); got != "P\n\nclient\n\nS" {
+		t.Errorf("Unexpected wrap %q", got)
+	}
+	if got := (BackendPrompt{Suffix: "S"}).Wrap(""); got != "S" {
+		t.Errorf("Unexpected suffix-only wrap %q", got)
+	}
+	if got := (BackendPrompt{}).Wrap("client"); got != "client" {
+		t.Errorf("Expected an empty prompt to leave the system text, got %q", got)
+	}
+	if bp.Tokens("gpt-4o") <= 0 || (BackendPrompt{}).Tokens("gpt-4o") != 0 {
+		t.Error("Unexpected token estimates")
+	}
+}
+
+func TestInjectSystemBlocksPrefix(t *testing.T) {
+	body := injectSystemBlocks([]byte(`{"system":[{"type":"text","text":"client"}]}`), "P", "S")
+	var req struct {
+		System []AnthropicContentItem `json:"system"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.System) != 3 || req.System[0].Text != "P" || req.System[1].Text != "client" || req.System[2].Text != "S" {
+		t.Errorf("Expected prefix, client and suffix blocks, got %+v", req.System)
+	}
+}
+
+func TestAppendPromptArgs(t *testing.T) {
+	bp := BackendPrompt{Prefix: "P", Suffix: "S"}
+	args := appendPromptArgs(bp, []string{"--model", "opus"})
+	if len(args) != 4 || args[2] != "--append-system-prompt" || args[3] != "P\n\nS" {
+		t.Errorf("Unexpected args %v", args)
+	}
+	user := []string{"--append-system-prompt=mine"}
+	if got := appendPromptArgs(bp, user); len(got) != 1 {
+		t.Errorf("Expected the user's system prompt flag to win, got %v", got)
+	}
+	if got := appendPromptArgs(BackendPrompt{}, nil); got != nil {
+		t.Errorf("Expected no args without a prompt, got %v", got)
+	}
+}
+
+func TestLaunchBackendPromptSessionOff(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.Output = outputQuiet
+	cfg.BackendPrompts = map[string]BackendPrompt{"kimi": {Prefix: "P"}}
+	if bp := launchBackendPrompt(cfg, backends["kimi"]); bp.Prefix != "P" {
+		t.Errorf("Expected the prefix applied, got %+v", bp)
+	}
+	if bp := launchBackendPrompt(cfg, backends["zai"]); !bp.Empty() {
+		t.Errorf("Expected other backends unaffected, got %+v", bp)
+	}
+
+	session, err := createSession(cfg, "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	updateSession(cfg, session.ID, func(s *Session) { s.NoBackendPrompt = true })
+	if bp := launchBackendPrompt(cfg, backends["kimi"]); !bp.Empty() {
+		t.Errorf("Expected the session to turn the prompt off, got %+v", bp)
+	}
+}
+
+func TestProxyInjectsBackendPrompt(t *testing.T) {
+	var sent OpenAIRequest
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	proxy := NewOllamaProxy(upstream.URL, map[string]string{})
+	proxy.SetSystemPrimer("primer")
+	proxy.SetBackendPrompt(BackendPrompt{Prefix: "prefix", Suffix: "suffix"})
+	var decision ProxyDecision
+	proxy.SetDecisionRecorder(func(d ProxyDecision) { decision = d })
+
+	body := `{"model":"m","system":"client","messages":[{"role":"user","content":"hi"}]}`
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader([]byte(body))))
+
+	if len(sent.Messages) == 0 || sent.Messages[0].Content != "prefix\n\nclient\n\nprimer\n\nsuffix" {
+		t.Errorf("Unexpected system message %+v", sent.Messages)
+	}
+	if decision.BackendPromptTokens <= 0 || decisionFlags(decision) != "backend-prompt" {
+		t.Errorf("Expected the injection accounted, got %+v", decision)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// budgetPeriods are the budget periods in display order
+var budgetPeriods = []string{"daily", "weekly", "monthly"}
+
+// BackendBudget holds one backend's own limits, enforced alongside the
+// global budgets. Zero means no per-backend limit for that period.
+type BackendBudget struct {
+	Daily, Weekly, Monthly float64
+}
+
+// PeriodCosts is spending in the current day, week and month
+type PeriodCosts struct {
+	Daily, Weekly, Monthly float64
+}
+
+// limits returns the budget's limits in budgetPeriods order
+func (b BackendBudget) limits() []float64 {
+	return []float64{b.Daily, b.Weekly, b.Monthly}
+}
+
+// spent returns the costs in budgetPeriods order
+func (c PeriodCosts) spent() []float64 {
+	return []float64{c.Daily, c.Weekly, c.Monthly}
+}
+
+// parseBudgetKey splits NEXUS_DAILY_BUDGET_CLAUDE into its period and backend
+func parseBudgetKey(key string) (period, backend string, ok bool) {
+	for _, p := range budgetPeriods {
+		if name, found := strings.CutPrefix(key, "NEXUS_"+strings.ToUpper(p)+"_BUDGET_"); found && name != "" {
+			return p, strings.ToLower(name), true
+		}
+	}
+	return "", "", false
+}
+
+// setBackendBudget records a per-backend limit from the env file
+func setBackendBudget(cfg *Config, period, backend string, limit float64) {
+	if cfg.BackendBudgets == nil {
+		cfg.BackendBudgets = make(map[string]*BackendBudget)
+	}
+	b := cfg.BackendBudgets[backend]
+	if b == nil {
+		b = &BackendBudget{}
+		cfg.BackendBudgets[backend] = b
+	}
+	switch period {
+	case "daily":
+		b.Daily = limit
+	case "weekly":
+		b.Weekly = limit
+	case "monthly":
+		b.Monthly = limit
+	}
+}
+
+// budgetPeriodStarts returns the start of the current day, week (Sunday) and
+// month, as used for budget totals
+func budgetPeriodStarts(now time.Time) (today, weekStart, monthStart time.Time) {
+	today = now.Truncate(24 * time.Hour)
+	weekStart = today.AddDate(0, 0, -int(today.Weekday()))
+	monthStart = today.AddDate(0, 0, -today.Day()+1)
+	return today, weekStart, monthStart
+}
+
+// periodCostsByBackend totals each backend's spending in the current periods
+func periodCostsByBackend(records usageSource, now time.Time) map[string]PeriodCosts {
+	today, weekStart, monthStart := budgetPeriodStarts(now)
+	costs := make(map[string]PeriodCosts)
+	records(func(r UsageRecord) {
+		c := costs[r.Backend]
+		if r.Timestamp.Truncate(24 * time.Hour).Equal(today) {
+			c.Daily += r.CostUSD
+		}
+		if r.Timestamp.After(weekStart) {
+			c.Weekly += r.CostUSD
+		}
+		if r.Timestamp.After(monthStart) {
+			c.Monthly += r.CostUSD
+		}
+		costs[r.Backend] = c
+	})
+	return costs
+}
+
+// backendBudgetExhausted returns a reason when any of the backend's own
+// budget periods is fully spent
+func backendBudgetExhausted(cfg *Config, backend string, costs PeriodCosts) string {
+	b, ok := cfg.BackendBudgets[backend]
+	if !ok {
+		return ""
+	}
+	spent := costs.spent()
+	for i, limit := range b.limits() {
+		if limit > 0 && spent[i] >= limit {
+			return fmt.Sprintf("%s %s budget exhausted: %s of %s spent",
+				backends[backend].DisplayName, budgetPeriods[i], formatCurrency(spent[i]), formatCurrency(limit))
+		}
+	}
+	return ""
+}
+
+// checkBackendBudget refuses a launch once the backend's own budget is
+// spent, so the limit holds without the hooks installed
+func checkBackendBudget(cfg *Config, be Backend) error {
+	if _, ok := cfg.BackendBudgets[be.Name]; !ok {
+		return nil
+	}
+	costs := periodCostsByBackend(ledgerUsage(cfg), time.Now())
+	if reason := backendBudgetExhausted(cfg, be.Name, costs[be.Name]); reason != "" {
+		return errors.New(reason)
+	}
+	return nil
+}
+
+// renderBackendBudgets prints progress bars for every backend with its own
+// budget
+func renderBackendBudgets(cfg *Config, costs map[string]PeriodCosts) {
+	for _, name := range doctorBackends {
+		b, ok := cfg.BackendBudgets[name]
+		if !ok {
+			continue
+		}
+		spent := costs[name].spent()
+		for i, limit := range b.limits() {
+			if limit > 0 {
+				label := fmt.Sprintf("%-10s %-7s", backends[name].DisplayName, budgetPeriods[i])
+				renderProgressBar(label, spent[i], limit)
+			}
+		}
+	}
+}
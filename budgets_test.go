@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBudgetKey(t *testing.T) {
+	for key, want := range map[string][2]string{
+		"NEXUS_DAILY_BUDGET_CLAUDE":     {"daily", "claude"},
+		"NEXUS_WEEKLY_BUDGET_OPENAI":    {"weekly", "openai"},
+		"NEXUS_MONTHLY_BUDGET_DEEPSEEK": {"monthly", "deepseek"},
+	} {
+		period, backend, ok := parseBudgetKey(key)
+		if !ok || period != want[0] || backend != want[1] {
+			t.Errorf("%s: got %s %s %v", key, period, backend, ok)
+		}
+	}
+	for _, key := range []string{"NEXUS_DAILY_BUDGET", "NEXUS_DAILY_BUDGET_", "NEXUS_YEARLY_BUDGET_CLAUDE"} {
+		if _, _, ok := parseBudgetKey(key); ok {
+			t.Errorf("Expected %s to be rejected", key)
+		}
+	}
+}
+
+func TestPeriodCostsByBackend(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) // a Friday
+	records := []UsageRecord{
+		{Timestamp: now.Add(-time.Hour), Backend: "claude", CostUSD: 1},
+		{Timestamp: now.AddDate(0, 0, -3), Backend: "claude", CostUSD: 2},
+		{Timestamp: now.AddDate(0, 0, -10), Backend: "claude", CostUSD: 4},
+		{Timestamp: now.AddDate(0, -1, 0), Backend: "claude", CostUSD: 8},
+		{Timestamp: now.Add(-time.Hour), Backend: "deepseek", CostUSD: 0.5},
+	}
+	costs := periodCostsByBackend(usageRecords(records), now)
+	if c := costs["claude"]; c.Daily != 1 || c.Weekly != 3 || c.Monthly != 7 {
+		t.Errorf("Unexpected claude costs %+v", c)
+	}
+	if c := costs["deepseek"]; c.Daily != 0.5 || c.Monthly != 0.5 {
+		t.Errorf("Unexpected deepseek costs %+v", c)
+	}
+}
+
+func TestCheckBackendBudget(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	setBackendBudget(cfg, "daily", "claude", 2)
+	data, _ := json.Marshal(UsageRecord{Timestamp: time.Now(), Backend: "claude", CostUSD: 3})
+	if err := os.WriteFile(cfg.UsageFile, append(data, '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkBackendBudget(cfg, backends["claude"]); err == nil || !strings.Contains(err.Error(), "Claude daily budget exhausted") {
+		t.Errorf("Expected the claude launch refused, got %v", err)
+	}
+	if err := checkBackendBudget(cfg, backends["deepseek"]); err != nil {
+		t.Errorf("Expected deepseek to launch, got %v", err)
+	}
+}
+
+func TestBackendBudgetExhausted(t *testing.T) {
+	cfg := &Config{}
+	setBackendBudget(cfg, "daily", "claude", 5)
+	setBackendBudget(cfg, "monthly", "claude", 20)
+
+	if reason := backendBudgetExhausted(cfg, "claude", PeriodCosts{Daily: 4.99, Weekly: 30, Monthly: 19}); reason != "" {
+		t.Errorf("Expected no block, got %q", reason)
+	}
+	if reason := backendBudgetExhausted(cfg, "claude", PeriodCosts{Daily: 5}); !strings.Contains(reason, "Claude daily budget exhausted") {
+		t.Errorf("Expected daily block, got %q", reason)
+	}
+	if reason := backendBudgetExhausted(cfg, "claude", PeriodCosts{Monthly: 25}); !strings.Contains(reason, "monthly") {
+		t.Errorf("Expected monthly block, got %q", reason)
+	}
+	if reason := backendBudgetExhausted(cfg, "deepseek", PeriodCosts{Daily: 100}); reason != "" {
+		t.Errorf("Expected no block without a backend budget, got %q", reason)
+	}
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// State bundle layout and limits
+const (
+	bundleVersion       = 1
+	bundleManifestName  = "manifest.json"
+	bundleKeysName      = "keys.enc"
+	bundleAnswersPrefix = "answers/"        // stored prompt and answer texts
+	bundleMaxEntrySize  = 256 * 1024 * 1024 // usage ledgers can be large
+	bundleKDFIterations = 200000
+	bundleSaltSize      = 16
+)
+
+// BundleManifest describes the contents of a state bundle
+type BundleManifest struct {
+	Version      int       `json:"version"`
+	Created      time.Time `json:"created"`
+	AppVersion   string    `json:"app_version"`
+	Files        []string  `json:"files"`
+	KeysIncluded bool      `json:"keys_included"`
+	Answers      int       `json:"answers,omitempty"`
+}
+
+// bundleFiles maps archive entry names to the config paths they are read from
+// and restored to. Only these names are accepted on import.
+func bundleFiles(cfg *Config) map[string]string {
+	return map[string]string{
+		"env.local":            cfg.EnvFile,
+		"state":                cfg.StateFile,
+		"sessions.json":        cfg.SessionsFile,
+		"usage.jsonl":          cfg.UsageFile,
+		"audit.log":            cfg.AuditLog,
+		"prompts.jsonl":        cfg.PromptIndexFile,
+		"auto-selection":       cfg.AutoChoiceFile,
+		"credits.json":         cfg.CreditsFile,
+		"models.json":          cfg.ModelsFile,
+		"model-snapshots.json": cfg.ModelSnapshotsFile,
+	}
+}
+
+// apiKeyVars returns the set of env variables that hold API keys
+func apiKeyVars() map[string]bool {
+	vars := make(map[string]bool)
+	for _, be := range backends {
+		vars[be.AuthVar] = true
+	}
+	return vars
+}
+
+// stripKeys blanks API key values in env file content and returns them separately
+func stripKeys(env []byte) ([]byte, map[string]string) {
+	keyVars := apiKeyVars()
+	keys := make(map[string]string)
+	lines := strings.Split(string(env), "\n")
+	for i, line := range lines {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 || !keyVars[strings.TrimSpace(parts[0])] {
+			continue
+		}
+		name := strings.TrimSpace(parts[0])
+		value := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+		if value != "" {
+			keys[name] = value
+		}
+		lines[i] = name + "="
+	}
+	return []byte(strings.Join(lines, "\n")), keys
+}
+
+// restoreKeys fills API key values back into env file content, appending any
+// key not already present
+func restoreKeys(env []byte, keys map[string]string) []byte {
+	lines := strings.Split(string(env), "\n")
+	restored := make(map[string]bool)
+	for i, line := range lines {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(parts[0])
+		if value, ok := keys[name]; ok {
+			lines[i] = name + "=" + value
+			restored[name] = true
+		}
+	}
+	var names []string
+	for name := range keys {
+		if !restored[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, name+"="+keys[name])
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// bundleKey derives the AES-256 key for a passphrase (PBKDF2 with HMAC-SHA256)
+func bundleKey(passphrase string, salt []byte) []byte {
+	return pbkdf2.Key([]byte(passphrase), salt, bundleKDFIterations, 32, sha256.New)
+}
+
+// encryptBundleKeys seals API keys with AES-256-GCM under a passphrase.
+// Layout: salt | nonce | ciphertext.
+func encryptBundleKeys(keys map[string]string, passphrase string) ([]byte, error) {
+	plaintext, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, bundleSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	block, err := aes.NewCipher(bundleKey(passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	out := append(salt, nonce...)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+// decryptBundleKeys opens keys sealed by encryptBundleKeys
+func decryptBundleKeys(data []byte, passphrase string) (map[string]string, error) {
+	if len(data) < bundleSaltSize {
+		return nil, errors.New("encrypted keys truncated")
+	}
+	salt := data[:bundleSaltSize]
+	block, err := aes.NewCipher(bundleKey(passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	rest := data[bundleSaltSize:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("encrypted keys truncated")
+	}
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted keys")
+	}
+	var keys map[string]string
+	if err := json.Unmarshal(plaintext, &keys); err != nil {
+		return nil, fmt.Errorf("decode keys: %w", err)
+	}
+	return keys, nil
+}
+
+// writeStateBundle writes config, sessions and usage history to a tar.gz
+// bundle. API keys are stripped unless a passphrase is given, in which case
+// they are stored encrypted.
+func writeStateBundle(cfg *Config, path string, passphrase string) (*BundleManifest, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("create bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	manifest := &BundleManifest{
+		Version:    bundleVersion,
+		Created:    time.Now(),
+		AppVersion: getVersion(),
+	}
+
+	files := bundleFiles(cfg)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := os.ReadFile(files[name])
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		if name == "env.local" {
+			var keys map[string]string
+			data, keys = stripKeys(data)
+			if passphrase != "" && len(keys) > 0 {
+				sealed, err := encryptBundleKeys(keys, passphrase)
+				if err != nil {
+					return nil, fmt.Errorf("encrypt keys: %w", err)
+				}
+				if err := writeTarEntry(tw, bundleKeysName, sealed); err != nil {
+					return nil, err
+				}
+				manifest.KeysIncluded = true
+			}
+		}
+		if err := writeTarEntry(tw, name, data); err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, name)
+	}
+
+	// Stored prompt and answer texts the prompt index points to
+	var answers []os.DirEntry
+	if cfg.PromptAnswerDir != "" {
+		answers, err = os.ReadDir(cfg.PromptAnswerDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read %s: %w", cfg.PromptAnswerDir, err)
+		}
+	}
+	for _, entry := range answers {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(cfg.PromptAnswerDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", entry.Name(), err)
+		}
+		if err := writeTarEntry(tw, bundleAnswersPrefix+entry.Name(), data); err != nil {
+			return nil, err
+		}
+		manifest.Answers++
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarEntry(tw, bundleManifestName, manifestData); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("finish bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("finish bundle: %w", err)
+	}
+	return manifest, nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// readStateBundle reads a bundle into memory, rejecting unknown entries
+func readStateBundle(cfg *Config, path string) (*BundleManifest, map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read bundle: %w", err)
+	}
+	defer gz.Close()
+
+	allowed := bundleFiles(cfg)
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if _, ok := allowed[hdr.Name]; !ok && hdr.Name != bundleManifestName && hdr.Name != bundleKeysName && !isBundleAnswer(hdr.Name) {
+			return nil, nil, fmt.Errorf("unexpected bundle entry: %s", hdr.Name)
+		}
+		if hdr.Size > bundleMaxEntrySize {
+			return nil, nil, fmt.Errorf("bundle entry too large: %s", hdr.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, bundleMaxEntrySize))
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %s: %w", hdr.Name, err)
+		}
+		entries[hdr.Name] = data
+	}
+
+	var manifest BundleManifest
+	data, ok := entries[bundleManifestName]
+	if !ok {
+		return nil, nil, errors.New("bundle has no manifest")
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	if manifest.Version > bundleVersion {
+		return nil, nil, fmt.Errorf("bundle version %d is newer than supported version %d", manifest.Version, bundleVersion)
+	}
+	return &manifest, entries, nil
+}
+
+// isBundleAnswer reports whether name is a stored prompt or answer entry. The
+// file name must be a plain name so it cannot escape the answer directory.
+func isBundleAnswer(name string) bool {
+	file, ok := strings.CutPrefix(name, bundleAnswersPrefix)
+	return ok && file != "" && file != "." && file != ".." && filepath.Base(file) == file
+}
+
+// rebasePromptFiles points the prompt and answer files of imported prompt
+// index lines into this machine's answer directory
+func rebasePromptFiles(data []byte, answerDir string) []byte {
+	var out bytes.Buffer
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var entry PromptIndexEntry
+		if err := json.Unmarshal([]byte(line), &entry); err == nil {
+			if entry.PromptFile != "" {
+				entry.PromptFile = filepath.Join(answerDir, filepath.Base(entry.PromptFile))
+			}
+			if entry.AnswerFile != "" {
+				entry.AnswerFile = filepath.Join(answerDir, filepath.Base(entry.AnswerFile))
+			}
+			if rebased, err := json.Marshal(entry); err == nil {
+				line = string(rebased)
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.Bytes()
+}
+
+// importBundleAnswers writes stored prompt and answer texts that are not
+// present yet, or all of them when force is set
+func importBundleAnswers(cfg *Config, entries map[string][]byte, force bool) (int, error) {
+	restored := 0
+	for name, data := range entries {
+		if !isBundleAnswer(name) {
+			continue
+		}
+		if err := os.MkdirAll(cfg.PromptAnswerDir, 0700); err != nil {
+			return restored, fmt.Errorf("create answer dir: %w", err)
+		}
+		target := filepath.Join(cfg.PromptAnswerDir, strings.TrimPrefix(name, bundleAnswersPrefix))
+		if _, err := os.Stat(target); err == nil && !force {
+			continue
+		}
+		if err := writeFileAtomic(target, data, 0600); err != nil {
+			return restored, fmt.Errorf("restore %s: %w", name, err)
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// mergeJSONLines appends lines from incoming that are not already present in existing
+func mergeJSONLines(existing, incoming []byte) ([]byte, int) {
+	seen := make(map[string]bool)
+	var out bytes.Buffer
+	for _, line := range strings.Split(string(existing), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			seen[line] = true
+			out.WriteString(line + "\n")
+		}
+	}
+	added := 0
+	for _, line := range strings.Split(string(incoming), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !seen[line] {
+			seen[line] = true
+			out.WriteString(line + "\n")
+			added++
+		}
+	}
+	return out.Bytes(), added
+}
+
+// mergeSessions combines session lists, keeping existing sessions on ID conflicts
+func mergeSessions(existing, incoming []*Session) ([]*Session, int) {
+	ids := make(map[string]bool)
+	for _, s := range existing {
+		if s != nil {
+			ids[s.ID] = true
+		}
+	}
+	added := 0
+	for _, s := range incoming {
+		if s == nil || ids[s.ID] {
+			continue
+		}
+		existing = append(existing, s)
+		ids[s.ID] = true
+		added++
+	}
+	return existing, added
+}
+
+// importStateBundle restores a bundle. Usage history, prompt index and audit
+// log are merged; sessions are merged by ID; config and state are only
+// replaced when force is set or they do not exist yet.
+func importStateBundle(cfg *Config, path string, force bool, passphrase func() (string, error)) error {
+	manifest, entries, err := readStateBundle(cfg, path)
+	if err != nil {
+		return err
+	}
+
+	files := bundleFiles(cfg)
+	for _, name := range manifest.Files {
+		data, ok := entries[name]
+		if !ok {
+			return fmt.Errorf("bundle is missing %s", name)
+		}
+		target := files[name]
+
+		switch name {
+		case "usage.jsonl", "audit.log":
+			if cfg.LedgerSigning {
+				added, err := importLedgerLines(cfg, target, data)
+				if err != nil {
+					return fmt.Errorf("restore %s: %w", name, err)
+				}
+				fmt.Printf("[OK] %s: appended %d signed entries\n", name, added)
+				continue
+			}
+			fallthrough
+		case "prompts.jsonl":
+			if name == "prompts.jsonl" && cfg.PromptAnswerDir != "" {
+				data = rebasePromptFiles(data, cfg.PromptAnswerDir)
+			}
+			existing, _ := os.ReadFile(target)
+			merged, added := mergeJSONLines(existing, data)
+			if err := writeFileAtomic(target, merged, 0600); err != nil {
+				return fmt.Errorf("restore %s: %w", name, err)
+			}
+			fmt.Printf("[OK] %s: merged %d entries\n", name, added)
+		case "sessions.json":
+			var incoming []*Session
+			if err := json.Unmarshal(data, &incoming); err != nil {
+				return fmt.Errorf("decode sessions: %w", err)
+			}
+			added := 0
+			err := updateSessions(cfg, func(sessions []*Session) ([]*Session, error) {
+				var merged []*Session
+				merged, added = mergeSessions(sessions, incoming)
+				return merged, nil
+			})
+			if err != nil {
+				return fmt.Errorf("restore sessions: %w", err)
+			}
+			fmt.Printf("[OK] %s: merged %d sessions\n", name, added)
+		default:
+			if _, err := os.Stat(target); err == nil && !force {
+				fmt.Printf("[--] %s: exists, skipped (use --force to replace)\n", name)
+				continue
+			}
+			if name == "env.local" && manifest.KeysIncluded {
+				pass, err := passphrase()
+				if err != nil {
+					return err
+				}
+				keys, err := decryptBundleKeys(entries[bundleKeysName], pass)
+				if err != nil {
+					return err
+				}
+				data = restoreKeys(data, keys)
+			}
+			if err := writeFileAtomic(target, data, 0600); err != nil {
+				return fmt.Errorf("restore %s: %w", name, err)
+			}
+			fmt.Printf("[OK] %s: restored\n", name)
+		}
+	}
+
+	if manifest.Answers > 0 && cfg.PromptAnswerDir != "" {
+		restored, err := importBundleAnswers(cfg, entries, force)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[OK] answers: restored %d of %d files\n", restored, manifest.Answers)
+	}
+	return nil
+}
+
+// bundlePassphrase reads the bundle passphrase from NEXUS_BUNDLE_PASSPHRASE or stdin
+func bundlePassphrase() (string, error) {
+	if pass := os.Getenv("NEXUS_BUNDLE_PASSPHRASE"); pass != "" {
+		return pass, nil
+	}
+	fmt.Print("Bundle passphrase: ")
+	var pass string
+	var err error
+	if term.IsTerminal(os.Stdin.Fd()) {
+		// Not echoed, so the passphrase stays off the screen and out of scrollback
+		var data []byte
+		data, err = term.ReadPassword(os.Stdin.Fd())
+		fmt.Println()
+		pass = strings.TrimSpace(string(data))
+	} else {
+		pass, err = readLine(bufio.NewReader(os.Stdin))
+	}
+	if err != nil || pass == "" {
+		return "", errors.New("passphrase required to encrypt or decrypt API keys")
+	}
+	return pass, nil
+}
+
+func handleExportState(args []string) {
+	args, includeKeys := stripFlag(args, "--include-keys")
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: promptops export-state <bundle.tar.gz> [--include-keys]")
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+
+	passphrase := ""
+	if includeKeys {
+		var err error
+		if passphrase, err = bundlePassphrase(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	manifest, err := writeStateBundle(cfg, args[0], passphrase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	auditLog(cfg, fmt.Sprintf("EXPORT_STATE: %d files, keys=%t", len(manifest.Files), manifest.KeysIncluded))
+
+	fmt.Printf("[OK] Exported %s to %s\n", strings.Join(manifest.Files, ", "), args[0])
+	if manifest.KeysIncluded {
+		fmt.Println("INFO: API keys are encrypted with your passphrase")
+	} else {
+		fmt.Println("INFO: API keys were not included (use --include-keys to export them encrypted)")
+	}
+}
+
+func handleImportState(args []string) {
+	args, force := stripFlag(args, "--force")
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: promptops import-state <bundle.tar.gz> [--force]")
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+
+	if err := importStateBundle(cfg, args[0], force, bundlePassphrase); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	auditLog(cfg, "IMPORT_STATE")
+}
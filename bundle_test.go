@@ -0,0 +1,224 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newBundleTestConfig(t *testing.T) *Config {
+	dir := t.TempDir()
+	return &Config{
+		EnvFile:         filepath.Join(dir, ".env.local"),
+		StateFile:       filepath.Join(dir, ".nexus-state"),
+		AuditLog:        filepath.Join(dir, ".nexus-audit.log"),
+		UsageFile:       filepath.Join(dir, ".nexus-usage.jsonl"),
+		SessionsFile:    filepath.Join(dir, ".nexus-sessions.json"),
+		PromptIndexFile: filepath.Join(dir, ".promptops-prompts.jsonl"),
+		AutoChoiceFile:  filepath.Join(dir, ".promptops-auto"),
+		PromptAnswerDir: filepath.Join(dir, ".promptops-answers"),
+	}
+}
+
+func TestStripRestoreKeys(t *testing.T) {
+	env := []byte("ANTHROPIC_API_KEY=sk-ant-secret\nNEXUS_DEFAULT_BACKEND=claude\nDEEPSEEK_API_KEY=\"sk-ds\"\n")
+
+	stripped, keys := stripKeys(env)
+	if strings.Contains(string(stripped), "sk-ant-secret") || strings.Contains(string(stripped), "sk-ds") {
+		t.Errorf("Expected keys to be stripped, got %q", stripped)
+	}
+	if !strings.Contains(string(stripped), "NEXUS_DEFAULT_BACKEND=claude") {
+		t.Error("Expected non-key settings to be kept")
+	}
+	if keys["ANTHROPIC_API_KEY"] != "sk-ant-secret" || keys["DEEPSEEK_API_KEY"] != "sk-ds" {
+		t.Errorf("Unexpected extracted keys: %v", keys)
+	}
+
+	keys["OPENAI_API_KEY"] = "sk-openai"
+	restored := string(restoreKeys(stripped, keys))
+	for _, want := range []string{"ANTHROPIC_API_KEY=sk-ant-secret", "DEEPSEEK_API_KEY=sk-ds", "OPENAI_API_KEY=sk-openai"} {
+		if !strings.Contains(restored, want) {
+			t.Errorf("Expected %q in restored env, got %q", want, restored)
+		}
+	}
+}
+
+func TestBundleKeyEncryption(t *testing.T) {
+	keys := map[string]string{"ANTHROPIC_API_KEY": "sk-ant-secret"}
+	sealed, err := encryptBundleKeys(keys, "correct horse")
+	if err != nil {
+		t.Fatalf("encryptBundleKeys failed: %v", err)
+	}
+	if strings.Contains(string(sealed), "sk-ant-secret") {
+		t.Error("Expected key to be encrypted")
+	}
+
+	opened, err := decryptBundleKeys(sealed, "correct horse")
+	if err != nil {
+		t.Fatalf("decryptBundleKeys failed: %v", err)
+	}
+	if opened["ANTHROPIC_API_KEY"] != "sk-ant-secret" {
+		t.Errorf("Unexpected decrypted keys: %v", opened)
+	}
+
+	if _, err := decryptBundleKeys(sealed, "wrong"); err == nil {
+		t.Error("Expected error for wrong passphrase")
+	}
+	if _, err := decryptBundleKeys(sealed[:4], "correct horse"); err == nil {
+		t.Error("Expected error for truncated data")
+	}
+}
+
+func TestStateBundleRoundTrip(t *testing.T) {
+	src := newBundleTestConfig(t)
+	os.WriteFile(src.EnvFile, []byte("ANTHROPIC_API_KEY=sk-ant-secret\nNEXUS_DEFAULT_BACKEND=deepseek\n"), 0600)
+	os.WriteFile(src.StateFile, []byte("deepseek"), 0644)
+	os.WriteFile(src.UsageFile, []byte("{\"backend\":\"claude\",\"cost\":1}\n{\"backend\":\"deepseek\",\"cost\":2}\n"), 0600)
+	saveSessions(src, []*Session{{ID: "s1", Name: "feature", Backend: "claude", StartTime: time.Now(), Status: "active"}})
+
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	manifest, err := writeStateBundle(src, bundle, "pass")
+	if err != nil {
+		t.Fatalf("writeStateBundle failed: %v", err)
+	}
+	if !manifest.KeysIncluded {
+		t.Error("Expected keys to be included with a passphrase")
+	}
+	info, err := os.Stat(bundle)
+	if err != nil {
+		t.Fatalf("Bundle missing: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected bundle permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	// Destination already has one usage record and one session
+	dst := newBundleTestConfig(t)
+	os.WriteFile(dst.UsageFile, []byte("{\"backend\":\"claude\",\"cost\":1}\n"), 0600)
+	saveSessions(dst, []*Session{{ID: "s0", Name: "existing", Backend: "openai", StartTime: time.Now(), Status: "active"}})
+
+	pass := func() (string, error) { return "pass", nil }
+	if err := importStateBundle(dst, bundle, false, pass); err != nil {
+		t.Fatalf("importStateBundle failed: %v", err)
+	}
+
+	usage, _ := os.ReadFile(dst.UsageFile)
+	if lines := strings.Count(string(usage), "\n"); lines != 2 {
+		t.Errorf("Expected 2 merged usage records, got %d: %q", lines, usage)
+	}
+	if sessions := loadSessions(dst); len(sessions) != 2 {
+		t.Errorf("Expected 2 merged sessions, got %d", len(sessions))
+	}
+	env, _ := os.ReadFile(dst.EnvFile)
+	if !strings.Contains(string(env), "ANTHROPIC_API_KEY=sk-ant-secret") {
+		t.Errorf("Expected decrypted key in restored env, got %q", env)
+	}
+
+	// Importing again does not duplicate history
+	if err := importStateBundle(dst, bundle, false, pass); err != nil {
+		t.Fatalf("second import failed: %v", err)
+	}
+	usage, _ = os.ReadFile(dst.UsageFile)
+	if lines := strings.Count(string(usage), "\n"); lines != 2 {
+		t.Errorf("Expected re-import to be idempotent, got %d records", lines)
+	}
+}
+
+func TestStateBundleWithoutKeys(t *testing.T) {
+	src := newBundleTestConfig(t)
+	os.WriteFile(src.EnvFile, []byte("ANTHROPIC_API_KEY=sk-ant-secret\n"), 0600)
+
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	manifest, err := writeStateBundle(src, bundle, "")
+	if err != nil {
+		t.Fatalf("writeStateBundle failed: %v", err)
+	}
+	if manifest.KeysIncluded {
+		t.Error("Expected keys to be excluded without a passphrase")
+	}
+
+	_, entries, err := readStateBundle(src, bundle)
+	if err != nil {
+		t.Fatalf("readStateBundle failed: %v", err)
+	}
+	if strings.Contains(string(entries["env.local"]), "sk-ant-secret") {
+		t.Error("Expected key to be stripped from bundle")
+	}
+	if _, ok := entries[bundleKeysName]; ok {
+		t.Error("Expected no encrypted keys entry")
+	}
+}
+
+func TestReadStateBundleRejectsUnknownEntries(t *testing.T) {
+	cfg := newBundleTestConfig(t)
+	bundle := filepath.Join(t.TempDir(), "evil.tar.gz")
+
+	f, err := os.Create(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	writeTarEntry(tw, "../../etc/passwd", []byte("x"))
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	if _, _, err := readStateBundle(cfg, bundle); err == nil {
+		t.Error("Expected error for unknown bundle entry")
+	}
+}
+
+func TestStateBundleAnswers(t *testing.T) {
+	src := newBundleTestConfig(t)
+	idx := NewPromptIndex(src)
+	if err := idx.Add(PromptIndexEntry{ID: "p1", Timestamp: time.Now(), Backend: "ollama", Preview: "explain"}, "explain the proxy", "It translates."); err != nil {
+		t.Fatal(err)
+	}
+	bundle := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	manifest, err := writeStateBundle(src, bundle, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Answers != 2 {
+		t.Errorf("Expected the prompt and answer files bundled, got %d", manifest.Answers)
+	}
+
+	dst := newBundleTestConfig(t)
+	if err := importStateBundle(dst, bundle, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	entries := NewPromptIndex(dst).Load()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 imported prompt, got %d", len(entries))
+	}
+	if want := filepath.Join(dst.PromptAnswerDir, "p1.md"); entries[0].AnswerFile != want {
+		t.Errorf("Expected the answer file rebased to %s, got %s", want, entries[0].AnswerFile)
+	}
+	if answer, _ := os.ReadFile(entries[0].AnswerFile); string(answer) != "It translates." {
+		t.Errorf("Unexpected restored answer %q", answer)
+	}
+	if prompt, _ := os.ReadFile(entries[0].PromptFile); string(prompt) != "explain the proxy" {
+		t.Errorf("Unexpected restored prompt %q", prompt)
+	}
+}
+
+func TestIsBundleAnswer(t *testing.T) {
+	for name, want := range map[string]bool{
+		"answers/p1.md":        true,
+		"answers/p1.prompt.md": true,
+		"answers/":             false,
+		"answers/..":           false,
+		"answers/../env.local": false,
+		"answers/sub/p1.md":    false,
+		"p1.md":                false,
+	} {
+		if got := isBundleAnswer(name); got != want {
+			t.Errorf("isBundleAnswer(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
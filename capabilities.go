@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Capability probing limits
+const (
+	// capabilityMaxAge is how long probed capabilities are trusted before a
+	// launch probes again
+	capabilityMaxAge = 7 * 24 * time.Hour
+	// minAgentContext is the smallest context window Claude Code's system
+	// prompt, tool definitions and a few turns fit in
+	minAgentContext = 32000
+)
+
+// Support is a probed yes or no; the zero value means the provider did not
+// say and static assumptions apply
+type Support string
+
+const (
+	supportUnknown Support = ""
+	supportYes     Support = "yes"
+	supportNo      Support = "no"
+)
+
+func supportOf(ok bool) Support {
+	if ok {
+		return supportYes
+	}
+	return supportNo
+}
+
+// ModelCapabilities are what a backend reported for one model
+type ModelCapabilities struct {
+	Tools         Support `json:"tools,omitempty"`
+	JSONMode      Support `json:"json_mode,omitempty"`
+	ContextWindow int     `json:"context_window,omitempty"` // tokens, 0 when unknown
+}
+
+// BackendCapabilities are probed on first use of a backend and cached in
+// CapabilitiesFile. Streaming is also learned by the proxy when an upstream
+// answers a streaming request with a plain JSON response.
+type BackendCapabilities struct {
+	ProbedAt  time.Time                    `json:"probed_at"`
+	Streaming Support                      `json:"streaming,omitempty"`
+	Models    map[string]ModelCapabilities `json:"models,omitempty"`
+}
+
+// Model returns the capabilities probed for model, if any
+func (c *BackendCapabilities) Model(model string) (ModelCapabilities, bool) {
+	if c == nil {
+		return ModelCapabilities{}, false
+	}
+	m, ok := c.Models[model]
+	return m, ok
+}
+
+func loadCapabilities(cfg *Config) map[string]*BackendCapabilities {
+	caps := make(map[string]*BackendCapabilities)
+	data, err := os.ReadFile(cfg.CapabilitiesFile)
+	if err != nil {
+		return caps
+	}
+	if err := json.Unmarshal(data, &caps); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring unreadable capabilities cache: %v\n", err)
+		return make(map[string]*BackendCapabilities)
+	}
+	return caps
+}
+
+// updateCapabilities applies fn to the cached capabilities of backend and
+// saves the cache
+func updateCapabilities(cfg *Config, backend string, fn func(*BackendCapabilities)) error {
+	return withFileLock(cfg.CapabilitiesFile+".lock", func() error {
+		caps := loadCapabilities(cfg)
+		c := caps[backend]
+		if c == nil {
+			c = &BackendCapabilities{}
+			caps[backend] = c
+		}
+		fn(c)
+		data, err := json.MarshalIndent(caps, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(cfg.CapabilitiesFile, data, 0600)
+	})
+}
+
+// parseModelCapabilities reads capability metadata from an OpenAI-style
+// /models response. Providers name the fields differently: context_length
+// (OpenRouter, Together), context_window (Groq), max_context_length
+// (Mistral), max_model_len (vLLM); tool and JSON support come from
+// supported_parameters (OpenRouter) or capabilities (Mistral).
+func parseModelCapabilities(body []byte) map[string]ModelCapabilities {
+	var resp struct {
+		Data []map[string]json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return nil
+	}
+	models := make(map[string]ModelCapabilities)
+	for _, entry := range resp.Data {
+		var id string
+		if json.Unmarshal(entry["id"], &id) != nil || id == "" {
+			continue
+		}
+		var m ModelCapabilities
+		for _, key := range []string{"context_length", "context_window", "max_context_length", "max_model_len"} {
+			var n int
+			if raw, ok := entry[key]; ok && json.Unmarshal(raw, &n) == nil && n > 0 {
+				m.ContextWindow = n
+				break
+			}
+		}
+		var params []string
+		if raw, ok := entry["supported_parameters"]; ok && json.Unmarshal(raw, &params) == nil {
+			m.Tools = supportOf(containsString(params, "tools"))
+			m.JSONMode = supportOf(containsString(params, "response_format"))
+		}
+		var flags map[string]bool
+		if raw, ok := entry["capabilities"]; ok && json.Unmarshal(raw, &flags) == nil {
+			if v, ok := flags["function_calling"]; ok {
+				m.Tools = supportOf(v)
+			}
+			if v, ok := flags["json_mode"]; ok {
+				m.JSONMode = supportOf(v)
+			}
+		}
+		models[id] = m
+	}
+	return models
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// probeOllamaModel asks Ollama's /api/show what a local model supports. Every
+// completion model accepts Ollama's JSON format option.
+func probeOllamaModel(client *http.Client, apiURL, model string) (ModelCapabilities, error) {
+	body, _ := json.Marshal(map[string]string{"model": model, "name": model})
+	resp, err := client.Post(apiURL+"/api/show", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return ModelCapabilities{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ModelCapabilities{}, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var show struct {
+		Capabilities []string                   `json:"capabilities"`
+		ModelInfo    map[string]json.RawMessage `json:"model_info"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&show); err != nil {
+		return ModelCapabilities{}, err
+	}
+	var m ModelCapabilities
+	if show.Capabilities != nil {
+		m.Tools = supportOf(containsString(show.Capabilities, "tools"))
+		m.JSONMode = supportOf(containsString(show.Capabilities, "completion"))
+	}
+	for key, raw := range show.ModelInfo {
+		var n int
+		if strings.HasSuffix(key, ".context_length") && json.Unmarshal(raw, &n) == nil && n > 0 {
+			m.ContextWindow = n
+		}
+	}
+	return m, nil
+}
+
+// probeCapabilities asks be what its tier models support: the /models
+// catalog for every backend, and /api/show for Ollama models
+func probeCapabilities(cfg *Config, be Backend) (*BackendCapabilities, error) {
+	haiku, sonnet, opus := resolveTierModels(cfg, be)
+	tierModels := []string{haiku, sonnet, opus}
+
+	client := *httpClient
+	client.Timeout = cfg.healthTimeout(be.Name)
+
+	caps := &BackendCapabilities{ProbedAt: time.Now(), Models: make(map[string]ModelCapabilities)}
+	req, skip, err := modelsRequest(cfg, be, cfg.Keys[be.AuthVar])
+	if skip != "" {
+		return nil, fmt.Errorf("%s", skip)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s", sanitizeError(err))
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("models request returned HTTP %d", resp.StatusCode)
+	}
+	catalog := parseModelCapabilities(data)
+	for _, model := range tierModels {
+		if m, ok := catalog[model]; ok && model != "" {
+			caps.Models[model] = m
+		}
+	}
+
+	if be.Name == "ollama" {
+		// Ollama streams every model
+		caps.Streaming = supportYes
+		for _, model := range tierModels {
+			if model == "" {
+				continue
+			}
+			if m, err := probeOllamaModel(&client, ollamaAPIURL(be.BaseURL), model); err == nil {
+				caps.Models[model] = m
+			}
+		}
+	}
+	return caps, nil
+}
+
+// ensureCapabilities returns be's capabilities, probing on first use or when
+// the cache is older than capabilityMaxAge. Probe failures fall back to
+// static assumptions and are retried on the next launch. probed reports
+// whether a probe ran.
+func ensureCapabilities(cfg *Config, be Backend) (caps *BackendCapabilities, probed bool) {
+	cached := loadCapabilities(cfg)[be.Name]
+	if cached != nil && time.Since(cached.ProbedAt) < capabilityMaxAge {
+		return cached, false
+	}
+	if cfg.Keys[be.AuthVar] == "" && be.Name != "ollama" {
+		return cached, false
+	}
+	fresh, err := probeCapabilities(cfg, be)
+	if err != nil {
+		return cached, false
+	}
+	// Streaming learned by the proxy outlives a re-probe that can't tell
+	if fresh.Streaming == supportUnknown && cached != nil {
+		fresh.Streaming = cached.Streaming
+	}
+	err = updateCapabilities(cfg, be.Name, func(c *BackendCapabilities) { *c = *fresh })
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache capabilities: %v\n", err)
+	}
+	return fresh, true
+}
+
+// capabilityWarnings explains probed limits that will break Claude Code on
+// be's tier models
+func capabilityWarnings(cfg *Config, be Backend, caps *BackendCapabilities) []string {
+	haiku, sonnet, opus := resolveTierModels(cfg, be)
+	seen := make(map[string]bool)
+	var warnings []string
+	for _, model := range []string{haiku, sonnet, opus} {
+		m, ok := caps.Model(model)
+		if !ok || seen[model] {
+			continue
+		}
+		seen[model] = true
+		if m.Tools == supportNo {
+			warnings = append(warnings, fmt.Sprintf("%s does not support tool calls; Claude Code cannot edit files or run commands with it", model))
+		}
+		if m.ContextWindow > 0 && m.ContextWindow < minAgentContext {
+			warnings = append(warnings, fmt.Sprintf("%s has a %s-token context window; Claude Code needs about %s",
+				model, formatTokenCount(int64(m.ContextWindow)), formatTokenCount(minAgentContext)))
+		}
+	}
+	return warnings
+}
+
+// writeCapabilities prints the probed capabilities of be's models for
+// `which`
+func writeCapabilities(caps *BackendCapabilities, out io.Writer) {
+	if caps == nil {
+		fmt.Fprintln(out, "  Not probed yet (probed on the next launch)")
+		return
+	}
+	show := func(s Support) string {
+		if s == supportUnknown {
+			return "?"
+		}
+		return string(s)
+	}
+	fmt.Fprintf(out, "  %-10s %s (streaming: %s)\n", "Probed:", caps.ProbedAt.Local().Format("2006-01-02 15:04"), show(caps.Streaming))
+	models := make([]string, 0, len(caps.Models))
+	for model := range caps.Models {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		m := caps.Models[model]
+		context := "?"
+		if m.ContextWindow > 0 {
+			context = formatTokenCount(int64(m.ContextWindow))
+		}
+		fmt.Fprintf(out, "  %s: context %s, tools %s, json %s\n", model, context, show(m.Tools), show(m.JSONMode))
+	}
+}
+
+// SetCapabilities gives the proxy the probed capabilities of its Ollama
+// upstream. onNoStream is called once if the upstream answers a streaming
+// request with a single completion, so the finding can be cached.
+func (p *OllamaProxy) SetCapabilities(caps *BackendCapabilities, onNoStream func()) {
+	p.capabilities = caps
+	p.onNoStream = onNoStream
+	if caps != nil && caps.Streaming == supportNo {
+		p.streamOff.Store(true)
+	}
+}
+
+// markNoStreaming switches later requests to non-streaming upstream calls
+func (p *OllamaProxy) markNoStreaming() {
+	if !p.streamOff.CompareAndSwap(false, true) {
+		return
+	}
+	fmt.Fprintln(os.Stderr, styleMuted.Render("[promptops] Upstream does not stream; relaying whole responses"))
+	if p.onNoStream != nil {
+		p.onNoStream()
+	}
+}
+
+// checkCapabilities warns, once per model and problem, when a request needs
+// more than the model was probed to support
+func (p *OllamaProxy) checkCapabilities(model string, req AnthropicRequest, msgs []OpenAIMessage) {
+	m, ok := p.capabilities.Model(model)
+	if !ok {
+		return
+	}
+	if len(req.Tools) > 0 && m.Tools == supportNo {
+		p.warnOnce(model+"|tools", fmt.Sprintf("%s does not support tool calls; Claude Code's tools will fail", model))
+	}
+	if m.ContextWindow > 0 {
+		if tokens := estimateTokens(model, msgs); tokens > m.ContextWindow {
+			p.warnOnce(model+"|context", fmt.Sprintf("Request is about %s tokens but %s has a %s-token context window; the upstream may truncate it",
+				formatTokenCount(int64(tokens)), model, formatTokenCount(int64(m.ContextWindow))))
+		}
+	}
+}
+
+func (p *OllamaProxy) warnOnce(key, msg string) {
+	if _, seen := p.warned.LoadOrStore(key, true); !seen {
+		fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] "+msg))
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseModelCapabilities(t *testing.T) {
+	body := []byte(`{"data":[
+		{"id":"openrouter/model","context_length":131072,"supported_parameters":["tools","temperature"]},
+		{"id":"mistral-small","max_context_length":32768,"capabilities":{"function_calling":true,"json_mode":true}},
+		{"id":"groq-model","context_window":8192},
+		{"id":""}
+	]}`)
+	models := parseModelCapabilities(body)
+	if len(models) != 3 {
+		t.Fatalf("Expected 3 models, got %v", models)
+	}
+	if m := models["openrouter/model"]; m.ContextWindow != 131072 || m.Tools != supportYes || m.JSONMode != supportNo {
+		t.Errorf("Unexpected OpenRouter capabilities %+v", m)
+	}
+	if m := models["mistral-small"]; m.ContextWindow != 32768 || m.Tools != supportYes || m.JSONMode != supportYes {
+		t.Errorf("Unexpected Mistral capabilities %+v", m)
+	}
+	if m := models["groq-model"]; m.ContextWindow != 8192 || m.Tools != supportUnknown {
+		t.Errorf("Expected unknown tool support when not reported, got %+v", m)
+	}
+	if parseModelCapabilities([]byte("not json")) != nil {
+		t.Error("Expected nil for an unparseable response")
+	}
+}
+
+// newCapabilityServer serves an Ollama-style /v1/models and /api/show
+func newCapabilityServer(t *testing.T, probes *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			atomic.AddInt32(probes, 1)
+			w.Write([]byte(`{"data":[{"id":"small:latest"},{"id":"coder:latest"}]}`))
+		case "/api/show":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["model"] == "small:latest" {
+				w.Write([]byte(`{"capabilities":["completion"],"model_info":{"llama.context_length":8192}}`))
+				return
+			}
+			w.Write([]byte(`{"capabilities":["completion","tools"],"model_info":{"qwen2.context_length":131072}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestEnsureCapabilitiesProbesOnce(t *testing.T) {
+	var probes int32
+	server := newCapabilityServer(t, &probes)
+	defer server.Close()
+
+	cfg := newSelfTestConfig(t.TempDir())
+	be := backends["ollama"]
+	be.BaseURL = server.URL + "/v1"
+	be.HaikuModel, be.SonnetModel, be.OpusModel = "small:latest", "coder:latest", "coder:latest"
+
+	caps, probed := ensureCapabilities(cfg, be)
+	if !probed || caps == nil {
+		t.Fatal("Expected the first use to probe")
+	}
+	if caps.Streaming != supportYes {
+		t.Errorf("Expected Ollama to be recorded as streaming, got %q", caps.Streaming)
+	}
+	small, _ := caps.Model("small:latest")
+	if small.Tools != supportNo || small.ContextWindow != 8192 || small.JSONMode != supportYes {
+		t.Errorf("Unexpected small model capabilities %+v", small)
+	}
+	coder, _ := caps.Model("coder:latest")
+	if coder.Tools != supportYes || coder.ContextWindow != 131072 {
+		t.Errorf("Unexpected coder model capabilities %+v", coder)
+	}
+
+	cached, probed := ensureCapabilities(cfg, be)
+	if probed || atomic.LoadInt32(&probes) != 1 {
+		t.Errorf("Expected the cache to be used, probes = %d", probes)
+	}
+	if m, _ := cached.Model("coder:latest"); m != coder {
+		t.Errorf("Cached capabilities differ: %+v", m)
+	}
+
+	warnings := capabilityWarnings(cfg, be, caps)
+	if len(warnings) != 2 {
+		t.Fatalf("Expected tool and context warnings for the small model, got %v", warnings)
+	}
+	for _, w := range warnings {
+		if !strings.Contains(w, "small:latest") {
+			t.Errorf("Unexpected warning %q", w)
+		}
+	}
+}
+
+func TestEnsureCapabilitiesReprobesStaleCache(t *testing.T) {
+	var probes int32
+	server := newCapabilityServer(t, &probes)
+	defer server.Close()
+
+	cfg := newSelfTestConfig(t.TempDir())
+	be := backends["ollama"]
+	be.BaseURL = server.URL + "/v1"
+	updateCapabilities(cfg, be.Name, func(c *BackendCapabilities) {
+		c.ProbedAt = time.Now().Add(-capabilityMaxAge - time.Hour)
+		c.Streaming = supportNo
+	})
+
+	caps, probed := ensureCapabilities(cfg, be)
+	if !probed || atomic.LoadInt32(&probes) != 1 {
+		t.Fatalf("Expected a stale cache to be probed again, probes = %d", probes)
+	}
+	if caps.Streaming != supportYes {
+		t.Errorf("Expected the probe to set streaming, got %q", caps.Streaming)
+	}
+}
+
+func TestEnsureCapabilitiesSkipsWithoutKey(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	caps, probed := ensureCapabilities(cfg, backends["openai"])
+	if probed || caps != nil {
+		t.Errorf("Expected no probe without a key, got %+v", caps)
+	}
+}
+
+func TestProxyRelaysNonStreamingUpstreamAsStream(t *testing.T) {
+	var streamRequests []bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		streamRequests = append(streamRequests, req.Stream)
+		// Ignore stream and answer with a single completion
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(OpenAIResponse{
+			Model:   req.Model,
+			Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: "hello"}, FinishReason: "stop"}},
+			Usage:   OpenAIUsage{PromptTokens: 12, CompletionTokens: 3},
+		})
+	}))
+	defer upstream.Close()
+
+	proxy := NewOllamaProxy(upstream.URL, nil)
+	var cached int32
+	proxy.SetCapabilities(nil, func() { atomic.AddInt32(&cached, 1) })
+
+	body := []byte(`{"model":"llama3.2","stream":true,"max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+		if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Request %d: expected an event stream, got %q", i, ct)
+		}
+		out := w.Body.String()
+		for _, want := range []string{`"message_start"`, `"text":"hello"`, `"stop_reason":"end_turn"`, `"output_tokens":3`, `"message_stop"`} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Request %d: expected %s in %s", i, want, out)
+			}
+		}
+	}
+	if len(streamRequests) != 2 || !streamRequests[0] || streamRequests[1] {
+		t.Errorf("Expected a streaming request, then a plain one, got %v", streamRequests)
+	}
+	if atomic.LoadInt32(&cached) != 1 {
+		t.Errorf("Expected the finding to be reported once, got %d", cached)
+	}
+}
+
+func TestProxyUsesProbedStreaming(t *testing.T) {
+	var streamed bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		streamed = req.Stream
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(OpenAIResponse{Choices: []OpenAIChoice{{Message: OpenAIMessage{Content: "ok"}}}})
+	}))
+	defer upstream.Close()
+
+	proxy := NewOllamaProxy(upstream.URL, nil)
+	proxy.SetCapabilities(&BackendCapabilities{Streaming: supportNo}, nil)
+	body := []byte(`{"model":"llama3.2","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+	if streamed {
+		t.Error("Expected a plain upstream request for a backend probed as not streaming")
+	}
+	if !strings.Contains(w.Body.String(), `"text":"ok"`) {
+		t.Errorf("Expected the answer relayed as events, got %s", w.Body.String())
+	}
+}
+
+func TestWriteCapabilities(t *testing.T) {
+	var out bytes.Buffer
+	writeCapabilities(nil, &out)
+	if !strings.Contains(out.String(), "Not probed yet") {
+		t.Errorf("Unexpected output for unprobed backend: %s", out.String())
+	}
+
+	out.Reset()
+	writeCapabilities(&BackendCapabilities{
+		ProbedAt: time.Now(),
+		Models:   map[string]ModelCapabilities{"coder": {Tools: supportYes, ContextWindow: 131072}},
+	}, &out)
+	if !strings.Contains(out.String(), "coder: context 131.1k, tools yes, json ?") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
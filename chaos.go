@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chaosErrorBody is returned for injected failures, in the Anthropic error
+// format so Claude Code treats it like a provider outage
+const chaosErrorBody = `{"type":"error","error":{"type":"overloaded_error","message":"promptops chaos mode: injected upstream failure"}}`
+
+// ChaosConfig describes synthetic degradation injected into the local
+// proxies' upstream requests (NEXUS_CHAOS=latency:500ms,errors:5%)
+type ChaosConfig struct {
+	Latency   time.Duration
+	ErrorRate float64 // fraction of requests failed, 0 to 1
+}
+
+// Enabled reports whether any degradation is configured
+func (c ChaosConfig) Enabled() bool {
+	return c.Latency > 0 || c.ErrorRate > 0
+}
+
+// String describes the configuration for notices and the audit log
+func (c ChaosConfig) String() string {
+	var parts []string
+	if c.Latency > 0 {
+		parts = append(parts, fmt.Sprintf("%s added latency", c.Latency))
+	}
+	if c.ErrorRate > 0 {
+		parts = append(parts, fmt.Sprintf("%s upstream errors", strconv.FormatFloat(c.ErrorRate*100, 'f', -1, 64)+"%"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseChaos parses "latency:500ms,errors:5%". Either part may be omitted.
+func parseChaos(value string) (ChaosConfig, error) {
+	var c ChaosConfig
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kind, arg, ok := strings.Cut(part, ":")
+		if !ok {
+			return ChaosConfig{}, fmt.Errorf("invalid setting '%s': expected latency:<duration> or errors:<percent>%%", part)
+		}
+		switch strings.TrimSpace(kind) {
+		case "latency":
+			d, err := parseTimeout(strings.TrimSpace(arg))
+			if err != nil {
+				return ChaosConfig{}, fmt.Errorf("invalid latency '%s': %v", arg, err)
+			}
+			c.Latency = d
+		case "errors":
+			pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(arg), "%"), 64)
+			if err != nil || pct < 0 || pct > 100 {
+				return ChaosConfig{}, fmt.Errorf("invalid error rate '%s': must be a percentage between 0 and 100", arg)
+			}
+			c.ErrorRate = pct / 100
+		default:
+			return ChaosConfig{}, fmt.Errorf("unknown setting '%s': use latency or errors", kind)
+		}
+	}
+	return c, nil
+}
+
+// chaosTransport delays upstream requests and fails a share of them with a
+// synthetic 503 before they reach the provider
+type chaosTransport struct {
+	next   http.RoundTripper
+	config ChaosConfig
+	roll   func() float64
+}
+
+// wrapChaos returns next wrapped with the configured degradation, or next
+// itself when chaos mode is off
+func wrapChaos(next http.RoundTripper, c ChaosConfig) http.RoundTripper {
+	if !c.Enabled() {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &chaosTransport{next: next, config: c, roll: rand.Float64}
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.config.Latency > 0 {
+		select {
+		case <-time.After(t.config.Latency):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if t.config.ErrorRate > 0 && t.roll() < t.config.ErrorRate {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return &http.Response{
+			Status:     "503 Service Unavailable",
+			StatusCode: http.StatusServiceUnavailable,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(chaosErrorBody)),
+			Request:    req,
+		}, nil
+	}
+	return t.next.RoundTrip(req)
+}
+
+// EnableChaos injects synthetic latency and failures into upstream requests
+func (p *OllamaProxy) EnableChaos(c ChaosConfig) {
+	p.chaos = c
+	p.secureClient.Transport = wrapChaos(p.secureClient.Transport, c)
+}
+
+// EnableChaos injects synthetic latency and failures into upstream requests
+func (p *GrokProxy) EnableChaos(c ChaosConfig) {
+	p.chaos = c
+}
+
+// announceChaos warns that chaos mode is active for a launch through a local
+// proxy and notes it in the audit log
+func announceChaos(cfg *Config) {
+	if !cfg.Chaos.Enabled() {
+		return
+	}
+	fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] Chaos mode: injecting "+cfg.Chaos.String()))
+	auditLog(cfg, "CHAOS: "+cfg.Chaos.String())
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseChaos(t *testing.T) {
+	c, err := parseChaos("latency:500ms, errors:5%")
+	if err != nil || c.Latency != 500*time.Millisecond || c.ErrorRate != 0.05 {
+		t.Fatalf("Unexpected result %+v (err=%v)", c, err)
+	}
+	if c.String() != "500ms added latency, 5% upstream errors" {
+		t.Errorf("Unexpected description %q", c.String())
+	}
+	if c, _ := parseChaos("errors:100"); c.ErrorRate != 1 || c.Latency != 0 {
+		t.Errorf("Unexpected result %+v", c)
+	}
+	if c, _ := parseChaos(""); c.Enabled() {
+		t.Error("Expected chaos off for empty value")
+	}
+	for _, bad := range []string{"latency", "latency:soon", "errors:150%", "errors:-1", "drops:5%"} {
+		if _, err := parseChaos(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestChaosTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+
+	if rt := wrapChaos(http.DefaultTransport, ChaosConfig{}); rt != http.DefaultTransport {
+		t.Error("Expected transport unchanged when chaos is off")
+	}
+
+	rt := wrapChaos(nil, ChaosConfig{Latency: 20 * time.Millisecond, ErrorRate: 0.5}).(*chaosTransport)
+	rolls := []float64{0.9, 0.1}
+	rt.roll = func() float64 {
+		r := rolls[0]
+		rolls = rolls[1:]
+		return r
+	}
+	client := &http.Client{Transport: rt}
+
+	start := time.Now()
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected request to pass through, got %d", resp.StatusCode)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("Expected injected latency")
+	}
+
+	resp, err = client.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || string(body) != chaosErrorBody {
+		t.Errorf("Expected injected failure, got %d %s", resp.StatusCode, body)
+	}
+	pe := classifyProviderError(backends["ollama"], resp.StatusCode, resp.Header, body)
+	if pe.Kind != errKindOverloaded {
+		t.Errorf("Expected overloaded error, got %s", pe.Kind)
+	}
+}
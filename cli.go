@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// GlobalOptions are the global flags, given before or after the command name.
+// Optional ones are honoured only by the commands that list them.
+type GlobalOptions struct {
+	Env       string
+	ConfigDir string
+	Quiet     bool
+	JSON      bool
+	Wide      bool
+	Timeout   time.Duration
+}
+
+// globalOpts holds the global flags of this invocation
+var globalOpts GlobalOptions
+
+// globalFlag is a flag parsed for every command
+type globalFlag struct {
+	Name string
+	// Value names the flag's value in help; switches have none
+	Value string
+	Usage string
+	// Optional flags are only accepted by commands that list them
+	Optional bool
+	set      func(opts *GlobalOptions, value string) error
+}
+
+var globalFlags = []globalFlag{
+	{
+		Name: "env", Value: "<name>", Usage: "Use .env.<name>.local with its own usage, sessions and audit log",
+		set: func(o *GlobalOptions, v string) error { o.Env = v; return nil },
+	},
+	{
+		Name: "config-dir", Value: "<dir>", Usage: "Read env files and keep state in <dir> instead of next to the binary",
+		set: func(o *GlobalOptions, v string) error {
+			dir, err := filepath.Abs(v)
+			if err != nil {
+				return fmt.Errorf("invalid --config-dir value '%s': %w", v, err)
+			}
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return fmt.Errorf("invalid --config-dir value '%s': not a directory", v)
+			}
+			o.ConfigDir = dir
+			return nil
+		},
+	},
+	{
+		Name: "quiet", Usage: "Print only warnings and errors, as NEXUS_QUIET=true does",
+		set: func(o *GlobalOptions, _ string) error { o.Quiet = true; return nil },
+	},
+	{
+		Name: "json", Usage: "Print machine-readable JSON", Optional: true,
+		set: func(o *GlobalOptions, _ string) error { o.JSON = true; return nil },
+	},
+	{
+		Name: "wide", Usage: "Keep every table column and full cell text", Optional: true,
+		set: func(o *GlobalOptions, _ string) error { o.Wide = true; return nil },
+	},
+	{
+		Name: "timeout", Value: "<duration>", Usage: "Override health check, usage and service timeouts", Optional: true,
+		set: func(o *GlobalOptions, v string) error {
+			d, err := parseTimeout(v)
+			if err != nil {
+				return fmt.Errorf("invalid --timeout value '%s': %w", v, err)
+			}
+			o.Timeout = d
+			return nil
+		},
+	},
+}
+
+// findGlobalFlag returns the global flag named by arg, which may carry its
+// value as --name=value
+func findGlobalFlag(arg string) (*globalFlag, bool) {
+	if !strings.HasPrefix(arg, "--") {
+		return nil, false
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+	for i := range globalFlags {
+		if globalFlags[i].Name == name {
+			return &globalFlags[i], true
+		}
+	}
+	return nil, false
+}
+
+// command is an entry of the command table
+type command struct {
+	Names []string
+	// Launch commands pass their arguments to Claude Code, so only global
+	// flags ahead of the command name are parsed
+	Launch bool
+	// Flags are the optional global flags the command supports
+	Flags []string
+	Run   func(args []string)
+}
+
+// commandTable returns every command main dispatches: each backend, which
+// switches to it and launches Claude Code, then the other commands
+func commandTable() []command {
+	var cmds []command
+	for _, name := range doctorBackends {
+		name := name
+		cmds = append(cmds, command{Names: []string{name}, Launch: true, Flags: []string{"timeout"},
+			Run: func(args []string) { switchBackend(name, args) }})
+	}
+	return append(cmds, []command{
+		{Names: []string{"status", "current"}, Flags: []string{"wide", "timeout"}, Run: showStatus},
+		// Endpoint, models and provider API versions a launch would use
+		{Names: []string{"which"}, Run: handleWhichCommand},
+		{Names: []string{"diff-backend"}, Run: handleDiffBackend},
+		// Model catalogs - live, or from saved snapshots for offline use
+		{Names: []string{"models"}, Run: handleModelsCommand},
+		{Names: []string{"snapshot-models"}, Run: handleSnapshotModels},
+		// Recent proxy decisions for debugging a misbehaving session
+		{Names: []string{"debug"}, Run: handleDebugCommand},
+		{Names: []string{"inspect-env"}, Flags: []string{"json"}, Run: handleInspectEnv},
+		// Write usage records spooled while the usage file was unavailable
+		{Names: []string{"flush"}, Run: handleFlushCommand},
+		// Tamper evidence for signed usage and audit records
+		{Names: []string{"audit"}, Flags: []string{"json"}, Run: handleAuditCommand},
+		// Companion local services (ollama serve, vLLM containers, gateways)
+		{Names: []string{"services"}, Flags: []string{"timeout"}, Run: handleServicesCommand},
+		{Names: []string{"run", "launch"}, Launch: true, Flags: []string{"timeout"}, Run: runClaude},
+		{Names: []string{"init", "setup"}, Run: func([]string) { initEnv() }},
+		{Names: []string{"version", "--version", "-v"}, Run: func([]string) { showVersion() }},
+		{Names: []string{"help", "--help", "-h"}, Run: showHelp},
+		// Cost tracking commands
+		{Names: []string{"cost"}, Flags: []string{"wide"}, Run: handleCostCommand},
+		// Budget management commands
+		{Names: []string{"budget"}, Run: handleBudgetCommand},
+		{Names: []string{"credits"}, Run: handleCreditsCommand},
+		// Environment validation commands
+		{Names: []string{"doctor"}, Flags: []string{"wide", "timeout"}, Run: runDoctor},
+		{Names: []string{"gc"}, Run: handleGCCommand},
+		{Names: []string{"validate"}, Flags: []string{"timeout"}, Run: validateBackend},
+		// Session management commands
+		{Names: []string{"session"}, Flags: []string{"wide"}, Run: handleSessionCommand},
+		// Session migration - continue a conversation on another backend
+		{Names: []string{"migrate-session"}, Run: handleMigrateSession},
+		// Swarm - parallel isolated proxies for multi-agent runs
+		{Names: []string{"swarm"}, Flags: []string{"json"}, Run: handleSwarmCommand},
+		// Claude Code hooks - budget and policy gate inside the agent loop
+		{Names: []string{"hooks"}, Run: handleHooksCommand},
+		// Local HTTP API for IDE plugins and dashboards
+		{Names: []string{"api"}, Run: handleAPICommand},
+		// Usage command - fetch real API usage from providers
+		{Names: []string{"usage"}, Flags: []string{"timeout"}, Run: showAPIUsage},
+		// Prompt index - duplicate prompt hints from past prompts
+		{Names: []string{"prompts"}, Run: handlePromptsCommand},
+		// Self test - verify an install end to end against a local mock backend
+		{Names: []string{"selftest"}, Run: func([]string) { handleSelftest() }},
+		// State bundles - migrate config, sessions and usage history
+		{Names: []string{"export-state"}, Run: handleExportState},
+		{Names: []string{"import-state"}, Run: handleImportState},
+	}...)
+}
+
+// findCommand returns the command named name
+func findCommand(cmds []command, name string) (command, bool) {
+	for _, c := range cmds {
+		if slices.Contains(c.Names, name) {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// commandFlags returns the global flags a command accepts, for its help page
+func commandFlags(name string) []globalFlag {
+	cmd, _ := findCommand(commandTable(), name)
+	var flags []globalFlag
+	for _, f := range globalFlags {
+		if !f.Optional || slices.Contains(cmd.Flags, f.Name) {
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+// flagCommands returns the commands that support an optional global flag,
+// listing backends once
+func flagCommands(flag string) []string {
+	var names []string
+	launches := false
+	for _, c := range commandTable() {
+		if !slices.Contains(c.Flags, flag) {
+			continue
+		}
+		if _, ok := backends[c.Names[0]]; ok {
+			launches = true
+			continue
+		}
+		names = append(names, c.Names[0])
+	}
+	if launches {
+		names = append(names, "backend launches")
+	}
+	return names
+}
+
+// errUnknownCommand is returned by parseCommandLine for a command that is not
+// in the table
+var errUnknownCommand = errors.New("unknown command")
+
+// parseCommandLine splits argv into a command, its arguments and the global
+// flags. No command shows the status. Global flags are taken from anywhere
+// before a "--" argument, except that launch arguments are left to Claude
+// Code. An optional flag the command does not support is an error.
+func parseCommandLine(cmds []command, argv []string) (cmd command, name string, args []string, opts GlobalOptions, err error) {
+	var seen []string
+	// parse consumes the global flag at argv[i], returning how many
+	// arguments it took or 0 when argv[i] is not a global flag
+	parse := func(argv []string, i int) (int, error) {
+		f, ok := findGlobalFlag(argv[i])
+		if !ok {
+			return 0, nil
+		}
+		used := 1
+		_, value, hasValue := strings.Cut(argv[i], "=")
+		switch {
+		case f.Value == "" && hasValue:
+			return 0, fmt.Errorf("--%s does not take a value", f.Name)
+		case f.Value != "" && !hasValue:
+			if i+1 >= len(argv) {
+				return 0, fmt.Errorf("--%s requires %s", f.Name, f.Value)
+			}
+			value = argv[i+1]
+			used = 2
+		}
+		if err := f.set(&opts, value); err != nil {
+			return 0, err
+		}
+		seen = append(seen, f.Name)
+		return used, nil
+	}
+
+	i := 0
+	for i < len(argv) {
+		n, err := parse(argv, i)
+		if err != nil {
+			return cmd, "", nil, opts, err
+		}
+		if n == 0 {
+			break
+		}
+		i += n
+	}
+
+	name = "status"
+	if i < len(argv) {
+		name = argv[i]
+		i++
+	}
+	cmd, ok := findCommand(cmds, name)
+	if !ok {
+		return cmd, name, nil, opts, errUnknownCommand
+	}
+
+	if cmd.Launch {
+		args = argv[i:]
+	} else {
+		for i < len(argv) {
+			if argv[i] == "--" {
+				args = append(args, argv[i:]...)
+				break
+			}
+			n, err := parse(argv, i)
+			if err != nil {
+				return cmd, name, nil, opts, err
+			}
+			if n == 0 {
+				args = append(args, argv[i])
+				n = 1
+			}
+			i += n
+		}
+	}
+
+	for _, flag := range seen {
+		f, _ := findGlobalFlag("--" + flag)
+		if f.Optional && !slices.Contains(cmd.Flags, flag) {
+			return cmd, name, nil, opts, fmt.Errorf("--%s is not supported for 'promptops %s'; it applies to %s",
+				flag, name, strings.Join(flagCommands(flag), ", "))
+		}
+	}
+	return cmd, name, args, opts, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setGlobalOptions sets the global flags for the length of a test
+func setGlobalOptions(t *testing.T, opts GlobalOptions) {
+	t.Helper()
+	old := globalOpts
+	globalOpts = opts
+	t.Cleanup(func() { globalOpts = old })
+}
+
+func TestParseCommandLine(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		argv     []string
+		wantCmd  string
+		wantArgs string
+		wantOpts GlobalOptions
+	}{
+		{"no_command", nil, "status", "", GlobalOptions{}},
+		{"leading_flags", []string{"--env", "prod", "--wide", "cost", "report"}, "cost", "report", GlobalOptions{Env: "prod", Wide: true}},
+		{"trailing_flags", []string{"doctor", "--required", "kimi", "--timeout=15s"}, "doctor", "--required kimi", GlobalOptions{Timeout: 15 * time.Second}},
+		{"only_flags", []string{"--env=dev", "--quiet"}, "status", "", GlobalOptions{Env: "dev", Quiet: true}},
+		{"config_dir", []string{"--config-dir", dir, "gc"}, "gc", "", GlobalOptions{ConfigDir: dir}},
+		{"alias", []string{"current", "--details"}, "current", "--details", GlobalOptions{}},
+		{"json", []string{"inspect-env", "--last-launch", "--json"}, "inspect-env", "--last-launch", GlobalOptions{JSON: true}},
+		// Launch arguments go to Claude Code untouched
+		{"launch", []string{"--timeout", "5s", "deepseek", "--quiet", "--json"}, "deepseek", "--quiet --json", GlobalOptions{Timeout: 5 * time.Second}},
+		{"run", []string{"run", "--env", "x"}, "run", "--env x", GlobalOptions{}},
+		{"separator", []string{"hooks", "check", "--", "--quiet"}, "hooks", "check -- --quiet", GlobalOptions{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, name, args, opts, err := parseCommandLine(commandTable(), tt.argv)
+			if err != nil {
+				t.Fatalf("parseCommandLine() error: %v", err)
+			}
+			if name != tt.wantCmd || strings.Join(args, " ") != tt.wantArgs {
+				t.Errorf("parseCommandLine() = %q %v, want %q %q", name, args, tt.wantCmd, tt.wantArgs)
+			}
+			if opts != tt.wantOpts {
+				t.Errorf("options = %+v, want %+v", opts, tt.wantOpts)
+			}
+		})
+	}
+}
+
+func TestParseCommandLineErrors(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want string
+	}{
+		{[]string{"cost", "--json"}, "--json is not supported for 'promptops cost'"},
+		{[]string{"--json", "status"}, "--json is not supported for 'promptops status'; it applies to inspect-env, audit, swarm"},
+		{[]string{"--wide", "budget", "status"}, "--wide is not supported for 'promptops budget'"},
+		{[]string{"--wide", "kimi"}, "--wide is not supported for 'promptops kimi'"},
+		{[]string{"doctor", "--timeout"}, "--timeout requires <duration>"},
+		{[]string{"doctor", "--timeout", "later"}, "invalid --timeout value 'later'"},
+		{[]string{"--env"}, "--env requires <name>"},
+		{[]string{"--quiet=yes", "status"}, "--quiet does not take a value"},
+		{[]string{"--config-dir", "/nonexistent/promptops", "status"}, "not a directory"},
+	}
+	for _, tt := range tests {
+		_, _, _, _, err := parseCommandLine(commandTable(), tt.argv)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseCommandLine(%v) error = %v, want %q", tt.argv, err, tt.want)
+		}
+	}
+
+	_, name, _, _, err := parseCommandLine(commandTable(), []string{"--quiet", "statsu"})
+	if !errors.Is(err, errUnknownCommand) || name != "statsu" {
+		t.Errorf("Expected an unknown command, got %q %v", name, err)
+	}
+}
+
+func TestCommandTableHelp(t *testing.T) {
+	cmds := commandTable()
+	for _, c := range cmds {
+		for _, name := range c.Names {
+			if strings.HasPrefix(name, "-") {
+				continue
+			}
+			if _, ok := backends[name]; ok {
+				continue
+			}
+			if _, ok := findHelpPage(name); !ok {
+				t.Errorf("Command %q has no help page", name)
+			}
+		}
+		for _, flag := range c.Flags {
+			if f, ok := findGlobalFlag("--" + flag); !ok || !f.Optional {
+				t.Errorf("Command %q lists %q, which is not an optional global flag", c.Names[0], flag)
+			}
+		}
+	}
+	for _, p := range helpPages {
+		for _, name := range p.Commands {
+			if _, ok := findCommand(cmds, name); !ok {
+				t.Errorf("Help page for %q has no command", name)
+			}
+		}
+	}
+}
+
+func TestGlobalOptionsConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("NEXUS_ENV_FILE", "")
+	setGlobalOptions(t, GlobalOptions{ConfigDir: dir, Quiet: true, Timeout: 3 * time.Second})
+	if got, err := getScriptDir(); err != nil || got != dir {
+		t.Errorf("getScriptDir() = %q, %v, want %q", got, err, dir)
+	}
+	cfg := loadConfig()
+	if cfg.verbose() {
+		t.Error("Expected --quiet to silence launches")
+	}
+	if cfg.TimeoutOverride != 3*time.Second {
+		t.Errorf("Expected --timeout applied, got %v", cfg.TimeoutOverride)
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	var names []string
+	for _, f := range commandFlags("status") {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, " "); got != "env config-dir quiet wide timeout" {
+		t.Errorf("commandFlags(status) = %s", got)
+	}
+	if got := strings.Join(flagCommands("json"), ", "); got != "inspect-env, audit, swarm" {
+		t.Errorf("flagCommands(json) = %s", got)
+	}
+}
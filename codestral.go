@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Codestral fill-in-the-middle routing for the mistral backend
+const (
+	codestralBaseURL = "https://codestral.mistral.ai/v1"
+	defaultFIMModel  = "codestral-latest"
+	// fimUpstream names FIM completions in usage records and proxy decisions
+	fimUpstream = "codestral"
+	// Codestral price per 1M tokens, billed apart from Mistral Large chat
+	codestralInputPrice  = 0.30
+	codestralOutputPrice = 0.90
+)
+
+// FIMRoute is the endpoint the proxy sends fill-in-the-middle requests to.
+// A nil Auth sends Credential as a bearer token.
+type FIMRoute struct {
+	BaseURL    string
+	Model      string
+	Credential string
+	Auth       AuthStrategy
+}
+
+// FIMRequest is a Mistral /fim/completions request
+type FIMRequest struct {
+	Model       string   `json:"model"`
+	Prompt      string   `json:"prompt"`
+	Suffix      string   `json:"suffix,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+}
+
+// fimMarkers are the prefix, suffix and middle sentinels editors use to ask a
+// chat endpoint for an infill. Claude Code never sends them: the route serves
+// completion plugins pointed at the mistral proxy while a session runs.
+var fimMarkers = [][3]string{
+	{"<|fim_prefix|>", "<|fim_suffix|>", "<|fim_middle|>"},
+	{"<fim_prefix>", "<fim_suffix>", "<fim_middle>"},
+}
+
+// parseFIMRequest returns the code before and after the gap when req asks for
+// an infill: a single user turn without tools whose text is prefix, suffix
+// and middle markers in order, with nothing after the middle marker
+func parseFIMRequest(req AnthropicRequest) (prefix, suffix string, ok bool) {
+	if len(req.Tools) > 0 || len(req.Messages) != 1 || req.Messages[0].Role != "user" {
+		return "", "", false
+	}
+	text := req.Messages[0].GetContentText()
+	for _, m := range fimMarkers {
+		i := strings.Index(text, m[0])
+		j := strings.Index(text, m[1])
+		k := strings.LastIndex(text, m[2])
+		if i < 0 || j < i || k < j || strings.TrimSpace(text[k+len(m[2]):]) != "" {
+			continue
+		}
+		return text[i+len(m[0]) : j], text[j+len(m[1]) : k], true
+	}
+	return "", "", false
+}
+
+// fimRoute returns where the mistral backend sends infill requests: the
+// dedicated Codestral endpoint when CODESTRAL_API_KEY is set, otherwise
+// Mistral's own FIM endpoint with MISTRAL_API_KEY. Both authenticate the
+// way the mistral backend is configured to.
+func fimRoute(cfg *Config) (FIMRoute, bool) {
+	model := cfg.FIMModel
+	if model == "" {
+		model = defaultFIMModel
+	}
+	if key := cfg.Keys["CODESTRAL_API_KEY"]; key != "" {
+		baseURL := cfg.CodestralURL
+		if baseURL == "" {
+			baseURL = codestralBaseURL
+		}
+		return FIMRoute{BaseURL: baseURL, Model: model, Credential: key, Auth: cfg.authStrategy(backends["mistral"])}, true
+	}
+	if key := cfg.Keys["MISTRAL_API_KEY"]; key != "" {
+		return FIMRoute{BaseURL: backends["mistral"].BaseURL, Model: model, Credential: key, Auth: cfg.authStrategy(backends["mistral"])}, true
+	}
+	return FIMRoute{}, false
+}
+
+// usagePricing returns the price per 1M tokens of a usage record at t.
+// Mistral FIM completions are billed at Codestral rates, and opus-tier
+// requests at the backend's opus price when it has one.
+func usagePricing(cfg *Config, be Backend, upstream, tier string, t time.Time) (inputPrice, outputPrice float64) {
+	if be.Name == "mistral" && upstream == fimUpstream {
+		return codestralInputPrice, codestralOutputPrice
+	}
+	if tier == "opus" && be.OpusOutputPrice > 0 {
+		return be.OpusInputPrice, be.OpusOutputPrice
+	}
+	return pricingAt(cfg, be, t)
+}
+
+// SetFIMRoute sends infill requests to route's /fim/completions instead of
+// the chat upstream. record is called with their usage in place of the
+// usage recorder, so they can be accounted to the FIM endpoint.
+func (p *OllamaProxy) SetFIMRoute(route FIMRoute, record func(model string, usage AnthropicUsage)) {
+	p.fim = &route
+	p.recordFIM = record
+}
+
+// handleFIMMessage answers an infill message request from the FIM endpoint
+func (p *OllamaProxy) handleFIMMessage(w http.ResponseWriter, anthReq AnthropicRequest, prefix, suffix string) AnthropicUsage {
+	body, err := json.Marshal(FIMRequest{
+		Model:       p.fim.Model,
+		Prompt:      prefix,
+		Suffix:      suffix,
+		MaxTokens:   anthReq.MaxTokens,
+		Temperature: anthReq.Temperature,
+		TopP:        anthReq.TopP,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return AnthropicUsage{}
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(p.fim.BaseURL, "/")+"/fim/completions", bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return AnthropicUsage{}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	auth := p.fim.Auth
+	if auth == nil {
+		auth = BearerAuth{}
+	}
+	if err := auth.Apply(req, p.fim.Credential); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return AnthropicUsage{}
+	}
+
+	resp, err := p.secureClient.Do(req)
+	if err != nil {
+		if isTimeout(err) {
+			p.writeTimeout(w)
+			return AnthropicUsage{}
+		}
+		http.Error(w, sanitizeError(err).Error(), http.StatusBadGateway)
+		return AnthropicUsage{}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		p.writeUpstreamError(w, resp)
+		return AnthropicUsage{}
+	}
+
+	// FIM completions share the chat completion response format
+	var completion OpenAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		if isTimeout(err) {
+			p.writeTimeout(w)
+			return AnthropicUsage{}
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return AnthropicUsage{}
+	}
+	_, usage := p.writeCompletion(w, completion, anthReq.Model, p.fim.Model, anthReq.Stream)
+	return usage
+}
+
+// validateCodestralURL accepts https URLs, and http only on localhost, since
+// the Codestral key is sent with every request
+func validateCodestralURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("expected a URL such as %s", codestralBaseURL)
+	}
+	if u.Scheme == "https" {
+		return nil
+	}
+	if host := u.Hostname(); u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1") {
+		return nil
+	}
+	return fmt.Errorf("the Codestral endpoint must use https")
+}
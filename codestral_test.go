@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseFIMRequest(t *testing.T) {
+	parse := func(body string) (string, string, bool) {
+		var req AnthropicRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatal(err)
+		}
+		return parseFIMRequest(req)
+	}
+
+	prefix, suffix, ok := parse(`{"messages":[{"role":"user","content":"<|fim_prefix|>func add(a, b int) int {\n<|fim_suffix|>\n}<|fim_middle|>"}]}`)
+	if !ok || prefix != "func add(a, b int) int {\n" || suffix != "\n}" {
+		t.Errorf("Unexpected infill %q %q %v", prefix, suffix, ok)
+	}
+	if _, _, ok := parse(`{"messages":[{"role":"user","content":[{"type":"text","text":"<fim_prefix>a<fim_suffix>c<fim_middle>\n"}]}]}`); !ok {
+		t.Error("Expected block content with plain markers to be an infill")
+	}
+
+	notFIM := []string{
+		`{"messages":[{"role":"user","content":"Fix the bug in main.go"}]}`,
+		`{"messages":[{"role":"user","content":"<|fim_prefix|>a<|fim_middle|>b<|fim_suffix|>"}]}`,
+		`{"messages":[{"role":"user","content":"<|fim_prefix|>a<|fim_suffix|>b<|fim_middle|> and explain"}]}`,
+		`{"tools":[{"name":"Read"}],"messages":[{"role":"user","content":"<|fim_prefix|>a<|fim_suffix|>b<|fim_middle|>"}]}`,
+		`{"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"<|fim_prefix|>a<|fim_suffix|>b<|fim_middle|>"}]}`,
+	}
+	for _, body := range notFIM {
+		if _, _, ok := parse(body); ok {
+			t.Errorf("Expected no infill for %s", body)
+		}
+	}
+}
+
+func TestFIMRoute(t *testing.T) {
+	cfg := &Config{Keys: map[string]string{}}
+	if _, ok := fimRoute(cfg); ok {
+		t.Error("Expected no route without a key")
+	}
+
+	cfg.Keys["MISTRAL_API_KEY"] = "mistral-key"
+	route, ok := fimRoute(cfg)
+	if !ok || route.BaseURL != backends["mistral"].BaseURL || route.Credential != "mistral-key" || route.Model != defaultFIMModel {
+		t.Errorf("Expected the Mistral FIM endpoint, got %+v", route)
+	}
+
+	cfg.Keys["CODESTRAL_API_KEY"] = "codestral-key"
+	cfg.FIMModel = "codestral-2501"
+	route, _ = fimRoute(cfg)
+	if route.BaseURL != codestralBaseURL || route.Credential != "codestral-key" || route.Model != "codestral-2501" {
+		t.Errorf("Expected the Codestral endpoint, got %+v", route)
+	}
+
+	cfg.AuthStrategies = map[string]AuthStrategy{"mistral": HeaderAuth{Header: "api-key"}}
+	if route, _ = fimRoute(cfg); route.Auth != (HeaderAuth{Header: "api-key"}) {
+		t.Errorf("Expected the mistral auth strategy, got %v", route.Auth)
+	}
+}
+
+func TestValidateCodestralURL(t *testing.T) {
+	for _, u := range []string{"https://codestral.mistral.ai/v1", "http://localhost:8080/v1"} {
+		if err := validateCodestralURL(u); err != nil {
+			t.Errorf("Expected %s accepted: %v", u, err)
+		}
+	}
+	for _, u := range []string{"http://codestral.example.com/v1", "codestral", "ftp://localhost"} {
+		if err := validateCodestralURL(u); err == nil {
+			t.Errorf("Expected %s rejected", u)
+		}
+	}
+}
+
+func TestUsagePricingCodestral(t *testing.T) {
+	cfg := &Config{}
+	mistral := backends["mistral"]
+	if in, out := usagePricing(cfg, mistral, fimUpstream, "", time.Now()); in != codestralInputPrice || out != codestralOutputPrice {
+		t.Errorf("Expected Codestral pricing, got %v/%v", in, out)
+	}
+	if in, out := usagePricing(cfg, mistral, "", "", time.Now()); in != mistral.InputPrice || out != mistral.OutputPrice {
+		t.Errorf("Expected Mistral pricing for chat, got %v/%v", in, out)
+	}
+	if in, _ := usagePricing(cfg, backends["ollama"], fimUpstream, "", time.Now()); in != backends["ollama"].InputPrice {
+		t.Error("Expected a local upstream named codestral to keep the backend's pricing")
+	}
+}
+
+func TestProxyRoutesFIMToCodestral(t *testing.T) {
+	var fimReq FIMRequest
+	var fimAuth string
+	fim := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fim/completions" {
+			t.Errorf("Expected /fim/completions, got %s", r.URL.Path)
+		}
+		fimAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&fimReq)
+		w.Write([]byte(`{"model":"codestral-latest","choices":[{"message":{"content":"return a + b"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":4}}`))
+	}))
+	defer fim.Close()
+
+	var chatReq map[string]any
+	chat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&chatReq)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"choices":[{"delta":{"content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}` + "\n\ndata: [DONE]\n\n"))
+	}))
+	defer chat.Close()
+
+	proxy := NewOllamaProxy(chat.URL, map[string]string{})
+	proxy.SetUpstreamAuth("mistral", nil, "mistral-key")
+	proxy.OmitStreamOptions()
+	var chatUsage, fimUsage AnthropicUsage
+	var decisions []ProxyDecision
+	proxy.SetUsageRecorder(func(model string, usage AnthropicUsage) { chatUsage = usage })
+	proxy.SetDecisionRecorder(func(d ProxyDecision) { decisions = append(decisions, d) })
+	proxy.SetFIMRoute(FIMRoute{BaseURL: fim.URL, Model: "codestral-latest", Credential: "codestral-key"},
+		func(model string, usage AnthropicUsage) { fimUsage = usage })
+
+	body := []byte(`{"model":"codestral-latest","max_tokens":64,"messages":[{"role":"user","content":"<|fim_prefix|>func add(a, b int) int {\n\t<|fim_suffix|>\n}<|fim_middle|>"}]}`)
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+
+	var resp AnthropicResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.Content) != 1 || resp.Content[0].Text != "return a + b" {
+		t.Fatalf("Unexpected response %d %s", w.Code, w.Body.String())
+	}
+	if fimReq.Prompt != "func add(a, b int) int {\n\t" || fimReq.Suffix != "\n}" || fimReq.MaxTokens != 64 {
+		t.Errorf("Unexpected FIM request %+v", fimReq)
+	}
+	if fimAuth != "Bearer codestral-key" {
+		t.Errorf("Expected the Codestral key, got %q", fimAuth)
+	}
+	if fimUsage.InputTokens != 12 || fimUsage.OutputTokens != 4 || chatUsage.InputTokens != 0 {
+		t.Errorf("Expected usage recorded against the FIM endpoint only, got fim %+v chat %+v", fimUsage, chatUsage)
+	}
+
+	// Chat requests keep going to the chat upstream
+	body = []byte(`{"model":"mistral-large-latest","stream":true,"messages":[{"role":"user","content":"hello"}]}`)
+	w = httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+	if chatReq["model"] != "mistral-large-latest" {
+		t.Errorf("Expected the chat request upstream, got %v", chatReq)
+	}
+	if _, ok := chatReq["stream_options"]; ok {
+		t.Error("Expected stream_options left out")
+	}
+	if chatUsage.InputTokens != 3 {
+		t.Errorf("Expected chat usage recorded, got %+v", chatUsage)
+	}
+	if len(decisions) != 2 || decisions[0].Upstream != fimUpstream || decisions[1].Upstream == fimUpstream {
+		t.Errorf("Unexpected decisions %+v", decisions)
+	}
+
+	// A configured strategy replaces the bearer token
+	proxy.fim.Auth = HeaderAuth{Header: "api-key"}
+	var fimKey string
+	body = []byte(`{"model":"codestral-latest","messages":[{"role":"user","content":"<fim_prefix>a<fim_suffix>c<fim_middle>"}]}`)
+	fim.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fimAuth, fimKey = r.Header.Get("Authorization"), r.Header.Get("api-key")
+		w.Write([]byte(`{"model":"codestral-latest","choices":[{"message":{"content":"b"},"finish_reason":"stop"}]}`))
+	})
+	proxy.handleMessages(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+	if fimAuth != "" || fimKey != "codestral-key" {
+		t.Errorf("Expected the key in the api-key header, got %q %q", fimAuth, fimKey)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Compaction defaults
+const (
+	defaultCompactKeep      = 6
+	compactSummaryMaxTokens = 1024
+	compactSummaryPrompt    = "Summarize the conversation below so it can replace the original messages. " +
+		"Keep decisions, file names, code identifiers, open tasks and constraints. Be concise."
+)
+
+// estimateTokens approximates the token count of messages using the
+// tokenizer selected for model
+func estimateTokens(model string, msgs []OpenAIMessage) int {
+	total := 0
+	for _, m := range msgs {
+		total += countTokens(model, m.Content)
+	}
+	return total
+}
+
+// hashMessages returns a stable fingerprint of a message prefix
+func hashMessages(msgs []OpenAIMessage) string {
+	h := sha256.New()
+	for _, m := range msgs {
+		h.Write([]byte(m.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(m.Content))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Compactor tracks conversation growth for a proxied session and, once the
+// history exceeds a token threshold, replaces older turns with a summary
+// produced by a cheaper model. Claude Code resends the full history on every
+// request, so the summary is remembered and reused for as long as the
+// conversation prefix it replaced is unchanged.
+type Compactor struct {
+	threshold int
+	keep      int
+	summarize func([]OpenAIMessage) (string, error)
+
+	mu          sync.Mutex
+	cut         int    // number of conversation messages replaced by summary
+	prefixHash  string // fingerprint of the replaced messages
+	summary     string
+	compactions int
+	tokensSaved int // estimated tokens removed from the latest request
+}
+
+// NewCompactor creates a compactor that summarizes history above threshold
+// tokens while keeping the most recent keep messages verbatim
+func NewCompactor(threshold, keep int, summarize func([]OpenAIMessage) (string, error)) *Compactor {
+	if keep <= 0 {
+		keep = defaultCompactKeep
+	}
+	return &Compactor{threshold: threshold, keep: keep, summarize: summarize}
+}
+
+// Compact returns the messages to forward to model, with older turns
+// summarized when the conversation is over the threshold. On summarization
+// failure the original messages are returned unchanged. The lock is not
+// held while summarizing, so a slow summary model does not hold up other
+// requests of the session.
+func (c *Compactor) Compact(model string, msgs []OpenAIMessage) []OpenAIMessage {
+	// Leading system messages are never compacted
+	split := 0
+	for split < len(msgs) && msgs[split].Role == "system" {
+		split++
+	}
+	system, conv := msgs[:split], msgs[split:]
+	original := estimateTokens(model, msgs)
+
+	// Reuse the previous summary while the conversation still starts with the
+	// messages it replaced
+	offset := 0
+	summary := ""
+	c.mu.Lock()
+	if c.cut > 0 && c.cut <= len(conv) && hashMessages(conv[:c.cut]) == c.prefixHash {
+		offset = c.cut
+		summary = c.summary
+	}
+	c.mu.Unlock()
+	rest := conv[offset:]
+
+	current := withSummary(system, summary, rest)
+	if estimateTokens(model, current) <= c.threshold {
+		if summary != "" {
+			c.mu.Lock()
+			c.tokensSaved = original - estimateTokens(model, current)
+			c.mu.Unlock()
+		}
+		return current
+	}
+
+	// Keep the most recent messages, starting the kept tail on a user turn
+	cut := len(rest) - c.keep
+	for cut > 0 && cut < len(rest) && rest[cut].Role != "user" {
+		cut++
+	}
+	if cut <= 0 || cut >= len(rest) {
+		return current
+	}
+
+	toSummarize := rest[:cut]
+	if summary != "" {
+		toSummarize = append([]OpenAIMessage{{Role: "user", Content: "Earlier summary:\n" + summary}}, toSummarize...)
+	}
+	newSummary, err := c.summarize(toSummarize)
+	if err != nil || strings.TrimSpace(newSummary) == "" {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: context compaction failed: %v\n", sanitizeError(err))
+		}
+		return current
+	}
+
+	compacted := withSummary(system, newSummary, rest[cut:])
+	after := estimateTokens(model, compacted)
+
+	// A concurrent request may have summarized further meanwhile; keep the
+	// summary that replaces the most messages
+	c.mu.Lock()
+	if offset+cut >= c.cut {
+		c.cut = offset + cut
+		c.prefixHash = hashMessages(conv[:c.cut])
+		c.summary = newSummary
+	}
+	c.compactions++
+	c.tokensSaved = original - after
+	c.mu.Unlock()
+	fmt.Fprintln(os.Stderr, styleMuted.Render(fmt.Sprintf("[promptops] compacted %d messages (~%s -> ~%s tokens)",
+		offset+cut, formatNumber(int64(original)), formatNumber(int64(after)))))
+	return compacted
+}
+
+// Stats returns the number of compactions and the estimated tokens the
+// latest request saved compared to sending the full history
+func (c *Compactor) Stats() (compactions, tokensSaved int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.compactions, c.tokensSaved
+}
+
+// withSummary assembles system messages, an optional summary and the remaining turns
+func withSummary(system []OpenAIMessage, summary string, rest []OpenAIMessage) []OpenAIMessage {
+	out := make([]OpenAIMessage, 0, len(system)+len(rest)+1)
+	out = append(out, system...)
+	if summary != "" {
+		out = append(out, OpenAIMessage{
+			Role:    "system",
+			Content: "Summary of the earlier conversation:\n" + summary,
+		})
+	}
+	return append(out, rest...)
+}
+
+// EnableCompaction turns on context compaction for proxied requests.
+// Summaries are generated by model (typically the haiku tier) on the same
+// upstream, and record is called with the usage of each summary request.
+// Responses API upstreams are not compacted: their requests carry tool calls
+// and results as typed input items, which the text summary would drop.
+func (p *OllamaProxy) EnableCompaction(threshold, keep int, model string, record func(model string, usage AnthropicUsage)) error {
+	if p.protocol == protocolOpenAIResponses {
+		return errors.New("context compaction is not supported on the Responses API")
+	}
+	p.compactModel = p.mapModel(model)
+	p.recordSummary = record
+	p.compactor = NewCompactor(threshold, keep, p.summarizeMessages)
+	return nil
+}
+
+// summarizeMessages asks the upstream to summarize a conversation prefix
+func (p *OllamaProxy) summarizeMessages(msgs []OpenAIMessage) (string, error) {
+	var transcript strings.Builder
+	for _, m := range msgs {
+		fmt.Fprintf(&transcript, "%s: %s\n\n", m.Role, m.Content)
+	}
+
+	body, err := json.Marshal(OpenAIRequest{
+		Model:     p.compactModel,
+		MaxTokens: compactSummaryMaxTokens,
+		Messages: []OpenAIMessage{
+			{Role: "system", Content: compactSummaryPrompt},
+			{Role: "user", Content: transcript.String()},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, baseURL := p.upstreamFor(p.compactModel)
+	req, err := http.NewRequest("POST", baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := p.authorize(req, baseURL); err != nil {
+		return "", err
+	}
+
+	resp, err := p.secureClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("summary request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("summary request failed: HTTP %d", resp.StatusCode)
+	}
+
+	var result OpenAIResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode summary: %w", err)
+	}
+	usage := AnthropicUsage{InputTokens: result.Usage.PromptTokens, OutputTokens: result.Usage.CompletionTokens}
+	if p.recordSummary != nil && usage.InputTokens+usage.OutputTokens > 0 {
+		p.recordSummary(p.compactModel, usage)
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("empty summary returned")
+	}
+	return result.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func conversation(turns int, size int) []OpenAIMessage {
+	msgs := []OpenAIMessage{{Role: "system", Content: "You are a coding assistant."}}
+	for i := 0; i < turns; i++ {
+		msgs = append(msgs,
+			OpenAIMessage{Role: "user", Content: strings.Repeat("q", size) + string(rune('a'+i))},
+			OpenAIMessage{Role: "assistant", Content: strings.Repeat("r", size)},
+		)
+	}
+	return msgs
+}
+
+func TestCompactorBelowThreshold(t *testing.T) {
+	calls := 0
+	c := NewCompactor(1000, 2, func([]OpenAIMessage) (string, error) {
+		calls++
+		return "summary", nil
+	})
+
+	msgs := conversation(2, 40)
+	out := c.Compact("llama3.3", msgs)
+	if len(out) != len(msgs) || calls != 0 {
+		t.Errorf("Expected messages unchanged without summarizing, got %d messages, %d calls", len(out), calls)
+	}
+}
+
+func TestCompactorSummarizesAndReuses(t *testing.T) {
+	var summarized [][]OpenAIMessage
+	c := NewCompactor(500, 2, func(msgs []OpenAIMessage) (string, error) {
+		summarized = append(summarized, msgs)
+		return "earlier work", nil
+	})
+
+	msgs := conversation(6, 400) // ~1200 tokens
+	out := c.Compact("llama3.3", msgs)
+
+	if len(summarized) != 1 {
+		t.Fatalf("Expected 1 summarization, got %d", len(summarized))
+	}
+	if out[0].Role != "system" || out[0].Content != msgs[0].Content {
+		t.Error("Expected original system prompt to be kept first")
+	}
+	if !strings.Contains(out[1].Content, "earlier work") {
+		t.Errorf("Expected summary message, got %q", out[1].Content)
+	}
+	if tail := out[len(out)-2:]; tail[0].Content != msgs[len(msgs)-2].Content || tail[0].Role != "user" {
+		t.Error("Expected the most recent turns to be kept verbatim")
+	}
+	if estimateTokens("llama3.3", out) >= estimateTokens("llama3.3", msgs) {
+		t.Error("Expected compacted request to be smaller")
+	}
+
+	// The next request resends the full history plus a small new turn; the
+	// stored summary is reused without another summarization call
+	next := append(append([]OpenAIMessage{}, msgs...), OpenAIMessage{Role: "user", Content: "ok"})
+	out = c.Compact("llama3.3", next)
+	if len(summarized) != 1 {
+		t.Errorf("Expected summary reuse, got %d summarizations", len(summarized))
+	}
+	if !strings.Contains(out[1].Content, "earlier work") || out[len(out)-1].Content != "ok" {
+		t.Errorf("Unexpected reused compaction: %+v", out)
+	}
+
+	if n, saved := c.Stats(); n != 1 || saved <= 0 {
+		t.Errorf("Stats = (%d, %d), want one compaction with savings", n, saved)
+	}
+}
+
+func TestCompactorSummarizeFailure(t *testing.T) {
+	c := NewCompactor(100, 2, func([]OpenAIMessage) (string, error) {
+		return "", errors.New("upstream down")
+	})
+
+	msgs := conversation(4, 400)
+	out := c.Compact("llama3.3", msgs)
+	if len(out) != len(msgs) {
+		t.Errorf("Expected original messages on failure, got %d", len(out))
+	}
+}
+
+func TestCompactorUnlockedWhileSummarizing(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	c := NewCompactor(500, 2, func([]OpenAIMessage) (string, error) {
+		close(started)
+		<-release
+		return "earlier work", nil
+	})
+
+	done := make(chan []OpenAIMessage)
+	go func() { done <- c.Compact("llama3.3", conversation(6, 400)) }()
+	<-started
+
+	stats := make(chan struct{})
+	go func() {
+		c.Stats()
+		close(stats)
+	}()
+	select {
+	case <-stats:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Stats not to wait for the summary model")
+	}
+	close(release)
+	if out := <-done; !strings.Contains(out[1].Content, "earlier work") {
+		t.Errorf("Expected the summary applied, got %q", out[1].Content)
+	}
+	if n, _ := c.Stats(); n != 1 {
+		t.Errorf("Expected one compaction, got %d", n)
+	}
+}
+
+func TestProxyCompactsLongHistory(t *testing.T) {
+	var forwarded OpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		content := "answer"
+		if req.Model == "llama3.2:3b" {
+			content = "summary of earlier turns"
+		} else {
+			forwarded = req
+		}
+		json.NewEncoder(w).Encode(OpenAIResponse{
+			Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: content}, FinishReason: "stop"}},
+		})
+	}))
+	defer server.Close()
+
+	proxy := NewOllamaProxy(server.URL, nil)
+	proxy.EnableCompaction(200, 2, "llama3.2:3b", nil)
+
+	var messages []AnthropicMessage
+	for i := 0; i < 4; i++ {
+		messages = append(messages,
+			AnthropicMessage{Role: "user", Content: strings.Repeat("question ", 100)},
+			AnthropicMessage{Role: "assistant", Content: strings.Repeat("answer ", 100)},
+		)
+	}
+	messages = append(messages, AnthropicMessage{Role: "user", Content: "latest"})
+
+	body, _ := json.Marshal(AnthropicRequest{Model: "llama3.3", Messages: messages})
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if len(forwarded.Messages) >= len(messages) {
+		t.Fatalf("Expected compacted history, got %d messages", len(forwarded.Messages))
+	}
+	if !strings.Contains(forwarded.Messages[0].Content, "summary of earlier turns") {
+		t.Errorf("Expected summary first, got %q", forwarded.Messages[0].Content)
+	}
+	if last := forwarded.Messages[len(forwarded.Messages)-1]; last.Content != "latest" {
+		t.Errorf("Expected latest turn kept, got %q", last.Content)
+	}
+}
+
+func TestProxyCompactionAuthorizesAndRecordsSummary(t *testing.T) {
+	var summaryAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		resp := OpenAIResponse{Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: "done"}, FinishReason: "stop"}}}
+		if req.Model == "mistral-small-latest" {
+			summaryAuth = r.Header.Get("Authorization")
+			resp.Choices[0].Message.Content = "summary"
+			resp.Usage = OpenAIUsage{PromptTokens: 900, CompletionTokens: 40}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	proxy := NewOllamaProxy(server.URL, nil)
+	proxy.SetUpstreamAuth("mistral", nil, "mistral-key")
+	var recorded []AnthropicUsage
+	err := proxy.EnableCompaction(200, 2, "mistral-small-latest", func(model string, usage AnthropicUsage) {
+		recorded = append(recorded, usage)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []AnthropicMessage
+	for i := 0; i < 4; i++ {
+		messages = append(messages,
+			AnthropicMessage{Role: "user", Content: strings.Repeat("question ", 100)},
+			AnthropicMessage{Role: "assistant", Content: strings.Repeat("answer ", 100)},
+		)
+	}
+	messages = append(messages, AnthropicMessage{Role: "user", Content: "latest"})
+	body, _ := json.Marshal(AnthropicRequest{Model: "mistral-large-latest", Messages: messages})
+	proxy.handleMessages(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+
+	if summaryAuth != "Bearer mistral-key" {
+		t.Errorf("Expected the summary request authorized, got %q", summaryAuth)
+	}
+	if len(recorded) != 1 || recorded[0].InputTokens != 900 || recorded[0].OutputTokens != 40 {
+		t.Errorf("Expected the summary usage recorded once, got %+v", recorded)
+	}
+}
+
+func TestResponsesProxyNotCompacted(t *testing.T) {
+	proxy := NewOllamaProxy("http://127.0.0.1:1", nil)
+	proxy.SetProtocol(protocolOpenAIResponses)
+	if err := proxy.EnableCompaction(200, 2, "gpt-4o-mini", nil); err == nil || proxy.compactor != nil {
+		t.Errorf("Expected compaction refused for the Responses API, got %v", err)
+	}
+}
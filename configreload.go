@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configSettleDelay lets an editor finish writing the env file before it is
+// read; saves often arrive as several events
+const configSettleDelay = 100 * time.Millisecond
+
+// configPollInterval is how often the env file is checked when it cannot be
+// watched
+const configPollInterval = 2 * time.Second
+
+// configVersion identifies the contents of a config file; a missing file
+// has the zero version
+func configVersion(path string) [sha256.Size]byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(data)
+}
+
+// watchConfig calls reload whenever the contents of path change, until stop
+// is closed. The directory is watched rather than the file, so edits that
+// replace the file, as editors and sync tools do, are noticed. When no watch
+// can be set up the file is polled instead.
+func watchConfig(path string, stop <-chan struct{}, reload func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		if err = watcher.Add(filepath.Dir(path)); err != nil {
+			watcher.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot watch %s (%v); checking it every %s\n", path, err, configPollInterval)
+		pollConfig(path, configPollInterval, stop, reload)
+		return
+	}
+	defer watcher.Close()
+
+	last := configVersion(path)
+	name := filepath.Clean(path)
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == name {
+				settle = time.After(configSettleDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Warning: watching %s: %v\n", path, err)
+		case <-settle:
+			settle = nil
+			// Rewrites with unchanged contents don't reload
+			if v := configVersion(path); v != last {
+				last = v
+				reload()
+			}
+		}
+	}
+}
+
+// pollConfig calls reload whenever the contents of path change, checking
+// every interval until stop is closed
+func pollConfig(path string, interval time.Duration, stop <-chan struct{}, reload func()) {
+	last := configVersion(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if v := configVersion(path); v != last {
+				last = v
+				reload()
+			}
+		}
+	}
+}
+
+// configChanges names the areas of configuration that differ, in a fixed
+// order. Key values are compared but never reported.
+func configChanges(old, next *Config) []string {
+	areas := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"budgets",
+			[]interface{}{old.DailyBudget, old.WeeklyBudget, old.MonthlyBudget, old.BackendBudgets},
+			[]interface{}{next.DailyBudget, next.WeeklyBudget, next.MonthlyBudget, next.BackendBudgets}},
+		{"routing",
+			[]interface{}{old.DefaultBackend, old.AutoPreference, old.LocalUpstreams, old.ModelRoutes},
+			[]interface{}{next.DefaultBackend, next.AutoPreference, next.LocalUpstreams, next.ModelRoutes}},
+		{"models",
+			[]interface{}{old.OllamaModels, old.ZAIModels, old.KimiModels, old.GrokModels, old.PinnedModels, old.ModelAliases},
+			[]interface{}{next.OllamaModels, next.ZAIModels, next.KimiModels, next.GrokModels, next.PinnedModels, next.ModelAliases}},
+		{"sampling", old.Sampling, next.Sampling},
+		{"keys", old.Keys, next.Keys},
+		{"training policy", old.NoTrainingRepos, next.NoTrainingRepos},
+		{"data regions", []interface{}{old.DataRegions, old.DataRegionWarn}, []interface{}{next.DataRegions, next.DataRegionWarn}},
+		{"cost tags", []interface{}{old.CostTags, old.RequiredCostTags}, []interface{}{next.CostTags, next.RequiredCostTags}},
+	}
+	var changed []string
+	for _, a := range areas {
+		if !reflect.DeepEqual(a.old, a.new) {
+			changed = append(changed, a.name)
+		}
+	}
+	return changed
+}
+
+// reloadConfig loads the configuration again and serves later requests with
+// it, recording what changed in the audit log
+func (s *APIServer) reloadConfig(load func() *Config) []string {
+	old := s.config()
+	next := load()
+	s.setConfig(next)
+
+	changes := configChanges(old, next)
+	summary := "no changes to budgets, routing, models, sampling, keys or policy"
+	if len(changes) > 0 {
+		summary = strings.Join(changes, ", ")
+	}
+	auditLog(next, fmt.Sprintf("CONFIG_RELOAD: %s (%s)", next.EnvFile, summary))
+	fmt.Printf("[OK] %s Reloaded %s: %s\n", time.Now().Format("15:04:05"), next.EnvFile, summary)
+	return changes
+}
+
+// reloadedModelMap returns the model map of a running proxy under next. Claude
+// Code keeps requesting the tier models it was launched with, so those are
+// mapped to the tier models next configures.
+func reloadedModelMap(launched, next *Config, be Backend) map[string]string {
+	modelMap := buildModelMap(next)
+	h0, s0, o0 := resolveTierModels(launched, be)
+	h1, s1, o1 := resolveTierModels(next, be)
+	for i, from := range []string{h0, s0, o0} {
+		to := []string{h1, s1, o1}[i]
+		if from == to {
+			continue
+		}
+		if mapped, ok := modelMap[to]; ok {
+			to = mapped
+		}
+		modelMap[from] = to
+	}
+	return modelMap
+}
+
+// reloadLaunchProxies applies routing, tier models and sampling from next to
+// the proxies of a running launch, recording what changed since current in
+// the audit log. Budgets need no push: hooks and later launches read them
+// from the env file each time.
+func reloadLaunchProxies(launched, current, next *Config, be Backend, proxy *OllamaProxy, grokProxy *GrokProxy) []string {
+	if proxy != nil {
+		proxy.SetModelMap(reloadedModelMap(launched, next, be))
+		proxy.SetUpstreams(next.LocalUpstreams, next.ModelRoutes)
+		proxy.SetSampling(next.samplingDefaults(be.Name))
+		proxy.SetModelTiers(proxyModelTiers(next, be, proxy))
+	}
+	if grokProxy != nil {
+		grokProxy.SetSampling(next.samplingDefaults(be.Name))
+	}
+
+	changes := configChanges(current, next)
+	summary := "no changes to budgets, routing, models, sampling, keys or policy"
+	if len(changes) > 0 {
+		summary = strings.Join(changes, ", ")
+	}
+	auditLog(next, fmt.Sprintf("CONFIG_RELOAD: %s (%s) in the %s proxy", next.EnvFile, summary, be.Name))
+	if next.verbose() {
+		fmt.Fprintln(os.Stderr, styleMuted.Render(fmt.Sprintf("[promptops] Reloaded %s: %s", next.EnvFile, summary)))
+	}
+	return changes
+}
+
+// watchLaunchConfig keeps the proxies of a running launch following edits to
+// the env file until the returned function is called
+func watchLaunchConfig(launched *Config, be Backend, proxy *OllamaProxy, grokProxy *GrokProxy) func() {
+	if proxy == nil && grokProxy == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	current := launched
+	go watchConfig(launched.EnvFile, stop, func() {
+		next := loadConfig()
+		applySessionModels(next)
+		reloadLaunchProxies(launched, current, next, be, proxy, grokProxy)
+		current = next
+	})
+	return func() { close(stop) }
+}
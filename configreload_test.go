@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigChanges(t *testing.T) {
+	old := newSelfTestConfig(t.TempDir())
+	next := newSelfTestConfig(t.TempDir())
+	next.EnvFile, next.UsageFile = old.EnvFile, old.UsageFile
+	if changes := configChanges(old, next); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+
+	next.DailyBudget = 25
+	next.ModelRoutes = []ModelRoute{{Pattern: "qwen*", Upstream: "lmstudio"}}
+	next.Keys["OPENAI_API_KEY"] = "sk-new"
+	got := configChanges(old, next)
+	if want := []string{"budgets", "routing", "keys"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("configChanges = %v, want %v", got, want)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env.local")
+	if err := os.WriteFile(path, []byte("NEXUS_DAILY_BUDGET=10\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan struct{}, 4)
+	stop := make(chan struct{})
+	defer close(stop)
+	go watchConfig(path, stop, func() { reloads <- struct{}{} })
+
+	// Unchanged contents do not reload, even when rewritten
+	time.Sleep(50 * time.Millisecond)
+	os.WriteFile(path, []byte("NEXUS_DAILY_BUDGET=10\n"), 0600)
+	time.Sleep(3 * configSettleDelay)
+	if len(reloads) != 0 {
+		t.Fatalf("Expected no reload for unchanged contents, got %d", len(reloads))
+	}
+
+	// Editors replace the file rather than writing it in place
+	tmp := path + ".swp"
+	os.WriteFile(tmp, []byte("NEXUS_DAILY_BUDGET=20\n"), 0600)
+	os.Rename(tmp, path)
+	select {
+	case <-reloads:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a reload after the file changed")
+	}
+}
+
+func TestPollConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env.local")
+	os.WriteFile(path, []byte("NEXUS_DAILY_BUDGET=10\n"), 0600)
+	reloads := make(chan struct{}, 4)
+	stop := make(chan struct{})
+	defer close(stop)
+	go pollConfig(path, 10*time.Millisecond, stop, func() { reloads <- struct{}{} })
+
+	time.Sleep(30 * time.Millisecond)
+	os.WriteFile(path, []byte("NEXUS_DAILY_BUDGET=20\n"), 0600)
+	select {
+	case <-reloads:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a reload after the file changed")
+	}
+}
+
+func TestReloadLaunchProxies(t *testing.T) {
+	launched := newSelfTestConfig(t.TempDir())
+	launched.OllamaModels = map[string]string{"sonnet": "qwen2.5-coder:7b"}
+	be := backends["ollama"]
+	proxy := newOllamaProxy(launched, be, "http://localhost:11434/v1", NewModelTracker(launched), "s1")
+
+	next := newSelfTestConfig(filepath.Dir(launched.StateFile))
+	next.AuditEnabled = true
+	next.OllamaModels = map[string]string{"sonnet": "qwen2.5-coder:14b"}
+	next.LocalUpstreams = []LocalUpstream{{Name: "lmstudio", BaseURL: "http://localhost:1234/v1"}}
+	next.ModelRoutes = []ModelRoute{{Pattern: "qwen*", Upstream: "lmstudio"}}
+	next.Sampling = map[string]SamplingDefaults{"": {MaxTokens: 2048}}
+
+	changes := reloadLaunchProxies(launched, launched, next, be, proxy, nil)
+	if want := []string{"routing", "models", "sampling"}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("Expected %v, got %v", want, changes)
+	}
+
+	// Claude Code still asks for the launch-time sonnet model
+	if got := proxy.mapModel("qwen2.5-coder:7b"); got != "qwen2.5-coder:14b" {
+		t.Errorf("Expected the reloaded sonnet model, got %s", got)
+	}
+	if got := proxy.modelTier("qwen2.5-coder:14b"); got != "sonnet" {
+		t.Errorf("Expected the reloaded model recorded as sonnet, got %q", got)
+	}
+	if name, _ := proxy.upstreamFor("qwen2.5-coder:14b"); name != "lmstudio" {
+		t.Errorf("Expected the reloaded route, got %s", name)
+	}
+	if proxy.sampling.MaxTokens != 2048 {
+		t.Errorf("Expected the reloaded max_tokens cap, got %d", proxy.sampling.MaxTokens)
+	}
+
+	audit, _ := os.ReadFile(next.AuditLog)
+	if !strings.Contains(string(audit), "(routing, models, sampling) in the ollama proxy") {
+		t.Errorf("Expected the reload audited, got %s", audit)
+	}
+}
+
+func TestAPIServerReloadConfig(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	api := NewAPIServer(cfg, "secret")
+	h := api.Handler()
+
+	next := newSelfTestConfig(filepath.Dir(cfg.StateFile))
+	next.AuditEnabled = true
+	next.DailyBudget = 40
+	changes := api.reloadConfig(func() *Config { return next })
+	if !reflect.DeepEqual(changes, []string{"budgets"}) {
+		t.Errorf("Expected a budget change, got %v", changes)
+	}
+
+	// Later requests use the reloaded config
+	rec := apiRequest(h, "GET", "/v1/budgets", "", true)
+	var budgets map[string]apiBudget
+	if err := json.NewDecoder(rec.Body).Decode(&budgets); err != nil {
+		t.Fatal(err)
+	}
+	if budgets["daily"].Limit != 40 {
+		t.Errorf("Expected the reloaded daily limit, got %+v", budgets["daily"])
+	}
+
+	audit, err := os.ReadFile(next.AuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(audit), "CONFIG_RELOAD: "+next.EnvFile+" (budgets)") {
+		t.Errorf("Expected the reload audited, got %s", audit)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// contextPrimerFile is the per-project file whose contents the local proxies
+// add to every request as an extra system block
+var contextPrimerFile = filepath.Join(".promptops", "system.md")
+
+// maxContextPrimerBytes caps the injected text so a large file can't
+// silently inflate the cost of every request
+const maxContextPrimerBytes = 16 * 1024
+
+// projectRoot returns the git top-level directory containing dir, or dir
+// itself outside a repository
+func projectRoot(dir string) string {
+	if top := runGit(dir, "rev-parse", "--show-toplevel"); top != "" {
+		return top
+	}
+	return dir
+}
+
+// loadContextPrimer reads the project's primer file, truncated to
+// maxContextPrimerBytes. A missing file yields an empty primer.
+func loadContextPrimer(root string) (text string, truncated bool, err error) {
+	data, err := os.ReadFile(filepath.Join(root, contextPrimerFile))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if len(data) > maxContextPrimerBytes {
+		data = data[:maxContextPrimerBytes]
+		// Don't cut a multi-byte character in half
+		for len(data) > 0 && !utf8.Valid(data) {
+			data = data[:len(data)-1]
+		}
+		truncated = true
+	}
+	return strings.TrimSpace(string(data)), truncated, nil
+}
+
+// projectContextPrimer loads the primer for the working directory's project,
+// warning when it was truncated and noting the injection in the audit log
+func projectContextPrimer(cfg *Config) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	root := projectRoot(wd)
+	path := filepath.Join(root, contextPrimerFile)
+	text, truncated, err := loadContextPrimer(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", path, err)
+		return ""
+	}
+	if text == "" {
+		return ""
+	}
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Warning: %s exceeds %d bytes and was truncated\n", path, maxContextPrimerBytes)
+	}
+	auditLog(cfg, fmt.Sprintf("CONTEXT_PRIMER: %s (%d bytes)", path, len(text)))
+	return text
+}
+
+// appendSystemText adds the primer after an existing system prompt
+func appendSystemText(system, primer string) string {
+	if primer == "" {
+		return system
+	}
+	if system == "" {
+		return primer
+	}
+	return system + "\n\n" + primer
+}
+
+// injectSystemBlock adds the primer as a trailing text block of an Anthropic
+// messages request's system field. Bodies that aren't JSON objects are
+// returned unchanged.
+func injectSystemBlock(body []byte, primer string) []byte {
+	return injectSystemBlocks(body, "", primer)
+}
+
+// injectSystemBlocks adds leading and trailing text blocks to an Anthropic
+// messages request's system field; empty ones are left out
+func injectSystemBlocks(body []byte, prefix, suffix string) []byte {
+	if prefix == "" && suffix == "" {
+		return body
+	}
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(body, &req); err != nil {
+		return body
+	}
+
+	var blocks []json.RawMessage
+	if prefix != "" {
+		block, _ := json.Marshal(AnthropicContentItem{Type: "text", Text: prefix})
+		blocks = append(blocks, block)
+	}
+	if raw, ok := req["system"]; ok {
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			if text != "" {
+				block, _ := json.Marshal(AnthropicContentItem{Type: "text", Text: text})
+				blocks = append(blocks, block)
+			}
+		} else {
+			var existing []json.RawMessage
+			if err := json.Unmarshal(raw, &existing); err != nil {
+				return body
+			}
+			blocks = append(blocks, existing...)
+		}
+	}
+	if suffix != "" {
+		block, _ := json.Marshal(AnthropicContentItem{Type: "text", Text: suffix})
+		blocks = append(blocks, block)
+	}
+
+	system, err := json.Marshal(blocks)
+	if err != nil {
+		return body
+	}
+	req["system"] = system
+	patched, err := json.Marshal(req)
+	if err != nil {
+		return body
+	}
+	return patched
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func writePrimer(t *testing.T, root, content string) {
+	t.Helper()
+	path := filepath.Join(root, contextPrimerFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadContextPrimer(t *testing.T) {
+	root := t.TempDir()
+	if text, _, err := loadContextPrimer(root); err != nil || text != "" {
+		t.Errorf("Expected empty primer without a file, got %q (err=%v)", text, err)
+	}
+
+	writePrimer(t, root, "\nUse tabs.\n")
+	if text, truncated, err := loadContextPrimer(root); err != nil || text != "Use tabs." || truncated {
+		t.Errorf("Unexpected primer %q (truncated=%t, err=%v)", text, truncated, err)
+	}
+
+	writePrimer(t, root, strings.Repeat("é", maxContextPrimerBytes))
+	text, truncated, err := loadContextPrimer(root)
+	if err != nil || !truncated {
+		t.Fatalf("Expected truncation (err=%v)", err)
+	}
+	if len(text) > maxContextPrimerBytes || !utf8.ValidString(text) {
+		t.Errorf("Expected valid UTF-8 within the cap, got %d bytes", len(text))
+	}
+}
+
+func TestAppendSystemText(t *testing.T) {
+	if got := appendSystemText("base", "extra"); got != "base\n\nextra" {
+		t.Errorf("Unexpected system text: %q", got)
+	}
+	if got := appendSystemText("", "extra"); got != "extra" {
+		t.Errorf("Unexpected system text: %q", got)
+	}
+	if got := appendSystemText("base", ""); got != "base" {
+		t.Errorf("Unexpected system text: %q", got)
+	}
+}
+
+func TestInjectSystemBlock(t *testing.T) {
+	systemOf := func(body []byte) []map[string]interface{} {
+		t.Helper()
+		var req struct {
+			System []map[string]interface{} `json:"system"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatalf("Invalid patched body %s: %v", body, err)
+		}
+		return req.System
+	}
+
+	blocks := systemOf(injectSystemBlock([]byte(`{"model":"m","system":"Be brief."}`), "Use tabs."))
+	if len(blocks) != 2 || blocks[0]["text"] != "Be brief." || blocks[1]["text"] != "Use tabs." {
+		t.Errorf("Expected string system converted to two blocks, got %v", blocks)
+	}
+
+	body := `{"system":[{"type":"text","text":"A","cache_control":{"type":"ephemeral"}}]}`
+	blocks = systemOf(injectSystemBlock([]byte(body), "B"))
+	if len(blocks) != 2 || blocks[0]["cache_control"] == nil || blocks[1]["text"] != "B" {
+		t.Errorf("Expected existing blocks kept and primer appended, got %v", blocks)
+	}
+
+	if blocks = systemOf(injectSystemBlock([]byte(`{"messages":[]}`), "B")); len(blocks) != 1 {
+		t.Errorf("Expected a single primer block, got %v", blocks)
+	}
+
+	for _, unchanged := range []string{`not json`, `{"system":42}`} {
+		if got := injectSystemBlock([]byte(unchanged), "B"); string(got) != unchanged {
+			t.Errorf("Expected %q unchanged, got %q", unchanged, got)
+		}
+	}
+	if got := injectSystemBlock([]byte(body), ""); string(got) != body {
+		t.Error("Expected body unchanged without a primer")
+	}
+}
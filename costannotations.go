@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// formatTokenCount renders a token count compactly: 450, 1.2k, 3.4M
+func formatTokenCount(n int64) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// costAnnotation describes one proxied response's spend, e.g.
+// "[promptops] +$0.031 (1.2k in / 450 out, deepseek-chat)"
+func costAnnotation(record UsageRecord) string {
+	return fmt.Sprintf("[promptops] +$%.3f (%s in / %s out, %s)", record.CostUSD,
+		formatTokenCount(record.InputTokens), formatTokenCount(record.OutputTokens), record.Model)
+}
+
+// annotateCost prints a dim cost line to stderr after a proxied response when
+// NEXUS_COST_ANNOTATIONS is on. Claude Code owns stdout, so the line appears
+// alongside its UI rather than inside it.
+func annotateCost(cfg *Config, record UsageRecord) {
+	if !cfg.CostAnnotations || record.InputTokens+record.OutputTokens == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, styleMuted.Render(costAnnotation(record)))
+}
+
+// priceUsage returns a record for usage priced at the backend's current rate,
+// for proxies that observe usage without recording it
+func priceUsage(cfg *Config, be Backend, model string, usage AnthropicUsage) UsageRecord {
+	inputPrice, outputPrice := pricingAt(cfg, be, time.Now())
+	return UsageRecord{
+		Backend:      be.Name,
+		Model:        model,
+		InputTokens:  int64(usage.InputTokens),
+		OutputTokens: int64(usage.OutputTokens),
+		CostUSD:      (float64(usage.InputTokens)*inputPrice + float64(usage.OutputTokens)*outputPrice) / 1000000,
+	}
+}
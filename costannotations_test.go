@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFormatTokenCount(t *testing.T) {
+	for n, want := range map[int64]string{0: "0", 450: "450", 1200: "1.2k", 3400000: "3.4M"} {
+		if got := formatTokenCount(n); got != want {
+			t.Errorf("formatTokenCount(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestCostAnnotation(t *testing.T) {
+	got := costAnnotation(UsageRecord{Model: "deepseek-chat", InputTokens: 1200, OutputTokens: 450, CostUSD: 0.0312})
+	want := "[promptops] +$0.031 (1.2k in / 450 out, deepseek-chat)"
+	if got != want {
+		t.Errorf("costAnnotation() = %q, want %q", got, want)
+	}
+}
+
+func TestPriceUsage(t *testing.T) {
+	be := Backend{Name: "test", InputPrice: 2, OutputPrice: 10}
+	r := priceUsage(&Config{}, be, "m", AnthropicUsage{InputTokens: 1000000, OutputTokens: 100000})
+	if r.CostUSD != 3 || r.InputTokens != 1000000 || r.OutputTokens != 100000 || r.Model != "m" {
+		t.Errorf("Unexpected record %+v", r)
+	}
+}
+
+func TestGrokProxyObservesStreamingUsage(t *testing.T) {
+	var model string
+	var usage AnthropicUsage
+	p := NewGrokProxy("", "")
+	p.SetUsageObserver(func(m string, u AnthropicUsage) { model, usage = m, u })
+
+	stream := strings.Join([]string{
+		`event: message_start`,
+		`data: {"type":"message_start","message":{"model":"grok-code-fast-1","usage":{"input_tokens":1200,"output_tokens":1}}}`,
+		``,
+		`event: message_delta`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":450}}`,
+		``,
+		`event: message_stop`,
+		`data: {"type":"message_stop"}`,
+		``,
+	}, "\n")
+	p.filterSSEThinking(httptest.NewRecorder(), strings.NewReader(stream))
+
+	if model != "grok-code-fast-1" || usage.InputTokens != 1200 || usage.OutputTokens != 450 {
+		t.Errorf("Unexpected usage %s %+v", model, usage)
+	}
+}
+
+func TestGrokProxyObservesJSONUsage(t *testing.T) {
+	called := false
+	p := NewGrokProxy("", "")
+	p.SetUsageObserver(func(m string, u AnthropicUsage) {
+		called = true
+		if m != "grok-4" || u.InputTokens != 10 || u.OutputTokens != 5 {
+			t.Errorf("Unexpected usage %s %+v", m, u)
+		}
+	})
+	p.observeJSONUsage([]byte(`{"model":"grok-4","usage":{"input_tokens":10,"output_tokens":5}}`))
+	if !called {
+		t.Error("Expected usage to be observed")
+	}
+
+	// Responses without usage are ignored
+	called = false
+	p.observeJSONUsage([]byte(`{"data":[]}`))
+	if called {
+		t.Error("Expected no observation without usage")
+	}
+}
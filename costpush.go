@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// costPushTimeout bounds each push so a cron job never hangs on a dead gateway
+const costPushTimeout = 10 * time.Second
+
+// CostPushOptions selects where cost gauges are sent
+type CostPushOptions struct {
+	Gateway string // Prometheus pushgateway base URL
+	StatsD  string // statsd host:port (UDP)
+	Job     string
+	DryRun  bool
+}
+
+// BackendCost is the spend of one backend exported as gauges
+type BackendCost struct {
+	Backend string
+	Daily   float64
+	Total   float64
+}
+
+func parseCostPushArgs(args []string) (CostPushOptions, error) {
+	opts := CostPushOptions{Job: "promptops"}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		value := ""
+		if name, v, ok := strings.Cut(arg, "="); ok {
+			arg, value = name, v
+		} else if arg != "--dry-run" {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s requires a value", arg)
+			}
+			value = args[i+1]
+			i++
+		}
+
+		switch arg {
+		case "--prometheus-gateway":
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return opts, fmt.Errorf("invalid pushgateway URL '%s'", value)
+			}
+			opts.Gateway = strings.TrimRight(value, "/")
+		case "--statsd":
+			if _, _, err := net.SplitHostPort(value); err != nil {
+				return opts, fmt.Errorf("invalid statsd address '%s': expected host:port", value)
+			}
+			opts.StatsD = value
+		case "--job":
+			if value == "" {
+				return opts, errors.New("--job requires a value")
+			}
+			opts.Job = value
+		case "--dry-run":
+			opts.DryRun = true
+		default:
+			return opts, fmt.Errorf("unknown flag '%s'", arg)
+		}
+	}
+	if opts.Gateway == "" && opts.StatsD == "" && !opts.DryRun {
+		return opts, errors.New("specify --prometheus-gateway, --statsd or --dry-run")
+	}
+	return opts, nil
+}
+
+// backendCosts sums today's and all-time spend per backend, using the same
+// day boundary as calculateCosts
+func backendCosts(records usageSource, now time.Time) []BackendCost {
+	today := now.Truncate(24 * time.Hour)
+	byBackend := make(map[string]*BackendCost)
+	records(func(r UsageRecord) {
+		c, ok := byBackend[r.Backend]
+		if !ok {
+			c = &BackendCost{Backend: r.Backend}
+			byBackend[r.Backend] = c
+		}
+		c.Total += r.CostUSD
+		if r.Timestamp.Truncate(24 * time.Hour).Equal(today) {
+			c.Daily += r.CostUSD
+		}
+	})
+
+	costs := make([]BackendCost, 0, len(byBackend))
+	for _, c := range byBackend {
+		costs = append(costs, *c)
+	}
+	sort.Slice(costs, func(i, j int) bool { return costs[i].Backend < costs[j].Backend })
+	return costs
+}
+
+// prometheusCostMetrics renders the gauges in the Prometheus text format
+func prometheusCostMetrics(costs []BackendCost) string {
+	var b strings.Builder
+	gauges := []struct {
+		name, help string
+		value      func(BackendCost) float64
+	}{
+		{"promptops_cost_daily_usd", "Spend today in USD per backend", func(c BackendCost) float64 { return c.Daily }},
+		{"promptops_cost_total_usd", "All-time logged spend in USD per backend", func(c BackendCost) float64 { return c.Total }},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", g.name)
+		for _, c := range costs {
+			fmt.Fprintf(&b, "%s{backend=%q} %.6f\n", g.name, c.Backend, g.value(c))
+		}
+	}
+	return b.String()
+}
+
+// statsdCostMetrics renders the gauges as newline separated statsd lines
+func statsdCostMetrics(costs []BackendCost, env string) string {
+	prefix := "promptops."
+	if env != "" {
+		prefix += env + "."
+	}
+	var b strings.Builder
+	for _, c := range costs {
+		fmt.Fprintf(&b, "%scost.daily_usd.%s:%.6f|g\n", prefix, c.Backend, c.Daily)
+		fmt.Fprintf(&b, "%scost.total_usd.%s:%.6f|g\n", prefix, c.Backend, c.Total)
+	}
+	return b.String()
+}
+
+// pushgatewayURL is the grouping key URL for the job, adding the named
+// environment as a label so environments don't overwrite each other
+func pushgatewayURL(gateway, job, env string) string {
+	u := gateway + "/metrics/job/" + url.PathEscape(job)
+	if env != "" {
+		u += "/env/" + url.PathEscape(env)
+	}
+	return u
+}
+
+// pushPrometheus replaces the job's metrics on the pushgateway
+func pushPrometheus(target, body string) error {
+	req, err := http.NewRequest(http.MethodPut, target, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	client := *httpClient
+	client.Timeout = costPushTimeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// pushStatsD sends the gauges in a single UDP packet
+func pushStatsD(addr, body string) error {
+	conn, err := net.DialTimeout("udp", addr, costPushTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(bytes.TrimSuffix([]byte(body), []byte("\n")))
+	return err
+}
+
+func handleCostPush(args []string) {
+	opts, err := parseCostPushArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Usage: promptops cost push [--prometheus-gateway URL] [--statsd host:port] [--job name] [--dry-run]")
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+	costs := backendCosts(ledgerUsage(cfg), time.Now())
+
+	if opts.DryRun {
+		fmt.Print(prometheusCostMetrics(costs))
+		fmt.Print(statsdCostMetrics(costs, cfg.Environment))
+		return
+	}
+
+	failed := false
+	if opts.Gateway != "" {
+		if err := pushPrometheus(pushgatewayURL(opts.Gateway, opts.Job, cfg.Environment), prometheusCostMetrics(costs)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: pushgateway: %v\n", err)
+			failed = true
+		} else {
+			fmt.Printf("[OK] Pushed cost gauges for %d backends to %s\n", len(costs), opts.Gateway)
+		}
+	}
+	if opts.StatsD != "" {
+		if err := pushStatsD(opts.StatsD, statsdCostMetrics(costs, cfg.Environment)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: statsd: %v\n", err)
+			failed = true
+		} else {
+			fmt.Printf("[OK] Sent cost gauges for %d backends to statsd at %s\n", len(costs), opts.StatsD)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCostPushArgs(t *testing.T) {
+	opts, err := parseCostPushArgs([]string{"--prometheus-gateway", "http://pushgw:9091/", "--statsd=localhost:8125", "--job", "llm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Gateway != "http://pushgw:9091" || opts.StatsD != "localhost:8125" || opts.Job != "llm" {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"--prometheus-gateway", "pushgw:9091"},
+		{"--statsd", "localhost"},
+		{"--statsd"},
+		{"--verbose"},
+	} {
+		if _, err := parseCostPushArgs(args); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestBackendCosts(t *testing.T) {
+	now := time.Now()
+	records := []UsageRecord{
+		{Timestamp: now, Backend: "deepseek", CostUSD: 1.50},
+		{Timestamp: now.AddDate(0, 0, -3), Backend: "deepseek", CostUSD: 2.00},
+		{Timestamp: now.AddDate(0, 0, -3), Backend: "claude", CostUSD: 4.00},
+	}
+	costs := backendCosts(usageRecords(records), now)
+	if len(costs) != 2 || costs[0].Backend != "claude" {
+		t.Fatalf("Expected costs sorted by backend, got %+v", costs)
+	}
+	if costs[0].Daily != 0 || costs[0].Total != 4.00 {
+		t.Errorf("Unexpected claude costs: %+v", costs[0])
+	}
+	if costs[1].Daily != 1.50 || costs[1].Total != 3.50 {
+		t.Errorf("Unexpected deepseek costs: %+v", costs[1])
+	}
+}
+
+func TestCostMetricFormats(t *testing.T) {
+	costs := []BackendCost{{Backend: "zai", Daily: 0.25, Total: 1}}
+
+	prom := prometheusCostMetrics(costs)
+	for _, want := range []string{
+		"# TYPE promptops_cost_daily_usd gauge\n",
+		"promptops_cost_daily_usd{backend=\"zai\"} 0.250000\n",
+		"promptops_cost_total_usd{backend=\"zai\"} 1.000000\n",
+	} {
+		if !strings.Contains(prom, want) {
+			t.Errorf("Expected %q in Prometheus output:\n%s", want, prom)
+		}
+	}
+
+	statsd := statsdCostMetrics(costs, "work")
+	if statsd != "promptops.work.cost.daily_usd.zai:0.250000|g\npromptops.work.cost.total_usd.zai:1.000000|g\n" {
+		t.Errorf("Unexpected statsd output: %q", statsd)
+	}
+
+	if got := pushgatewayURL("http://gw:9091", "prompt ops", "work"); got != "http://gw:9091/metrics/job/prompt%20ops/env/work" {
+		t.Errorf("Unexpected pushgateway URL: %s", got)
+	}
+}
+
+func TestPushPrometheus(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if err := pushPrometheus(pushgatewayURL(server.URL, "promptops", ""), "metric 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/promptops" || body != "metric 1\n" {
+		t.Errorf("Unexpected push: %s %s %q", method, path, body)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	if err := pushPrometheus(failing.URL, ""); err == nil {
+		t.Error("Expected error for a rejected push")
+	}
+}
+
+func TestPushStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	if err := pushStatsD(conn.LocalAddr().String(), "a:1|g\nb:2|g\n"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 512)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "a:1|g\nb:2|g" {
+		t.Errorf("Unexpected packet: %q", buf[:n])
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// costTagsFile is the per-workspace file of cost-allocation tags, one
+// name=value per line, read from the project root
+var costTagsFile = filepath.Join(".promptops", "tags")
+
+// untaggedKey groups usage recorded without the reported tag
+const untaggedKey = "(untagged)"
+
+// maxCostTagValue bounds tag values so reports stay readable
+const maxCostTagValue = 64
+
+var costTagNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+var (
+	workspaceTagsOnce sync.Once
+	workspaceTags     map[string]string
+)
+
+// validateCostTag checks a tag name and value
+func validateCostTag(name, value string) error {
+	if !costTagNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid tag name '%s': use lowercase letters, digits, '-' and '_'", name)
+	}
+	if value == "" {
+		return fmt.Errorf("tag '%s' has no value", name)
+	}
+	if len(value) > maxCostTagValue {
+		return fmt.Errorf("tag '%s' is longer than %d characters", name, maxCostTagValue)
+	}
+	if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		return fmt.Errorf("tag '%s' contains control characters", name)
+	}
+	return nil
+}
+
+// parseCostTags reads name=value lines, skipping blanks and # comments.
+// Invalid lines are reported and left out.
+func parseCostTags(r io.Reader) (map[string]string, []error) {
+	tags := make(map[string]string)
+	var errs []error
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		if !ok {
+			errs = append(errs, fmt.Errorf("line %d: expected name=value", n))
+			continue
+		}
+		if err := validateCostTag(name, value); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+			continue
+		}
+		tags[name] = value
+	}
+	return tags, errs
+}
+
+// loadWorkspaceTags reads the cost tags of the project at root. A missing
+// file has no tags.
+func loadWorkspaceTags(root string) map[string]string {
+	path := filepath.Join(root, costTagsFile)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", path, err)
+		return nil
+	}
+	defer f.Close()
+	tags, errs := parseCostTags(f)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", path, err)
+	}
+	return tags
+}
+
+// resolveCostTags overlays workspace tags on the configured defaults
+func resolveCostTags(defaults, workspace map[string]string) map[string]string {
+	if len(defaults) == 0 && len(workspace) == 0 {
+		return nil
+	}
+	tags := make(map[string]string, len(defaults)+len(workspace))
+	for name, value := range defaults {
+		tags[name] = value
+	}
+	for name, value := range workspace {
+		tags[name] = value
+	}
+	return tags
+}
+
+// currentCostTags returns the tags stamped on usage records. The workspace
+// file is read once per process, like git details.
+func currentCostTags(cfg *Config) map[string]string {
+	workspaceTagsOnce.Do(func() {
+		if wd, err := os.Getwd(); err == nil {
+			workspaceTags = loadWorkspaceTags(projectRoot(wd))
+		}
+	})
+	return resolveCostTags(cfg.CostTags, workspaceTags)
+}
+
+// missingCostTags lists required tags absent from tags
+func missingCostTags(cfg *Config, tags map[string]string) []string {
+	var missing []string
+	for _, name := range cfg.RequiredCostTags {
+		if tags[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// checkCostTags refuses a launch whose usage could not be charged back
+func checkCostTags(cfg *Config) error {
+	missing := missingCostTags(cfg, currentCostTags(cfg))
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("missing required cost tags: %s - add them to %s in the project root, e.g. %s=<value> (NEXUS_REQUIRED_COST_TAGS)",
+		strings.Join(missing, ", "), costTagsFile, missing[0])
+}
+
+// formatCostTags renders tags as "project=PAY-12, team=payments"
+func formatCostTags(tags map[string]string) string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + tags[name]
+	}
+	return strings.Join(parts, ", ")
+}
+
+// usageTagKey groups records by the value of one tag
+func usageTagKey(name string) func(UsageRecord) string {
+	return func(r UsageRecord) string {
+		if v := r.Tags[name]; v != "" {
+			return v
+		}
+		return untaggedKey
+	}
+}
+
+// parseGroupBy maps a --group-by value to a report title, key column and key:
+// backend, repo or tag:<name>
+func parseGroupBy(value string) (title, keyName string, key func(UsageRecord) string, err error) {
+	switch {
+	case value == "backend":
+		return "COST BY BACKEND", "Backend", func(r UsageRecord) string { return r.Backend }, nil
+	case value == "repo":
+		return "COST BY REPOSITORY", "Repository", usageRepoKey, nil
+	case strings.HasPrefix(value, "tag:"):
+		name := strings.ToLower(strings.TrimPrefix(value, "tag:"))
+		if !costTagNamePattern.MatchString(name) {
+			return "", "", nil, fmt.Errorf("invalid tag name '%s'", name)
+		}
+		return "COST BY TAG " + strings.ToUpper(name), name, usageTagKey(name), nil
+	}
+	return "", "", nil, fmt.Errorf("unknown grouping '%s': use backend, repo or tag:<name>", value)
+}
+
+// writeCostCSV writes report groups for finance: one row per group with the
+// period, requests, tokens and cost
+func writeCostCSV(out io.Writer, keyName string, rng UsageRange, groups []*UsageGroup) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{keyName, "period", "requests", "input_tokens", "output_tokens", "cost_usd"})
+	for _, g := range groups {
+		w.Write([]string{
+			g.Key,
+			rng.Label(),
+			fmt.Sprintf("%d", g.Requests),
+			fmt.Sprintf("%d", g.InputTokens),
+			fmt.Sprintf("%d", g.OutputTokens),
+			fmt.Sprintf("%.4f", g.CostUSD),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// showCostTags prints the tags this workspace stamps on usage:
+// promptops cost tags
+func showCostTags() {
+	cfg := loadConfig()
+	tags := currentCostTags(cfg)
+	fmt.Println()
+	fmt.Println(styleSection.Render("COST TAGS"))
+	fmt.Println()
+	if len(tags) == 0 {
+		fmt.Printf("No cost tags. Add name=value lines to %s or set NEXUS_COST_TAG_<NAME>.\n", costTagsFile)
+	} else {
+		fmt.Println(formatCostTags(tags))
+	}
+	if missing := missingCostTags(cfg, tags); len(missing) > 0 {
+		fmt.Println(styleWarning.Render(fmt.Sprintf("Missing required tags: %s (launches are refused until set)", strings.Join(missing, ", "))))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCostTags(t *testing.T) {
+	input := "# chargeback\nteam = payments\nProject=\"PAY-12\"\n\nbad line\nTeam Name=x\ncost-center=\n"
+	tags, errs := parseCostTags(strings.NewReader(input))
+	if len(tags) != 2 || tags["team"] != "payments" || tags["project"] != "PAY-12" {
+		t.Errorf("Unexpected tags %v", tags)
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 invalid lines reported, got %v", errs)
+	}
+}
+
+func TestWorkspaceTagsOverrideDefaults(t *testing.T) {
+	root := t.TempDir()
+	if got := loadWorkspaceTags(root); got != nil {
+		t.Errorf("Expected no tags without a file, got %v", got)
+	}
+	os.MkdirAll(filepath.Join(root, ".promptops"), 0755)
+	os.WriteFile(filepath.Join(root, costTagsFile), []byte("team=payments\n"), 0644)
+
+	tags := resolveCostTags(map[string]string{"team": "platform", "project": "OPS-1"}, loadWorkspaceTags(root))
+	if tags["team"] != "payments" || tags["project"] != "OPS-1" {
+		t.Errorf("Expected workspace tags over defaults, got %v", tags)
+	}
+	if formatCostTags(tags) != "project=OPS-1, team=payments" {
+		t.Errorf("Unexpected formatting %q", formatCostTags(tags))
+	}
+	if resolveCostTags(nil, nil) != nil {
+		t.Error("Expected no tags when none are configured")
+	}
+}
+
+func TestMissingCostTags(t *testing.T) {
+	cfg := &Config{RequiredCostTags: []string{"team", "project"}}
+	missing := missingCostTags(cfg, map[string]string{"team": "payments"})
+	if len(missing) != 1 || missing[0] != "project" {
+		t.Errorf("Expected project missing, got %v", missing)
+	}
+	if missing := missingCostTags(&Config{}, nil); len(missing) != 0 {
+		t.Errorf("Expected nothing required by default, got %v", missing)
+	}
+}
+
+func TestLoadConfigCostTags(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	envFile := filepath.Join(home, ".env.local")
+	t.Setenv("NEXUS_ENV_FILE", envFile)
+	content := "NEXUS_COST_TAG_TEAM=platform\nNEXUS_COST_TAG_BAD NAME=x\nNEXUS_REQUIRED_COST_TAGS=team, Project\n"
+	if err := os.WriteFile(envFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := loadConfig()
+	if len(cfg.CostTags) != 1 || cfg.CostTags["team"] != "platform" {
+		t.Errorf("Unexpected default tags %v", cfg.CostTags)
+	}
+	if strings.Join(cfg.RequiredCostTags, ",") != "team,project" {
+		t.Errorf("Unexpected required tags %v", cfg.RequiredCostTags)
+	}
+}
+
+func TestCostReportByTag(t *testing.T) {
+	records := []UsageRecord{
+		{Backend: "claude", CostUSD: 2, InputTokens: 100, Tags: map[string]string{"team": "payments"}},
+		{Backend: "openai", CostUSD: 1, InputTokens: 50, Tags: map[string]string{"team": "payments"}},
+		{Backend: "claude", CostUSD: 4, InputTokens: 10, Tags: map[string]string{"team": "search"}},
+		{Backend: "claude", CostUSD: 0.5},
+	}
+	_, keyName, key, err := parseGroupBy("tag:team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := groupUsage(records, key)
+	if len(groups) != 3 || groups[0].Key != "search" || groups[1].Key != "payments" || groups[1].CostUSD != 3 || groups[2].Key != untaggedKey {
+		t.Errorf("Unexpected groups %+v %+v %+v", groups[0], groups[1], groups[2])
+	}
+
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.Local)
+	var buf bytes.Buffer
+	if err := writeCostCSV(&buf, keyName, UsageRange{From: from, To: from.AddDate(0, 1, 0)}, groups); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "team,period,requests,input_tokens,output_tokens,cost_usd" ||
+		lines[2] != "payments,2026-09-01 to 2026-09-30,2,150,0,3.0000" {
+		t.Errorf("Unexpected CSV:\n%s", buf.String())
+	}
+
+	for _, bad := range []string{"tag:", "tag:Team Name", "owner"} {
+		if _, _, _, err := parseGroupBy(bad); err == nil {
+			t.Errorf("Expected %q rejected", bad)
+		}
+	}
+}
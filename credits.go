@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// defaultCreditWarnPercents are the remaining-balance percentages that trigger
+// a low credit warning
+var defaultCreditWarnPercents = []float64{25, 10}
+
+// CreditBalance is a manually recorded prepaid balance for a backend. Usage
+// logged after SetAt is subtracted to estimate what remains.
+type CreditBalance struct {
+	Amount float64   `json:"amount"`
+	SetAt  time.Time `json:"set_at"`
+}
+
+// CreditStatus is the estimated remaining balance for a backend
+type CreditStatus struct {
+	Backend   string
+	Amount    float64
+	Spent     float64
+	Remaining float64
+	SetAt     time.Time
+}
+
+// PercentLeft returns the remaining balance as a percentage of the recorded amount
+func (s CreditStatus) PercentLeft() float64 {
+	if s.Amount <= 0 {
+		return 0
+	}
+	return s.Remaining / s.Amount * 100
+}
+
+// loadCredits reads recorded balances; a missing or corrupt file yields none
+func loadCredits(cfg *Config) map[string]CreditBalance {
+	credits := make(map[string]CreditBalance)
+	data, err := os.ReadFile(cfg.CreditsFile)
+	if err != nil {
+		return credits
+	}
+	if err := json.Unmarshal(data, &credits); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring unreadable credits file: %v\n", err)
+		return make(map[string]CreditBalance)
+	}
+	return credits
+}
+
+// updateCredits applies fn to the recorded balances under the credits file lock
+func updateCredits(cfg *Config, fn func(map[string]CreditBalance)) error {
+	return withFileLock(cfg.CreditsFile+".lock", func() error {
+		credits := loadCredits(cfg)
+		fn(credits)
+		data, err := json.MarshalIndent(credits, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(cfg.CreditsFile, data, 0600)
+	})
+}
+
+// addCredits records amount on top of the backend's estimated remaining
+// balance and returns the new balance. The balance is read and replaced under
+// the credits file lock so concurrent top-ups are not lost.
+func addCredits(cfg *Config, backend string, amount float64) (float64, error) {
+	err := updateCredits(cfg, func(credits map[string]CreditBalance) {
+		if c, ok := credits[backend]; ok {
+			amount += creditStatuses(map[string]CreditBalance{backend: c}, ledgerUsage(cfg))[0].Remaining
+		}
+		credits[backend] = CreditBalance{Amount: amount, SetAt: time.Now()}
+	})
+	return amount, err
+}
+
+// creditStatuses estimates remaining balances from usage records, sorted by backend
+func creditStatuses(credits map[string]CreditBalance, records usageSource) []CreditStatus {
+	byBackend := make(map[string]*CreditStatus, len(credits))
+	for name, c := range credits {
+		if _, ok := backends[name]; ok {
+			byBackend[name] = &CreditStatus{Backend: name, Amount: c.Amount, SetAt: c.SetAt}
+		}
+	}
+	records(func(r UsageRecord) {
+		if s, ok := byBackend[r.Backend]; ok && r.Timestamp.After(s.SetAt) {
+			s.Spent += r.CostUSD
+		}
+	})
+	statuses := make([]CreditStatus, 0, len(byBackend))
+	for _, s := range byBackend {
+		s.Remaining = s.Amount - s.Spent
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Backend < statuses[j].Backend })
+	return statuses
+}
+
+// creditStatus returns the estimated balance for one backend, if recorded
+func creditStatus(cfg *Config, backend string) (CreditStatus, bool) {
+	credits := loadCredits(cfg)
+	c, ok := credits[backend]
+	if !ok {
+		return CreditStatus{}, false
+	}
+	statuses := creditStatuses(map[string]CreditBalance{backend: c}, ledgerUsage(cfg))
+	return statuses[0], true
+}
+
+// creditWarning returns a warning when the balance is at or below the lowest
+// crossed threshold, or an empty string when the balance is healthy
+func creditWarning(s CreditStatus, thresholds []float64) string {
+	if s.Remaining <= 0 {
+		return fmt.Sprintf("%s prepaid credit is exhausted (estimated %s remaining)", backends[s.Backend].DisplayName, formatCurrency(s.Remaining))
+	}
+	crossed := -1.0
+	for _, t := range thresholds {
+		if s.PercentLeft() <= t && (crossed < 0 || t < crossed) {
+			crossed = t
+		}
+	}
+	if crossed < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s prepaid credit below %.0f%%: %s of %s remaining", backends[s.Backend].DisplayName, crossed, formatCurrency(s.Remaining), formatCurrency(s.Amount))
+}
+
+// creditLevel reports whether a balance has crossed any warning threshold,
+// and whether it is critical: exhausted, or below every one of two or more
+// thresholds
+func creditLevel(s CreditStatus, thresholds []float64) (low, critical bool) {
+	if s.Remaining <= 0 {
+		return true, true
+	}
+	crossed := 0
+	for _, t := range thresholds {
+		if s.PercentLeft() <= t {
+			crossed++
+		}
+	}
+	return crossed > 0, crossed > 1 && crossed == len(thresholds)
+}
+
+// parseCreditWarn parses a comma-separated list of percentages
+func parseCreditWarn(value string) ([]float64, error) {
+	var thresholds []float64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSuffix(strings.TrimSpace(part), "%")
+		if part == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v <= 0 || v > 100 {
+			return nil, fmt.Errorf("'%s' is not a percentage between 0 and 100", part)
+		}
+		thresholds = append(thresholds, v)
+	}
+	if len(thresholds) == 0 {
+		return nil, errors.New("empty threshold list")
+	}
+	return thresholds, nil
+}
+
+// warnLowCredit prints a credit warning for a backend before launch
+func warnLowCredit(cfg *Config, be Backend) {
+	s, ok := creditStatus(cfg, be.Name)
+	if !ok {
+		return
+	}
+	if msg := creditWarning(s, cfg.CreditWarnPercents); msg != "" {
+		fmt.Fprintln(os.Stderr, styleWarning.Render("Warning: "+msg))
+	}
+}
+
+func handleCreditsCommand(args []string) {
+	if len(args) == 0 || args[0] == "status" {
+		showCredits()
+		return
+	}
+
+	cfg := loadConfig()
+	subcmd := args[0]
+	switch subcmd {
+	case "set", "add":
+		if len(args) < 3 {
+			fmt.Fprintf(os.Stderr, "Usage: promptops credits %s <backend> <amount>\n", subcmd)
+			os.Exit(1)
+		}
+		name := strings.ToLower(args[1])
+		if _, ok := backends[name]; !ok {
+			unknownBackendError(args[1])
+			os.Exit(1)
+		}
+		amount, err := strconv.ParseFloat(args[2], 64)
+		if err != nil || (subcmd == "set" && amount < 0) {
+			fmt.Fprintf(os.Stderr, "Error: Invalid amount: %s\n", args[2])
+			os.Exit(1)
+		}
+
+		// "add" records a top-up or correction on top of the current estimate
+		balance := amount
+		if subcmd == "add" {
+			balance, err = addCredits(cfg, name, amount)
+		} else {
+			err = updateCredits(cfg, func(credits map[string]CreditBalance) {
+				credits[name] = CreditBalance{Amount: amount, SetAt: time.Now()}
+			})
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving credits: %v\n", err)
+			os.Exit(1)
+		}
+		if subcmd == "add" {
+			auditLog(cfg, fmt.Sprintf("CREDITS_ADD: %s %+.2f (balance %.2f)", name, amount, balance))
+		} else {
+			auditLog(cfg, fmt.Sprintf("CREDITS_SET: %s %.2f", name, balance))
+		}
+		fmt.Printf("[OK] %s credit balance set to %s\n", backends[name].DisplayName, formatCurrency(balance))
+	case "clear":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: promptops credits clear <backend>")
+			os.Exit(1)
+		}
+		name := strings.ToLower(args[1])
+		if _, ok := backends[name]; !ok {
+			unknownBackendError(args[1])
+			os.Exit(1)
+		}
+		err := updateCredits(cfg, func(credits map[string]CreditBalance) {
+			delete(credits, name)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving credits: %v\n", err)
+			os.Exit(1)
+		}
+		auditLog(cfg, fmt.Sprintf("CREDITS_CLEARED: %s", name))
+		fmt.Printf("[OK] Cleared credit balance for %s\n", name)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown credits command: %s\n", subcmd)
+		os.Exit(1)
+	}
+}
+
+func showCredits() {
+	cfg := loadConfig()
+	statuses := creditStatuses(loadCredits(cfg), ledgerUsage(cfg))
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("PREPAID CREDITS"))
+	if len(statuses) == 0 {
+		fmt.Println(styleMuted.Render("No balances recorded. Use: promptops credits set <backend> <amount>"))
+		fmt.Println()
+		return
+	}
+	renderCredits(cfg, statuses)
+	fmt.Println()
+}
+
+// renderCredits prints recorded balances with their estimated remainder,
+// followed by any low balance warnings
+func renderCredits(cfg *Config, statuses []CreditStatus) {
+	rows := [][]string{}
+	for _, s := range statuses {
+		remaining := formatCurrency(s.Remaining)
+		switch low, critical := creditLevel(s, cfg.CreditWarnPercents); {
+		case critical:
+			remaining = styleError.Render(remaining)
+		case low:
+			remaining = styleWarning.Render(remaining)
+		}
+		rows = append(rows, []string{
+			backends[s.Backend].DisplayName,
+			formatCurrency(s.Amount),
+			formatCurrency(s.Spent),
+			remaining,
+			s.SetAt.Format("2006-01-02"),
+		})
+	}
+
+	t := table.New().
+		Headers("Backend", "Balance", "Used", "Remaining", "Since").
+		Rows(rows...).
+		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		}).
+		Width(80)
+
+	fmt.Println(t.Render())
+	for _, s := range statuses {
+		if msg := creditWarning(s, cfg.CreditWarnPercents); msg != "" {
+			fmt.Println(styleWarning.Render("Warning: " + msg))
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreditStatuses(t *testing.T) {
+	setAt := time.Now().Add(-time.Hour)
+	credits := map[string]CreditBalance{
+		"deepseek": {Amount: 10, SetAt: setAt},
+		"unknown":  {Amount: 5, SetAt: setAt},
+	}
+	records := []UsageRecord{
+		{Timestamp: setAt.Add(-time.Minute), Backend: "deepseek", CostUSD: 3}, // before the balance was set
+		{Timestamp: setAt.Add(time.Minute), Backend: "deepseek", CostUSD: 2.5},
+		{Timestamp: setAt.Add(time.Minute), Backend: "claude", CostUSD: 4},
+	}
+
+	statuses := creditStatuses(credits, usageRecords(records))
+	if len(statuses) != 1 {
+		t.Fatalf("Expected 1 status (unknown backends skipped), got %d", len(statuses))
+	}
+	s := statuses[0]
+	if s.Spent != 2.5 || s.Remaining != 7.5 {
+		t.Errorf("Expected spent 2.50 and remaining 7.50, got %.2f and %.2f", s.Spent, s.Remaining)
+	}
+	if s.PercentLeft() != 75 {
+		t.Errorf("Expected 75%% left, got %.1f", s.PercentLeft())
+	}
+}
+
+func TestCreditWarning(t *testing.T) {
+	thresholds := []float64{25, 10}
+	tests := []struct {
+		name      string
+		remaining float64
+		want      string
+	}{
+		{"healthy", 50, ""},
+		{"below first threshold", 20, "below 25%"},
+		{"below lowest threshold", 5, "below 10%"},
+		{"exhausted", -1, "exhausted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := creditWarning(CreditStatus{Backend: "deepseek", Amount: 100, Remaining: tt.remaining}, thresholds)
+			if tt.want == "" && msg != "" {
+				t.Errorf("Expected no warning, got %q", msg)
+			}
+			if tt.want != "" && !strings.Contains(msg, tt.want) {
+				t.Errorf("Expected warning containing %q, got %q", tt.want, msg)
+			}
+		})
+	}
+}
+
+func TestCreditLevel(t *testing.T) {
+	tests := []struct {
+		name          string
+		thresholds    []float64
+		remaining     float64
+		low, critical bool
+	}{
+		{"healthy", []float64{25, 10}, 50, false, false},
+		{"below first threshold", []float64{25, 10}, 20, true, false},
+		{"below every threshold", []float64{25, 10}, 5, true, true},
+		{"configured thresholds", []float64{50, 30}, 40, true, false},
+		{"below configured thresholds", []float64{50, 30}, 20, true, true},
+		{"single threshold", []float64{15}, 5, true, false},
+		{"exhausted", []float64{15}, 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			low, critical := creditLevel(CreditStatus{Backend: "deepseek", Amount: 100, Remaining: tt.remaining}, tt.thresholds)
+			if low != tt.low || critical != tt.critical {
+				t.Errorf("creditLevel() = %t, %t, want %t, %t", low, critical, tt.low, tt.critical)
+			}
+		})
+	}
+}
+
+func TestCreditsAddAudited(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("NEXUS_ENV_FILE", "")
+	setGlobalOptions(t, GlobalOptions{ConfigDir: dir, Quiet: true})
+	handleCreditsCommand([]string{"set", "deepseek", "10"})
+	handleCreditsCommand([]string{"add", "deepseek", "5"})
+
+	audit, err := os.ReadFile(loadConfig().AuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(audit), "CREDITS_SET: deepseek 10.00") || !strings.Contains(string(audit), "CREDITS_ADD: deepseek +5.00 (balance 15.00)") {
+		t.Errorf("Expected distinct set and add entries, got %q", string(audit))
+	}
+}
+
+func TestParseCreditWarn(t *testing.T) {
+	got, err := parseCreditWarn("50%, 20,5")
+	if err != nil {
+		t.Fatalf("parseCreditWarn() error: %v", err)
+	}
+	if len(got) != 3 || got[0] != 50 || got[1] != 20 || got[2] != 5 {
+		t.Errorf("parseCreditWarn() = %v", got)
+	}
+
+	for _, bad := range []string{"", "abc", "0", "150"} {
+		if _, err := parseCreditWarn(bad); err == nil {
+			t.Errorf("parseCreditWarn(%q) should fail", bad)
+		}
+	}
+}
+
+func TestUpdateCreditsPersists(t *testing.T) {
+	cfg := &Config{CreditsFile: filepath.Join(t.TempDir(), "credits.json")}
+
+	err := updateCredits(cfg, func(credits map[string]CreditBalance) {
+		credits["deepseek"] = CreditBalance{Amount: 10, SetAt: time.Now()}
+	})
+	if err != nil {
+		t.Fatalf("updateCredits() error: %v", err)
+	}
+	info, err := os.Stat(cfg.CreditsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	s, ok := creditStatus(cfg, "deepseek")
+	if !ok || s.Remaining != 10 {
+		t.Errorf("Expected recorded balance 10, got %+v (found=%t)", s, ok)
+	}
+	if _, ok := creditStatus(cfg, "claude"); ok {
+		t.Error("Expected no balance for untracked backend")
+	}
+}
+
+func TestAddCreditsConcurrent(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	if _, err := addCredits(cfg, "deepseek", 10); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := addCredits(cfg, "deepseek", 5); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if s, _ := creditStatus(cfg, "deepseek"); s.Remaining != 50 {
+		t.Errorf("Expected every top-up kept, got %.2f", s.Remaining)
+	}
+}
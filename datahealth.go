@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Data file health thresholds
+const (
+	// usageWarnSize is where reading the whole usage file on every status and
+	// budget check starts to be noticeable
+	usageWarnSize = 100 << 20
+	auditWarnSize = 50 << 20
+	minFreeDisk   = 500 << 20
+	// orphanTempAge is how old a .tmp-<digits> file must be before it is treated as
+	// left behind by an interrupted write rather than one in progress
+	orphanTempAge = time.Hour
+)
+
+// DataCheck is the result of one data file health check
+type DataCheck struct {
+	Name    string
+	OK      bool
+	Message string
+}
+
+// formatBytes renders a size such as 512 B, 3.4 KB or 12.0 MB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// freeDiskSpace returns the bytes available to this user on dir's filesystem
+func freeDiskSpace(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+// dataDirs are the directories promptops writes atomically into
+func dataDirs(cfg *Config) []string {
+	dirs := []string{filepath.Dir(cfg.StateFile)}
+	if cfg.PromptAnswerDir != "" {
+		dirs = append(dirs, cfg.PromptAnswerDir)
+	}
+	return dirs
+}
+
+// findOrphanedTempFiles lists writeFileAtomic temp files in dirs older than
+// orphanTempAge, left behind when a write was interrupted before its rename
+func findOrphanedTempFiles(dirs []string, now time.Time) []string {
+	var orphans []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !isAtomicTempName(e.Name()) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || now.Sub(info.ModTime()) < orphanTempAge {
+				continue
+			}
+			orphans = append(orphans, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(orphans)
+	return orphans
+}
+
+// isAtomicTempName reports whether name is a writeFileAtomic temp file,
+// .tmp- followed by a nanosecond timestamp. Other .tmp-* files belong to
+// someone else and are left alone.
+func isAtomicTempName(name string) bool {
+	digits, ok := strings.CutPrefix(name, ".tmp-")
+	if !ok || digits == "" {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// usageGrowthPerDay estimates how fast the usage file grows from the records
+// of the last week and the file's average record size
+func usageGrowthPerDay(records usageSource, size int64, now time.Time) int64 {
+	total, recent := 0, 0
+	records(func(r UsageRecord) {
+		total++
+		if now.Sub(r.Timestamp) < 7*24*time.Hour {
+			recent++
+		}
+	})
+	if total == 0 {
+		return 0
+	}
+	return size / int64(total) * int64(recent) / 7
+}
+
+// checkDataHealth checks data file sizes, free disk space and orphaned temp
+// files. Usage growth is reported when records are given.
+func checkDataHealth(cfg *Config, records usageSource, now time.Time) []DataCheck {
+	var checks []DataCheck
+
+	if info, err := os.Stat(cfg.UsageFile); err == nil {
+		c := DataCheck{Name: "Usage file", OK: info.Size() < usageWarnSize, Message: formatBytes(info.Size())}
+		if records != nil {
+			c.Message += fmt.Sprintf(", +%s/day", formatBytes(usageGrowthPerDay(records, info.Size(), now)))
+		}
+		if !c.OK {
+			c.Message += fmt.Sprintf(" (over %s; status and budget checks read it in full)", formatBytes(usageWarnSize))
+		}
+		checks = append(checks, c)
+	}
+
+	if info, err := os.Stat(cfg.AuditLog); err == nil {
+		c := DataCheck{Name: "Audit log", OK: info.Size() < auditWarnSize, Message: formatBytes(info.Size())}
+		if !c.OK {
+			c.Message += fmt.Sprintf(" (over %s; archive it to keep it manageable)", formatBytes(auditWarnSize))
+		}
+		checks = append(checks, c)
+	}
+
+	dir := filepath.Dir(cfg.StateFile)
+	if free, err := freeDiskSpace(dir); err == nil {
+		c := DataCheck{Name: "Free disk space", OK: free >= minFreeDisk, Message: formatBytes(free) + " in " + dir}
+		if !c.OK {
+			c.Message += "; writes of usage records and sessions may fail"
+		}
+		checks = append(checks, c)
+	}
+
+	orphans := findOrphanedTempFiles(dataDirs(cfg), now)
+	c := DataCheck{Name: "Temp files", OK: len(orphans) == 0, Message: "none left by interrupted writes"}
+	if len(orphans) > 0 {
+		var total int64
+		for _, path := range orphans {
+			if info, err := os.Stat(path); err == nil {
+				total += info.Size()
+			}
+		}
+		c.Message = fmt.Sprintf("%d orphaned .tmp-<n> files (%s); remove them with 'promptops gc'", len(orphans), formatBytes(total))
+	}
+	checks = append(checks, c)
+	return checks
+}
+
+// renderDataChecks prints data file checks in the doctor's line format
+func renderDataChecks(checks []DataCheck, out io.Writer) {
+	for _, c := range checks {
+		status := styleSuccess.Render("[OK]  ")
+		if !c.OK {
+			status = styleWarning.Render("[WARN]")
+		}
+		fmt.Fprintf(out, "%s %-22s %s\n", status, c.Name, c.Message)
+	}
+}
+
+// warnDataHealth prints failing data file checks before a launch. Usage
+// growth is not computed here, so the usage file is not read.
+func warnDataHealth(cfg *Config) {
+	for _, c := range checkDataHealth(cfg, nil, time.Now()) {
+		if !c.OK {
+			fmt.Fprintln(os.Stderr, styleWarning.Render(fmt.Sprintf("Warning: %s: %s", c.Name, c.Message)))
+		}
+	}
+}
+
+// handleGCCommand removes orphaned temp files: promptops gc [--dry-run]
+func handleGCCommand(args []string) {
+	args, dryRun := stripFlag(args, "--dry-run")
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: promptops gc [--dry-run]")
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+	orphans := findOrphanedTempFiles(dataDirs(cfg), time.Now())
+	if len(orphans) == 0 {
+		fmt.Println("No orphaned temp files.")
+		return
+	}
+	removed := 0
+	var freed int64
+	for _, path := range orphans {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would remove %s (%s)\n", path, formatBytes(info.Size()))
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", path, err)
+			continue
+		}
+		removed++
+		freed += info.Size()
+	}
+	if !dryRun {
+		fmt.Printf("[OK] Removed %d orphaned temp files (%s)\n", removed, formatBytes(freed))
+		auditLog(cfg, fmt.Sprintf("GC: removed %d temp files", removed))
+	}
+}
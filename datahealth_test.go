@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:         "0 B",
+		512:       "512 B",
+		1536:      "1.5 KB",
+		100 << 20: "100.0 MB",
+		3 << 30:   "3.0 GB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestFindOrphanedTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := filepath.Join(dir, ".tmp-1")
+	fresh := filepath.Join(dir, ".tmp-2")
+	foreign := filepath.Join(dir, ".tmp-editor-swap")
+	for _, path := range []string{old, fresh, foreign, filepath.Join(dir, "usage.jsonl")} {
+		os.WriteFile(path, []byte("x"), 0644)
+	}
+	os.Chtimes(old, now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	os.Chtimes(foreign, now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+
+	orphans := findOrphanedTempFiles([]string{dir, filepath.Join(dir, "missing")}, now)
+	if len(orphans) != 1 || orphans[0] != old {
+		t.Errorf("Expected only the old temp file, got %v", orphans)
+	}
+}
+
+func TestIsAtomicTempName(t *testing.T) {
+	for name, want := range map[string]bool{
+		".tmp-1729000000000000000": true,
+		".tmp-":                    false,
+		".tmp-abc":                 false,
+		".tmp-123.json":            false,
+		"tmp-123":                  false,
+	} {
+		if got := isAtomicTempName(name); got != want {
+			t.Errorf("isAtomicTempName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestUsageGrowthPerDay(t *testing.T) {
+	now := time.Now()
+	var records []UsageRecord
+	for i := 0; i < 14; i++ {
+		records = append(records, UsageRecord{Timestamp: now.Add(-time.Duration(i) * 24 * time.Hour)})
+	}
+	// 14 records of 100 bytes, 7 of them this week: one record a day
+	if got := usageGrowthPerDay(usageRecords(records), 1400, now); got != 100 {
+		t.Errorf("Expected 100 bytes/day, got %d", got)
+	}
+	if got := usageGrowthPerDay(usageRecords(nil), 1400, now); got != 0 {
+		t.Errorf("Expected no growth without records, got %d", got)
+	}
+}
+
+func TestCheckDataHealth(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newSelfTestConfig(dir)
+	os.WriteFile(cfg.UsageFile, []byte("{}\n"), 0644)
+	tmp := filepath.Join(dir, ".tmp-123")
+	os.WriteFile(tmp, []byte("partial"), 0644)
+	os.Chtimes(tmp, time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour))
+
+	checks := checkDataHealth(cfg, usageRecords([]UsageRecord{{Timestamp: time.Now()}}), time.Now())
+	byName := map[string]DataCheck{}
+	for _, c := range checks {
+		byName[c.Name] = c
+	}
+	if c := byName["Usage file"]; !c.OK || !strings.Contains(c.Message, "/day") {
+		t.Errorf("Expected a healthy usage file with growth, got %+v", c)
+	}
+	if _, ok := byName["Free disk space"]; !ok {
+		t.Error("Expected a free disk space check")
+	}
+	if c := byName["Temp files"]; c.OK || !strings.Contains(c.Message, "1 orphaned") {
+		t.Errorf("Expected the orphaned temp file reported, got %+v", c)
+	}
+
+	os.Remove(tmp)
+	for _, c := range checkDataHealth(cfg, nil, time.Now()) {
+		if c.Name == "Temp files" && !c.OK {
+			t.Errorf("Expected no orphans after removal, got %+v", c)
+		}
+		if c.Name == "Usage file" && strings.Contains(c.Message, "/day") {
+			t.Errorf("Expected no growth without records, got %+v", c)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// maxDecisions is how many proxy decisions are kept; older ones are dropped
+const maxDecisions = 200
+
+// ProxyDecision records how the proxy handled one message request, for
+// `promptops debug last` when a session misbehaves. Prompts and responses are
+// not recorded.
+type ProxyDecision struct {
+	Time      time.Time `json:"time"`
+	Backend   string    `json:"backend"`
+	SessionID string    `json:"session_id,omitempty"`
+	Requested string    `json:"requested"` // model Claude Code asked for
+	Model     string    `json:"model"`     // model sent upstream after mapping
+	Upstream  string    `json:"upstream"`
+	Stream    bool      `json:"stream,omitempty"`
+	// Demoted is set when latency-based tier demotion replaced the model
+	Demoted bool `json:"demoted,omitempty"`
+	// Compacted is set when the history was summarized before sending
+	Compacted bool `json:"compacted,omitempty"`
+	// SimilarPrompt is set when the prompt index found a near-duplicate
+	SimilarPrompt bool `json:"similar_prompt,omitempty"`
+	// BackendPromptTokens estimates what the backend's system prompt
+	// prefix/suffix added to the request
+	BackendPromptTokens int `json:"backend_prompt_tokens,omitempty"`
+	// TimedOut is set when the upstream exceeded the latency budget
+	TimedOut bool `json:"timed_out,omitempty"`
+	// MaxTokensLowered is the max_tokens the client asked for when the
+	// backend's cap lowered it
+	MaxTokensLowered int `json:"max_tokens_lowered,omitempty"`
+	// Chaos is set when chaos mode was active for the request
+	Chaos        bool    `json:"chaos,omitempty"`
+	Status       int     `json:"status"`
+	DurationMS   int64   `json:"duration_ms"`
+	InputTokens  int     `json:"input_tokens,omitempty"`
+	OutputTokens int     `json:"output_tokens,omitempty"`
+	CostUSD      float64 `json:"cost_usd,omitempty"`
+}
+
+// statusWriter remembers the status code written to a response, keeping
+// streaming flushes working
+type statusWriter struct {
+	http.ResponseWriter
+	status   int
+	timedOut bool // the upstream exceeded the latency budget
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// SetDecisionRecorder registers a callback invoked with a decision record
+// after each message request
+func (p *OllamaProxy) SetDecisionRecorder(record func(ProxyDecision)) {
+	p.logDecision = record
+}
+
+// loadDecisions reads the decision log, oldest first. Unreadable lines are
+// skipped.
+func loadDecisions(path string) []ProxyDecision {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var decisions []ProxyDecision
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var d ProxyDecision
+		if json.Unmarshal(line, &d) == nil {
+			decisions = append(decisions, d)
+		}
+	}
+	return decisions
+}
+
+// appendDecision adds d to the decision log, keeping only the newest
+// maxDecisions records
+func appendDecision(path string, d ProxyDecision) error {
+	return withFileLock(path+".lock", func() error {
+		decisions := append(loadDecisions(path), d)
+		if len(decisions) > maxDecisions {
+			decisions = decisions[len(decisions)-maxDecisions:]
+		}
+		var buf bytes.Buffer
+		for _, d := range decisions {
+			line, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+		return writeFileAtomic(path, buf.Bytes(), 0600)
+	})
+}
+
+// recordProxyDecision prices and persists a decision. Failures only warn:
+// the log is a debugging aid and must not disturb the session.
+func recordProxyDecision(cfg *Config, d ProxyDecision) {
+	if be, ok := backends[d.Backend]; ok {
+		d.CostUSD = priceUsage(cfg, be, d.Model, AnthropicUsage{InputTokens: d.InputTokens, OutputTokens: d.OutputTokens}).CostUSD
+	}
+	if cfg.DecisionsFile == "" {
+		return
+	}
+	if err := appendDecision(cfg.DecisionsFile, d); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record proxy decision: %v\n", err)
+	}
+}
+
+// decisionFlags summarizes what the proxy did beyond forwarding
+func decisionFlags(d ProxyDecision) string {
+	var flags []byte
+	add := func(set bool, flag string) {
+		if !set {
+			return
+		}
+		if len(flags) > 0 {
+			flags = append(flags, ',')
+		}
+		flags = append(flags, flag...)
+	}
+	add(d.Stream, "stream")
+	add(d.Demoted, "demoted")
+	add(d.Compacted, "compacted")
+	add(d.SimilarPrompt, "similar")
+	add(d.Chaos, "chaos")
+	add(d.BackendPromptTokens > 0, "backend-prompt")
+	add(d.TimedOut, "timeout")
+	add(d.MaxTokensLowered > 0, "capped")
+	if len(flags) == 0 {
+		return "-"
+	}
+	return string(flags)
+}
+
+// handleDebugCommand handles promptops debug last [n]
+func handleDebugCommand(args []string) {
+	if len(args) == 0 || args[0] != "last" || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "Usage: promptops debug last [n]")
+		os.Exit(1)
+	}
+	n := 10
+	if len(args) == 2 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 1 {
+			fmt.Fprintf(os.Stderr, "Error: invalid count '%s'\n", args[1])
+			os.Exit(1)
+		}
+		n = v
+	}
+	cfg := loadConfig()
+	showDecisions(loadDecisions(cfg.DecisionsFile), n)
+}
+
+// showDecisions prints the n most recent decisions, newest first
+func showDecisions(decisions []ProxyDecision, n int) {
+	if len(decisions) == 0 {
+		fmt.Println("No proxy decisions recorded. They are written by the Ollama proxy during launches and swarms.")
+		return
+	}
+	start := 0
+	if len(decisions) > n {
+		start = len(decisions) - n
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("PROXY DECISIONS"))
+
+	rows := [][]string{}
+	for i := len(decisions) - 1; i >= start; i-- {
+		d := decisions[i]
+		model := d.Model
+		if d.Requested != "" && d.Requested != d.Model {
+			model = d.Requested + " -> " + d.Model
+		}
+		rows = append(rows, []string{
+			d.Time.Local().Format("01-02 15:04:05"),
+			truncate(model, 40),
+			d.Upstream,
+			strconv.Itoa(d.Status),
+			formatElapsed(time.Duration(d.DurationMS) * time.Millisecond),
+			fmt.Sprintf("%s/%s", formatTokenCount(int64(d.InputTokens)), formatTokenCount(int64(d.OutputTokens))),
+			fmt.Sprintf("$%.4f", d.CostUSD),
+			decisionFlags(d),
+		})
+	}
+
+	t := table.New().
+		Headers("Time", "Model", "Upstream", "Status", "Took", "In/Out", "Cost", "Flags").
+		Rows(rows...).
+		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
+			}
+			if col == 3 && rows[row-1][3] != "200" {
+				return lipgloss.NewStyle().Padding(0, 1).Foreground(colorWarning)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+
+	fmt.Println(t.Render())
+	fmt.Println()
+}
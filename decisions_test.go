@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendDecisionKeepsNewest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	for i := 0; i < maxDecisions+5; i++ {
+		if err := appendDecision(path, ProxyDecision{Model: "m", InputTokens: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decisions := loadDecisions(path)
+	if len(decisions) != maxDecisions {
+		t.Fatalf("Expected %d decisions, got %d", maxDecisions, len(decisions))
+	}
+	if decisions[0].InputTokens != 5 || decisions[len(decisions)-1].InputTokens != maxDecisions+4 {
+		t.Errorf("Expected the oldest records dropped, got %d..%d", decisions[0].InputTokens, decisions[len(decisions)-1].InputTokens)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected 0600 permissions, got %v", info.Mode().Perm())
+	}
+}
+
+func TestDecisionFlags(t *testing.T) {
+	if got := decisionFlags(ProxyDecision{}); got != "-" {
+		t.Errorf("Expected '-', got %q", got)
+	}
+	if got := decisionFlags(ProxyDecision{Stream: true, Demoted: true, Chaos: true}); got != "stream,demoted,chaos" {
+		t.Errorf("Unexpected flags %q", got)
+	}
+}
+
+func TestProxyRecordsDecisions(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model == "missing:latest" {
+			http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","model":"llama3.2:latest","choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`))
+	}))
+	defer upstream.Close()
+
+	var decisions []ProxyDecision
+	p := NewOllamaProxy(upstream.URL, map[string]string{"claude-haiku": "llama3.2:latest"})
+	p.SetDecisionRecorder(func(d ProxyDecision) { decisions = append(decisions, d) })
+
+	for _, model := range []string{"claude-haiku", "missing:latest"} {
+		body := `{"model":"` + model + `","max_tokens":10,"messages":[{"role":"user","content":"hello"}]}`
+		p.handleMessages(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body)))
+	}
+
+	if len(decisions) != 2 {
+		t.Fatalf("Expected 2 decisions, got %d", len(decisions))
+	}
+	d := decisions[0]
+	if d.Requested != "claude-haiku" || d.Model != "llama3.2:latest" || d.Upstream != defaultUpstream || d.Status != http.StatusOK {
+		t.Errorf("Unexpected decision %+v", d)
+	}
+	if d.InputTokens != 12 || d.OutputTokens != 3 || d.Time.IsZero() {
+		t.Errorf("Expected usage and time recorded, got %+v", d)
+	}
+	if decisions[1].Status != http.StatusNotFound {
+		t.Errorf("Expected the upstream error status, got %d", decisions[1].Status)
+	}
+}
+
+func TestRecordProxyDecisionPricesUsage(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	recordProxyDecision(cfg, ProxyDecision{Time: time.Now(), Backend: "deepseek", Model: "deepseek-chat", InputTokens: 1000000})
+	decisions := loadDecisions(cfg.DecisionsFile)
+	if len(decisions) != 1 {
+		t.Fatalf("Expected 1 decision, got %d", len(decisions))
+	}
+	input, _ := pricingAt(cfg, backends["deepseek"], time.Now())
+	if decisions[0].CostUSD != input {
+		t.Errorf("Expected cost %v, got %v", input, decisions[0].CostUSD)
+	}
+}
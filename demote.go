@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tier demotion defaults
+const (
+	defaultDemoteRequests = 5
+	// latencyWindow is the number of recent upstream latencies p95 is taken over
+	latencyWindow = 20
+)
+
+// TierDemoter watches upstream latency and, once p95 stays above a threshold
+// for a number of consecutive requests, routes haiku-tier requests to a
+// smaller model until latency has stayed below the threshold for as long
+type TierDemoter struct {
+	threshold   time.Duration
+	consecutive int
+	from, to    string
+	notify      func(string)
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	over      int // consecutive observations with p95 above threshold
+	under     int // consecutive observations with p95 at or below threshold
+	demoted   bool
+}
+
+// NewTierDemoter creates a demoter that sends requests for from to to while
+// the upstream is slow. notify is called once on each change.
+func NewTierDemoter(threshold time.Duration, consecutive int, from, to string, notify func(string)) *TierDemoter {
+	if consecutive <= 0 {
+		consecutive = defaultDemoteRequests
+	}
+	return &TierDemoter{threshold: threshold, consecutive: consecutive, from: from, to: to, notify: notify}
+}
+
+// p95 returns the 95th percentile of the latencies, nearest-rank
+func p95(latencies []time.Duration) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := (len(sorted)*95 + 99) / 100
+	return sorted[rank-1]
+}
+
+// Observe records the latency of an upstream request
+func (d *TierDemoter) Observe(latency time.Duration) {
+	d.mu.Lock()
+	d.latencies = append(d.latencies, latency)
+	if len(d.latencies) > latencyWindow {
+		d.latencies = d.latencies[len(d.latencies)-latencyWindow:]
+	}
+	current := p95(d.latencies)
+	if current > d.threshold {
+		d.over++
+		d.under = 0
+	} else {
+		d.under++
+		d.over = 0
+	}
+
+	var msg string
+	switch {
+	case !d.demoted && d.over >= d.consecutive:
+		d.demoted = true
+		msg = fmt.Sprintf("Upstream p95 latency %s is above %s; haiku-tier requests now use %s",
+			formatDuration(current), formatDuration(d.threshold), d.to)
+	case d.demoted && d.under >= d.consecutive:
+		d.demoted = false
+		msg = fmt.Sprintf("Upstream latency recovered (p95 %s); haiku-tier requests use %s again",
+			formatDuration(current), d.from)
+	}
+	d.mu.Unlock()
+
+	if msg != "" && d.notify != nil {
+		d.notify(msg)
+	}
+}
+
+// Route returns the model to request upstream for model
+func (d *TierDemoter) Route(model string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.demoted && model == d.from {
+		return d.to
+	}
+	return model
+}
+
+// Demoted reports whether haiku-tier requests are currently demoted
+func (d *TierDemoter) Demoted() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.demoted
+}
+
+// EnableTierDemotion demotes requests for haikuModel to smallModel while
+// upstream p95 latency exceeds threshold
+func (p *OllamaProxy) EnableTierDemotion(threshold time.Duration, consecutive int, haikuModel, smallModel string) {
+	p.demoter = NewTierDemoter(threshold, consecutive, p.mapModel(haikuModel), p.mapModel(smallModel), func(msg string) {
+		fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] "+msg))
+	})
+}
+
+// observeLatency feeds an upstream latency to the demoter, if enabled
+func (p *OllamaProxy) observeLatency(start time.Time) {
+	if p.demoter != nil {
+		p.demoter.Observe(time.Since(start))
+	}
+}
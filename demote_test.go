@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestP95(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 20; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Second)
+	}
+	if got := p95(latencies); got != 19*time.Second {
+		t.Errorf("Expected p95 of 1..20s to be 19s, got %s", got)
+	}
+	if got := p95([]time.Duration{3 * time.Second}); got != 3*time.Second {
+		t.Errorf("Expected single value, got %s", got)
+	}
+	if got := p95(nil); got != 0 {
+		t.Errorf("Expected 0 for no latencies, got %s", got)
+	}
+}
+
+func TestTierDemoter(t *testing.T) {
+	var notes []string
+	d := NewTierDemoter(time.Second, 3, "llama3.2:3b", "llama3.2:1b", func(msg string) {
+		notes = append(notes, msg)
+	})
+
+	d.Observe(2 * time.Second)
+	d.Observe(2 * time.Second)
+	if d.Demoted() || d.Route("llama3.2:3b") != "llama3.2:3b" {
+		t.Fatal("Expected no demotion before the consecutive limit")
+	}
+	d.Observe(2 * time.Second)
+	d.Observe(2 * time.Second)
+	if !d.Demoted() || d.Route("llama3.2:3b") != "llama3.2:1b" {
+		t.Fatal("Expected haiku tier demoted after 3 slow requests")
+	}
+	if d.Route("llama3.3:70b") != "llama3.3:70b" {
+		t.Error("Expected other tiers to be left alone")
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "llama3.2:1b") {
+		t.Errorf("Expected a single demotion notice, got %v", notes)
+	}
+
+	// p95 over the window stays high until the slow requests age out, then
+	// must stay low for 3 requests
+	for i := 0; i < latencyWindow-2; i++ {
+		d.Observe(100 * time.Millisecond)
+	}
+	if !d.Demoted() {
+		t.Fatal("Expected demotion to hold while slow requests remain in the window")
+	}
+	for i := 0; i < 3; i++ {
+		d.Observe(100 * time.Millisecond)
+	}
+	if d.Demoted() || d.Route("llama3.2:3b") != "llama3.2:3b" {
+		t.Error("Expected tier restored once latency recovered")
+	}
+	if len(notes) != 2 || !strings.Contains(notes[1], "recovered") {
+		t.Errorf("Expected a single recovery notice, got %v", notes)
+	}
+}
+
+func TestProxyTierDemotion(t *testing.T) {
+	var models []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Model)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	proxy := NewOllamaProxy(upstream.URL, map[string]string{})
+	proxy.demoter = NewTierDemoter(0, 1, "small", "tiny", nil)
+
+	send := func() {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"small","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+		proxy.handleMessages(httptest.NewRecorder(), req)
+	}
+	send()
+	send()
+	if len(models) != 2 || models[0] != "small" || models[1] != "tiny" {
+		t.Errorf("Expected the second request demoted, got %v", models)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// backendDiffRow is one compared setting
+type backendDiffRow struct {
+	Setting string
+	A, B    string
+}
+
+// Differs reports whether the two backends configure the setting differently
+func (r backendDiffRow) Differs() bool {
+	return r.A != r.B
+}
+
+// backendSettings returns the settings a launch with be would configure, in
+// display order. Credentials are reported as set or not set, never shown.
+func backendSettings(cfg *Config, be Backend) ([]string, map[string]string) {
+	values := make(map[string]string)
+	var order []string
+	set := func(key, value string) {
+		if _, ok := values[key]; !ok {
+			order = append(order, key)
+		}
+		values[key] = value
+	}
+
+	set("Provider", be.Provider)
+	set("Models", be.Models)
+	set("Coding tier", be.CodingTier)
+
+	baseURL := be.BaseURL
+	if baseURL == "" {
+		baseURL = "(Anthropic default)"
+	}
+	if port, ok := launchProxyPorts[be.Name]; ok {
+		baseURL = fmt.Sprintf("%s -> %s", proxyURL(port), be.BaseURL)
+	}
+	set("ANTHROPIC_BASE_URL", baseURL)
+
+	auth := "not set"
+	if cfg.Keys[be.AuthVar] != "" {
+		auth = "set"
+	} else if be.Name == "ollama" {
+		auth = "not required"
+	}
+	set("API key", fmt.Sprintf("%s (%s)", be.AuthVar, auth))
+
+	// Tier variables default to Claude Code's own choice when not set
+	for _, tier := range modelTiers {
+		set(fmt.Sprintf("ANTHROPIC_DEFAULT_%s_MODEL", strings.ToUpper(tier)), "(Claude Code default)")
+	}
+	env, err := buildLaunchEnv(cfg, be, nil, "")
+	if err != nil {
+		set("Launch error", err.Error())
+	}
+	for _, kv := range env {
+		// The base URL and key are reported above; the key is never shown
+		key, value, ok := strings.Cut(kv, "=")
+		if ok && key != "ANTHROPIC_AUTH_TOKEN" && key != "ANTHROPIC_BASE_URL" {
+			set(key, value)
+		}
+	}
+	if _, ok := values["API_TIMEOUT_MS"]; !ok {
+		set("API_TIMEOUT_MS", "(Claude Code default)")
+	}
+
+	set("Pricing (in/out per 1M)", fmt.Sprintf("$%.2f / $%.2f", be.InputPrice, be.OutputPrice))
+	inputPrice, outputPrice := opusTierPricing(cfg, be)
+	set("Opus tier pricing", fmt.Sprintf("$%.2f / $%.2f", inputPrice, outputPrice))
+	set("Typical session cost", formatCurrency(projectedSessionCost(inputPrice, outputPrice)))
+
+	set("Health timeout", cfg.healthTimeout(be.Name).String())
+	set("Usage timeout", cfg.usageTimeout(be.Name).String())
+
+	set("YOLO mode", fmt.Sprintf("%t", cfg.getYoloMode(be.Name)))
+	launchArgs := strings.Join(cfg.LaunchArgs[be.Name], " ")
+	if launchArgs == "" {
+		launchArgs = "-"
+	}
+	set("Launch args", launchArgs)
+
+	proxy := "none"
+	switch be.Name {
+	case "ollama":
+		proxy = "Anthropic-to-OpenAI translation"
+	case "grok":
+		proxy = "xAI compatibility"
+	}
+	set("Local proxy", proxy)
+
+	terms := termsFor(be.Name)
+	set("Region", terms.Region)
+	set("Retention", terms.Retention)
+	set("Trains on inputs", terms.Training)
+
+	if s, ok := creditStatus(cfg, be.Name); ok {
+		set("Prepaid credit", formatCurrency(s.Remaining))
+	} else {
+		set("Prepaid credit", "-")
+	}
+	return order, values
+}
+
+// diffBackendSettings compares what two backends would configure, keeping
+// the display order of a and appending settings only b has
+func diffBackendSettings(cfg *Config, a, b Backend) []backendDiffRow {
+	orderA, valuesA := backendSettings(cfg, a)
+	orderB, valuesB := backendSettings(cfg, b)
+
+	rows := make([]backendDiffRow, 0, len(orderA))
+	seen := make(map[string]bool)
+	for _, key := range orderA {
+		seen[key] = true
+		rows = append(rows, backendDiffRow{Setting: key, A: valuesA[key], B: orDash(valuesB[key])})
+	}
+	var extra []string
+	for _, key := range orderB {
+		if !seen[key] {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	for _, key := range extra {
+		rows = append(rows, backendDiffRow{Setting: key, A: "-", B: valuesB[key]})
+	}
+	return rows
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func handleDiffBackend(args []string) {
+	args, onlyChanged := stripFlag(args, "--changed")
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: promptops diff-backend <backend> <backend> [--changed]")
+		os.Exit(1)
+	}
+	var selected [2]Backend
+	for i, name := range args {
+		be, ok := backends[strings.ToLower(name)]
+		if !ok {
+			unknownBackendError(name)
+			os.Exit(1)
+		}
+		selected[i] = be
+	}
+
+	cfg := loadConfig()
+	rows := diffBackendSettings(cfg, selected[0], selected[1])
+
+	fmt.Println()
+	fmt.Println(styleSection.Render(fmt.Sprintf("BACKEND DIFF: %s vs %s", selected[0].DisplayName, selected[1].DisplayName)))
+	renderBackendDiff(selected[0], selected[1], rows, onlyChanged)
+	fmt.Println()
+}
+
+// renderBackendDiff prints the comparison, coloring settings that differ
+func renderBackendDiff(a, b Backend, rows []backendDiffRow, onlyChanged bool) {
+	tableRows := [][]string{}
+	changed := 0
+	for _, r := range rows {
+		if r.Differs() {
+			changed++
+		} else if onlyChanged {
+			continue
+		}
+		marker := " "
+		valueA, valueB := truncate(r.A, 40), truncate(r.B, 40)
+		if r.Differs() {
+			marker = styleWarning.Render("~")
+			valueA = styleError.Render(valueA)
+			valueB = styleSuccess.Render(valueB)
+		} else {
+			valueA = styleMuted.Render(valueA)
+			valueB = styleMuted.Render(valueB)
+		}
+		tableRows = append(tableRows, []string{marker, r.Setting, valueA, valueB})
+	}
+
+	t := table.New().
+		Headers("", "Setting", a.DisplayName, b.DisplayName).
+		Rows(tableRows...).
+		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary).Padding(0, 1)
+			}
+			if col == 0 {
+				return lipgloss.NewStyle().Width(2)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		}).
+		Width(120)
+
+	fmt.Println(t.Render())
+	fmt.Printf("%d of %d settings differ\n", changed, len(rows))
+}
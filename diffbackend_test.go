@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func diffTestConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.Keys["DEEPSEEK_API_KEY"] = "sk-test-secret-value"
+	return cfg
+}
+
+func findDiffRow(rows []backendDiffRow, setting string) (backendDiffRow, bool) {
+	for _, r := range rows {
+		if r.Setting == setting {
+			return r, true
+		}
+	}
+	return backendDiffRow{}, false
+}
+
+func TestDiffBackendSettings(t *testing.T) {
+	cfg := diffTestConfig(t)
+	rows := diffBackendSettings(cfg, backends["deepseek"], backends["zai"])
+
+	key, ok := findDiffRow(rows, "API key")
+	if !ok || key.A != "DEEPSEEK_API_KEY (set)" || key.B != "ZAI_API_KEY (not set)" {
+		t.Errorf("Unexpected API key row: %+v", key)
+	}
+	for _, r := range rows {
+		if strings.Contains(r.A, "secret") || strings.Contains(r.B, "secret") {
+			t.Fatalf("Key value leaked in %q row", r.Setting)
+		}
+	}
+
+	sonnet, ok := findDiffRow(rows, "ANTHROPIC_DEFAULT_SONNET_MODEL")
+	if !ok || !sonnet.Differs() || sonnet.A != backends["deepseek"].SonnetModel {
+		t.Errorf("Unexpected sonnet row: %+v", sonnet)
+	}
+	if region, _ := findDiffRow(rows, "Region"); region.A != "China" || region.B != "Singapore" {
+		t.Errorf("Unexpected region row: %+v", region)
+	}
+	if health, _ := findDiffRow(rows, "Health timeout"); health.Differs() {
+		t.Errorf("Expected identical default health timeouts, got %+v", health)
+	}
+}
+
+func TestBackendSettingsProxyAndDefaults(t *testing.T) {
+	cfg := diffTestConfig(t)
+
+	_, ollama := backendSettings(cfg, backends["ollama"])
+	if !strings.HasPrefix(ollama["ANTHROPIC_BASE_URL"], "http://127.0.0.1:18080 -> ") {
+		t.Errorf("Expected proxied base URL for ollama, got %q", ollama["ANTHROPIC_BASE_URL"])
+	}
+	if ollama["API key"] != "OLLAMA_API_KEY (not required)" {
+		t.Errorf("Unexpected ollama key status: %q", ollama["API key"])
+	}
+
+	cfg.PinnedModels = map[string]map[string]string{"claude": {"opus": "claude-opus-4-1-20250805"}}
+	_, claude := backendSettings(cfg, backends["claude"])
+	if claude["ANTHROPIC_DEFAULT_OPUS_MODEL"] != "claude-opus-4-1-20250805" {
+		t.Errorf("Expected pinned opus model, got %q", claude["ANTHROPIC_DEFAULT_OPUS_MODEL"])
+	}
+	if claude["ANTHROPIC_DEFAULT_HAIKU_MODEL"] != "(Claude Code default)" || claude["API_TIMEOUT_MS"] != "(Claude Code default)" {
+		t.Errorf("Expected Claude Code defaults for unset variables, got %q / %q",
+			claude["ANTHROPIC_DEFAULT_HAIKU_MODEL"], claude["API_TIMEOUT_MS"])
+	}
+}
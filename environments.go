@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// activeEnv is the named environment selected with --env or NEXUS_ENV.
+// Empty means the default environment (.env.local and unsuffixed ledgers).
+var activeEnv string
+
+var envNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// validateEnvName checks that an environment name is safe to embed in file names
+func validateEnvName(name string) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid environment name '%s' (use lowercase letters, digits, '-' or '_')", name)
+	}
+	return nil
+}
+
+// selectEnvironment makes name, or NEXUS_ENV when name is empty, the
+// active environment
+func selectEnvironment(name string) error {
+	if name == "" {
+		name = os.Getenv("NEXUS_ENV")
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "default" {
+		activeEnv = ""
+		return nil
+	}
+	if err := validateEnvName(name); err != nil {
+		return err
+	}
+	activeEnv = name
+	return nil
+}
+
+// envScopedName inserts the environment name before a file extension, e.g.
+// ".promptops-usage.jsonl" becomes ".promptops-usage.prod.jsonl". Names
+// without one, including dotfiles like ".promptops-auto", get it appended.
+func envScopedName(base, env string) string {
+	if env == "" {
+		return base
+	}
+	ext := filepath.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+	return strings.TrimSuffix(base, ext) + "." + env + ext
+}
+
+// envFileName returns the env file holding keys and budgets for an environment
+func envFileName(env string) string {
+	if env == "" {
+		return ".env.local"
+	}
+	return ".env." + env + ".local"
+}
+
+// listEnvironments returns the named environments that have an env file in dir
+func listEnvironments(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, ".env.*.local"))
+	var names []string
+	for _, m := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), ".env."), ".local")
+		if validateEnvName(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelectEnvironment(t *testing.T) {
+	defer func() { activeEnv = "" }()
+	t.Setenv("NEXUS_ENV", "")
+
+	if err := selectEnvironment("../etc"); err == nil {
+		t.Error("Expected error for unsafe environment name")
+	}
+
+	if err := selectEnvironment("Prod"); err != nil {
+		t.Fatalf("selectEnvironment failed: %v", err)
+	}
+	if activeEnv != "prod" {
+		t.Errorf("activeEnv = %q", activeEnv)
+	}
+
+	// NEXUS_ENV applies when no flag is given
+	t.Setenv("NEXUS_ENV", "staging")
+	if err := selectEnvironment(""); err != nil {
+		t.Fatalf("selectEnvironment failed: %v", err)
+	}
+	if activeEnv != "staging" {
+		t.Errorf("Expected staging from NEXUS_ENV, got %q", activeEnv)
+	}
+
+	// "default" selects the unnamed environment
+	if err := selectEnvironment("default"); err != nil {
+		t.Fatalf("selectEnvironment failed: %v", err)
+	}
+	if activeEnv != "" {
+		t.Errorf("Expected default environment, got %q", activeEnv)
+	}
+}
+
+func TestEnvScopedName(t *testing.T) {
+	tests := []struct {
+		base, env, expected string
+	}{
+		{".promptops-usage.jsonl", "", ".promptops-usage.jsonl"},
+		{".promptops-usage.jsonl", "prod", ".promptops-usage.prod.jsonl"},
+		{".promptops-audit.log", "dev", ".promptops-audit.dev.log"},
+		{"session", "prod", "session.prod"},
+		{".promptops-auto", "prod", ".promptops-auto.prod"},
+	}
+	for _, tt := range tests {
+		if got := envScopedName(tt.base, tt.env); got != tt.expected {
+			t.Errorf("envScopedName(%q, %q) = %q, want %q", tt.base, tt.env, got, tt.expected)
+		}
+	}
+	if envFileName("") != ".env.local" || envFileName("prod") != ".env.prod.local" {
+		t.Error("Unexpected env file names")
+	}
+}
+
+func TestLoadConfigNamedEnvironment(t *testing.T) {
+	defer func() { activeEnv = "" }()
+	activeEnv = "prod"
+
+	cfg := loadConfig()
+	if cfg.Environment != "prod" {
+		t.Errorf("Environment = %q, want prod", cfg.Environment)
+	}
+	if filepath.Base(cfg.EnvFile) != ".env.prod.local" {
+		t.Errorf("EnvFile = %s", cfg.EnvFile)
+	}
+	for _, path := range []string{cfg.UsageFile, cfg.AuditLog, cfg.SessionsFile, cfg.PromptIndexFile} {
+		if !strings.Contains(filepath.Base(path), ".prod.") {
+			t.Errorf("Expected environment-scoped path, got %s", path)
+		}
+	}
+	for _, path := range []string{cfg.StateFile, cfg.AutoChoiceFile, cfg.PromptAnswerDir} {
+		if !strings.HasSuffix(path, ".prod") {
+			t.Errorf("Expected environment-scoped path, got %s", path)
+		}
+	}
+}
+
+func TestListEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{".env.local", ".env.prod.local", ".env.dev.local", ".env.Bad Name.local"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0600)
+	}
+	envs := listEnvironments(dir)
+	if strings.Join(envs, ",") != "dev,prod" {
+		t.Errorf("listEnvironments = %v, want [dev prod]", envs)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreHeader introduces the entries init adds to .gitignore
+const gitignoreHeader = "# PromptOps keys, usage, audit and session files"
+
+// secretIgnorePatterns match the files promptops keeps next to its env file:
+// keys, usage and audit logs, sessions, service logs and atomic-write temps.
+// The committed .promptops/ directory (context primer) is not matched.
+var secretIgnorePatterns = []string{".env.local", ".env.*.local", ".promptops-*", "state", "state.*", "session", "session.*", ".tmp-*"}
+
+// gitignoreEntries anchors secretIgnorePatterns to dir, relative to the
+// .gitignore in root
+func gitignoreEntries(root, dir string) []string {
+	prefix := "/"
+	if rel, err := filepath.Rel(root, dir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		prefix += filepath.ToSlash(rel) + "/"
+	}
+	entries := make([]string, len(secretIgnorePatterns))
+	for i, p := range secretIgnorePatterns {
+		entries[i] = prefix + p
+	}
+	return entries
+}
+
+// missingIgnoreEntries returns the entries that are not already lines of the
+// .gitignore content
+func missingIgnoreEntries(content string, entries []string) []string {
+	present := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		present[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, e := range entries {
+		if !present[e] {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
+
+// ensureGitignore creates root/.gitignore or appends the missing entries for
+// dir, returning what was added
+func ensureGitignore(root, dir string) ([]string, error) {
+	path := filepath.Join(root, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	content := string(data)
+	missing := missingIgnoreEntries(content, gitignoreEntries(root, dir))
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if content != "" {
+		content += "\n"
+	}
+	content += gitignoreHeader + "\n" + strings.Join(missing, "\n") + "\n"
+	if err := writeFileAtomic(path, []byte(content), 0644); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// trackedByGit reports whether path is tracked in the repository at root
+func trackedByGit(root, path string) bool {
+	return runGit(root, "ls-files", "--", path) != ""
+}
+
+// bootstrapSecretHygiene keeps the env file and local state out of git: it
+// adds .gitignore entries and, when the env file is already tracked, offers
+// to untrack it. Anything other than an explicit yes leaves it tracked.
+func bootstrapSecretHygiene(dir, envFile string, reader *bufio.Reader, out io.Writer) {
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+		envFile = filepath.Join(dir, filepath.Base(envFile))
+	}
+	root := projectRoot(dir)
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	added, err := ensureGitignore(root, dir)
+	switch {
+	case err != nil:
+		fmt.Fprintf(out, "Warning: failed to update %s: %v\n", filepath.Join(root, ".gitignore"), err)
+	case len(added) > 0:
+		fmt.Fprintf(out, "[OK] Added %d entries to %s\n", len(added), filepath.Join(root, ".gitignore"))
+	}
+
+	if !trackedByGit(root, envFile) {
+		return
+	}
+	name := filepath.Base(envFile)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, styleWarning.Render(fmt.Sprintf("WARNING: %s is tracked by git, so its API keys are shared with the repository", name)))
+	fmt.Fprint(out, "Stop tracking it (git rm --cached, the file stays on disk)? [y/N]: ")
+	answer, err := readLine(reader)
+	if err != nil {
+		fmt.Fprintln(out)
+	}
+	answer = strings.ToLower(answer)
+	if answer != "y" && answer != "yes" {
+		fmt.Fprintf(out, "%s is still tracked. Untrack it with: git rm --cached %s\n", name, envFile)
+		return
+	}
+	runGit(root, "rm", "--cached", "--quiet", "--", envFile)
+	if trackedByGit(root, envFile) {
+		fmt.Fprintf(out, "Error: failed to untrack %s; run: git rm --cached %s\n", name, envFile)
+		return
+	}
+	fmt.Fprintf(out, "[OK] %s is no longer tracked; commit the change\n", name)
+	fmt.Fprintln(out, styleWarning.Render("Keys committed earlier remain in git history - rotate them with each provider"))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGitignoreEntries(t *testing.T) {
+	if got := gitignoreEntries("/repo", "/repo"); got[0] != "/.env.local" || got[2] != "/.promptops-*" {
+		t.Errorf("Unexpected root entries %v", got)
+	}
+	if got := gitignoreEntries("/repo", "/repo/tools/promptops"); got[0] != "/tools/promptops/.env.local" {
+		t.Errorf("Unexpected nested entries %v", got)
+	}
+}
+
+func TestEnsureGitignore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".gitignore")
+	if err := os.WriteFile(path, []byte("node_modules\n/.env.local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := ensureGitignore(dir, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != len(secretIgnorePatterns)-1 || added[0] != "/.env.*.local" {
+		t.Errorf("Unexpected entries added: %v", added)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "node_modules\n/.env.local\n\n"+gitignoreHeader+"\n") {
+		t.Errorf("Unexpected .gitignore:\n%s", data)
+	}
+
+	// A second run adds nothing
+	if added, err := ensureGitignore(dir, dir); err != nil || added != nil {
+		t.Errorf("Expected no changes, got %v (err=%v)", added, err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0644 {
+		t.Errorf("Expected 0644, got %v", info.Mode().Perm())
+	}
+}
+
+func TestBootstrapSecretHygiene(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo := t.TempDir()
+	envFile := filepath.Join(repo, ".env.local")
+	if err := os.WriteFile(envFile, []byte("ANTHROPIC_API_KEY=\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q", repo},
+		{"-C", repo, "add", ".env.local"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Skipf("git setup failed: %v: %s", err, out)
+		}
+	}
+
+	// Declining leaves the file tracked
+	var out bytes.Buffer
+	bootstrapSecretHygiene(repo, envFile, bufio.NewReader(strings.NewReader("\n")), &out)
+	if !strings.Contains(out.String(), "is tracked by git") || !trackedByGit(repo, envFile) {
+		t.Errorf("Expected tracked warning, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	bootstrapSecretHygiene(repo, envFile, bufio.NewReader(strings.NewReader("y\n")), &out)
+	if trackedByGit(repo, envFile) {
+		t.Errorf("Expected .env.local to be untracked, got:\n%s", out.String())
+	}
+	if _, err := os.Stat(envFile); err != nil {
+		t.Errorf("Expected .env.local to stay on disk: %v", err)
+	}
+	if !strings.Contains(out.String(), "rotate") {
+		t.Errorf("Expected rotation advice, got:\n%s", out.String())
+	}
+	if ignored, _ := exec.Command("git", "-C", repo, "check-ignore", ".env.local", ".promptops-usage.jsonl", "session.prod").Output(); !reflect.DeepEqual(strings.Fields(string(ignored)), []string{".env.local", ".promptops-usage.jsonl", "session.prod"}) {
+		t.Errorf("Expected files to be ignored, got %q", ignored)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const gitCommandTimeout = 2 * time.Second
+
+// GitInfo identifies the git checkout a session was launched from
+type GitInfo struct {
+	Repo   string // Name of the repository top-level directory
+	Branch string
+	Commit string // Abbreviated HEAD commit
+}
+
+var (
+	launchGitOnce sync.Once
+	launchGit     GitInfo
+)
+
+// runGit runs a git command in dir and returns its trimmed output
+func runGit(dir string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), gitCommandTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// detectGitInfo returns repository details for dir, or an empty GitInfo when
+// dir is not inside a git repository or git is unavailable
+func detectGitInfo(dir string) GitInfo {
+	top := runGit(dir, "rev-parse", "--show-toplevel")
+	if top == "" {
+		return GitInfo{}
+	}
+	info := GitInfo{
+		Repo:   filepath.Base(top),
+		Branch: runGit(dir, "rev-parse", "--abbrev-ref", "HEAD"),
+		Commit: runGit(dir, "rev-parse", "--short", "HEAD"),
+	}
+	if info.Branch == "HEAD" {
+		info.Branch = "detached"
+	}
+	return info
+}
+
+// currentGitInfo returns git details for the working directory, detected once
+// per process so usage logging does not shell out on every request
+func currentGitInfo() GitInfo {
+	launchGitOnce.Do(func() {
+		if wd, err := os.Getwd(); err == nil {
+			launchGit = detectGitInfo(wd)
+		}
+	})
+	return launchGit
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectGitInfo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	if info := detectGitInfo(t.TempDir()); info != (GitInfo{}) {
+		t.Errorf("Expected empty info outside a repository, got %+v", info)
+	}
+
+	repo := filepath.Join(t.TempDir(), "webapp")
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "feature-x", repo},
+		{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Skipf("git setup failed: %v: %s", err, out)
+		}
+	}
+
+	info := detectGitInfo(repo)
+	if info.Repo != "webapp" {
+		t.Errorf("Repo = %q, want webapp", info.Repo)
+	}
+	if info.Branch != "feature-x" {
+		t.Errorf("Branch = %q, want feature-x", info.Branch)
+	}
+	if len(info.Commit) < 7 {
+		t.Errorf("Expected abbreviated commit, got %q", info.Commit)
+	}
+
+	// Subdirectories resolve to the same repository
+	if sub := detectGitInfo(repo + "/."); sub.Repo != "webapp" {
+		t.Errorf("Expected webapp from subdirectory, got %q", sub.Repo)
+	}
+}
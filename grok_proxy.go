@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,6 +20,8 @@ import (
 // Request patches:
 //   - Adds "required":[] to object schemas missing it (xAI strict validation)
 //   - Rewrites "additionalProperties":{} to false
+//   - Adds the project's context primer as a system block, when set
+//   - Caps max_tokens and sets default temperature and top_p, when set
 //
 // Response patches:
 //   - Strips "thinking" content blocks from streaming SSE responses
@@ -28,12 +32,91 @@ type GrokProxy struct {
 	targetBaseURL string
 	apiKey        string
 	server        *http.Server
+	systemPrimer  string        // Optional project context added to message requests
+	backendPrompt BackendPrompt // Optional system prefix/suffix for xAI models
+	chaos         ChaosConfig   // Optional synthetic upstream degradation
+	observeUsage  func(model string, usage AnthropicUsage)
+	auth          AuthStrategy // How forwarded requests authenticate to xAI
+	cooldownFile  string       // Optional rate-limit cool-downs shared across instances
+	latencyBudget time.Duration
+	onTimeout     func(model string, elapsed time.Duration)
+	sampling      SamplingDefaults // Optional max_tokens cap and temperature/top_p defaults
+	samplingMu    sync.RWMutex     // Guards sampling, which a config reload replaces
+	samplingWarn  sync.Once
 }
 
 func NewGrokProxy(targetBaseURL, apiKey string) *GrokProxy {
 	return &GrokProxy{
 		targetBaseURL: targetBaseURL,
 		apiKey:        apiKey,
+		auth:          backends["grok"].Auth,
+		latencyBudget: defaultLatencyBudget,
+	}
+}
+
+// SetSampling caps max_tokens and sets default temperature and top_p for
+// message requests
+func (p *GrokProxy) SetSampling(s SamplingDefaults) {
+	p.samplingMu.Lock()
+	defer p.samplingMu.Unlock()
+	p.sampling = s
+}
+
+// SetAuth replaces how forwarded requests authenticate (NEXUS_AUTH_GROK)
+func (p *GrokProxy) SetAuth(auth AuthStrategy) {
+	p.auth = auth
+}
+
+// SetSystemPrimer adds text as a trailing system block of every message request
+func (p *GrokProxy) SetSystemPrimer(text string) {
+	p.systemPrimer = text
+}
+
+// SetBackendPrompt wraps the system prompt of every message request in the
+// configured prefix and suffix
+func (p *GrokProxy) SetBackendPrompt(bp BackendPrompt) {
+	p.backendPrompt = bp
+}
+
+// SetUsageObserver registers a callback invoked with token usage after each
+// completed message response
+func (p *GrokProxy) SetUsageObserver(observe func(model string, usage AnthropicUsage)) {
+	p.observeUsage = observe
+}
+
+// SetCooldownFile shares xAI rate limits with other promptops instances
+// through path
+func (p *GrokProxy) SetCooldownFile(path string) {
+	p.cooldownFile = path
+}
+
+// SetLatencyBudget bounds how long xAI may take to start answering and to
+// pause within an answer
+func (p *GrokProxy) SetLatencyBudget(budget time.Duration) {
+	p.latencyBudget = budget
+}
+
+// SetTimeoutObserver registers a callback invoked when a request exceeded
+// the latency budget
+func (p *GrokProxy) SetTimeoutObserver(observe func(model string, elapsed time.Duration)) {
+	p.onTimeout = observe
+}
+
+// timedOut answers a request that exceeded the latency budget, as an error
+// event once streaming has begun
+func (p *GrokProxy) timedOut(w http.ResponseWriter, body []byte, start time.Time, streaming bool) {
+	pe := latencyBudgetError(backends["grok"], p.latencyBudget)
+	if streaming {
+		writeStreamTimeout(w, pe)
+	} else {
+		writeTimeoutError(w, pe)
+	}
+	if p.onTimeout != nil {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.Unmarshal(body, &req)
+		p.onTimeout(req.Model, time.Since(start))
 	}
 }
 
@@ -42,21 +125,14 @@ func (p *GrokProxy) Start(port int) error {
 	mux.HandleFunc("/", p.handle)
 
 	p.server = &http.Server{
-		Addr:         fmt.Sprintf("localhost:%d", port),
+		Addr:         proxyAddr(port),
 		Handler:      mux,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 0, // no timeout for streaming
 		IdleTimeout:  120 * time.Second,
 	}
 
-	go func() {
-		if err := p.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			fmt.Fprintf(os.Stderr, "Grok proxy error: %v\n", err)
-		}
-	}()
-
-	time.Sleep(100 * time.Millisecond)
-	return nil
+	return serveProxy(p.server, mux, port, "Grok proxy")
 }
 
 func (p *GrokProxy) Stop() error {
@@ -76,6 +152,27 @@ func (p *GrokProxy) handle(w http.ResponseWriter, r *http.Request) {
 	// Patch the request body to fix tool schemas
 	if r.Method == http.MethodPost && len(body) > 0 {
 		body = patchToolSchemas(body)
+		if strings.HasPrefix(r.URL.Path, "/v1/messages") {
+			body = injectSystemBlock(body, p.systemPrimer)
+			body = injectSystemBlocks(body, p.backendPrompt.Prefix, p.backendPrompt.Suffix)
+			p.samplingMu.RLock()
+			sampling := p.sampling
+			p.samplingMu.RUnlock()
+			var lowered int
+			if body, lowered = sampling.ApplyJSON(body); lowered > 0 {
+				p.samplingWarn.Do(func() {
+					fmt.Fprintln(os.Stderr, styleWarning.Render(fmt.Sprintf("[promptops] Lowering max_tokens from %d to the %d-token cap for Grok", lowered, sampling.MaxTokens)))
+				})
+			}
+		}
+	}
+
+	// Message requests wait out a rate limit another instance ran into
+	if r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/messages") {
+		if left, ok := activeCooldown(p.cooldownFile, "grok", time.Now()); ok {
+			writeCooldownError(w, cooldownError(backends["grok"], left))
+			return
+		}
 	}
 
 	// Forward to xAI
@@ -84,7 +181,9 @@ func (p *GrokProxy) handle(w http.ResponseWriter, r *http.Request) {
 		url += "?" + r.URL.RawQuery
 	}
 
-	req, err := http.NewRequest(r.Method, url, bytes.NewReader(body))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, bytes.NewReader(body))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -101,24 +200,39 @@ func (p *GrokProxy) handle(w http.ResponseWriter, r *http.Request) {
 			req.Header.Add(key, value)
 		}
 	}
-	req.Header.Set("X-Api-Key", p.apiKey)
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	req.ContentLength = int64(len(body))
+	if err := p.auth.Apply(req, p.apiKey); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	client := &http.Client{
 		Timeout: 0, // no timeout for streaming
-		Transport: &http.Transport{
-			TLSClientConfig:   &tls.Config{MinVersion: tls.VersionTLS12},
-			DisableCompression: true,
-		},
+		Transport: wrapChaos(&http.Transport{
+			TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+			DisableCompression:    true,
+			ResponseHeaderTimeout: p.latencyBudget,
+		}, p.chaos),
 	}
 
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
+		if isTimeout(err) {
+			p.timedOut(w, body, start, false)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
 	defer resp.Body.Close()
+	upstream := newStallReader(resp.Body, p.latencyBudget, cancel)
+	isSSE := strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
+	defer func() {
+		if upstream.Stop() {
+			p.timedOut(w, body, start, isSSE)
+		}
+	}()
 
 	// Copy response headers
 	for key, values := range resp.Header {
@@ -128,27 +242,39 @@ func (p *GrokProxy) handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ct := resp.Header.Get("Content-Type")
-	isSSE := strings.Contains(ct, "text/event-stream")
 
 	if isSSE {
 		// Streaming: filter out thinking blocks from SSE events
 		w.WriteHeader(resp.StatusCode)
-		p.filterSSEThinking(w, resp.Body)
+		p.filterSSEThinking(w, upstream)
 	} else if resp.StatusCode == http.StatusOK && strings.Contains(ct, "application/json") {
 		// Non-streaming JSON: strip thinking from content array
-		respBody, err := io.ReadAll(resp.Body)
+		respBody, err := io.ReadAll(upstream)
 		if err != nil {
+			if upstream.Stop() {
+				return
+			}
 			w.WriteHeader(resp.StatusCode)
 			return
 		}
+		p.observeJSONUsage(respBody)
 		respBody = stripThinkingFromJSON(respBody)
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(respBody)))
 		w.WriteHeader(resp.StatusCode)
 		w.Write(respBody)
 	} else {
-		// Pass through as-is (errors, other content types)
+		// Pass through as-is (errors, other content types), explaining errors in the terminal
+		if resp.StatusCode >= 400 {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+			pe := classifyProviderError(backends["grok"], resp.StatusCode, resp.Header, body)
+			fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] "+pe.Message))
+			noteRateLimit(p.cooldownFile, pe)
+			w.WriteHeader(resp.StatusCode)
+			w.Write(body)
+			return
+		}
 		w.WriteHeader(resp.StatusCode)
-		io.Copy(w, resp.Body)
+		io.Copy(w, upstream)
 	}
 }
 
@@ -161,6 +287,13 @@ func (p *GrokProxy) filterSSEThinking(w http.ResponseWriter, body io.Reader) {
 
 	inThinkingBlock := false
 	var eventLines []string
+	var model string
+	var usage AnthropicUsage
+	defer func() {
+		if p.observeUsage != nil && usage.InputTokens+usage.OutputTokens > 0 {
+			p.observeUsage(model, usage)
+		}
+	}()
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -191,6 +324,26 @@ func (p *GrokProxy) filterSSEThinking(w http.ResponseWriter, body io.Reader) {
 			eventType, _ := event["type"].(string)
 
 			switch eventType {
+			case "message_start":
+				// Input tokens arrive with the message, output tokens with
+				// the final message_delta
+				var start struct {
+					Message struct {
+						Model string         `json:"model"`
+						Usage AnthropicUsage `json:"usage"`
+					} `json:"message"`
+				}
+				if json.Unmarshal([]byte(data), &start) == nil {
+					model = start.Message.Model
+					usage.InputTokens = start.Message.Usage.InputTokens
+				}
+			case "message_delta":
+				var delta struct {
+					Usage AnthropicUsage `json:"usage"`
+				}
+				if json.Unmarshal([]byte(data), &delta) == nil && delta.Usage.OutputTokens > 0 {
+					usage.OutputTokens = delta.Usage.OutputTokens
+				}
 			case "content_block_start":
 				if cb, ok := event["content_block"].(map[string]interface{}); ok {
 					if cbType, _ := cb["type"].(string); cbType == "thinking" {
@@ -235,6 +388,20 @@ func (p *GrokProxy) filterSSEThinking(w http.ResponseWriter, body io.Reader) {
 	}
 }
 
+// observeJSONUsage reports the usage of a non-streaming message response
+func (p *GrokProxy) observeJSONUsage(body []byte) {
+	if p.observeUsage == nil {
+		return
+	}
+	var resp struct {
+		Model string         `json:"model"`
+		Usage AnthropicUsage `json:"usage"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.Usage.InputTokens+resp.Usage.OutputTokens > 0 {
+		p.observeUsage(resp.Model, resp.Usage)
+	}
+}
+
 // stripThinkingFromJSON removes thinking content blocks from a non-streaming
 // Anthropic API response.
 func stripThinkingFromJSON(body []byte) []byte {
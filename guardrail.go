@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Default opus-tier output price (USD per 1M tokens) above which a launch
+// is considered expensive
+const defaultExpensiveThreshold = 10.00
+
+// Token volume of a typical Claude Code session, used to project launch cost.
+// Claude Code resends context on every turn, so input dominates.
+const (
+	typicalSessionInputTokens  = 2000000
+	typicalSessionOutputTokens = 100000
+)
+
+// opusTierPricing returns the effective opus-tier input/output price for a
+// backend. A configured opus model is priced at its configured tier: the
+// opus price, unless it is the backend's own haiku or sonnet model, which
+// are billed at the base price.
+func opusTierPricing(cfg *Config, be Backend) (inputPrice, outputPrice float64) {
+	if be.OpusOutputPrice <= 0 {
+		return be.InputPrice, be.OutputPrice
+	}
+	if _, _, m := resolveTierModels(cfg, be); m != "" && (m == be.HaikuModel || m == be.SonnetModel) {
+		return be.InputPrice, be.OutputPrice
+	}
+	return be.OpusInputPrice, be.OpusOutputPrice
+}
+
+// projectedSessionCost estimates the cost of a typical session at the given prices
+func projectedSessionCost(inputPrice, outputPrice float64) float64 {
+	return float64(typicalSessionInputTokens)*inputPrice/1000000 +
+		float64(typicalSessionOutputTokens)*outputPrice/1000000
+}
+
+// isExpensiveLaunch reports whether the backend's opus tier exceeds the configured threshold
+func isExpensiveLaunch(cfg *Config, be Backend) bool {
+	_, outputPrice := opusTierPricing(cfg, be)
+	return outputPrice > cfg.ExpensiveThreshold
+}
+
+// confirmExpensiveLaunch shows the opus-tier pricing and projected session cost
+// and asks the user to confirm. Anything other than an explicit yes declines.
+func confirmExpensiveLaunch(cfg *Config, be Backend, reader *bufio.Reader) bool {
+	inputPrice, outputPrice := opusTierPricing(cfg, be)
+	_, _, opusModel := resolveTierModels(cfg, be)
+	if opusModel == "" {
+		opusModel = "default opus model"
+	}
+
+	fmt.Println()
+	fmt.Println(styleWarning.Render("WARNING: Expensive opus-tier pricing"))
+	fmt.Printf("  Backend:   %s (%s)\n", be.DisplayName, opusModel)
+	fmt.Printf("  Pricing:   $%.2f/$%.2f per 1M tokens (threshold $%.2f output)\n",
+		inputPrice, outputPrice, cfg.ExpensiveThreshold)
+	fmt.Printf("  Projected: %s for a typical session (%s in / %s out)\n",
+		formatCurrency(projectedSessionCost(inputPrice, outputPrice)),
+		formatNumber(typicalSessionInputTokens), formatNumber(typicalSessionOutputTokens))
+	fmt.Print("Launch anyway? [y/N]: ")
+
+	answer, err := readLine(reader)
+	if err != nil {
+		fmt.Println()
+		return false
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
+
+// stripLeadingFlag removes flag from the promptops options that lead launch
+// arguments (flag itself and --for) and reports whether it was present.
+// Everything from the first Claude Code argument on is passed through, so a
+// prompt or option value that happens to equal flag is left alone.
+func stripLeadingFlag(args []string, flag string) ([]string, bool) {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == flag:
+			return append(args[:i:i], args[i+1:]...), true
+		case args[i] == "--for":
+			i++
+		case strings.HasPrefix(args[i], "--for="):
+		default:
+			return args, false
+		}
+	}
+	return args, false
+}
+
+// stripFlag removes every occurrence of flag from args and reports whether it was present
+func stripFlag(args []string, flag string) ([]string, bool) {
+	var kept []string
+	found := false
+	for _, arg := range args {
+		if arg == flag {
+			found = true
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	return kept, found
+}
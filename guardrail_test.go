@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestOpusTierPricing(t *testing.T) {
+	cfg := &Config{ExpensiveThreshold: defaultExpensiveThreshold}
+
+	in, out := opusTierPricing(cfg, backends["claude"])
+	if in != 15.00 || out != 75.00 {
+		t.Errorf("claude opus pricing = $%.2f/$%.2f, want $15.00/$75.00", in, out)
+	}
+
+	// Backends without opus pricing fall back to base pricing
+	in, out = opusTierPricing(cfg, backends["deepseek"])
+	if in != backends["deepseek"].InputPrice || out != backends["deepseek"].OutputPrice {
+		t.Errorf("deepseek opus pricing = $%.2f/$%.2f, want base pricing", in, out)
+	}
+
+	// A custom opus model on a backend without opus pricing stays at base pricing
+	cfg.GrokModels = map[string]string{"opus": "grok-custom"}
+	_, out = opusTierPricing(cfg, backends["grok"])
+	if out != backends["grok"].OutputPrice {
+		t.Errorf("grok custom opus output = $%.2f, want $%.2f", out, backends["grok"].OutputPrice)
+	}
+
+	// A custom opus model is priced at the opus tier it is configured for,
+	// unless it is one of the backend's cheaper tier models
+	for model, want := range map[string]float64{"o3": 60.00, "gpt-4o": 10.00, "gpt-4o-mini": 10.00} {
+		cfg.PinnedModels = map[string]map[string]string{"openai": {"opus": model}}
+		if _, out := opusTierPricing(cfg, backends["openai"]); out != want {
+			t.Errorf("openai opus %s output = $%.2f, want $%.2f", model, out, want)
+		}
+	}
+}
+
+func TestIsExpensiveLaunch(t *testing.T) {
+	cfg := &Config{ExpensiveThreshold: defaultExpensiveThreshold}
+
+	tests := []struct {
+		backend  string
+		expected bool
+	}{
+		{"claude", true},
+		{"openai", true},
+		{"openrouter", true},
+		{"deepseek", false},
+		{"ollama", false},
+		{"gemini", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			if got := isExpensiveLaunch(cfg, backends[tt.backend]); got != tt.expected {
+				t.Errorf("isExpensiveLaunch(%s) = %v, want %v", tt.backend, got, tt.expected)
+			}
+		})
+	}
+
+	cfg.ExpensiveThreshold = 100
+	if isExpensiveLaunch(cfg, backends["claude"]) {
+		t.Error("claude should not be expensive with a $100 threshold")
+	}
+}
+
+func TestProjectedSessionCost(t *testing.T) {
+	// 2M input at $15 + 100K output at $75
+	expected := 30.0 + 7.5
+	if got := projectedSessionCost(15.00, 75.00); got != expected {
+		t.Errorf("projectedSessionCost = %.2f, want %.2f", got, expected)
+	}
+	if got := projectedSessionCost(0, 0); got != 0 {
+		t.Errorf("projectedSessionCost(0, 0) = %.2f, want 0", got)
+	}
+}
+
+func TestConfirmExpensiveLaunch(t *testing.T) {
+	cfg := &Config{ExpensiveThreshold: defaultExpensiveThreshold}
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false}, // EOF declines
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.input), func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.input))
+			if got := confirmExpensiveLaunch(cfg, backends["claude"], reader); got != tt.expected {
+				t.Errorf("confirmExpensiveLaunch(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStripLeadingFlag(t *testing.T) {
+	tests := []struct {
+		args  []string
+		rest  string
+		found bool
+	}{
+		{[]string{"--confirm-expensive", "-p", "hello"}, "-p hello", true},
+		{[]string{"--for", "2h", "--confirm-expensive", "-c"}, "--for 2h -c", true},
+		{[]string{"--for=2h", "--confirm-expensive"}, "--for=2h", true},
+		// A prompt or option value equal to the flag goes to Claude Code
+		{[]string{"-p", "--confirm-expensive"}, "-p --confirm-expensive", false},
+		{[]string{"--model", "o1", "--confirm-expensive"}, "--model o1 --confirm-expensive", false},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		rest, found := stripLeadingFlag(tt.args, "--confirm-expensive")
+		if strings.Join(rest, " ") != tt.rest || found != tt.found {
+			t.Errorf("stripLeadingFlag(%v) = %v, %v", tt.args, rest, found)
+		}
+	}
+}
+
+func TestStripFlag(t *testing.T) {
+	args, found := stripFlag([]string{"--confirm-expensive", "-p", "hello"}, "--confirm-expensive")
+	if !found {
+		t.Error("expected flag to be found")
+	}
+	if len(args) != 2 || args[0] != "-p" || args[1] != "hello" {
+		t.Errorf("unexpected remaining args: %v", args)
+	}
+
+	args, found = stripFlag([]string{"-p"}, "--confirm-expensive")
+	if found || len(args) != 1 {
+		t.Errorf("stripFlag without flag = %v, %v", args, found)
+	}
+}
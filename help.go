@@ -0,0 +1,691 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// helpPage is the detailed help for one command or group of related commands
+type helpPage struct {
+	Group    string
+	Commands []string // commands the page covers; the first names the page
+	Summary  string
+	Usage    []string
+	Examples []helpExample
+}
+
+// helpExample is an example command. Examples naming a backend are only shown
+// when that backend is configured.
+type helpExample struct {
+	Backend string
+	Line    string
+}
+
+// helpGroups is the order command groups are listed in the help overview
+var helpGroups = []string{"Cost and Budgets", "Environment Validation", "Sessions and Agents", "Configuration", "General"}
+
+var helpPages = []helpPage{
+	{
+		Group:    "Cost and Budgets",
+		Commands: []string{"cost"},
+		Summary:  "Show the cost dashboard, usage log and per-repo costs",
+		Usage: []string{
+			"cost                    Show cost dashboard with budgets",
+			"cost report --by-repo   Show cost per git repository and branch",
+			"cost report --group-by tag:<name>  Chargeback totals per cost tag (also backend, repo)",
+			"  --from/--to YYYY-MM-DD Limit the period (--to includes that day)",
+			"  --csv                 Print CSV for finance instead of a table",
+			"cost tags               Show the cost tags this workspace stamps on usage",
+			"cost log                Show detailed usage log",
+			"  --wide                Keep every column and full text (any cost table)",
+			"cost push               Export daily and total cost gauges per backend",
+			"  --prometheus-gateway  Pushgateway URL (job 'promptops', --job to change)",
+			"  --statsd host:port    Send statsd gauges over UDP",
+			"  --dry-run             Print the gauges instead of sending them",
+		},
+		Examples: []helpExample{
+			{Line: "promptops cost report --by-repo"},
+			{Line: "promptops cost report --group-by tag:team --from 2026-09-01 --to 2026-09-30 --csv"},
+			{Line: "promptops cost push --prometheus-gateway http://localhost:9091"},
+		},
+	},
+	{
+		Group:    "Cost and Budgets",
+		Commands: []string{"usage"},
+		Summary:  "Fetch usage from provider APIs",
+		Usage: []string{
+			"usage                   Show usage data from all provider APIs",
+			"usage <backend>         Show usage for specific backend",
+			"  --from/--to YYYY-MM-DD Limit the period (--to includes that day)",
+			"  --days N              Cover today and the N-1 days before",
+			"  --timeout <duration>  Usage API timeout (e.g. 30s)",
+		},
+		Examples: []helpExample{
+			{Line: "promptops usage --days 7"},
+			{Backend: "claude", Line: "promptops usage claude"},
+			{Backend: "deepseek", Line: "promptops usage deepseek --from 2026-10-01"},
+			{Backend: "openrouter", Line: "promptops usage openrouter"},
+		},
+	},
+	{
+		Group:    "Cost and Budgets",
+		Commands: []string{"budget"},
+		Summary:  "Show and set daily, weekly and monthly budgets",
+		Usage: []string{
+			"budget status           Show budget progress",
+			"budget set <period> <amount> [backend]  Set budget (daily/weekly/monthly)",
+		},
+		Examples: []helpExample{
+			{Line: "promptops budget set daily 5"},
+			{Backend: "claude", Line: "promptops budget set weekly 40 claude"},
+			{Backend: "openai", Line: "promptops budget set daily 3 openai"},
+		},
+	},
+	{
+		Group:    "Cost and Budgets",
+		Commands: []string{"credits"},
+		Summary:  "Track prepaid balances",
+		Usage: []string{
+			"credits                 Show remaining prepaid balance per backend",
+			"credits set <backend> <amount>  Record the current balance",
+			"credits add <backend> <amount>  Record a top-up or correction",
+			"credits clear <backend> Stop tracking a backend",
+		},
+		Examples: []helpExample{
+			{Backend: "deepseek", Line: "promptops credits set deepseek 20"},
+			{Backend: "openrouter", Line: "promptops credits add openrouter 10"},
+		},
+	},
+	{
+		Group:    "Environment Validation",
+		Commands: []string{"doctor"},
+		Summary:  "Health check every backend, model versions and data files",
+		Usage: []string{
+			"doctor                  Full health check of all backends",
+			"  --required <list>     Exit non-zero only if these backends fail (comma-separated)",
+			"  --timeout <duration>  Health check timeout (e.g. 15s)",
+			"  --wide                Keep each message on one line",
+			"doctor --onboarding     Readiness checklist for a new install with a score and next steps",
+		},
+		Examples: []helpExample{
+			{Line: "promptops doctor"},
+			{Line: "promptops doctor --onboarding"},
+			{Backend: "ollama", Line: "promptops doctor --required ollama"},
+			{Backend: "claude", Line: "promptops doctor --required claude --timeout 15s"},
+		},
+	},
+	{
+		Group:    "Environment Validation",
+		Commands: []string{"validate"},
+		Summary:  "Check connectivity of specific backends",
+		Usage: []string{
+			"validate <backend>...   Validate specific backend connectivity",
+			"  --required <list>     Exit non-zero only if these backends fail",
+			"  --timeout <duration>  Health check timeout (e.g. 15s)",
+		},
+		Examples: []helpExample{
+			{Backend: "claude", Line: "promptops validate claude"},
+			{Backend: "deepseek", Line: "promptops validate deepseek --timeout 15s"},
+			{Backend: "ollama", Line: "promptops validate ollama"},
+		},
+	},
+	{
+		Group:    "Environment Validation",
+		Commands: []string{"selftest"},
+		Summary:  "Run offline end-to-end checks against a mock backend",
+		Usage: []string{
+			"selftest                Run offline end-to-end checks (state, sessions, proxy, locking)",
+		},
+	},
+	{
+		Group:    "Environment Validation",
+		Commands: []string{"audit"},
+		Summary:  "Check signed usage and audit records for tampering",
+		Usage: []string{
+			"audit verify            Check the HMAC chains of the usage ledger and audit log",
+			"                        (records are signed with NEXUS_LEDGER_SIGNING=true)",
+		},
+	},
+	{
+		Group:    "Environment Validation",
+		Commands: []string{"gc"},
+		Summary:  "Remove temp files left by interrupted writes",
+		Usage: []string{
+			"gc                      Remove .tmp-<n> files older than an hour from the data directory",
+			"  --dry-run             List the files instead of removing them",
+		},
+	},
+	{
+		Group:    "Sessions and Agents",
+		Commands: []string{"session"},
+		Summary:  "Start, resume and inspect named sessions",
+		Usage: []string{
+			"session start <name>    Start a new named session",
+			"  --backend <name>      Backend the session works on (switched to now and on resume)",
+			"  --haiku/--sonnet/--opus <model>  Tier models used only while this session is current",
+			"  --no-backend-prompt   Skip the backend's system prompt prefix/suffix",
+			"session backend-prompt <on|off> [name]  Turn the prefix/suffix on or off for a session",
+			"session list            List all sessions (--wide keeps every column)",
+			"session resume <name>   Resume a previous session",
+			"session info [name]     Show session details",
+			"session stats [name]    Chart context size and cost per proxied turn",
+			"session close <name>    Close a session",
+			"session cleanup         Remove old closed sessions",
+		},
+		Examples: []helpExample{
+			{Line: "promptops session start bugfix-123"},
+			{Backend: "openai", Line: "promptops session start big-refactor --opus o1 --backend openai"},
+			{Line: "promptops session stats"},
+		},
+	},
+	{
+		Group:    "Sessions and Agents",
+		Commands: []string{"migrate-session"},
+		Summary:  "Continue a captured conversation on another backend (NEXUS_PROMPT_INDEX=true)",
+		Usage: []string{
+			"migrate-session <name> --to <backend>  Summarize the captured conversation locally into a new session",
+			"  --as <new-name>       Name of the new session (default <name>-<backend>)",
+		},
+		Examples: []helpExample{
+			{Backend: "deepseek", Line: "promptops migrate-session auth --to deepseek"},
+		},
+	},
+	{
+		Group:    "Sessions and Agents",
+		Commands: []string{"hooks"},
+		Summary:  "Gate prompts and tool calls on budgets and policy",
+		Usage: []string{
+			"hooks install [--user]  Gate every prompt and tool call on budgets and policy",
+			"                        (project .claude/settings.json, or ~/.claude with --user)",
+			"hooks uninstall [--user] Remove the PromptOps hooks",
+			"hooks status [--user]   Show whether hooks are installed and would block now",
+		},
+		Examples: []helpExample{
+			{Line: "promptops hooks install --user"},
+		},
+	},
+	{
+		Group:    "Sessions and Agents",
+		Commands: []string{"swarm"},
+		Summary:  "Run isolated proxies for parallel agents",
+		Usage: []string{
+			"swarm start -n <count>  Run isolated proxies on consecutive ports for parallel agents",
+			"  --backend <name>      Backend to proxy (ollama)",
+			"  --port <base>         First port (default 18100)",
+			"  --json                Print endpoints as JSON for orchestration tools",
+		},
+		Examples: []helpExample{
+			{Backend: "ollama", Line: "promptops swarm start -n 4 --backend ollama"},
+		},
+	},
+	{
+		Group:    "Sessions and Agents",
+		Commands: []string{"prompts"},
+		Summary:  "Check the index of past prompts for duplicates",
+		Usage: []string{
+			"prompts list            List recently indexed prompts",
+			"prompts check <text>    Check for a similar past prompt before sending",
+			"prompts clear           Remove indexed prompts and stored answers",
+		},
+	},
+	{
+		Group:    "Sessions and Agents",
+		Commands: []string{"debug"},
+		Summary:  "Show the proxy's recent decisions",
+		Usage: []string{
+			"debug last [n]          Show the proxy's last n decisions (model mapping, upstream, cost)",
+		},
+		Examples: []helpExample{
+			{Backend: "ollama", Line: "promptops debug last 20"},
+		},
+	},
+	{
+		Group:    "Sessions and Agents",
+		Commands: []string{"inspect-env"},
+		Summary:  "Show the environment and argv the last launch passed to Claude Code",
+		Usage: []string{
+			"inspect-env --last-launch  Print the argv and environment (secrets redacted)",
+			"  --json                Print the launch record as JSON",
+		},
+		Examples: []helpExample{
+			{Line: "promptops inspect-env --last-launch"},
+		},
+	},
+	{
+		Group:    "Configuration",
+		Commands: []string{"status", "current"},
+		Summary:  "Show the current backend, budgets and spend",
+		Usage: []string{
+			"status                  Show current backend and configuration",
+			"  --hours N             Window of the hourly spend sparkline (default 24)",
+			"  --details             Include provider region, retention and training terms",
+			"  --wide                Keep every column however narrow the terminal",
+		},
+		Examples: []helpExample{
+			{Line: "promptops status --hours 72"},
+		},
+	},
+	{
+		Group:    "Configuration",
+		Commands: []string{"which"},
+		Summary:  "Show the endpoint, models and API versions a launch uses",
+		Usage: []string{
+			"which [backend]         Show endpoint, tier models, capabilities and API versions",
+		},
+		Examples: []helpExample{
+			{Line: "promptops which"},
+			{Backend: "deepseek", Line: "promptops which deepseek"},
+			{Backend: "gemini", Line: "promptops which gemini"},
+		},
+	},
+	{
+		Group:    "Configuration",
+		Commands: []string{"diff-backend"},
+		Summary:  "Compare what two backends would configure",
+		Usage: []string{
+			"diff-backend <a> <b>    Compare the env, models, pricing and timeouts two backends configure",
+			"  --changed             Only show settings that differ",
+		},
+		Examples: []helpExample{
+			{Backend: "zai", Line: "promptops diff-backend deepseek zai --changed"},
+		},
+	},
+	{
+		Group:    "Configuration",
+		Commands: []string{"models"},
+		Summary:  "List a backend's models",
+		Usage: []string{
+			"models <backend>        List a backend's models live",
+			"  --offline             Use the last snapshot instead",
+			"models aliases          List model aliases (NEXUS_ALIAS_<NAME>)",
+		},
+		Examples: []helpExample{
+			{Backend: "ollama", Line: "promptops models ollama"},
+			{Backend: "openrouter", Line: "promptops models openrouter --offline"},
+		},
+	},
+	{
+		Group:    "Configuration",
+		Commands: []string{"snapshot-models"},
+		Summary:  "Save model catalogs and report changes",
+		Usage: []string{
+			"snapshot-models [b...]  Save model catalogs of configured backends and report changes",
+		},
+	},
+	{
+		Group:    "Configuration",
+		Commands: []string{"services"},
+		Summary:  "Start and stop companion local services",
+		Usage: []string{
+			"services start [name]   Start configured services and wait until ready",
+			"  --timeout <duration>  Readiness timeout (default 60s)",
+			"services stop [name]    Stop services in reverse order",
+			"services status         Show readiness and process of each service",
+		},
+	},
+	{
+		Group:    "Configuration",
+		Commands: []string{"api"},
+		Summary:  "Serve a localhost JSON API for IDE plugins",
+		Usage: []string{
+			"api serve [--port <n>]  Serve a localhost JSON API (default port 18090); follows .env.local edits",
+			"                        Endpoints: /v1/status /v1/backend /v1/cost /v1/budgets",
+			"                        /v1/sessions /v1/usage; bearer token in .promptops-api-token",
+		},
+	},
+	{
+		Group:    "Configuration",
+		Commands: []string{"export-state", "import-state"},
+		Summary:  "Migrate config, sessions and usage history",
+		Usage: []string{
+			"export-state <file>     Export config, sessions, usage and captured prompts",
+			"  --include-keys        Include API keys, encrypted with a passphrase",
+			"import-state <file>     Import a state bundle (merges history)",
+			"  --force               Replace existing config and state",
+		},
+		Examples: []helpExample{
+			{Line: "promptops export-state backup.tar.gz"},
+		},
+	},
+	{
+		Group:    "Configuration",
+		Commands: []string{"init", "setup"},
+		Summary:  "Create .env.local and ignore local state in git",
+		Usage: []string{
+			"init                    Initialize .env.local and ignore it and local state in git",
+		},
+	},
+	{
+		Group:    "General",
+		Commands: []string{"run", "launch"},
+		Summary:  "Launch Claude Code with the current backend",
+		Usage: []string{
+			"run [args]              Launch Claude Code with current backend",
+			"  --confirm-expensive   Confirm before launching on opus-tier pricing",
+			"  --for <duration>      Stop Claude Code after a time limit (e.g. 2h)",
+			"  --no-animation        Skip spinners, logos and progress bars",
+			"  --quiet               Print only warnings and errors",
+		},
+		Examples: []helpExample{
+			{Line: "promptops run --for 2h"},
+		},
+	},
+	{
+		Group:    "General",
+		Commands: []string{"flush"},
+		Summary:  "Write usage records saved while the usage file was unavailable",
+		Usage: []string{
+			"flush                   Write usage records saved while the usage file was unavailable",
+		},
+	},
+	{
+		Group:    "General",
+		Commands: []string{"version"},
+		Summary:  "Show version information",
+		Usage:    []string{"version                 Show version information"},
+	},
+	{
+		Group:    "General",
+		Commands: []string{"help"},
+		Summary:  "Show help for a command, backend or 'env'",
+		Usage: []string{
+			"help                    Show the command overview",
+			"help <command>          Show a command's options and examples",
+			"help <backend>          Show a backend's key, models and related commands",
+			"help env                List the environment variables",
+		},
+	},
+}
+
+// helpEnv lists the environment variables, shown by `promptops help env`
+var helpEnv = []string{
+	"NEXUS_ENV                 Named environment used when --env is not given",
+	"NEXUS_ENV_FILE            Path to env file (default: ./.env.local)",
+	"NEXUS_YOLO_MODE           Global YOLO mode (default: true)",
+	"NEXUS_YOLO_MODE_<BACKEND> YOLO mode for specific backend (default: true)",
+	"NEXUS_SYSTEM_PREFIX_<BACKEND> System text added before the client's system prompt (\\n for newlines)",
+	"NEXUS_SYSTEM_SUFFIX_<BACKEND> System text added after the client's system prompt",
+	"NEXUS_NO_ANIMATION        Skip launch spinners, logos and progress bars (default: false)",
+	"NEXUS_QUIET               Print only warnings and errors at launch (default: false)",
+	"NEXUS_DAILY_BUDGET_<BACKEND> Backend's own budget (also _WEEKLY_, _MONTHLY_)",
+	"NEXUS_DEFAULT_BACKEND     Default backend, or 'auto' for daily health-based choice",
+	"NEXUS_AUTO_BACKENDS       Preference list for auto selection (comma-separated)",
+	"NEXUS_BUNDLE_PASSPHRASE   Passphrase for export-state/import-state key encryption",
+	"NEXUS_CONFIRM_EXPENSIVE   Confirm launches above the price threshold (default: false)",
+	"NEXUS_EXPENSIVE_THRESHOLD Opus-tier output price per 1M tokens (default: 10.00)",
+	"NEXUS_OFFPEAK_<BACKEND>   Off-peak prices, HH:MM-HH:MM=in/out in UTC",
+	"NEXUS_COMPACT_THRESHOLD   Summarize proxied history above N tokens (default: 0, off)",
+	"NEXUS_DEMOTE_LATENCY      Demote haiku-tier proxy requests to NEXUS_DEMOTE_MODEL above this p95",
+	"NEXUS_AUTH_<BACKEND>      Auth strategy: bearer, header[:name], query:param, sigv4:region/service, oauth:url",
+	"NEXUS_KEY_BROKER_URL      Lease short-lived keys from a key broker at launch (organization mode)",
+	"NEXUS_LEDGER_SIGNING      Chain usage and audit records with HMAC for audit verify (default: false)",
+	"NEXUS_LEDGER_KEY_FILE     Absolute path of the signing key (default: .promptops-ledger.key)",
+	"NEXUS_COST_ANNOTATIONS    Print each proxied response's cost to the terminal (default: false)",
+	"NEXUS_WARM_MODELS         Keep Ollama haiku and sonnet models loaded during a launch (default: false)",
+	"NEXUS_SESSION_SUMMARIES   Describe sessions by their first prompt, using a local model (default: false)",
+	"NEXUS_FIM_MODEL           Mistral fill-in-the-middle model (default: codestral-latest)",
+	"NEXUS_CODESTRAL_URL       Codestral FIM endpoint used with CODESTRAL_API_KEY",
+	"NEXUS_CHAOS               Inject proxy latency and upstream errors (e.g. latency:500ms,errors:5%)",
+	"NEXUS_LOCAL_UPSTREAMS     Extra local servers for the proxy (e.g. lmstudio=http://localhost:1234/v1)",
+	"NEXUS_LOCAL_ROUTES        Model routes to upstreams (e.g. qwen*=lmstudio,llama*=ollama)",
+	"NEXUS_SERVICE_<NAME>      Local service command (_READY probe URL, _STOP stop command)",
+	"NEXUS_SERVICES_AUTOSTART  Start local services before each launch (default: false)",
+	"NEXUS_TOKENIZER_URL       Remote tokenize endpoint for exact token counts",
+	"ANTHROPIC_VERSION         anthropic-version header (default: 2023-06-01)",
+	"OPENAI_API_VERSION        api-version parameter for OpenAI requests (default: not sent)",
+	"NEXUS_ARGS_<BACKEND>      Default Claude Code arguments for a backend",
+	"NEXUS_PIN_<BACKEND>_<TIER> Exact model version for a tier (e.g. NEXUS_PIN_CLAUDE_SONNET)",
+	"NEXUS_ALIAS_<NAME>        Model alias as <backend>/<model>, usable wherever a model is expected",
+	"NEXUS_NO_TRAINING_REPOS   Repos where providers that may train on inputs are refused",
+	"NEXUS_DATA_REGIONS        Regions a repo may send data to, e.g. payments:EU,*:EU+US",
+	"NEXUS_DATA_REGION_MODE    block (default) or warn on data region violations",
+	"NEXUS_COST_TAG_<NAME>     Default cost-allocation tag; .promptops/tags overrides per workspace",
+	"NEXUS_REQUIRED_COST_TAGS  Tags a launch requires, e.g. team,project (comma-separated)",
+	"NEXUS_HEALTH_TIMEOUT      Health check timeout (default: 5s; _<BACKEND> per backend)",
+	"NEXUS_USAGE_TIMEOUT       Usage API timeout (default: 10s; _<BACKEND> per backend)",
+	"NEXUS_API_TIMEOUT         Claude Code API timeout (default: 50m; _<BACKEND> per backend)",
+	"NEXUS_LATENCY_BUDGET      Proxied upstream wait (default: 10m; _<BACKEND> per backend)",
+	"NEXUS_MAX_TOKENS          Cap on max_tokens per request (_<BACKEND> per backend)",
+	"NEXUS_TEMPERATURE         Proxied temperature when a request sets none (_<BACKEND> per backend)",
+	"NEXUS_TOP_P               Proxied top_p when a request sets none (_<BACKEND> per backend)",
+}
+
+// commandNames are the top-level commands other than backends, for suggestions
+func commandNames() []string {
+	var names []string
+	for _, p := range helpPages {
+		names = append(names, p.Commands...)
+	}
+	return names
+}
+
+// findHelpPage returns the page covering command
+func findHelpPage(command string) (helpPage, bool) {
+	for _, p := range helpPages {
+		for _, c := range p.Commands {
+			if c == command {
+				return p, true
+			}
+		}
+	}
+	return helpPage{}, false
+}
+
+// backendConfigured reports whether a launch on be could authenticate
+func backendConfigured(cfg *Config, be Backend) bool {
+	return cfg.Keys[be.AuthVar] != "" || be.Name == "ollama" || cfg.KeyBrokerURL != ""
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// suggest returns the candidate closest to input, or "" when none is close
+// enough to be a likely typo: within 2 edits, and fewer than half of input's
+// characters for short inputs
+func suggest(input string, candidates []string) string {
+	input = strings.ToLower(input)
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		d := editDistance(input, c)
+		if d < bestDist && d*2 < len([]rune(input)) {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// didYouMean formats a suggestion for an unknown name, or "" without one
+func didYouMean(input string, candidates []string) string {
+	if s := suggest(input, candidates); s != "" {
+		return fmt.Sprintf(" Did you mean '%s'?", s)
+	}
+	return ""
+}
+
+// unknownBackendError reports an unknown backend name with the closest match
+func unknownBackendError(name string) {
+	fmt.Fprintf(os.Stderr, "Error: Unknown backend '%s'.%s\n", name, didYouMean(name, doctorBackends))
+}
+
+// unknownCommandError reports an unknown command with the closest command or
+// backend name
+func unknownCommandError(cmd string) {
+	candidates := append(commandNames(), doctorBackends...)
+	fmt.Fprintf(os.Stderr, "Error: Unknown command '%s'.%s Run 'promptops help' for usage.\n", cmd, didYouMean(cmd, candidates))
+}
+
+// showHelp prints the overview, or the page for a command or backend
+func showHelp(args []string) {
+	cfg := loadConfig()
+	if len(args) == 0 {
+		writeHelpOverview(cfg, os.Stdout)
+		return
+	}
+	if !writeHelpTopic(cfg, strings.ToLower(args[0]), os.Stdout) {
+		candidates := append(commandNames(), doctorBackends...)
+		fmt.Fprintf(os.Stderr, "Error: No help for '%s'.%s Run 'promptops help' for the command list.\n", args[0], didYouMean(args[0], append(candidates, "env")))
+		os.Exit(1)
+	}
+}
+
+// writeHelpOverview lists backends with whether they are configured, each
+// command with a one-line summary, and examples for configured backends
+func writeHelpOverview(cfg *Config, out io.Writer) {
+	fmt.Fprintln(out, "+-------------------------------------------------------------------------------+")
+	fmt.Fprintln(out, "|                    PROMPTOPS ENTERPRISE v"+getVersion()+"                       |")
+	fmt.Fprintln(out, "+-------------------------------------------------------------------------------+")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Usage: promptops <command> [options]")
+	fmt.Fprintln(out)
+
+	fmt.Fprintln(out, "Backends (switch and launch Claude Code):")
+	var configured []Backend
+	for _, name := range doctorBackends {
+		be := backends[name]
+		state := "no key"
+		if backendConfigured(cfg, be) {
+			state = "configured"
+			configured = append(configured, be)
+		}
+		fmt.Fprintf(out, "  %-24s%-28s %s\n", name, be.DisplayName, state)
+	}
+
+	for _, group := range helpGroups {
+		fmt.Fprintln(out)
+		fmt.Fprintf(out, "%s:\n", group)
+		for _, p := range helpPages {
+			if p.Group == group {
+				fmt.Fprintf(out, "  %-24s%s\n", p.Commands[0], p.Summary)
+			}
+		}
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Global flags (before or after the command):")
+	for _, f := range globalFlags {
+		fmt.Fprintf(out, "  %-24s%s\n", strings.TrimSpace("--"+f.Name+" "+f.Value), f.Usage)
+		if f.Optional {
+			fmt.Fprintf(out, "  %-24sFor %s\n", "", strings.Join(flagCommands(f.Name), ", "))
+		}
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Examples:")
+	if len(configured) == 0 {
+		fmt.Fprintln(out, "  promptops init            # Create .env.local, then add an API key")
+	}
+	for i, be := range configured {
+		// A few backends are enough to show the pattern
+		if i == 3 {
+			break
+		}
+		fmt.Fprintf(out, "  %-25s # Switch to %s and launch Claude Code\n", "promptops "+be.Name, be.DisplayName)
+	}
+	fmt.Fprintln(out, "  promptops status          # Check current configuration")
+	fmt.Fprintln(out, "  promptops doctor          # Run health checks")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Run 'promptops help <command>' for options and examples, 'promptops help env' for")
+	fmt.Fprintln(out, "environment variables.")
+}
+
+// writeHelpTopic prints the page for a command, a backend or "env", and
+// reports whether topic was known
+func writeHelpTopic(cfg *Config, topic string, out io.Writer) bool {
+	if topic == "env" {
+		fmt.Fprintln(out, "Environment Variables:")
+		for _, line := range helpEnv {
+			fmt.Fprintln(out, "  "+line)
+		}
+		return true
+	}
+	if be, ok := backends[topic]; ok {
+		writeBackendHelp(cfg, be, out)
+		return true
+	}
+	p, ok := findHelpPage(topic)
+	if !ok {
+		return false
+	}
+	fmt.Fprintf(out, "promptops %s - %s\n", p.Commands[0], p.Summary)
+	if len(p.Commands) > 1 {
+		fmt.Fprintf(out, "Also: %s\n", strings.Join(p.Commands[1:], ", "))
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Usage:")
+	for _, line := range p.Usage {
+		// Option and continuation lines are indented under their command
+		if strings.HasPrefix(line, " ") {
+			fmt.Fprintln(out, "            "+line)
+		} else {
+			fmt.Fprintln(out, "  promptops "+line)
+		}
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Global flags:")
+	for _, f := range commandFlags(p.Commands[0]) {
+		// Usage lines describe what a flag means for this command
+		if !slices.ContainsFunc(p.Usage, func(line string) bool { return strings.Contains(line, "--"+f.Name) }) {
+			fmt.Fprintf(out, "  %-24s%s\n", strings.TrimSpace("--"+f.Name+" "+f.Value), f.Usage)
+		}
+	}
+	var examples []string
+	for _, e := range p.Examples {
+		if e.Backend == "" || backendConfigured(cfg, backends[e.Backend]) {
+			examples = append(examples, e.Line)
+		}
+	}
+	if len(examples) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Examples:")
+		for _, line := range examples {
+			fmt.Fprintln(out, "  "+line)
+		}
+	}
+	return true
+}
+
+// writeBackendHelp prints how to launch a backend, its key and tier models
+func writeBackendHelp(cfg *Config, be Backend, out io.Writer) {
+	fmt.Fprintf(out, "promptops %s - Switch to %s (%s) and launch Claude Code\n", be.Name, be.DisplayName, be.Models)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintf(out, "  promptops %s [claude args]   Arguments are passed to Claude Code\n", be.Name)
+	fmt.Fprintf(out, "  promptops %s --no-animation  Skip spinners, logos and progress bars (--quiet: warnings only)\n", be.Name)
+	fmt.Fprintln(out)
+	key := "not set"
+	if backendConfigured(cfg, be) {
+		key = "configured"
+	}
+	haiku, sonnet, opus := resolveTierModels(cfg, be)
+	fmt.Fprintf(out, "Key:     %s (%s)\n", be.AuthVar, key)
+	fmt.Fprintf(out, "Models:  haiku %s, sonnet %s, opus %s\n", haiku, sonnet, opus)
+	fmt.Fprintf(out, "Pricing: %s in / %s out per 1M tokens\n", formatCurrency(be.InputPrice), formatCurrency(be.OutputPrice))
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Related:")
+	fmt.Fprintf(out, "  promptops which %s\n", be.Name)
+	fmt.Fprintf(out, "  promptops validate %s\n", be.Name)
+	fmt.Fprintf(out, "  promptops usage %s\n", be.Name)
+	fmt.Fprintf(out, "  promptops models %s\n", be.Name)
+}
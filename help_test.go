@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"status", "status", 0},
+		{"stauts", "status", 2},
+		{"dcotor", "doctor", 2},
+		{"deepsek", "deepseek", 1},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := append(commandNames(), doctorBackends...)
+	tests := map[string]string{
+		"stauts":   "status",
+		"docter":   "doctor",
+		"Deepsek":  "deepseek",
+		"olama":    "ollama",
+		"sesion":   "session",
+		"xyzzy":    "",
+		"go":       "",
+		"budgetss": "budget",
+	}
+	for input, want := range tests {
+		if got := suggest(input, candidates); got != want {
+			t.Errorf("suggest(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestHelpPagesCoverCommands(t *testing.T) {
+	seen := map[string]bool{}
+	for _, p := range helpPages {
+		if p.Summary == "" || len(p.Usage) == 0 {
+			t.Errorf("Page %s is missing a summary or usage", p.Commands[0])
+		}
+		found := false
+		for _, g := range helpGroups {
+			found = found || g == p.Group
+		}
+		if !found {
+			t.Errorf("Page %s has unknown group %q", p.Commands[0], p.Group)
+		}
+		for _, c := range p.Commands {
+			if seen[c] {
+				t.Errorf("Command %s is on more than one page", c)
+			}
+			seen[c] = true
+		}
+		for _, e := range p.Examples {
+			if _, ok := backends[e.Backend]; e.Backend != "" && !ok {
+				t.Errorf("Example %q names unknown backend %s", e.Line, e.Backend)
+			}
+		}
+	}
+}
+
+func TestWriteHelpOverview(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.Keys["DEEPSEEK_API_KEY"] = "sk-test"
+
+	var out bytes.Buffer
+	writeHelpOverview(cfg, &out)
+	s := out.String()
+	if !strings.Contains(s, "promptops deepseek") || strings.Contains(s, "promptops gemini ") {
+		t.Errorf("Expected examples only for configured backends:\n%s", s)
+	}
+	if !strings.Contains(s, "doctor") || strings.Contains(s, "NEXUS_ENV_FILE") {
+		t.Errorf("Expected commands without the environment variables:\n%s", s)
+	}
+	if strings.Contains(s, "sk-test") {
+		t.Error("Help must not print keys")
+	}
+}
+
+func TestWriteHelpTopic(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.Keys["ANTHROPIC_API_KEY"] = "sk-ant"
+
+	var out bytes.Buffer
+	if !writeHelpTopic(cfg, "usage", &out) {
+		t.Fatal("Expected a usage page")
+	}
+	s := out.String()
+	if !strings.Contains(s, "promptops usage claude") || strings.Contains(s, "promptops usage deepseek") {
+		t.Errorf("Expected only examples for configured backends:\n%s", s)
+	}
+
+	out.Reset()
+	if !writeHelpTopic(cfg, "current", &out) || !strings.Contains(out.String(), "promptops status") {
+		t.Errorf("Expected an alias to show its page, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if !writeHelpTopic(cfg, "deepseek", &out) || !strings.Contains(out.String(), "DEEPSEEK_API_KEY (not set)") {
+		t.Errorf("Expected the backend page, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if !writeHelpTopic(cfg, "env", &out) || !strings.Contains(out.String(), "NEXUS_ENV_FILE") {
+		t.Errorf("Expected the environment variables, got:\n%s", out.String())
+	}
+
+	if writeHelpTopic(cfg, "nonsense", &out) {
+		t.Error("Expected an unknown topic to be reported")
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hookEvents are the Claude Code hook events PromptOps gates
+var hookEvents = []string{"UserPromptSubmit", "PreToolUse"}
+
+// hookBlockExitCode makes Claude Code block the prompt or tool call and
+// show the hook's stderr
+const hookBlockExitCode = 2
+
+// hookCommandSuffix identifies hook entries managed by PromptOps
+const hookCommandSuffix = " hooks check"
+
+// hookInput is the subset of the JSON Claude Code sends to hooks on stdin
+type hookInput struct {
+	Event    string `json:"hook_event_name"`
+	ToolName string `json:"tool_name"`
+	Prompt   string `json:"prompt"` // UserPromptSubmit only
+}
+
+// budgetExhausted returns a reason when any budget period is fully spent
+func budgetExhausted(cfg *Config, daily, weekly, monthly float64) string {
+	periods := []struct {
+		name         string
+		spent, limit float64
+	}{
+		{"daily", daily, cfg.DailyBudget},
+		{"weekly", weekly, cfg.WeeklyBudget},
+		{"monthly", monthly, cfg.MonthlyBudget},
+	}
+	for _, p := range periods {
+		if p.limit > 0 && p.spent >= p.limit {
+			return fmt.Sprintf("%s budget exhausted: %s of %s spent", p.name, formatCurrency(p.spent), formatCurrency(p.limit))
+		}
+	}
+	return ""
+}
+
+// hookBlockReason checks budgets, the prepaid balance and the training
+// policy for the current backend and returns why work should stop, if at all
+func hookBlockReason(cfg *Config, git GitInfo) string {
+	daily, weekly, monthly, _ := calculateCosts(cfg)
+	if reason := budgetExhausted(cfg, daily, weekly, monthly); reason != "" {
+		return reason
+	}
+
+	be, ok := backends[getCurrentBackend(cfg)]
+	if !ok {
+		return ""
+	}
+	costs := periodCostsByBackend(ledgerUsage(cfg), time.Now())
+	if reason := backendBudgetExhausted(cfg, be.Name, costs[be.Name]); reason != "" {
+		return reason
+	}
+	if s, ok := creditStatus(cfg, be.Name); ok && s.Remaining <= 0 {
+		return creditWarning(s, cfg.CreditWarnPercents)
+	}
+	if err := checkTrainingPolicy(cfg, be, git); err != nil {
+		return err.Error()
+	}
+	// Warn mode was reported at launch; prompts are not held up again
+	if err := checkRegionPolicy(cfg, be, git); err != nil && !cfg.DataRegionWarn {
+		return err.Error()
+	}
+	return ""
+}
+
+// runHookCheck handles one hook invocation and returns the exit code:
+// 0 to allow or hookBlockExitCode to block with a reason on stderr
+func runHookCheck(cfg *Config, stdin io.Reader, stderr io.Writer) int {
+	var input hookInput
+	// A malformed payload still gets checked; it only loses the event name
+	json.NewDecoder(io.LimitReader(stdin, maxAPIRequestBody)).Decode(&input)
+
+	reason := hookBlockReason(cfg, currentGitInfo())
+	if reason == "" {
+		if input.Event == "UserPromptSubmit" {
+			// Claude Code only shows a passing hook's stderr in verbose mode
+			if err := countSessionPrompt(cfg); err != nil {
+				fmt.Fprintf(stderr, "Warning: failed to count prompt: %v\n", err)
+			}
+			if err := recordSessionIntent(cfg, input.Prompt, startIntentSummary); err != nil {
+				fmt.Fprintf(stderr, "Warning: failed to summarize session intent: %v\n", err)
+			}
+		}
+		return 0
+	}
+
+	event := input.Event
+	if input.ToolName != "" {
+		event += " " + input.ToolName
+	}
+	auditLog(cfg, fmt.Sprintf("HOOK_BLOCKED: %s: %s", strings.TrimSpace(event), reason))
+	fmt.Fprintf(stderr, "PromptOps blocked this request: %s (check with: promptops hooks status)\n", reason)
+	return hookBlockExitCode
+}
+
+// hookSettingsPath returns the Claude Code settings file to manage: the
+// project's .claude/settings.json, or the user's with user set
+func hookSettingsPath(user bool) (string, error) {
+	if user {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".claude", "settings.json"), nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(wd, ".claude", "settings.json"), nil
+}
+
+// hookCommand returns the command Claude Code runs for each gated event.
+// A config directory and named environment are passed through so the hook
+// reads the same ledger.
+func hookCommand(exe, configDir, env string) string {
+	cmd := fmt.Sprintf("%q", exe)
+	if configDir != "" {
+		cmd += fmt.Sprintf(" --config-dir %q", configDir)
+	}
+	if env != "" {
+		cmd += " --env " + env
+	}
+	return cmd + hookCommandSuffix
+}
+
+func isPromptOpsHook(command string) bool {
+	return strings.HasSuffix(command, hookCommandSuffix)
+}
+
+// loadHookSettings reads a settings file, keeping every unrelated key
+func loadHookSettings(path string) (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+func saveHookSettings(path string, settings map[string]interface{}) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0644)
+}
+
+// removePromptOpsHooks drops PromptOps hook entries from settings, removing
+// matcher groups and events that become empty. It returns how many it removed.
+func removePromptOpsHooks(settings map[string]interface{}) int {
+	hooks, _ := settings["hooks"].(map[string]interface{})
+	removed := 0
+	for event, raw := range hooks {
+		groups, _ := raw.([]interface{})
+		var keptGroups []interface{}
+		for _, g := range groups {
+			group, ok := g.(map[string]interface{})
+			if !ok {
+				keptGroups = append(keptGroups, g)
+				continue
+			}
+			entries, _ := group["hooks"].([]interface{})
+			var kept []interface{}
+			for _, e := range entries {
+				entry, _ := e.(map[string]interface{})
+				if cmd, _ := entry["command"].(string); entry != nil && isPromptOpsHook(cmd) {
+					removed++
+					continue
+				}
+				kept = append(kept, e)
+			}
+			if len(kept) > 0 {
+				group["hooks"] = kept
+				keptGroups = append(keptGroups, group)
+			}
+		}
+		if len(keptGroups) > 0 {
+			hooks[event] = keptGroups
+		} else {
+			delete(hooks, event)
+		}
+	}
+	if hooks != nil && len(hooks) == 0 {
+		delete(settings, "hooks")
+	}
+	return removed
+}
+
+// installPromptOpsHooks replaces any PromptOps hooks in settings with one
+// entry per gated event running command
+func installPromptOpsHooks(settings map[string]interface{}, command string) {
+	removePromptOpsHooks(settings)
+	hooks, ok := settings["hooks"].(map[string]interface{})
+	if !ok {
+		hooks = make(map[string]interface{})
+		settings["hooks"] = hooks
+	}
+	for _, event := range hookEvents {
+		group := map[string]interface{}{
+			"hooks": []interface{}{
+				map[string]interface{}{"type": "command", "command": command},
+			},
+		}
+		if event == "PreToolUse" {
+			group["matcher"] = "*"
+		}
+		groups, _ := hooks[event].([]interface{})
+		hooks[event] = append(groups, group)
+	}
+}
+
+// installedHookCount counts PromptOps hook entries in settings
+func installedHookCount(settings map[string]interface{}) int {
+	copied := make(map[string]interface{})
+	data, _ := json.Marshal(settings)
+	json.Unmarshal(data, &copied)
+	return removePromptOpsHooks(copied)
+}
+
+func handleHooksCommand(args []string) {
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+	subcmd := args[0]
+	rest, user := stripFlag(args[1:], "--user")
+
+	switch subcmd {
+	case "check":
+		os.Exit(runHookCheck(loadConfig(), os.Stdin, os.Stderr))
+	case "intent":
+		// Started in the background by the UserPromptSubmit hook
+		if len(rest) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: promptops hooks intent <session-id>")
+			os.Exit(1)
+		}
+		prompt, _ := io.ReadAll(io.LimitReader(os.Stdin, maxAPIRequestBody))
+		if err := summarizeSessionIntent(loadConfig(), rest[0], backends["ollama"].BaseURL, string(prompt)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "install", "uninstall", "status":
+		if len(rest) > 0 {
+			fmt.Fprintf(os.Stderr, "Usage: promptops hooks %s [--user]\n", subcmd)
+			os.Exit(1)
+		}
+		manageHooks(subcmd, user)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown hooks command: %s\n", subcmd)
+		os.Exit(1)
+	}
+}
+
+func manageHooks(subcmd string, user bool) {
+	cfg := loadConfig()
+	path, err := hookSettingsPath(user)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	settings, err := loadHookSettings(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch subcmd {
+	case "install":
+		exe, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot locate promptops binary: %v\n", err)
+			os.Exit(1)
+		}
+		installPromptOpsHooks(settings, hookCommand(exe, globalOpts.ConfigDir, cfg.Environment))
+		if err := saveHookSettings(path, settings); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		auditLog(cfg, fmt.Sprintf("HOOKS_INSTALLED: %s", path))
+		fmt.Printf("[OK] Installed budget gate hooks (%s) in %s\n", strings.Join(hookEvents, ", "), path)
+	case "uninstall":
+		removed := removePromptOpsHooks(settings)
+		if removed == 0 {
+			fmt.Printf("No PromptOps hooks found in %s\n", path)
+			return
+		}
+		if err := saveHookSettings(path, settings); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		auditLog(cfg, fmt.Sprintf("HOOKS_REMOVED: %s", path))
+		fmt.Printf("[OK] Removed %d PromptOps hooks from %s\n", removed, path)
+	case "status":
+		fmt.Println()
+		fmt.Println(styleSection.Render("CLAUDE CODE HOOKS"))
+		if n := installedHookCount(settings); n > 0 {
+			fmt.Printf("%s %d PromptOps hooks in %s\n", styleSuccess.Render("[OK]"), n, path)
+		} else {
+			fmt.Printf("%s Not installed in %s (run: promptops hooks install)\n", styleMuted.Render("[--]"), path)
+		}
+		if reason := hookBlockReason(cfg, currentGitInfo()); reason != "" {
+			fmt.Println(styleError.Render("Gate: blocking - " + reason))
+		} else {
+			fmt.Println(styleSuccess.Render("Gate: allowing prompts and tool calls"))
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetExhausted(t *testing.T) {
+	cfg := &Config{DailyBudget: 10, WeeklyBudget: 50, MonthlyBudget: 0}
+
+	if reason := budgetExhausted(cfg, 9.99, 20, 500); reason != "" {
+		t.Errorf("Expected no block under budget (monthly disabled), got %q", reason)
+	}
+	if reason := budgetExhausted(cfg, 10, 20, 20); !strings.Contains(reason, "daily budget exhausted") {
+		t.Errorf("Expected daily budget block, got %q", reason)
+	}
+	if reason := budgetExhausted(cfg, 1, 75, 75); !strings.Contains(reason, "weekly") {
+		t.Errorf("Expected weekly budget block, got %q", reason)
+	}
+}
+
+func TestRunHookCheck(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.AuditEnabled = true
+	input := `{"hook_event_name": "PreToolUse", "tool_name": "Bash"}`
+
+	var stderr bytes.Buffer
+	if code := runHookCheck(cfg, strings.NewReader(input), &stderr); code != 0 {
+		t.Errorf("Expected exit 0 with no spend, got %d (%s)", code, stderr.String())
+	}
+
+	data, _ := json.Marshal(UsageRecord{Timestamp: time.Now(), Backend: "claude", CostUSD: 12})
+	if err := os.WriteFile(cfg.UsageFile, append(data, '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if code := runHookCheck(cfg, strings.NewReader(input), &stderr); code != hookBlockExitCode {
+		t.Errorf("Expected exit %d over budget, got %d", hookBlockExitCode, code)
+	}
+	if !strings.Contains(stderr.String(), "daily budget exhausted") {
+		t.Errorf("Expected reason on stderr, got %q", stderr.String())
+	}
+	audit, _ := os.ReadFile(cfg.AuditLog)
+	if !strings.Contains(string(audit), "HOOK_BLOCKED: PreToolUse Bash") {
+		t.Errorf("Expected audit entry, got %q", string(audit))
+	}
+}
+
+func TestHookBlockReasonCredits(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	if err := setCurrentBackend(cfg, "deepseek"); err != nil {
+		t.Fatal(err)
+	}
+	err := updateCredits(cfg, func(credits map[string]CreditBalance) {
+		credits["deepseek"] = CreditBalance{Amount: 0, SetAt: time.Now().Add(-time.Hour)}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason := hookBlockReason(cfg, GitInfo{}); !strings.Contains(reason, "exhausted") {
+		t.Errorf("Expected exhausted credit block, got %q", reason)
+	}
+}
+
+func TestHookBlockReasonBackendBudget(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	setBackendBudget(cfg, "daily", "claude", 2)
+	if err := setCurrentBackend(cfg, "claude"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(UsageRecord{Timestamp: time.Now(), Backend: "claude", CostUSD: 3})
+	if err := os.WriteFile(cfg.UsageFile, append(data, '\n'), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if reason := hookBlockReason(cfg, GitInfo{}); !strings.Contains(reason, "Claude daily budget exhausted") {
+		t.Errorf("Expected backend budget block, got %q", reason)
+	}
+
+	// Other backends keep working within the global budget
+	if err := setCurrentBackend(cfg, "deepseek"); err != nil {
+		t.Fatal(err)
+	}
+	if reason := hookBlockReason(cfg, GitInfo{}); reason != "" {
+		t.Errorf("Expected no block for deepseek, got %q", reason)
+	}
+}
+
+func TestInstallPromptOpsHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".claude", "settings.json")
+	existing := `{"env": {"X": "1"}, "hooks": {"PreToolUse": [{"matcher": "Bash", "hooks": [{"type": "command", "command": "lint.sh"}]}]}}`
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := loadHookSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command := hookCommand("/opt/promptops", "/srv/promptops", "work"); command != `"/opt/promptops" --config-dir "/srv/promptops" --env work hooks check` {
+		t.Errorf("Unexpected hook command: %s", command)
+	}
+	command := hookCommand("/opt/promptops", "", "work")
+	if command != `"/opt/promptops" --env work hooks check` {
+		t.Errorf("Unexpected hook command: %s", command)
+	}
+
+	// Installing twice must not duplicate entries
+	installPromptOpsHooks(settings, command)
+	installPromptOpsHooks(settings, command)
+	if err := saveHookSettings(path, settings); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, _ := loadHookSettings(path)
+	if n := installedHookCount(reloaded); n != len(hookEvents) {
+		t.Errorf("Expected %d PromptOps hooks, got %d", len(hookEvents), n)
+	}
+	if _, ok := reloaded["env"]; !ok {
+		t.Error("Expected unrelated settings to be preserved")
+	}
+
+	if removed := removePromptOpsHooks(reloaded); removed != len(hookEvents) {
+		t.Errorf("Expected %d hooks removed, got %d", len(hookEvents), removed)
+	}
+	hooks, _ := reloaded["hooks"].(map[string]interface{})
+	if len(hooks) != 1 || hooks["PreToolUse"] == nil {
+		t.Errorf("Expected only the user's PreToolUse hook to remain, got %v", hooks)
+	}
+
+	empty := map[string]interface{}{}
+	installPromptOpsHooks(empty, command)
+	removePromptOpsHooks(empty)
+	if _, ok := empty["hooks"]; ok {
+		t.Error("Expected empty hooks key to be removed")
+	}
+}
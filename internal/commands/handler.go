@@ -4,7 +4,6 @@ package commands
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -15,7 +14,7 @@ import (
 
 	"nexus/internal/backend"
 	"nexus/internal/config"
-	"nexus/internal/proxy"
+	"nexus/internal/launch"
 	"nexus/internal/session"
 	"nexus/internal/ui"
 	"nexus/internal/usage"
@@ -165,114 +164,18 @@ func (h *Handler) SwitchBackend(name string, args []string) {
 }
 
 func (h *Handler) launchClaudeWithBackend(be backend.Backend, args []string) {
-	cmdArgs := []string{}
-
-	yolo := h.cfg.GetYoloMode(be.Name)
-	if yolo {
-		cmdArgs = append(cmdArgs, "--dangerously-skip-permissions")
-	}
-
-	// Sanitize user-provided arguments
-	sanitizedArgs := ui.SanitizeArgs(args)
-	cmdArgs = append(cmdArgs, sanitizedArgs...)
-
-	cmd := exec.Command("claude", cmdArgs...)
-
-	// Build environment with whitelist approach
-	env := ui.FilterEnvironment(os.Environ(), ui.GetAllowedEnvVars())
-
-	// Set auth token for Claude Code
-	apiKey := h.cfg.Keys[be.AuthVar]
-	if apiKey != "" {
-		env = append(env, fmt.Sprintf("ANTHROPIC_AUTH_TOKEN=%s", apiKey))
-	} else if be.Name == "ollama" {
-		env = append(env, "ANTHROPIC_AUTH_TOKEN=ollama")
-	}
-
-	// Set backend-specific vars
-	baseURL := be.BaseURL
-	if be.BaseURL != "" {
-		env = append(env, fmt.Sprintf("API_TIMEOUT_MS=%d", be.Timeout.Milliseconds()))
-
-		haikuModel := be.HaikuModel
-		sonnetModel := be.SonnetModel
-		opusModel := be.OpusModel
-
-		if be.Name == "ollama" {
-			if m, ok := h.cfg.OllamaModels["haiku"]; ok && m != "" {
-				haikuModel = strings.TrimSpace(m)
-			}
-			if m, ok := h.cfg.OllamaModels["sonnet"]; ok && m != "" {
-				sonnetModel = strings.TrimSpace(m)
-			}
-			if m, ok := h.cfg.OllamaModels["opus"]; ok && m != "" {
-				opusModel = strings.TrimSpace(m)
-			}
-		}
-
-		if be.Name == "zai" {
-			if m, ok := h.cfg.ZAIModels["haiku"]; ok && m != "" {
-				haikuModel = strings.TrimSpace(m)
-			}
-			if m, ok := h.cfg.ZAIModels["sonnet"]; ok && m != "" {
-				sonnetModel = strings.TrimSpace(m)
-			}
-			if m, ok := h.cfg.ZAIModels["opus"]; ok && m != "" {
-				opusModel = strings.TrimSpace(m)
-			}
-		}
-
-		if be.Name == "kimi" {
-			if m, ok := h.cfg.KimiModels["haiku"]; ok && m != "" {
-				haikuModel = strings.TrimSpace(m)
-			}
-			if m, ok := h.cfg.KimiModels["sonnet"]; ok && m != "" {
-				sonnetModel = strings.TrimSpace(m)
-			}
-			if m, ok := h.cfg.KimiModels["opus"]; ok && m != "" {
-				opusModel = strings.TrimSpace(m)
-			}
-		}
-
-		env = append(env, fmt.Sprintf("ANTHROPIC_DEFAULT_HAIKU_MODEL=%s", haikuModel))
-		env = append(env, fmt.Sprintf("ANTHROPIC_DEFAULT_SONNET_MODEL=%s", sonnetModel))
-		env = append(env, fmt.Sprintf("ANTHROPIC_DEFAULT_OPUS_MODEL=%s", opusModel))
+	l := launch.New(h.cfg)
+	if !h.cfg.GetYoloMode(be.Name) {
+		l.Out = os.Stdout
 	}
-
-	// For Ollama, start a proxy
-	var prx *proxy.OllamaProxy
-	if be.Name == "ollama" {
-		prx = proxy.NewOllamaProxy(baseURL, proxy.BuildModelMap(h.cfg.OllamaModels))
-		if err := prx.Start(18080); err != nil {
-			fmt.Fprintf(os.Stderr, "Error starting Ollama proxy: %v\n", err)
-			os.Exit(1)
-		}
-		baseURL = "http://localhost:18080"
-		if !yolo {
-			fmt.Println("[OK] Started Anthropic-to-OpenAI proxy on port 18080")
-		}
-	}
-
-	env = append(env, fmt.Sprintf("ANTHROPIC_BASE_URL=%s", baseURL))
-
-	cmd.Env = env
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
-
-	if prx != nil {
-		prx.Stop()
-	}
-
+	code, err := l.Launch(be, args)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			os.Exit(exitErr.ExitCode())
-		}
 		fmt.Fprintf(os.Stderr, "Error launching claude: %v\n", err)
 		os.Exit(1)
 	}
+	if code != 0 {
+		os.Exit(code)
+	}
 }
 
 func (h *Handler) animateSwitch(msg string) {
@@ -0,0 +1,58 @@
+package launch
+
+import "nexus/internal/backend"
+
+// FakeRunner records runs instead of starting processes.
+type FakeRunner struct {
+	Runs     []FakeRun
+	ExitCode int
+	Err      error
+}
+
+// FakeRun is one recorded run.
+type FakeRun struct {
+	Name string
+	Args []string
+	Env  []string
+}
+
+// Run records the run and returns the configured exit code and error.
+func (r *FakeRunner) Run(name string, args, env []string) (int, error) {
+	r.Runs = append(r.Runs, FakeRun{Name: name, Args: args, Env: env})
+	return r.ExitCode, r.Err
+}
+
+// FakeProxy records its lifecycle instead of listening.
+type FakeProxy struct {
+	Port     int
+	Started  bool
+	Stopped  bool
+	StartErr error
+}
+
+// Start records the port, failing with StartErr when set.
+func (p *FakeProxy) Start(port int) error {
+	if p.StartErr != nil {
+		return p.StartErr
+	}
+	p.Port, p.Started = port, true
+	return nil
+}
+
+// Stop records that the proxy was stopped.
+func (p *FakeProxy) Stop() error {
+	p.Stopped = true
+	return nil
+}
+
+// FakeProxies returns a factory handing out p for the named backends.
+func FakeProxies(p *FakeProxy, names ...string) ProxyFactory {
+	return func(be backend.Backend) Proxy {
+		for _, name := range names {
+			if be.Name == name {
+				return p
+			}
+		}
+		return nil
+	}
+}
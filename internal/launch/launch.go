@@ -0,0 +1,275 @@
+// Package launch starts Claude Code against a backend: it composes the
+// environment, runs the local proxy a backend needs and runs the process.
+// Each step sits behind an interface so launches can be tested without
+// starting processes or servers. The promptops command runs its launches
+// through ConfigEnv, Runner and Proxy too, supplying its own EnvSettings,
+// with FakeRunner standing in for Claude Code in its tests.
+package launch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"nexus/internal/backend"
+	"nexus/internal/config"
+	"nexus/internal/proxy"
+	"nexus/internal/ui"
+)
+
+// ProxyPorts are the local ports of the proxies started for backends Claude
+// Code cannot talk to directly.
+var ProxyPorts = map[string]int{
+	"ollama":  18080,
+	"grok":    18081,
+	"openai":  18082,
+	"mistral": 18083,
+}
+
+// Command is the Claude Code executable.
+const Command = "claude"
+
+// EnvBuilder composes the environment Claude Code is launched with. baseURL
+// is where Claude Code sends requests: the backend itself or a local proxy.
+type EnvBuilder interface {
+	Build(be backend.Backend, baseURL string) ([]string, error)
+}
+
+// Runner runs a process to completion and returns its exit code. err is set
+// only when the process could not be run.
+type Runner interface {
+	Run(name string, args, env []string) (int, error)
+}
+
+// Proxy is a local server that runs for the length of a launch.
+type Proxy interface {
+	Start(port int) error
+	Stop() error
+}
+
+// ProxyFactory returns the proxy a backend needs, or nil when Claude Code
+// talks to the backend directly.
+type ProxyFactory func(be backend.Backend) Proxy
+
+// Launcher runs Claude Code against a backend.
+type Launcher struct {
+	Config  *config.Config
+	Env     EnvBuilder
+	Runner  Runner
+	Proxies ProxyFactory
+	// Out receives status lines such as a started proxy; nil discards them.
+	Out io.Writer
+}
+
+// New returns a launcher that runs the real claude executable with the
+// current process environment and terminal.
+func New(cfg *config.Config) *Launcher {
+	return &Launcher{
+		Config:  cfg,
+		Env:     NewConfigEnv(cfg, os.Environ()),
+		Runner:  ExecRunner{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr},
+		Proxies: OllamaProxies(cfg),
+	}
+}
+
+// Args returns the claude arguments for a launch: YOLO mode first, then
+// the sanitized user arguments.
+func (l *Launcher) Args(be backend.Backend, args []string) []string {
+	var cmdArgs []string
+	if l.Config.GetYoloMode(be.Name) {
+		cmdArgs = append(cmdArgs, "--dangerously-skip-permissions")
+	}
+	return append(cmdArgs, ui.SanitizeArgs(args)...)
+}
+
+// Launch starts any proxy the backend needs, runs Claude Code and stops the
+// proxy again. It returns Claude Code's exit code.
+func (l *Launcher) Launch(be backend.Backend, args []string) (int, error) {
+	baseURL := be.BaseURL
+	if l.Proxies != nil {
+		if p := l.Proxies(be); p != nil {
+			port, ok := ProxyPorts[be.Name]
+			if !ok {
+				return 1, fmt.Errorf("no proxy port for %s", be.DisplayName)
+			}
+			if err := p.Start(port); err != nil {
+				return 1, fmt.Errorf("starting %s proxy: %w", be.DisplayName, err)
+			}
+			defer p.Stop()
+			baseURL = fmt.Sprintf("http://127.0.0.1:%d", port)
+			l.printf("[OK] Started Anthropic-to-OpenAI proxy on port %d\n", port)
+		}
+	}
+	env, err := l.Env.Build(be, baseURL)
+	if err != nil {
+		return 1, err
+	}
+	return l.Runner.Run(Command, l.Args(be, args), env)
+}
+
+func (l *Launcher) printf(format string, a ...interface{}) {
+	if l.Out != nil {
+		fmt.Fprintf(l.Out, format, a...)
+	}
+}
+
+// Tiers are the Claude Code model tiers, in the order their variables are set.
+var Tiers = []string{"haiku", "sonnet", "opus"}
+
+// EnvSettings supplies the per-backend values of a launch environment.
+type EnvSettings interface {
+	// APIKey returns the key Claude Code authenticates with, or "".
+	APIKey(be backend.Backend) string
+	// Timeout returns the API timeout; it is set only for backends with a
+	// base URL.
+	Timeout(be backend.Backend) time.Duration
+	// TierModels returns the models Claude Code uses by tier name. Tiers
+	// missing from the map keep Claude Code's default.
+	TierModels(be backend.Backend) (map[string]string, error)
+	// Extra returns any further variables, such as output limits.
+	Extra(be backend.Backend) []string
+}
+
+// ConfigEnv builds the launch environment from EnvSettings. It is the only
+// implementation of EnvBuilder: the promptops command supplies its own
+// settings and NewConfigEnv supplies settings read from a config.Config.
+type ConfigEnv struct {
+	Base     []string
+	Settings EnvSettings
+	// Filter reduces Base to the variables passed on; nil keeps the
+	// ui.GetAllowedEnvVars whitelist.
+	Filter func(env []string) []string
+}
+
+// NewConfigEnv returns a builder using cfg's keys and tier models.
+func NewConfigEnv(cfg *config.Config, base []string) ConfigEnv {
+	return ConfigEnv{Base: base, Settings: configSettings{cfg}}
+}
+
+// Build returns the filtered base environment plus the auth token, timeout,
+// tier model and extra variables for be, and the base URL. The base URL is
+// set even when empty, so one inherited from Base is never used.
+func (e ConfigEnv) Build(be backend.Backend, baseURL string) ([]string, error) {
+	var env []string
+	if e.Filter != nil {
+		env = e.Filter(e.Base)
+	} else {
+		env = ui.FilterEnvironment(e.Base, ui.GetAllowedEnvVars())
+	}
+
+	// Ollama needs no key, but Claude Code requires a token with a custom
+	// base URL
+	if apiKey := e.Settings.APIKey(be); apiKey != "" {
+		env = append(env, "ANTHROPIC_AUTH_TOKEN="+apiKey)
+	} else if be.Name == "ollama" {
+		env = append(env, "ANTHROPIC_AUTH_TOKEN=ollama")
+	}
+
+	if be.BaseURL != "" {
+		env = append(env, fmt.Sprintf("API_TIMEOUT_MS=%d", e.Settings.Timeout(be).Milliseconds()))
+	}
+	models, err := e.Settings.TierModels(be)
+	if err != nil {
+		return nil, err
+	}
+	for _, tier := range Tiers {
+		if m := models[tier]; m != "" {
+			env = append(env, fmt.Sprintf("ANTHROPIC_DEFAULT_%s_MODEL=%s", strings.ToUpper(tier), m))
+		}
+	}
+	env = append(env, e.Settings.Extra(be)...)
+
+	return append(env, "ANTHROPIC_BASE_URL="+baseURL), nil
+}
+
+// configSettings reads launch settings from a config.Config.
+type configSettings struct {
+	cfg *config.Config
+}
+
+// APIKey returns the configured key for be.
+func (s configSettings) APIKey(be backend.Backend) string {
+	return s.cfg.Keys[be.AuthVar]
+}
+
+// Timeout returns the backend's default timeout.
+func (s configSettings) Timeout(be backend.Backend) time.Duration {
+	return be.Timeout
+}
+
+// TierModels returns the backend defaults with any models configured for it
+// applied over them. Backends without a base URL keep Claude Code's defaults.
+func (s configSettings) TierModels(be backend.Backend) (map[string]string, error) {
+	if be.BaseURL == "" {
+		return nil, nil
+	}
+	var overrides map[string]string
+	switch be.Name {
+	case "ollama":
+		overrides = s.cfg.OllamaModels
+	case "zai":
+		overrides = s.cfg.ZAIModels
+	case "kimi":
+		overrides = s.cfg.KimiModels
+	}
+	models := map[string]string{"haiku": be.HaikuModel, "sonnet": be.SonnetModel, "opus": be.OpusModel}
+	for _, tier := range Tiers {
+		if m := strings.TrimSpace(overrides[tier]); m != "" {
+			models[tier] = m
+		}
+	}
+	return models, nil
+}
+
+// Extra returns nothing; config.Config has no further launch settings.
+func (s configSettings) Extra(be backend.Backend) []string {
+	return nil
+}
+
+// OllamaProxies returns a factory starting the translation proxy for Ollama.
+// The proxy keeps the configured API keys and other credentials out of
+// prompts sent to the local model.
+func OllamaProxies(cfg *config.Config) ProxyFactory {
+	return func(be backend.Backend) Proxy {
+		if be.Name != "ollama" {
+			return nil
+		}
+		secrets := make([]string, 0, len(cfg.Keys))
+		for _, key := range cfg.Keys {
+			secrets = append(secrets, key)
+		}
+		return proxy.New(be.BaseURL, proxy.Options{
+			Backend:        be.Name,
+			ModelMap:       proxy.BuildModelMap(cfg.OllamaModels),
+			Secrets:        secrets,
+			RedactPatterns: proxy.DefaultRedactPatterns,
+		})
+	}
+}
+
+// ExecRunner runs processes attached to the given streams.
+type ExecRunner struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Run runs name and returns its exit code.
+func (r ExecRunner) Run(name string, args, env []string) (int, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = r.Stdin, r.Stdout, r.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,287 @@
+// Package launch_test provides tests for the launch package.
+package launch_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/backend"
+	"nexus/internal/config"
+	"nexus/internal/launch"
+)
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Keys:         map[string]string{},
+		YoloModes:    map[string]bool{},
+		OllamaModels: map[string]string{},
+		ZAIModels:    map[string]string{},
+		KimiModels:   map[string]string{},
+	}
+}
+
+// envMap turns NAME=value pairs into a map, failing on duplicates
+func envMap(t *testing.T, env []string) map[string]string {
+	t.Helper()
+	m := make(map[string]string)
+	for _, e := range env {
+		name, value, _ := strings.Cut(e, "=")
+		if _, dup := m[name]; dup {
+			t.Errorf("Duplicate environment variable %s", name)
+		}
+		m[name] = value
+	}
+	return m
+}
+
+// buildEnv builds the launch environment for be from cfg
+func buildEnv(t *testing.T, cfg *config.Config, base []string, be backend.Backend, baseURL string) map[string]string {
+	t.Helper()
+	env, err := launch.NewConfigEnv(cfg, base).Build(be, baseURL)
+	if err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+	return envMap(t, env)
+}
+
+// ============================================================================
+// Environment Tests
+// ============================================================================
+
+func TestConfigEnvEveryBackend(t *testing.T) {
+	registry := backend.NewRegistry()
+	for _, name := range registry.GetOrdered() {
+		be, _ := registry.Get(name)
+		t.Run(name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.Keys[be.AuthVar] = "key-" + name
+			env := buildEnv(t, cfg, nil, be, be.BaseURL)
+
+			if env["ANTHROPIC_AUTH_TOKEN"] != "key-"+name {
+				t.Errorf("ANTHROPIC_AUTH_TOKEN = %q", env["ANTHROPIC_AUTH_TOKEN"])
+			}
+			if be.BaseURL == "" {
+				// Claude Code talks to Anthropic with its own defaults
+				for _, v := range []string{"ANTHROPIC_BASE_URL", "API_TIMEOUT_MS", "ANTHROPIC_DEFAULT_SONNET_MODEL"} {
+					if env[v] != "" {
+						t.Errorf("Expected %s empty for %s, got %q", v, name, env[v])
+					}
+				}
+				return
+			}
+			if env["ANTHROPIC_BASE_URL"] != be.BaseURL {
+				t.Errorf("ANTHROPIC_BASE_URL = %q, want %q", env["ANTHROPIC_BASE_URL"], be.BaseURL)
+			}
+			if want := fmt.Sprint(be.Timeout.Milliseconds()); env["API_TIMEOUT_MS"] != want {
+				t.Errorf("API_TIMEOUT_MS = %q, want %q", env["API_TIMEOUT_MS"], want)
+			}
+			for tier, want := range map[string]string{"HAIKU": be.HaikuModel, "SONNET": be.SonnetModel, "OPUS": be.OpusModel} {
+				if got := env["ANTHROPIC_DEFAULT_"+tier+"_MODEL"]; got != want {
+					t.Errorf("ANTHROPIC_DEFAULT_%s_MODEL = %q, want %q", tier, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigEnvFiltersBase(t *testing.T) {
+	registry := backend.NewRegistry()
+	be, _ := registry.Get("deepseek")
+	cfg := testConfig()
+	base := []string{"PATH=/usr/bin", "HOME=/home/dev", "AWS_SECRET_ACCESS_KEY=secret", "OPENAI_API_KEY=sk-other"}
+	env := buildEnv(t, cfg, base, be, be.BaseURL)
+
+	if env["PATH"] != "/usr/bin" || env["HOME"] != "/home/dev" {
+		t.Errorf("Expected whitelisted variables kept, got %v", env)
+	}
+	for _, v := range []string{"AWS_SECRET_ACCESS_KEY", "OPENAI_API_KEY"} {
+		if _, ok := env[v]; ok {
+			t.Errorf("Expected %s filtered out", v)
+		}
+	}
+	if _, ok := env["ANTHROPIC_AUTH_TOKEN"]; ok {
+		t.Error("Expected no auth token without a configured key")
+	}
+}
+
+func TestConfigEnvOllama(t *testing.T) {
+	registry := backend.NewRegistry()
+	be, _ := registry.Get("ollama")
+	cfg := testConfig()
+	cfg.OllamaModels["sonnet"] = " qwen2.5-coder:14b "
+	env := buildEnv(t, cfg, nil, be, "http://localhost:18080")
+
+	// No key is needed, but Claude Code requires a token with a custom base URL
+	if env["ANTHROPIC_AUTH_TOKEN"] != "ollama" {
+		t.Errorf("ANTHROPIC_AUTH_TOKEN = %q", env["ANTHROPIC_AUTH_TOKEN"])
+	}
+	if env["ANTHROPIC_BASE_URL"] != "http://localhost:18080" {
+		t.Errorf("Expected the proxy URL, got %q", env["ANTHROPIC_BASE_URL"])
+	}
+	if env["ANTHROPIC_DEFAULT_SONNET_MODEL"] != "qwen2.5-coder:14b" || env["ANTHROPIC_DEFAULT_HAIKU_MODEL"] != be.HaikuModel {
+		t.Errorf("Unexpected tier models %v", env)
+	}
+}
+
+func TestConfigEnvTierOverrides(t *testing.T) {
+	registry := backend.NewRegistry()
+	cfg := testConfig()
+	cfg.ZAIModels["opus"] = "glm-5"
+	cfg.KimiModels["haiku"] = "kimi-lite"
+
+	zai, _ := registry.Get("zai")
+	if opus := buildEnv(t, cfg, nil, zai, zai.BaseURL)["ANTHROPIC_DEFAULT_OPUS_MODEL"]; opus != "glm-5" {
+		t.Errorf("Expected the Z.AI opus override, got %q", opus)
+	}
+	kimi, _ := registry.Get("kimi")
+	env := buildEnv(t, cfg, nil, kimi, kimi.BaseURL)
+	if env["ANTHROPIC_DEFAULT_HAIKU_MODEL"] != "kimi-lite" || env["ANTHROPIC_DEFAULT_SONNET_MODEL"] != kimi.SonnetModel {
+		t.Errorf("Unexpected Kimi models %v", env)
+	}
+	deepseek, _ := registry.Get("deepseek")
+	env = buildEnv(t, cfg, nil, deepseek, deepseek.BaseURL)
+	if env["ANTHROPIC_DEFAULT_HAIKU_MODEL"] != deepseek.HaikuModel || env["ANTHROPIC_DEFAULT_OPUS_MODEL"] != deepseek.OpusModel {
+		t.Error("Expected backend defaults without overrides")
+	}
+}
+
+// settingsStub supplies fixed launch settings
+type settingsStub struct {
+	models map[string]string
+	err    error
+}
+
+func (s settingsStub) APIKey(backend.Backend) string         { return "key" }
+func (s settingsStub) Timeout(backend.Backend) time.Duration { return 90 * time.Second }
+func (s settingsStub) TierModels(backend.Backend) (map[string]string, error) {
+	return s.models, s.err
+}
+func (s settingsStub) Extra(backend.Backend) []string { return []string{"EXTRA=1"} }
+
+func TestConfigEnvSettings(t *testing.T) {
+	registry := backend.NewRegistry()
+	claude, _ := registry.Get("claude")
+	// Only the tiers the settings name are set
+	settings := settingsStub{models: map[string]string{"opus": "claude-opus-4-1"}}
+	env, err := launch.ConfigEnv{Settings: settings}.Build(claude, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := envMap(t, env)
+	if got["ANTHROPIC_DEFAULT_OPUS_MODEL"] != "claude-opus-4-1" || got["EXTRA"] != "1" {
+		t.Errorf("Unexpected environment %v", got)
+	}
+	for _, v := range []string{"ANTHROPIC_DEFAULT_SONNET_MODEL", "API_TIMEOUT_MS"} {
+		if _, ok := got[v]; ok {
+			t.Errorf("Expected %s unset", v)
+		}
+	}
+	if url, ok := got["ANTHROPIC_BASE_URL"]; !ok || url != "" {
+		t.Errorf("Expected an empty base URL to override an inherited one, got %q", url)
+	}
+
+	zai, _ := registry.Get("zai")
+	env, _ = launch.ConfigEnv{Settings: settings}.Build(zai, zai.BaseURL)
+	if envMap(t, env)["API_TIMEOUT_MS"] != "90000" {
+		t.Errorf("Expected the settings timeout, got %v", env)
+	}
+
+	settings.err = errors.New("invalid opus model name")
+	if _, err := (launch.ConfigEnv{Settings: settings}).Build(zai, zai.BaseURL); err == nil {
+		t.Error("Expected the settings error returned")
+	}
+}
+
+// ============================================================================
+// Launcher Tests
+// ============================================================================
+
+func newTestLauncher(cfg *config.Config, proxy *launch.FakeProxy) (*launch.Launcher, *launch.FakeRunner) {
+	runner := &launch.FakeRunner{}
+	return &launch.Launcher{
+		Config:  cfg,
+		Env:     launch.NewConfigEnv(cfg, nil),
+		Runner:  runner,
+		Proxies: launch.FakeProxies(proxy, "ollama"),
+	}, runner
+}
+
+func TestLaunchDirectBackend(t *testing.T) {
+	registry := backend.NewRegistry()
+	be, _ := registry.Get("kimi")
+	cfg := testConfig()
+	cfg.Keys[be.AuthVar] = "key"
+	cfg.YoloModes["kimi"] = true
+	proxy := &launch.FakeProxy{}
+	l, runner := newTestLauncher(cfg, proxy)
+	runner.ExitCode = 3
+
+	code, err := l.Launch(be, []string{"--resume", "bad\x00arg"})
+	if err != nil || code != 3 {
+		t.Fatalf("Launch() = %d, %v; want exit code 3", code, err)
+	}
+	if proxy.Started {
+		t.Error("Expected no proxy for a direct backend")
+	}
+	if len(runner.Runs) != 1 {
+		t.Fatalf("Expected one run, got %d", len(runner.Runs))
+	}
+	run := runner.Runs[0]
+	if run.Name != launch.Command {
+		t.Errorf("Expected %s run, got %s", launch.Command, run.Name)
+	}
+	if want := "--dangerously-skip-permissions --resume badarg"; strings.Join(run.Args, " ") != want {
+		t.Errorf("Args = %q, want %q", strings.Join(run.Args, " "), want)
+	}
+	if envMap(t, run.Env)["ANTHROPIC_BASE_URL"] != be.BaseURL {
+		t.Errorf("Expected the backend URL, got %v", run.Env)
+	}
+}
+
+func TestLaunchProxiedBackend(t *testing.T) {
+	registry := backend.NewRegistry()
+	be, _ := registry.Get("ollama")
+	proxy := &launch.FakeProxy{}
+	l, runner := newTestLauncher(testConfig(), proxy)
+	var out bytes.Buffer
+	l.Out = &out
+	runner.Err = errors.New("claude: executable file not found")
+
+	if _, err := l.Launch(be, nil); err == nil {
+		t.Error("Expected the run error returned")
+	}
+	port := launch.ProxyPorts["ollama"]
+	if !proxy.Started || proxy.Port != port || !proxy.Stopped {
+		t.Errorf("Expected the proxy started on %d and stopped, got %+v", port, proxy)
+	}
+	want := fmt.Sprintf("http://127.0.0.1:%d", port)
+	if got := envMap(t, runner.Runs[0].Env)["ANTHROPIC_BASE_URL"]; got != want {
+		t.Errorf("ANTHROPIC_BASE_URL = %q, want %q", got, want)
+	}
+	if !strings.Contains(out.String(), "Started Anthropic-to-OpenAI proxy") {
+		t.Errorf("Expected the proxy announced, got %q", out.String())
+	}
+}
+
+func TestLaunchProxyStartFailure(t *testing.T) {
+	registry := backend.NewRegistry()
+	be, _ := registry.Get("ollama")
+	proxy := &launch.FakeProxy{StartErr: errors.New("port in use")}
+	l, runner := newTestLauncher(testConfig(), proxy)
+
+	code, err := l.Launch(be, nil)
+	if err == nil || code == 0 {
+		t.Fatalf("Launch() = %d, %v; want a failure", code, err)
+	}
+	if len(runner.Runs) != 0 {
+		t.Error("Expected Claude Code not run without its proxy")
+	}
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the number of answers a Cache keeps when no size is
+// given.
+const DefaultCacheSize = 256
+
+// Cache answers repeated identical upstream requests from memory for ttl,
+// keeping at most size answers and evicting the least recently used.
+// Streamed and plain requests share entries; a streamed answer is cached
+// once it has been read to the end.
+type Cache struct {
+	ttl   time.Duration
+	size  int
+	mu    sync.Mutex
+	order *list.List // of *cacheEntry, most recently used first
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	message AnthropicResponse
+	expires time.Time
+}
+
+// NewCache returns a cache for answers younger than ttl.
+func NewCache(ttl time.Duration, size int) *Cache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &Cache{ttl: ttl, size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// cacheKey identifies an upstream request regardless of streaming.
+func cacheKey(req *Request) string {
+	up := req.Upstream
+	up.Stream, up.StreamOptions = false, nil
+	data, _ := json.Marshal(up)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) get(key string, now time.Time) (AnthropicResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return AnthropicResponse{}, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if now.After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return AnthropicResponse{}, false
+	}
+	c.order.MoveToFront(el)
+	return entry.message, true
+}
+
+func (c *Cache) put(key string, message AnthropicResponse, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, message: message, expires: now.Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of cached answers, including expired ones not yet
+// evicted.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Middleware serves cached answers and caches complete new ones. Answers cut
+// short by max_tokens are not cached.
+func (c *Cache) Middleware(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		key := cacheKey(req)
+		if message, ok := c.get(key, time.Now()); ok {
+			message.ID = generateID()
+			message.Model = req.Incoming.Model
+			return &Response{Message: message, Cached: true}, nil
+		}
+
+		resp, err := next.Handle(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		if resp.Stream == nil {
+			if resp.Message.StopReason == "end_turn" {
+				c.put(key, resp.Message, time.Now())
+			}
+			return resp, nil
+		}
+		resp.Stream = &teeStream{Stream: resp.Stream, onEnd: func(text string, s Stream) {
+			if s.StopReason() != "end_turn" {
+				return
+			}
+			message := resp.Message
+			message.Content = []AnthropicContent{{Type: "text", Text: text}}
+			message.StopReason = s.StopReason()
+			message.Usage = s.Usage()
+			c.put(key, message, time.Now())
+		}}
+		return resp, nil
+	})
+}
+
+// CacheResponses caches answers for ttl, keeping at most size of them.
+func CacheResponses(ttl time.Duration, size int) Middleware {
+	if ttl <= 0 {
+		return nil
+	}
+	return NewCache(ttl, size).Middleware
+}
+
+// teeStream collects the text of a stream and hands it to onEnd once the
+// stream is exhausted.
+type teeStream struct {
+	Stream
+	text  []byte
+	onEnd func(text string, s Stream)
+	done  bool
+}
+
+func (s *teeStream) Next() (string, error) {
+	text, err := s.Stream.Next()
+	s.text = append(s.text, text...)
+	if errors.Is(err, io.EOF) && !s.done {
+		s.done = true
+		s.onEnd(string(s.text), s.Stream)
+	}
+	return text, err
+}
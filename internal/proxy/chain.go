@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Request is a messages request on its way through the middleware chain.
+type Request struct {
+	// Backend names the backend the proxy serves, for logging and
+	// recording.
+	Backend string
+	// Incoming is the request as the client sent it.
+	Incoming AnthropicRequest
+	// Upstream is the chat completions request the transport sends. It is
+	// built by Translate; later middleware may edit it.
+	Upstream OpenAIRequest
+}
+
+// Response is the answer to a messages request. A streamed answer's text is
+// read from Stream; Message then has no content yet.
+type Response struct {
+	Message AnthropicResponse
+	Stream  Stream
+	// Cached is set when the answer came from the cache instead of upstream.
+	Cached bool
+}
+
+// Stream yields the text of a streamed answer.
+type Stream interface {
+	// Next returns the next piece of text, or io.EOF after the last one.
+	Next() (string, error)
+	// Usage returns the token usage, complete once Next has returned io.EOF.
+	Usage() AnthropicUsage
+	// StopReason returns why the answer ended, once Next has returned io.EOF.
+	StopReason() string
+	Close() error
+}
+
+// Handler answers messages requests. The transport is the core Handler and
+// each middleware wraps another.
+type Handler interface {
+	Handle(ctx context.Context, req *Request) (*Response, error)
+}
+
+// HandlerFunc adapts a function to Handler.
+type HandlerFunc func(ctx context.Context, req *Request) (*Response, error)
+
+// Handle calls f.
+func (f HandlerFunc) Handle(ctx context.Context, req *Request) (*Response, error) {
+	return f(ctx, req)
+}
+
+// Middleware adds behaviour around a Handler.
+type Middleware func(next Handler) Handler
+
+// Chain wraps h in middleware. The first middleware is outermost, so it sees
+// requests first and responses last. Nil middleware is skipped.
+func Chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		if middleware[i] != nil {
+			h = middleware[i](h)
+		}
+	}
+	return h
+}
+
+// Error is a failed request, answered to the client with Status.
+type Error struct {
+	Status  int
+	Message string
+	// Retryable is set for failures worth retrying: network errors, 429
+	// and 5xx responses.
+	Retryable bool
+	// RetryAfter is how long the upstream asked clients to wait, if it did.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("upstream error %d: %s", e.Status, e.Message)
+}
+
+// isRetryableStatus reports whether an upstream status is worth retrying.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// textStream replays text already known, such as a cached answer.
+type textStream struct {
+	text       string
+	usage      AnthropicUsage
+	stopReason string
+	done       bool
+}
+
+func (s *textStream) Next() (string, error) {
+	if s.done || s.text == "" {
+		s.done = true
+		return "", io.EOF
+	}
+	s.done = true
+	return s.text, nil
+}
+
+func (s *textStream) Usage() AnthropicUsage { return s.usage }
+func (s *textStream) StopReason() string    { return s.stopReason }
+func (s *textStream) Close() error          { return nil }
+
+// messageText returns the text content of an answer.
+func messageText(m AnthropicResponse) string {
+	text := ""
+	for _, c := range m.Content {
+		text += c.Text
+	}
+	return text
+}
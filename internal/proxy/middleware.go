@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Translate builds the upstream chat completions request from the client's
+// messages request, mapping model names through modelMap. It must run
+// before middleware that reads or edits the upstream request.
+func Translate(modelMap map[string]string) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			in := req.Incoming
+			model := in.Model
+			if mapped, ok := modelMap[model]; ok {
+				model = mapped
+			}
+			up := OpenAIRequest{
+				Model:       model,
+				MaxTokens:   in.MaxTokens,
+				Temperature: 0.7,
+				TopP:        1.0,
+				Stream:      in.Stream,
+			}
+			if in.Stream {
+				up.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+			}
+			if in.Temperature != nil {
+				up.Temperature = *in.Temperature
+			}
+			if in.TopP != nil {
+				up.TopP = *in.TopP
+			}
+			if system := in.GetSystemText(); system != "" {
+				up.Messages = append(up.Messages, OpenAIMessage{Role: "system", Content: system})
+			}
+			for _, msg := range in.Messages {
+				up.Messages = append(up.Messages, OpenAIMessage{Role: msg.Role, Content: msg.GetContentText()})
+			}
+			req.Upstream = up
+			return next.Handle(ctx, req)
+		})
+	}
+}
+
+// Redacted replaces secrets removed from prompts.
+const Redacted = "[REDACTED]"
+
+// minSecretLength keeps short values, which would match ordinary text, from
+// being redacted.
+const minSecretLength = 8
+
+// DefaultRedactPatterns match common credential formats: provider API keys,
+// AWS access keys, GitHub tokens and private key blocks.
+var DefaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+}
+
+// Redact replaces secrets, and text matching patterns, in the upstream
+// messages so they don't leave the machine. Secrets shorter than 8
+// characters are ignored.
+func Redact(secrets []string, patterns []*regexp.Regexp) Middleware {
+	var literals []string
+	for _, s := range secrets {
+		if len(s) >= minSecretLength {
+			literals = append(literals, s)
+		}
+	}
+	if len(literals) == 0 && len(patterns) == 0 {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			for i, msg := range req.Upstream.Messages {
+				for _, s := range literals {
+					msg.Content = strings.ReplaceAll(msg.Content, s, Redacted)
+				}
+				for _, p := range patterns {
+					msg.Content = p.ReplaceAllString(msg.Content, Redacted)
+				}
+				req.Upstream.Messages[i] = msg
+			}
+			return next.Handle(ctx, req)
+		})
+	}
+}
+
+// UsageRecorder is called with the token usage of each upstream answer.
+type UsageRecorder func(backend, model string, usage AnthropicUsage)
+
+// RecordUsage calls record with the usage of each answer that reports any,
+// once a streamed answer has ended.
+func RecordUsage(record UsageRecorder) Middleware {
+	if record == nil {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			resp, err := next.Handle(ctx, req)
+			if err != nil || resp.Cached {
+				return resp, err
+			}
+			report := func(usage AnthropicUsage) {
+				if usage.InputTokens+usage.OutputTokens > 0 {
+					record(req.Backend, req.Upstream.Model, usage)
+				}
+			}
+			if resp.Stream == nil {
+				report(resp.Message.Usage)
+				return resp, nil
+			}
+			resp.Stream = &endStream{Stream: resp.Stream, onEnd: func(s Stream) { report(s.Usage()) }}
+			return resp, nil
+		})
+	}
+}
+
+// endStream calls onEnd once, when the wrapped stream is exhausted.
+type endStream struct {
+	Stream
+	onEnd func(Stream)
+	once  sync.Once
+}
+
+func (s *endStream) Next() (string, error) {
+	text, err := s.Stream.Next()
+	if errors.Is(err, io.EOF) {
+		s.once.Do(func() { s.onEnd(s.Stream) })
+	}
+	return text, err
+}
+
+// Retry retries requests that failed with a retryable Error up to retries
+// times, waiting delay before the first retry and doubling it after each,
+// or as long as the upstream asked. Streams are only retried before they
+// start, since the transport fails before returning one.
+func Retry(retries int, delay time.Duration) Middleware {
+	if retries <= 0 {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			wait := delay
+			for attempt := 0; ; attempt++ {
+				resp, err := next.Handle(ctx, req)
+				var e *Error
+				if err == nil || attempt == retries || !errors.As(err, &e) || !e.Retryable {
+					return resp, err
+				}
+				pause := max(wait, e.RetryAfter)
+				select {
+				case <-ctx.Done():
+					return nil, err
+				case <-time.After(pause):
+				}
+				wait *= 2
+			}
+		})
+	}
+}
+
+// RateLimiter lets at most a set number of requests start in any window,
+// holding the rest until a slot frees up.
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+	mu     sync.Mutex
+	starts []time.Time
+}
+
+// NewRateLimiter returns a limiter for limit requests per window.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{limit: limit, window: window}
+}
+
+// Wait blocks until a request may start, or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		for len(l.starts) > 0 && now.Sub(l.starts[0]) >= l.window {
+			l.starts = l.starts[1:]
+		}
+		if len(l.starts) < l.limit {
+			l.starts = append(l.starts, now)
+			l.mu.Unlock()
+			return nil
+		}
+		wait := l.window - now.Sub(l.starts[0])
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return &Error{Status: http.StatusTooManyRequests, Message: "local rate limit: " + ctx.Err().Error()}
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Middleware holds each request until the limiter lets it start.
+func (l *RateLimiter) Middleware(next Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+		if err := l.Wait(ctx); err != nil {
+			return nil, err
+		}
+		return next.Handle(ctx, req)
+	})
+}
+
+// RateLimit lets at most perMinute requests reach the upstream in any
+// minute.
+func RateLimit(perMinute int) Middleware {
+	if perMinute <= 0 {
+		return nil
+	}
+	return NewRateLimiter(perMinute, time.Minute).Middleware
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"regexp"
+	"time"
+)
+
+// Options configures the middleware a proxy runs for one backend. The zero
+// value only translates requests.
+type Options struct {
+	// Backend names the backend, as passed to RecordUsage.
+	Backend string
+	// ModelMap maps client model names to upstream ones; nil uses the
+	// default Ollama models.
+	ModelMap map[string]string
+
+	// Secrets and RedactPatterns are removed from prompts.
+	Secrets        []string
+	RedactPatterns []*regexp.Regexp
+
+	// CacheTTL enables the response cache; CacheSize bounds it.
+	CacheTTL  time.Duration
+	CacheSize int
+
+	// RateLimit caps upstream requests per minute; 0 means no limit.
+	RateLimit int
+
+	// Retries is how often failed requests are retried, RetryDelay how long
+	// to wait before the first retry.
+	Retries    int
+	RetryDelay time.Duration
+
+	// RecordUsage, if set, receives the usage of each upstream answer.
+	RecordUsage UsageRecorder
+}
+
+// Middleware returns the middleware the options enable, outermost first:
+// translation and redaction run before the cache so it keys on what is
+// actually sent, usage is recorded for upstream answers only, and each retry
+// waits its turn with the rate limiter.
+func (o Options) Middleware() []Middleware {
+	modelMap := o.ModelMap
+	if modelMap == nil {
+		modelMap = defaultModelMap()
+	}
+	return []Middleware{
+		Translate(modelMap),
+		Redact(o.Secrets, o.RedactPatterns),
+		CacheResponses(o.CacheTTL, o.CacheSize),
+		RecordUsage(o.RecordUsage),
+		Retry(o.Retries, o.RetryDelay),
+		RateLimit(o.RateLimit),
+	}
+}
@@ -1,10 +1,15 @@
 // Package proxy provides the Ollama proxy for Anthropic-to-OpenAI translation.
+//
+// The proxy is built as a chain of middleware around a Transport, the core
+// Handler that talks to the upstream: Translate, Redact, CacheResponses,
+// RecordUsage, Retry and RateLimit can each be used on their own, composed
+// with Chain, or enabled per backend through Options.
 package proxy
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -106,8 +111,9 @@ type AnthropicStreamEvent struct {
 
 // AnthropicDelta represents a delta in a stream.
 type AnthropicDelta struct {
-	Type string `json:"type,omitempty"`
-	Text string `json:"text,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Text       string `json:"text,omitempty"`
+	StopReason string `json:"stop_reason,omitempty"`
 }
 
 // OpenAIRequest represents an OpenAI API chat completions request.
@@ -118,6 +124,13 @@ type OpenAIRequest struct {
 	Temperature float64         `json:"temperature,omitempty"`
 	TopP        float64         `json:"top_p,omitempty"`
 	Stream      bool            `json:"stream,omitempty"`
+	// StreamOptions asks for usage in the last chunk of a stream.
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+// OpenAIStreamOptions configures a streamed chat completion.
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // OpenAIMessage represents an OpenAI message.
@@ -158,43 +171,69 @@ type OpenAIStreamEvent struct {
 	Created int64          `json:"created"`
 	Model   string         `json:"model"`
 	Choices []OpenAIChoice `json:"choices"`
+	Usage   *OpenAIUsage   `json:"usage,omitempty"`
 }
 
 // OllamaProxy is the proxy server that translates Anthropic to OpenAI.
+// Messages requests go through a Handler chain ending in a Transport; other
+// paths are passed through unchanged.
 type OllamaProxy struct {
 	ollamaBaseURL string
+	backend       string
 	server        *http.Server
-	modelMap      map[string]string
+	handler       Handler
+	mux           *http.ServeMux
 }
 
-// NewOllamaProxy creates a new proxy instance.
-func NewOllamaProxy(ollamaBaseURL string, modelMap map[string]string) *OllamaProxy {
-	if modelMap == nil {
-		modelMap = map[string]string{
-			"llama3.2":    "llama3.2:latest",
-			"llama3.2:3b": "llama3.2:3b",
-			"codellama":   "codellama:latest",
-			"phi3":        "phi3:latest",
-			"mistral":     "mistral:latest",
-			"llama3.3":    "llama3.3:latest",
-		}
-	}
-	return &OllamaProxy{
-		ollamaBaseURL: ollamaBaseURL,
-		modelMap:      modelMap,
+// defaultModelMap maps the common Ollama model names to their latest tags.
+func defaultModelMap() map[string]string {
+	return map[string]string{
+		"llama3.2":    "llama3.2:latest",
+		"llama3.2:3b": "llama3.2:3b",
+		"codellama":   "codellama:latest",
+		"phi3":        "phi3:latest",
+		"mistral":     "mistral:latest",
+		"llama3.3":    "llama3.3:latest",
 	}
 }
 
+// New creates a proxy for the OpenAI-compatible endpoint at baseURL running
+// the middleware opts enables.
+func New(baseURL string, opts Options) *OllamaProxy {
+	p := &OllamaProxy{
+		ollamaBaseURL: baseURL,
+		backend:       opts.Backend,
+		handler:       Chain(NewTransport(baseURL), opts.Middleware()...),
+		mux:           http.NewServeMux(),
+	}
+	p.mux.HandleFunc("/v1/models", p.handleModels)
+	p.mux.HandleFunc("/v1/messages", p.handleMessages)
+	p.mux.HandleFunc("/", p.handleProxy)
+	return p
+}
+
+// NewOllamaProxy creates a new proxy instance that only translates requests.
+func NewOllamaProxy(ollamaBaseURL string, modelMap map[string]string) *OllamaProxy {
+	return New(ollamaBaseURL, Options{ModelMap: modelMap})
+}
+
+// Use wraps the proxy's handler in more middleware, outside what it already
+// runs. It must be called before the proxy serves requests.
+func (p *OllamaProxy) Use(middleware ...Middleware) {
+	p.handler = Chain(p.handler, middleware...)
+}
+
+// ServeHTTP serves the Anthropic API, so the proxy can be mounted in another
+// server or tested without a listener.
+func (p *OllamaProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
 // Start starts the proxy server on the given port.
 func (p *OllamaProxy) Start(port int) error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/v1/models", p.handleModels)
-	mux.HandleFunc("/v1/messages", p.handleMessages)
-	mux.HandleFunc("/", p.handleProxy)
-
 	p.server = &http.Server{
-		Addr:    fmt.Sprintf("localhost:%d", port),
-		Handler: mux,
+		Addr:    fmt.Sprintf("127.0.0.1:%d", port),
+		Handler: p,
 	}
 
 	// Start server in a goroutine
@@ -236,210 +275,96 @@ func (p *OllamaProxy) handleMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
 	var anthReq AnthropicRequest
-	if err := json.Unmarshal(body, &anthReq); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := json.NewDecoder(r.Body).Decode(&anthReq); err != nil {
+		writeError(w, &Error{Status: http.StatusBadRequest, Message: err.Error()})
 		return
 	}
 
-	model := p.mapModel(anthReq.Model)
-
-	openaiReq := OpenAIRequest{
-		Model:       model,
-		MaxTokens:   anthReq.MaxTokens,
-		Temperature: 0.7,
-		TopP:        1.0,
-		Stream:      anthReq.Stream,
-	}
-
-	if anthReq.Temperature != nil {
-		openaiReq.Temperature = *anthReq.Temperature
-	}
-	if anthReq.TopP != nil {
-		openaiReq.TopP = *anthReq.TopP
-	}
-
-	systemText := anthReq.GetSystemText()
-	if systemText != "" {
-		openaiReq.Messages = append(openaiReq.Messages, OpenAIMessage{
-			Role:    "system",
-			Content: systemText,
-		})
-	}
-
-	for _, msg := range anthReq.Messages {
-		role := msg.Role
-		openaiReq.Messages = append(openaiReq.Messages, OpenAIMessage{
-			Role:    role,
-			Content: msg.GetContentText(),
-		})
-	}
-
-	openaiBody, err := json.Marshal(openaiReq)
+	resp, err := p.handler.Handle(r.Context(), &Request{Backend: p.backend, Incoming: anthReq})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	if anthReq.Stream {
-		p.handleStreaming(w, r, openaiBody)
-	} else {
-		p.handleNonStreaming(w, openaiBody, anthReq.Model)
+	if !anthReq.Stream {
+		if resp.Stream != nil {
+			resp.Stream.Close()
+			writeError(w, &Error{Status: http.StatusInternalServerError, Message: "unexpected stream for a non-streaming request"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp.Message)
+		return
 	}
-}
 
-func (p *OllamaProxy) handleStreaming(w http.ResponseWriter, r *http.Request, openaiBody []byte) {
-	req, err := http.NewRequest("POST", p.ollamaBaseURL+"/chat/completions", bytes.NewReader(openaiBody))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	stream := resp.Stream
+	if stream == nil {
+		stream = &textStream{text: messageText(resp.Message), usage: resp.Message.Usage, stopReason: resp.Message.StopReason}
 	}
-	req.Header.Set("Content-Type", "application/json")
+	defer stream.Close()
+	p.writeStream(w, resp.Message, stream)
+}
 
-	client := &http.Client{Timeout: 0}
-	resp, err := client.Do(req)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// writeStream writes an answer as Anthropic server-sent events.
+func (p *OllamaProxy) writeStream(w http.ResponseWriter, message AnthropicResponse, stream Stream) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, &Error{Status: http.StatusInternalServerError, Message: "Streaming not supported"})
 		return
 	}
-	defer resp.Body.Close()
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.WriteHeader(http.StatusOK)
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
-		return
-	}
-
-	msgStart := AnthropicStreamEvent{
-		Type: "message_start",
-		Message: &AnthropicResponse{
-			ID:      generateID(),
-			Type:    "message",
-			Role:    "assistant",
-			Model:   "unknown",
-			Content: []AnthropicContent{},
-			Usage:   AnthropicUsage{},
-		},
-	}
-	writeSSE(w, msgStart)
-	flusher.Flush()
-
-	blockStart := AnthropicStreamEvent{
-		Type:  "content_block_start",
-		Index: 0,
-		ContentBlock: &AnthropicContent{
-			Type: "text",
-			Text: "",
-		},
-	}
-	writeSSE(w, blockStart)
+	start := message
+	start.Content = []AnthropicContent{}
+	start.StopReason = ""
+	start.Usage = AnthropicUsage{}
+	writeSSE(w, AnthropicStreamEvent{Type: "message_start", Message: &start})
+	writeSSE(w, AnthropicStreamEvent{Type: "content_block_start", Index: 0, ContentBlock: &AnthropicContent{Type: "text", Text: ""}})
 	flusher.Flush()
 
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
+	for {
+		text, err := stream.Next()
+		if text != "" {
+			writeSSE(w, AnthropicStreamEvent{Type: "content_block_delta", Index: 0, Delta: &AnthropicDelta{Type: "text_delta", Text: text}})
+			flusher.Flush()
 		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
+		if err != nil {
 			break
 		}
-
-		var streamEvent OpenAIStreamEvent
-		if err := json.Unmarshal([]byte(data), &streamEvent); err != nil {
-			continue
-		}
-
-		if len(streamEvent.Choices) > 0 && streamEvent.Choices[0].Delta != nil {
-			text := streamEvent.Choices[0].Delta.Content
-			if text != "" {
-				delta := AnthropicStreamEvent{
-					Type:  "content_block_delta",
-					Index: 0,
-					Delta: &AnthropicDelta{
-						Type: "text_delta",
-						Text: text,
-					},
-				}
-				writeSSE(w, delta)
-				flusher.Flush()
-			}
-		}
 	}
 
-	blockStop := AnthropicStreamEvent{
-		Type:  "content_block_stop",
-		Index: 0,
-	}
-	writeSSE(w, blockStop)
-	flusher.Flush()
-
-	msgStop := AnthropicStreamEvent{
-		Type: "message_stop",
-	}
-	writeSSE(w, msgStop)
+	usage := stream.Usage()
+	writeSSE(w, AnthropicStreamEvent{Type: "content_block_stop", Index: 0})
+	writeSSE(w, AnthropicStreamEvent{Type: "message_delta", Delta: &AnthropicDelta{StopReason: stream.StopReason()}, Usage: &usage})
+	writeSSE(w, AnthropicStreamEvent{Type: "message_stop"})
 	flusher.Flush()
 }
 
-func (p *OllamaProxy) handleNonStreaming(w http.ResponseWriter, openaiBody []byte, originalModel string) {
-	req, err := http.NewRequest("POST", p.ollamaBaseURL+"/chat/completions", bytes.NewReader(openaiBody))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Minute}
-	resp, err := client.Do(req)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// writeError answers with an Anthropic error body.
+func writeError(w http.ResponseWriter, err error) {
+	status, errType := http.StatusInternalServerError, "api_error"
+	var e *Error
+	if errors.As(err, &e) {
+		status = e.Status
 	}
-	defer resp.Body.Close()
-
-	var openaiResp OpenAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&openaiResp); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	switch {
+	case status == http.StatusTooManyRequests:
+		errType = "rate_limit_error"
+	case status == http.StatusBadRequest:
+		errType = "invalid_request_error"
 	}
-
-	anthResp := AnthropicResponse{
-		ID:    generateID(),
-		Type:  "message",
-		Role:  "assistant",
-		Model: originalModel,
-		Usage: AnthropicUsage{
-			InputTokens:  openaiResp.Usage.PromptTokens,
-			OutputTokens: openaiResp.Usage.CompletionTokens,
-		},
-	}
-
-	if len(openaiResp.Choices) > 0 {
-		content := openaiResp.Choices[0].Message.Content
-		anthResp.Content = []AnthropicContent{
-			{Type: "text", Text: content},
-		}
-		if openaiResp.Choices[0].FinishReason == "stop" {
-			anthResp.StopReason = "end_turn"
-		}
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(anthResp)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"type": errType, "message": err.Error()},
+	})
 }
 
 func (p *OllamaProxy) handleProxy(w http.ResponseWriter, r *http.Request) {
@@ -483,13 +408,6 @@ func (p *OllamaProxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, resp.Body)
 }
 
-func (p *OllamaProxy) mapModel(model string) string {
-	if mapped, ok := p.modelMap[model]; ok {
-		return mapped
-	}
-	return model
-}
-
 func generateID() string {
 	return fmt.Sprintf("msg_%d", time.Now().UnixNano())
 }
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds non-streamed answers of a Transport.
+const DefaultTimeout = 10 * time.Minute
+
+// maxErrorBody is how much of an upstream error body is kept for the client.
+const maxErrorBody = 1024
+
+// Transport is the core Handler: it sends the upstream request to an
+// OpenAI-compatible chat completions endpoint and decodes the answer.
+type Transport struct {
+	BaseURL string
+	// Client sends requests; nil uses http.DefaultClient. Streams are
+	// bounded by the request context only, other answers also by Timeout.
+	Client  *http.Client
+	Timeout time.Duration
+}
+
+// NewTransport returns a transport for the endpoint at baseURL.
+func NewTransport(baseURL string) *Transport {
+	return &Transport{BaseURL: baseURL, Timeout: DefaultTimeout}
+}
+
+// Handle sends req.Upstream and returns the answer, streamed when the
+// upstream request asks for a stream.
+func (t *Transport) Handle(ctx context.Context, req *Request) (*Response, error) {
+	body, err := json.Marshal(req.Upstream)
+	if err != nil {
+		return nil, &Error{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	var cancel context.CancelFunc
+	if !req.Upstream.Stream && t.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", t.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, &Error{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		cancel()
+		return nil, &Error{Status: http.StatusBadGateway, Message: err.Error(), Retryable: ctx.Err() == nil}
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer cancel()
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		e := &Error{Status: resp.StatusCode, Message: strings.TrimSpace(string(msg)), Retryable: isRetryableStatus(resp.StatusCode)}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return nil, e
+	}
+
+	message := AnthropicResponse{ID: generateID(), Type: "message", Role: "assistant", Model: req.Incoming.Model}
+	if req.Upstream.Stream {
+		return &Response{Message: message, Stream: newChunkStream(resp.Body, cancel)}, nil
+	}
+	defer cancel()
+	defer resp.Body.Close()
+
+	var openaiResp OpenAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&openaiResp); err != nil {
+		return nil, &Error{Status: http.StatusBadGateway, Message: "invalid upstream response: " + err.Error()}
+	}
+	message.Usage = AnthropicUsage{
+		InputTokens:  openaiResp.Usage.PromptTokens,
+		OutputTokens: openaiResp.Usage.CompletionTokens,
+	}
+	if len(openaiResp.Choices) > 0 {
+		message.Content = []AnthropicContent{{Type: "text", Text: openaiResp.Choices[0].Message.Content}}
+		message.StopReason = stopReason(openaiResp.Choices[0].FinishReason)
+	}
+	return &Response{Message: message}, nil
+}
+
+// stopReason maps an OpenAI finish reason to an Anthropic stop reason.
+func stopReason(finish string) string {
+	switch finish {
+	case "length":
+		return "max_tokens"
+	case "":
+		return ""
+	default:
+		return "end_turn"
+	}
+}
+
+// chunkStream reads the text of a streamed chat completion.
+type chunkStream struct {
+	body       io.ReadCloser
+	cancel     context.CancelFunc
+	scanner    *bufio.Scanner
+	usage      AnthropicUsage
+	stopReason string
+}
+
+func newChunkStream(body io.ReadCloser, cancel context.CancelFunc) *chunkStream {
+	return &chunkStream{body: body, cancel: cancel, scanner: bufio.NewScanner(body), stopReason: "end_turn"}
+}
+
+func (s *chunkStream) Next() (string, error) {
+	for s.scanner.Scan() {
+		data, ok := strings.CutPrefix(s.scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			return "", io.EOF
+		}
+		var event OpenAIStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+		if event.Usage != nil {
+			s.usage = AnthropicUsage{InputTokens: event.Usage.PromptTokens, OutputTokens: event.Usage.CompletionTokens}
+		}
+		if len(event.Choices) == 0 {
+			continue
+		}
+		if reason := stopReason(event.Choices[0].FinishReason); reason != "" {
+			s.stopReason = reason
+		}
+		if d := event.Choices[0].Delta; d != nil && d.Content != "" {
+			return d.Content, nil
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+func (s *chunkStream) Usage() AnthropicUsage { return s.usage }
+func (s *chunkStream) StopReason() string    { return s.stopReason }
+
+func (s *chunkStream) Close() error {
+	defer s.cancel()
+	return s.body.Close()
+}
@@ -0,0 +1,361 @@
+// Package tokenizer provides token counting with per-model tokenizer selection.
+//
+// The package ships no exact encoders. Every built-in tokenizer, including
+// O200K and CL100K, is a character-class approximation tuned per model
+// family; bundling the o200k_base and cl100k_base BPE vocabularies is out of
+// scope. Exact encoders (for example a tiktoken implementation) or remote
+// tokenize endpoints can be registered under a family name to replace the
+// approximation for every model mapped to it.
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Built-in tokenizer family names. Each names the encoding its approximation
+// is tuned against, not an implementation of that encoding.
+const (
+	O200K         = "o200k"         // GPT-4o, GPT-4.1, GPT-5, o-series
+	CL100K        = "cl100k"        // GPT-4, GPT-3.5, Kimi, Qwen
+	Claude        = "claude"        // Anthropic models
+	Llama3        = "llama3"        // Llama 3, DeepSeek
+	SentencePiece = "sentencepiece" // Mistral, Gemini, GLM, Grok, Llama 2
+	Default       = O200K
+)
+
+// RemoteTimeout bounds a single remote tokenize request.
+const RemoteTimeout = 5 * time.Second
+
+// maxRemoteResponse caps remote tokenize response bodies.
+const maxRemoteResponse = 10 * 1024 * 1024
+
+// Tokenizer counts tokens in text.
+type Tokenizer interface {
+	Name() string
+	Count(text string) (int, error)
+}
+
+// ============================================================================
+// Heuristic Tokenizer
+// ============================================================================
+
+// Heuristic approximates BPE token counts from character classes. Common
+// words are a single token, longer words split roughly every WordChars
+// letters, digits group in threes, punctuation is mostly one
+// token per character, and CJK text costs about CJKPerRune tokens per rune.
+type Heuristic struct {
+	name       string
+	WordChars  float64
+	CJKPerRune float64
+}
+
+// NewHeuristic creates a heuristic tokenizer.
+func NewHeuristic(name string, wordChars, cjkPerRune float64) *Heuristic {
+	return &Heuristic{name: name, WordChars: wordChars, CJKPerRune: cjkPerRune}
+}
+
+// Name returns the tokenizer name.
+func (h *Heuristic) Name() string {
+	return h.name
+}
+
+// Count returns the estimated token count of text.
+func (h *Heuristic) Count(text string) (int, error) {
+	tokens := 0.0
+	word, digits := 0, 0
+	newline := false
+
+	flushWord := func() {
+		if word > 0 {
+			tokens += math.Max(1, math.Ceil(float64(word)/h.WordChars))
+			word = 0
+		}
+	}
+	flushDigits := func() {
+		if digits > 0 {
+			tokens += math.Ceil(float64(digits) / 3)
+			digits = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || r == '_'):
+			flushDigits()
+			word++
+			newline = false
+		case unicode.IsDigit(r):
+			flushWord()
+			digits++
+			newline = false
+		case r == '\n':
+			flushWord()
+			flushDigits()
+			// Runs of newlines usually merge into one token
+			if !newline {
+				tokens++
+			}
+			newline = true
+		case unicode.IsSpace(r):
+			// Spaces attach to the following word
+			flushWord()
+			flushDigits()
+		case isCJK(r):
+			flushWord()
+			flushDigits()
+			tokens += h.CJKPerRune
+			newline = false
+		case r > unicode.MaxASCII:
+			// Other non-ASCII letters (accented Latin, Cyrillic) split more often
+			flushDigits()
+			word += 2
+			newline = false
+		default:
+			flushWord()
+			flushDigits()
+			tokens++
+			newline = false
+		}
+	}
+	flushWord()
+	flushDigits()
+	return int(math.Ceil(tokens)), nil
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// ============================================================================
+// Remote Tokenizer
+// ============================================================================
+
+// Remote counts tokens with an HTTP tokenize endpoint. The request body is
+// {"model": Model, "content": text}; the response may contain either a
+// "tokens" array (llama.cpp server) or a numeric "count" or "input_tokens".
+type Remote struct {
+	name   string
+	URL    string
+	Model  string
+	Header http.Header
+	Client *http.Client
+}
+
+// NewRemote creates a remote tokenizer posting to url.
+func NewRemote(name, url, model string) *Remote {
+	return &Remote{
+		name:   name,
+		URL:    url,
+		Model:  model,
+		Header: http.Header{},
+		Client: &http.Client{Timeout: RemoteTimeout},
+	}
+}
+
+// Name returns the tokenizer name.
+func (r *Remote) Name() string {
+	return r.name
+}
+
+// Count returns the token count reported by the remote endpoint.
+func (r *Remote) Count(text string) (int, error) {
+	body, err := json.Marshal(map[string]string{"model": r.Model, "content": text})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest("POST", r.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range r.Header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("tokenize request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("tokenize request failed: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Tokens      []json.RawMessage `json:"tokens"`
+		Count       *int              `json:"count"`
+		InputTokens *int              `json:"input_tokens"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRemoteResponse)).Decode(&result); err != nil {
+		return 0, fmt.Errorf("decode tokenize response: %w", err)
+	}
+	switch {
+	case result.Count != nil:
+		return *result.Count, nil
+	case result.InputTokens != nil:
+		return *result.InputTokens, nil
+	case result.Tokens != nil:
+		return len(result.Tokens), nil
+	}
+	return 0, errors.New("tokenize response has no token count")
+}
+
+// ============================================================================
+// Registry
+// ============================================================================
+
+// defaultRules maps model name prefixes to tokenizer families.
+var defaultRules = map[string]string{
+	"gpt-4o":    O200K,
+	"gpt-4.1":   O200K,
+	"gpt-4.5":   O200K,
+	"gpt-5":     O200K,
+	"o1":        O200K,
+	"o3":        O200K,
+	"o4":        O200K,
+	"gpt-4":     CL100K,
+	"gpt-3.5":   CL100K,
+	"kimi":      CL100K,
+	"moonshot":  CL100K,
+	"qwen":      CL100K,
+	"claude":    Claude,
+	"llama3":    Llama3,
+	"llama-3":   Llama3,
+	"deepseek":  Llama3,
+	"llama2":    SentencePiece,
+	"codellama": SentencePiece,
+	"mistral":   SentencePiece,
+	"mixtral":   SentencePiece,
+	"codestral": SentencePiece,
+	"devstral":  SentencePiece,
+	"gemini":    SentencePiece,
+	"gemma":     SentencePiece,
+	"glm":       SentencePiece,
+	"grok":      SentencePiece,
+	"phi":       SentencePiece,
+}
+
+// Registry selects a tokenizer per model by longest matching name prefix.
+// Prefixes added with Map take precedence over the default mappings.
+type Registry struct {
+	mu         sync.RWMutex
+	tokenizers map[string]Tokenizer
+	custom     map[string]string
+	fallback   string
+	// unmapped is used for models no rule matches; fallback when empty.
+	unmapped string
+}
+
+// NewRegistry creates a registry with the built-in approximations and
+// default model mappings. Register an exact encoder under O200K or CL100K to
+// replace the approximation for the OpenAI family.
+func NewRegistry() *Registry {
+	r := &Registry{
+		tokenizers: make(map[string]Tokenizer),
+		custom:     make(map[string]string),
+		fallback:   Default,
+	}
+	r.Register(NewHeuristic(O200K, 6.0, 0.8))
+	r.Register(NewHeuristic(CL100K, 5.5, 1.0))
+	r.Register(NewHeuristic(Claude, 5.0, 1.2))
+	r.Register(NewHeuristic(Llama3, 5.8, 0.9))
+	r.Register(NewHeuristic(SentencePiece, 5.0, 1.0))
+	return r
+}
+
+// Register adds a tokenizer, replacing any existing one with the same name.
+func (r *Registry) Register(t Tokenizer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokenizers[t.Name()] = t
+}
+
+// Map selects the named tokenizer for models starting with prefix.
+func (r *Registry) Map(prefix, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tokenizers[name]; !ok {
+		return fmt.Errorf("unknown tokenizer '%s'", name)
+	}
+	r.custom[strings.ToLower(prefix)] = name
+	return nil
+}
+
+// SetUnmapped selects the named tokenizer for models that neither a Map
+// prefix nor a default mapping covers. Failures still fall back to the
+// default approximation.
+func (r *Registry) SetUnmapped(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tokenizers[name]; !ok {
+		return fmt.Errorf("unknown tokenizer '%s'", name)
+	}
+	r.unmapped = name
+	return nil
+}
+
+// ForModel returns the tokenizer for a model. Provider prefixes such as
+// "openai/gpt-4o" are ignored; unknown models use the SetUnmapped tokenizer
+// or the default family.
+func (r *Registry) ForModel(model string) Tokenizer {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := longestPrefix(r.custom, model)
+	if !ok {
+		name, ok = longestPrefix(defaultRules, model)
+	}
+	if t, found := r.tokenizers[name]; ok && found {
+		return t
+	}
+	if t, found := r.tokenizers[r.unmapped]; found {
+		return t
+	}
+	return r.tokenizers[r.fallback]
+}
+
+// longestPrefix returns the rule with the longest prefix of model.
+func longestPrefix(rules map[string]string, model string) (string, bool) {
+	best, bestLen := "", -1
+	for prefix, name := range rules {
+		if strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = name, len(prefix)
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// Count returns the token count of text for model. If the selected
+// tokenizer fails (for example an unreachable remote endpoint) the default
+// approximation is used and the error is returned alongside the estimate.
+func (r *Registry) Count(model, text string) (int, error) {
+	t := r.ForModel(model)
+	n, err := t.Count(text)
+	if err == nil {
+		return n, nil
+	}
+
+	r.mu.RLock()
+	fallback := r.tokenizers[r.fallback]
+	r.mu.RUnlock()
+	estimate, _ := fallback.Count(text)
+	return estimate, fmt.Errorf("%s tokenizer failed, using estimate: %w", t.Name(), err)
+}
@@ -0,0 +1,256 @@
+// Package tokenizer_test provides tests for the tokenizer package.
+package tokenizer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nexus/internal/tokenizer"
+)
+
+// ============================================================================
+// Heuristic Tests
+// ============================================================================
+
+func TestHeuristicCount(t *testing.T) {
+	h := tokenizer.NewHeuristic("test", 4, 1)
+
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"empty", "", 0},
+		{"single word", "hello", 2},
+		{"short words", "a b c d", 4},
+		{"digits group in threes", "1234567", 3},
+		{"punctuation", "f(x);", 5},
+		{"newline run", "a\n\n\nb", 3},
+		{"cjk", "你好世界", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.Count(tt.text)
+			if err != nil {
+				t.Fatalf("Count() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeuristicProseRatio(t *testing.T) {
+	h := tokenizer.NewHeuristic("test", 6, 0.8)
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+
+	got, _ := h.Count(text)
+	// Real BPE tokenizers produce about 10 tokens per sentence
+	if got < 180 || got > 240 {
+		t.Errorf("Count() = %d for 20 sentences, want about 200", got)
+	}
+}
+
+func TestApproximationsNearKnownCounts(t *testing.T) {
+	r := tokenizer.NewRegistry()
+	// Counts produced by the real o200k_base and cl100k_base encoders. The
+	// built-ins only approximate them, so longer text may differ.
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"hello world", 2},
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+	}
+
+	for _, model := range []string{"gpt-4o", "gpt-4"} {
+		for _, tt := range tests {
+			got, err := r.Count(model, tt.text)
+			if err != nil {
+				t.Fatalf("Count() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Count(%q, %q) = %d, want %d", model, tt.text, got, tt.want)
+			}
+		}
+	}
+}
+
+// ============================================================================
+// Registry Tests
+// ============================================================================
+
+func TestRegistryForModel(t *testing.T) {
+	r := tokenizer.NewRegistry()
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"gpt-4o-mini", tokenizer.O200K},
+		{"gpt-4-turbo", tokenizer.CL100K},
+		{"openai/gpt-4.1", tokenizer.O200K},
+		{"claude-sonnet-4-5-20250929", tokenizer.Claude},
+		{"deepseek-chat", tokenizer.Llama3},
+		{"mistral-large-latest", tokenizer.SentencePiece},
+		{"GLM-4.6", tokenizer.SentencePiece},
+		{"unknown-model", tokenizer.Default},
+		{"", tokenizer.Default},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := r.ForModel(tt.model).Name(); got != tt.want {
+				t.Errorf("ForModel(%q) = %q, want %q", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryMap(t *testing.T) {
+	r := tokenizer.NewRegistry()
+
+	if err := r.Map("my-finetune", tokenizer.Claude); err != nil {
+		t.Fatalf("Map() error: %v", err)
+	}
+	if got := r.ForModel("my-finetune-v2").Name(); got != tokenizer.Claude {
+		t.Errorf("ForModel() = %q, want %q", got, tokenizer.Claude)
+	}
+
+	// Custom mappings win over longer default prefixes
+	if err := r.Map("llama", tokenizer.CL100K); err != nil {
+		t.Fatalf("Map() error: %v", err)
+	}
+	if got := r.ForModel("llama3.3").Name(); got != tokenizer.CL100K {
+		t.Errorf("ForModel() = %q, want %q", got, tokenizer.CL100K)
+	}
+
+	if err := r.Map("x", "missing"); err == nil {
+		t.Error("Map() with unknown tokenizer should fail")
+	}
+}
+
+type fixedTokenizer struct {
+	name string
+	n    int
+	err  error
+}
+
+func (f fixedTokenizer) Name() string              { return f.name }
+func (f fixedTokenizer) Count(string) (int, error) { return f.n, f.err }
+
+func TestRegistryRegisterReplaces(t *testing.T) {
+	r := tokenizer.NewRegistry()
+	r.Register(fixedTokenizer{name: tokenizer.O200K, n: 42})
+
+	n, err := r.Count("gpt-4o", "anything")
+	if err != nil || n != 42 {
+		t.Errorf("Count() = (%d, %v), want (42, nil)", n, err)
+	}
+}
+
+func TestRegistryCountFallback(t *testing.T) {
+	r := tokenizer.NewRegistry()
+	r.Register(fixedTokenizer{name: "broken", err: errors.New("unreachable")})
+	if err := r.Map("local", "broken"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := r.Count("local-model", "hello world")
+	if err == nil {
+		t.Error("Count() should report the tokenizer failure")
+	}
+	if n != 2 {
+		t.Errorf("Count() = %d, want fallback estimate 2", n)
+	}
+}
+
+func TestRegistrySetUnmapped(t *testing.T) {
+	r := tokenizer.NewRegistry()
+	r.Register(fixedTokenizer{name: "remote", n: 42})
+	if err := r.SetUnmapped("remote"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.ForModel("some-local-model").Name(); got != "remote" {
+		t.Errorf("ForModel(unmapped) = %s, want remote", got)
+	}
+	if got := r.ForModel("claude-sonnet-4-5").Name(); got != tokenizer.Claude {
+		t.Errorf("ForModel(mapped) = %s, want %s", got, tokenizer.Claude)
+	}
+	if err := r.SetUnmapped("missing"); err == nil {
+		t.Error("SetUnmapped() should reject unknown tokenizers")
+	}
+}
+
+// ============================================================================
+// Remote Tests
+// ============================================================================
+
+func TestRemoteCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     int
+	}{
+		{"tokens array", `{"tokens":[1,2,3,4]}`, 4},
+		{"count", `{"count":7}`, 7},
+		{"input_tokens", `{"input_tokens":9}`, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&got)
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			remote := tokenizer.NewRemote("remote", server.URL, "llama3")
+			n, err := remote.Count("hello")
+			if err != nil {
+				t.Fatalf("Count() error: %v", err)
+			}
+			if n != tt.want {
+				t.Errorf("Count() = %d, want %d", n, tt.want)
+			}
+			if got["content"] != "hello" || got["model"] != "llama3" {
+				t.Errorf("Unexpected request body: %v", got)
+			}
+		})
+	}
+}
+
+func TestRemoteCountErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"http error", http.StatusInternalServerError, `{}`},
+		{"no count", http.StatusOK, `{"ok":true}`},
+		{"invalid json", http.StatusOK, `not json`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			if _, err := tokenizer.NewRemote("remote", server.URL, "").Count("hello"); err == nil {
+				t.Error("Count() should fail")
+			}
+		})
+	}
+}
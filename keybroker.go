@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Key broker defaults
+const (
+	defaultLeaseTTL = time.Hour
+	// brokerTimeout bounds each broker request so a dead broker fails the
+	// launch quickly instead of hanging it
+	brokerTimeout = 15 * time.Second
+	// minLeaseRenewal bounds how often renewals (and retries) are sent
+	minLeaseRenewal = 30 * time.Second
+)
+
+// Lease is a short-lived, scoped provider credential issued by the key broker
+type Lease struct {
+	ID        string    `json:"lease_id"`
+	APIKey    string    `json:"api_key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LeaseRequest asks the broker for a credential for one backend
+type LeaseRequest struct {
+	Backend    string `json:"backend"`
+	Scope      string `json:"scope,omitempty"`
+	Repo       string `json:"repo,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// KeyBroker is a client for the organization's credential broker:
+//
+//	POST   /v1/leases             issue a lease for a backend
+//	POST   /v1/leases/{id}/renew  extend a lease, returning the new expiry
+//	DELETE /v1/leases/{id}        revoke a lease
+//
+// Requests authenticate with NEXUS_KEY_BROKER_TOKEN as a bearer token.
+type KeyBroker struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func NewKeyBroker(baseURL, token string) *KeyBroker {
+	return &KeyBroker{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: brokerTimeout, Transport: httpClient.Transport},
+	}
+}
+
+// validateBrokerURL requires HTTPS, except for a broker on localhost
+func validateBrokerURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("expected a URL such as https://keys.example.com")
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if host := u.Hostname(); host == "localhost" || host == "127.0.0.1" || host == "::1" {
+			return nil
+		}
+		return fmt.Errorf("the key broker must use https")
+	}
+	return fmt.Errorf("unsupported scheme '%s'", u.Scheme)
+}
+
+// do sends a broker request and decodes a JSON response into out. Error
+// responses are reported by status and the broker's message, never the body
+// as a whole, since it may contain a credential.
+func (b *KeyBroker) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, b.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("key broker unreachable: %s", sanitizeError(err))
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return fmt.Errorf("key broker returned HTTP %d: %s", resp.StatusCode, truncate(e.Error, 200))
+		}
+		return fmt.Errorf("key broker returned HTTP %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid key broker response: %v", err)
+	}
+	return nil
+}
+
+// Acquire requests a new lease
+func (b *KeyBroker) Acquire(r LeaseRequest) (*Lease, error) {
+	var lease Lease
+	if err := b.do("POST", "/v1/leases", r, &lease); err != nil {
+		return nil, err
+	}
+	if lease.ID == "" || lease.APIKey == "" {
+		return nil, fmt.Errorf("invalid key broker response: missing lease_id or api_key")
+	}
+	return &lease, nil
+}
+
+// Renew extends a lease, returning its new expiry
+func (b *KeyBroker) Renew(id string) (time.Time, error) {
+	var resp struct {
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := b.do("POST", "/v1/leases/"+url.PathEscape(id)+"/renew", nil, &resp); err != nil {
+		return time.Time{}, err
+	}
+	return resp.ExpiresAt, nil
+}
+
+// Revoke ends a lease so its credential stops working immediately
+func (b *KeyBroker) Revoke(id string) error {
+	return b.do("DELETE", "/v1/leases/"+url.PathEscape(id), nil, nil)
+}
+
+// LeaseSession holds a lease for the length of a launch, renewing it before
+// it expires and revoking it on Close
+type LeaseSession struct {
+	broker *KeyBroker
+	lease  *Lease
+	ttl    time.Duration
+	notify func(string)
+
+	mu      sync.Mutex
+	expires time.Time
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// startLeaseSession renews lease in the background until Close
+func startLeaseSession(broker *KeyBroker, lease *Lease, ttl time.Duration, notify func(string)) *LeaseSession {
+	s := &LeaseSession{
+		broker:  broker,
+		lease:   lease,
+		ttl:     ttl,
+		notify:  notify,
+		expires: lease.ExpiresAt,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.renewLoop()
+	return s
+}
+
+// Expires returns when the lease currently expires
+func (s *LeaseSession) Expires() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expires
+}
+
+// nextRenewal is half the remaining lifetime, but not sooner than
+// minLeaseRenewal. Leases without an expiry are renewed every half TTL.
+func (s *LeaseSession) nextRenewal(now time.Time) time.Duration {
+	wait := s.ttl / 2
+	if expires := s.Expires(); !expires.IsZero() {
+		wait = expires.Sub(now) / 2
+	}
+	if wait < minLeaseRenewal {
+		return minLeaseRenewal
+	}
+	return wait
+}
+
+func (s *LeaseSession) renewLoop() {
+	defer close(s.done)
+	failing := false
+	for {
+		timer := time.NewTimer(s.nextRenewal(time.Now()))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		expires, err := s.broker.Renew(s.lease.ID)
+		if err != nil {
+			// Report the first failure; later retries stay quiet until one succeeds
+			if !failing && s.notify != nil {
+				s.notify(fmt.Sprintf("Could not renew key lease (expires %s): %v", s.Expires().Local().Format("15:04"), err))
+			}
+			failing = true
+			continue
+		}
+		if failing && s.notify != nil {
+			s.notify("Key lease renewed")
+		}
+		failing = false
+		s.mu.Lock()
+		if !expires.IsZero() {
+			s.expires = expires
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Close stops renewal and revokes the lease. It is safe to call more than
+// once.
+func (s *LeaseSession) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+		err = s.broker.Revoke(s.lease.ID)
+	})
+	return err
+}
+
+// acquireLaunchLease replaces the backend's stored key with a broker lease
+// when organization mode (NEXUS_KEY_BROKER_URL) is on. It returns nil for
+// backends that need no key. The lease is revoked if promptops is terminated.
+func acquireLaunchLease(cfg *Config, be Backend) (*LeaseSession, error) {
+	if cfg.KeyBrokerURL == "" || be.Name == "ollama" {
+		return nil, nil
+	}
+	ttl := cfg.KeyLeaseTTL
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	broker := NewKeyBroker(cfg.KeyBrokerURL, cfg.KeyBrokerToken)
+	lease, err := broker.Acquire(LeaseRequest{
+		Backend:    be.Name,
+		Scope:      cfg.KeyBrokerScope,
+		Repo:       currentGitInfo().Repo,
+		TTLSeconds: int(ttl.Seconds()),
+	})
+	if err != nil {
+		auditLog(cfg, fmt.Sprintf("LEASE_FAILED: %s", be.Name))
+		return nil, fmt.Errorf("failed to lease a %s key: %w", be.DisplayName, err)
+	}
+	cfg.Keys[be.AuthVar] = lease.APIKey
+	auditLog(cfg, fmt.Sprintf("LEASE_ACQUIRED: %s (lease %s)", be.Name, lease.ID))
+
+	session := startLeaseSession(broker, lease, ttl, func(msg string) {
+		fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] "+msg))
+	})
+
+	// Claude Code handles Ctrl-C itself; revoke when promptops is told to stop
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		if _, ok := <-sig; ok {
+			releaseLaunchLease(cfg, be, session)
+			os.Exit(1)
+		}
+	}()
+	return session, nil
+}
+
+// releaseLaunchLease revokes a launch's lease, warning when the broker
+// cannot be reached so the user knows the key lives until it expires
+func releaseLaunchLease(cfg *Config, be Backend, session *LeaseSession) {
+	if err := session.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to revoke key lease %s (it expires %s): %v\n",
+			session.lease.ID, session.Expires().Local().Format("15:04"), err)
+		auditLog(cfg, fmt.Sprintf("LEASE_REVOKE_FAILED: %s (lease %s)", be.Name, session.lease.ID))
+		return
+	}
+	auditLog(cfg, fmt.Sprintf("LEASE_REVOKED: %s (lease %s)", be.Name, session.lease.ID))
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nexus/internal/launch"
+)
+
+// fakeBroker is an in-memory key broker recording the requests it receives
+type fakeBroker struct {
+	mu      sync.Mutex
+	leases  map[string]bool
+	renewed int
+	request LeaseRequest
+	fail    bool
+}
+
+func (b *fakeBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer broker-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid token"}`))
+		return
+	}
+	if b.fail {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":"backend not allowed for scope","api_key":"sk-should-not-leak"}`))
+		return
+	}
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/v1/leases":
+		json.NewDecoder(r.Body).Decode(&b.request)
+		b.leases["lease-1"] = true
+		json.NewEncoder(w).Encode(Lease{ID: "lease-1", APIKey: "sk-leased", ExpiresAt: time.Now().Add(time.Hour)})
+	case r.Method == "POST" && r.URL.Path == "/v1/leases/lease-1/renew":
+		b.renewed++
+		json.NewEncoder(w).Encode(map[string]time.Time{"expires_at": time.Now().Add(2 * time.Hour)})
+	case r.Method == "DELETE" && r.URL.Path == "/v1/leases/lease-1":
+		delete(b.leases, "lease-1")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeBroker() (*fakeBroker, *httptest.Server) {
+	b := &fakeBroker{leases: make(map[string]bool)}
+	return b, httptest.NewServer(b)
+}
+
+func TestValidateBrokerURL(t *testing.T) {
+	for _, good := range []string{"https://keys.example.com", "http://localhost:8200", "http://127.0.0.1:8200"} {
+		if err := validateBrokerURL(good); err != nil {
+			t.Errorf("validateBrokerURL(%q) = %v", good, err)
+		}
+	}
+	for _, bad := range []string{"http://keys.example.com", "ftp://keys", "keys.example.com", ""} {
+		if err := validateBrokerURL(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestKeyBrokerLifecycle(t *testing.T) {
+	fb, server := newFakeBroker()
+	defer server.Close()
+	broker := NewKeyBroker(server.URL+"/", "broker-token")
+
+	lease, err := broker.Acquire(LeaseRequest{Backend: "claude", Scope: "eng", TTLSeconds: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.ID != "lease-1" || lease.APIKey != "sk-leased" || lease.ExpiresAt.IsZero() {
+		t.Errorf("Unexpected lease %+v", lease)
+	}
+	if fb.request.Backend != "claude" || fb.request.Scope != "eng" || fb.request.TTLSeconds != 3600 {
+		t.Errorf("Unexpected request %+v", fb.request)
+	}
+
+	expires, err := broker.Renew(lease.ID)
+	if err != nil || !expires.After(lease.ExpiresAt) {
+		t.Errorf("Renew() = %v, %v", expires, err)
+	}
+	if err := broker.Revoke(lease.ID); err != nil {
+		t.Fatal(err)
+	}
+	if fb.leases["lease-1"] {
+		t.Error("Expected lease to be revoked")
+	}
+}
+
+func TestKeyBrokerErrorsOmitBody(t *testing.T) {
+	fb, server := newFakeBroker()
+	defer server.Close()
+	fb.fail = true
+
+	_, err := NewKeyBroker(server.URL, "broker-token").Acquire(LeaseRequest{Backend: "claude"})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.Contains(err.Error(), "HTTP 403") || !strings.Contains(err.Error(), "backend not allowed") {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if strings.Contains(err.Error(), "sk-should-not-leak") {
+		t.Error("Error must not include the response body")
+	}
+
+	if _, err := NewKeyBroker(server.URL, "wrong").Acquire(LeaseRequest{Backend: "claude"}); err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Errorf("Expected an authorization error, got %v", err)
+	}
+}
+
+func TestLeaseSessionNextRenewal(t *testing.T) {
+	now := time.Now()
+	s := &LeaseSession{lease: &Lease{}, ttl: time.Hour, expires: now.Add(time.Hour)}
+	if got := s.nextRenewal(now); got != 30*time.Minute {
+		t.Errorf("Expected renewal at half the remaining lifetime, got %s", got)
+	}
+	s.expires = now.Add(10 * time.Second)
+	if got := s.nextRenewal(now); got != minLeaseRenewal {
+		t.Errorf("Expected minimum interval, got %s", got)
+	}
+	s.expires = time.Time{}
+	if got := s.nextRenewal(now); got != 30*time.Minute {
+		t.Errorf("Expected half the TTL without an expiry, got %s", got)
+	}
+}
+
+func TestAcquireLaunchLease(t *testing.T) {
+	fb, server := newFakeBroker()
+	defer server.Close()
+	dir := t.TempDir()
+	cfg := newSelfTestConfig(dir)
+	cfg.AuditEnabled = true
+
+	be := backends["claude"]
+	if lease, err := acquireLaunchLease(cfg, be); lease != nil || err != nil {
+		t.Fatalf("Expected no lease without a broker, got %v, %v", lease, err)
+	}
+
+	cfg.KeyBrokerURL = server.URL
+	cfg.KeyBrokerToken = "broker-token"
+	cfg.Keys[be.AuthVar] = "sk-static"
+	if lease, err := acquireLaunchLease(cfg, backends["ollama"]); lease != nil || err != nil {
+		t.Errorf("Expected Ollama not to be leased, got %v, %v", lease, err)
+	}
+
+	lease, err := acquireLaunchLease(cfg, be)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Keys[be.AuthVar] != "sk-leased" {
+		t.Error("Expected the leased key to replace the stored key")
+	}
+	releaseLaunchLease(cfg, be, lease)
+	if fb.leases["lease-1"] {
+		t.Error("Expected lease to be revoked on release")
+	}
+	// Releasing twice does not revoke again
+	releaseLaunchLease(cfg, be, lease)
+
+	audit, _ := os.ReadFile(cfg.AuditLog)
+	for _, want := range []string{"LEASE_ACQUIRED: claude (lease lease-1)", "LEASE_REVOKED: claude (lease lease-1)"} {
+		if !strings.Contains(string(audit), want) {
+			t.Errorf("Expected audit entry %q in:\n%s", want, audit)
+		}
+	}
+	if strings.Contains(string(audit), "sk-leased") {
+		t.Error("Audit log must not contain the leased key")
+	}
+}
+
+func TestAcquireLaunchLeaseFailure(t *testing.T) {
+	fb, server := newFakeBroker()
+	defer server.Close()
+	fb.fail = true
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.KeyBrokerURL = server.URL
+	cfg.KeyBrokerToken = "broker-token"
+	cfg.Keys["ANTHROPIC_API_KEY"] = "sk-static"
+
+	if _, err := acquireLaunchLease(cfg, backends["claude"]); err == nil {
+		t.Fatal("Expected the launch to fail without a lease")
+	}
+}
+
+func TestRunLaunchReleasesLeaseOnError(t *testing.T) {
+	fb, server := newFakeBroker()
+	defer server.Close()
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.KeyBrokerURL = server.URL
+	cfg.KeyBrokerToken = "broker-token"
+	cfg.Keys["ZAI_API_KEY"] = "sk-static"
+	// An invalid tier model fails the launch after the lease is taken
+	cfg.ZAIModels = map[string]string{"sonnet": "bad model!"}
+
+	runner := &launch.FakeRunner{}
+	if code, err := runLaunch(cfg, backends["zai"], nil, func(time.Duration) launch.Runner { return runner }); err == nil || code != 1 {
+		t.Fatalf("Expected the launch to fail, got %d, %v", code, err)
+	}
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	if fb.request.Backend != "zai" {
+		t.Fatal("Expected a lease acquired before the failure")
+	}
+	if fb.leases["lease-1"] {
+		t.Error("Expected the lease revoked when the launch fails")
+	}
+}
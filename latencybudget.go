@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultLatencyBudget is how long the local proxies wait on an upstream
+// when no NEXUS_LATENCY_BUDGET is set: for a whole non-streaming answer, and
+// for the first byte of and any pause within a streamed one
+const defaultLatencyBudget = 10 * time.Minute
+
+// errKindTimeout is the Anthropic error type of a request that ran out of
+// its latency budget
+const errKindTimeout = "timeout_error"
+
+// isTimeout reports whether err is a request running out of time
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout() || errors.Is(err, context.DeadlineExceeded)
+}
+
+// latencyBudgetError explains a request that exceeded the budget
+func latencyBudgetError(be Backend, budget time.Duration) *ProviderError {
+	return &ProviderError{
+		Backend:    be.Name,
+		StatusCode: http.StatusGatewayTimeout,
+		Kind:       errKindTimeout,
+		Message: fmt.Sprintf("%s did not answer within the %s latency budget - retry, raise %s_%s or switch backends",
+			be.DisplayName, budget, timeoutKeyPrefix(timeoutLatency), strings.ToUpper(be.Name)),
+	}
+}
+
+// markTimedOut flags the response as a timeout for the decision log
+func markTimedOut(w http.ResponseWriter) {
+	if sw, ok := w.(*statusWriter); ok {
+		sw.timedOut = true
+	}
+}
+
+// writeTimeoutError answers a request that exceeded the latency budget
+func writeTimeoutError(w http.ResponseWriter, pe *ProviderError) {
+	markTimedOut(w)
+	fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] "+pe.Message))
+	writeAnthropicError(w, pe)
+}
+
+// writeStreamTimeout ends a stream that stalled with an error event
+func writeStreamTimeout(w http.ResponseWriter, pe *ProviderError) {
+	markTimedOut(w)
+	fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] "+pe.Message))
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"type": pe.Kind, "message": pe.Message},
+	})
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stallReader cancels a streamed response once nothing arrives for longer
+// than the budget, since a total timeout would cut long answers short
+type stallReader struct {
+	r       io.Reader
+	budget  time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+func newStallReader(r io.Reader, budget time.Duration, cancel context.CancelFunc) *stallReader {
+	s := &stallReader{r: r, budget: budget}
+	s.timer = time.AfterFunc(budget, func() {
+		s.stalled.Store(true)
+		cancel()
+	})
+	return s
+}
+
+func (s *stallReader) Read(b []byte) (int, error) {
+	n, err := s.r.Read(b)
+	if n > 0 {
+		s.timer.Reset(s.budget)
+	}
+	return n, err
+}
+
+// Stop ends the watch and reports whether the stream stalled
+func (s *stallReader) Stop() bool {
+	s.timer.Stop()
+	return s.stalled.Load()
+}
+
+// recordTimeout logs a request that exceeded the latency budget to the audit
+// log and, flagged as a timeout, to the usage ledger with any usage the
+// upstream reported before it stalled
+func recordTimeout(cfg *Config, record UsageRecord, budget, elapsed time.Duration) {
+	auditLog(cfg, fmt.Sprintf("TIMEOUT: %s %s after %s (latency budget %s)",
+		record.Backend, record.Model, formatDuration(elapsed), budget))
+	if record.SessionID == "" {
+		record.SessionID = currentSessionID(cfg)
+	}
+	record.TimedOut = true
+	appendUsageRecord(cfg, record)
+}
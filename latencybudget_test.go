@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyBudgetConfig(t *testing.T) {
+	cfg := &Config{Timeouts: map[string]time.Duration{
+		timeoutLatency:             2 * time.Minute,
+		timeoutLatency + ":ollama": 20 * time.Minute,
+	}}
+	if got := cfg.latencyBudget("openai"); got != 2*time.Minute {
+		t.Errorf("Expected global latency budget, got %v", got)
+	}
+	if got := cfg.latencyBudget("ollama"); got != 20*time.Minute {
+		t.Errorf("Expected per-backend latency budget, got %v", got)
+	}
+	if got := (&Config{}).latencyBudget("ollama"); got != defaultLatencyBudget {
+		t.Errorf("Expected default latency budget, got %v", got)
+	}
+	if !cfg.hasLatencyBudget("kimi") || (&Config{}).hasLatencyBudget("kimi") {
+		t.Error("hasLatencyBudget should report only configured budgets")
+	}
+
+	// --timeout is for checks and never shortens proxied requests
+	cfg.TimeoutOverride = time.Second
+	if got := cfg.latencyBudget("openai"); got != 2*time.Minute {
+		t.Errorf("Expected latency budget unaffected by --timeout, got %v", got)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	if !isTimeout(context.DeadlineExceeded) || !isTimeout(fmt.Errorf("post: %w", context.DeadlineExceeded)) {
+		t.Error("Expected deadline errors to be timeouts")
+	}
+	if isTimeout(context.Canceled) || isTimeout(fmt.Errorf("connection refused")) {
+		t.Error("Expected other errors not to be timeouts")
+	}
+}
+
+// slowProxy returns a proxy with a short budget in front of handler, and the
+// decisions and timeouts it records
+func slowProxy(t *testing.T, handler http.HandlerFunc) (*OllamaProxy, *[]ProxyDecision, *[]string) {
+	t.Helper()
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	proxy := NewOllamaProxy(upstream.URL, nil)
+	proxy.SetUpstreamAuth("openai", nil, "")
+	proxy.SetLatencyBudget(100 * time.Millisecond)
+	var decisions []ProxyDecision
+	var timeouts []string
+	proxy.SetDecisionRecorder(func(d ProxyDecision) { decisions = append(decisions, d) })
+	proxy.SetTimeoutRecorder(func(model string, usage AnthropicUsage, elapsed time.Duration) {
+		timeouts = append(timeouts, model)
+	})
+	proxy.SetUsageRecorder(func(model string, usage AnthropicUsage) {
+		t.Errorf("Expected no usage recorded for a timed out request, got %s", model)
+	})
+	return proxy, &decisions, &timeouts
+}
+
+func TestProxyLatencyBudgetNonStreaming(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	proxy, decisions, timeouts := slowProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	w := httptest.NewRecorder()
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader([]byte(body))))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), errKindTimeout) || !strings.Contains(w.Body.String(), "NEXUS_LATENCY_BUDGET_OPENAI") {
+		t.Errorf("Unexpected body %s", w.Body.String())
+	}
+	if len(*decisions) != 1 || !(*decisions)[0].TimedOut {
+		t.Errorf("Expected the decision flagged as a timeout, got %+v", *decisions)
+	}
+	if len(*timeouts) != 1 || (*timeouts)[0] != "gpt-4o" {
+		t.Errorf("Expected one timeout recorded, got %v", *timeouts)
+	}
+}
+
+func TestProxyLatencyBudgetBodyStall(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	proxy, decisions, timeouts := slowProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[`))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	w := httptest.NewRecorder()
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader([]byte(body))))
+
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), errKindTimeout) {
+		t.Errorf("Expected a timeout error for a body that stalls, got %d %s", w.Code, w.Body.String())
+	}
+	if len(*decisions) != 1 || !(*decisions)[0].TimedOut || len(*timeouts) != 1 {
+		t.Errorf("Expected the stall recorded as a timeout, got %+v %v", *decisions, *timeouts)
+	}
+}
+
+func TestProxyLatencyBudgetStreamStall(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	proxy, decisions, timeouts := slowProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		// A stream that keeps producing within the budget is not cut off
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"tok%d \"}}]}\n\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(60 * time.Millisecond)
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	w := httptest.NewRecorder()
+	body := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader([]byte(body))))
+
+	out := w.Body.String()
+	if !strings.Contains(out, "tok2") {
+		t.Errorf("Expected tokens sent within the budget relayed, got %s", out)
+	}
+	if !strings.Contains(out, "event: error") || !strings.Contains(out, errKindTimeout) {
+		t.Errorf("Expected a timeout error event, got %s", out)
+	}
+	if strings.Contains(out, "message_stop") {
+		t.Errorf("Expected the stalled stream not to end normally, got %s", out)
+	}
+	if len(*decisions) != 1 || !(*decisions)[0].TimedOut {
+		t.Errorf("Expected the decision flagged as a timeout, got %+v", *decisions)
+	}
+	if len(*timeouts) != 1 {
+		t.Errorf("Expected one timeout recorded, got %v", *timeouts)
+	}
+}
+
+func TestGrokProxyLatencyBudget(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	proxy := NewGrokProxy(upstream.URL, "key")
+	proxy.SetLatencyBudget(100 * time.Millisecond)
+	var models []string
+	proxy.SetTimeoutObserver(func(model string, elapsed time.Duration) { models = append(models, model) })
+
+	w := httptest.NewRecorder()
+	body := `{"model":"grok-4","messages":[{"role":"user","content":"hi"}]}`
+	proxy.handle(w, httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body)))
+
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), errKindTimeout) {
+		t.Errorf("Expected a 504 timeout error, got %d %s", w.Code, w.Body.String())
+	}
+	if len(models) != 1 || models[0] != "grok-4" {
+		t.Errorf("Expected the timeout observed for grok-4, got %v", models)
+	}
+}
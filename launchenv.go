@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"nexus/internal/backend"
+	"nexus/internal/launch"
+)
+
+// launchSettings supplies the configuration Claude Code is launched with for
+// be to the launch package's environment builder
+type launchSettings struct {
+	cfg *Config
+	be  Backend
+}
+
+// newLaunchEnv returns the builder of the environment Claude Code is launched
+// with for be, starting from the whitelisted variables of base
+func newLaunchEnv(cfg *Config, be Backend, base []string) launch.ConfigEnv {
+	return launch.ConfigEnv{Base: base, Settings: launchSettings{cfg: cfg, be: be}, Filter: filterEnvironment}
+}
+
+// buildLaunchEnv returns the environment for launching Claude Code against be
+// with requests sent to baseURL
+func buildLaunchEnv(cfg *Config, be Backend, base []string, baseURL string) ([]string, error) {
+	return newLaunchEnv(cfg, be, base).Build(launchBackend(be), baseURL)
+}
+
+// launchBackend is be as the launch package sees it
+func launchBackend(be Backend) backend.Backend {
+	return backend.Backend{
+		InputPrice:  be.InputPrice,
+		OutputPrice: be.OutputPrice,
+		Name:        be.Name,
+		DisplayName: be.DisplayName,
+		Provider:    be.Provider,
+		Models:      be.Models,
+		AuthVar:     be.AuthVar,
+		BaseURL:     be.BaseURL,
+		Timeout:     be.Timeout,
+		HaikuModel:  be.HaikuModel,
+		SonnetModel: be.SonnetModel,
+		OpusModel:   be.OpusModel,
+		CodingTier:  be.CodingTier,
+	}
+}
+
+// APIKey returns the stored key for the backend
+func (s launchSettings) APIKey(backend.Backend) string {
+	return s.cfg.Keys[s.be.AuthVar]
+}
+
+// Timeout returns the configured API timeout for the backend
+func (s launchSettings) Timeout(backend.Backend) time.Duration {
+	return s.cfg.apiTimeout(s.be)
+}
+
+// TierModels returns the validated tier models for the backend. Backends
+// without a base URL (Claude) only override pinned and session tiers.
+func (s launchSettings) TierModels(backend.Backend) (map[string]string, error) {
+	models := make(map[string]string)
+	if s.be.BaseURL == "" {
+		for _, tier := range modelTiers {
+			m, ok := sessionModel(s.cfg, s.be.Name, tier)
+			if !ok {
+				m, ok = pinnedModel(s.cfg, s.be.Name, tier)
+			}
+			if ok {
+				models[tier] = m
+			}
+		}
+		return models, nil
+	}
+
+	haiku, sonnet, opus := resolveTierModels(s.cfg, s.be)
+	for i, m := range []string{haiku, sonnet, opus} {
+		if err := validateModelName(m); err != nil {
+			return nil, fmt.Errorf("invalid %s model name: %w", modelTiers[i], err)
+		}
+		models[modelTiers[i]] = m
+	}
+	return models, nil
+}
+
+// Extra returns the output token limit for the backend
+func (s launchSettings) Extra(backend.Backend) []string {
+	return samplingEnv(s.cfg, s.be)
+}
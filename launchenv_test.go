@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus/internal/launch"
+)
+
+// launchEnvFor runs a launch against be through a FakeRunner and returns the
+// environment Claude Code was given
+func launchEnvFor(t *testing.T, cfg *Config, be Backend) map[string]string {
+	t.Helper()
+	// Claude Code variables of the test's own environment would be passed on
+	for _, name := range []string{"ANTHROPIC_AUTH_TOKEN", "ANTHROPIC_BASE_URL", "API_TIMEOUT_MS",
+		"ANTHROPIC_DEFAULT_HAIKU_MODEL", "ANTHROPIC_DEFAULT_SONNET_MODEL", "ANTHROPIC_DEFAULT_OPUS_MODEL"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	// A fresh capability cache keeps the launch from probing the backend
+	if err := updateCapabilities(cfg, be.Name, func(c *BackendCapabilities) { c.ProbedAt = time.Now() }); err != nil {
+		t.Fatal(err)
+	}
+	runner := &launch.FakeRunner{}
+	if _, err := runLaunch(cfg, be, nil, func(time.Duration) launch.Runner { return runner }); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.Runs) != 1 {
+		t.Fatalf("Expected one run, got %d", len(runner.Runs))
+	}
+	// As with exec, a later value replaces an inherited one
+	env := make(map[string]string)
+	for _, kv := range runner.Runs[0].Env {
+		name, value, _ := strings.Cut(kv, "=")
+		env[name] = value
+	}
+	return env
+}
+
+func TestRunLaunchEnvEveryBackend(t *testing.T) {
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		be := backends[name]
+		t.Run(name, func(t *testing.T) {
+			cfg := newSelfTestConfig(t.TempDir())
+			cfg.Keys[be.AuthVar] = "key-" + name
+			env := launchEnvFor(t, cfg, be)
+
+			if env["ANTHROPIC_AUTH_TOKEN"] != "key-"+name {
+				t.Errorf("ANTHROPIC_AUTH_TOKEN = %q", env["ANTHROPIC_AUTH_TOKEN"])
+			}
+			wantURL := be.BaseURL
+			if port, ok := launchProxyPorts[name]; ok {
+				wantURL = proxyURL(port)
+			}
+			if be.BaseURL == "" {
+				// Claude Code talks to Anthropic with its own defaults
+				for _, v := range []string{"ANTHROPIC_BASE_URL", "API_TIMEOUT_MS", "ANTHROPIC_DEFAULT_SONNET_MODEL"} {
+					if env[v] != "" {
+						t.Errorf("Expected %s empty, got %q", v, env[v])
+					}
+				}
+				return
+			}
+			if env["ANTHROPIC_BASE_URL"] != wantURL {
+				t.Errorf("ANTHROPIC_BASE_URL = %q, want %q", env["ANTHROPIC_BASE_URL"], wantURL)
+			}
+			if want := fmt.Sprint(cfg.apiTimeout(be).Milliseconds()); env["API_TIMEOUT_MS"] != want {
+				t.Errorf("API_TIMEOUT_MS = %q, want %q", env["API_TIMEOUT_MS"], want)
+			}
+			for tier, want := range map[string]string{"HAIKU": be.HaikuModel, "SONNET": be.SonnetModel, "OPUS": be.OpusModel} {
+				if got := env["ANTHROPIC_DEFAULT_"+tier+"_MODEL"]; got != want {
+					t.Errorf("ANTHROPIC_DEFAULT_%s_MODEL = %q, want %q", tier, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunLaunchEnvOverrides(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.Keys["GROK_API_KEY"] = "grok-key"
+	cfg.GrokModels = map[string]string{"haiku": "grok-3-mini"}
+	cfg.PinnedModels = map[string]map[string]string{"grok": {"sonnet": "grok-4-0709"}}
+	cfg.SessionModels = map[string]map[string]string{"grok": {"opus": "grok-4-heavy"}}
+	cfg.Timeouts = map[string]time.Duration{"api:grok": 90 * time.Second}
+	env := launchEnvFor(t, cfg, backends["grok"])
+
+	for name, want := range map[string]string{
+		"ANTHROPIC_DEFAULT_HAIKU_MODEL":  "grok-3-mini",
+		"ANTHROPIC_DEFAULT_SONNET_MODEL": "grok-4-0709",
+		"ANTHROPIC_DEFAULT_OPUS_MODEL":   "grok-4-heavy",
+		"API_TIMEOUT_MS":                 "90000",
+		"ANTHROPIC_BASE_URL":             proxyURL(launchProxyPorts["grok"]),
+	} {
+		if env[name] != want {
+			t.Errorf("%s = %q, want %q", name, env[name], want)
+		}
+	}
+
+	// Ollama needs no key, but Claude Code requires a token with a custom base URL
+	if env := launchEnvFor(t, newSelfTestConfig(t.TempDir()), backends["ollama"]); env["ANTHROPIC_AUTH_TOKEN"] != "ollama" {
+		t.Errorf("ANTHROPIC_AUTH_TOKEN = %q, want ollama", env["ANTHROPIC_AUTH_TOKEN"])
+	}
+}
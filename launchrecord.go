@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces secret values in launch records. Keys are never
+// written, not even masked.
+const redactedValue = "[REDACTED]"
+
+// LaunchRecord is the environment and argv of the last Claude Code process,
+// for `promptops inspect-env --last-launch`
+type LaunchRecord struct {
+	Time    time.Time `json:"time"`
+	Backend string    `json:"backend"`
+	Session string    `json:"session,omitempty"`
+	Dir     string    `json:"dir"`
+	Argv    []string  `json:"argv"`
+	Env     []string  `json:"env"` // NAME=value, secrets redacted
+}
+
+// secretNameParts mark environment variables whose values are credentials
+var secretNameParts = []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "PASSPHRASE", "CREDENTIAL"}
+
+// isSecretEnvName reports whether an environment variable holds a credential
+func isSecretEnvName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, part := range secretNameParts {
+		if strings.Contains(upper, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecrets replaces any configured key found in s
+func redactSecrets(cfg *Config, s string) string {
+	for _, key := range cfg.Keys {
+		if len(key) >= maskKeyMinLength {
+			s = strings.ReplaceAll(s, key, redactedValue)
+		}
+	}
+	return s
+}
+
+// newLaunchRecord captures argv and env of a launch with secrets redacted:
+// values of credential variables, and configured keys wherever they appear
+func newLaunchRecord(cfg *Config, be Backend, argv, env []string) LaunchRecord {
+	record := LaunchRecord{
+		Time:    time.Now(),
+		Backend: be.Name,
+		Dir:     getWorkingDir(),
+	}
+	if s := getCurrentSession(cfg); s != nil {
+		record.Session = s.Name
+	}
+	for _, arg := range argv {
+		record.Argv = append(record.Argv, redactSecrets(cfg, arg))
+	}
+	for _, e := range env {
+		name, value, _ := strings.Cut(e, "=")
+		if isSecretEnvName(name) && value != "" {
+			value = redactedValue
+		}
+		record.Env = append(record.Env, name+"="+redactSecrets(cfg, value))
+	}
+	sort.Strings(record.Env)
+	return record
+}
+
+// saveLaunchRecord replaces the last launch record
+func saveLaunchRecord(cfg *Config, record LaunchRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cfg.LaunchRecordFile, data, 0600)
+}
+
+// loadLaunchRecord reads the last launch record
+func loadLaunchRecord(cfg *Config) (*LaunchRecord, error) {
+	data, err := os.ReadFile(cfg.LaunchRecordFile)
+	if err != nil {
+		return nil, err
+	}
+	var record LaunchRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("read launch record: %w", err)
+	}
+	return &record, nil
+}
+
+// shellQuote quotes an argument for display when it needs it
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t'\"\\$`|&;<>()*?[]{}!#~") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// handleInspectEnv prints what the last launch passed to Claude Code:
+// promptops inspect-env --last-launch [--json]
+func handleInspectEnv(args []string) {
+	args, last := stripFlag(args, "--last-launch")
+	if !last || len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: promptops inspect-env --last-launch [--json]")
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+	record, err := loadLaunchRecord(cfg)
+	if os.IsNotExist(err) {
+		fmt.Println("No launch recorded yet. It is written each time promptops starts Claude Code.")
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if globalOpts.JSON {
+		data, _ := json.MarshalIndent(record, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("LAST LAUNCH"))
+	fmt.Println()
+	fmt.Printf("%s %s (%s ago)\n", styleLabel.Render("Time:     "), record.Time.Local().Format("2006-01-02 15:04:05"), formatElapsed(time.Since(record.Time)))
+	fmt.Printf("%s %s\n", styleLabel.Render("Backend:  "), record.Backend)
+	if record.Session != "" {
+		fmt.Printf("%s %s\n", styleLabel.Render("Session:  "), record.Session)
+	}
+	fmt.Printf("%s %s\n", styleLabel.Render("Directory:"), record.Dir)
+
+	quoted := make([]string, len(record.Argv))
+	for i, arg := range record.Argv {
+		quoted[i] = shellQuote(arg)
+	}
+	fmt.Println()
+	fmt.Println(styleSection.Render("ARGV"))
+	fmt.Println()
+	fmt.Println(strings.Join(quoted, " "))
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("ENVIRONMENT"))
+	fmt.Println()
+	for _, e := range record.Env {
+		name, value, _ := strings.Cut(e, "=")
+		fmt.Printf("%s=%s\n", styleLabel.Render(name), value)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNewLaunchRecordRedactsSecrets(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	secret := "sk-deepseek-" + strings.Repeat("x", 20)
+	cfg.Keys["DEEPSEEK_API_KEY"] = secret
+
+	argv := []string{"claude", "--dangerously-skip-permissions", "--append-system-prompt", "key is " + secret}
+	env := []string{
+		"ANTHROPIC_BASE_URL=https://api.deepseek.com/anthropic",
+		"ANTHROPIC_AUTH_TOKEN=" + secret,
+		"ANTHROPIC_DEFAULT_OPUS_MODEL=deepseek-reasoner",
+		"OLLAMA_API_KEY=",
+		"HOME=/home/dev",
+		"NOTE=" + secret,
+	}
+	record := newLaunchRecord(cfg, backends["deepseek"], argv, env)
+
+	all := strings.Join(append(record.Argv, record.Env...), "\n")
+	if strings.Contains(all, secret) || strings.Contains(all, maskKey(secret)) {
+		t.Fatalf("Launch record leaked a key:\n%s", all)
+	}
+	if record.Argv[3] != "key is "+redactedValue {
+		t.Errorf("Expected the key redacted in argv, got %q", record.Argv[3])
+	}
+	want := map[string]bool{
+		"ANTHROPIC_AUTH_TOKEN=" + redactedValue:                 true,
+		"ANTHROPIC_DEFAULT_OPUS_MODEL=deepseek-reasoner":        true,
+		"ANTHROPIC_BASE_URL=https://api.deepseek.com/anthropic": true,
+		"OLLAMA_API_KEY=":       true,
+		"HOME=/home/dev":        true,
+		"NOTE=" + redactedValue: true,
+	}
+	for _, e := range record.Env {
+		if !want[e] {
+			t.Errorf("Unexpected env entry %q", e)
+		}
+	}
+	if record.Backend != "deepseek" || len(record.Env) != len(env) {
+		t.Errorf("Unexpected record %+v", record)
+	}
+}
+
+func TestLaunchRecordRoundTrip(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	if _, err := loadLaunchRecord(cfg); !os.IsNotExist(err) {
+		t.Errorf("Expected no record yet, got %v", err)
+	}
+
+	record := newLaunchRecord(cfg, backends["ollama"], []string{"claude"}, []string{"API_TIMEOUT_MS=3000000"})
+	if err := saveLaunchRecord(cfg, record); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(cfg.LaunchRecordFile)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a 0600 launch record, got %v %v", info, err)
+	}
+	got, err := loadLaunchRecord(cfg)
+	if err != nil || got.Backend != "ollama" || len(got.Env) != 1 || got.Env[0] != "API_TIMEOUT_MS=3000000" {
+		t.Errorf("Unexpected record %+v %v", got, err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"--model":    "--model",
+		"be brief":   "'be brief'",
+		"it's":       `'it'\''s'`,
+		"":           "''",
+		"$HOME/file": "'$HOME/file'",
+	}
+	for in, want := range tests {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
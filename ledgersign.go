@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ledger signing chains usage and audit records with HMAC-SHA256: each
+// record carries a MAC over the previous record's MAC and its own content,
+// so edited, removed or reordered records break the chain. A signed chain
+// head next to each file records how many records there are, which catches
+// truncation.
+const (
+	ledgerKeyBytes = 32
+	// maxLedgerProblems caps the problems reported per file
+	maxLedgerProblems = 20
+)
+
+var (
+	jsonMACPattern = regexp.MustCompile(`,?"mac":"([0-9a-f]{64})"}$`)
+	textMACPattern = regexp.MustCompile(` mac=([0-9a-f]{64})$`)
+)
+
+// ledgerMAC chains body to the MAC of the record before it, "" for the first
+func ledgerMAC(key []byte, prev string, body []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(prev))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// signLedgerLine returns line carrying its MAC, as a trailing "mac" field of
+// a JSON object or a " mac=" suffix of a text line, and the MAC
+func signLedgerLine(key []byte, prev string, line []byte) ([]byte, string) {
+	line = bytes.TrimRight(line, "\r\n")
+	if isJSONObjectLine(line) {
+		sep := ","
+		if len(bytes.TrimSpace(line[1:len(line)-1])) == 0 {
+			line, sep = []byte("{}"), ""
+		}
+		mac := ledgerMAC(key, prev, line)
+		return []byte(fmt.Sprintf(`%s%s"mac":"%s"}`, line[:len(line)-1], sep, mac)), mac
+	}
+	mac := ledgerMAC(key, prev, line)
+	return []byte(fmt.Sprintf("%s mac=%s", line, mac)), mac
+}
+
+func isJSONObjectLine(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("{")) && bytes.HasSuffix(line, []byte("}"))
+}
+
+// splitLedgerLine returns the record a signed line carries and its MAC; ok
+// is false for unsigned lines
+func splitLedgerLine(line []byte) (body []byte, mac string, ok bool) {
+	if bytes.HasPrefix(line, []byte("{")) {
+		if m := jsonMACPattern.FindSubmatchIndex(line); m != nil {
+			body = append(append([]byte{}, line[:m[0]]...), '}')
+			return body, string(line[m[2]:m[3]]), true
+		}
+	}
+	m := textMACPattern.FindSubmatchIndex(line)
+	if m == nil {
+		return nil, "", false
+	}
+	return line[:m[0]], string(line[m[2]:m[3]]), true
+}
+
+// chainHead is the last link of a signed file's chain, kept in
+// <file>.chain so removing records from the end is detected
+type chainHead struct {
+	Records int    `json:"records"`
+	MAC     string `json:"mac"`
+	Sig     string `json:"sig"`
+}
+
+func chainHeadPath(path string) string {
+	return path + ".chain"
+}
+
+// signature authenticates the head, so it cannot be rolled back to an
+// earlier record without the key
+func (h chainHead) signature(key []byte) string {
+	return ledgerMAC(key, "head", []byte(strconv.Itoa(h.Records)+" "+h.MAC))
+}
+
+// readChainHead loads the head of path's chain; found is false when the
+// file has never been signed
+func readChainHead(path string) (head chainHead, found bool, err error) {
+	data, err := os.ReadFile(chainHeadPath(path))
+	if os.IsNotExist(err) {
+		return chainHead{}, false, nil
+	}
+	if err != nil {
+		return chainHead{}, false, err
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return chainHead{}, true, fmt.Errorf("invalid chain head %s: %w", chainHeadPath(path), err)
+	}
+	return head, true, nil
+}
+
+func writeChainHead(path string, key []byte, head chainHead) error {
+	head.Sig = head.signature(key)
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(chainHeadPath(path), data, 0600)
+}
+
+// ledgerKey reads the signing key from cfg.LedgerKeyFile. With create set, a
+// random key is written on first use; concurrent first writers all end up
+// with the one that was linked into place first.
+func ledgerKey(cfg *Config, create bool) ([]byte, error) {
+	data, err := os.ReadFile(cfg.LedgerKeyFile)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < ledgerKeyBytes/2 {
+			return nil, fmt.Errorf("invalid ledger key in %s", cfg.LedgerKeyFile)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !create {
+		return nil, fmt.Errorf("no ledger key at %s", cfg.LedgerKeyFile)
+	}
+
+	key := make([]byte, ledgerKeyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	tmp := filepath.Join(filepath.Dir(cfg.LedgerKeyFile), ".tmp-"+strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := os.WriteFile(tmp, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+	if err := os.Link(tmp, cfg.LedgerKeyFile); err != nil {
+		if os.IsExist(err) {
+			return ledgerKey(cfg, false)
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// appendLedgerLines appends records to the usage file or audit log at path,
+// chaining them when ledger signing is on
+func appendLedgerLines(cfg *Config, path string, lines [][]byte) error {
+	if !cfg.LedgerSigning {
+		return appendUsageLines(path, lines)
+	}
+	key, err := ledgerKey(cfg, true)
+	if err != nil {
+		return fmt.Errorf("ledger key: %w", err)
+	}
+	return withFileLock(path+".lock", func() error {
+		// A head that fails verification is still chained onto, so the
+		// damage stays visible to audit verify
+		head, _, err := readChainHead(path)
+		if err != nil {
+			return err
+		}
+		signed := make([][]byte, len(lines))
+		for i, line := range lines {
+			signed[i], head.MAC = signLedgerLine(key, head.MAC, line)
+			head.Records++
+		}
+		if err := appendUsageLines(path, signed); err != nil {
+			return err
+		}
+		return writeChainHead(path, key, head)
+	})
+}
+
+// importLedgerLines appends the records of incoming that path does not have
+// yet, chaining them after its existing records rather than rewriting the
+// file. Signatures from the bundle are dropped and records are signed anew,
+// so the import is covered by this install's key. Since re-signing vouches
+// for records this install did not write, every import is audited. It
+// returns how many records were added.
+func importLedgerLines(cfg *Config, path string, incoming []byte) (int, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	seen := make(map[string]bool)
+	for _, line := range bytes.Split(existing, []byte("\n")) {
+		seen[string(ledgerRecord(line))] = true
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(incoming, []byte("\n")) {
+		record := ledgerRecord(line)
+		if len(record) > 0 && !seen[string(record)] {
+			seen[string(record)] = true
+			lines = append(lines, record)
+		}
+	}
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	if err := appendLedgerLines(cfg, path, lines); err != nil {
+		return 0, err
+	}
+	auditLog(cfg, fmt.Sprintf("LEDGER_IMPORT: %d imported records re-signed into %s", len(lines), path))
+	return len(lines), nil
+}
+
+// ledgerRecord returns a line without its signature, if it has one
+func ledgerRecord(line []byte) []byte {
+	line = bytes.TrimSpace(line)
+	if body, _, ok := splitLedgerLine(line); ok {
+		return body
+	}
+	return line
+}
+
+// LedgerReport is the result of verifying one signed file
+type LedgerReport struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Signed int    `json:"signed"`
+	// Unsigned counts records from before signing was turned on
+	Unsigned int      `json:"unsigned"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// OK reports whether the file's chain is intact
+func (r LedgerReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *LedgerReport) problem(format string, a ...interface{}) {
+	if len(r.Problems) < maxLedgerProblems {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, a...))
+	} else if len(r.Problems) == maxLedgerProblems {
+		r.Problems = append(r.Problems, "further problems not shown")
+	}
+}
+
+// verifyLedger checks the chain of the file at path against key and its
+// chain head. Records written before signing was turned on are counted but
+// not covered; unsigned records after the first signed one are problems.
+func verifyLedger(key []byte, name, path string) LedgerReport {
+	r := LedgerReport{Name: name, File: path}
+	head, found, err := readChainHead(path)
+	if err != nil {
+		r.problem("%v", err)
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		if found && head.Records > 0 {
+			r.problem("file is missing; the chain head records %d signed records", head.Records)
+		}
+		return r
+	}
+	if err != nil {
+		r.problem("%v", err)
+		return r
+	}
+	defer f.Close()
+
+	prev, headMAC := "", ""
+	reader := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimRight(line, "\r\n"); len(bytes.TrimSpace(line)) > 0 {
+			body, mac, ok := splitLedgerLine(line)
+			switch {
+			case !ok && r.Signed == 0:
+				r.Unsigned++
+			case !ok:
+				r.problem("line %d: unsigned record after signed ones", lineNo)
+			default:
+				if !hmac.Equal([]byte(ledgerMAC(key, prev, body)), []byte(mac)) {
+					r.problem("line %d: record was modified, or one before it was removed or reordered", lineNo)
+				}
+				// Continuing from the recorded MAC reports each edit once
+				prev = mac
+				r.Signed++
+				if r.Signed == head.Records {
+					headMAC = mac
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			r.problem("%v", err)
+			return r
+		}
+	}
+
+	switch {
+	case !found:
+		if r.Signed > 0 {
+			r.problem("chain head %s is missing", chainHeadPath(path))
+		}
+	case !hmac.Equal([]byte(head.Sig), []byte(head.signature(key))):
+		r.problem("chain head %s was modified", chainHeadPath(path))
+	case r.Signed < head.Records:
+		r.problem("truncated: %d signed records, the chain head records %d", r.Signed, head.Records)
+	case headMAC != head.MAC:
+		r.problem("signed records do not lead to the chain head")
+	}
+	// Records past the head were appended by a writer interrupted before it
+	// moved the head; they are still chained, so they are not a problem
+	return r
+}
+
+// ledgerKeyBesideLedgers reports whether the signing key sits in the same
+// directory as the usage ledger or audit log, where whoever can edit them can
+// usually read the key and re-sign the edits
+func ledgerKeyBesideLedgers(cfg *Config) bool {
+	keyDir, err := filepath.Abs(filepath.Dir(cfg.LedgerKeyFile))
+	if err != nil {
+		return false
+	}
+	for _, path := range []string{cfg.UsageFile, cfg.AuditLog} {
+		if dir, err := filepath.Abs(filepath.Dir(path)); err == nil && dir == keyDir {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAuditCommand handles promptops audit verify
+func handleAuditCommand(args []string) {
+	if len(args) != 1 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "Usage: promptops audit verify [--json]")
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+	key, err := ledgerKey(cfg, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if !cfg.LedgerSigning {
+			fmt.Fprintln(os.Stderr, "Set NEXUS_LEDGER_SIGNING=true to sign usage and audit records.")
+		}
+		os.Exit(1)
+	}
+
+	if ledgerKeyBesideLedgers(cfg) {
+		fmt.Fprintf(os.Stderr, "Warning: the ledger key %s is kept beside the ledgers; anyone who can edit them can likely re-sign the edits\n", cfg.LedgerKeyFile)
+		fmt.Fprintln(os.Stderr, "Set NEXUS_LEDGER_KEY_FILE to a file outside that directory that ledger users cannot write.")
+	}
+
+	reports := []LedgerReport{
+		verifyLedger(key, "Usage ledger", cfg.UsageFile),
+		verifyLedger(key, "Audit log", cfg.AuditLog),
+	}
+	failed := false
+	for _, r := range reports {
+		failed = failed || !r.OK()
+	}
+
+	if globalOpts.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(reports)
+	} else {
+		for _, r := range reports {
+			if r.OK() {
+				fmt.Printf("%s %s: %d signed records (%s)\n", styleSuccess.Render("[OK]  "), r.Name, r.Signed, r.File)
+			} else {
+				fmt.Printf("%s %s: %d signed records (%s)\n", styleError.Render("[FAIL]"), r.Name, r.Signed, r.File)
+				for _, p := range r.Problems {
+					fmt.Printf("       %s\n", p)
+				}
+			}
+			if r.Unsigned > 0 {
+				fmt.Printf("       %d records from before signing was turned on are not covered\n", r.Unsigned)
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newLedgerConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := newSelfTestConfig(dir)
+	cfg.AuditEnabled = true
+	cfg.LedgerSigning = true
+	cfg.LedgerKeyFile = filepath.Join(dir, "ledger.key")
+	return cfg
+}
+
+func writeLedger(t *testing.T, cfg *Config, lines ...string) []byte {
+	t.Helper()
+	for _, line := range lines {
+		if err := appendLedgerLines(cfg, cfg.UsageFile, [][]byte{[]byte(line)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, err := ledgerKey(cfg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSignLedgerLine(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	for _, line := range []string{`{"backend":"openai","cost":0.1}`, `{}`, `[2026-01-02T10:00:00Z] SWITCH: claude -> kimi`} {
+		signed, mac := signLedgerLine(key, "prev", []byte(line))
+		body, got, ok := splitLedgerLine(signed)
+		if !ok || string(body) != line || got != mac {
+			t.Errorf("splitLedgerLine(%s) = %s %s %v", signed, body, got, ok)
+		}
+		if mac != ledgerMAC(key, "prev", []byte(line)) {
+			t.Errorf("Expected the MAC chained to the previous one for %s", line)
+		}
+	}
+	if signed, _ := signLedgerLine(key, "", []byte(`{"n":1}`)); !strings.HasPrefix(string(signed), `{"n":1,"mac":"`) {
+		t.Errorf("Expected a mac field in the JSON record, got %s", signed)
+	}
+	if _, _, ok := splitLedgerLine([]byte(`{"backend":"openai"}`)); ok {
+		t.Error("Expected an unsigned record")
+	}
+}
+
+func TestVerifyLedger(t *testing.T) {
+	cfg := newLedgerConfig(t)
+	key := writeLedger(t, cfg, `{"n":1}`, `{"n":2}`, `{"n":3}`)
+
+	if r := verifyLedger(key, "usage", cfg.UsageFile); !r.OK() || r.Signed != 3 {
+		t.Fatalf("Expected an intact ledger, got %+v", r)
+	}
+	if r := verifyLedger([]byte("another key 1234"), "usage", cfg.UsageFile); r.OK() {
+		t.Error("Expected records signed with another key to fail")
+	}
+	data, _ := os.ReadFile(cfg.UsageFile)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	lines[len(lines)-1] += "\n"
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"modified", strings.Replace(string(data), `"n":2`, `"n":9`, 1), "line 2: record was modified"},
+		{"removed", lines[0] + lines[2], "line 2: record was modified"},
+		{"reordered", lines[1] + lines[0] + lines[2], "line 1: record was modified"},
+		{"truncated", lines[0] + lines[1], "truncated: 2 signed records, the chain head records 3"},
+		{"unsigned_insert", string(data) + "{\"n\":4}\n", "line 4: unsigned record after signed ones"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(cfg.UsageFile, []byte(tt.data), 0600)
+			r := verifyLedger(key, "usage", cfg.UsageFile)
+			if r.OK() || !strings.Contains(strings.Join(r.Problems, "\n"), tt.want) {
+				t.Errorf("Expected %q, got %v", tt.want, r.Problems)
+			}
+		})
+	}
+
+	os.WriteFile(cfg.UsageFile, data, 0600)
+	head, _, _ := readChainHead(cfg.UsageFile)
+	os.WriteFile(chainHeadPath(cfg.UsageFile), []byte(`{"records":2,"mac":"`+head.MAC+`","sig":"`+head.Sig+`"}`), 0600)
+	if r := verifyLedger(key, "usage", cfg.UsageFile); r.OK() {
+		t.Error("Expected an edited chain head to fail")
+	}
+	os.Remove(chainHeadPath(cfg.UsageFile))
+	if r := verifyLedger(key, "usage", cfg.UsageFile); r.OK() {
+		t.Error("Expected a missing chain head to fail")
+	}
+}
+
+func TestVerifyLedgerUnsignedHistory(t *testing.T) {
+	cfg := newLedgerConfig(t)
+	os.WriteFile(cfg.UsageFile, []byte("{\"n\":0}\n{\"n\":1}\n"), 0600)
+	key := writeLedger(t, cfg, `{"n":2}`)
+	if r := verifyLedger(key, "usage", cfg.UsageFile); !r.OK() || r.Unsigned != 2 || r.Signed != 1 {
+		t.Errorf("Expected records from before signing counted, got %+v", r)
+	}
+
+	missing := filepath.Join(t.TempDir(), "usage.jsonl")
+	if r := verifyLedger(key, "usage", missing); !r.OK() {
+		t.Errorf("Expected a file never written to pass, got %v", r.Problems)
+	}
+}
+
+func TestLedgerKeyFile(t *testing.T) {
+	cfg := newLedgerConfig(t)
+	if _, err := ledgerKey(cfg, false); err == nil {
+		t.Error("Expected an error without a key")
+	}
+	key, err := ledgerKey(cfg, true)
+	if err != nil || len(key) != ledgerKeyBytes {
+		t.Fatalf("ledgerKey() = %d bytes, %v", len(key), err)
+	}
+	info, err := os.Stat(cfg.LedgerKeyFile)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the key file with 0600 permissions, got %v", info)
+	}
+	again, _ := ledgerKey(cfg, true)
+	if !bytes.Equal(key, again) {
+		t.Error("Expected the existing key reused")
+	}
+}
+
+func TestSignedUsageAndAuditLog(t *testing.T) {
+	cfg := newLedgerConfig(t)
+	if err := (&usageQueue{}).write(cfg, []byte(`{"backend":"deepseek"}`)); err != nil {
+		t.Fatal(err)
+	}
+	auditLog(cfg, "SWITCH: claude -> deepseek")
+	auditLog(cfg, "IMPORT: two\nlines")
+
+	key, err := ledgerKey(cfg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{cfg.UsageFile, cfg.AuditLog} {
+		if r := verifyLedger(key, "ledger", path); !r.OK() || r.Signed == 0 {
+			t.Errorf("Expected signed records in %s, got %+v", path, r)
+		}
+	}
+	records := loadUsageRecords(cfg)
+	if len(records) != 1 || records[0].Backend != "deepseek" {
+		t.Errorf("Expected signed usage records readable, got %v", records)
+	}
+}
+
+func TestImportLedgerLines(t *testing.T) {
+	cfg := newLedgerConfig(t)
+	key := writeLedger(t, cfg, `{"n":1}`)
+	other, _ := signLedgerLine([]byte("another key 1234"), "", []byte(`{"n":2}`))
+	incoming := []byte("{\"n\":1}\n" + string(other) + "\n{\"n\":3}\n")
+
+	added, err := importLedgerLines(cfg, cfg.UsageFile, incoming)
+	if err != nil || added != 2 {
+		t.Fatalf("importLedgerLines() = %d, %v", added, err)
+	}
+	if r := verifyLedger(key, "usage", cfg.UsageFile); !r.OK() || r.Signed != 3 {
+		t.Errorf("Expected imported records signed into the chain, got %+v", r)
+	}
+	if added, _ := importLedgerLines(cfg, cfg.UsageFile, incoming); added != 0 {
+		t.Errorf("Expected a second import to add nothing, got %d", added)
+	}
+	audit, _ := os.ReadFile(cfg.AuditLog)
+	if n := strings.Count(string(audit), "LEDGER_IMPORT: 2 imported records re-signed"); n != 1 {
+		t.Errorf("Expected the re-signing import audited once, got %d in %s", n, audit)
+	}
+}
+
+func TestLedgerKeyBesideLedgers(t *testing.T) {
+	cfg := newLedgerConfig(t)
+	if !ledgerKeyBesideLedgers(cfg) {
+		t.Error("Expected a key in the data directory reported")
+	}
+	cfg.LedgerKeyFile = filepath.Join(t.TempDir(), "ledger.key")
+	if ledgerKeyBesideLedgers(cfg) {
+		t.Error("Expected a key in another directory accepted")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// defaultUpstream names the Ollama base URL when routing between local upstreams
+const defaultUpstream = "ollama"
+
+var upstreamNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// LocalUpstream is an OpenAI-compatible local server the proxy can forward to
+type LocalUpstream struct {
+	Name    string
+	BaseURL string
+}
+
+// ModelRoute sends models matching a glob pattern to a named upstream
+type ModelRoute struct {
+	Pattern  string
+	Upstream string
+}
+
+// parseLocalUpstreams parses "name=url,name=url" (NEXUS_LOCAL_UPSTREAMS)
+func parseLocalUpstreams(value string) ([]LocalUpstream, error) {
+	var upstreams []LocalUpstream
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, baseURL, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+		if !ok || !upstreamNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid upstream '%s': expected name=url", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate upstream '%s'", name)
+		}
+		u, err := url.Parse(baseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid URL for upstream '%s'", name)
+		}
+		seen[name] = true
+		upstreams = append(upstreams, LocalUpstream{Name: name, BaseURL: baseURL})
+	}
+	return upstreams, nil
+}
+
+// parseModelRoutes parses "pattern=upstream,..." (NEXUS_LOCAL_ROUTES).
+// Patterns use shell glob syntax and are tried in order.
+func parseModelRoutes(value string) ([]ModelRoute, error) {
+	var routes []ModelRoute
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pattern, upstream, ok := strings.Cut(part, "=")
+		pattern = strings.TrimSpace(pattern)
+		upstream = strings.ToLower(strings.TrimSpace(upstream))
+		if !ok || pattern == "" || !upstreamNamePattern.MatchString(upstream) {
+			return nil, fmt.Errorf("invalid route '%s': expected pattern=upstream", part)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+		}
+		routes = append(routes, ModelRoute{Pattern: pattern, Upstream: upstream})
+	}
+	return routes, nil
+}
+
+// routeModel returns the upstream of the first route matching model, or ""
+func routeModel(routes []ModelRoute, model string) string {
+	for _, r := range routes {
+		if ok, _ := path.Match(r.Pattern, model); ok {
+			return r.Upstream
+		}
+	}
+	return ""
+}
+
+// SetUpstreams adds local upstreams and the routes that select them. Models
+// without a matching route, or routed to an unknown upstream, use the
+// proxy's own base URL.
+func (p *OllamaProxy) SetUpstreams(upstreams []LocalUpstream, routes []ModelRoute) {
+	byName := make(map[string]string, len(upstreams))
+	for _, u := range upstreams {
+		byName[u.Name] = u.BaseURL
+	}
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	p.upstreams, p.routes = byName, routes
+}
+
+// upstreamFor returns the name and base URL of the upstream serving model
+func (p *OllamaProxy) upstreamFor(model string) (string, string) {
+	p.settingsMu.RLock()
+	defer p.settingsMu.RUnlock()
+	if name := routeModel(p.routes, model); name != "" {
+		if baseURL, ok := p.upstreams[name]; ok {
+			return name, baseURL
+		}
+	}
+	return defaultUpstream, p.ollamaBaseURL
+}
+
+// unknownRouteTargets lists route upstreams that are not configured
+func unknownRouteTargets(upstreams []LocalUpstream, routes []ModelRoute) []string {
+	known := map[string]bool{defaultUpstream: true}
+	for _, u := range upstreams {
+		known[u.Name] = true
+	}
+	var unknown []string
+	for _, r := range routes {
+		if !known[r.Upstream] {
+			unknown = append(unknown, r.Upstream)
+			known[r.Upstream] = true
+		}
+	}
+	return unknown
+}
+
+// checkLocalUpstreams health checks every configured local upstream
+func checkLocalUpstreams(cfg *Config, check func(*Config, Backend) HealthResult, out io.Writer) {
+	for _, u := range cfg.LocalUpstreams {
+		be := backends["ollama"]
+		be.DisplayName = u.Name
+		be.BaseURL = u.BaseURL
+		be.AuthVar = "" // The Ollama key is not sent to other servers
+		fmt.Fprintln(out, formatHealthLine(be, check(cfg, be)))
+	}
+	for _, name := range unknownRouteTargets(cfg.LocalUpstreams, cfg.ModelRoutes) {
+		fmt.Fprintln(out, styleWarning.Render(fmt.Sprintf("Warning: NEXUS_LOCAL_ROUTES uses unknown upstream '%s'", name)))
+	}
+}
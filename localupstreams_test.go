@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseLocalUpstreams(t *testing.T) {
+	got, err := parseLocalUpstreams("LMStudio=http://localhost:1234/v1/, vllm=http://gpu-box:8000/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != (LocalUpstream{"lmstudio", "http://localhost:1234/v1"}) || got[1].Name != "vllm" {
+		t.Errorf("Unexpected upstreams: %+v", got)
+	}
+
+	for _, bad := range []string{"lmstudio", "lmstudio=localhost:1234", "a=http://x,a=http://y", "bad name=http://x"} {
+		if _, err := parseLocalUpstreams(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseModelRoutes(t *testing.T) {
+	routes, err := parseModelRoutes("qwen*=lmstudio, llama*=Ollama")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes[1] != (ModelRoute{"llama*", "ollama"}) {
+		t.Errorf("Unexpected routes: %+v", routes)
+	}
+	if got := routeModel(routes, "qwen2.5-coder:7b"); got != "lmstudio" {
+		t.Errorf("Expected qwen routed to lmstudio, got %q", got)
+	}
+	if got := routeModel(routes, "phi3:latest"); got != "" {
+		t.Errorf("Expected no route for phi3, got %q", got)
+	}
+
+	for _, bad := range []string{"qwen*", "=lmstudio", "[=lmstudio"} {
+		if _, err := parseModelRoutes(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestUnknownRouteTargets(t *testing.T) {
+	upstreams := []LocalUpstream{{Name: "lmstudio", BaseURL: "http://localhost:1234/v1"}}
+	routes := []ModelRoute{{"qwen*", "lmstudio"}, {"llama*", "ollama"}, {"phi*", "vllm"}, {"gemma*", "vllm"}}
+	if got := unknownRouteTargets(upstreams, routes); len(got) != 1 || got[0] != "vllm" {
+		t.Errorf("Expected vllm reported once, got %v", got)
+	}
+}
+
+func TestProxyRoutesToUpstream(t *testing.T) {
+	served := make(map[string]string)
+	upstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var buf bytes.Buffer
+			buf.ReadFrom(r.Body)
+			served[name] = buf.String()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`))
+		}))
+	}
+	ollama, lmstudio := upstream("ollama"), upstream("lmstudio")
+	defer ollama.Close()
+	defer lmstudio.Close()
+
+	proxy := NewOllamaProxy(ollama.URL, map[string]string{})
+	proxy.SetUpstreams([]LocalUpstream{{Name: "lmstudio", BaseURL: lmstudio.URL}}, []ModelRoute{{"qwen*", "lmstudio"}, {"phi*", "missing"}})
+
+	var recorded []string
+	proxy.SetUsageRecorder(func(model string, usage AnthropicUsage) {
+		name, _ := proxy.upstreamFor(model)
+		recorded = append(recorded, name)
+	})
+	for _, model := range []string{"qwen2.5-coder:7b", "llama3.2:latest", "phi3:latest"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"`+model+`","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+		proxy.handleMessages(httptest.NewRecorder(), req)
+	}
+
+	if !strings.Contains(served["lmstudio"], "qwen2.5-coder:7b") || strings.Contains(served["ollama"], "qwen") {
+		t.Errorf("Expected qwen served by lmstudio, got %v", served)
+	}
+	if strings.Join(recorded, ",") != "lmstudio,ollama,ollama" {
+		t.Errorf("Expected unknown route to fall back to ollama, got %v", recorded)
+	}
+}
+
+func TestLogUpstreamUsage(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	appendUsageRecord(cfg, UsageRecord{Backend: "ollama", Upstream: "lmstudio", InputTokens: 100, OutputTokens: 10})
+	logSessionUsage(cfg, "ollama", "", 100, 10)
+
+	records := loadUsageRecords(cfg)
+	if len(records) != 2 || records[0].Upstream != "lmstudio" || records[1].Upstream != "" {
+		t.Errorf("Unexpected upstreams in records: %+v", records)
+	}
+}
+
+func TestCheckLocalUpstreams(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.Keys["OLLAMA_API_KEY"] = "local-key"
+	cfg.LocalUpstreams = []LocalUpstream{{Name: "lmstudio", BaseURL: "http://localhost:1234/v1"}}
+	cfg.ModelRoutes = []ModelRoute{{"phi*", "vllm"}}
+
+	var checked Backend
+	check := func(cfg *Config, be Backend) HealthResult {
+		checked = be
+		return HealthResult{Backend: be.Name, Status: "ok"}
+	}
+	var out bytes.Buffer
+	checkLocalUpstreams(cfg, check, &out)
+
+	if checked.BaseURL != "http://localhost:1234/v1" || cfg.Keys[checked.AuthVar] != "" {
+		t.Errorf("Expected upstream checked without the Ollama key, got %+v", checked)
+	}
+	if !strings.Contains(out.String(), "lmstudio") || !strings.Contains(out.String(), "unknown upstream 'vllm'") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
+
+func TestRoutedUpstreamGetsNoCredential(t *testing.T) {
+	seen := make(map[string]string)
+	upstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen[name] = r.URL.Path + " " + r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`))
+		}))
+	}
+	hosted, local := upstream("hosted"), upstream("local")
+	defer hosted.Close()
+	defer local.Close()
+
+	proxy := NewOllamaProxy(hosted.URL, map[string]string{})
+	proxy.SetUpstreamAuth("openai", BearerAuth{}, "sk-hosted")
+	proxy.SetProtocol(protocolOpenAIResponses)
+	proxy.SetUpstreams([]LocalUpstream{{Name: "local", BaseURL: local.URL}}, []ModelRoute{{"qwen*", "local"}})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"qwen2.5-coder:7b","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+	proxy.handleMessages(httptest.NewRecorder(), req)
+
+	if seen["local"] != "/chat/completions " {
+		t.Errorf("Expected a chat completions request without credentials, got %q", seen["local"])
+	}
+	if _, ok := seen["hosted"]; ok {
+		t.Error("Expected the routed model kept off the hosted backend")
+	}
+}
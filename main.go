@@ -17,6 +17,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,6 +27,8 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
+
+	"nexus/internal/launch"
 )
 
 var version = "dev"
@@ -44,9 +47,6 @@ func getVersion() string {
 // Default timeout for API calls (50 minutes)
 const defaultTimeout = 50 * time.Minute
 
-// Health check HTTP timeout
-const healthCheckTimeout = 5 * time.Second
-
 // Progress bar widths
 const (
 	progressBarWidth = 40
@@ -55,7 +55,6 @@ const (
 
 // HTTP client and request timeouts
 const (
-	httpClientTimeout  = 10 * time.Second
 	maxResponseSize    = 10 * 1024 * 1024 // 10MB
 	maxArgLength       = 4096
 	maxModelNameLength = 128
@@ -148,6 +147,50 @@ func sanitizeArgs(args []string) []string {
 	return sanitized
 }
 
+// splitLaunchArgs splits a config value into arguments, honoring single and
+// double quotes so values like --append-system-prompt "be brief" stay intact
+func splitLaunchArgs(value string) ([]string, error) {
+	// Unwrap a value quoted as a whole, e.g. NEXUS_ARGS_CLAUDE="--model sonnet"
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] &&
+		!strings.ContainsRune(value[1:len(value)-1], rune(value[0])) {
+		value = value[1 : len(value)-1]
+	}
+
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
 // filterEnvironment returns only whitelisted environment variables
 func filterEnvironment(env []string) []string {
 	var filtered []string
@@ -171,7 +214,7 @@ func filterEnvironment(env []string) []string {
 
 // Shared HTTP client with connection pooling and secure TLS
 var httpClient = &http.Client{
-	Timeout: healthCheckTimeout,
+	Timeout: defaultHealthTimeout,
 	Transport: &http.Transport{
 		MaxIdleConns:        10,
 		MaxIdleConnsPerHost: 5,
@@ -279,18 +322,30 @@ type Backend struct {
 	OpusModel   string
 	// Coding capability tier (S/A/B/C)
 	CodingTier string
+	// Opus-tier pricing per 1M tokens (USD) when it differs from the base price
+	OpusInputPrice  float64
+	OpusOutputPrice float64
+	// How promptops's own requests authenticate; nil sends a bearer token
+	Auth AuthStrategy
+	// Protocol is the upstream API of backends Claude Code reaches through the
+	// translating proxy (protocolOpenAIChat, protocolOpenAIResponses); empty
+	// for Anthropic-compatible endpoints Claude Code calls directly
+	Protocol string
 }
 
 var backends = map[string]Backend{
 	"claude": {
-		Name:        "claude",
-		DisplayName: "Claude",
-		Provider:    "Anthropic",
-		Models:      "Claude Sonnet 4.5",
-		AuthVar:     "ANTHROPIC_API_KEY",
-		InputPrice:  3.00,
-		OutputPrice: 15.00,
-		CodingTier:  "S",
+		Name:            "claude",
+		DisplayName:     "Claude",
+		Provider:        "Anthropic",
+		Models:          "Claude Sonnet 4.5",
+		AuthVar:         "ANTHROPIC_API_KEY",
+		InputPrice:      3.00,
+		OutputPrice:     15.00,
+		CodingTier:      "S",
+		OpusInputPrice:  15.00,
+		OpusOutputPrice: 75.00,
+		Auth:            HeaderAuth{Header: "x-api-key"},
 	},
 	"zai": {
 		Name:        "zai",
@@ -366,6 +421,7 @@ var backends = map[string]Backend{
 		InputPrice:  2.00,
 		OutputPrice: 6.00,
 		CodingTier:  "B",
+		Protocol:    protocolOpenAIChat,
 	},
 	"groq": {
 		Name:        "groq",
@@ -398,34 +454,39 @@ var backends = map[string]Backend{
 		CodingTier:  "B",
 	},
 	"openrouter": {
-		Name:        "openrouter",
-		DisplayName: "OpenRouter",
-		Provider:    "OpenRouter",
-		Models:      "200+ models via meta-router",
-		AuthVar:     "OPENROUTER_API_KEY",
-		BaseURL:     "https://openrouter.ai/api/v1",
-		Timeout:     defaultTimeout,
-		HaikuModel:  "google/gemini-flash-1.5",
-		SonnetModel: "anthropic/claude-3.5-sonnet",
-		OpusModel:   "anthropic/claude-3-opus",
-		InputPrice:  3.00,
-		OutputPrice: 15.00,
-		CodingTier:  "A",
+		Name:            "openrouter",
+		DisplayName:     "OpenRouter",
+		Provider:        "OpenRouter",
+		Models:          "200+ models via meta-router",
+		AuthVar:         "OPENROUTER_API_KEY",
+		BaseURL:         "https://openrouter.ai/api/v1",
+		Timeout:         defaultTimeout,
+		HaikuModel:      "google/gemini-flash-1.5",
+		SonnetModel:     "anthropic/claude-3.5-sonnet",
+		OpusModel:       "anthropic/claude-3-opus",
+		InputPrice:      3.00,
+		OutputPrice:     15.00,
+		CodingTier:      "A",
+		OpusInputPrice:  15.00,
+		OpusOutputPrice: 75.00,
 	},
 	"openai": {
-		Name:        "openai",
-		DisplayName: "OpenAI",
-		Provider:    "OpenAI",
-		Models:      "GPT-4o / GPT-4o-mini / o1",
-		AuthVar:     "OPENAI_API_KEY",
-		BaseURL:     "https://api.openai.com/v1",
-		Timeout:     defaultTimeout,
-		HaikuModel:  "gpt-4o-mini",
-		SonnetModel: "gpt-4o",
-		OpusModel:   "o1",
-		InputPrice:  2.50,
-		OutputPrice: 10.00,
-		CodingTier:  "A",
+		Name:            "openai",
+		DisplayName:     "OpenAI",
+		Provider:        "OpenAI",
+		Models:          "GPT-4o / GPT-4o-mini / o1",
+		AuthVar:         "OPENAI_API_KEY",
+		BaseURL:         "https://api.openai.com/v1",
+		Timeout:         defaultTimeout,
+		HaikuModel:      "gpt-4o-mini",
+		SonnetModel:     "gpt-4o",
+		OpusModel:       "o1",
+		InputPrice:      2.50,
+		OutputPrice:     10.00,
+		CodingTier:      "A",
+		OpusInputPrice:  15.00,
+		OpusOutputPrice: 60.00,
+		Protocol:        protocolOpenAIResponses,
 	},
 	"grok": {
 		Name:        "grok",
@@ -441,6 +502,8 @@ var backends = map[string]Backend{
 		InputPrice:  0.20,
 		OutputPrice: 1.50,
 		CodingTier:  "A",
+		// xAI's Anthropic-compatible API accepts either header
+		Auth: AuthChain{HeaderAuth{Header: "X-Api-Key"}, BearerAuth{}},
 	},
 	"ollama": {
 		Name:        "ollama",
@@ -456,26 +519,35 @@ var backends = map[string]Backend{
 		InputPrice:  0.00,
 		OutputPrice: 0.00,
 		CodingTier:  "B",
+		Protocol:    protocolOpenAIChat,
 	},
 }
 
 type Config struct {
 	EnvFile        string
+	Environment    string // Named environment, empty for default
 	StateFile      string
 	AuditLog       string
 	UsageFile      string
 	SessionsFile   string
 	SessionFile    string
 	YoloMode       bool
-	YoloModes      map[string]bool // Per-backend YOLO mode settings
+	YoloModes      map[string]bool     // Per-backend YOLO mode settings
+	LaunchArgs     map[string][]string // Per-backend default Claude Code arguments
 	DefaultBackend string
 	VerifyOnSwitch bool
 	AuditEnabled   bool
-	Keys           map[string]string
+	// Chain usage and audit records with HMAC so edits and truncation are
+	// detected by audit verify (NEXUS_LEDGER_SIGNING)
+	LedgerSigning bool
+	LedgerKeyFile string
+	Keys          map[string]string
 	// Budget settings
 	DailyBudget   float64
 	WeeklyBudget  float64
 	MonthlyBudget float64
+	// Per-backend budgets (NEXUS_DAILY_BUDGET_<BACKEND> and so on)
+	BackendBudgets map[string]*BackendBudget
 	// Ollama model configuration (allows user to specify local models)
 	OllamaModels map[string]string // haiku/sonnet/opus -> model name
 	// Z.AI model configuration (allows user to specify GLM model versions)
@@ -484,6 +556,107 @@ type Config struct {
 	KimiModels map[string]string // haiku/sonnet/opus -> model name
 	// Grok model configuration (allows user to specify xAI model versions)
 	GrokModels map[string]string // haiku/sonnet/opus -> model name
+	// Cost-allocation tag defaults (NEXUS_COST_TAG_<NAME>), overridden by the
+	// workspace's .promptops/tags, and tags a launch requires
+	CostTags         map[string]string
+	RequiredCostTags []string
+	// Per-backend system prompt prefix/suffix (NEXUS_SYSTEM_PREFIX_<BACKEND>)
+	BackendPrompts map[string]BackendPrompt
+	// Generation parameters proxies enforce, by backend ("" for every
+	// backend): NEXUS_MAX_TOKENS, NEXUS_TEMPERATURE, NEXUS_TOP_P
+	Sampling map[string]SamplingDefaults
+	// Launch output level (NEXUS_QUIET, NEXUS_NO_ANIMATION)
+	Output OutputLevel
+	// Expensive launch guardrail (opus-tier output price per 1M tokens)
+	ConfirmExpensive   bool
+	ExpensiveThreshold float64
+	// Local prompt index for duplicate prompt hints (embeddings via Ollama)
+	PromptIndexEnabled bool
+	PromptIndexFile    string
+	PromptAnswerDir    string
+	EmbedURL           string
+	EmbedModel         string
+	DedupeThreshold    float64
+	// Recent proxy decisions for `promptops debug last`
+	DecisionsFile string
+	// Probed backend capabilities, reprobed after capabilityMaxAge
+	CapabilitiesFile string
+	// Environment and argv of the last Claude Code launch, secrets redacted
+	LaunchRecordFile string
+	// RateLimitFile holds provider cool-downs shared by concurrent instances
+	RateLimitFile string
+	// Health-based default backend selection (NEXUS_DEFAULT_BACKEND=auto)
+	AutoPreference []string
+	AutoChoiceFile string
+	// Proxy context compaction (0 threshold disables)
+	CompactThreshold int
+	CompactKeep      int
+	CompactModel     string
+	// Latency-based haiku tier demotion in the proxy (0 latency disables)
+	DemoteLatency  time.Duration
+	DemoteRequests int
+	DemoteModel    string
+	// Additional local upstreams for the proxy and the models routed to them
+	LocalUpstreams []LocalUpstream
+	ModelRoutes    []ModelRoute
+	// Optional remote tokenize endpoint and the model prefixes it serves
+	TokenizerURL    string
+	TokenizerModels []string
+	// Manually recorded prepaid balances and low balance warning percentages
+	CreditsFile        string
+	CreditWarnPercents []float64
+	// Network timeouts keyed by kind ("health") or kind and backend ("health:deepseek")
+	Timeouts map[string]time.Duration
+	// TimeoutOverride is set by --timeout and applies to health and usage checks
+	TimeoutOverride time.Duration
+	// Exact model versions pinned per backend and tier (NEXUS_PIN_<BACKEND>_<TIER>)
+	PinnedModels map[string]map[string]string
+	// Tier models of the current session keyed by backend, over pinned versions
+	SessionModels map[string]map[string]string
+	// User-defined model names (NEXUS_ALIAS_<NAME>=<backend>/<model>)
+	ModelAliases map[string]ModelAlias
+	// Model resolutions seen per backend, used to detect alias drift
+	ModelsFile string
+	// Repositories where backends that may train on inputs are refused ("*" for all)
+	NoTrainingRepos []string
+	// Regions each repository may send data to, by repo or "*" (NEXUS_DATA_REGIONS)
+	DataRegions map[string][]string
+	// Only warn about data region violations instead of refusing
+	DataRegionWarn bool
+	// Bearer token required by the local HTTP API (generated on first serve)
+	APITokenFile string
+	// Saved model catalogs per backend for offline browsing and change detection
+	ModelSnapshotsFile string
+	// Companion local services in env file order, their process state, and
+	// whether launches start them (NEXUS_SERVICES_AUTOSTART)
+	Services          map[string]*ServiceDef
+	ServiceOrder      []string
+	ServicesFile      string
+	ServicesAutostart bool
+	// Provider API version overrides keyed by backend (ANTHROPIC_VERSION)
+	APIVersions map[string]string
+	// Auth strategy overrides keyed by backend (NEXUS_AUTH_<BACKEND>)
+	AuthStrategies map[string]AuthStrategy
+	// Organization mode: keys are leased from a broker at launch
+	KeyBrokerURL   string
+	KeyBrokerToken string
+	KeyBrokerScope string
+	KeyLeaseTTL    time.Duration
+	// Print the cost of each proxied response to the terminal
+	CostAnnotations bool
+	// Keep the Ollama haiku and sonnet models loaded during a launch
+	WarmModels    bool
+	WarmKeepAlive time.Duration
+	// One-line session intents written by a local model (NEXUS_SESSION_SUMMARIES)
+	SessionSummaries bool
+	SummaryModel     string
+	// Mistral fill-in-the-middle endpoint (CODESTRAL_API_KEY selects it) and model
+	CodestralURL string
+	FIMModel     string
+	// Synthetic latency and upstream errors injected by the proxies (NEXUS_CHAOS)
+	Chaos ChaosConfig
+	// Time-windowed prices per backend (NEXUS_OFFPEAK_<BACKEND>)
+	PriceWindows map[string][]PriceWindow
 }
 
 // UsageRecord represents a single API usage entry
@@ -495,6 +668,17 @@ type UsageRecord struct {
 	InputTokens  int64     `json:"input_tokens"`
 	OutputTokens int64     `json:"output_tokens"`
 	CostUSD      float64   `json:"cost_usd"`
+	Tier         string    `json:"tier,omitempty"`     // haiku, sonnet or opus, when known
+	Upstream     string    `json:"upstream,omitempty"` // Local server that served a proxied request
+	Repo         string    `json:"repo,omitempty"`
+	Branch       string    `json:"branch,omitempty"`
+	Commit       string    `json:"commit,omitempty"`
+	// Tags are the workspace's cost-allocation tags (team, project code)
+	Tags map[string]string `json:"tags,omitempty"`
+	// TimedOut is set when the request exceeded the latency budget
+	TimedOut bool `json:"timed_out,omitempty"`
+	// PID is the promptops process that wrote the record
+	PID int `json:"pid,omitempty"`
 }
 
 // Session represents a named working session
@@ -508,6 +692,24 @@ type Session struct {
 	PromptCount int       `json:"prompt_count"`
 	TotalCost   float64   `json:"total_cost"`
 	Status      string    `json:"status"` // active, paused, closed
+	// LastRun is the exit summary of the most recent Claude Code run
+	LastRun *RunSummary `json:"last_run,omitempty"`
+	// Intent is a one-line summary of the session's first prompt
+	Intent string `json:"intent,omitempty"`
+	// IntentAttempted is set once a summary has been started, so a failed
+	// summary is not retried on every prompt
+	IntentAttempted bool `json:"intent_attempted,omitempty"`
+	// Models are tier models (haiku, sonnet, opus) used for Backend while
+	// this is the current session, over the global configuration
+	Models map[string]string `json:"models,omitempty"`
+	// NoBackendPrompt turns off the backend's system prompt prefix/suffix
+	// for launches while this is the current session
+	NoBackendPrompt bool `json:"no_backend_prompt,omitempty"`
+	// MigratedFrom names the session this one continues on another backend
+	MigratedFrom string `json:"migrated_from,omitempty"`
+	// Handoff is the summary of MigratedFrom's conversation added to the
+	// system prompt of this session's launches
+	Handoff string `json:"handoff,omitempty"`
 }
 
 // HealthResult represents the result of a backend health check
@@ -519,59 +721,32 @@ type HealthResult struct {
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		showStatus()
-		return
+	cmd, name, args, opts, err := parseCommandLine(commandTable(), os.Args[1:])
+	if errors.Is(err, errUnknownCommand) {
+		unknownCommandError(name)
+		os.Exit(1)
 	}
-
-	cmd := os.Args[1]
-	args := os.Args[2:]
-
-	switch cmd {
-	case "claude", "zai", "kimi", "deepseek", "gemini", "mistral", "groq", "grok", "together", "openrouter", "openai", "ollama":
-		switchBackend(cmd, args)
-	case "status", "current":
-		showStatus()
-	case "run", "launch":
-		runClaude(args)
-	case "init", "setup":
-		initEnv()
-	case "version", "--version", "-v":
-		showVersion()
-	case "help", "--help", "-h":
-		showHelp()
-	// Cost tracking commands
-	case "cost":
-		if len(args) > 0 && args[0] == "log" {
-			showCostLog()
-		} else {
-			showCostDashboard()
-		}
-	// Budget management commands
-	case "budget":
-		handleBudgetCommand(args)
-	// Environment validation commands
-	case "doctor":
-		runDoctor()
-	case "validate":
-		if len(args) < 1 {
-			fmt.Fprintln(os.Stderr, "Error: validate requires a backend name")
-			os.Exit(1)
-		}
-		validateBackend(args[0])
-	// Session management commands
-	case "session":
-		handleSessionCommand(args)
-	// Usage command - fetch real API usage from providers
-	case "usage":
-		showAPIUsage(args)
-	default:
-		fmt.Fprintf(os.Stderr, "Error: Unknown command '%s'. Run 'promptops help' for usage.\n", cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	globalOpts = opts
+	if err := selectEnvironment(opts.Env); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	// Launch arguments go to Claude Code, including --help
+	if !cmd.Launch && slices.Contains(args, "--help") {
+		showHelp([]string{name})
+		return
+	}
+	cmd.Run(args)
 }
 
 func getScriptDir() (string, error) {
+	if globalOpts.ConfigDir != "" {
+		return globalOpts.ConfigDir, nil
+	}
 	ex, err := os.Executable()
 	if err != nil {
 		// Fallback to working directory if executable path unavailable
@@ -591,7 +766,10 @@ func loadConfig() *Config {
 		os.Exit(1)
 	}
 	envFile := os.Getenv("NEXUS_ENV_FILE")
-	if envFile != "" {
+	if activeEnv != "" {
+		// Named environments keep their keys and budgets next to the default env file
+		envFile = filepath.Join(dir, envFileName(activeEnv))
+	} else if envFile != "" {
 		// Validate to prevent path traversal using EvalSymlinks
 		cleanPath := filepath.Clean(envFile)
 		absPath, err := filepath.Abs(cleanPath)
@@ -630,13 +808,18 @@ func loadConfig() *Config {
 
 	cfg := &Config{
 		EnvFile:        envFile,
-		StateFile:      filepath.Join(dir, "state"),
-		AuditLog:       filepath.Join(dir, ".promptops-audit.log"),
-		UsageFile:      filepath.Join(dir, ".promptops-usage.jsonl"),
-		SessionsFile:   filepath.Join(dir, ".promptops-sessions.json"),
-		SessionFile:    filepath.Join(dir, "session"),
+		Environment:    activeEnv,
+		StateFile:      filepath.Join(dir, envScopedName("state", activeEnv)),
+		AuditLog:       filepath.Join(dir, envScopedName(".promptops-audit.log", activeEnv)),
+		UsageFile:      filepath.Join(dir, envScopedName(".promptops-usage.jsonl", activeEnv)),
+		SessionsFile:   filepath.Join(dir, envScopedName(".promptops-sessions.json", activeEnv)),
+		SessionFile:    filepath.Join(dir, envScopedName("session", activeEnv)),
+		LedgerKeyFile:  filepath.Join(dir, ".promptops-ledger.key"),
 		Keys:           make(map[string]string),
 		YoloModes:      make(map[string]bool),
+		LaunchArgs:     make(map[string][]string),
+		Timeouts:       make(map[string]time.Duration),
+		PinnedModels:   make(map[string]map[string]string),
 		OllamaModels:   make(map[string]string),
 		ZAIModels:      make(map[string]string),
 		KimiModels:     make(map[string]string),
@@ -647,6 +830,27 @@ func loadConfig() *Config {
 		DailyBudget:    10.00,
 		WeeklyBudget:   50.00,
 		MonthlyBudget:  100.00,
+
+		ExpensiveThreshold: defaultExpensiveThreshold,
+		PromptIndexFile:    filepath.Join(dir, envScopedName(".promptops-prompts.jsonl", activeEnv)),
+		PromptAnswerDir:    filepath.Join(dir, envScopedName(".promptops-answers", activeEnv)),
+		DecisionsFile:      filepath.Join(dir, envScopedName(".promptops-decisions.jsonl", activeEnv)),
+		CapabilitiesFile:   filepath.Join(dir, envScopedName(".promptops-capabilities.json", activeEnv)),
+		LaunchRecordFile:   filepath.Join(dir, envScopedName(".promptops-last-launch.json", activeEnv)),
+		RateLimitFile:      filepath.Join(dir, envScopedName(".promptops-ratelimits.json", activeEnv)),
+		EmbedURL:           defaultEmbedURL,
+		EmbedModel:         defaultEmbedModel,
+		DedupeThreshold:    defaultDedupeThreshold,
+		AutoChoiceFile:     filepath.Join(dir, envScopedName(".promptops-auto", activeEnv)),
+		CompactKeep:        defaultCompactKeep,
+		DemoteRequests:     defaultDemoteRequests,
+		CreditsFile:        filepath.Join(dir, envScopedName(".promptops-credits.json", activeEnv)),
+		CreditWarnPercents: defaultCreditWarnPercents,
+		ModelsFile:         filepath.Join(dir, envScopedName(".promptops-models.json", activeEnv)),
+		APITokenFile:       filepath.Join(dir, envScopedName(".promptops-api-token", activeEnv)),
+		ModelSnapshotsFile: filepath.Join(dir, envScopedName(".promptops-model-snapshots.json", activeEnv)),
+		Services:           make(map[string]*ServiceDef),
+		ServicesFile:       filepath.Join(dir, envScopedName(".promptops-services.json", activeEnv)),
 	}
 
 	// Parse .env.local
@@ -695,6 +899,12 @@ func loadConfig() *Config {
 				cfg.YoloModes["ollama"] = value == "true"
 			case "NEXUS_DEFAULT_BACKEND":
 				cfg.DefaultBackend = value
+			case "NEXUS_AUTO_BACKENDS":
+				if list, err := parseBackendList(value); err == nil {
+					cfg.AutoPreference = list
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_AUTO_BACKENDS value '%s': %v\n", value, err)
+				}
 			case "NEXUS_VERIFY_ON_SWITCH":
 				cfg.VerifyOnSwitch = value == "true"
 			case "NEXUS_AUDIT_LOG":
@@ -717,7 +927,203 @@ func loadConfig() *Config {
 				} else {
 					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_MONTHLY_BUDGET value '%s': %v\n", value, err)
 				}
-			case "ANTHROPIC_API_KEY", "ZAI_API_KEY", "KIMI_API_KEY", "DEEPSEEK_API_KEY", "GEMINI_API_KEY", "MISTRAL_API_KEY", "GROQ_API_KEY", "GROK_API_KEY", "TOGETHER_API_KEY", "OPENROUTER_API_KEY", "OPENAI_API_KEY", "OLLAMA_API_KEY":
+			case "NEXUS_SERVICES_AUTOSTART":
+				cfg.ServicesAutostart = value == "true"
+			case "NEXUS_REQUIRED_COST_TAGS":
+				cfg.RequiredCostTags = nil
+				for _, name := range strings.Split(value, ",") {
+					name = strings.ToLower(strings.TrimSpace(name))
+					if name == "" {
+						continue
+					}
+					if !costTagNamePattern.MatchString(name) {
+						fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_REQUIRED_COST_TAGS value '%s': invalid tag name '%s'\n", value, name)
+						continue
+					}
+					cfg.RequiredCostTags = append(cfg.RequiredCostTags, name)
+				}
+			case "NEXUS_QUIET":
+				if value == "true" {
+					cfg.raiseOutput(outputQuiet)
+				}
+			case "NEXUS_NO_ANIMATION":
+				if value == "true" {
+					cfg.raiseOutput(outputNoAnimation)
+				}
+			case "NEXUS_CONFIRM_EXPENSIVE":
+				cfg.ConfirmExpensive = value == "true"
+			case "NEXUS_EXPENSIVE_THRESHOLD":
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					cfg.ExpensiveThreshold = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_EXPENSIVE_THRESHOLD value '%s': %v\n", value, err)
+				}
+			case "NEXUS_PROMPT_INDEX":
+				cfg.PromptIndexEnabled = value == "true"
+			case "NEXUS_EMBED_URL":
+				cfg.EmbedURL = value
+			case "NEXUS_EMBED_MODEL":
+				if err := validateModelName(value); err == nil {
+					cfg.EmbedModel = value
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_EMBED_MODEL value: %v\n", err)
+				}
+			case "NEXUS_COMPACT_THRESHOLD":
+				if v, err := strconv.Atoi(value); err == nil && v >= 0 {
+					cfg.CompactThreshold = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_COMPACT_THRESHOLD value '%s' (must be a token count)\n", value)
+				}
+			case "NEXUS_COMPACT_KEEP":
+				if v, err := strconv.Atoi(value); err == nil && v > 0 {
+					cfg.CompactKeep = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_COMPACT_KEEP value '%s' (must be positive)\n", value)
+				}
+			case "NEXUS_COMPACT_MODEL":
+				if err := validateModelName(value); err == nil {
+					cfg.CompactModel = value
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_COMPACT_MODEL value '%s': %v\n", value, err)
+				}
+			case "NEXUS_DEMOTE_LATENCY":
+				if d, err := parseTimeout(value); err == nil {
+					cfg.DemoteLatency = d
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_DEMOTE_LATENCY value '%s': %v\n", value, err)
+				}
+			case "NEXUS_DEMOTE_REQUESTS":
+				if v, err := strconv.Atoi(value); err == nil && v > 0 {
+					cfg.DemoteRequests = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_DEMOTE_REQUESTS value '%s' (must be positive)\n", value)
+				}
+			case "NEXUS_LOCAL_UPSTREAMS":
+				if v, err := parseLocalUpstreams(value); err == nil {
+					cfg.LocalUpstreams = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_LOCAL_UPSTREAMS value '%s': %v\n", value, err)
+				}
+			case "NEXUS_LOCAL_ROUTES":
+				if v, err := parseModelRoutes(value); err == nil {
+					cfg.ModelRoutes = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_LOCAL_ROUTES value '%s': %v\n", value, err)
+				}
+			case "NEXUS_KEY_BROKER_URL":
+				if err := validateBrokerURL(value); err == nil {
+					cfg.KeyBrokerURL = value
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_KEY_BROKER_URL value '%s': %v\n", value, err)
+				}
+			case "NEXUS_KEY_BROKER_TOKEN":
+				cfg.KeyBrokerToken = value
+			case "NEXUS_KEY_BROKER_SCOPE":
+				cfg.KeyBrokerScope = value
+			case "NEXUS_KEY_LEASE_TTL":
+				if d, err := parseTimeout(value); err == nil {
+					cfg.KeyLeaseTTL = d
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_KEY_LEASE_TTL value '%s': %v\n", value, err)
+				}
+			case "NEXUS_COST_ANNOTATIONS":
+				cfg.CostAnnotations = value == "true"
+			case "NEXUS_LEDGER_SIGNING":
+				cfg.LedgerSigning = value == "true"
+			case "NEXUS_LEDGER_KEY_FILE":
+				if !filepath.IsAbs(value) {
+					fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': must be an absolute path\n", key, value)
+				} else {
+					cfg.LedgerKeyFile = value
+				}
+			case "NEXUS_WARM_MODELS":
+				cfg.WarmModels = value == "true"
+			case "NEXUS_SESSION_SUMMARIES":
+				cfg.SessionSummaries = value == "true"
+			case "NEXUS_SUMMARY_MODEL":
+				if err := validateModelName(value); err == nil {
+					cfg.SummaryModel = value
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_SUMMARY_MODEL value '%s': %v\n", value, err)
+				}
+			case "NEXUS_CODESTRAL_URL":
+				if err := validateCodestralURL(value); err == nil {
+					cfg.CodestralURL = strings.TrimRight(value, "/")
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_CODESTRAL_URL value '%s': %v\n", value, err)
+				}
+			case "NEXUS_FIM_MODEL":
+				if err := validateModelName(value); err == nil {
+					cfg.FIMModel = value
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_FIM_MODEL value '%s': %v\n", value, err)
+				}
+			case "NEXUS_WARM_KEEPALIVE":
+				if d, err := parseTimeout(value); err == nil {
+					cfg.WarmKeepAlive = d
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_WARM_KEEPALIVE value '%s': %v\n", value, err)
+				}
+			case "NEXUS_CHAOS":
+				if v, err := parseChaos(value); err == nil {
+					cfg.Chaos = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_CHAOS value '%s': %v\n", value, err)
+				}
+			case "NEXUS_DEMOTE_MODEL":
+				if err := validateModelName(value); err == nil {
+					cfg.DemoteModel = value
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_DEMOTE_MODEL value '%s': %v\n", value, err)
+				}
+			case "NEXUS_CREDIT_WARN":
+				if v, err := parseCreditWarn(value); err == nil {
+					cfg.CreditWarnPercents = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_CREDIT_WARN value '%s': %v\n", value, err)
+				}
+			case "NEXUS_NO_TRAINING_REPOS":
+				cfg.NoTrainingRepos = nil
+				for _, repo := range strings.Split(value, ",") {
+					if repo = strings.TrimSpace(repo); repo != "" {
+						cfg.NoTrainingRepos = append(cfg.NoTrainingRepos, repo)
+					}
+				}
+			case "NEXUS_DATA_REGIONS":
+				if v, err := parseDataRegions(value); err == nil {
+					cfg.DataRegions = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_DATA_REGIONS value '%s': %v\n", value, err)
+				}
+			case "NEXUS_DATA_REGION_MODE":
+				switch strings.ToLower(value) {
+				case "block":
+					cfg.DataRegionWarn = false
+				case "warn":
+					cfg.DataRegionWarn = true
+				default:
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_DATA_REGION_MODE value '%s': expected block or warn\n", value)
+				}
+			case "NEXUS_TOKENIZER_URL":
+				cfg.TokenizerURL = value
+			case "NEXUS_TOKENIZER_MODELS":
+				cfg.TokenizerModels = nil
+				for _, prefix := range strings.Split(value, ",") {
+					if prefix = strings.TrimSpace(prefix); prefix != "" {
+						cfg.TokenizerModels = append(cfg.TokenizerModels, prefix)
+					}
+				}
+			case "NEXUS_DEDUPE_THRESHOLD":
+				if v, err := strconv.ParseFloat(value, 64); err == nil && v > 0 && v <= 1 {
+					cfg.DedupeThreshold = v
+				} else {
+					fmt.Fprintf(os.Stderr, "Warning: invalid NEXUS_DEDUPE_THRESHOLD value '%s' (must be between 0 and 1)\n", value)
+				}
+			case "ANTHROPIC_VERSION", "OPENAI_API_VERSION":
+				if _, err := setAPIVersionOverride(cfg, key, value); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, value, err)
+				}
+			case "ANTHROPIC_API_KEY", "ZAI_API_KEY", "KIMI_API_KEY", "DEEPSEEK_API_KEY", "GEMINI_API_KEY", "MISTRAL_API_KEY", "CODESTRAL_API_KEY", "GROQ_API_KEY", "GROK_API_KEY", "TOGETHER_API_KEY", "OPENROUTER_API_KEY", "OPENAI_API_KEY", "OLLAMA_API_KEY":
 				cfg.Keys[key] = value
 			// Ollama model configuration - allow custom local models
 			case "OLLAMA_HAIKU_MODEL":
@@ -747,10 +1153,167 @@ func loadConfig() *Config {
 				cfg.GrokModels["sonnet"] = value
 			case "GROK_OPUS_MODEL":
 				cfg.GrokModels["opus"] = value
+			default:
+				// Network timeouts, e.g. NEXUS_HEALTH_TIMEOUT or NEXUS_USAGE_TIMEOUT_KIMI
+				if kind, name, ok := parseTimeoutKey(key); ok {
+					if _, known := backends[name]; name != "" && !known {
+						fmt.Fprintf(os.Stderr, "Warning: unknown backend in %s\n", key)
+						continue
+					}
+					d, err := parseTimeout(value)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, value, err)
+						continue
+					}
+					if name != "" {
+						kind += ":" + name
+					}
+					cfg.Timeouts[kind] = d
+					continue
+				}
+				// Pinned model versions, e.g. NEXUS_PIN_CLAUDE_SONNET
+				if name, tier, ok := parsePinKey(key); ok {
+					if _, known := backends[name]; !known {
+						fmt.Fprintf(os.Stderr, "Warning: unknown backend in %s\n", key)
+						continue
+					}
+					if tier != "haiku" && tier != "sonnet" && tier != "opus" {
+						fmt.Fprintf(os.Stderr, "Warning: unknown tier in %s (use HAIKU, SONNET or OPUS)\n", key)
+						continue
+					}
+					if err := validateModelName(value); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, value, err)
+						continue
+					}
+					if cfg.PinnedModels[name] == nil {
+						cfg.PinnedModels[name] = make(map[string]string)
+					}
+					cfg.PinnedModels[name][tier] = value
+					continue
+				}
+				// Per-backend budgets, e.g. NEXUS_DAILY_BUDGET_CLAUDE=5.00
+				if period, name, ok := parseBudgetKey(key); ok {
+					if _, known := backends[name]; !known {
+						fmt.Fprintf(os.Stderr, "Warning: unknown backend in %s\n", key)
+						continue
+					}
+					v, err := strconv.ParseFloat(value, 64)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, value, err)
+						continue
+					}
+					setBackendBudget(cfg, period, name, v)
+					continue
+				}
+				// Auth strategies, e.g. NEXUS_AUTH_GEMINI=query:key
+				if name, ok := strings.CutPrefix(key, "NEXUS_AUTH_"); ok {
+					name = strings.ToLower(name)
+					if _, known := backends[name]; !known {
+						fmt.Fprintf(os.Stderr, "Warning: unknown backend in %s\n", key)
+						continue
+					}
+					strategy, err := parseAuthStrategy(value)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, value, err)
+						continue
+					}
+					if cfg.AuthStrategies == nil {
+						cfg.AuthStrategies = make(map[string]AuthStrategy)
+					}
+					cfg.AuthStrategies[name] = strategy
+					continue
+				}
+				// Off-peak prices, e.g. NEXUS_OFFPEAK_DEEPSEEK=16:30-00:30=0.135/0.55
+				if name, ok := strings.CutPrefix(key, "NEXUS_OFFPEAK_"); ok {
+					name = strings.ToLower(name)
+					if _, known := backends[name]; !known {
+						fmt.Fprintf(os.Stderr, "Warning: unknown backend in %s\n", key)
+						continue
+					}
+					windows, err := parsePriceWindows(value)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, value, err)
+						continue
+					}
+					if cfg.PriceWindows == nil {
+						cfg.PriceWindows = make(map[string][]PriceWindow)
+					}
+					cfg.PriceWindows[name] = windows
+					continue
+				}
+				// Model aliases, e.g. NEXUS_ALIAS_MYFAST=groq/llama-3.3-70b-versatile
+				if name, ok := strings.CutPrefix(key, "NEXUS_ALIAS_"); ok {
+					name = strings.ToLower(name)
+					alias, err := parseModelAlias(name, value)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, value, err)
+						continue
+					}
+					if cfg.ModelAliases == nil {
+						cfg.ModelAliases = make(map[string]ModelAlias)
+					}
+					cfg.ModelAliases[name] = alias
+					continue
+				}
+				// Cost-allocation tags, e.g. NEXUS_COST_TAG_TEAM=platform
+				if name, ok := strings.CutPrefix(key, "NEXUS_COST_TAG_"); ok {
+					name = strings.ToLower(name)
+					if err := validateCostTag(name, value); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, value, err)
+						continue
+					}
+					if cfg.CostTags == nil {
+						cfg.CostTags = make(map[string]string)
+					}
+					cfg.CostTags[name] = value
+					continue
+				}
+				// Sampling parameters, e.g. NEXUS_MAX_TOKENS or NEXUS_TEMPERATURE_OLLAMA
+				if param, name, ok := parseSamplingKey(key); ok {
+					if err := setSamplingKey(cfg, param, name, value); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, value, err)
+					}
+					continue
+				}
+				// Per-backend system text, e.g. NEXUS_SYSTEM_PREFIX_OLLAMA
+				if strings.HasPrefix(key, "NEXUS_SYSTEM_PREFIX_") || strings.HasPrefix(key, "NEXUS_SYSTEM_SUFFIX_") {
+					if err := setBackendPromptKey(cfg, key, value); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value: %v\n", key, err)
+					}
+					continue
+				}
+				// Companion local services, e.g. NEXUS_SERVICE_OLLAMA=ollama serve
+				if strings.HasPrefix(key, "NEXUS_SERVICE_") {
+					if err := setServiceKey(cfg, key, strings.TrimSpace(parts[1])); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, parts[1], err)
+					}
+					continue
+				}
+				// Per-backend default Claude Code arguments, e.g. NEXUS_ARGS_OLLAMA
+				if name := strings.ToLower(strings.TrimPrefix(key, "NEXUS_ARGS_")); name != strings.ToLower(key) {
+					if _, ok := backends[name]; !ok {
+						fmt.Fprintf(os.Stderr, "Warning: unknown backend in %s\n", key)
+						continue
+					}
+					launchArgs, err := splitLaunchArgs(strings.TrimSpace(parts[1]))
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, parts[1], err)
+						continue
+					}
+					cfg.LaunchArgs[name] = launchArgs
+				}
 			}
 		}
 	}
 
+	// Global flags override the env file
+	if globalOpts.Quiet {
+		cfg.raiseOutput(outputQuiet)
+	}
+	cfg.TimeoutOverride = globalOpts.Timeout
+
+	resolveConfigAliases(cfg)
+	configureTokenizers(cfg)
 	return cfg
 }
 
@@ -883,16 +1446,6 @@ func auditLog(cfg *Config, msg string) {
 	if !cfg.AuditEnabled {
 		return
 	}
-	f, err := os.OpenFile(cfg.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to open audit log: %v\n", err)
-		return
-	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to close audit log: %v\n", err)
-		}
-	}()
 
 	// Include session ID if available
 	session := getCurrentSession(cfg)
@@ -900,7 +1453,9 @@ func auditLog(cfg *Config, msg string) {
 		msg = fmt.Sprintf("[%s] %s", session.Name, msg)
 	}
 
-	if _, err := fmt.Fprintf(f, "[%s] %s\n", time.Now().Format(time.RFC3339), msg); err != nil {
+	// One line per entry keeps signed entries verifiable
+	line := fmt.Sprintf("[%s] %s", time.Now().Format(time.RFC3339), strings.ReplaceAll(msg, "\n", " "))
+	if err := appendLedgerLines(cfg, cfg.AuditLog, [][]byte{[]byte(line)}); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to write audit log: %v\n", err)
 	}
 }
@@ -1037,21 +1592,22 @@ func switchBackend(name string, args []string) {
 	cfg := loadConfig()
 	be, ok := backends[name]
 	if !ok {
-		fmt.Fprintf(os.Stderr, "Error: Unknown backend '%s'\n", name)
+		unknownBackendError(name)
 		os.Exit(1)
 	}
 
-	// Check for API key (not required for local backends like Ollama)
+	// Check for API key (not required for local backends like Ollama, or
+	// when keys are leased from the key broker at launch)
 	apiKey := cfg.Keys[be.AuthVar]
-	if apiKey == "" && be.Name != "ollama" {
+	if apiKey == "" && be.Name != "ollama" && cfg.KeyBrokerURL == "" {
 		fmt.Fprintf(os.Stderr, "Error: %s not set in .env.local\n", be.AuthVar)
 		os.Exit(1)
 	}
 
-	yolo := cfg.getYoloMode(name)
+	args = parseOutputFlags(cfg, args)
 
 	// Animations
-	if !yolo {
+	if cfg.animate() {
 		// Animation messages for all backends
 		animMsgs := map[string]string{
 			"claude":     "Initializing neural pathways...",
@@ -1092,6 +1648,13 @@ func switchBackend(name string, args []string) {
 		}
 	}
 
+	// Bring up local services, then verify the backend is reachable,
+	// offering alternatives if it is not
+	autostartServices(cfg)
+	be = verifyBeforeLaunch(cfg, be)
+	name = be.Name
+	apiKey = cfg.Keys[be.AuthVar]
+
 	// Save state
 	if err := setCurrentBackend(cfg, name); err != nil {
 		fmt.Fprintf(os.Stderr, "Error saving state: %v\n", err)
@@ -1101,7 +1664,7 @@ func switchBackend(name string, args []string) {
 	// Audit log - never log API keys even masked
 	auditLog(cfg, fmt.Sprintf("SWITCH: %s", name))
 
-	if !yolo {
+	if cfg.verbose() {
 		fmt.Println()
 		drawBox(fmt.Sprintf("%s BACKEND ACTIVE", strings.ToUpper(be.DisplayName)))
 		fmt.Printf("  Provider: %s\n", be.Provider)
@@ -1122,168 +1685,462 @@ func switchBackend(name string, args []string) {
 	launchClaudeWithBackend(cfg, be, args)
 }
 
+// launchClaudeWithBackend launches Claude Code against be and exits with its
+// exit code when it fails
 func launchClaudeWithBackend(cfg *Config, be Backend, args []string) {
+	code, err := runLaunch(cfg, be, args, newClaudeRunner)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// runLaunch runs Claude Code against be through the runner newRunner returns
+// for the run's time limit, and returns Claude Code's exit code. Proxies the
+// backend needs run for the length of the launch.
+func runLaunch(cfg *Config, be Backend, args []string, newRunner func(limit time.Duration) launch.Runner) (int, error) {
 	cmdArgs := []string{}
 
-	yolo := cfg.getYoloMode(be.Name)
-	if yolo {
+	// The current session's own tier models replace the global ones
+	if session := applySessionModels(cfg); cfg.verbose() {
+		announceSessionModels(session, be)
+	}
+
+	// Expensive launch guardrail runs even in YOLO mode
+	args, forceConfirm := stripLeadingFlag(args, "--confirm-expensive")
+	args, timeLimit, err := parseForFlag(args)
+	if err != nil {
+		return 1, err
+	}
+	if (cfg.ConfirmExpensive || forceConfirm) && isExpensiveLaunch(cfg, be) {
+		if !confirmExpensiveLaunch(cfg, be, bufio.NewReader(os.Stdin)) {
+			auditLog(cfg, fmt.Sprintf("LAUNCH_DECLINED: %s (expensive opus tier)", be.Name))
+			fmt.Println("Launch cancelled.")
+			return 1, nil
+		}
+	}
+	git := currentGitInfo()
+	if err := checkTrainingPolicy(cfg, be, git); err != nil {
+		auditLog(cfg, fmt.Sprintf("LAUNCH_BLOCKED: %s (training policy)", be.Name))
+		return 1, err
+	}
+	if err := enforceRegionPolicy(cfg, be, git); err != nil {
+		auditLog(cfg, fmt.Sprintf("LAUNCH_BLOCKED: %s (data region policy)", be.Name))
+		return 1, err
+	}
+	if err := checkCostTags(cfg); err != nil {
+		auditLog(cfg, fmt.Sprintf("LAUNCH_BLOCKED: %s (missing cost tags)", be.Name))
+		return 1, err
+	}
+	if err := checkBackendBudget(cfg, be); err != nil {
+		auditLog(cfg, fmt.Sprintf("LAUNCH_BLOCKED: %s (backend budget)", be.Name))
+		return 1, err
+	}
+	warnLowCredit(cfg, be)
+	warnDataHealth(cfg)
+
+	// Organization mode replaces the stored key with a short-lived lease
+	lease, err := acquireLaunchLease(cfg, be)
+	if err != nil {
+		return 1, err
+	}
+	if lease != nil {
+		defer releaseLaunchLease(cfg, be, lease)
+	}
+
+	// Probe what the backend supports on first use; later launches use the cache
+	if caps, probed := ensureCapabilities(cfg, be); probed {
+		for _, warning := range capabilityWarnings(cfg, be, caps) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+	}
+
+	if lease != nil && cfg.verbose() {
+		fmt.Printf("[OK] Leased %s key from the key broker (expires %s)\n", be.DisplayName, lease.Expires().Local().Format("15:04"))
+	}
+	if cfg.getYoloMode(be.Name) {
 		cmdArgs = append(cmdArgs, "--dangerously-skip-permissions")
 	}
 
+	// Backend default arguments go before user arguments so the user can override them
+	cmdArgs = append(cmdArgs, sanitizeArgs(cfg.LaunchArgs[be.Name])...)
+
 	// Sanitize user-provided arguments
 	sanitizedArgs := sanitizeArgs(args)
 	cmdArgs = append(cmdArgs, sanitizedArgs...)
 
-	cmd := exec.Command("claude", cmdArgs...)
-
-	// Build environment with whitelist approach
-	env := filterEnvironment(os.Environ())
-
-	// Set auth token for Claude Code
-	// Note: For backends like Ollama that don't require API keys, we still need
-	// to set ANTHROPIC_AUTH_TOKEN for Claude Code itself
-	apiKey := cfg.Keys[be.AuthVar]
-	if apiKey != "" {
-		env = append(env, fmt.Sprintf("ANTHROPIC_AUTH_TOKEN=%s", apiKey))
-	} else if be.Name == "ollama" {
-		// Ollama doesn't require an API key, but Claude Code still needs
-		// ANTHROPIC_AUTH_TOKEN to be set when using a custom base URL
-		env = append(env, "ANTHROPIC_AUTH_TOKEN=ollama")
+	// Provider-specific system text and project conventions from
+	// .promptops/system.md go through the local proxy when there is one,
+	// otherwise to Claude Code itself
+	backendPrompt := launchBackendPrompt(cfg, be)
+	// A migrated session carries a summary of its earlier conversation
+	backendPrompt.Suffix = appendSystemText(backendPrompt.Suffix, sessionHandoff(cfg))
+	primer := projectContextPrimer(cfg)
+	if _, proxied := launchProxyPorts[be.Name]; !proxied {
+		// The proxies put the primer between the prefix and the suffix
+		direct := backendPrompt
+		direct.Suffix = appendSystemText(primer, backendPrompt.Suffix)
+		cmdArgs = appendPromptArgs(direct, cmdArgs)
 	}
 
-	// Set backend-specific vars
+	// Invalid tier models stop the launch before any proxy starts
+	if _, err := buildLaunchEnv(cfg, be, nil, ""); err != nil {
+		return 1, err
+	}
 	baseURL := be.BaseURL
-	if be.BaseURL != "" {
-		env = append(env, fmt.Sprintf("API_TIMEOUT_MS=%d", be.Timeout.Milliseconds()))
 
-		// Use custom Ollama models if configured, otherwise use defaults
-		haikuModel := be.HaikuModel
-		sonnetModel := be.SonnetModel
-		opusModel := be.OpusModel
-
-		if be.Name == "ollama" {
-			if m, ok := cfg.OllamaModels["haiku"]; ok && m != "" {
-				haikuModel = strings.TrimSpace(m)
-			}
-			if m, ok := cfg.OllamaModels["sonnet"]; ok && m != "" {
-				sonnetModel = strings.TrimSpace(m)
-			}
-			if m, ok := cfg.OllamaModels["opus"]; ok && m != "" {
-				opusModel = strings.TrimSpace(m)
-			}
-		}
-
-		if be.Name == "zai" {
-			if m, ok := cfg.ZAIModels["haiku"]; ok && m != "" {
-				haikuModel = strings.TrimSpace(m)
-			}
-			if m, ok := cfg.ZAIModels["sonnet"]; ok && m != "" {
-				sonnetModel = strings.TrimSpace(m)
-			}
-			if m, ok := cfg.ZAIModels["opus"]; ok && m != "" {
-				opusModel = strings.TrimSpace(m)
-			}
+	if _, proxied := launchProxyPorts[be.Name]; proxied {
+		announceChaos(cfg)
+	} else {
+		if cfg.Chaos.Enabled() {
+			fmt.Fprintf(os.Stderr, "Warning: NEXUS_CHAOS only applies to backends served through a local proxy, not %s\n", be.DisplayName)
 		}
-
-		if be.Name == "kimi" {
-			if m, ok := cfg.KimiModels["haiku"]; ok && m != "" {
-				haikuModel = strings.TrimSpace(m)
-			}
-			if m, ok := cfg.KimiModels["sonnet"]; ok && m != "" {
-				sonnetModel = strings.TrimSpace(m)
-			}
-			if m, ok := cfg.KimiModels["opus"]; ok && m != "" {
-				opusModel = strings.TrimSpace(m)
-			}
+		if cfg.hasLatencyBudget(be.Name) {
+			fmt.Fprintf(os.Stderr, "Warning: NEXUS_LATENCY_BUDGET only applies to backends served through a local proxy, not %s\n", be.DisplayName)
 		}
-
-		if be.Name == "grok" {
-			if m, ok := cfg.GrokModels["haiku"]; ok && m != "" {
-				haikuModel = strings.TrimSpace(m)
-			}
-			if m, ok := cfg.GrokModels["sonnet"]; ok && m != "" {
-				sonnetModel = strings.TrimSpace(m)
-			}
-			if m, ok := cfg.GrokModels["opus"]; ok && m != "" {
-				opusModel = strings.TrimSpace(m)
-			}
+		if s := cfg.samplingDefaults(be.Name); s.Temperature != nil || s.TopP != nil {
+			fmt.Fprintf(os.Stderr, "Warning: NEXUS_TEMPERATURE and NEXUS_TOP_P only apply to backends served through a local proxy, not %s\n", be.DisplayName)
 		}
+	}
 
-		// Validate model names before setting environment variables
-		if err := validateModelName(haikuModel); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: invalid haiku model name: %v\n", err)
-			os.Exit(1)
-		}
-		if err := validateModelName(sonnetModel); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: invalid sonnet model name: %v\n", err)
-			os.Exit(1)
-		}
-		if err := validateModelName(opusModel); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: invalid opus model name: %v\n", err)
-			os.Exit(1)
+	// Proxies started for the launch stop when it ends, however it ends
+	var proxies []launch.Proxy
+	defer func() {
+		for _, p := range proxies {
+			p.Stop()
 		}
-
-		env = append(env, fmt.Sprintf("ANTHROPIC_DEFAULT_HAIKU_MODEL=%s", haikuModel))
-		env = append(env, fmt.Sprintf("ANTHROPIC_DEFAULT_SONNET_MODEL=%s", sonnetModel))
-		env = append(env, fmt.Sprintf("ANTHROPIC_DEFAULT_OPUS_MODEL=%s", opusModel))
-	}
+	}()
 
 	// For Grok, start a proxy to patch Claude Code requests for xAI compatibility
 	var grokProxy *GrokProxy
 	if be.Name == "grok" {
 		apiKey := cfg.Keys[be.AuthVar]
 		grokProxy = NewGrokProxy(be.BaseURL, apiKey)
-		if err := grokProxy.Start(18081); err != nil {
-			fmt.Fprintf(os.Stderr, "Error starting Grok proxy: %v\n", err)
-			os.Exit(1)
+		grokProxy.SetAuth(cfg.authStrategy(be))
+		grokProxy.SetCooldownFile(cfg.RateLimitFile)
+		grokProxy.SetSampling(cfg.samplingDefaults(be.Name))
+		grokProxy.SetLatencyBudget(cfg.latencyBudget(be.Name))
+		grokProxy.SetTimeoutObserver(func(model string, elapsed time.Duration) {
+			recordTimeout(cfg, UsageRecord{Backend: be.Name, Model: model}, cfg.latencyBudget(be.Name), elapsed)
+		})
+		grokProxy.SetSystemPrimer(primer)
+		grokProxy.SetBackendPrompt(backendPrompt)
+		if cfg.CostAnnotations {
+			grokProxy.SetUsageObserver(func(model string, usage AnthropicUsage) {
+				annotateCost(cfg, priceUsage(cfg, be, model, usage))
+			})
 		}
-		baseURL = "http://localhost:18081"
-		if !yolo {
-			fmt.Println("[OK] Started xAI compatibility proxy on port 18081")
+		if cfg.Chaos.Enabled() {
+			grokProxy.EnableChaos(cfg.Chaos)
+		}
+		port := launchProxyPorts[be.Name]
+		if err := startLaunchProxy(grokProxy, port, &proxies); err != nil {
+			return 1, fmt.Errorf("starting Grok proxy: %w", err)
+		}
+		baseURL = proxyURL(port)
+		if cfg.verbose() {
+			fmt.Printf("[OK] Started xAI compatibility proxy on port %d\n", port)
 		}
 	}
 
-	// For Ollama, start a proxy to translate Anthropic API to OpenAI format
+	// For OpenAI-protocol backends, start a proxy to translate the Anthropic
+	// API to chat completions or the Responses API
 	var proxy *OllamaProxy
-	if be.Name == "ollama" {
-		proxy = NewOllamaProxy(baseURL, buildModelMap(cfg))
-		if err := proxy.Start(18080); err != nil {
-			fmt.Fprintf(os.Stderr, "Error starting Ollama proxy: %v\n", err)
-			os.Exit(1)
+	var warmPool *WarmPool
+	if be.Protocol != "" {
+		proxy = newOllamaProxy(cfg, be, baseURL, NewModelTracker(cfg), "")
+		proxy.SetSystemPrimer(primer)
+		proxy.SetBackendPrompt(backendPrompt)
+		port := launchProxyPorts[be.Name]
+		if err := startLaunchProxy(proxy, port, &proxies); err != nil {
+			return 1, fmt.Errorf("starting %s proxy: %w", be.DisplayName, err)
+		}
+		// Point Claude Code to our proxy instead of directly to the upstream
+		baseURL = proxyURL(port)
+		if cfg.verbose() {
+			fmt.Printf("[OK] Started Anthropic-to-OpenAI proxy on port %d (%s)\n", port, be.Protocol)
 		}
-		// Point Claude Code to our proxy instead of directly to Ollama
-		baseURL = "http://localhost:18080"
-		if !yolo {
-			fmt.Println("[OK] Started Anthropic-to-OpenAI proxy on port 18080")
+		if be.Name == "ollama" {
+			warmPool = startWarmPool(cfg, be, proxy)
+		}
+		if warmPool != nil && cfg.verbose() {
+			fmt.Printf("[OK] Keeping %s loaded\n", strings.Join(warmPool.Models(), ", "))
 		}
 	}
 
-	// Set the base URL (may have been changed to proxy for Ollama)
-	env = append(env, fmt.Sprintf("ANTHROPIC_BASE_URL=%s", baseURL))
+	// The base URL may have been changed to a local proxy
+	env, err := buildLaunchEnv(cfg, be, os.Environ(), baseURL)
+	if err != nil {
+		return 1, err
+	}
+
+	argv := append([]string{"claude"}, cmdArgs...)
+	if err := saveLaunchRecord(cfg, newLaunchRecord(cfg, be, argv, env)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save launch record: %v\n", err)
+	}
 
-	cmd.Env = env
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// Running proxies follow edits to the env file
+	stopReload := watchLaunchConfig(cfg, be, proxy, grokProxy)
 
-	err := cmd.Run()
+	start := time.Now()
+	sessionID := currentSessionID(cfg)
+	prompts := sessionPromptCount(cfg, sessionID)
+	runner := newRunner(timeLimit)
+	code, err := runner.Run("claude", cmdArgs, env)
+	timedOut := false
+	if t, ok := runner.(interface{ TimedOut() bool }); ok {
+		timedOut = t.TimedOut()
+	}
+	stopReload()
+	for _, p := range proxies {
+		p.Stop()
+	}
+	proxies = nil
 
-	// Stop proxies if started
-	if grokProxy != nil {
-		grokProxy.Stop()
+	if warmPool != nil {
+		warmPool.Stop()
 	}
 	if proxy != nil {
-		proxy.Stop()
+		if proxy.compactor != nil {
+			if n, saved := proxy.compactor.Stats(); n > 0 {
+				fmt.Printf("INFO: Compacted context %d times, last request ~%s tokens smaller\n", n, formatNumber(int64(saved)))
+			}
+		}
 	}
+	flushUsageOnExit(cfg)
+	finishRun(cfg, be, sessionID, prompts, start, timeLimit, timedOut)
+
+	// Stopping at the time limit is the expected end of a time-boxed run
+	if timedOut {
+		return 0, nil
+	}
+	if err != nil {
+		return 1, fmt.Errorf("launching claude: %w", err)
+	}
+	return code, nil
+}
+
+// startLaunchProxy starts p on port and adds it to the proxies stopped when
+// the launch ends
+func startLaunchProxy(p launch.Proxy, port int, started *[]launch.Proxy) error {
+	if err := p.Start(port); err != nil {
+		return err
+	}
+	*started = append(*started, p)
+	return nil
+}
+
+// claudeRunner runs Claude Code attached to the terminal, stopping it at a
+// time limit when one is set
+type claudeRunner struct {
+	limit    time.Duration
+	timedOut bool
+}
+
+// newClaudeRunner returns the runner real launches use
+func newClaudeRunner(limit time.Duration) launch.Runner {
+	return &claudeRunner{limit: limit}
+}
 
+// Run runs name and returns its exit code
+func (r *claudeRunner) Run(name string, args, env []string) (int, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	timedOut, err := runClaudeProcess(cmd, r.limit)
+	r.timedOut = timedOut
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
 	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			os.Exit(exitErr.ExitCode())
+		return 1, err
+	}
+	return 0, nil
+}
+
+// TimedOut reports whether the last run was stopped at the time limit
+func (r *claudeRunner) TimedOut() bool {
+	return r.timedOut
+}
+
+// launchProxyPorts are the local ports of the proxies started for backends
+// Claude Code cannot talk to directly
+var launchProxyPorts = launch.ProxyPorts
+
+// newOllamaProxy creates a translating proxy for be with usage recording,
+// drift tracking, the prompt index, compaction and tier demotion configured. Usage is
+// attributed to sessionID, or to the current session when it is empty.
+func newOllamaProxy(cfg *Config, be Backend, baseURL string, tracker *ModelTracker, sessionID string) *OllamaProxy {
+	proxy := NewOllamaProxy(baseURL, buildModelMap(cfg))
+	proxy.SetProtocol(be.Protocol)
+	if key := cfg.Keys[be.AuthVar]; key != "" {
+		proxy.SetUpstreamAuth(be.Name, cfg.authStrategy(be), key)
+	}
+	proxy.SetUpstreams(cfg.LocalUpstreams, cfg.ModelRoutes)
+	proxy.SetCooldownFile(cfg.RateLimitFile)
+	proxy.SetSampling(cfg.samplingDefaults(be.Name))
+	budget := cfg.latencyBudget(be.Name)
+	proxy.SetLatencyBudget(budget)
+	proxy.SetModelTiers(proxyModelTiers(cfg, be, proxy))
+	recordUsage := func(model, upstream, tier string, usage AnthropicUsage) {
+		record := UsageRecord{
+			SessionID:    sessionID,
+			Backend:      be.Name,
+			Model:        model,
+			Tier:         tier,
+			InputTokens:  int64(usage.InputTokens),
+			OutputTokens: int64(usage.OutputTokens),
+			Upstream:     upstream,
 		}
-		fmt.Fprintf(os.Stderr, "Error launching claude: %v\n", err)
-		os.Exit(1)
+		if record.SessionID == "" {
+			record.SessionID = currentSessionID(cfg)
+		}
+		annotateCost(cfg, appendUsageRecord(cfg, record))
+	}
+	proxy.SetUsageRecorder(func(model string, usage AnthropicUsage) {
+		// Name the serving upstream only when there is more than one
+		upstream := ""
+		if len(cfg.LocalUpstreams) > 0 {
+			upstream, _ = proxy.upstreamFor(model)
+		}
+		recordUsage(model, upstream, proxy.modelTier(model), usage)
+	})
+	proxy.SetTimeoutRecorder(func(model string, usage AnthropicUsage, elapsed time.Duration) {
+		recordTimeout(cfg, UsageRecord{
+			SessionID:    sessionID,
+			Backend:      be.Name,
+			Model:        model,
+			Tier:         proxy.modelTier(model),
+			InputTokens:  int64(usage.InputTokens),
+			OutputTokens: int64(usage.OutputTokens),
+		}, budget, elapsed)
+	})
+	if be.Name == "mistral" {
+		// Mistral reports usage on the last chunk and rejects unknown fields
+		proxy.OmitStreamOptions()
+		if route, ok := fimRoute(cfg); ok {
+			proxy.SetFIMRoute(route, func(model string, usage AnthropicUsage) {
+				recordUsage(model, fimUpstream, proxy.modelTier(model), usage)
+			})
+		}
+	}
+	proxy.SetDecisionRecorder(func(d ProxyDecision) {
+		d.Backend, d.SessionID = be.Name, sessionID
+		if d.SessionID == "" {
+			d.SessionID = currentSessionID(cfg)
+		}
+		recordProxyDecision(cfg, d)
+	})
+	proxy.SetCapabilities(loadCapabilities(cfg)[be.Name], func() {
+		err := updateCapabilities(cfg, be.Name, func(c *BackendCapabilities) { c.Streaming = supportNo })
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to cache capabilities: %v\n", err)
+		}
+	})
+	proxy.SetModelObserver(func(requested, resolved string) {
+		if msg := tracker.Observe(be.Name, requested, resolved); msg != "" {
+			fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] "+msg))
+		}
+	})
+	if cfg.PromptIndexEnabled {
+		indexSession := sessionID
+		if indexSession == "" {
+			indexSession = currentSessionID(cfg)
+		}
+		proxy.SetPromptIndex(NewPromptIndex(cfg), be.Name, indexSession)
+	}
+	if cfg.CompactThreshold > 0 {
+		compactModel := cfg.CompactModel
+		if compactModel == "" {
+			compactModel, _, _ = resolveTierModels(cfg, be)
+		}
+		// Summaries are billed as haiku-tier usage whichever model writes them
+		err := proxy.EnableCompaction(cfg.CompactThreshold, cfg.CompactKeep, compactModel, func(model string, usage AnthropicUsage) {
+			recordUsage(model, "", "haiku", usage)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", be.DisplayName, err)
+		}
+	}
+	if cfg.DemoteLatency > 0 && cfg.DemoteModel != "" {
+		haikuModel, _, _ := resolveTierModels(cfg, be)
+		proxy.EnableTierDemotion(cfg.DemoteLatency, cfg.DemoteRequests, haikuModel, cfg.DemoteModel)
+	}
+	if cfg.Chaos.Enabled() {
+		proxy.EnableChaos(cfg.Chaos)
+	}
+	return proxy
+}
+
+// proxyModelTiers maps the upstream models a proxy requests to the tier they
+// serve. A demotion model counts as haiku unless it is another tier's model.
+func proxyModelTiers(cfg *Config, be Backend, proxy *OllamaProxy) map[string]string {
+	haiku, sonnet, opus := resolveTierModels(cfg, be)
+	tiers := make(map[string]string)
+	if cfg.DemoteModel != "" {
+		tiers[proxy.mapModel(cfg.DemoteModel)] = "haiku"
+	}
+	// Later tiers win when several share a model
+	tiers[proxy.mapModel(haiku)] = "haiku"
+	tiers[proxy.mapModel(sonnet)] = "sonnet"
+	tiers[proxy.mapModel(opus)] = "opus"
+	return tiers
+}
+
+// tierModelOverrides returns the user-configured haiku/sonnet/opus models for a backend
+func tierModelOverrides(cfg *Config, backend string) map[string]string {
+	switch backend {
+	case "ollama":
+		return cfg.OllamaModels
+	case "zai":
+		return cfg.ZAIModels
+	case "kimi":
+		return cfg.KimiModels
+	case "grok":
+		return cfg.GrokModels
+	}
+	return nil
+}
+
+// resolveTierModels returns the effective haiku, sonnet and opus models for a
+// backend, applying any custom models from config over the backend defaults
+// and pinned versions over both
+func resolveTierModels(cfg *Config, be Backend) (haiku, sonnet, opus string) {
+	haiku, sonnet, opus = be.HaikuModel, be.SonnetModel, be.OpusModel
+
+	overrides := tierModelOverrides(cfg, be.Name)
+	if m, ok := overrides["haiku"]; ok && m != "" {
+		haiku = strings.TrimSpace(m)
+	}
+	if m, ok := overrides["sonnet"]; ok && m != "" {
+		sonnet = strings.TrimSpace(m)
+	}
+	if m, ok := overrides["opus"]; ok && m != "" {
+		opus = strings.TrimSpace(m)
+	}
+
+	if m, ok := pinnedModel(cfg, be.Name, "haiku"); ok {
+		haiku = m
+	}
+	if m, ok := pinnedModel(cfg, be.Name, "sonnet"); ok {
+		sonnet = m
 	}
+	if m, ok := pinnedModel(cfg, be.Name, "opus"); ok {
+		opus = m
+	}
+	if m, ok := sessionModel(cfg, be.Name, "haiku"); ok {
+		haiku = m
+	}
+	if m, ok := sessionModel(cfg, be.Name, "sonnet"); ok {
+		sonnet = m
+	}
+	if m, ok := sessionModel(cfg, be.Name, "opus"); ok {
+		opus = m
+	}
+	return haiku, sonnet, opus
 }
 
 // buildModelMap creates a mapping from Anthropic model names to Ollama model names
@@ -1332,13 +2189,38 @@ func buildModelMap(cfg *Config) map[string]string {
 
 func runClaude(args []string) {
 	cfg := loadConfig()
-	current := getCurrentBackend(cfg)
+	launchArgs := parseOutputFlags(cfg, args)
 
-	if current == "" {
-		fmt.Println("WARNING: No backend configured. Defaulting to Claude.")
-		switchBackend("claude", args)
-		return
-	}
+	// Auto mode picks a healthy backend once per day, unless the user has
+	// switched to one
+	if cfg.DefaultBackend == autoBackend && autoManaged(cfg) {
+		name, err := selectAutoBackend(cfg, checkBackendHealth)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: auto backend selection failed: %v\n", err)
+			os.Exit(1)
+		}
+		if err := setCurrentBackend(cfg, name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving state: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg.verbose() {
+			fmt.Printf("INFO: Launching Claude Code with %s backend (auto)...\n\n", name)
+		}
+		launchClaudeWithBackend(cfg, backends[name], launchArgs)
+		return
+	}
+
+	current := getCurrentBackend(cfg)
+
+	if current == "" {
+		defaultBackend := cfg.DefaultBackend
+		if _, ok := backends[defaultBackend]; !ok {
+			defaultBackend = "claude"
+		}
+		fmt.Printf("WARNING: No backend configured. Defaulting to %s.\n", backends[defaultBackend].DisplayName)
+		switchBackend(defaultBackend, args)
+		return
+	}
 
 	be, ok := backends[current]
 	if !ok {
@@ -1346,26 +2228,17 @@ func runClaude(args []string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("INFO: Launching Claude Code with %s backend...\n\n", current)
-	launchClaudeWithBackend(cfg, be, args)
+	autostartServices(cfg)
+	be = verifyBeforeLaunch(cfg, be)
+	if cfg.verbose() {
+		fmt.Printf("INFO: Launching Claude Code with %s backend...\n\n", be.Name)
+	}
+	launchClaudeWithBackend(cfg, be, launchArgs)
 }
 
 // formatCustomModels returns a formatted string of custom models for the given backend
 func formatCustomModels(backend string, cfg *Config) string {
-	var models map[string]string
-	switch backend {
-	case "ollama":
-		models = cfg.OllamaModels
-	case "zai":
-		models = cfg.ZAIModels
-	case "kimi":
-		models = cfg.KimiModels
-	case "grok":
-		models = cfg.GrokModels
-	default:
-		return ""
-	}
-
+	models := tierModelOverrides(cfg, backend)
 	if len(models) == 0 {
 		return ""
 	}
@@ -1384,25 +2257,34 @@ func formatCustomModels(backend string, cfg *Config) string {
 	return strings.Join(customModels, ", ")
 }
 
-func showStatus() {
+func showStatus(args []string) {
 	cfg := loadConfig()
 	current := getCurrentBackend(cfg)
 	session := getCurrentSession(cfg)
 	dailyCost, weeklyCost, monthlyCost, byBackend := calculateCosts(cfg)
 
+	sparkHours, err := parseHoursFlag(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Check for --check flag to enable health check/latency
 	checkLatency := false
-	for _, arg := range os.Args {
-		if arg == "--check" || arg == "--latency" {
+	showDetails := false
+	for _, arg := range args {
+		switch arg {
+		case "--check", "--latency":
 			checkLatency = true
-			break
+		case "--details":
+			showDetails = true
 		}
 	}
 
 	// Title
 	fmt.Println()
 	title := styleTitle.Render(fmt.Sprintf("PROMPTOPS v%s", getVersion()))
-	fmt.Println(lipgloss.PlaceHorizontal(80, lipgloss.Center, title))
+	fmt.Println(lipgloss.PlaceHorizontal(min(80, outputWidth()), lipgloss.Center, title))
 	fmt.Println()
 
 	// Current Backend Section
@@ -1423,11 +2305,36 @@ func showStatus() {
 			if custom := formatCustomModels(be.Name, cfg); custom != "" {
 				fmt.Println(styleWarning.Render("Custom: " + custom))
 			}
+			var pins []string
+			for _, tier := range modelTiers {
+				if m, ok := pinnedModel(cfg, be.Name, tier); ok {
+					pins = append(pins, tier+"="+m)
+				}
+			}
+			if len(pins) > 0 {
+				fmt.Println(styleMuted.Render("Pinned: " + strings.Join(pins, ", ")))
+			}
+			if launchArgs := cfg.LaunchArgs[be.Name]; len(launchArgs) > 0 {
+				fmt.Println(styleMuted.Render("Args: " + strings.Join(launchArgs, " ")))
+			}
+			if hint := offPeakHint(cfg, be, time.Now()); hint != "" {
+				fmt.Println(styleMuted.Render(hint))
+			}
+			if cfg.DefaultBackend == autoBackend {
+				if choice := loadAutoChoice(cfg, time.Now()); choice != "" {
+					fmt.Println(styleMuted.Render("Auto: " + choice + " selected today"))
+				}
+			}
 		}
 	}
 	if current == "" {
 		fmt.Println(styleMuted.Render("No backend configured"))
 	}
+	if cfg.Environment != "" {
+		fmt.Println(styleWarning.Render("Environment: " + cfg.Environment))
+	} else if envs := listEnvironments(filepath.Dir(cfg.StateFile)); len(envs) > 0 {
+		fmt.Println(styleMuted.Render("Environments: " + strings.Join(envs, ", ") + " (use --env <name>)"))
+	}
 
 	// Session info
 	if session != nil {
@@ -1461,6 +2368,8 @@ func showStatus() {
 		if !hasKey {
 			if be.Name == "ollama" {
 				status = styleSuccess.Render("Local")
+			} else if cfg.KeyBrokerURL != "" {
+				status = styleSuccess.Render("Leased")
 			} else {
 				status = styleMuted.Render("No Key")
 			}
@@ -1501,7 +2410,7 @@ func showStatus() {
 		rows = append(rows, []string{
 			marker,
 			be.DisplayName,
-			truncate(be.Models, 22),
+			truncateCell(be.Models, 22),
 			status,
 			tierStr,
 			extraCol,
@@ -1513,22 +2422,36 @@ func showStatus() {
 		header = "Latency"
 	}
 
-	t := table.New().
-		Headers("", "Provider", "Models", "Status", "Tier", header).
-		Rows(rows...).
-		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
-		StyleFunc(func(row, col int) lipgloss.Style {
-			if row == 0 {
-				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary).Padding(0, 1)
-			}
-			if col == 0 {
-				return lipgloss.NewStyle().Width(2)
-			}
-			return lipgloss.NewStyle().Padding(0, 1)
-		}).
-		Width(90)
+	// Narrow terminals lose the models, then the tier
+	columns := []tableColumn{{"", 0}, {"Provider", 0}, {"Models", 2}, {"Status", 0}, {"Tier", 1}, {header, 0}}
+	fmt.Println(fitTable(columns, rows, 90, func(headers []string, rows [][]string) *table.Table {
+		return table.New().
+			Headers(headers...).
+			Rows(rows...).
+			BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+			StyleFunc(func(row, col int) lipgloss.Style {
+				if row == 0 {
+					return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary).Padding(0, 1)
+				}
+				if col == 0 {
+					return lipgloss.NewStyle().Width(2)
+				}
+				return lipgloss.NewStyle().Padding(0, 1)
+			})
+	}))
 
-	fmt.Println(t.Render())
+	// Provider data handling terms
+	if showDetails {
+		fmt.Println()
+		fmt.Println(styleSection.Render("PROVIDER TERMS"))
+		renderProviderTerms(backendOrder, current)
+		if len(cfg.NoTrainingRepos) > 0 {
+			fmt.Println(styleMuted.Render("No-training policy: " + strings.Join(cfg.NoTrainingRepos, ", ")))
+		}
+		if len(cfg.DataRegions) > 0 {
+			fmt.Println(styleMuted.Render("Data region policy: " + formatDataRegions(cfg.DataRegions)))
+		}
+	}
 
 	// Cost Summary
 	fmt.Println()
@@ -1542,6 +2465,19 @@ func showStatus() {
 	renderProgressBar("Daily  ", dailyCost, cfg.DailyBudget)
 	renderProgressBar("Weekly ", weeklyCost, cfg.WeeklyBudget)
 	renderProgressBar("Monthly", monthlyCost, cfg.MonthlyBudget)
+	fmt.Println()
+	renderSpendSparkline(ledgerUsage(cfg), time.Now(), sparkHours)
+	if len(cfg.BackendBudgets) > 0 {
+		fmt.Println()
+		renderBackendBudgets(cfg, periodCostsByBackend(ledgerUsage(cfg), time.Now()))
+	}
+
+	// Prepaid balances recorded with `promptops credits set`
+	if statuses := creditStatuses(loadCredits(cfg), ledgerUsage(cfg)); len(statuses) > 0 {
+		fmt.Println()
+		fmt.Println(styleSection.Render("PREPAID CREDITS"))
+		renderCredits(cfg, statuses)
+	}
 
 	// Top backends by usage
 	if len(byBackend) > 0 {
@@ -1633,10 +2569,12 @@ func initEnv() {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	envFile := filepath.Join(dir, ".env.local")
+	envFile := filepath.Join(dir, envFileName(activeEnv))
+	envName := filepath.Base(envFile)
 
 	if _, err := os.Stat(envFile); err == nil {
-		fmt.Println("[OK] .env.local already exists")
+		fmt.Printf("[OK] %s already exists\n", envName)
+		bootstrapSecretHygiene(dir, envFile, bufio.NewReader(os.Stdin), os.Stdout)
 		return
 	}
 
@@ -1670,12 +2608,53 @@ func initEnv() {
 # Enable audit logging (logs all backend switches to .promptops-audit.log)
 NEXUS_AUDIT_LOG=true
 
-# Default backend when none specified (claude|zai|kimi|deepseek|gemini|mistral|groq|together|openrouter|ollama)
+# Chain usage and audit records with HMAC so 'promptops audit verify' detects
+# edits and truncation. The key is created on first use; keep it somewhere
+# the people who can edit the logs cannot write to.
+# NEXUS_LEDGER_SIGNING=true
+# NEXUS_LEDGER_KEY_FILE=/etc/promptops/ledger.key
+
+# Organization mode: lease short-lived, scoped keys from a key broker at
+# launch instead of using the keys above. Leases are renewed during the
+# session and revoked on exit.
+# NEXUS_KEY_BROKER_URL=https://keys.example.com
+# NEXUS_KEY_BROKER_TOKEN=
+# NEXUS_KEY_BROKER_SCOPE=engineering
+# NEXUS_KEY_LEASE_TTL=1h
+
+# How promptops authenticates its own requests (health checks, usage, the
+# Grok proxy) per backend: bearer, header[:<name>], query:<param>,
+# sigv4:<region>/<service> (key is ACCESS_KEY_ID:SECRET) or
+# oauth:<token-url> [scope] (key is CLIENT_ID:CLIENT_SECRET)
+# NEXUS_AUTH_GEMINI=query:key
+
+# Default backend when none specified (claude|zai|kimi|deepseek|gemini|mistral|groq|together|openrouter|ollama|auto)
+# "auto" picks the first healthy backend with a key from NEXUS_AUTO_BACKENDS
+# on the first run of each day
 NEXUS_DEFAULT_BACKEND=claude
+# NEXUS_AUTO_BACKENDS=claude,deepseek,ollama
 
-# Verify API keys on switch (true|false)
+# Health-check the backend before launch; if unreachable, offer to launch
+# anyway, pick another backend or fail over (true|false)
 NEXUS_VERIFY_ON_SWITCH=true
 
+# Network timeouts (Go durations or seconds). Append _<BACKEND> to override
+# one provider, e.g. NEXUS_HEALTH_TIMEOUT_KIMI=20s
+# NEXUS_HEALTH_TIMEOUT=5s
+# NEXUS_USAGE_TIMEOUT=10s
+# NEXUS_API_TIMEOUT=50m
+
+# How long the local proxies (Ollama, Grok, OpenAI, Mistral) wait on the
+# upstream before answering with a timeout error
+# NEXUS_LATENCY_BUDGET=10m
+
+# Sampling limits. NEXUS_MAX_TOKENS caps each request's max_tokens; the local
+# proxies also use NEXUS_TEMPERATURE and NEXUS_TOP_P when a request sets none.
+# Append _<BACKEND> for one provider, e.g. NEXUS_MAX_TOKENS_OPENAI=8192
+# NEXUS_MAX_TOKENS=
+# NEXUS_TEMPERATURE=
+# NEXUS_TOP_P=
+
 # -------------------------------------------------------------------------------
 # Budget Settings (USD)
 # -------------------------------------------------------------------------------
@@ -1683,6 +2662,175 @@ NEXUS_DAILY_BUDGET=10.00
 NEXUS_WEEKLY_BUDGET=50.00
 NEXUS_MONTHLY_BUDGET=100.00
 
+# Per-backend budgets, enforced alongside the global ones so one expensive
+# backend can't use up the whole budget
+# NEXUS_DAILY_BUDGET_CLAUDE=5.00
+# NEXUS_MONTHLY_BUDGET_OPENAI=40.00
+
+# Warn when a prepaid balance recorded with "promptops credits set" drops
+# below these percentages
+# NEXUS_CREDIT_WARN=25,10
+
+# Refuse to launch providers that may train on inputs (or whose terms are
+# unknown) inside these git repositories; "*" applies everywhere.
+# See "promptops status --details" for each provider's terms.
+# NEXUS_NO_TRAINING_REPOS=payments-service,internal-tools
+
+# Regions (US, EU, CN, SG) each git repository may send prompts to, for
+# contracts with data residency clauses; "*" covers every other repository.
+# Local backends are always allowed. Set the mode to warn to audit and print
+# violations instead of refusing them.
+# NEXUS_DATA_REGIONS=payments-service:EU,*:EU+US
+# NEXUS_DATA_REGION_MODE=block
+
+# Cost-allocation tags stamped on every usage record. A workspace's
+# .promptops/tags file (name=value lines) overrides these defaults; launches
+# missing a required tag are refused.
+# NEXUS_COST_TAG_TEAM=platform
+# NEXUS_REQUIRED_COST_TAGS=team,project
+
+# Provider-specific system text placed before/after the client's system
+# prompt (\n for a newline, 4 KB max each). Turn it off for a session with
+# "promptops session backend-prompt off".
+# NEXUS_SYSTEM_PREFIX_KIMI=
+# NEXUS_SYSTEM_SUFFIX_ZAI=
+
+# Launch output, independent of YOLO mode: NEXUS_NO_ANIMATION skips spinners,
+# logos and progress bars; NEXUS_QUIET prints only warnings and errors.
+# The --no-animation and --quiet launch flags do the same for one launch.
+# NEXUS_NO_ANIMATION=false
+# NEXUS_QUIET=false
+
+# Confirm before launching when the opus-tier output price exceeds the
+# threshold (USD per 1M tokens). Applies even in YOLO mode.
+# NEXUS_CONFIRM_EXPENSIVE=false
+# NEXUS_EXPENSIVE_THRESHOLD=10.00
+
+# Time-windowed prices (UTC, USD per 1M input/output tokens) used for logged
+# usage. None are built in; check your provider's current pricing first.
+# NEXUS_OFFPEAK_DEEPSEEK=16:30-00:30=0.135/0.55
+
+# -------------------------------------------------------------------------------
+# Prompt Index (optional - local embeddings via Ollama)
+# Warns when a nearly identical prompt was already answered. Covers prompts
+# sent through the OpenAI-protocol proxy (Ollama and similar backends) only
+# -------------------------------------------------------------------------------
+# NEXUS_PROMPT_INDEX=false
+# NEXUS_EMBED_URL=http://localhost:11434
+# NEXUS_EMBED_MODEL=nomic-embed-text
+# NEXUS_DEDUPE_THRESHOLD=0.95
+
+# -------------------------------------------------------------------------------
+# Launch Arguments (optional - per backend)
+# Passed to Claude Code before any arguments given on the command line
+# -------------------------------------------------------------------------------
+# NEXUS_ARGS_CLAUDE=--permission-mode plan
+# NEXUS_ARGS_OLLAMA=--model sonnet
+
+# -------------------------------------------------------------------------------
+# Model Pinning (optional)
+# Pin exact model versions per tier for reproducible runs. Doctor and the
+# proxy warn when an unpinned alias starts resolving to a different version.
+# -------------------------------------------------------------------------------
+# NEXUS_PIN_CLAUDE_SONNET=claude-sonnet-4-5-20250929
+# NEXUS_PIN_OLLAMA_OPUS=llama3.3:70b-instruct-q4_K_M
+
+# -------------------------------------------------------------------------------
+# Model Aliases (optional)
+# Your own model names, usable wherever a model is expected (tier models, pins,
+# session models, NEXUS_LOCAL_ROUTES, NEXUS_DEMOTE_MODEL, ...). An alias only
+# applies to its own backend.
+# -------------------------------------------------------------------------------
+# NEXUS_ALIAS_MYFAST=groq/llama-3.3-70b-versatile
+# NEXUS_ALIAS_MYLOCAL=ollama/qwen2.5-coder:14b
+
+# -------------------------------------------------------------------------------
+# Context Compaction (optional - proxied backends such as Ollama)
+# Above the threshold (estimated tokens), older turns are summarized by the
+# haiku-tier model (or NEXUS_COMPACT_MODEL) before the request is forwarded
+# -------------------------------------------------------------------------------
+# NEXUS_COMPACT_THRESHOLD=0
+# NEXUS_COMPACT_KEEP=6
+# NEXUS_COMPACT_MODEL=llama3.2:3b
+
+# -------------------------------------------------------------------------------
+# Tier Demotion (optional - proxied backends such as Ollama)
+# When upstream p95 latency stays above the limit for N consecutive requests,
+# haiku-tier requests go to the smaller model until latency recovers
+# -------------------------------------------------------------------------------
+# NEXUS_DEMOTE_LATENCY=8s
+# NEXUS_DEMOTE_REQUESTS=5
+# NEXUS_DEMOTE_MODEL=llama3.2:1b
+
+# -------------------------------------------------------------------------------
+# Cost Annotations (optional - proxied backends such as Ollama and Grok)
+# Print a dim line after each response, e.g.
+#   [promptops] +$0.031 (1.2k in / 450 out, deepseek-chat)
+# -------------------------------------------------------------------------------
+# NEXUS_COST_ANNOTATIONS=false
+
+# -------------------------------------------------------------------------------
+# Warm Models (optional - Ollama backend)
+# Keep the haiku and sonnet tier models loaded while Claude Code runs, so tier
+# switches don't wait for a reload. Ollama must allow both to be loaded at
+# once (OLLAMA_MAX_LOADED_MODELS).
+# -------------------------------------------------------------------------------
+# NEXUS_WARM_MODELS=false
+# NEXUS_WARM_KEEPALIVE=10m
+
+# -------------------------------------------------------------------------------
+# Session Intents (optional - needs Ollama and 'promptops hooks install')
+# Summarize each session's first prompt into one line for 'session list',
+# using a local Ollama model; nothing is sent to hosted providers.
+# Defaults to the Ollama haiku tier model.
+# -------------------------------------------------------------------------------
+# NEXUS_SESSION_SUMMARIES=false
+# NEXUS_SUMMARY_MODEL=llama3.2
+
+# -------------------------------------------------------------------------------
+# Chaos Mode (testing only - proxied backends such as Ollama and Grok)
+# Adds latency to every upstream request and fails a share of them with
+# HTTP 503, to rehearse retries, failover and budgets under degradation
+# -------------------------------------------------------------------------------
+# NEXUS_CHAOS=latency:500ms,errors:5%
+
+# -------------------------------------------------------------------------------
+# Local Upstreams (optional - Ollama backend)
+# Route models to other OpenAI-compatible local servers such as LM Studio.
+# Routes are glob patterns tried in order; unmatched models go to Ollama.
+# -------------------------------------------------------------------------------
+# NEXUS_LOCAL_UPSTREAMS=lmstudio=http://localhost:1234/v1
+# NEXUS_LOCAL_ROUTES=qwen*=lmstudio,llama*=ollama
+
+# -------------------------------------------------------------------------------
+# Local Services (optional)
+# Companion services managed by 'promptops services', started in this order.
+# _READY is an http(s):// or tcp:// readiness probe; _STOP replaces the
+# default of terminating the started process.
+# -------------------------------------------------------------------------------
+# NEXUS_SERVICE_OLLAMA=ollama serve
+# NEXUS_SERVICE_OLLAMA_READY=http://localhost:11434/api/tags
+# NEXUS_SERVICE_VLLM=docker run -d --rm --name vllm -p 8000:8000 vllm/vllm-openai --model Qwen/Qwen2.5-Coder-7B-Instruct
+# NEXUS_SERVICE_VLLM_READY=http://localhost:8000/v1/models
+# NEXUS_SERVICE_VLLM_STOP=docker stop vllm
+# NEXUS_SERVICES_AUTOSTART=false
+
+# -------------------------------------------------------------------------------
+# Token Counting (optional)
+# Token estimates use per-model approximations; set a tokenize endpoint (for
+# example a llama.cpp server's /tokenize) for exact counts on local models
+# -------------------------------------------------------------------------------
+# NEXUS_TOKENIZER_URL=http://localhost:8080/tokenize
+# NEXUS_TOKENIZER_MODELS=llama,qwen
+
+# -------------------------------------------------------------------------------
+# Provider API Versions (optional)
+# Versions sent with promptops' own provider requests; override when a
+# provider retires a version. 'promptops which' shows the active versions.
+# -------------------------------------------------------------------------------
+# ANTHROPIC_VERSION=2023-06-01
+# OPENAI_API_VERSION=
+
 # -------------------------------------------------------------------------------
 # LLM API Keys (add your keys here)
 # -------------------------------------------------------------------------------
@@ -1715,6 +2863,14 @@ GEMINI_API_KEY=
 # Get your API key from: https://console.mistral.ai/
 MISTRAL_API_KEY=
 
+# Codestral API Key (optional)
+# Fill-in-the-middle requests on the mistral backend go to the dedicated
+# Codestral endpoint with this key, or to Mistral's own FIM endpoint without it
+# Get your API key from: https://console.mistral.ai/codestral
+CODESTRAL_API_KEY=
+# NEXUS_CODESTRAL_URL=https://codestral.mistral.ai/v1
+# NEXUS_FIM_MODEL=codestral-latest
+
 # Groq API Key
 # Get your API key from: https://console.groq.com/
 GROQ_API_KEY=
@@ -1755,12 +2911,13 @@ OLLAMA_API_KEY=
 # KIMI_OPUS_MODEL=kimi-for-coding
 `
 	if err := os.WriteFile(envFile, []byte(content), 0600); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating .env.local: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", envName, err)
 		os.Exit(1)
 	}
 
-	fmt.Println("[OK] Created .env.local")
-	fmt.Println("INFO: Please add your API keys to .env.local")
+	fmt.Printf("[OK] Created %s\n", envName)
+	bootstrapSecretHygiene(dir, envFile, bufio.NewReader(os.Stdin), os.Stdout)
+	fmt.Printf("INFO: Please add your API keys to %s\n", envName)
 }
 
 func showVersion() {
@@ -1786,82 +2943,6 @@ func showVersion() {
 	fmt.Println("    - ollama: Ollama Local LLM - http://localhost:11434")
 }
 
-func showHelp() {
-	fmt.Println("+-------------------------------------------------------------------------------+")
-	fmt.Println("|                    PROMPTOPS ENTERPRISE v" + getVersion() + "                       |")
-	fmt.Println("+-------------------------------------------------------------------------------+")
-	fmt.Println()
-	fmt.Println("Usage: promptops <command> [options]")
-	fmt.Println()
-	fmt.Println("Commands:")
-	fmt.Println("  Tier 1 Backends:")
-	fmt.Println("    claude                  Switch to Claude (Anthropic) and launch")
-	fmt.Println("    openai                  Switch to OpenAI (GPT-4o/o1) and launch")
-	fmt.Println("    zai                     Switch to Z.AI (GLM) and launch")
-	fmt.Println("    kimi                    Switch to Kimi (Moonshot) and launch")
-	fmt.Println("    deepseek                Switch to DeepSeek (V3/R1) and launch")
-	fmt.Println("    gemini                  Switch to Gemini (Google) and launch")
-	fmt.Println("    mistral                 Switch to Mistral (Large/Codestral) and launch")
-	fmt.Println("    grok                    Switch to Grok (xAI Code) and launch")
-	fmt.Println()
-	fmt.Println("  Tier 2 Backends:")
-	fmt.Println("    groq                    Switch to Groq (Llama) and launch")
-	fmt.Println("    together                Switch to Together AI and launch")
-	fmt.Println("    openrouter              Switch to OpenRouter (200+ models) and launch")
-	fmt.Println()
-	fmt.Println("  Local Backends:")
-	fmt.Println("    ollama                  Switch to Ollama (local) and launch")
-	fmt.Println()
-	fmt.Println("  Cost Tracking:")
-	fmt.Println("    cost                    Show cost dashboard with budgets")
-	fmt.Println("    cost log                Show detailed usage log")
-	fmt.Println()
-	fmt.Println("  API Usage:")
-	fmt.Println("    usage                   Show usage data from all provider APIs")
-	fmt.Println("    usage <backend>         Show usage for specific backend")
-	fmt.Println()
-	fmt.Println("  Budget Management:")
-	fmt.Println("    budget status           Show budget progress")
-	fmt.Println("    budget set <period> <amount>  Set budget (daily/weekly/monthly)")
-	fmt.Println()
-	fmt.Println("  Environment Validation:")
-	fmt.Println("    doctor                  Full health check of all backends")
-	fmt.Println("    validate <backend>      Validate specific backend connectivity")
-	fmt.Println()
-	fmt.Println("  Session Management:")
-	fmt.Println("    session start <name>    Start a new named session")
-	fmt.Println("    session list            List all sessions")
-	fmt.Println("    session resume <name>   Resume a previous session")
-	fmt.Println("    session info [name]     Show session details")
-	fmt.Println("    session close <name>    Close a session")
-	fmt.Println("    session cleanup         Remove old closed sessions")
-	fmt.Println()
-	fmt.Println("  General Commands:")
-	fmt.Println("    status                  Show current backend and configuration")
-	fmt.Println("    run [args]              Launch Claude Code with current backend")
-	fmt.Println("    usage [backend]         Check API usage from provider APIs")
-	fmt.Println("    init                    Initialize .env.local with API key templates")
-	fmt.Println("    version                 Show version information")
-	fmt.Println("    help                    Show this help message")
-	fmt.Println()
-	fmt.Println("Environment Variables:")
-	fmt.Println("  NEXUS_ENV_FILE            Path to env file (default: ./.env.local)")
-	fmt.Println("  NEXUS_YOLO_MODE           Global YOLO mode (default: true)")
-	fmt.Println("  NEXUS_YOLO_MODE_<BACKEND> YOLO mode for specific backend (default: true)")
-	fmt.Println()
-	fmt.Println("Examples:")
-	fmt.Println("  promptops deepseek        # Switch to DeepSeek and launch Claude Code")
-	fmt.Println("  promptops gemini          # Switch to Gemini and launch")
-	fmt.Println("  promptops openrouter      # Switch to OpenRouter and launch")
-	fmt.Println("  promptops status          # Check current configuration")
-	fmt.Println("  promptops run             # Launch with current backend")
-	fmt.Println("  promptops doctor          # Run health checks")
-	fmt.Println("  promptops usage           # Check API usage from all providers")
-	fmt.Println("  promptops usage claude    # Check Claude API usage")
-	fmt.Println("  promptops session start bugfix-123")
-	fmt.Println()
-}
-
 // For testing - allows running with mocked input
 func readLine(reader *bufio.Reader) (string, error) {
 	line, err := reader.ReadString('\n')
@@ -1902,13 +2983,17 @@ func setCurrentSession(cfg *Config, sessionID string) error {
 	return writeFileAtomic(cfg.SessionFile, []byte(sessionID), 0600)
 }
 
-// withFileLock executes the given function with an exclusive file lock
+// withFileLock executes the given function with an exclusive file lock.
+// The lock file is left in place after release. flock locks an inode, not a
+// path: if the holder removed the file, a process already waiting would get
+// the old inode while a newcomer created and locked a new one, and both would
+// run fn at once. The leftover files are empty, named after the file they
+// guard (.promptops-*.lock) and covered by the init .gitignore entries.
 func withFileLock(lockPath string, fn func() error) error {
 	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
 		return fmt.Errorf("open lock file: %w", err)
 	}
-	defer os.Remove(lockPath)
 	defer f.Close()
 
 	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
@@ -1920,9 +3005,17 @@ func withFileLock(lockPath string, fn func() error) error {
 }
 
 func loadSessions(cfg *Config) []*Session {
+	sessions, _ := loadSessionsVersion(cfg)
+	return sessions
+}
+
+// loadSessionsVersion loads sessions along with the file version they were
+// read at, for saveSessionsIfUnchanged
+func loadSessionsVersion(cfg *Config) ([]*Session, string) {
 	lockPath := cfg.SessionsFile + ".lock"
 
 	var sessions []*Session
+	var version string
 	err := withFileLock(lockPath, func() error {
 		data, err := os.ReadFile(cfg.SessionsFile)
 		if err != nil {
@@ -1931,6 +3024,7 @@ func loadSessions(cfg *Config) []*Session {
 			}
 			return nil
 		}
+		version = sessionsVersion(data)
 
 		if err := json.Unmarshal(data, &sessions); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: sessions file corrupted: %v\n", err)
@@ -1950,13 +3044,13 @@ func loadSessions(cfg *Config) []*Session {
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to lock sessions file: %v\n", err)
-		return []*Session{}
+		return []*Session{}, version
 	}
 
 	if sessions == nil {
-		return []*Session{}
+		return []*Session{}, version
 	}
-	return sessions
+	return sessions, version
 }
 
 func saveSessions(cfg *Config, sessions []*Session) error {
@@ -1983,8 +3077,6 @@ func generateSessionID(name string) (string, error) {
 }
 
 func createSession(cfg *Config, name string) (*Session, error) {
-	sessions := loadSessions(cfg)
-
 	// Generate unique ID with random component to prevent collisions
 	sessionID, err := generateSessionID(name)
 	if err != nil {
@@ -2003,8 +3095,10 @@ func createSession(cfg *Config, name string) (*Session, error) {
 		Status:      "active",
 	}
 
-	sessions = append(sessions, &session)
-	if err := saveSessions(cfg, sessions); err != nil {
+	err = updateSessions(cfg, func(sessions []*Session) ([]*Session, error) {
+		return append(sessions, &session), nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to save sessions: %w", err)
 	}
 	if err := setCurrentSession(cfg, sessionID); err != nil {
@@ -2024,87 +3118,85 @@ func getWorkingDir() string {
 
 // Usage tracking functions
 func logUsage(cfg *Config, backend string, inputTokens, outputTokens int64) {
-	be, ok := backends[backend]
-	if !ok {
-		return
-	}
+	logSessionUsage(cfg, backend, currentSessionID(cfg), inputTokens, outputTokens)
+}
 
-	// Calculate cost
-	inputCost := float64(inputTokens) * be.InputPrice / 1000000
-	outputCost := float64(outputTokens) * be.OutputPrice / 1000000
-	totalCost := inputCost + outputCost
+// currentSessionID returns the ID of the current session, if any
+func currentSessionID(cfg *Config) string {
+	if session := getCurrentSession(cfg); session != nil {
+		return session.ID
+	}
+	return ""
+}
 
-	record := UsageRecord{
-		Timestamp:    time.Now(),
-		SessionID:    "",
+// logSessionUsage appends a usage record attributed to sessionID
+func logSessionUsage(cfg *Config, backend, sessionID string, inputTokens, outputTokens int64) {
+	appendUsageRecord(cfg, UsageRecord{
+		SessionID:    sessionID,
 		Backend:      backend,
-		Model:        be.SonnetModel,
 		InputTokens:  inputTokens,
 		OutputTokens: outputTokens,
-		CostUSD:      totalCost,
+	})
+}
+
+// appendUsageRecord prices, timestamps and appends a usage record, returning
+// it as written. Proxied requests fill in the model, tier and upstream that
+// served them.
+func appendUsageRecord(cfg *Config, record UsageRecord) UsageRecord {
+	be, ok := backends[record.Backend]
+	if !ok {
+		return record
 	}
 
-	// Include session ID if available
-	session := getCurrentSession(cfg)
-	if session != nil {
-		record.SessionID = session.ID
+	// Calculate cost at the rate in effect when the request was made
+	record.Timestamp = time.Now()
+	record.PID = os.Getpid()
+	inputPrice, outputPrice := usagePricing(cfg, be, record.Upstream, record.Tier, record.Timestamp)
+	inputCost := float64(record.InputTokens) * inputPrice / 1000000
+	outputCost := float64(record.OutputTokens) * outputPrice / 1000000
+	record.CostUSD = inputCost + outputCost
+
+	if record.Model == "" {
+		record.Model = be.SonnetModel
 	}
 
+	// Attribute usage to the git checkout Claude Code was launched in
+	git := currentGitInfo()
+	record.Repo, record.Branch, record.Commit = git.Repo, git.Branch, git.Commit
+	record.Tags = currentCostTags(cfg)
+
 	// Append to usage file
 	data, err := json.Marshal(record)
 	if err != nil {
 		// Log to stderr but don't fail - usage tracking is best-effort
 		fmt.Fprintf(os.Stderr, "Warning: failed to marshal usage record: %v\n", err)
-		return
-	}
-	f, err := os.OpenFile(cfg.UsageFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to open usage file: %v\n", err)
-		return
+		return record
 	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to close usage file: %v\n", err)
-		}
-	}()
-	if _, err := fmt.Fprintln(f, string(data)); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to write usage record: %v\n", err)
+	// Records that can't be written are queued and retried on the next write
+	// or on exit, rather than dropped
+	if err := pendingUsage.write(cfg, data); err != nil && pendingUsage.shouldWarn() {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write usage record, queued for retry: %v\n", err)
 	}
+	return record
 }
 
 func loadUsageRecords(cfg *Config) []UsageRecord {
-	data, err := os.ReadFile(cfg.UsageFile)
-	if err != nil {
+	records := []UsageRecord{}
+	if err := eachUsageRecord(cfg, func(r UsageRecord) { records = append(records, r) }); err != nil {
 		return []UsageRecord{}
 	}
-
-	var records []UsageRecord
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		var record UsageRecord
-		if err := json.Unmarshal([]byte(line), &record); err == nil {
-			records = append(records, record)
-		}
-	}
 	return records
 }
 
 func calculateCosts(cfg *Config) (daily, weekly, monthly float64, byBackend map[string]float64) {
-	records := loadUsageRecords(cfg)
 	byBackend = make(map[string]float64)
 
-	now := time.Now()
-	today := now.Truncate(24 * time.Hour)
 	// Week starts on Sunday (Weekday() returns 0 for Sunday)
 	// Note: This is US-centric; some regions start week on Monday
-	weekStart := today.AddDate(0, 0, -int(today.Weekday()))
-	monthStart := today.AddDate(0, 0, -today.Day()+1)
+	today, weekStart, monthStart := budgetPeriodStarts(time.Now())
 
-	for _, r := range records {
+	// Streamed, so status and budget checks stay cheap on large ledgers
+	eachUsageRecord(cfg, func(r UsageRecord) {
 		byBackend[r.Backend] += r.CostUSD
 
 		recordDay := r.Timestamp.Truncate(24 * time.Hour)
@@ -2117,7 +3209,7 @@ func calculateCosts(cfg *Config) (daily, weekly, monthly float64, byBackend map[
 		if r.Timestamp.After(monthStart) {
 			monthly += r.CostUSD
 		}
-	}
+	})
 
 	return daily, weekly, monthly, byBackend
 }
@@ -2167,27 +3259,7 @@ func showCostDashboard() {
 		fmt.Println(styleSection.Render("BACKEND BREAKDOWN"))
 
 		// Calculate totals by period per backend
-		now := time.Now()
-		today := now.Truncate(24 * time.Hour)
-		weekStart := today.AddDate(0, 0, -int(today.Weekday()))
-		monthStart := today.AddDate(0, 0, -today.Day()+1)
-
-		records := loadUsageRecords(cfg)
-		backendDaily := make(map[string]float64)
-		backendWeekly := make(map[string]float64)
-		backendMonthly := make(map[string]float64)
-
-		for _, r := range records {
-			if r.Timestamp.After(monthStart) {
-				backendMonthly[r.Backend] += r.CostUSD
-			}
-			if r.Timestamp.After(weekStart) {
-				backendWeekly[r.Backend] += r.CostUSD
-			}
-			if r.Timestamp.Truncate(24 * time.Hour).Equal(today) {
-				backendDaily[r.Backend] += r.CostUSD
-			}
-		}
+		periods := periodCostsByBackend(ledgerUsage(cfg), time.Now())
 
 		total := 0.0
 		for _, cost := range byBackend {
@@ -2202,69 +3274,95 @@ func showCostDashboard() {
 			percent := byBackend[name] / total * 100
 			rows = append(rows, []string{
 				be.DisplayName,
-				formatCurrency(backendDaily[name]),
-				formatCurrency(backendWeekly[name]),
-				formatCurrency(backendMonthly[name]),
+				formatCurrency(periods[name].Daily),
+				formatCurrency(periods[name].Weekly),
+				formatCurrency(periods[name].Monthly),
 				fmt.Sprintf("%.0f%%", percent),
 			})
 		}
 
-		t := table.New().
-			Headers("Backend", "Today", "This Week", "This Month", "%").
-			Rows(rows...).
-			BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
-			StyleFunc(func(row, col int) lipgloss.Style {
-				if row == 0 {
-					return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
-				}
-				return lipgloss.NewStyle().Padding(0, 1)
-			}).
-			Width(80)
-
-		fmt.Println(t.Render())
+		columns := []tableColumn{{"Backend", 0}, {"Today", 1}, {"This Week", 2}, {"This Month", 0}, {"%", 3}}
+		fmt.Println(fitTable(columns, rows, 80, costTable))
 	}
 
 	fmt.Println()
 }
 
-func showCostLog() {
-	cfg := loadConfig()
-	records := loadUsageRecords(cfg)
-
-	if len(records) == 0 {
-		fmt.Println("No usage records found.")
+func handleCostCommand(args []string) {
+	if len(args) == 0 {
+		showCostDashboard()
 		return
 	}
 
-	// Show last 20 records
-	start := 0
-	if len(records) > 20 {
-		start = len(records) - 20
+	switch args[0] {
+	case "log":
+		showCostLog()
+	case "report":
+		showCostReport(args[1:])
+	case "push":
+		handleCostPush(args[1:])
+	case "tags":
+		showCostTags()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown cost command: %s\n", args[0])
+		os.Exit(1)
 	}
+}
 
-	fmt.Println()
-	fmt.Println(styleSection.Render("Recent Usage Records"))
+// UsageGroup aggregates usage records sharing a report key
+type UsageGroup struct {
+	Key          string
+	Requests     int
+	InputTokens  int64
+	OutputTokens int64
+	CostUSD      float64
+	Branches     map[string]bool
+}
 
-	rows := [][]string{}
-	for i := len(records) - 1; i >= start; i-- {
-		r := records[i]
-		sessionID := r.SessionID
-		sessionID = truncate(sessionID, 18)
-		if sessionID == "" {
-			sessionID = "-"
+// groupUsage aggregates records by key, sorted by cost descending
+func groupUsage(records []UsageRecord, key func(UsageRecord) string) []*UsageGroup {
+	groups := make(map[string]*UsageGroup)
+	for _, r := range records {
+		k := key(r)
+		g, ok := groups[k]
+		if !ok {
+			g = &UsageGroup{Key: k, Branches: make(map[string]bool)}
+			groups[k] = g
+		}
+		g.Requests++
+		g.InputTokens += r.InputTokens
+		g.OutputTokens += r.OutputTokens
+		g.CostUSD += r.CostUSD
+		if r.Branch != "" {
+			g.Branches[r.Branch] = true
 		}
-		rows = append(rows, []string{
-			r.Timestamp.Format("2006-01-02 15:04"),
-			r.Backend,
-			sessionID,
-			fmt.Sprintf("%d", r.InputTokens),
-			fmt.Sprintf("%d", r.OutputTokens),
-			formatCurrency(r.CostUSD),
-		})
 	}
 
-	t := table.New().
-		Headers("Timestamp", "Backend", "Session", "Input", "Output", "Cost").
+	result := make([]*UsageGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CostUSD != result[j].CostUSD {
+			return result[i].CostUSD > result[j].CostUSD
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// usageRepoKey groups records by repository, keeping non-git usage separate
+func usageRepoKey(r UsageRecord) string {
+	if r.Repo == "" {
+		return "(no repo)"
+	}
+	return r.Repo
+}
+
+// costTable builds the cost dashboard, report and log tables
+func costTable(headers []string, rows [][]string) *table.Table {
+	return table.New().
+		Headers(headers...).
 		Rows(rows...).
 		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
 		StyleFunc(func(row, col int) lipgloss.Style {
@@ -2272,40 +3370,181 @@ func showCostLog() {
 				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
 			}
 			return lipgloss.NewStyle().Padding(0, 1)
-		}).
-		Width(100)
-
-	fmt.Println(t.Render())
-	fmt.Println()
+		})
 }
 
-func handleBudgetCommand(args []string) {
-	if len(args) == 0 {
-		showBudgetStatus()
-		return
+func showCostReport(args []string) {
+	cfg := loadConfig()
+	args, rng, err := parseUsageRange(args, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-
-	subcmd := args[0]
-	switch subcmd {
-	case "status":
-		showBudgetStatus()
-	case "set":
-		if len(args) < 3 {
-			fmt.Fprintln(os.Stderr, "Usage: promptops budget set <daily|weekly|monthly> <amount>")
+	args, asCSV := stripFlag(args, "--csv")
+
+	title, keyName, key, _ := parseGroupBy("backend")
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--by-repo":
+			title, keyName, key, _ = parseGroupBy("repo")
+		case arg == "--by-backend":
+			// Default grouping
+		case arg == "--group-by" || strings.HasPrefix(arg, "--group-by="):
+			value, hasValue := strings.CutPrefix(arg, "--group-by=")
+			if !hasValue {
+				if i+1 >= len(args) {
+					fmt.Fprintln(os.Stderr, "Error: --group-by requires a value (backend, repo or tag:<name>)")
+					os.Exit(1)
+				}
+				value = args[i+1]
+				i++
+			}
+			if title, keyName, key, err = parseGroupBy(value); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown report option: %s\n", arg)
 			os.Exit(1)
 		}
-		setBudget(args[1], args[2])
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown budget command: %s\n", subcmd)
-		os.Exit(1)
 	}
-}
-
-func showBudgetStatus() {
-	cfg := loadConfig()
-	dailyCost, weeklyCost, monthlyCost, _ := calculateCosts(cfg)
 
-	fmt.Println()
+	var records []UsageRecord
+	eachUsageRecord(cfg, func(r UsageRecord) {
+		if rng.Contains(r.Timestamp) {
+			records = append(records, r)
+		}
+	})
+	if asCSV {
+		if err := writeCostCSV(os.Stdout, keyName, rng, groupUsage(records, key)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("No usage records found.")
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render(title))
+	if !rng.IsZero() {
+		fmt.Println(styleMuted.Render(rng.Label()))
+	}
+
+	rows := [][]string{}
+	for _, g := range groupUsage(records, key) {
+		branches := make([]string, 0, len(g.Branches))
+		for b := range g.Branches {
+			branches = append(branches, b)
+		}
+		sort.Strings(branches)
+		branchStr := "-"
+		if len(branches) > 0 {
+			branchStr = truncateCell(strings.Join(branches, ", "), 30)
+		}
+		rows = append(rows, []string{
+			g.Key,
+			branchStr,
+			fmt.Sprintf("%d", g.Requests),
+			formatNumber(g.InputTokens + g.OutputTokens),
+			formatCurrency(g.CostUSD),
+		})
+	}
+
+	columns := []tableColumn{{keyName, 0}, {"Branches", 3}, {"Requests", 1}, {"Tokens", 2}, {"Cost", 0}}
+	fmt.Println(fitTable(columns, rows, 100, costTable))
+	fmt.Println()
+}
+
+func showCostLog() {
+	cfg := loadConfig()
+
+	// Show the last 20 records; only those are kept as the ledger streams
+	var records []UsageRecord
+	eachUsageRecord(cfg, func(r UsageRecord) {
+		if len(records) == 20 {
+			records = append(records[:0], records[1:]...)
+		}
+		records = append(records, r)
+	})
+
+	if len(records) == 0 {
+		fmt.Println("No usage records found.")
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("Recent Usage Records"))
+
+	rows := [][]string{}
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		sessionID := truncateCell(r.SessionID, 18)
+		if sessionID == "" {
+			sessionID = "-"
+		}
+		repo := "-"
+		if r.Repo != "" {
+			repo = r.Repo
+			if r.Branch != "" {
+				repo += "@" + r.Branch
+			}
+			repo = truncateCell(repo, 24)
+		}
+		backend := r.Backend
+		if r.Upstream != "" && r.Upstream != r.Backend {
+			backend += "/" + r.Upstream
+		}
+		rows = append(rows, []string{
+			r.Timestamp.Format("2006-01-02 15:04"),
+			backend,
+			sessionID,
+			repo,
+			fmt.Sprintf("%d", r.InputTokens),
+			fmt.Sprintf("%d", r.OutputTokens),
+			formatCurrency(r.CostUSD),
+		})
+	}
+
+	columns := []tableColumn{{"Timestamp", 0}, {"Backend", 0}, {"Session", 4}, {"Repo", 3}, {"Input", 2}, {"Output", 1}, {"Cost", 0}}
+	fmt.Println(fitTable(columns, rows, 100, costTable))
+	fmt.Println()
+}
+
+func handleBudgetCommand(args []string) {
+	if len(args) == 0 {
+		showBudgetStatus()
+		return
+	}
+
+	subcmd := args[0]
+	switch subcmd {
+	case "status":
+		showBudgetStatus()
+	case "set":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: promptops budget set <daily|weekly|monthly> <amount> [backend]")
+			os.Exit(1)
+		}
+		backend := ""
+		if len(args) > 3 {
+			backend = args[3]
+		}
+		setBudget(args[1], args[2], backend)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown budget command: %s\n", subcmd)
+		os.Exit(1)
+	}
+}
+
+func showBudgetStatus() {
+	cfg := loadConfig()
+	dailyCost, weeklyCost, monthlyCost, _ := calculateCosts(cfg)
+
+	fmt.Println()
 	fmt.Println(styleSection.Render("BUDGET STATUS"))
 	fmt.Println()
 
@@ -2313,10 +3552,17 @@ func showBudgetStatus() {
 	renderProgressBar("Weekly ", weeklyCost, cfg.WeeklyBudget)
 	renderProgressBar("Monthly", monthlyCost, cfg.MonthlyBudget)
 
+	if len(cfg.BackendBudgets) > 0 {
+		fmt.Println()
+		fmt.Println(styleSection.Render("BACKEND BUDGETS"))
+		renderBackendBudgets(cfg, periodCostsByBackend(ledgerUsage(cfg), time.Now()))
+	}
+
 	fmt.Println()
 }
 
-func setBudget(period, amountStr string) {
+// setBudget writes a global budget, or backend's own budget when backend is set
+func setBudget(period, amountStr, backend string) {
 	amount, err := strconv.ParseFloat(amountStr, 64)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: Invalid amount: %s\n", amountStr)
@@ -2344,6 +3590,16 @@ func setBudget(period, amountStr string) {
 		fmt.Fprintf(os.Stderr, "Error: Invalid period '%s'. Use daily, weekly, or monthly.\n", period)
 		os.Exit(1)
 	}
+	label := period
+	if backend != "" {
+		be, ok := backends[backend]
+		if !ok {
+			unknownBackendError(backend)
+			os.Exit(1)
+		}
+		varKey += "_" + strings.ToUpper(be.Name)
+		label = be.DisplayName + " " + period
+	}
 
 	content := string(data)
 	lines := strings.Split(content, "\n")
@@ -2369,81 +3625,201 @@ func setBudget(period, amountStr string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("[OK] Set %s budget to %s\n", period, formatCurrency(amount))
+	fmt.Printf("[OK] Set %s budget to %s\n", label, formatCurrency(amount))
 }
 
-func runDoctor() {
-	cfg := loadConfig()
+// doctorBackends is the order backends are checked and reported in
+var doctorBackends = []string{"claude", "openai", "deepseek", "gemini", "mistral", "zai", "kimi", "grok", "groq", "together", "openrouter", "ollama"}
 
-	fmt.Println()
-	fmt.Println(styleSection.Render("ENVIRONMENT HEALTH CHECK"))
-	fmt.Println()
+// HealthSummary counts health check results by status
+type HealthSummary struct {
+	OK             int
+	Fail           int
+	Skip           int
+	RequiredFailed []string
+}
 
-	rows := [][]string{}
-	for _, name := range []string{"claude", "openai", "deepseek", "gemini", "mistral", "zai", "kimi", "grok", "groq", "together", "openrouter", "ollama"} {
-		be, ok := backends[name]
-		if !ok {
-			continue // Skip unknown backends (defensive)
-		}
-		result := checkBackendHealth(cfg, be)
+// String returns the machine-readable summary line, e.g. "9 ok, 1 fail, 1 skip"
+func (s HealthSummary) String() string {
+	return fmt.Sprintf("%d ok, %d fail, %d skip", s.OK, s.Fail, s.Skip)
+}
+
+// formatHealthLine renders a single health check result as one output line
+func formatHealthLine(be Backend, result HealthResult) string {
+	tag := ""
+	switch result.Status {
+	case "ok":
+		tag = styleSuccess.Render("[OK]  ")
+	case "skip":
+		tag = styleMuted.Render("[--]  ")
+	default:
+		tag = styleError.Render("[FAIL]")
+	}
+
+	latencyStr := "--"
+	if result.Latency > 0 {
+		latencyStr = formatDuration(result.Latency)
+	}
+	return fmt.Sprintf("%s %-22s %8s  %s", tag, be.DisplayName, latencyStr, wrapHealthMessage(result.Message))
+}
+
+// streamHealthChecks checks backends concurrently and writes each result as
+// soon as it completes. With a nil required set every failure counts against
+// the run; otherwise only the listed backends count, and for those a skipped
+// check (no key configured) is a failure too.
+func streamHealthChecks(cfg *Config, names []string, required map[string]bool, check func(*Config, Backend) HealthResult, out io.Writer) HealthSummary {
+	results := make(chan HealthResult, len(names))
+	for _, name := range names {
+		be := backends[name]
+		go func() {
+			results <- check(cfg, be)
+		}()
+	}
+
+	var summary HealthSummary
+	for range names {
+		result := <-results
+		be := backends[result.Backend]
+		fmt.Fprintln(out, formatHealthLine(be, result))
 
-		statusStr := ""
 		switch result.Status {
 		case "ok":
-			statusStr = styleSuccess.Render("OK")
+			summary.OK++
 		case "skip":
-			statusStr = styleMuted.Render("SKIP")
-		case "error":
-			statusStr = styleError.Render("FAIL")
+			summary.Skip++
+		default:
+			summary.Fail++
 		}
 
-		latencyStr := "--"
-		if result.Latency > 0 {
-			latencyStr = formatDuration(result.Latency)
+		failed := result.Status == "error"
+		if required != nil {
+			failed = required[result.Backend] && result.Status != "ok"
+		}
+		if failed {
+			summary.RequiredFailed = append(summary.RequiredFailed, result.Backend)
 		}
-
-		rows = append(rows, []string{
-			be.DisplayName,
-			statusStr,
-			latencyStr,
-			truncate(result.Message, 35),
-		})
 	}
+	sort.Strings(summary.RequiredFailed)
+	return summary
+}
 
-	t := table.New().
-		Headers("Backend", "Status", "Latency", "Message").
-		Rows(rows...).
-		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
-		StyleFunc(func(row, col int) lipgloss.Style {
-			if row == 0 {
-				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
+// parseRequiredFlag extracts "--required a,b" or "--required=a,b" from args
+func parseRequiredFlag(args []string) ([]string, map[string]bool, error) {
+	var rest []string
+	var value string
+	found := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--required":
+			if i+1 >= len(args) {
+				return nil, nil, errors.New("--required requires a comma-separated backend list")
 			}
-			return lipgloss.NewStyle().Padding(0, 1)
-		}).
-		Width(80)
-
-	fmt.Println(t.Render())
-	fmt.Println()
+			value = args[i+1]
+			found = true
+			i++
+		case strings.HasPrefix(args[i], "--required="):
+			value = strings.TrimPrefix(args[i], "--required=")
+			found = true
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	if !found {
+		return rest, nil, nil
+	}
+	names, err := parseBackendList(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --required value: %w", err)
+	}
+	required := make(map[string]bool)
+	for _, name := range names {
+		required[name] = true
+	}
+	return rest, required, nil
 }
 
-func validateBackend(name string) {
+func runDoctor(args []string) {
+	args, onboarding := stripFlag(args, "--onboarding")
+	_, required, err := parseRequiredFlag(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if onboarding && required != nil {
+		fmt.Fprintln(os.Stderr, "Error: --required cannot be used with --onboarding")
+		os.Exit(1)
+	}
 	cfg := loadConfig()
-	be, ok := backends[name]
-	if !ok {
-		fmt.Fprintf(os.Stderr, "Error: Unknown backend '%s'\n", name)
+	if onboarding {
+		runOnboardingDoctor(cfg)
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("ENVIRONMENT HEALTH CHECK"))
+	fmt.Println()
+
+	summary := streamHealthChecks(cfg, doctorBackends, required, checkBackendHealth, os.Stdout)
+
+	fmt.Println()
+	fmt.Println(summary.String())
+
+	if len(cfg.LocalUpstreams) > 0 {
+		fmt.Println()
+		fmt.Println(styleSection.Render("LOCAL UPSTREAMS"))
+		fmt.Println()
+		checkLocalUpstreams(cfg, checkBackendHealth, os.Stdout)
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("MODEL VERSIONS"))
+	fmt.Println()
+	checkModelVersions(cfg, anthropicAPIBase, os.Stdout)
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("DATA FILES"))
+	fmt.Println()
+	renderDataChecks(checkDataHealth(cfg, ledgerUsage(cfg), time.Now()), os.Stdout)
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("RATE LIMITS"))
+	fmt.Println()
+	renderCooldowns(loadCooldowns(cfg.RateLimitFile), time.Now(), os.Stdout)
+
+	if len(summary.RequiredFailed) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: required backends failed: %s\n", strings.Join(summary.RequiredFailed, ", "))
 		os.Exit(1)
 	}
+}
 
-	fmt.Printf("Validating %s...\n", be.DisplayName)
-	result := checkBackendHealth(cfg, be)
+func validateBackend(args []string) {
+	names, required, err := parseRequiredFlag(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: validate requires a backend name")
+		os.Exit(1)
+	}
+	for _, name := range names {
+		if _, ok := backends[name]; !ok {
+			unknownBackendError(name)
+			os.Exit(1)
+		}
+	}
+	// Backends named explicitly are required unless --required narrows it
+	if required == nil {
+		required = make(map[string]bool)
+		for _, name := range names {
+			required[name] = true
+		}
+	}
+	cfg := loadConfig()
 
-	switch result.Status {
-	case "ok":
-		fmt.Printf("[OK] %s is healthy (latency: %s)\n", be.DisplayName, formatDuration(result.Latency))
-	case "skip":
-		fmt.Printf("[--] %s - %s\n", be.DisplayName, result.Message)
-	case "error":
-		fmt.Printf("[FAIL] %s - %s\n", be.DisplayName, result.Message)
+	summary := streamHealthChecks(cfg, names, required, checkBackendHealth, os.Stdout)
+	fmt.Println(summary.String())
+	if len(summary.RequiredFailed) > 0 {
 		os.Exit(1)
 	}
 }
@@ -2457,81 +3833,100 @@ func checkBackendHealth(cfg *Config, be Backend) HealthResult {
 	// Make a lightweight API call to check health
 	start := time.Now()
 
-	var url string
-	var req *http.Request
-	var err error
+	req, skip, err := modelsRequest(cfg, be, apiKey)
+	if skip != "" {
+		return HealthResult{Backend: be.Name, Status: "skip", Message: skip}
+	}
+	if err != nil {
+		return HealthResult{Backend: be.Name, Status: "error", Message: err.Error()}
+	}
 
+	client := *httpClient
+	client.Timeout = cfg.healthTimeout(be.Name)
+
+	// Transient failures (network errors, 429, 5xx) are retried once before
+	// the check is reported as failed
+	var result HealthResult
+	for attempt := 0; attempt <= healthRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(healthRetryDelay)
+			start = time.Now()
+		}
+		var retryable bool
+		result, retryable = doHealthRequest(&client, be, req, start)
+		if result.Status == "ok" || !retryable {
+			break
+		}
+		if attempt == healthRetries {
+			result.Message += " (retried)"
+		}
+	}
+	return result
+}
+
+// modelsRequest builds the model list request used to check a backend's
+// health, authenticated with the backend's auth strategy. skip explains why
+// a backend cannot be checked.
+func modelsRequest(cfg *Config, be Backend, apiKey string) (req *http.Request, skip string, err error) {
+	var url string
 	switch be.Name {
 	case "claude":
 		url = "https://api.anthropic.com/v1/models"
-		req, err = http.NewRequest("GET", url, nil)
-		if err == nil {
-			req.Header.Set("x-api-key", apiKey)
-			req.Header.Set("anthropic-version", "2023-06-01")
-		}
 	case "openai":
 		url = "https://api.openai.com/v1/models"
-		req, err = http.NewRequest("GET", url, nil)
-		if err == nil {
-			req.Header.Set("Authorization", "Bearer "+apiKey)
-		}
 	case "kimi":
 		// Kimi API - try the BaseURL first
-		if be.BaseURL != "" {
-			url = be.BaseURL + "/v1/models"
-			req, err = http.NewRequest("GET", url, nil)
-			if err == nil {
-				req.Header.Set("Authorization", "Bearer "+apiKey)
-			}
-		} else {
-			return HealthResult{Backend: be.Name, Status: "skip", Message: "No BaseURL configured"}
+		if be.BaseURL == "" {
+			return nil, "No BaseURL configured", nil
 		}
+		url = be.BaseURL + "/v1/models"
 	case "ollama":
-		// Ollama is local, no auth required
-		if be.BaseURL != "" {
-			url = be.BaseURL + "/models"
-			req, err = http.NewRequest("GET", url, nil)
-			if err == nil && apiKey != "" {
-				req.Header.Set("Authorization", "Bearer "+apiKey)
-			}
-		} else {
-			return HealthResult{Backend: be.Name, Status: "skip", Message: "No BaseURL configured"}
+		if be.BaseURL == "" {
+			return nil, "No BaseURL configured", nil
 		}
+		url = be.BaseURL + "/models"
 	default:
 		// For other backends, just check if we can resolve the base URL
-		if be.BaseURL != "" {
-			url = be.BaseURL + "/models"
-			req, err = http.NewRequest("GET", url, nil)
-			if err != nil {
-				return HealthResult{Backend: be.Name, Status: "error", Message: err.Error()}
-			}
-			req.Header.Set("Authorization", "Bearer "+apiKey)
-		} else {
-			return HealthResult{Backend: be.Name, Status: "skip", Message: "Health check not implemented"}
+		if be.BaseURL == "" {
+			return nil, "Health check not implemented", nil
 		}
+		url = be.BaseURL + "/models"
 	}
 
-	if err != nil || req == nil {
-		return HealthResult{Backend: be.Name, Status: "error", Message: err.Error()}
+	req, err = http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", err
 	}
+	applyAPIVersion(req, cfg, be.Name)
+	// Ollama is local, so a key is only sent when one is configured
+	if apiKey != "" || be.Name != "ollama" {
+		if err := cfg.authStrategy(be).Apply(req, apiKey); err != nil {
+			return nil, "", err
+		}
+	}
+	return req, "", nil
+}
 
-	client := httpClient
-	resp, err := client.Do(req)
+// doHealthRequest performs one health check request and reports whether a
+// failure is worth retrying
+func doHealthRequest(client *http.Client, be Backend, req *http.Request, start time.Time) (HealthResult, bool) {
+	resp, err := client.Do(req.Clone(context.Background()))
 	latency := time.Since(start)
 
 	if err != nil {
-		return HealthResult{Backend: be.Name, Status: "error", Latency: latency, Message: err.Error()}
+		msg := diagnoseNetworkError(be, req.URL.Host, err, client.Timeout)
+		return HealthResult{Backend: be.Name, Status: "error", Latency: latency, Message: msg}, true
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusOK {
-		return HealthResult{Backend: be.Name, Status: "ok", Latency: latency, Message: "Connection verified"}
+		return HealthResult{Backend: be.Name, Status: "ok", Latency: latency, Message: "Connection verified"}, false
 	}
 
-	// Read body for error details but sanitize to prevent API key exposure
+	// Classify the error from status, headers and body markers; the body itself is never shown
 	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-	errMsg := sanitizeError(fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))).Error()
-	return HealthResult{Backend: be.Name, Status: "error", Latency: latency, Message: truncate(errMsg, 100)}
+	pe := classifyProviderError(be, resp.StatusCode, resp.Header, body)
+	return HealthResult{Backend: be.Name, Status: "error", Latency: latency, Message: pe.Message}, isRetryableHealthStatus(resp.StatusCode)
 }
 
 func handleSessionCommand(args []string) {
@@ -2543,11 +3938,16 @@ func handleSessionCommand(args []string) {
 	subcmd := args[0]
 	switch subcmd {
 	case "start":
-		if len(args) < 2 {
-			fmt.Fprintln(os.Stderr, "Usage: promptops session start <name>")
+		rest, noBackendPrompt := stripFlag(args[1:], "--no-backend-prompt")
+		name, backend, models, err := parseSessionStartArgs(rest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Usage: promptops session start <name> [--backend <name>] [--haiku|--sonnet|--opus <model>] [--no-backend-prompt]")
 			os.Exit(1)
 		}
-		startSession(args[1])
+		startSession(name, backend, models, noBackendPrompt)
+	case "backend-prompt":
+		setSessionBackendPrompt(args[1:])
 	case "list":
 		listSessions()
 	case "resume":
@@ -2562,6 +3962,12 @@ func handleSessionCommand(args []string) {
 			name = args[1]
 		}
 		showSessionInfo(name)
+	case "stats":
+		name := ""
+		if len(args) > 1 {
+			name = args[1]
+		}
+		showSessionStats(name)
 	case "close":
 		if len(args) < 2 {
 			fmt.Fprintln(os.Stderr, "Usage: promptops session close <name>")
@@ -2576,8 +3982,14 @@ func handleSessionCommand(args []string) {
 	}
 }
 
-func startSession(name string) {
+func startSession(name, backend string, models map[string]string, noBackendPrompt bool) {
 	cfg := loadConfig()
+	if backend != "" {
+		if _, ok := backends[backend]; !ok {
+			unknownBackendError(backend)
+			os.Exit(1)
+		}
+	}
 
 	// Check if session with this name already exists
 	sessions := loadSessions(cfg)
@@ -2588,16 +4000,49 @@ func startSession(name string) {
 		}
 	}
 
+	// Tier models may name aliases of the session's backend
+	modelBackend := backend
+	if modelBackend == "" {
+		modelBackend = getCurrentBackend(cfg)
+	}
+	if err := resolveSessionModels(cfg, modelBackend, models); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	session, err := createSession(cfg, name)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if backend != "" || len(models) > 0 || noBackendPrompt {
+		err = updateSession(cfg, session.ID, func(s *Session) {
+			if backend != "" {
+				s.Backend = backend
+			}
+			s.Models = models
+			s.NoBackendPrompt = noBackendPrompt
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to save sessions: %v\n", err)
+			os.Exit(1)
+		}
+		if backend != "" {
+			session.Backend = backend
+			setCurrentBackend(cfg, backend)
+		}
+	}
 	be, ok := backends[session.Backend]
 	if !ok {
 		be = Backend{DisplayName: session.Backend}
 	}
 	fmt.Printf("[OK] Started session '%s' with %s backend\n", session.Name, be.DisplayName)
+	if len(models) > 0 {
+		fmt.Printf("[OK] Session models: %s (applied whenever this session is current)\n", formatSessionModels(models))
+	}
+	if noBackendPrompt {
+		fmt.Println("[OK] Backend system prompt prefix/suffix off for this session")
+	}
 }
 
 func listSessions() {
@@ -2643,9 +4088,15 @@ func listSessions() {
 			backendName = be.DisplayName
 		}
 
+		intent := s.Intent
+		if intent == "" {
+			intent = "-"
+		}
+
 		rows = append(rows, []string{
 			marker,
-			truncate(s.Name, 14),
+			truncateCell(s.Name, 14),
+			truncateCell(intent, 32),
 			backendName,
 			started,
 			fmt.Sprintf("%d", s.PromptCount),
@@ -2654,22 +4105,22 @@ func listSessions() {
 		})
 	}
 
-	t := table.New().
-		Headers("", "Name", "Backend", "Started", "Prompts", "Cost", "Status").
-		Rows(rows...).
-		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
-		StyleFunc(func(row, col int) lipgloss.Style {
-			if row == 0 {
-				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
-			}
-			if col == 0 {
-				return lipgloss.NewStyle().Width(2)
-			}
-			return lipgloss.NewStyle().Padding(0, 1)
-		}).
-		Width(90)
-
-	fmt.Println(t.Render())
+	columns := []tableColumn{{"", 0}, {"Name", 0}, {"Intent", 3}, {"Backend", 1}, {"Started", 4}, {"Prompts", 2}, {"Cost", 0}, {"Status", 0}}
+	fmt.Println(fitTable(columns, rows, 90, func(headers []string, rows [][]string) *table.Table {
+		return table.New().
+			Headers(headers...).
+			Rows(rows...).
+			BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+			StyleFunc(func(row, col int) lipgloss.Style {
+				if row == 0 {
+					return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
+				}
+				if col == 0 {
+					return lipgloss.NewStyle().Width(2)
+				}
+				return lipgloss.NewStyle().Padding(0, 1)
+			})
+	}))
 	fmt.Println()
 }
 
@@ -2677,16 +4128,21 @@ func resumeSession(name string) {
 	cfg := loadConfig()
 	sessions := loadSessions(cfg)
 
-	for i, s := range sessions {
+	for _, s := range sessions {
 		if s.Name == name {
 			if s.Status == "closed" {
 				fmt.Fprintf(os.Stderr, "Error: Session '%s' is closed\n", name)
 				os.Exit(1)
 			}
 
-			sessions[i].Status = "active"
-			sessions[i].LastActive = time.Now()
-			saveSessions(cfg, sessions)
+			err := updateSession(cfg, s.ID, func(s *Session) {
+				s.Status = "active"
+				s.LastActive = time.Now()
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to save sessions: %v\n", err)
+				os.Exit(1)
+			}
 			setCurrentSession(cfg, s.ID)
 
 			// Also switch to the session's backend
@@ -2706,30 +4162,35 @@ func resumeSession(name string) {
 	os.Exit(1)
 }
 
-func showSessionInfo(name string) {
-	cfg := loadConfig()
-	sessions := loadSessions(cfg)
-
+// findSession returns the named session, or the current one when name is
+// empty, exiting with an error if there is none
+func findSession(cfg *Config, name, subcmd string) *Session {
 	var session *Session
 	if name == "" {
 		session = getCurrentSession(cfg)
 		if session == nil {
-			fmt.Println("No active session. Use 'promptops session info <name>' to show a specific session.")
+			fmt.Printf("No active session. Use 'promptops session %s <name>' to show a specific session.\n", subcmd)
 			os.Exit(1)
 		}
-	} else {
-		for _, s := range sessions {
-			if s.Name == name {
-				session = s
-				break
-			}
-		}
+		return session
 	}
 
+	for _, s := range loadSessions(cfg) {
+		if s.Name == name {
+			session = s
+			break
+		}
+	}
 	if session == nil {
 		fmt.Fprintf(os.Stderr, "Error: Session '%s' not found\n", name)
 		os.Exit(1)
 	}
+	return session
+}
+
+func showSessionInfo(name string) {
+	cfg := loadConfig()
+	session := findSession(cfg, name, "info")
 
 	fmt.Println()
 	fmt.Println(styleSection.Render(fmt.Sprintf("SESSION: %s", session.Name)))
@@ -2744,6 +4205,18 @@ func showSessionInfo(name string) {
 		backendName = be.DisplayName
 	}
 	fmt.Printf("%s %s\n", infoStyle.Render("Backend:"), valueStyle.Render(backendName))
+	if session.Intent != "" {
+		fmt.Printf("%s %s\n", infoStyle.Render("Intent:"), valueStyle.Render(session.Intent))
+	}
+	if len(session.Models) > 0 {
+		fmt.Printf("%s %s\n", infoStyle.Render("Models:"), valueStyle.Render(formatSessionModels(session.Models)))
+	}
+	if session.NoBackendPrompt {
+		fmt.Printf("%s %s\n", infoStyle.Render("Backend prompt:"), valueStyle.Render("off"))
+	}
+	if session.MigratedFrom != "" {
+		fmt.Printf("%s %s\n", infoStyle.Render("Migrated From:"), valueStyle.Render(fmt.Sprintf("%s (%d byte summary)", session.MigratedFrom, len(session.Handoff))))
+	}
 
 	statusStr := session.Status
 	switch session.Status {
@@ -2761,6 +4234,13 @@ func showSessionInfo(name string) {
 	fmt.Printf("%s %s\n", infoStyle.Render("Working Dir:"), valueStyle.Render(truncate(session.WorkingDir, 50)))
 	fmt.Printf("%s %s\n", infoStyle.Render("Prompts:"), valueStyle.Render(fmt.Sprintf("%d", session.PromptCount)))
 	fmt.Printf("%s %s\n", infoStyle.Render("Total Cost:"), valueStyle.Render(formatCurrency(session.TotalCost)))
+	if run := session.LastRun; run != nil {
+		lastRun := fmt.Sprintf("%s on %s, %d prompts, %s", formatElapsed(run.Duration()), run.Backend, run.Requests, formatCurrency(run.CostUSD))
+		if run.TimedOut {
+			lastRun += " (time limit reached)"
+		}
+		fmt.Printf("%s %s\n", infoStyle.Render("Last Run:"), valueStyle.Render(lastRun))
+	}
 
 	fmt.Println()
 }
@@ -2770,11 +4250,16 @@ func closeSession(name string) {
 	sessions := loadSessions(cfg)
 	current := getCurrentSession(cfg)
 
-	for i, s := range sessions {
+	for _, s := range sessions {
 		if s.Name == name {
-			sessions[i].Status = "closed"
-			sessions[i].LastActive = time.Now()
-			saveSessions(cfg, sessions)
+			err := updateSession(cfg, s.ID, func(s *Session) {
+				s.Status = "closed"
+				s.LastActive = time.Now()
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to save sessions: %v\n", err)
+				os.Exit(1)
+			}
 
 			// If this was the current session, clear it
 			if current != nil && s.ID == current.ID {
@@ -2794,23 +4279,28 @@ func closeSession(name string) {
 
 func cleanupSessions() {
 	cfg := loadConfig()
-	sessions := loadSessions(cfg)
 
 	// Remove sessions closed for more than 30 days
 	cutoff := time.Now().AddDate(0, 0, -sessionCleanupDays)
-	var kept []*Session
 	removed := 0
-
-	for _, s := range sessions {
-		if s.Status == "closed" && s.LastActive.Before(cutoff) {
-			removed++
-		} else {
-			kept = append(kept, s)
+	err := updateSessions(cfg, func(sessions []*Session) ([]*Session, error) {
+		kept := []*Session{}
+		removed = 0
+		for _, s := range sessions {
+			if s.Status == "closed" && s.LastActive.Before(cutoff) {
+				removed++
+			} else {
+				kept = append(kept, s)
+			}
 		}
+		return kept, nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to save sessions: %v\n", err)
+		os.Exit(1)
 	}
 
 	if removed > 0 {
-		saveSessions(cfg, kept)
 		fmt.Printf("[OK] Removed %d old closed sessions\n", removed)
 	} else {
 		fmt.Println("No old sessions to cleanup")
@@ -2834,6 +4324,11 @@ type UsageInfo struct {
 }
 
 func showAPIUsage(args []string) {
+	args, rng, err := parseUsageRange(args, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 	cfg := loadConfig()
 
 	// If specific backend requested
@@ -2841,7 +4336,7 @@ func showAPIUsage(args []string) {
 		backend := args[0]
 		be, ok := backends[backend]
 		if !ok {
-			fmt.Fprintf(os.Stderr, "Error: Unknown backend '%s'\n", backend)
+			unknownBackendError(backend)
 			os.Exit(1)
 		}
 
@@ -2853,7 +4348,7 @@ func showAPIUsage(args []string) {
 
 		fmt.Println()
 		fmt.Printf("Fetching usage for %s...\n", be.DisplayName)
-		usage := fetchUsageForBackend(be, apiKey)
+		usage := fetchUsageInRange(cfg, be, apiKey, rng)
 		displayUsage(usage)
 		return
 	}
@@ -2862,9 +4357,13 @@ func showAPIUsage(args []string) {
 	fmt.Println()
 	title := styleTitle.Render("API USAGE DASHBOARD")
 	fmt.Println(lipgloss.PlaceHorizontal(80, lipgloss.Center, title))
+	if !rng.IsZero() {
+		fmt.Println(lipgloss.PlaceHorizontal(80, lipgloss.Center, styleMuted.Render("Period: "+rng.Label())))
+	}
 	fmt.Println()
 
 	var usages []UsageInfo
+	var localOnly []string
 	for _, name := range []string{"claude", "openai", "zai", "kimi", "deepseek", "gemini", "mistral", "grok", "groq", "together", "openrouter"} {
 		be, ok := backends[name]
 		if !ok {
@@ -2876,8 +4375,11 @@ func showAPIUsage(args []string) {
 			continue // Skip backends without keys
 		}
 
-		usage := fetchUsageForBackend(be, apiKey)
+		usage := fetchUsageInRange(cfg, be, apiKey, rng)
 		usages = append(usages, usage)
+		if !rng.IsZero() && !usageRangeSupported(be) {
+			localOnly = append(localOnly, be.DisplayName)
+		}
 	}
 
 	if len(usages) == 0 {
@@ -2928,6 +4430,9 @@ func showAPIUsage(args []string) {
 	fmt.Printf("Total across all backends: %s  %s tokens\n",
 		styleAccent.Render(formatCurrency(totalCost)),
 		formatNumber(totalTokens))
+	if len(localOnly) > 0 {
+		fmt.Println(styleMuted.Render("From local usage records (no date range in provider API): " + strings.Join(localOnly, ", ")))
+	}
 	fmt.Println()
 
 	// Show detailed breakdown for each backend
@@ -2940,7 +4445,9 @@ func showAPIUsage(args []string) {
 	}
 }
 
-func fetchUsageForBackend(be Backend, apiKey string) UsageInfo {
+// fetchUsageForBackend fetches usage from the provider. be.Auth must already
+// be resolved with withAuth.
+func fetchUsageForBackend(be Backend, apiKey string, timeout time.Duration, rng UsageRange) UsageInfo {
 	usage := UsageInfo{Backend: be.Name, Period: "current period"}
 
 	switch be.Name {
@@ -2949,11 +4456,11 @@ func fetchUsageForBackend(be Backend, apiKey string) UsageInfo {
 	case "openai":
 		return fetchOpenAIUsage(apiKey)
 	case "kimi":
-		return fetchKimiUsage(apiKey)
+		return fetchKimiUsage(be, apiKey, timeout, rng)
 	default:
 		// For other backends, try generic OpenAI-compatible endpoint or return N/A
 		if be.BaseURL != "" {
-			return fetchOpenAICompatibleUsage(be, apiKey)
+			return fetchOpenAICompatibleUsage(be, apiKey, timeout, rng)
 		}
 		usage.Error = "Usage API not implemented for this provider"
 	}
@@ -2980,22 +4487,29 @@ func fetchOpenAIUsage(apiKey string) UsageInfo {
 	return usage
 }
 
-func fetchKimiUsage(apiKey string) UsageInfo {
+func fetchKimiUsage(be Backend, apiKey string, timeout time.Duration, rng UsageRange) UsageInfo {
 	usage := UsageInfo{Backend: "kimi", Period: "current billing period"}
 
 	// Kimi API usage endpoint
-	ctx, cancel := context.WithTimeout(context.Background(), httpClientTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.kimi.com/coding/usage", nil)
+	url := "https://api.kimi.com/coding/usage"
+	if q := rng.Query(); len(q) > 0 {
+		url += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		usage.Error = "N/A"
 		return usage
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if err := applyAuth(req, be, apiKey); err != nil {
+		usage.Error = err.Error()
+		return usage
+	}
 
-	client := &http.Client{Timeout: httpClientTimeout}
+	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		usage.Error = "N/A"
@@ -3038,12 +4552,15 @@ func fetchKimiUsage(apiKey string) UsageInfo {
 	return usage
 }
 
-func fetchOpenAICompatibleUsage(be Backend, apiKey string) UsageInfo {
+func fetchOpenAICompatibleUsage(be Backend, apiKey string, timeout time.Duration, rng UsageRange) UsageInfo {
 	usage := UsageInfo{Backend: be.Name, Period: "current period"}
 
 	// Generic handler for OpenAI-compatible APIs
 	url := be.BaseURL + "/usage"
-	ctx, cancel := context.WithTimeout(context.Background(), httpClientTimeout)
+	if q := rng.Query(); len(q) > 0 {
+		url += "?" + q.Encode()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -3052,9 +4569,12 @@ func fetchOpenAICompatibleUsage(be Backend, apiKey string) UsageInfo {
 		return usage
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if err := applyAuth(req, be, apiKey); err != nil {
+		usage.Error = err.Error()
+		return usage
+	}
 
-	client := &http.Client{Timeout: httpClientTimeout}
+	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		usage.Error = err.Error()
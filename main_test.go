@@ -8,8 +8,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"nexus/internal/launch"
 )
 
 // ============================================================================
@@ -41,6 +45,36 @@ func TestMaskKey(t *testing.T) {
 	}
 }
 
+func TestSplitLaunchArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+		wantErr  bool
+	}{
+		{"simple", "--permission-mode plan", []string{"--permission-mode", "plan"}, false},
+		{"extra_spaces", "  --model   sonnet ", []string{"--model", "sonnet"}, false},
+		{"double_quoted", `--append-system-prompt "be brief"`, []string{"--append-system-prompt", "be brief"}, false},
+		{"single_quoted", `--append-system-prompt 'be brief'`, []string{"--append-system-prompt", "be brief"}, false},
+		{"wrapped", `"--model sonnet"`, []string{"--model", "sonnet"}, false},
+		{"empty_quoted", `--flag ""`, []string{"--flag", ""}, false},
+		{"empty", "", nil, false},
+		{"unterminated", `--flag "oops`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := splitLaunchArgs(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitLaunchArgs(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if strings.Join(result, "|") != strings.Join(tt.expected, "|") || len(result) != len(tt.expected) {
+				t.Errorf("splitLaunchArgs(%q) = %q, want %q", tt.value, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestFormatCurrency(t *testing.T) {
 	tests := []struct {
 		amount   float64
@@ -470,6 +504,32 @@ func TestLoadConfigPathTraversal(t *testing.T) {
 	}
 }
 
+func TestLoadConfigLaunchArgs(t *testing.T) {
+	// Env files are only accepted from home or the script directory
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	envFile := filepath.Join(home, ".env.local")
+	t.Setenv("NEXUS_ENV_FILE", envFile)
+
+	content := "NEXUS_ARGS_CLAUDE=--permission-mode plan\n" +
+		"NEXUS_ARGS_OLLAMA=\"--model sonnet --append-system-prompt 'be brief'\"\n" +
+		"NEXUS_ARGS_BOGUS=--x\n"
+	if err := os.WriteFile(envFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := loadConfig()
+	if got := strings.Join(cfg.LaunchArgs["claude"], "|"); got != "--permission-mode|plan" {
+		t.Errorf("claude args = %q", got)
+	}
+	if got := strings.Join(cfg.LaunchArgs["ollama"], "|"); got != "--model|sonnet|--append-system-prompt|be brief" {
+		t.Errorf("ollama args = %q", got)
+	}
+	if _, ok := cfg.LaunchArgs["bogus"]; ok {
+		t.Error("Expected unknown backend args to be ignored")
+	}
+}
+
 // ============================================================================
 // State Management Tests
 // ============================================================================
@@ -741,6 +801,38 @@ func TestGetWorkingDir(t *testing.T) {
 // Usage Tracking Tests
 // ============================================================================
 
+func TestGroupUsage(t *testing.T) {
+	records := []UsageRecord{
+		{Backend: "claude", Repo: "webapp", Branch: "main", InputTokens: 100, CostUSD: 1.00},
+		{Backend: "claude", Repo: "webapp", Branch: "feature", OutputTokens: 50, CostUSD: 2.00},
+		{Backend: "deepseek", Repo: "infra", Branch: "main", CostUSD: 0.50},
+		{Backend: "ollama", CostUSD: 0},
+	}
+
+	groups := groupUsage(records, usageRepoKey)
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %d", len(groups))
+	}
+	webapp := groups[0]
+	if webapp.Key != "webapp" || webapp.Requests != 2 || webapp.CostUSD != 3.00 {
+		t.Errorf("Unexpected top group: %+v", webapp)
+	}
+	if webapp.InputTokens != 100 || webapp.OutputTokens != 50 {
+		t.Errorf("Unexpected token totals: %d/%d", webapp.InputTokens, webapp.OutputTokens)
+	}
+	if !webapp.Branches["main"] || !webapp.Branches["feature"] {
+		t.Errorf("Expected both branches, got %v", webapp.Branches)
+	}
+	if groups[2].Key != "(no repo)" {
+		t.Errorf("Expected non-git usage last, got %q", groups[2].Key)
+	}
+
+	byBackend := groupUsage(records, func(r UsageRecord) string { return r.Backend })
+	if byBackend[0].Key != "claude" || len(byBackend) != 3 {
+		t.Errorf("Unexpected backend grouping: %+v", byBackend[0])
+	}
+}
+
 func TestLogUsage(t *testing.T) {
 	tmpDir := t.TempDir()
 	usageFile := filepath.Join(tmpDir, "usage.jsonl")
@@ -1269,6 +1361,78 @@ func TestSessionLifecycle(t *testing.T) {
 	}
 }
 
+// ============================================================================
+// Doctor Tests
+// ============================================================================
+
+func TestParseRequiredFlag(t *testing.T) {
+	rest, required, err := parseRequiredFlag([]string{"claude", "--required", "claude,ollama"})
+	if err != nil {
+		t.Fatalf("parseRequiredFlag failed: %v", err)
+	}
+	if len(rest) != 1 || rest[0] != "claude" {
+		t.Errorf("Expected remaining args [claude], got %v", rest)
+	}
+	if !required["claude"] || !required["ollama"] || len(required) != 2 {
+		t.Errorf("Unexpected required set: %v", required)
+	}
+
+	_, required, err = parseRequiredFlag([]string{"--required=deepseek"})
+	if err != nil || !required["deepseek"] {
+		t.Errorf("Expected deepseek required, got %v (%v)", required, err)
+	}
+
+	_, required, err = parseRequiredFlag(nil)
+	if err != nil || required != nil {
+		t.Errorf("Expected nil required set without flag, got %v (%v)", required, err)
+	}
+
+	if _, _, err := parseRequiredFlag([]string{"--required"}); err == nil {
+		t.Error("Expected error for missing --required value")
+	}
+	if _, _, err := parseRequiredFlag([]string{"--required", "bogus"}); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+}
+
+func TestStreamHealthChecks(t *testing.T) {
+	check := func(cfg *Config, be Backend) HealthResult {
+		switch be.Name {
+		case "claude":
+			return HealthResult{Backend: be.Name, Status: "ok", Latency: 50 * time.Millisecond}
+		case "openai":
+			return HealthResult{Backend: be.Name, Status: "error", Message: "HTTP 500"}
+		default:
+			return HealthResult{Backend: be.Name, Status: "skip", Message: "No API key configured"}
+		}
+	}
+	names := []string{"claude", "openai", "ollama"}
+
+	var out bytes.Buffer
+	summary := streamHealthChecks(&Config{}, names, nil, check, &out)
+	if summary.String() != "1 ok, 1 fail, 1 skip" {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+	if len(summary.RequiredFailed) != 1 || summary.RequiredFailed[0] != "openai" {
+		t.Errorf("Expected openai to fail by default, got %v", summary.RequiredFailed)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 3 {
+		t.Errorf("Expected one line per backend, got %d:\n%s", lines, out.String())
+	}
+
+	// Only required backends count, and a skip is a failure for them
+	out.Reset()
+	summary = streamHealthChecks(&Config{}, names, map[string]bool{"claude": true, "ollama": true}, check, &out)
+	if len(summary.RequiredFailed) != 1 || summary.RequiredFailed[0] != "ollama" {
+		t.Errorf("Expected only ollama to fail, got %v", summary.RequiredFailed)
+	}
+
+	summary = streamHealthChecks(&Config{}, names, map[string]bool{"claude": true}, check, &out)
+	if len(summary.RequiredFailed) != 0 {
+		t.Errorf("Expected no required failures, got %v", summary.RequiredFailed)
+	}
+}
+
 // ============================================================================
 // Benchmark Tests
 // ============================================================================
@@ -1317,3 +1481,107 @@ func BenchmarkCalculateCosts(b *testing.B) {
 		calculateCosts(cfg)
 	}
 }
+
+func TestRunLaunchDirectBackend(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.YoloModes["zai"] = true
+	cfg.LaunchArgs["zai"] = []string{"--verbose"}
+	runner := &launch.FakeRunner{ExitCode: 3}
+	var limit time.Duration
+	code, err := runLaunch(cfg, backends["zai"], []string{"--for", "30m", "-p", "hi"}, func(l time.Duration) launch.Runner {
+		limit = l
+		return runner
+	})
+	if err != nil || code != 3 {
+		t.Fatalf("Expected Claude Code's exit code 3, got %d, %v", code, err)
+	}
+	if limit != 30*time.Minute {
+		t.Errorf("Expected the --for limit passed to the runner, got %v", limit)
+	}
+	if len(runner.Runs) != 1 {
+		t.Fatalf("Expected one run, got %d", len(runner.Runs))
+	}
+	run := runner.Runs[0]
+	if got := strings.Join(run.Args, " "); got != "--dangerously-skip-permissions --verbose -p hi" {
+		t.Errorf("Unexpected args %q", got)
+	}
+	env := strings.Join(run.Env, "\n")
+	for _, want := range []string{
+		"ANTHROPIC_BASE_URL=https://api.z.ai/api/anthropic",
+		"ANTHROPIC_DEFAULT_SONNET_MODEL=glm-5",
+		"ANTHROPIC_DEFAULT_HAIKU_MODEL=glm-4.5-air",
+	} {
+		if !strings.Contains(env, want) {
+			t.Errorf("Expected %s in the environment", want)
+		}
+	}
+}
+
+func TestRunLaunchDirectBackendPrimer(t *testing.T) {
+	project := t.TempDir()
+	writePrimer(t, project, "Use tabs.")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(project); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cfg := newSelfTestConfig(t.TempDir())
+	runner := &launch.FakeRunner{}
+	if _, err := runLaunch(cfg, backends["zai"], nil, func(time.Duration) launch.Runner { return runner }); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.Runs) != 1 {
+		t.Fatalf("Expected one run, got %d", len(runner.Runs))
+	}
+	if got := strings.Join(runner.Runs[0].Args, " "); !strings.Contains(got, "--append-system-prompt Use tabs.") {
+		t.Errorf("Expected the primer passed to Claude Code, got %q", got)
+	}
+}
+
+func TestRunLaunchBlockedByPolicy(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.RequiredCostTags = []string{"team"}
+	runner := &launch.FakeRunner{}
+	code, err := runLaunch(cfg, backends["zai"], nil, func(time.Duration) launch.Runner { return runner })
+	if err == nil || code != 1 {
+		t.Errorf("Expected the launch refused, got %d, %v", code, err)
+	}
+	if len(runner.Runs) != 0 {
+		t.Error("Expected Claude Code not to run")
+	}
+}
+
+// Lock holders must never overlap. Removing the lock file on release used to
+// let a waiter on the old inode and a newcomer on a fresh file both hold it.
+func TestWithFileLockExclusive(t *testing.T) {
+	lock := filepath.Join(t.TempDir(), "counter.lock")
+	var holders, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				withFileLock(lock, func() error {
+					if holders.Add(1) > 1 {
+						overlaps.Add(1)
+					}
+					time.Sleep(50 * time.Microsecond)
+					holders.Add(-1)
+					return nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("Expected exclusive lock holders, got %d overlaps", n)
+	}
+	if _, err := os.Stat(lock); err != nil {
+		t.Errorf("Expected the lock file kept after release: %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Session migration defaults
+const (
+	// migrateTimeout bounds the local summary of a whole conversation
+	migrateTimeout   = 2 * time.Minute
+	migrateMaxTokens = 800
+	// maxMigrateTranscript is how much of the conversation the local model
+	// sees; the most recent turns are kept
+	maxMigrateTranscript = 24000
+	// maxMigrateAnswer caps each answer in the transcript
+	maxMigrateAnswer = 4000
+	// maxHandoffBytes caps the summary, which is sent with every request of
+	// the new session
+	maxHandoffBytes = 8 * 1024
+	// maxFallbackRequests is how many requests a summary lists when no local
+	// model could write one
+	maxFallbackRequests = 20
+	migratePrompt       = "Summarize this conversation between a developer and a coding assistant so another " +
+		"assistant can continue the task. Cover the goal, decisions made, files and commands involved, and " +
+		"what remains to be done. Be concise and factual. Reply with the summary only."
+)
+
+// migrateTurn is one captured prompt and its answer
+type migrateTurn struct {
+	Prompt string
+	Answer string
+}
+
+// capturedTurns returns the captured turns of a session, oldest first.
+// Entries indexed before full prompts were stored fall back to the preview.
+func capturedTurns(idx *PromptIndex, sessionID string) []migrateTurn {
+	var turns []migrateTurn
+	for _, e := range idx.Load() {
+		if e.SessionID != sessionID {
+			continue
+		}
+		prompt := readCapturedText(e.PromptFile)
+		if prompt == "" {
+			prompt = e.Preview
+		}
+		turns = append(turns, migrateTurn{Prompt: prompt, Answer: readCapturedText(e.AnswerFile)})
+	}
+	return turns
+}
+
+// readCapturedText returns a stored prompt or answer, or "" when there is none
+func readCapturedText(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// migrateTranscript renders turns for the summary model, dropping the
+// oldest turns beyond maxMigrateTranscript
+func migrateTranscript(turns []migrateTurn) string {
+	var parts []string
+	size := 0
+	for i := len(turns) - 1; i >= 0; i-- {
+		part := "User: " + turns[i].Prompt
+		if turns[i].Answer != "" {
+			part += "\nAssistant: " + truncate(turns[i].Answer, maxMigrateAnswer)
+		}
+		n := len([]rune(part))
+		if size+n > maxMigrateTranscript && len(parts) > 0 {
+			break
+		}
+		parts = append([]string{part}, parts...)
+		size += n
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// fallbackSummary lists the latest requests and answer, for when no local
+// model could summarize the conversation
+func fallbackSummary(turns []migrateTurn) string {
+	var b strings.Builder
+	b.WriteString("Requests so far:\n")
+	for _, t := range turns[max(0, len(turns)-maxFallbackRequests):] {
+		fmt.Fprintf(&b, "- %s\n", t.Prompt)
+	}
+	if last := turns[len(turns)-1].Answer; last != "" {
+		b.WriteString("\nLatest answer:\n")
+		b.WriteString(truncate(last, maxMigrateAnswer))
+	}
+	return b.String()
+}
+
+// handoffText introduces a summary as the earlier part of the conversation,
+// capped at maxHandoffBytes on a character boundary
+func handoffText(source *Session, summary string) string {
+	text := fmt.Sprintf("This session continues a conversation from session '%s' on %s. "+
+		"Summary of the conversation so far:\n\n%s", source.Name, backendDisplayName(source.Backend), strings.TrimSpace(summary))
+	if len(text) <= maxHandoffBytes {
+		return text
+	}
+	cut := maxHandoffBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
+
+// backendDisplayName returns a backend's display name, or name itself for
+// one that is no longer known
+func backendDisplayName(name string) string {
+	if be, ok := backends[name]; ok {
+		return be.DisplayName
+	}
+	return name
+}
+
+// migrateSession starts session newName on backend to, carrying a summary of
+// source's captured conversation. summarize turns a transcript into a
+// summary; when it fails the latest requests are listed instead.
+func migrateSession(cfg *Config, source *Session, to, newName string, summarize func(transcript string) (string, error)) (*Session, error) {
+	if !cfg.PromptIndexEnabled {
+		return nil, errors.New("conversation capture is off; set NEXUS_PROMPT_INDEX=true to capture conversations")
+	}
+	if source.Backend == to {
+		return nil, fmt.Errorf("session '%s' already uses %s", source.Name, backendDisplayName(to))
+	}
+	for _, s := range loadSessions(cfg) {
+		if s.Name == newName && s.Status != "closed" {
+			return nil, fmt.Errorf("session '%s' already exists (status: %s)", newName, s.Status)
+		}
+	}
+	turns := capturedTurns(NewPromptIndex(cfg), source.ID)
+	if len(turns) == 0 {
+		return nil, fmt.Errorf("no captured conversation for session '%s'; only prompts sent through a local proxy are captured", source.Name)
+	}
+
+	summary, err := summarize(migrateTranscript(turns))
+	if err == nil && strings.TrimSpace(summary) == "" {
+		err = errors.New("empty summary returned")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to summarize the conversation, carrying over the latest requests instead: %v\n", err)
+		summary = fallbackSummary(turns)
+	}
+	handoff := handoffText(source, summary)
+
+	session, err := createSession(cfg, newName)
+	if err != nil {
+		return nil, err
+	}
+	err = updateSession(cfg, session.ID, func(s *Session) {
+		s.Backend = to
+		s.MigratedFrom = source.Name
+		s.Handoff = handoff
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save sessions: %w", err)
+	}
+	session.Backend, session.MigratedFrom, session.Handoff = to, source.Name, handoff
+	if err := setCurrentBackend(cfg, to); err != nil {
+		return nil, fmt.Errorf("failed to set current backend: %w", err)
+	}
+	auditLog(cfg, fmt.Sprintf("SESSION_MIGRATE: %s (%s) -> %s (%s): %d prompts, %d byte summary",
+		source.Name, source.Backend, session.Name, to, len(turns), len(handoff)))
+	return session, nil
+}
+
+// sessionHandoff returns the conversation summary the current session
+// carries into its launches, if it was migrated
+func sessionHandoff(cfg *Config) string {
+	s := getCurrentSession(cfg)
+	if s == nil || s.Handoff == "" {
+		return ""
+	}
+	if cfg.verbose() {
+		fmt.Printf("INFO: Session '%s' continues '%s' with a %d byte summary\n", s.Name, s.MigratedFrom, len(s.Handoff))
+	}
+	return s.Handoff
+}
+
+// parseMigrateArgs parses <name> --to <backend> [--as <new-name>]
+func parseMigrateArgs(args []string) (name, to, newName string, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			if name != "" {
+				return "", "", "", fmt.Errorf("unexpected argument '%s'", arg)
+			}
+			name = arg
+			continue
+		}
+		flag, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !hasValue {
+			if i+1 >= len(args) {
+				return "", "", "", fmt.Errorf("--%s requires a value", flag)
+			}
+			value = args[i+1]
+			i++
+		}
+		switch flag {
+		case "to":
+			to = strings.ToLower(value)
+		case "as":
+			newName = value
+		default:
+			return "", "", "", fmt.Errorf("unknown flag --%s", flag)
+		}
+	}
+	if name == "" {
+		return "", "", "", errors.New("a session name is required")
+	}
+	if to == "" {
+		return "", "", "", errors.New("--to is required")
+	}
+	if newName == "" {
+		newName = name + "-" + to
+	}
+	return name, to, newName, nil
+}
+
+// handleMigrateSession moves a session's conversation to another backend:
+// promptops migrate-session <name> --to <backend> [--as <new-name>]
+func handleMigrateSession(args []string) {
+	name, to, newName, err := parseMigrateArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Usage: promptops migrate-session <name> --to <backend> [--as <new-name>]")
+		os.Exit(1)
+	}
+	if _, ok := backends[to]; !ok {
+		unknownBackendError(to)
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+	source := findSession(cfg, name, "info")
+
+	// The conversation is summarized locally so it only reaches the new
+	// backend, as part of its system prompt
+	summarize := func(transcript string) (string, error) {
+		return localCompletion(backends["ollama"].BaseURL, intentModel(cfg), migratePrompt, transcript, migrateMaxTokens, migrateTimeout)
+	}
+	session, err := migrateSession(cfg, source, to, newName, summarize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("[OK] Started session '%s' with %s backend from '%s'\n", session.Name, backendDisplayName(to), source.Name)
+	fmt.Printf("[OK] Carrying a %d byte conversation summary into its launches\n", len(session.Handoff))
+	fmt.Printf("Continue with: promptops %s\n", to)
+}
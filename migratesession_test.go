@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMigrateTestConfig returns a config with capture on and a session "auth"
+// on ollama holding two captured prompts
+func newMigrateTestConfig(t *testing.T) (*Config, *Session) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := newSelfTestConfig(dir)
+	cfg.AuditEnabled = true
+	cfg.PromptIndexEnabled = true
+	cfg.PromptIndexFile = filepath.Join(dir, "prompt-index.jsonl")
+	cfg.PromptAnswerDir = filepath.Join(dir, "answers")
+	setCurrentBackend(cfg, "ollama")
+	source, err := createSession(cfg, "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := NewPromptIndex(cfg)
+	now := time.Now()
+	for i, turn := range []migrateTurn{
+		{"Add OAuth login to the API", "Added handlers in auth/oauth.go."},
+		{"Now write tests for it", "Added auth/oauth_test.go; two cases still fail."},
+	} {
+		entry := PromptIndexEntry{Timestamp: now.Add(time.Duration(i) * time.Second), SessionID: source.ID, Backend: "ollama", Preview: promptPreview(turn.Prompt)}
+		if err := idx.Add(entry, turn.Prompt, turn.Answer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx.Add(PromptIndexEntry{Timestamp: now, SessionID: "other", Backend: "ollama", Preview: "Unrelated"}, "Unrelated", "")
+	return cfg, source
+}
+
+func TestMigrateTranscript(t *testing.T) {
+	turns := []migrateTurn{
+		{"first", strings.Repeat("a", maxMigrateAnswer*2)},
+		{"second", "short"},
+	}
+	got := migrateTranscript(turns)
+	if !strings.HasPrefix(got, "User: first\nAssistant: ") || !strings.HasSuffix(got, "User: second\nAssistant: short") {
+		t.Errorf("Unexpected transcript %q", truncate(got, 200))
+	}
+	if strings.Count(got, "a") > maxMigrateAnswer {
+		t.Error("Expected long answers capped")
+	}
+
+	// Older turns are dropped first
+	var many []migrateTurn
+	for i := 0; i < 20; i++ {
+		many = append(many, migrateTurn{Prompt: string(rune('A' + i)), Answer: strings.Repeat("x", maxMigrateAnswer)})
+	}
+	got = migrateTranscript(many)
+	if len([]rune(got)) > maxMigrateTranscript+100 {
+		t.Errorf("Expected the transcript capped, got %d characters", len([]rune(got)))
+	}
+	if strings.Contains(got, "User: A\n") || !strings.Contains(got, "User: T\n") {
+		t.Error("Expected the most recent turns kept")
+	}
+}
+
+func TestCapturedTurnsFullPrompt(t *testing.T) {
+	cfg, source := newMigrateTestConfig(t)
+	idx := NewPromptIndex(cfg)
+	long := strings.Repeat("Refactor the session store. ", 10)
+	idx.Add(PromptIndexEntry{Timestamp: time.Now(), SessionID: source.ID, Backend: "ollama", Preview: promptPreview(long)}, long, "Done.")
+	// Entries from before full prompts were stored keep their preview
+	idx.Add(PromptIndexEntry{Timestamp: time.Now(), SessionID: source.ID, Backend: "ollama", Preview: "Old prompt"}, "", "")
+
+	turns := capturedTurns(idx, source.ID)
+	if len(turns) != 4 {
+		t.Fatalf("Expected 4 turns, got %d", len(turns))
+	}
+	if turns[2].Prompt != strings.TrimSpace(long) || turns[2].Answer != "Done." {
+		t.Errorf("Expected the full prompt replayed, got %+v", turns[2])
+	}
+	if turns[3].Prompt != "Old prompt" {
+		t.Errorf("Expected the preview for an old entry, got %q", turns[3].Prompt)
+	}
+}
+
+func TestHandoffTextCapped(t *testing.T) {
+	text := handoffText(&Session{Name: "auth", Backend: "ollama"}, strings.Repeat("é", maxHandoffBytes))
+	if len(text) > maxHandoffBytes {
+		t.Errorf("Expected at most %d bytes, got %d", maxHandoffBytes, len(text))
+	}
+	if !strings.HasPrefix(text, "This session continues a conversation from session 'auth' on Ollama") {
+		t.Errorf("Unexpected introduction %q", truncate(text, 100))
+	}
+	if !strings.HasSuffix(text, "é") {
+		t.Error("Expected the cut on a character boundary")
+	}
+}
+
+func TestMigrateSession(t *testing.T) {
+	cfg, source := newMigrateTestConfig(t)
+	var transcript string
+	session, err := migrateSession(cfg, source, "deepseek", "auth-deepseek", func(text string) (string, error) {
+		transcript = text
+		return "OAuth login added; fix the two failing tests.", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(transcript, "User: Add OAuth login to the API\nAssistant: Added handlers") ||
+		strings.Contains(transcript, "Unrelated") {
+		t.Errorf("Expected only the session's turns, got %q", transcript)
+	}
+	current := getCurrentSession(cfg)
+	if current == nil || current.ID != session.ID || current.Backend != "deepseek" || current.MigratedFrom != "auth" {
+		t.Fatalf("Expected the migrated session current, got %+v", current)
+	}
+	if !strings.HasSuffix(current.Handoff, "OAuth login added; fix the two failing tests.") {
+		t.Errorf("Unexpected handoff %q", current.Handoff)
+	}
+	if getCurrentBackend(cfg) != "deepseek" {
+		t.Errorf("Expected the current backend switched, got %s", getCurrentBackend(cfg))
+	}
+	if sessionHandoff(cfg) != current.Handoff {
+		t.Error("Expected launches to carry the handoff")
+	}
+	audit, _ := os.ReadFile(cfg.AuditLog)
+	if !strings.Contains(string(audit), "SESSION_MIGRATE: auth (ollama) -> auth-deepseek (deepseek): 2 prompts") {
+		t.Errorf("Expected the migration audited, got %q", audit)
+	}
+	if strings.Contains(string(audit), "OAuth login added") {
+		t.Error("Expected the summary kept out of the audit log")
+	}
+}
+
+func TestMigrateSessionFallback(t *testing.T) {
+	cfg, source := newMigrateTestConfig(t)
+	session, err := migrateSession(cfg, source, "deepseek", "auth-deepseek", func(string) (string, error) {
+		return "", errors.New("connection refused")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"- Add OAuth login to the API", "- Now write tests for it", "Latest answer:\nAdded auth/oauth_test.go"} {
+		if !strings.Contains(session.Handoff, want) {
+			t.Errorf("Expected %q in the fallback summary, got %q", want, session.Handoff)
+		}
+	}
+}
+
+func TestMigrateSessionErrors(t *testing.T) {
+	summarize := func(string) (string, error) { return "summary", nil }
+
+	cfg, source := newMigrateTestConfig(t)
+	cfg.PromptIndexEnabled = false
+	if _, err := migrateSession(cfg, source, "deepseek", "next", summarize); err == nil || !strings.Contains(err.Error(), "NEXUS_PROMPT_INDEX") {
+		t.Errorf("Expected a capture error, got %v", err)
+	}
+
+	cfg, source = newMigrateTestConfig(t)
+	if _, err := migrateSession(cfg, source, "ollama", "next", summarize); err == nil {
+		t.Error("Expected an error migrating to the same backend")
+	}
+	if _, err := migrateSession(cfg, source, "deepseek", "auth", summarize); err == nil {
+		t.Error("Expected an error for an existing session name")
+	}
+	empty, _ := createSession(cfg, "empty")
+	if _, err := migrateSession(cfg, empty, "deepseek", "next", summarize); err == nil || !strings.Contains(err.Error(), "no captured conversation") {
+		t.Errorf("Expected an error without captured prompts, got %v", err)
+	}
+}
+
+func TestParseMigrateArgs(t *testing.T) {
+	name, to, newName, err := parseMigrateArgs([]string{"auth", "--to", "DeepSeek"})
+	if err != nil || name != "auth" || to != "deepseek" || newName != "auth-deepseek" {
+		t.Errorf("parseMigrateArgs() = %q %q %q %v", name, to, newName, err)
+	}
+	if _, _, newName, _ := parseMigrateArgs([]string{"auth", "--to=kimi", "--as", "auth-2"}); newName != "auth-2" {
+		t.Errorf("Expected --as used, got %q", newName)
+	}
+	for _, args := range [][]string{{"--to", "kimi"}, {"auth"}, {"auth", "--to"}, {"auth", "--to", "kimi", "--model", "x"}, {"a", "b", "--to", "kimi"}} {
+		if _, _, _, err := parseMigrateArgs(args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ModelAlias is a user-defined model name bound to one backend's model,
+// configured as NEXUS_ALIAS_<NAME>=<backend>/<model>
+type ModelAlias struct {
+	Backend string
+	Model   string
+}
+
+func (a ModelAlias) String() string {
+	return a.Backend + "/" + a.Model
+}
+
+var aliasNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// parseModelAlias validates an alias name and its backend/model target. The
+// model may itself contain slashes (openrouter/anthropic/claude-3.5-sonnet).
+func parseModelAlias(name, value string) (ModelAlias, error) {
+	if !aliasNamePattern.MatchString(name) {
+		return ModelAlias{}, fmt.Errorf("alias names use lowercase letters, digits, '-' and '_'")
+	}
+	if _, ok := backends[name]; ok {
+		return ModelAlias{}, fmt.Errorf("'%s' is a backend name", name)
+	}
+	for _, tier := range modelTiers {
+		if name == tier {
+			return ModelAlias{}, fmt.Errorf("'%s' is a tier name", name)
+		}
+	}
+	backend, model, ok := strings.Cut(value, "/")
+	backend = strings.ToLower(strings.TrimSpace(backend))
+	model = strings.TrimSpace(model)
+	if !ok || backend == "" || model == "" {
+		return ModelAlias{}, fmt.Errorf("expected <backend>/<model>")
+	}
+	if _, known := backends[backend]; !known {
+		return ModelAlias{}, fmt.Errorf("unknown backend '%s'", backend)
+	}
+	if err := validateModelName(model); err != nil {
+		return ModelAlias{}, err
+	}
+	return ModelAlias{Backend: backend, Model: model}, nil
+}
+
+// resolveModel returns the model an alias stands for, or name unchanged when
+// it is not an alias. An alias used for another backend is an error; an
+// empty backend accepts any.
+func (c *Config) resolveModel(backend, name string) (string, error) {
+	alias, ok := c.ModelAliases[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return name, nil
+	}
+	if backend != "" && alias.Backend != backend {
+		return "", fmt.Errorf("alias '%s' is %s, not a %s model", name, alias, backend)
+	}
+	return alias.Model, nil
+}
+
+// resolveConfigAliases replaces aliases wherever the config expects a model,
+// once every key is read so aliases may be defined after their use. Settings
+// naming an alias of another backend are dropped with a warning.
+func resolveConfigAliases(cfg *Config) {
+	if len(cfg.ModelAliases) == 0 {
+		return
+	}
+	resolve := func(key, backend string, model *string) bool {
+		if *model == "" {
+			return true
+		}
+		m, err := cfg.resolveModel(backend, *model)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: invalid %s value '%s': %v\n", key, *model, err)
+			*model = ""
+			return false
+		}
+		*model = m
+		return true
+	}
+
+	// Tier overrides and pins
+	for backend, models := range map[string]map[string]string{
+		"ollama": cfg.OllamaModels,
+		"zai":    cfg.ZAIModels,
+		"kimi":   cfg.KimiModels,
+		"grok":   cfg.GrokModels,
+	} {
+		resolveTierAliases(models, func(tier string, m *string) bool {
+			return resolve(strings.ToUpper(backend+"_"+tier)+"_MODEL", backend, m)
+		})
+	}
+	for backend, models := range cfg.PinnedModels {
+		resolveTierAliases(models, func(tier string, m *string) bool {
+			return resolve(strings.ToUpper("NEXUS_PIN_"+backend+"_"+tier), backend, m)
+		})
+	}
+
+	// Models of one backend's features
+	resolve("NEXUS_EMBED_MODEL", "ollama", &cfg.EmbedModel)
+	resolve("NEXUS_SUMMARY_MODEL", "ollama", &cfg.SummaryModel)
+	resolve("NEXUS_FIM_MODEL", "mistral", &cfg.FIMModel)
+
+	// Proxy features of whichever backend is launched
+	resolve("NEXUS_COMPACT_MODEL", "", &cfg.CompactModel)
+	resolve("NEXUS_DEMOTE_MODEL", "", &cfg.DemoteModel)
+
+	// Local routes send exact models; an alias pattern routes its model.
+	// Routes apply in the proxy of whichever backend is launched.
+	routes := cfg.ModelRoutes[:0]
+	for _, r := range cfg.ModelRoutes {
+		if resolve("NEXUS_LOCAL_ROUTES", "", &r.Pattern) {
+			routes = append(routes, r)
+		}
+	}
+	cfg.ModelRoutes = routes
+}
+
+// resolveTierAliases applies resolve to each tier model, dropping the tiers
+// it rejects
+func resolveTierAliases(models map[string]string, resolve func(tier string, model *string) bool) {
+	for tier, m := range models {
+		if resolve(tier, &m) {
+			models[tier] = m
+		} else {
+			delete(models, tier)
+		}
+	}
+}
+
+// resolveSessionModels replaces aliases in `session start` tier models
+func resolveSessionModels(cfg *Config, backend string, models map[string]string) error {
+	for tier, m := range models {
+		resolved, err := cfg.resolveModel(backend, m)
+		if err != nil {
+			return fmt.Errorf("invalid --%s model: %w", tier, err)
+		}
+		models[tier] = resolved
+	}
+	return nil
+}
+
+// showModelAliases lists the configured aliases (`promptops models aliases`)
+func showModelAliases() {
+	cfg := loadConfig()
+	fmt.Println()
+	fmt.Println(styleSection.Render("MODEL ALIASES"))
+	if len(cfg.ModelAliases) == 0 {
+		fmt.Println(styleMuted.Render("  none - define one with NEXUS_ALIAS_<NAME>=<backend>/<model>"))
+		fmt.Println()
+		return
+	}
+	names := make([]string, 0, len(cfg.ModelAliases))
+	width := 0
+	for name := range cfg.ModelAliases {
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-*s  %s\n", width, name, cfg.ModelAliases[name])
+	}
+	fmt.Println()
+}
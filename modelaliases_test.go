@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseModelAlias(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    ModelAlias
+		wantErr bool
+	}{
+		{"myfast", "groq/llama-3.3-70b-versatile", ModelAlias{"groq", "llama-3.3-70b-versatile"}, false},
+		{"mysmart", " OpenAI / o1 ", ModelAlias{"openai", "o1"}, false},
+		{"router", "openrouter/anthropic/claude-3.5-sonnet", ModelAlias{"openrouter", "anthropic/claude-3.5-sonnet"}, false},
+		{"fast", "llama3.2", ModelAlias{}, true},
+		{"fast", "nope/llama3.2", ModelAlias{}, true},
+		{"fast", "ollama/", ModelAlias{}, true},
+		{"ollama", "ollama/llama3.2", ModelAlias{}, true},
+		{"sonnet", "ollama/llama3.2", ModelAlias{}, true},
+		{"bad name", "ollama/llama3.2", ModelAlias{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			got, err := parseModelAlias(tt.name, tt.value)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseModelAlias(%q, %q) = %v, %v", tt.name, tt.value, got, err)
+			}
+		})
+	}
+}
+
+func TestResolveModel(t *testing.T) {
+	cfg := &Config{ModelAliases: map[string]ModelAlias{
+		"myfast": {"groq", "llama-3.3-70b-versatile"},
+	}}
+	if m, err := cfg.resolveModel("groq", "MyFast"); err != nil || m != "llama-3.3-70b-versatile" {
+		t.Errorf("Expected the alias resolved, got %q, %v", m, err)
+	}
+	if m, err := cfg.resolveModel("", "myfast"); err != nil || m != "llama-3.3-70b-versatile" {
+		t.Errorf("Expected any backend accepted, got %q, %v", m, err)
+	}
+	if _, err := cfg.resolveModel("ollama", "myfast"); err == nil {
+		t.Error("Expected an alias of another backend rejected")
+	}
+	if m, err := cfg.resolveModel("ollama", "llama3.2"); err != nil || m != "llama3.2" {
+		t.Errorf("Expected other names unchanged, got %q, %v", m, err)
+	}
+}
+
+func TestLoadConfigModelAliases(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	envFile := filepath.Join(home, ".env.local")
+	t.Setenv("NEXUS_ENV_FILE", envFile)
+	// Aliases resolve wherever they are defined in the file
+	content := `OLLAMA_SONNET_MODEL=mylocal
+OLLAMA_OPUS_MODEL=myfast
+NEXUS_PIN_GROQ_SONNET=myfast
+NEXUS_LOCAL_UPSTREAMS=lmstudio=http://localhost:1234/v1
+NEXUS_LOCAL_ROUTES=mylocal=lmstudio,myfast=lmstudio,llama*=ollama
+NEXUS_DEMOTE_MODEL=myfast
+NEXUS_ALIAS_MYLOCAL=ollama/qwen2.5-coder:14b
+NEXUS_ALIAS_MYFAST=groq/llama-3.3-70b-versatile
+NEXUS_ALIAS_BROKEN=llama3.2
+`
+	if err := os.WriteFile(envFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := loadConfig()
+
+	if len(cfg.ModelAliases) != 2 {
+		t.Errorf("Expected the invalid alias skipped, got %v", cfg.ModelAliases)
+	}
+	if cfg.OllamaModels["sonnet"] != "qwen2.5-coder:14b" {
+		t.Errorf("Expected the sonnet alias resolved, got %q", cfg.OllamaModels["sonnet"])
+	}
+	if _, ok := cfg.OllamaModels["opus"]; ok {
+		t.Errorf("Expected a groq alias dropped from an ollama tier, got %q", cfg.OllamaModels["opus"])
+	}
+	if m, _ := pinnedModel(cfg, "groq", "sonnet"); m != "llama-3.3-70b-versatile" {
+		t.Errorf("Expected the pin resolved, got %q", m)
+	}
+	if routeModel(cfg.ModelRoutes, "qwen2.5-coder:14b") != "lmstudio" || routeModel(cfg.ModelRoutes, "llama3.2") != "ollama" ||
+		routeModel(cfg.ModelRoutes, "llama-3.3-70b-versatile") != "lmstudio" {
+		t.Errorf("Unexpected routes %+v", cfg.ModelRoutes)
+	}
+	if cfg.DemoteModel != "llama-3.3-70b-versatile" {
+		t.Errorf("Expected the demote model resolved, got %q", cfg.DemoteModel)
+	}
+}
+
+func TestResolveSessionModels(t *testing.T) {
+	cfg := &Config{ModelAliases: map[string]ModelAlias{"mysmart": {"openai", "o1"}}}
+	models := map[string]string{"opus": "mysmart", "haiku": "gpt-4o-mini"}
+	if err := resolveSessionModels(cfg, "openai", models); err != nil || models["opus"] != "o1" || models["haiku"] != "gpt-4o-mini" {
+		t.Errorf("Unexpected session models %v, %v", models, err)
+	}
+	if err := resolveSessionModels(cfg, "ollama", map[string]string{"opus": "mysmart"}); err == nil {
+		t.Error("Expected an alias of another backend rejected")
+	}
+}
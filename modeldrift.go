@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// modelTiers are the Claude Code model tiers that can be pinned
+var modelTiers = []string{"haiku", "sonnet", "opus"}
+
+// claudeTierAliases are the Anthropic aliases Claude Code resolves by default;
+// doctor checks which dated version each currently points to
+var claudeTierAliases = map[string]string{
+	"haiku":  "claude-haiku-4-5",
+	"sonnet": "claude-sonnet-4-5",
+	"opus":   "claude-opus-4-1",
+}
+
+const anthropicAPIBase = "https://api.anthropic.com"
+
+// ModelObservation records which model a provider served for a requested name
+type ModelObservation struct {
+	Backend   string    `json:"backend"`
+	Requested string    `json:"requested"`
+	Resolved  string    `json:"resolved"`
+	Previous  string    `json:"previous,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	ChangedAt time.Time `json:"changed_at,omitempty"`
+}
+
+// parsePinKey splits NEXUS_PIN_<BACKEND>_<TIER> into a lowercase backend and
+// tier. ok is false for other keys.
+func parsePinKey(key string) (backend, tier string, ok bool) {
+	rest, found := strings.CutPrefix(key, "NEXUS_PIN_")
+	if !found {
+		return "", "", false
+	}
+	i := strings.LastIndex(rest, "_")
+	if i <= 0 {
+		return "", "", false
+	}
+	return strings.ToLower(rest[:i]), strings.ToLower(rest[i+1:]), true
+}
+
+// pinnedModel returns the pinned model for a backend tier, if any
+func pinnedModel(cfg *Config, backend, tier string) (string, bool) {
+	m, ok := cfg.PinnedModels[backend][tier]
+	return m, ok && m != ""
+}
+
+func modelObservationKey(backend, requested string) string {
+	return backend + "/" + requested
+}
+
+// loadModelObservations reads recorded model resolutions keyed by backend/requested
+func loadModelObservations(cfg *Config) map[string]*ModelObservation {
+	observations := make(map[string]*ModelObservation)
+	data, err := os.ReadFile(cfg.ModelsFile)
+	if err != nil {
+		return observations
+	}
+	if err := json.Unmarshal(data, &observations); err != nil {
+		return make(map[string]*ModelObservation)
+	}
+	return observations
+}
+
+// observeModel records that requested resolved to resolved on backend and
+// reports whether this differs from the version seen last time
+func observeModel(cfg *Config, backend, requested, resolved string) (*ModelObservation, bool, error) {
+	var obs *ModelObservation
+	drifted := false
+	err := withFileLock(cfg.ModelsFile+".lock", func() error {
+		observations := loadModelObservations(cfg)
+		key := modelObservationKey(backend, requested)
+		now := time.Now()
+
+		obs = observations[key]
+		switch {
+		case obs == nil:
+			obs = &ModelObservation{Backend: backend, Requested: requested, Resolved: resolved, FirstSeen: now}
+			observations[key] = obs
+		case obs.Resolved != resolved:
+			obs.Previous = obs.Resolved
+			obs.Resolved = resolved
+			obs.ChangedAt = now
+			drifted = true
+		}
+		obs.LastSeen = now
+
+		data, err := json.MarshalIndent(observations, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(cfg.ModelsFile, data, 0600)
+	})
+	return obs, drifted, err
+}
+
+// modelDriftWarning describes a changed model resolution
+func modelDriftWarning(obs *ModelObservation) string {
+	return fmt.Sprintf("model drift: %s %s now resolves to %s (was %s) - responses may differ from earlier runs; pin an exact version with NEXUS_PIN_%s_<TIER>",
+		obs.Backend, obs.Requested, obs.Resolved, obs.Previous, strings.ToUpper(obs.Backend))
+}
+
+// ModelTracker records model resolutions seen by a proxy, touching the
+// observations file only when a resolution is new for this process
+type ModelTracker struct {
+	cfg  *Config
+	mu   sync.Mutex
+	seen map[string]string
+}
+
+// NewModelTracker creates a tracker backed by cfg.ModelsFile
+func NewModelTracker(cfg *Config) *ModelTracker {
+	return &ModelTracker{cfg: cfg, seen: make(map[string]string)}
+}
+
+// Observe records a resolution and returns a warning if the model drifted
+func (t *ModelTracker) Observe(backend, requested, resolved string) string {
+	key := modelObservationKey(backend, requested)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.seen[key] == resolved {
+		return ""
+	}
+	t.seen[key] = resolved
+
+	obs, drifted, err := observeModel(t.cfg, backend, requested, resolved)
+	if err != nil || !drifted {
+		return ""
+	}
+	return modelDriftWarning(obs)
+}
+
+// resolveAnthropicModel asks the Anthropic models API which model an alias
+// or ID currently refers to
+func resolveAnthropicModel(client *http.Client, cfg *Config, baseURL, apiKey, model string) (string, error) {
+	req, err := http.NewRequest("GET", baseURL+"/v1/models/"+url.PathEscape(model), nil)
+	if err != nil {
+		return "", err
+	}
+	if err := cfg.authStrategy(backends["claude"]).Apply(req, apiKey); err != nil {
+		return "", err
+	}
+	applyAPIVersion(req, cfg, "claude")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errors.New("model not available")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.ID == "" {
+		return "", errors.New("response has no model id")
+	}
+	return result.ID, nil
+}
+
+// checkModelVersions resolves the Claude tier models, records what they point
+// to, and writes one line per tier plus any drift recorded by the proxies
+func checkModelVersions(cfg *Config, baseURL string, out io.Writer) {
+	checked := false
+	if apiKey := cfg.Keys[backends["claude"].AuthVar]; apiKey != "" {
+		client := *httpClient
+		client.Timeout = cfg.healthTimeout("claude")
+		checked = true
+		for _, tier := range modelTiers {
+			model, pinned := pinnedModel(cfg, "claude", tier)
+			if !pinned {
+				model = claudeTierAliases[tier]
+			}
+			label := fmt.Sprintf("%-22s %-6s", "Claude", tier)
+
+			resolved, err := resolveAnthropicModel(&client, cfg, baseURL, apiKey, model)
+			if err != nil {
+				fmt.Fprintf(out, "%s %s %s: %s\n", styleError.Render("[FAIL]"), label, model, sanitizeError(err))
+				continue
+			}
+			obs, drifted, err := observeModel(cfg, "claude", model, resolved)
+			switch {
+			case err != nil:
+				fmt.Fprintf(out, "%s %s %s -> %s (not recorded: %v)\n", styleWarning.Render("[WARN]"), label, model, resolved, err)
+			case drifted:
+				fmt.Fprintf(out, "%s %s %s\n", styleWarning.Render("[DRIFT]"), label, modelDriftWarning(obs))
+			default:
+				note := ""
+				if pinned {
+					note = " (pinned)"
+				}
+				fmt.Fprintf(out, "%s %s %s -> %s%s\n", styleSuccess.Render("[OK]  "), label, model, resolved, note)
+			}
+		}
+	}
+
+	// Drift seen by the proxies since the resolution was first recorded
+	var drifted []*ModelObservation
+	for _, obs := range loadModelObservations(cfg) {
+		if obs.Previous != "" && obs.Backend != "claude" {
+			drifted = append(drifted, obs)
+		}
+	}
+	sort.Slice(drifted, func(i, j int) bool { return drifted[i].ChangedAt.After(drifted[j].ChangedAt) })
+	for _, obs := range drifted {
+		fmt.Fprintf(out, "%s %s (%s)\n", styleWarning.Render("[DRIFT]"), modelDriftWarning(obs), obs.ChangedAt.Format("2006-01-02"))
+	}
+	if !checked && len(drifted) == 0 {
+		fmt.Fprintln(out, styleMuted.Render("No model drift recorded"))
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePinKey(t *testing.T) {
+	tests := []struct {
+		key         string
+		wantBackend string
+		wantTier    string
+		wantOK      bool
+	}{
+		{"NEXUS_PIN_CLAUDE_SONNET", "claude", "sonnet", true},
+		{"NEXUS_PIN_OLLAMA_OPUS", "ollama", "opus", true},
+		{"NEXUS_PIN_CLAUDE", "", "", false},
+		{"NEXUS_ARGS_CLAUDE", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			backend, tier, ok := parsePinKey(tt.key)
+			if backend != tt.wantBackend || tier != tt.wantTier || ok != tt.wantOK {
+				t.Errorf("parsePinKey(%q) = (%q, %q, %t)", tt.key, backend, tier, ok)
+			}
+		})
+	}
+}
+
+func TestResolveTierModelsPinned(t *testing.T) {
+	cfg := &Config{
+		ZAIModels:    map[string]string{"sonnet": "glm-4.6", "opus": "glm-4.6"},
+		PinnedModels: map[string]map[string]string{"zai": {"opus": "glm-4.6-20250930"}},
+	}
+
+	haiku, sonnet, opus := resolveTierModels(cfg, backends["zai"])
+	if haiku != backends["zai"].HaikuModel {
+		t.Errorf("Expected default haiku model, got %q", haiku)
+	}
+	if sonnet != "glm-4.6" {
+		t.Errorf("Expected custom sonnet model, got %q", sonnet)
+	}
+	if opus != "glm-4.6-20250930" {
+		t.Errorf("Expected pinned opus model to win over custom model, got %q", opus)
+	}
+}
+
+func TestLoadConfigPins(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	envFile := filepath.Join(home, ".env.local")
+	t.Setenv("NEXUS_ENV_FILE", envFile)
+
+	content := "NEXUS_PIN_CLAUDE_SONNET=claude-sonnet-4-5-20250929\n" +
+		"NEXUS_PIN_CLAUDE_TURBO=x\n" +
+		"NEXUS_PIN_BOGUS_OPUS=x\n" +
+		"NEXUS_PIN_OLLAMA_HAIKU=bad model\n"
+	if err := os.WriteFile(envFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := loadConfig()
+	if m, ok := pinnedModel(cfg, "claude", "sonnet"); !ok || m != "claude-sonnet-4-5-20250929" {
+		t.Errorf("Expected pinned claude sonnet, got %q", m)
+	}
+	if len(cfg.PinnedModels) != 1 || len(cfg.PinnedModels["claude"]) != 1 {
+		t.Errorf("Expected invalid pins to be ignored, got %v", cfg.PinnedModels)
+	}
+}
+
+func TestObserveModelDetectsDrift(t *testing.T) {
+	cfg := &Config{ModelsFile: filepath.Join(t.TempDir(), "models.json")}
+
+	if _, drifted, err := observeModel(cfg, "ollama", "llama3.3:latest", "llama3.3:70b-q4"); err != nil || drifted {
+		t.Fatalf("First observation: drifted=%t err=%v", drifted, err)
+	}
+	if _, drifted, _ := observeModel(cfg, "ollama", "llama3.3:latest", "llama3.3:70b-q4"); drifted {
+		t.Error("Unchanged resolution should not drift")
+	}
+
+	obs, drifted, err := observeModel(cfg, "ollama", "llama3.3:latest", "llama3.3:70b-q8")
+	if err != nil || !drifted {
+		t.Fatalf("Expected drift, got drifted=%t err=%v", drifted, err)
+	}
+	if obs.Previous != "llama3.3:70b-q4" || obs.Resolved != "llama3.3:70b-q8" {
+		t.Errorf("Unexpected observation: %+v", obs)
+	}
+	if msg := modelDriftWarning(obs); !strings.Contains(msg, "was llama3.3:70b-q4") || !strings.Contains(msg, "NEXUS_PIN_OLLAMA") {
+		t.Errorf("Unexpected warning: %q", msg)
+	}
+
+	info, err := os.Stat(cfg.ModelsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestModelTrackerWarnsOnce(t *testing.T) {
+	cfg := &Config{ModelsFile: filepath.Join(t.TempDir(), "models.json")}
+	if _, _, err := observeModel(cfg, "ollama", "qwen", "qwen:v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewModelTracker(cfg)
+	if msg := tracker.Observe("ollama", "qwen", "qwen:v2"); msg == "" {
+		t.Error("Expected drift warning against the recorded version")
+	}
+	if msg := tracker.Observe("ollama", "qwen", "qwen:v2"); msg != "" {
+		t.Errorf("Expected no repeat warning, got %q", msg)
+	}
+}
+
+func TestProxyReportsResolvedModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OpenAIResponse{
+			Model:   "llama3.3:70b-instruct-q4",
+			Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: "hi"}, FinishReason: "stop"}},
+		})
+	}))
+	defer server.Close()
+
+	var requested, resolved string
+	proxy := NewOllamaProxy(server.URL, nil)
+	proxy.SetModelObserver(func(req, res string) {
+		requested, resolved = req, res
+	})
+
+	body, _ := json.Marshal(AnthropicRequest{Model: "llama3.3", Messages: []AnthropicMessage{{Role: "user", Content: "hi"}}})
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+
+	if requested != "llama3.3:latest" || resolved != "llama3.3:70b-instruct-q4" {
+		t.Errorf("Observed (%q, %q)", requested, resolved)
+	}
+}
+
+func TestCheckModelVersions(t *testing.T) {
+	aliases := map[string]string{
+		"claude-haiku-4-5":           "claude-haiku-4-5-20251001",
+		"claude-sonnet-4-5-20250929": "claude-sonnet-4-5-20250929",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := aliases[strings.TrimPrefix(r.URL.Path, "/v1/models/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		Keys:         map[string]string{"ANTHROPIC_API_KEY": "sk-ant-test"},
+		PinnedModels: map[string]map[string]string{"claude": {"sonnet": "claude-sonnet-4-5-20250929"}},
+		ModelsFile:   filepath.Join(t.TempDir(), "models.json"),
+	}
+	if _, _, err := observeModel(cfg, "claude", "claude-haiku-4-5", "claude-haiku-4-5-20250101"); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	checkModelVersions(cfg, server.URL, &out)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", out.String())
+	}
+	if !strings.Contains(lines[0], "[DRIFT]") || !strings.Contains(lines[0], "claude-haiku-4-5-20251001") {
+		t.Errorf("Expected haiku drift, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "[OK]") || !strings.Contains(lines[1], "(pinned)") {
+		t.Errorf("Expected pinned sonnet ok, got %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "[FAIL]") || !strings.Contains(lines[2], "not available") {
+		t.Errorf("Expected opus failure, got %q", lines[2])
+	}
+}
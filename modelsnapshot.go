@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSnapshotsPerBackend bounds the stored catalog history per backend
+const maxSnapshotsPerBackend = 30
+
+// ModelSnapshot is a backend's model catalog at a point in time
+type ModelSnapshot struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Models    []string  `json:"models"`
+}
+
+// loadModelSnapshots reads saved catalogs keyed by backend, oldest first
+func loadModelSnapshots(cfg *Config) map[string][]ModelSnapshot {
+	snapshots := make(map[string][]ModelSnapshot)
+	data, err := os.ReadFile(cfg.ModelSnapshotsFile)
+	if err != nil {
+		return snapshots
+	}
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring unreadable model snapshots file: %v\n", err)
+		return make(map[string][]ModelSnapshot)
+	}
+	return snapshots
+}
+
+// saveModelSnapshot appends a catalog for backend, dropping the oldest
+// beyond maxSnapshotsPerBackend, and returns the previous snapshot if any
+func saveModelSnapshot(cfg *Config, backend string, snap ModelSnapshot) (*ModelSnapshot, error) {
+	var previous *ModelSnapshot
+	err := withFileLock(cfg.ModelSnapshotsFile+".lock", func() error {
+		snapshots := loadModelSnapshots(cfg)
+		history := snapshots[backend]
+		if len(history) > 0 {
+			prev := history[len(history)-1]
+			previous = &prev
+		}
+		history = append(history, snap)
+		if len(history) > maxSnapshotsPerBackend {
+			history = history[len(history)-maxSnapshotsPerBackend:]
+		}
+		snapshots[backend] = history
+
+		data, err := json.MarshalIndent(snapshots, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(cfg.ModelSnapshotsFile, data, 0600)
+	})
+	return previous, err
+}
+
+// parseModelList extracts sorted model IDs from an OpenAI or Anthropic style
+// {"data": [{"id": ...}]} response or an Ollama style {"models": [{"name": ...}]}
+func parseModelList(r io.Reader) ([]string, error) {
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r, maxResponseSize)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode model list: %w", err)
+	}
+	seen := make(map[string]bool)
+	var models []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			models = append(models, id)
+		}
+	}
+	for _, m := range result.Data {
+		add(m.ID)
+	}
+	for _, m := range result.Models {
+		add(m.Name)
+	}
+	if len(models) == 0 {
+		return nil, errors.New("response lists no models")
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// fetchModelCatalog lists a backend's models using the health check endpoint
+func fetchModelCatalog(cfg *Config, be Backend) ([]string, error) {
+	apiKey := cfg.Keys[be.AuthVar]
+	if apiKey == "" && be.Name != "ollama" {
+		return nil, errors.New("no API key configured")
+	}
+	req, skip, err := modelsRequest(cfg, be, apiKey)
+	if skip != "" {
+		return nil, errors.New(strings.ToLower(skip))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if be.Name == "claude" {
+		// The Anthropic models API pages 20 at a time by default
+		q := req.URL.Query()
+		q.Set("limit", "1000")
+		req.URL.RawQuery = q.Encode()
+	}
+
+	client := *httpClient
+	client.Timeout = cfg.usageTimeout(be.Name)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return parseModelList(resp.Body)
+}
+
+// diffModelLists returns models added to and removed from a sorted catalog
+func diffModelLists(old, current []string) (added, removed []string) {
+	inOld := make(map[string]bool, len(old))
+	for _, m := range old {
+		inOld[m] = true
+	}
+	inCurrent := make(map[string]bool, len(current))
+	for _, m := range current {
+		inCurrent[m] = true
+		if !inOld[m] {
+			added = append(added, m)
+		}
+	}
+	for _, m := range old {
+		if !inCurrent[m] {
+			removed = append(removed, m)
+		}
+	}
+	return added, removed
+}
+
+// describeModelChanges summarizes catalog changes since an earlier snapshot
+func describeModelChanges(be Backend, previous *ModelSnapshot, current []string) string {
+	if previous == nil {
+		return "first snapshot"
+	}
+	added, removed := diffModelLists(previous.Models, current)
+	since := previous.FetchedAt.Format("2006-01-02")
+	if len(added) == 0 && len(removed) == 0 {
+		return "no changes since " + since
+	}
+	var parts []string
+	if len(added) > 0 {
+		parts = append(parts, fmt.Sprintf("added %d", len(added)))
+	}
+	if len(removed) > 0 {
+		parts = append(parts, fmt.Sprintf("removed %d", len(removed)))
+	}
+	return fmt.Sprintf("%s %s models since %s", be.DisplayName, strings.Join(parts, " and "), since)
+}
+
+// snapshotCandidates returns the requested backends, or every backend with
+// credentials configured
+func snapshotCandidates(cfg *Config, names []string) ([]string, error) {
+	if len(names) > 0 {
+		return parseBackendList(strings.Join(names, ","))
+	}
+	var candidates []string
+	for _, name := range defaultAutoPreference {
+		be := backends[name]
+		if cfg.Keys[be.AuthVar] != "" || be.Name == "ollama" {
+			candidates = append(candidates, name)
+		}
+	}
+	return candidates, nil
+}
+
+func handleSnapshotModels(args []string) {
+	cfg := loadConfig()
+	names, err := snapshotCandidates(cfg, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("MODEL SNAPSHOTS"))
+	failed := 0
+	for _, name := range names {
+		be := backends[name]
+		label := fmt.Sprintf("%-22s", be.DisplayName)
+		models, err := fetchModelCatalog(cfg, be)
+		if err != nil {
+			fmt.Printf("%s %s %s\n", styleError.Render("[FAIL]"), label, sanitizeError(err))
+			failed++
+			continue
+		}
+		previous, err := saveModelSnapshot(cfg, name, ModelSnapshot{FetchedAt: time.Now(), Models: models})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving snapshot: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s %s %d models (%s)\n", styleSuccess.Render("[OK]  "), label, len(models), describeModelChanges(be, previous, models))
+	}
+	fmt.Println()
+	if failed > 0 && failed == len(names) {
+		os.Exit(1)
+	}
+}
+
+func handleModelsCommand(args []string) {
+	args, offline := stripFlag(args, "--offline")
+	if len(args) == 1 && args[0] == "aliases" {
+		showModelAliases()
+		return
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: promptops models <backend> [--offline] | models aliases")
+		os.Exit(1)
+	}
+	name := strings.ToLower(args[0])
+	be, ok := backends[name]
+	if !ok {
+		unknownBackendError(args[0])
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+
+	history := loadModelSnapshots(cfg)[name]
+	var models []string
+	source := "live"
+	if offline {
+		if len(history) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no snapshot for %s; run 'promptops snapshot-models %s' first\n", name, name)
+			os.Exit(1)
+		}
+		latest := history[len(history)-1]
+		models = latest.Models
+		source = "snapshot " + latest.FetchedAt.Format("2006-01-02 15:04")
+		history = history[:len(history)-1]
+	} else {
+		var err error
+		models, err = fetchModelCatalog(cfg, be)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", sanitizeError(err))
+			os.Exit(1)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render(fmt.Sprintf("%s MODELS (%s)", strings.ToUpper(be.DisplayName), source)))
+	var added map[string]bool
+	if len(history) > 0 {
+		previous := history[len(history)-1]
+		newModels, _ := diffModelLists(previous.Models, models)
+		added = make(map[string]bool, len(newModels))
+		for _, m := range newModels {
+			added[m] = true
+		}
+		fmt.Println(styleMuted.Render(describeModelChanges(be, &previous, models)))
+	}
+	for _, m := range models {
+		if added[m] {
+			fmt.Printf("  %s %s\n", m, styleSuccess.Render("(new)"))
+			continue
+		}
+		fmt.Printf("  %s\n", m)
+	}
+	fmt.Printf("%d models\n", len(models))
+	fmt.Println()
+}
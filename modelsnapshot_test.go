@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseModelList(t *testing.T) {
+	openai := `{"object": "list", "data": [{"id": "gpt-5"}, {"id": "gpt-4.1"}, {"id": "gpt-5"}]}`
+	models, err := parseModelList(strings.NewReader(openai))
+	if err != nil {
+		t.Fatalf("parseModelList() error: %v", err)
+	}
+	if strings.Join(models, ",") != "gpt-4.1,gpt-5" {
+		t.Errorf("Expected sorted unique models, got %v", models)
+	}
+
+	ollama := `{"models": [{"name": "qwen2.5-coder:7b"}, {"name": "llama3.2:latest"}]}`
+	if models, _ := parseModelList(strings.NewReader(ollama)); len(models) != 2 || models[0] != "llama3.2:latest" {
+		t.Errorf("Unexpected Ollama models: %v", models)
+	}
+
+	if _, err := parseModelList(strings.NewReader(`{"data": []}`)); err == nil {
+		t.Error("Expected error for an empty catalog")
+	}
+	if _, err := parseModelList(strings.NewReader(`not json`)); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+func TestDiffModelLists(t *testing.T) {
+	added, removed := diffModelLists([]string{"a", "b", "c"}, []string{"b", "c", "d", "e"})
+	if strings.Join(added, ",") != "d,e" || strings.Join(removed, ",") != "a" {
+		t.Errorf("Expected added d,e and removed a, got %v and %v", added, removed)
+	}
+}
+
+func TestDescribeModelChanges(t *testing.T) {
+	be := backends["openrouter"]
+	if got := describeModelChanges(be, nil, []string{"a"}); got != "first snapshot" {
+		t.Errorf("Unexpected first snapshot summary: %q", got)
+	}
+
+	previous := &ModelSnapshot{FetchedAt: time.Date(2026, 10, 9, 0, 0, 0, 0, time.UTC), Models: []string{"a", "b"}}
+	got := describeModelChanges(be, previous, []string{"b", "c", "d"})
+	if got != "OpenRouter added 2 and removed 1 models since 2026-10-09" {
+		t.Errorf("Unexpected change summary: %q", got)
+	}
+	if got := describeModelChanges(be, previous, []string{"a", "b"}); !strings.HasPrefix(got, "no changes") {
+		t.Errorf("Expected no changes, got %q", got)
+	}
+}
+
+func TestSaveModelSnapshot(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+
+	prev, err := saveModelSnapshot(cfg, "openai", ModelSnapshot{FetchedAt: time.Now(), Models: []string{"m0"}})
+	if err != nil || prev != nil {
+		t.Fatalf("Expected no previous snapshot, got %+v (err=%v)", prev, err)
+	}
+	for i := 1; i <= maxSnapshotsPerBackend+5; i++ {
+		prev, err = saveModelSnapshot(cfg, "openai", ModelSnapshot{FetchedAt: time.Now(), Models: []string{"m" + strconv.Itoa(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if prev == nil {
+		t.Fatal("Expected the previous snapshot to be returned")
+	}
+
+	history := loadModelSnapshots(cfg)["openai"]
+	if len(history) != maxSnapshotsPerBackend {
+		t.Errorf("Expected history capped at %d, got %d", maxSnapshotsPerBackend, len(history))
+	}
+	info, err := os.Stat(cfg.ModelSnapshotsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestFetchModelCatalog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"data": [{"id": "llama3.2:latest"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := newSelfTestConfig(t.TempDir())
+	be := backends["ollama"]
+	be.BaseURL = server.URL + "/v1"
+
+	models, err := fetchModelCatalog(cfg, be)
+	if err != nil || len(models) != 1 || models[0] != "llama3.2:latest" {
+		t.Errorf("Unexpected catalog %v (err=%v)", models, err)
+	}
+
+	if _, err := fetchModelCatalog(cfg, backends["deepseek"]); err == nil {
+		t.Error("Expected error for a backend without a key")
+	}
+}
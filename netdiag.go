@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Health check retry settings
+const (
+	healthRetries    = 1
+	healthRetryDelay = 500 * time.Millisecond
+)
+
+// regionRestrictedBackends are providers that are often unreachable from
+// outside their home region or from some corporate networks
+var regionRestrictedBackends = map[string]bool{
+	"zai":  true,
+	"kimi": true,
+}
+
+// isRetryableHealthStatus reports whether an HTTP status may succeed on retry.
+// Authentication, billing and not-found errors are deterministic.
+func isRetryableHealthStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// diagnoseNetworkError turns a transport error into a message naming the
+// failing layer (DNS, TCP, TLS or timeout) and its likely causes
+func diagnoseNetworkError(be Backend, host string, err error, timeout time.Duration) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	var netErr net.Error
+
+	msg := ""
+	regional := false
+	switch {
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			msg = fmt.Sprintf("DNS lookup failed: host %s not found - check the base URL, VPN or DNS settings", host)
+		} else {
+			msg = fmt.Sprintf("DNS lookup failed for %s - check your network connection, VPN or DNS settings", host)
+		}
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidCert), errors.As(err, &recordErr):
+		msg = fmt.Sprintf("TLS handshake with %s failed - a proxy, VPN or firewall may be intercepting HTTPS, or the system clock is wrong", host)
+		regional = true
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		msg = fmt.Sprintf("no response from %s within %s - the provider may be slow or blocked; raise NEXUS_HEALTH_TIMEOUT_%s or pass --timeout",
+			host, formatDuration(timeout), strings.ToUpper(be.Name))
+		regional = true
+	case errors.Is(err, syscall.ECONNREFUSED):
+		if be.Name == "ollama" {
+			msg = fmt.Sprintf("connection refused by %s - is Ollama running (ollama serve)?", host)
+		} else {
+			msg = fmt.Sprintf("connection refused by %s - check the base URL and any HTTP proxy settings", host)
+		}
+	case errors.Is(err, syscall.ECONNRESET):
+		msg = fmt.Sprintf("connection to %s was reset - a firewall or VPN may be blocking the provider", host)
+		regional = true
+	default:
+		msg = "connection failed: " + sanitizeError(err).Error()
+	}
+
+	if regional && regionRestrictedBackends[be.Name] {
+		msg += fmt.Sprintf("; %s may be blocked from your region - try a VPN", be.DisplayName)
+	}
+	return msg
+}
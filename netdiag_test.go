@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDiagnoseNetworkError(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		err     error
+		want    []string
+		notWant string
+	}{
+		{
+			name:    "dns not found",
+			backend: "deepseek",
+			err:     &url.Error{Op: "Get", URL: "https://api.example", Err: &net.DNSError{Name: "api.example", IsNotFound: true}},
+			want:    []string{"DNS lookup failed", "not found"},
+		},
+		{
+			name:    "tls unknown authority",
+			backend: "openai",
+			err:     &url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}},
+			want:    []string{"TLS handshake", "intercepting HTTPS"},
+			notWant: "region",
+		},
+		{
+			name:    "timeout",
+			backend: "kimi",
+			err:     &url.Error{Op: "Get", Err: context.DeadlineExceeded},
+			want:    []string{"no response", "NEXUS_HEALTH_TIMEOUT_KIMI", "region"},
+		},
+		{
+			name:    "ollama refused",
+			backend: "ollama",
+			err:     &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}},
+			want:    []string{"connection refused", "ollama serve"},
+		},
+		{
+			name:    "reset in region restricted backend",
+			backend: "zai",
+			err:     &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			want:    []string{"reset", "try a VPN"},
+		},
+		{
+			name:    "unknown",
+			backend: "groq",
+			err:     errors.New("something odd"),
+			want:    []string{"connection failed: something odd"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := diagnoseNetworkError(backends[tt.backend], "api.example", tt.err, 5*time.Second)
+			for _, want := range tt.want {
+				if !strings.Contains(msg, want) {
+					t.Errorf("Expected %q in %q", want, msg)
+				}
+			}
+			if tt.notWant != "" && strings.Contains(msg, tt.notWant) {
+				t.Errorf("Did not expect %q in %q", tt.notWant, msg)
+			}
+		})
+	}
+}
+
+func TestCheckBackendHealthRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int32
+		wantOK    bool
+	}{
+		{"transient failure recovers", []int{http.StatusBadGateway, http.StatusOK}, 2, true},
+		{"persistent failure", []int{http.StatusInternalServerError, http.StatusInternalServerError}, 2, false},
+		{"auth failure not retried", []int{http.StatusUnauthorized}, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer server.Close()
+
+			be := backends["ollama"]
+			be.BaseURL = server.URL
+			result := checkBackendHealth(&Config{Keys: map[string]string{}}, be)
+
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("Expected %d requests, got %d", tt.wantCalls, got)
+			}
+			if (result.Status == "ok") != tt.wantOK {
+				t.Errorf("Unexpected result: %+v", result)
+			}
+			if !tt.wantOK && tt.wantCalls > 1 && !strings.Contains(result.Message, "retried") {
+				t.Errorf("Expected retry noted in message, got %q", result.Message)
+			}
+		})
+	}
+}
+
+func TestCheckBackendHealthConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	be := backends["ollama"]
+	be.BaseURL = fmt.Sprintf("http://%s", addr)
+	result := checkBackendHealth(&Config{Keys: map[string]string{}}, be)
+
+	if result.Status != "error" || !strings.Contains(result.Message, "ollama serve") {
+		t.Errorf("Expected connection refused diagnosis, got %+v", result)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Onboarding check statuses. Skipped checks don't count toward the score.
+const (
+	onboardingOK   = "ok"
+	onboardingWarn = "warn"
+	onboardingFail = "fail"
+	onboardingSkip = "skip"
+)
+
+// OnboardingCheck is one item of the doctor --onboarding checklist. Weight
+// is the share of the readiness score it carries; a warning earns half of it.
+type OnboardingCheck struct {
+	Name    string
+	Status  string
+	Weight  int
+	Message string
+	// Fix is the next step that resolves a warning or failure
+	Fix string
+}
+
+// onboardingProbes are the parts of the checklist that look outside the
+// config, replaced in tests
+type onboardingProbes struct {
+	lookPath     func(file string) (string, error)
+	health       func(cfg *Config, be Backend) HealthResult
+	ollamaModels func(cfg *Config) ([]string, error)
+}
+
+var defaultOnboardingProbes = onboardingProbes{
+	lookPath: exec.LookPath,
+	health:   checkBackendHealth,
+	ollamaModels: func(cfg *Config) ([]string, error) {
+		return fetchModelCatalog(cfg, backends["ollama"])
+	},
+}
+
+// runOnboardingChecks runs the checklist for a new install
+func runOnboardingChecks(cfg *Config, probes onboardingProbes) []OnboardingCheck {
+	return []OnboardingCheck{
+		checkClaudeBinary(probes.lookPath),
+		checkEnvFile(cfg),
+		checkKeyValidity(cfg, probes.health),
+		checkFilePermissions(cfg),
+		checkGitignoreHygiene(cfg),
+		checkBudgetsConfigured(cfg),
+		checkLocalModels(cfg, probes.ollamaModels),
+	}
+}
+
+func checkClaudeBinary(lookPath func(string) (string, error)) OnboardingCheck {
+	c := OnboardingCheck{Name: "Claude Code", Weight: 25}
+	path, err := lookPath("claude")
+	if err != nil {
+		c.Status, c.Message = onboardingFail, "claude is not on PATH"
+		c.Fix = "Install Claude Code (npm install -g @anthropic-ai/claude-code) and make sure claude is on PATH"
+		return c
+	}
+	c.Status, c.Message = onboardingOK, path
+	return c
+}
+
+func checkEnvFile(cfg *Config) OnboardingCheck {
+	c := OnboardingCheck{Name: "Config file", Weight: 10}
+	if _, err := os.Stat(cfg.EnvFile); err != nil {
+		c.Status, c.Message = onboardingFail, filepath.Base(cfg.EnvFile)+" not found"
+		c.Fix = "Run 'promptops init' to create " + cfg.EnvFile
+		return c
+	}
+	c.Status, c.Message = onboardingOK, cfg.EnvFile
+	return c
+}
+
+// checkKeyValidity health-checks every backend with a key configured
+func checkKeyValidity(cfg *Config, health func(*Config, Backend) HealthResult) OnboardingCheck {
+	c := OnboardingCheck{Name: "API keys", Weight: 25}
+	var names []string
+	for _, name := range doctorBackends {
+		if be := backends[name]; name != "ollama" && cfg.Keys[be.AuthVar] != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		c.Status, c.Message = onboardingFail, "no API keys configured"
+		c.Fix = fmt.Sprintf("Add a key for at least one backend to %s (e.g. ANTHROPIC_API_KEY=...)", filepath.Base(cfg.EnvFile))
+		return c
+	}
+
+	results := make(chan HealthResult, len(names))
+	for _, name := range names {
+		be := backends[name]
+		go func() { results <- health(cfg, be) }()
+	}
+	var valid, failed []string
+	for range names {
+		r := <-results
+		if r.Status == "error" {
+			failed = append(failed, r.Backend)
+		} else {
+			valid = append(valid, r.Backend)
+		}
+	}
+	sort.Strings(valid)
+	sort.Strings(failed)
+
+	switch {
+	case len(failed) == 0:
+		c.Status, c.Message = onboardingOK, fmt.Sprintf("%d verified (%s)", len(valid), strings.Join(valid, ", "))
+	case len(valid) == 0:
+		c.Status, c.Message = onboardingFail, "none verified, failed: "+strings.Join(failed, ", ")
+		c.Fix = "Check the keys and connectivity with 'promptops validate " + strings.Join(failed, " ") + "'"
+	default:
+		c.Status, c.Message = onboardingWarn, fmt.Sprintf("%d verified, failed: %s", len(valid), strings.Join(failed, ", "))
+		c.Fix = "Check the failing keys with 'promptops validate " + strings.Join(failed, " ") + "'"
+	}
+	return c
+}
+
+// checkFilePermissions flags sensitive files other users can read
+func checkFilePermissions(cfg *Config) OnboardingCheck {
+	c := OnboardingCheck{Name: "File permissions", Weight: 15}
+	var open []string
+	for _, path := range []string{cfg.EnvFile, cfg.UsageFile, cfg.AuditLog, cfg.SessionsFile, cfg.CreditsFile, cfg.APITokenFile, cfg.LedgerKeyFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+			open = append(open, path)
+		}
+	}
+	if len(open) > 0 {
+		c.Status = onboardingFail
+		c.Message = fmt.Sprintf("%d files readable by other users", len(open))
+		c.Fix = "Restrict them to your user: chmod 600 " + strings.Join(open, " ")
+		return c
+	}
+	c.Status, c.Message = onboardingOK, "no key, usage, audit or session files readable by others"
+	return c
+}
+
+// checkGitignoreHygiene checks that the env file and local state are kept
+// out of the repository the config directory is in
+func checkGitignoreHygiene(cfg *Config) OnboardingCheck {
+	c := OnboardingCheck{Name: "Git hygiene", Weight: 15}
+	dir := filepath.Dir(cfg.EnvFile)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if runGit(dir, "rev-parse", "--show-toplevel") == "" {
+		c.Status, c.Message = onboardingOK, "config directory is not in a git repository"
+		return c
+	}
+	root := projectRoot(dir)
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	envFile := filepath.Join(dir, filepath.Base(cfg.EnvFile))
+	if trackedByGit(root, envFile) {
+		c.Status = onboardingFail
+		c.Message = filepath.Base(envFile) + " is tracked by git"
+		c.Fix = "Stop tracking it with 'git rm --cached " + envFile + "' and rotate the keys it held"
+		return c
+	}
+	data, _ := os.ReadFile(filepath.Join(root, ".gitignore"))
+	if missing := missingIgnoreEntries(string(data), gitignoreEntries(root, dir)); len(missing) > 0 {
+		c.Status = onboardingWarn
+		c.Message = fmt.Sprintf("%d .gitignore entries missing (%s)", len(missing), strings.Join(missing, ", "))
+		c.Fix = "Run 'promptops init' to add the .gitignore entries"
+		return c
+	}
+	c.Status, c.Message = onboardingOK, "env file and local state are ignored"
+	return c
+}
+
+// checkBudgetsConfigured warns when only the built-in budgets apply
+func checkBudgetsConfigured(cfg *Config) OnboardingCheck {
+	c := OnboardingCheck{Name: "Budgets", Weight: 5}
+	limits := fmt.Sprintf("%s/day, %s/week, %s/month", formatCurrency(cfg.DailyBudget), formatCurrency(cfg.WeeklyBudget), formatCurrency(cfg.MonthlyBudget))
+	data, _ := os.ReadFile(cfg.EnvFile)
+	for _, line := range strings.Split(string(data), "\n") {
+		key, _, _ := strings.Cut(strings.TrimSpace(line), "=")
+		for _, prefix := range []string{"NEXUS_DAILY_BUDGET", "NEXUS_WEEKLY_BUDGET", "NEXUS_MONTHLY_BUDGET"} {
+			if strings.HasPrefix(key, prefix) {
+				c.Status, c.Message = onboardingOK, limits
+				return c
+			}
+		}
+	}
+	c.Status, c.Message = onboardingWarn, "using the defaults ("+limits+")"
+	c.Fix = "Set budgets that match your team's spend, e.g. 'promptops budget set daily 5'"
+	return c
+}
+
+// checkLocalModels checks that Ollama serves the models configured for it.
+// Ollama is optional, so it is skipped when it is neither running nor
+// configured.
+func checkLocalModels(cfg *Config, list func(*Config) ([]string, error)) OnboardingCheck {
+	c := OnboardingCheck{Name: "Local models", Weight: 5}
+	var configured []string
+	for _, tier := range []string{"haiku", "sonnet", "opus"} {
+		if m := strings.TrimSpace(cfg.OllamaModels[tier]); m != "" && !slices.Contains(configured, m) {
+			configured = append(configured, m)
+		}
+	}
+
+	models, err := list(cfg)
+	if err != nil {
+		if len(configured) == 0 {
+			c.Status, c.Message = onboardingSkip, "Ollama not running (optional)"
+			return c
+		}
+		c.Status, c.Message = onboardingWarn, "Ollama not reachable: "+err.Error()
+		c.Fix = "Start Ollama with 'ollama serve' to use the configured local models"
+		return c
+	}
+
+	available := make(map[string]bool)
+	for _, m := range models {
+		available[ollamaModelTag(m)] = true
+	}
+	var missing []string
+	for _, m := range configured {
+		if !available[ollamaModelTag(m)] {
+			missing = append(missing, m)
+		}
+	}
+	switch {
+	case len(missing) > 0:
+		c.Status, c.Message = onboardingWarn, "not pulled: "+strings.Join(missing, ", ")
+		c.Fix = "Pull the configured models: ollama pull " + strings.Join(missing, " && ollama pull ")
+	case len(models) == 0:
+		c.Status, c.Message = onboardingWarn, "Ollama is running without models"
+		c.Fix = "Pull a model (e.g. 'ollama pull llama3.2') and set OLLAMA_SONNET_MODEL"
+	default:
+		c.Status, c.Message = onboardingOK, fmt.Sprintf("%d models available", len(models))
+	}
+	return c
+}
+
+// ollamaModelTag adds the :latest tag Ollama assumes for untagged names
+func ollamaModelTag(name string) string {
+	if !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
+
+// onboardingScore is the percentage of the applicable weight earned
+func onboardingScore(checks []OnboardingCheck) int {
+	earned, total := 0, 0
+	for _, c := range checks {
+		switch c.Status {
+		case onboardingOK:
+			earned += 2 * c.Weight
+		case onboardingWarn:
+			earned += c.Weight
+		case onboardingSkip:
+			continue
+		}
+		total += 2 * c.Weight
+	}
+	if total == 0 {
+		return 100
+	}
+	return earned * 100 / total
+}
+
+// onboardingNextSteps orders the fixes: failures, then warnings, each in
+// checklist order, which puts prerequisites first
+func onboardingNextSteps(checks []OnboardingCheck) []OnboardingCheck {
+	var steps []OnboardingCheck
+	for _, c := range checks {
+		if c.Fix != "" && (c.Status == onboardingFail || c.Status == onboardingWarn) {
+			steps = append(steps, c)
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Status == onboardingFail && steps[j].Status != onboardingFail
+	})
+	return steps
+}
+
+// renderOnboarding prints the checklist, the readiness score and next steps
+func renderOnboarding(checks []OnboardingCheck, out io.Writer) {
+	for _, c := range checks {
+		var tag string
+		switch c.Status {
+		case onboardingOK:
+			tag = styleSuccess.Render("[OK]  ")
+		case onboardingWarn:
+			tag = styleWarning.Render("[WARN]")
+		case onboardingSkip:
+			tag = styleMuted.Render("[--]  ")
+		default:
+			tag = styleError.Render("[FAIL]")
+		}
+		fmt.Fprintf(out, "%s %-22s %s\n", tag, c.Name, c.Message)
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Readiness: %d/100\n", onboardingScore(checks))
+
+	steps := onboardingNextSteps(checks)
+	if len(steps) == 0 {
+		fmt.Fprintln(out, styleSuccess.Render("Ready to go - try 'promptops status'"))
+		return
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, styleSection.Render("NEXT STEPS"))
+	fmt.Fprintln(out)
+	for i, c := range steps {
+		fmt.Fprintf(out, "  %d. %s\n", i+1, c.Fix)
+	}
+}
+
+// runOnboardingDoctor handles promptops doctor --onboarding. It exits
+// non-zero while any check fails.
+func runOnboardingDoctor(cfg *Config) {
+	fmt.Println()
+	fmt.Println(styleSection.Render("ONBOARDING CHECKLIST"))
+	fmt.Println()
+
+	checks := runOnboardingChecks(cfg, defaultOnboardingProbes)
+	renderOnboarding(checks, os.Stdout)
+	for _, c := range checks {
+		if c.Status == onboardingFail {
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newOnboardingConfig(t *testing.T, env string) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := newSelfTestConfig(dir)
+	cfg.EnvFile = filepath.Join(dir, ".env.local")
+	cfg.Keys = make(map[string]string)
+	cfg.OllamaModels = make(map[string]string)
+	if env != "" {
+		os.WriteFile(cfg.EnvFile, []byte(env), 0600)
+	}
+	return cfg
+}
+
+func findOnboardingCheck(checks []OnboardingCheck, name string) OnboardingCheck {
+	for _, c := range checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	return OnboardingCheck{}
+}
+
+func TestOnboardingChecksReady(t *testing.T) {
+	cfg := newOnboardingConfig(t, "ANTHROPIC_API_KEY=sk-test\nNEXUS_DAILY_BUDGET=5\n")
+	cfg.Keys["ANTHROPIC_API_KEY"] = "sk-test"
+	cfg.OllamaModels["sonnet"] = "qwen2.5-coder"
+	probes := onboardingProbes{
+		lookPath: func(string) (string, error) { return "/usr/local/bin/claude", nil },
+		health: func(_ *Config, be Backend) HealthResult {
+			return HealthResult{Backend: be.Name, Status: "ok"}
+		},
+		ollamaModels: func(*Config) ([]string, error) { return []string{"qwen2.5-coder:latest"}, nil },
+	}
+
+	checks := runOnboardingChecks(cfg, probes)
+	for _, c := range checks {
+		if c.Status != onboardingOK {
+			t.Errorf("%s: expected ok, got %s (%s)", c.Name, c.Status, c.Message)
+		}
+	}
+	if score := onboardingScore(checks); score != 100 {
+		t.Errorf("Expected a score of 100, got %d", score)
+	}
+	var out bytes.Buffer
+	renderOnboarding(checks, &out)
+	if !strings.Contains(out.String(), "Readiness: 100/100") || strings.Contains(out.String(), "NEXT STEPS") {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
+
+func TestOnboardingChecksNewInstall(t *testing.T) {
+	cfg := newOnboardingConfig(t, "")
+	probes := onboardingProbes{
+		lookPath: func(string) (string, error) { return "", errors.New("not found") },
+		health: func(*Config, Backend) HealthResult {
+			t.Error("Expected no health checks without keys")
+			return HealthResult{}
+		},
+		ollamaModels: func(*Config) ([]string, error) { return nil, errors.New("connection refused") },
+	}
+
+	checks := runOnboardingChecks(cfg, probes)
+	for name, want := range map[string]string{
+		"Claude Code":  onboardingFail,
+		"Config file":  onboardingFail,
+		"API keys":     onboardingFail,
+		"Budgets":      onboardingWarn,
+		"Local models": onboardingSkip,
+	} {
+		if got := findOnboardingCheck(checks, name).Status; got != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+	// Permissions (15), git hygiene (15) and half of budgets (5) out of 95
+	if score := onboardingScore(checks); score != 34 {
+		t.Errorf("Expected a score of 34, got %d", score)
+	}
+
+	steps := onboardingNextSteps(checks)
+	var names []string
+	for _, s := range steps {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ", "); got != "Claude Code, Config file, API keys, Budgets" {
+		t.Errorf("Expected failures first in checklist order, got %s", got)
+	}
+}
+
+func TestOnboardingKeyValidity(t *testing.T) {
+	cfg := newOnboardingConfig(t, "")
+	cfg.Keys["ANTHROPIC_API_KEY"] = "sk-test"
+	cfg.Keys["DEEPSEEK_API_KEY"] = "sk-test"
+	health := func(_ *Config, be Backend) HealthResult {
+		if be.Name == "deepseek" {
+			return HealthResult{Backend: be.Name, Status: "error", Message: "invalid key"}
+		}
+		return HealthResult{Backend: be.Name, Status: "ok"}
+	}
+	c := checkKeyValidity(cfg, health)
+	if c.Status != onboardingWarn || !strings.Contains(c.Fix, "promptops validate deepseek") {
+		t.Errorf("Expected a warning naming deepseek, got %+v", c)
+	}
+}
+
+func TestOnboardingFilePermissions(t *testing.T) {
+	cfg := newOnboardingConfig(t, "ANTHROPIC_API_KEY=sk-test\n")
+	os.Chmod(cfg.EnvFile, 0644)
+	c := checkFilePermissions(cfg)
+	if c.Status != onboardingFail || !strings.Contains(c.Fix, "chmod 600 "+cfg.EnvFile) {
+		t.Errorf("Expected the readable env file reported, got %+v", c)
+	}
+}
+
+func TestOnboardingLocalModels(t *testing.T) {
+	cfg := newOnboardingConfig(t, "")
+	cfg.OllamaModels["haiku"] = "llama3.2"
+	cfg.OllamaModels["sonnet"] = "qwen2.5-coder:14b"
+	list := func(*Config) ([]string, error) { return []string{"llama3.2:latest"}, nil }
+	c := checkLocalModels(cfg, list)
+	if c.Status != onboardingWarn || !strings.Contains(c.Fix, "ollama pull qwen2.5-coder:14b") {
+		t.Errorf("Expected the missing model reported, got %+v", c)
+	}
+
+	down := func(*Config) ([]string, error) { return nil, errors.New("connection refused") }
+	if c := checkLocalModels(cfg, down); c.Status != onboardingWarn {
+		t.Errorf("Expected a warning when configured models can't be checked, got %+v", c)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+// OutputLevel is how much a launch prints besides warnings and errors. It is
+// independent of YOLO mode, which only controls permission prompts.
+type OutputLevel int
+
+const (
+	// outputFull shows spinners, logos, progress bars and the backend banner
+	outputFull OutputLevel = iota
+	// outputNoAnimation keeps the banner and status lines but skips
+	// spinners, logos and progress bars (NEXUS_NO_ANIMATION, --no-animation)
+	outputNoAnimation
+	// outputQuiet prints only warnings and errors (NEXUS_QUIET, --quiet)
+	outputQuiet
+)
+
+// animate reports whether launches show spinners, logos and progress bars
+func (c *Config) animate() bool {
+	return c.Output == outputFull
+}
+
+// verbose reports whether launches print the banner and status lines
+func (c *Config) verbose() bool {
+	return c.Output < outputQuiet
+}
+
+// raiseOutput lowers how much is printed to at most level. Settings combine
+// to the quietest one, whichever order they come in.
+func (c *Config) raiseOutput(level OutputLevel) {
+	c.Output = max(c.Output, level)
+}
+
+// parseOutputFlags strips --quiet and --no-animation from launch arguments
+// and applies them to cfg
+func parseOutputFlags(cfg *Config, args []string) []string {
+	args, quiet := stripFlag(args, "--quiet")
+	args, noAnimation := stripFlag(args, "--no-animation")
+	if quiet {
+		cfg.raiseOutput(outputQuiet)
+	}
+	if noAnimation {
+		cfg.raiseOutput(outputNoAnimation)
+	}
+	return args
+}
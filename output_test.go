@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseOutputFlags(t *testing.T) {
+	cfg := &Config{}
+	args := parseOutputFlags(cfg, []string{"--no-animation", "--model", "opus"})
+	if cfg.Output != outputNoAnimation || cfg.animate() || !cfg.verbose() {
+		t.Errorf("Expected no animation with status lines, got %v", cfg.Output)
+	}
+	if len(args) != 2 || args[0] != "--model" {
+		t.Errorf("Expected the flag stripped, got %v", args)
+	}
+
+	parseOutputFlags(cfg, []string{"--quiet"})
+	if cfg.verbose() {
+		t.Error("Expected --quiet to silence status lines")
+	}
+
+	// A quieter setting is not undone by a louder one
+	parseOutputFlags(cfg, []string{"--no-animation"})
+	if cfg.Output != outputQuiet {
+		t.Errorf("Expected quiet kept, got %v", cfg.Output)
+	}
+	if fresh := (&Config{}); !fresh.animate() || !fresh.verbose() {
+		t.Error("Expected full output by default")
+	}
+}
+
+func TestOutputIndependentOfYolo(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	envFile := filepath.Join(home, ".env.local")
+	t.Setenv("NEXUS_ENV_FILE", envFile)
+	content := "NEXUS_YOLO_MODE=true\nNEXUS_QUIET=true\nNEXUS_NO_ANIMATION=true\n"
+	if err := os.WriteFile(envFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := loadConfig()
+	if !cfg.getYoloMode("claude") || cfg.Output != outputQuiet {
+		t.Errorf("Expected YOLO and quiet set separately, got yolo=%v output=%v", cfg.getYoloMode("claude"), cfg.Output)
+	}
+
+	os.WriteFile(envFile, []byte("NEXUS_YOLO_MODE=true\n"), 0600)
+	if cfg := loadConfig(); !cfg.animate() {
+		t.Error("Expected YOLO mode to leave animations on")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// preflightBackend checks the backend's health before launch when
+// NEXUS_VERIFY_ON_SWITCH is enabled. If it is unreachable the user can launch
+// anyway, pick another backend, fail over to the first healthy one, or quit.
+// ok is false when the launch should be cancelled.
+func preflightBackend(cfg *Config, be Backend, check func(*Config, Backend) HealthResult, reader *bufio.Reader) (Backend, bool) {
+	if !cfg.VerifyOnSwitch {
+		return be, true
+	}
+	result := check(cfg, be)
+	if result.Status != "error" {
+		return be, true
+	}
+
+	fmt.Println()
+	fmt.Println(styleWarning.Render(fmt.Sprintf("WARNING: %s unreachable - %s", be.DisplayName, truncate(result.Message, 120))))
+	others := preflightAlternatives(cfg, be.Name)
+
+	for {
+		fmt.Print("[l]aunch anyway, [p]ick another, [f]ailover, [Q]uit: ")
+		answer, err := readLine(reader)
+		if err != nil {
+			// No terminal to ask; keep the previous behavior of launching
+			fmt.Println()
+			fmt.Println("INFO: No answer, launching anyway")
+			return be, true
+		}
+
+		switch strings.ToLower(answer) {
+		case "l", "launch":
+			return be, true
+		case "p", "pick":
+			if picked, ok := pickBackend(others, reader); ok {
+				return backends[picked], true
+			}
+		case "f", "failover":
+			if name, ok := failoverBackend(cfg, others, check); ok {
+				fmt.Printf("INFO: Failing over to %s\n", backends[name].DisplayName)
+				return backends[name], true
+			}
+			fmt.Println("No healthy backend available.")
+		case "q", "quit", "":
+			return be, false
+		}
+	}
+}
+
+// preflightAlternatives returns configured backends other than current, in
+// auto preference order
+func preflightAlternatives(cfg *Config, current string) []string {
+	var names []string
+	for _, name := range autoCandidates(cfg) {
+		if name != current {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// pickBackend lists names and reads a choice by number or name
+func pickBackend(names []string, reader *bufio.Reader) (string, bool) {
+	if len(names) == 0 {
+		fmt.Println("No other backends have keys configured.")
+		return "", false
+	}
+	for i, name := range names {
+		fmt.Printf("  %d) %s\n", i+1, backends[name].DisplayName)
+	}
+	fmt.Print("Backend: ")
+	answer, err := readLine(reader)
+	if err != nil {
+		return "", false
+	}
+	answer = strings.ToLower(answer)
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(names) {
+		return names[n-1], true
+	}
+	for _, name := range names {
+		if name == answer {
+			return name, true
+		}
+	}
+	fmt.Printf("Unknown choice '%s'\n", answer)
+	return "", false
+}
+
+// failoverBackend returns the first healthy backend in names
+func failoverBackend(cfg *Config, names []string, check func(*Config, Backend) HealthResult) (string, bool) {
+	for _, name := range names {
+		result := check(cfg, backends[name])
+		if result.Status == "ok" {
+			return name, true
+		}
+		fmt.Printf("INFO: Skipping %s (%s)\n", name, truncate(result.Message, 60))
+	}
+	return "", false
+}
+
+// verifyBeforeLaunch runs the preflight check and records a changed backend
+func verifyBeforeLaunch(cfg *Config, be Backend) Backend {
+	chosen, ok := preflightBackend(cfg, be, checkBackendHealth, bufio.NewReader(os.Stdin))
+	if !ok {
+		auditLog(cfg, fmt.Sprintf("LAUNCH_CANCELLED: %s (unreachable)", be.Name))
+		fmt.Println("Launch cancelled.")
+		os.Exit(1)
+	}
+	if chosen.Name != be.Name {
+		if err := setCurrentBackend(cfg, chosen.Name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save state: %v\n", err)
+		}
+		auditLog(cfg, fmt.Sprintf("FAILOVER: %s -> %s (unreachable)", be.Name, chosen.Name))
+	}
+	return chosen
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func preflightConfig() *Config {
+	return &Config{
+		VerifyOnSwitch: true,
+		AutoPreference: []string{"claude", "openai", "deepseek", "mistral"},
+		Keys: map[string]string{
+			"ANTHROPIC_API_KEY": "k",
+			"OPENAI_API_KEY":    "k",
+			"DEEPSEEK_API_KEY":  "k",
+		},
+	}
+}
+
+// healthyExcept reports every backend healthy except the named ones
+func healthyExcept(down ...string) func(*Config, Backend) HealthResult {
+	return func(cfg *Config, be Backend) HealthResult {
+		for _, name := range down {
+			if be.Name == name {
+				return HealthResult{Backend: be.Name, Status: "error", Message: "connection refused"}
+			}
+		}
+		return HealthResult{Backend: be.Name, Status: "ok"}
+	}
+}
+
+func TestPreflightBackend(t *testing.T) {
+	claude := backends["claude"]
+	tests := []struct {
+		name   string
+		check  func(*Config, Backend) HealthResult
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{"healthy", healthyExcept(), "", "claude", true},
+		{"launch anyway", healthyExcept("claude"), "l\n", "claude", true},
+		{"quit", healthyExcept("claude"), "q\n", "claude", false},
+		{"default quits", healthyExcept("claude"), "\n", "claude", false},
+		{"no terminal launches", healthyExcept("claude"), "", "claude", true},
+		{"failover skips unhealthy", healthyExcept("claude", "openai"), "f\n", "deepseek", true},
+		{"pick by number", healthyExcept("claude"), "p\n2\n", "deepseek", true},
+		{"pick by name", healthyExcept("claude"), "p\nopenai\n", "openai", true},
+		{"failed failover reprompts", healthyExcept("claude", "openai", "deepseek"), "f\nl\n", "claude", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.input))
+			got, ok := preflightBackend(preflightConfig(), claude, tt.check, reader)
+			if got.Name != tt.want || ok != tt.wantOK {
+				t.Errorf("Expected %s (ok=%t), got %s (ok=%t)", tt.want, tt.wantOK, got.Name, ok)
+			}
+		})
+	}
+}
+
+func TestPreflightDisabled(t *testing.T) {
+	cfg := preflightConfig()
+	cfg.VerifyOnSwitch = false
+	called := false
+	check := func(*Config, Backend) HealthResult {
+		called = true
+		return HealthResult{Status: "error"}
+	}
+	if _, ok := preflightBackend(cfg, backends["claude"], check, bufio.NewReader(strings.NewReader(""))); !ok || called {
+		t.Errorf("Expected no health check when disabled (ok=%t, called=%t)", ok, called)
+	}
+}
+
+func TestPreflightAlternatives(t *testing.T) {
+	got := preflightAlternatives(preflightConfig(), "openai")
+	if strings.Join(got, ",") != "claude,deepseek" {
+		t.Errorf("Expected configured alternatives claude,deepseek, got %v", got)
+	}
+}
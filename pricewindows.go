@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PriceWindow is a daily UTC time window with its own per-1M token prices,
+// such as an off-peak discount. A window may wrap past midnight.
+type PriceWindow struct {
+	Start       int // minutes after midnight UTC
+	End         int
+	InputPrice  float64
+	OutputPrice float64
+}
+
+// Contains reports whether t falls within the window
+func (w PriceWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	m := t.Hour()*60 + t.Minute()
+	if w.Start <= w.End {
+		return m >= w.Start && m < w.End
+	}
+	return m >= w.Start || m < w.End
+}
+
+// NextStart returns the next time at or after t that the window opens
+func (w PriceWindow) NextStart(t time.Time) time.Time {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, w.Start, 0, 0, time.UTC)
+	if start.Before(t) {
+		start = start.AddDate(0, 0, 1)
+	}
+	return start
+}
+
+// String renders the window as "16:30-00:30 UTC"
+func (w PriceWindow) String() string {
+	return fmt.Sprintf("%s-%s UTC", formatClock(w.Start), formatClock(w.End))
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s': use HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parsePriceWindows parses "HH:MM-HH:MM=input/output,..." with times in UTC
+// and prices per 1M tokens. An empty value disables windowed pricing.
+func parsePriceWindows(value string) ([]PriceWindow, error) {
+	windows := []PriceWindow{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		span, prices, ok := strings.Cut(part, "=")
+		from, to, ok2 := strings.Cut(span, "-")
+		in, out, ok3 := strings.Cut(prices, "/")
+		if !ok || !ok2 || !ok3 {
+			return nil, fmt.Errorf("invalid window '%s': expected HH:MM-HH:MM=input/output", part)
+		}
+		var w PriceWindow
+		var err error
+		if w.Start, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.End, err = parseClock(to); err != nil {
+			return nil, err
+		}
+		if w.Start == w.End {
+			return nil, fmt.Errorf("invalid window '%s': start and end are the same", part)
+		}
+		if w.InputPrice, err = strconv.ParseFloat(strings.TrimSpace(in), 64); err != nil || w.InputPrice < 0 {
+			return nil, fmt.Errorf("invalid input price '%s'", in)
+		}
+		if w.OutputPrice, err = strconv.ParseFloat(strings.TrimSpace(out), 64); err != nil || w.OutputPrice < 0 {
+			return nil, fmt.Errorf("invalid output price '%s'", out)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// priceWindows returns the backend's configured windows. None are built in:
+// provider discounts change without notice, and a stale window would
+// under-report spend.
+func priceWindows(cfg *Config, backend string) []PriceWindow {
+	return cfg.PriceWindows[backend]
+}
+
+// activePriceWindow returns the window in effect for backend at t, if any
+func activePriceWindow(cfg *Config, backend string, t time.Time) (PriceWindow, bool) {
+	for _, w := range priceWindows(cfg, backend) {
+		if w.Contains(t) {
+			return w, true
+		}
+	}
+	return PriceWindow{}, false
+}
+
+// pricingAt returns the backend's input/output price per 1M tokens at t
+func pricingAt(cfg *Config, be Backend, t time.Time) (inputPrice, outputPrice float64) {
+	if w, ok := activePriceWindow(cfg, be.Name, t); ok {
+		return w.InputPrice, w.OutputPrice
+	}
+	return be.InputPrice, be.OutputPrice
+}
+
+// offPeakHint describes the backend's windowed pricing at now, or "" when it
+// has none
+func offPeakHint(cfg *Config, be Backend, now time.Time) string {
+	if w, ok := activePriceWindow(cfg, be.Name, now); ok {
+		return fmt.Sprintf("Off-peak pricing active until %s UTC ($%.3f / $%.3f per 1M)",
+			formatClock(w.End), w.InputPrice, w.OutputPrice)
+	}
+	var next time.Time
+	for _, w := range priceWindows(cfg, be.Name) {
+		if start := w.NextStart(now); next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	if next.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("Off-peak pricing from %s UTC (in %s)", next.Format("15:04"), formatElapsed(next.Sub(now)))
+}
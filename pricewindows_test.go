@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePriceWindows(t *testing.T) {
+	windows, err := parsePriceWindows("16:30-00:30=0.135/0.55, 02:00-06:00=0.1/0.2")
+	if err != nil || len(windows) != 2 {
+		t.Fatalf("Unexpected result %+v (err=%v)", windows, err)
+	}
+	if w := windows[0]; w.Start != 990 || w.End != 30 || w.InputPrice != 0.135 || w.OutputPrice != 0.55 {
+		t.Errorf("Unexpected window %+v", w)
+	}
+	if windows[0].String() != "16:30-00:30 UTC" {
+		t.Errorf("Unexpected label %q", windows[0].String())
+	}
+	if windows, err := parsePriceWindows(""); err != nil || windows == nil || len(windows) != 0 {
+		t.Errorf("Expected empty value to disable windows, got %v (err=%v)", windows, err)
+	}
+	for _, bad := range []string{"16:30=0.1/0.2", "16:30-00:30", "25:00-01:00=0.1/0.2", "01:00-01:00=0.1/0.2", "01:00-02:00=x/0.2", "01:00-02:00=0.1/-1"} {
+		if _, err := parsePriceWindows(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestPricingAt(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 10, 16, h, m, 0, 0, time.UTC) }
+	cfg := &Config{}
+	be := backends["deepseek"]
+
+	// No windows are built in
+	if in, _ := pricingAt(cfg, be, at(20, 0)); in != be.InputPrice {
+		t.Errorf("Expected base price without configured windows, got %.3f", in)
+	}
+
+	cfg.PriceWindows = map[string][]PriceWindow{"deepseek": {{Start: 16*60 + 30, End: 30, InputPrice: 0.135, OutputPrice: 0.55}}}
+	for _, tc := range []struct {
+		t       time.Time
+		offPeak bool
+	}{
+		{at(12, 0), false},
+		{at(16, 29), false},
+		{at(16, 30), true},
+		{at(23, 59), true},
+		{at(0, 29), true},
+		{at(0, 30), false},
+	} {
+		in, out := pricingAt(cfg, be, tc.t)
+		if offPeak := in == 0.135 && out == 0.55; offPeak != tc.offPeak {
+			t.Errorf("At %s: got %.3f/%.3f, expected off-peak=%v", tc.t.Format("15:04"), in, out, tc.offPeak)
+		}
+	}
+
+	if in, _ := pricingAt(cfg, backends["claude"], at(20, 0)); in != backends["claude"].InputPrice {
+		t.Errorf("Expected base price for claude, got %.3f", in)
+	}
+}
+
+func TestOffPeakHint(t *testing.T) {
+	cfg := &Config{PriceWindows: map[string][]PriceWindow{"deepseek": {{Start: 16*60 + 30, End: 30, InputPrice: 0.135, OutputPrice: 0.55}}}}
+	be := backends["deepseek"]
+	if hint := offPeakHint(cfg, be, time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)); hint != "Off-peak pricing active until 00:30 UTC ($0.135 / $0.550 per 1M)" {
+		t.Errorf("Unexpected active hint %q", hint)
+	}
+	if hint := offPeakHint(cfg, be, time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC)); hint != "Off-peak pricing from 16:30 UTC (in 1h30m)" {
+		t.Errorf("Unexpected upcoming hint %q", hint)
+	}
+	if hint := offPeakHint(cfg, backends["claude"], time.Now()); hint != "" {
+		t.Errorf("Expected no hint for claude, got %q", hint)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// Prompt index defaults
+const (
+	defaultEmbedURL        = "http://localhost:11434"
+	defaultEmbedModel      = "nomic-embed-text"
+	defaultDedupeThreshold = 0.95
+	promptPreviewLength    = 80
+	embedTimeout           = 10 * time.Second
+)
+
+// PromptIndexEntry is a single past prompt in the local embedding index
+type PromptIndexEntry struct {
+	ID         string    `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	SessionID  string    `json:"session_id,omitempty"`
+	Backend    string    `json:"backend"`
+	Model      string    `json:"model"`
+	Preview    string    `json:"preview"`
+	PromptFile string    `json:"prompt_file,omitempty"`
+	AnswerFile string    `json:"answer_file,omitempty"`
+	CostUSD    float64   `json:"cost_usd"`
+	Embedding  []float64 `json:"embedding"`
+}
+
+// PromptIndex is an optional local embedding index of past prompts used to
+// warn before a nearly identical prompt is sent again. Embeddings are computed
+// by a local Ollama server so prompts never leave the machine.
+type PromptIndex struct {
+	path      string
+	answerDir string
+	embedURL  string
+	model     string
+	threshold float64
+	client    *http.Client
+}
+
+// NewPromptIndex creates a prompt index from config
+func NewPromptIndex(cfg *Config) *PromptIndex {
+	return &PromptIndex{
+		path:      cfg.PromptIndexFile,
+		answerDir: cfg.PromptAnswerDir,
+		embedURL:  strings.TrimSuffix(cfg.EmbedURL, "/"),
+		model:     cfg.EmbedModel,
+		threshold: cfg.DedupeThreshold,
+		client:    &http.Client{Timeout: embedTimeout},
+	}
+}
+
+// Embed returns the embedding vector for text using Ollama's embeddings API
+func (idx *PromptIndex) Embed(text string) ([]float64, error) {
+	body, err := json.Marshal(map[string]string{"model": idx.model, "prompt": text})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), embedTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", idx.embedURL+"/api/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := idx.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding request failed: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode embedding: %w", err)
+	}
+	if len(result.Embedding) == 0 {
+		return nil, errors.New("empty embedding returned")
+	}
+	return result.Embedding, nil
+}
+
+// Load reads all entries from the index file, skipping malformed lines
+func (idx *PromptIndex) Load() []PromptIndexEntry {
+	f, err := os.Open(idx.path)
+	if err != nil {
+		return []PromptIndexEntry{}
+	}
+	defer f.Close()
+
+	var entries []PromptIndexEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), maxResponseSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry PromptIndexEntry
+		if err := json.Unmarshal([]byte(line), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Search returns the most similar past prompt at or above the dedupe
+// threshold, or nil if none qualifies
+func (idx *PromptIndex) Search(embedding []float64) (*PromptIndexEntry, float64) {
+	var best *PromptIndexEntry
+	bestScore := 0.0
+	entries := idx.Load()
+	for i := range entries {
+		score := cosineSimilarity(embedding, entries[i].Embedding)
+		if score >= idx.threshold && score > bestScore {
+			best = &entries[i]
+			bestScore = score
+		}
+	}
+	return best, bestScore
+}
+
+// Add stores a prompt with its answer. The full prompt and answer texts are
+// written to their own files so the index stays small and the location can
+// be shown to the user.
+func (idx *PromptIndex) Add(entry PromptIndexEntry, prompt, answer string) error {
+	if entry.ID == "" {
+		sum := sha256.Sum256([]byte(entry.Preview + entry.Timestamp.String()))
+		entry.ID = hex.EncodeToString(sum[:8])
+	}
+
+	if (prompt != "" || answer != "") && idx.answerDir != "" {
+		if err := os.MkdirAll(idx.answerDir, 0700); err != nil {
+			return fmt.Errorf("create answer dir: %w", err)
+		}
+	}
+	if prompt != "" && idx.answerDir != "" {
+		entry.PromptFile = filepath.Join(idx.answerDir, entry.ID+".prompt.md")
+		if err := writeFileAtomic(entry.PromptFile, []byte(prompt), 0600); err != nil {
+			return fmt.Errorf("write prompt: %w", err)
+		}
+	}
+	if answer != "" && idx.answerDir != "" {
+		entry.AnswerFile = filepath.Join(idx.answerDir, entry.ID+".md")
+		if err := writeFileAtomic(entry.AnswerFile, []byte(answer), 0600); err != nil {
+			return fmt.Errorf("write answer: %w", err)
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(idx.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open prompt index: %w", err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, string(data)); err != nil {
+		return fmt.Errorf("write prompt index: %w", err)
+	}
+	return nil
+}
+
+// Clear removes the index file and all stored answers
+func (idx *PromptIndex) Clear() error {
+	if err := os.Remove(idx.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if idx.answerDir != "" {
+		if err := os.RemoveAll(idx.answerDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors, or 0 when
+// they differ in length or either is zero
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// promptPreview collapses whitespace and truncates a prompt for display
+func promptPreview(text string) string {
+	return truncate(strings.Join(strings.Fields(text), " "), promptPreviewLength)
+}
+
+// lastUserPrompt returns the text of the final user message in a request
+func lastUserPrompt(req AnthropicRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return req.Messages[i].GetContentText()
+		}
+	}
+	return ""
+}
+
+// formatDedupeHint describes a previous matching prompt for the terminal
+func formatDedupeHint(match *PromptIndexEntry, score float64) string {
+	location := match.AnswerFile
+	if location == "" {
+		location = "answer not stored"
+	}
+	return fmt.Sprintf("[promptops] similar prompt (%.0f%% match) sent %s on %s, cost %s: %s",
+		score*100, match.Timestamp.Format("2006-01-02 15:04"), match.Backend,
+		formatCurrency(match.CostUSD), location)
+}
+
+// handlePromptsCommand dispatches prompt index subcommands
+func handlePromptsCommand(args []string) {
+	cfg := loadConfig()
+	idx := NewPromptIndex(cfg)
+
+	if len(args) == 0 {
+		listPromptIndex(idx)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		listPromptIndex(idx)
+	case "check":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: promptops prompts check <prompt text>")
+			os.Exit(1)
+		}
+		checkPromptIndex(idx, strings.Join(args[1:], " "))
+	case "clear":
+		if err := idx.Clear(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to clear prompt index: %v\n", err)
+			os.Exit(1)
+		}
+		auditLog(cfg, "PROMPT_INDEX_CLEAR")
+		fmt.Println("[OK] Cleared prompt index")
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown prompts command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func listPromptIndex(idx *PromptIndex) {
+	entries := idx.Load()
+	if len(entries) == 0 {
+		fmt.Println("No indexed prompts. Set NEXUS_PROMPT_INDEX=true to start indexing.")
+		return
+	}
+
+	start := 0
+	if len(entries) > 20 {
+		start = len(entries) - 20
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("INDEXED PROMPTS"))
+
+	rows := [][]string{}
+	for i := len(entries) - 1; i >= start; i-- {
+		e := entries[i]
+		rows = append(rows, []string{
+			e.Timestamp.Format("2006-01-02 15:04"),
+			e.Backend,
+			truncate(e.Preview, 40),
+			formatCurrency(e.CostUSD),
+		})
+	}
+
+	t := table.New().
+		Headers("Timestamp", "Backend", "Prompt", "Cost").
+		Rows(rows...).
+		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		}).
+		Width(90)
+
+	fmt.Println(t.Render())
+	fmt.Println()
+}
+
+func checkPromptIndex(idx *PromptIndex, prompt string) {
+	embedding, err := idx.Embed(prompt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", sanitizeError(err))
+		os.Exit(1)
+	}
+
+	match, score := idx.Search(embedding)
+	if match == nil {
+		fmt.Println("No similar prompt found.")
+		return
+	}
+
+	fmt.Println(styleWarning.Render(formatDedupeHint(match, score)))
+	fmt.Printf("  Prompt: %s\n", match.Preview)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestPromptIndex(t *testing.T, embedURL string) *PromptIndex {
+	tmpDir := t.TempDir()
+	return NewPromptIndex(&Config{
+		PromptIndexFile: filepath.Join(tmpDir, "prompts.jsonl"),
+		PromptAnswerDir: filepath.Join(tmpDir, "answers"),
+		EmbedURL:        embedURL,
+		EmbedModel:      defaultEmbedModel,
+		DedupeThreshold: defaultDedupeThreshold,
+	})
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []float64
+		expected float64
+	}{
+		{"identical", []float64{1, 2, 3}, []float64{1, 2, 3}, 1},
+		{"orthogonal", []float64{1, 0}, []float64{0, 1}, 0},
+		{"opposite", []float64{1, 0}, []float64{-1, 0}, -1},
+		{"length_mismatch", []float64{1, 0}, []float64{1, 0, 0}, 0},
+		{"zero_vector", []float64{0, 0}, []float64{1, 0}, 0},
+		{"empty", nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cosineSimilarity(tt.a, tt.b)
+			if math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("cosineSimilarity = %f, want %f", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPromptIndexAddSearch(t *testing.T) {
+	idx := newTestPromptIndex(t, defaultEmbedURL)
+
+	entry := PromptIndexEntry{
+		Timestamp: time.Now(),
+		Backend:   "ollama",
+		Model:     "llama3.2:latest",
+		Preview:   "explain the proxy",
+		CostUSD:   0.25,
+		Embedding: []float64{1, 0, 0},
+	}
+	if err := idx.Add(entry, "explain the proxy", "The proxy translates requests."); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	entries := idx.Load()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].ID == "" {
+		t.Error("Expected generated ID")
+	}
+
+	// Answer is stored with restricted permissions
+	info, err := os.Stat(entries[0].AnswerFile)
+	if err != nil {
+		t.Fatalf("Answer file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected answer permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	match, score := idx.Search([]float64{0.99, 0.01, 0})
+	if match == nil {
+		t.Fatal("Expected a match for a near-identical embedding")
+	}
+	if match.CostUSD != 0.25 || score < defaultDedupeThreshold {
+		t.Errorf("Unexpected match: cost %.2f score %.3f", match.CostUSD, score)
+	}
+
+	if match, _ := idx.Search([]float64{0, 1, 0}); match != nil {
+		t.Error("Expected no match for an unrelated embedding")
+	}
+
+	if err := idx.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if len(idx.Load()) != 0 {
+		t.Error("Expected empty index after Clear")
+	}
+}
+
+func TestPromptIndexEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embeddings" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["model"] != defaultEmbedModel {
+			t.Errorf("Expected model %q, got %q", defaultEmbedModel, req["model"])
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"embedding": []float64{0.1, 0.2}})
+	}))
+	defer server.Close()
+
+	idx := newTestPromptIndex(t, server.URL)
+	embedding, err := idx.Embed("hello")
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(embedding) != 2 {
+		t.Errorf("Expected 2 dimensions, got %d", len(embedding))
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer failing.Close()
+
+	if _, err := newTestPromptIndex(t, failing.URL).Embed("hello"); err == nil {
+		t.Error("Expected error for HTTP 404")
+	}
+}
+
+func TestLastUserPrompt(t *testing.T) {
+	req := AnthropicRequest{
+		Messages: []AnthropicMessage{
+			{Role: "user", Content: "first"},
+			{Role: "assistant", Content: "reply"},
+			{Role: "user", Content: "second"},
+		},
+	}
+	if got := lastUserPrompt(req); got != "second" {
+		t.Errorf("lastUserPrompt = %q, want %q", got, "second")
+	}
+	if got := lastUserPrompt(AnthropicRequest{}); got != "" {
+		t.Errorf("lastUserPrompt(empty) = %q, want empty", got)
+	}
+}
+
+func TestProxyIndexesPrompts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/embeddings":
+			json.NewEncoder(w).Encode(map[string]interface{}{"embedding": []float64{1, 0}})
+		case "/chat/completions":
+			json.NewEncoder(w).Encode(OpenAIResponse{
+				Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: "answer"}, FinishReason: "stop"}},
+				Usage:   OpenAIUsage{PromptTokens: 10, CompletionTokens: 5},
+			})
+		}
+	}))
+	defer server.Close()
+
+	idx := newTestPromptIndex(t, server.URL)
+	proxy := NewOllamaProxy(server.URL, nil)
+	proxy.SetPromptIndex(idx, "ollama", "s-1")
+
+	body, _ := json.Marshal(AnthropicRequest{
+		Model:    "llama3.2",
+		Messages: []AnthropicMessage{{Role: "user", Content: "What does the proxy do?"}},
+	})
+	req := httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	// Indexing finishes in the background; Stop waits for it
+	proxy.Stop()
+	entries := idx.Load()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 indexed prompt, got %d", len(entries))
+	}
+	if entries[0].Preview != "What does the proxy do?" || entries[0].Backend != "ollama" || entries[0].SessionID != "s-1" {
+		t.Errorf("Unexpected entry: %+v", entries[0])
+	}
+}
+
+func TestProxySlowEmbeddingDoesNotDelayRequest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/embeddings":
+			<-release
+			json.NewEncoder(w).Encode(map[string]interface{}{"embedding": []float64{1, 0}})
+		case "/chat/completions":
+			json.NewEncoder(w).Encode(OpenAIResponse{
+				Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: "answer"}, FinishReason: "stop"}},
+			})
+		}
+	}))
+	defer server.Close()
+
+	idx := newTestPromptIndex(t, server.URL)
+	proxy := NewOllamaProxy(server.URL, nil)
+	proxy.SetPromptIndex(idx, "ollama", "")
+
+	body, _ := json.Marshal(AnthropicRequest{
+		Model:    "llama3.2",
+		Messages: []AnthropicMessage{{Role: "user", Content: "Slow to embed"}},
+	})
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the request answered while the embedding is pending, got %d", w.Code)
+	}
+
+	// The prompt is indexed once the embedding arrives
+	close(release)
+	proxy.Stop()
+	if entries := idx.Load(); len(entries) != 1 {
+		t.Errorf("Expected the prompt indexed after the embedding, got %d entries", len(entries))
+	}
+}
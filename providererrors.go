@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider error kinds
+const (
+	errKindInvalidKey   = "authentication_error"
+	errKindPermission   = "permission_error"
+	errKindBalance      = "insufficient_balance"
+	errKindRateLimited  = "rate_limit_error"
+	errKindOverloaded   = "overloaded_error"
+	errKindServerError  = "api_error"
+	errKindInvalidInput = "invalid_request_error"
+)
+
+// billingURLs lists where to top up credit for each provider
+var billingURLs = map[string]string{
+	"claude":     "https://console.anthropic.com/settings/billing",
+	"openai":     "https://platform.openai.com/settings/organization/billing",
+	"deepseek":   "https://platform.deepseek.com/top_up",
+	"mistral":    "https://console.mistral.ai/billing",
+	"grok":       "https://console.x.ai",
+	"groq":       "https://console.groq.com/settings/billing",
+	"together":   "https://api.together.ai/settings/billing",
+	"openrouter": "https://openrouter.ai/settings/credits",
+}
+
+// balanceMarkers are body fragments providers use for exhausted credit,
+// sometimes sent with 400/403/429 instead of 402
+var balanceMarkers = []string{"insufficient balance", "insufficient_balance", "insufficient_quota", "credit balance", "exceeded your current quota", "out of credits"}
+
+// authMarkers are body fragments that mark a 403 as a key failure rather
+// than a permission or region restriction
+var authMarkers = []string{"invalid api key", "invalid_api_key", "incorrect api key", "invalid x-api-key", "authentication", "unauthorized"}
+
+// maxUpstreamMessage caps how much of a provider's error message is kept
+const maxUpstreamMessage = 200
+
+// ProviderError is an upstream HTTP error translated into an actionable message
+type ProviderError struct {
+	Backend    string
+	StatusCode int
+	Kind       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *ProviderError) Error() string {
+	return e.Message
+}
+
+// classifyProviderError maps an upstream error response to a user-friendly
+// message. The raw body is never echoed back, since providers sometimes
+// include request details; for permission and generic 4xx errors the
+// provider's own message is kept after secrets are redacted.
+func classifyProviderError(be Backend, statusCode int, header http.Header, body []byte) *ProviderError {
+	pe := &ProviderError{Backend: be.Name, StatusCode: statusCode}
+	lower := strings.ToLower(string(body))
+
+	isBalance := statusCode == http.StatusPaymentRequired
+	for _, marker := range balanceMarkers {
+		if strings.Contains(lower, marker) {
+			isBalance = true
+			break
+		}
+	}
+
+	switch {
+	case isBalance:
+		pe.Kind = errKindBalance
+		where := "top up your account"
+		if url, ok := billingURLs[be.Name]; ok {
+			where = "top up at " + url
+		}
+		pe.Message = fmt.Sprintf("%s balance exhausted - %s; or switch backends (promptops doctor lists healthy ones)", be.DisplayName, where)
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden && containsAny(lower, authMarkers):
+		pe.Kind = errKindInvalidKey
+		pe.Message = fmt.Sprintf("%s rejected the API key (HTTP %d) - it may be expired or revoked; check %s in .env.local", be.DisplayName, statusCode, be.AuthVar)
+	case statusCode == http.StatusForbidden:
+		pe.Kind = errKindPermission
+		pe.Message = fmt.Sprintf("%s denied access (HTTP 403) - the key is accepted but lacks access to this model, region or feature", be.DisplayName)
+		if msg := upstreamMessage(body); msg != "" {
+			pe.Message += ": " + msg
+		}
+	case statusCode == http.StatusTooManyRequests:
+		pe.Kind = errKindRateLimited
+		pe.RetryAfter = parseRateLimitReset(header, time.Now())
+		if pe.RetryAfter > 0 {
+			pe.Message = fmt.Sprintf("%s rate limit reached - resets in %s", be.DisplayName, formatDuration(pe.RetryAfter))
+		} else {
+			pe.Message = fmt.Sprintf("%s rate limit reached - wait a moment and retry", be.DisplayName)
+		}
+	case statusCode == 529 || statusCode == http.StatusServiceUnavailable:
+		pe.Kind = errKindOverloaded
+		pe.Message = fmt.Sprintf("%s is overloaded (HTTP %d) - retry shortly or switch backends", be.DisplayName, statusCode)
+	case statusCode >= 500:
+		pe.Kind = errKindServerError
+		pe.Message = fmt.Sprintf("%s server error (HTTP %d) - the provider may be having an outage", be.DisplayName, statusCode)
+	case statusCode == http.StatusNotFound:
+		pe.Kind = errKindInvalidInput
+		pe.Message = fmt.Sprintf("%s endpoint or model not found (HTTP 404) - check the model name and base URL", be.DisplayName)
+	default:
+		pe.Kind = errKindInvalidInput
+		pe.Message = fmt.Sprintf("%s request failed (HTTP %d)", be.DisplayName, statusCode)
+		if msg := upstreamMessage(body); msg != "" {
+			pe.Message += ": " + msg
+		}
+	}
+	return pe
+}
+
+// containsAny reports whether s contains any of the fragments
+func containsAny(s string, fragments []string) bool {
+	for _, f := range fragments {
+		if strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamMessage extracts the message from a provider error body in the
+// Anthropic, OpenAI or plain {"message"} shapes, with key-like tokens
+// redacted, whitespace collapsed and length capped. Non-JSON bodies give "".
+func upstreamMessage(body []byte) string {
+	var parsed struct {
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) != nil {
+		return ""
+	}
+	msg := parsed.Message
+	if len(parsed.Error) > 0 {
+		var nested struct {
+			Message string `json:"message"`
+		}
+		var plain string
+		if json.Unmarshal(parsed.Error, &nested) == nil && nested.Message != "" {
+			msg = nested.Message
+		} else if json.Unmarshal(parsed.Error, &plain) == nil && plain != "" {
+			msg = plain
+		}
+	}
+	msg = strings.Join(strings.Fields(sanitizeError(errors.New(msg)).Error()), " ")
+	if len(msg) > maxUpstreamMessage {
+		msg = msg[:maxUpstreamMessage] + "..."
+	}
+	return msg
+}
+
+// parseRateLimitReset returns how long until a rate limit resets, using
+// Retry-After or the provider-specific reset headers. Zero means unknown.
+func parseRateLimitReset(header http.Header, now time.Time) time.Duration {
+	if v := header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+	// Anthropic sends RFC 3339 timestamps
+	for _, name := range []string{"anthropic-ratelimit-requests-reset", "anthropic-ratelimit-tokens-reset"} {
+		if v := header.Get(name); v != "" {
+			if t, err := time.Parse(time.RFC3339, v); err == nil && t.After(now) {
+				return t.Sub(now)
+			}
+		}
+	}
+	// OpenAI-compatible providers send durations such as "1s" or "6m0s"
+	for _, name := range []string{"x-ratelimit-reset-requests", "x-ratelimit-reset-tokens"} {
+		if v := header.Get(name); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				return d
+			}
+		}
+	}
+	return 0
+}
+
+// writeAnthropicError writes a classified error in Anthropic API format so
+// Claude Code displays the hint instead of a raw upstream body
+func writeAnthropicError(w http.ResponseWriter, pe *ProviderError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(pe.StatusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type": "error",
+		"error": map[string]string{
+			"type":    pe.Kind,
+			"message": pe.Message,
+		},
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyProviderError(t *testing.T) {
+	tests := []struct {
+		name     string
+		backend  string
+		status   int
+		body     string
+		kind     string
+		contains string
+	}{
+		{"invalid_key", "claude", 401, `{"error":"invalid x-api-key"}`, errKindInvalidKey, "ANTHROPIC_API_KEY"},
+		{"forbidden_key", "openai", 403, `{"error":{"message":"Incorrect API key provided"}}`, errKindInvalidKey, "OPENAI_API_KEY"},
+		{"forbidden_region", "openai", 403, `{"error":{"code":"unsupported_country_region_territory","message":"Country, region, or territory not supported"}}`, errKindPermission, "region, or territory not supported"},
+		{"forbidden_model", "claude", 403, ``, errKindPermission, "lacks access"},
+		{"payment_required", "deepseek", 402, `{"error":{"message":"Insufficient Balance"}}`, errKindBalance, "platform.deepseek.com/top_up"},
+		{"quota_in_429", "openai", 429, `{"error":{"code":"insufficient_quota"}}`, errKindBalance, "balance exhausted"},
+		{"balance_no_url", "kimi", 402, ``, errKindBalance, "top up your account"},
+		{"rate_limited", "groq", 429, `{}`, errKindRateLimited, "rate limit"},
+		{"overloaded", "claude", 529, `{"type":"error","error":{"type":"overloaded_error"}}`, errKindOverloaded, "overloaded"},
+		{"unavailable", "mistral", 503, ``, errKindOverloaded, "HTTP 503"},
+		{"server_error", "gemini", 500, ``, errKindServerError, "outage"},
+		{"not_found", "ollama", 404, ``, errKindInvalidInput, "model not found"},
+		{"bad_request", "zai", 400, ``, errKindInvalidInput, "HTTP 400"},
+		{"bad_request_message", "mistral", 400, `{"message":"max_tokens must be at most 8192"}`, errKindInvalidInput, "HTTP 400): max_tokens must be at most 8192"},
+		{"bad_request_secret", "openai", 400, `{"error":"bad header Bearer sk-proj-abcdefghijklmnop"}`, errKindInvalidInput, "[REDACTED]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pe := classifyProviderError(backends[tt.backend], tt.status, http.Header{}, []byte(tt.body))
+			if pe.Kind != tt.kind {
+				t.Errorf("Kind = %q, want %q", pe.Kind, tt.kind)
+			}
+			if !strings.Contains(pe.Message, tt.contains) {
+				t.Errorf("Message %q does not contain %q", pe.Message, tt.contains)
+			}
+			if tt.body != "" && strings.Contains(pe.Message, tt.body) {
+				t.Error("Expected raw body not to be echoed")
+			}
+			if strings.Contains(pe.Message, "sk-proj-") {
+				t.Error("Expected keys in the upstream message redacted")
+			}
+		})
+	}
+}
+
+func TestParseRateLimitReset(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		header   map[string]string
+		expected time.Duration
+	}{
+		{"retry_after_seconds", map[string]string{"Retry-After": "30"}, 30 * time.Second},
+		{"retry_after_date", map[string]string{"Retry-After": now.Add(2 * time.Minute).Format(http.TimeFormat)}, 2 * time.Minute},
+		{"anthropic_reset", map[string]string{"anthropic-ratelimit-requests-reset": now.Add(45 * time.Second).Format(time.RFC3339)}, 45 * time.Second},
+		{"openai_reset", map[string]string{"x-ratelimit-reset-requests": "6m0s"}, 6 * time.Minute},
+		{"past_reset", map[string]string{"anthropic-ratelimit-requests-reset": now.Add(-time.Minute).Format(time.RFC3339)}, 0},
+		{"none", map[string]string{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+			if got := parseRateLimitReset(h, now); got != tt.expected {
+				t.Errorf("parseRateLimitReset = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProxyClassifiesUpstreamErrors(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "20")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"slow down"}`))
+	}))
+	defer upstream.Close()
+
+	proxy := NewOllamaProxy(upstream.URL, nil)
+	for _, stream := range []bool{false, true} {
+		body, _ := json.Marshal(AnthropicRequest{
+			Model:    "llama3.2",
+			Stream:   stream,
+			Messages: []AnthropicMessage{{Role: "user", Content: "hi"}},
+		})
+		w := httptest.NewRecorder()
+		proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", strings.NewReader(string(body))))
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("stream=%v: expected status 429, got %d", stream, w.Code)
+		}
+		var resp struct {
+			Type  string `json:"type"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("stream=%v: expected JSON error body: %v", stream, err)
+		}
+		if resp.Error.Type != errKindRateLimited || !strings.Contains(resp.Error.Message, "resets in") {
+			t.Errorf("stream=%v: unexpected error: %+v", stream, resp.Error)
+		}
+	}
+}
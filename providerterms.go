@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// Whether a provider may use API inputs to train models
+const (
+	trainingNo      = "no"
+	trainingYes     = "yes"
+	trainingUnknown = "unknown"
+)
+
+// regionLocal is the residency of backends running on this machine, which
+// every data region policy allows
+const regionLocal = "local"
+
+// dataRegions are the region codes NEXUS_DATA_REGIONS accepts
+var dataRegions = []string{"CN", "EU", "SG", "US"}
+
+// ProviderTerms summarizes a provider's published data handling terms for
+// API traffic. These are a reminder, not legal advice; check the linked
+// provider policies before relying on them.
+type ProviderTerms struct {
+	Region string // Where requests are processed
+	// Residency is the region code NEXUS_DATA_REGIONS matches: US, EU, CN
+	// or SG, regionLocal for this machine, or empty when it varies
+	Residency string
+	Retention string // How long inputs are kept
+	Training  string // trainingNo, trainingYes or trainingUnknown
+	Notes     string
+}
+
+// providerTerms holds the data handling terms for each backend
+var providerTerms = map[string]ProviderTerms{
+	"claude": {
+		Region:    "US",
+		Residency: "US",
+		Retention: "30 days",
+		Training:  trainingNo,
+		Notes:     "API inputs are not used for training",
+	},
+	"openai": {
+		Region:    "US",
+		Residency: "US",
+		Retention: "30 days",
+		Training:  trainingNo,
+		Notes:     "API inputs are not used for training unless opted in",
+	},
+	"deepseek": {
+		Region:    "China",
+		Residency: "CN",
+		Retention: "unspecified",
+		Training:  trainingYes,
+		Notes:     "Privacy policy permits using inputs to improve services",
+	},
+	"gemini": {
+		Region:    "US/global",
+		Residency: "",
+		Retention: "55 days",
+		Training:  trainingYes,
+		Notes:     "Free tier inputs may be used for training; paid tier is excluded",
+	},
+	"mistral": {
+		Region:    "EU",
+		Residency: "EU",
+		Retention: "30 days",
+		Training:  trainingNo,
+		Notes:     "Paid API inputs are not used for training",
+	},
+	"zai": {
+		Region:    "Singapore",
+		Residency: "SG",
+		Retention: "unspecified",
+		Training:  trainingUnknown,
+		Notes:     "Terms do not clearly exclude training on API inputs",
+	},
+	"kimi": {
+		Region:    "China",
+		Residency: "CN",
+		Retention: "unspecified",
+		Training:  trainingUnknown,
+		Notes:     "Subscription terms do not clearly exclude training",
+	},
+	"grok": {
+		Region:    "US",
+		Residency: "US",
+		Retention: "30 days",
+		Training:  trainingNo,
+		Notes:     "API inputs are not used for training",
+	},
+	"groq": {
+		Region:    "US",
+		Residency: "US",
+		Retention: "none by default",
+		Training:  trainingNo,
+		Notes:     "Zero data retention unless features require storage",
+	},
+	"together": {
+		Region:    "US",
+		Residency: "US",
+		Retention: "configurable",
+		Training:  trainingNo,
+		Notes:     "Training on inputs is opt-in",
+	},
+	"openrouter": {
+		Region:    "varies",
+		Residency: "",
+		Retention: "varies",
+		Training:  trainingUnknown,
+		Notes:     "Depends on the routed provider; restrict providers in OpenRouter privacy settings",
+	},
+	"ollama": {
+		Region:    "local",
+		Residency: regionLocal,
+		Retention: "local only",
+		Training:  trainingNo,
+		Notes:     "Runs on your machine",
+	},
+}
+
+// termsFor returns the terms for a backend, defaulting to unknown
+func termsFor(backend string) ProviderTerms {
+	if t, ok := providerTerms[backend]; ok {
+		return t
+	}
+	return ProviderTerms{Region: "unknown", Retention: "unknown", Training: trainingUnknown}
+}
+
+// noTrainingApplies reports whether the no-training policy covers repo.
+// "*" covers every launch, including launches outside a git repository.
+func noTrainingApplies(cfg *Config, repo string) bool {
+	for _, r := range cfg.NoTrainingRepos {
+		if r == "*" || (repo != "" && strings.EqualFold(r, repo)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTrainingPolicy refuses backends that may train on inputs when the
+// current repository is covered by NEXUS_NO_TRAINING_REPOS. Providers with
+// unknown terms are refused too.
+func checkTrainingPolicy(cfg *Config, be Backend, git GitInfo) error {
+	if !noTrainingApplies(cfg, git.Repo) {
+		return nil
+	}
+	terms := termsFor(be.Name)
+	if terms.Training == trainingNo {
+		return nil
+	}
+	where := "this directory"
+	if git.Repo != "" {
+		where = "repository '" + git.Repo + "'"
+	}
+	reason := "may train on inputs"
+	if terms.Training == trainingUnknown {
+		reason = "has unknown training terms"
+	}
+	return fmt.Errorf("%s %s, which is not allowed for %s (NEXUS_NO_TRAINING_REPOS)", be.DisplayName, reason, where)
+}
+
+// parseDataRegions parses NEXUS_DATA_REGIONS: comma-separated
+// <repo>:<REGION>[+<REGION>...] entries, with "*" as the repo for every
+// launch not listed
+func parseDataRegions(value string) (map[string][]string, error) {
+	policies := make(map[string][]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		repo, list, ok := strings.Cut(entry, ":")
+		repo = strings.TrimSpace(repo)
+		if !ok || repo == "" {
+			return nil, fmt.Errorf("expected <repo>:<region>, got '%s'", entry)
+		}
+		var regions []string
+		for _, r := range strings.Split(list, "+") {
+			r = strings.ToUpper(strings.TrimSpace(r))
+			if !slices.Contains(dataRegions, r) {
+				return nil, fmt.Errorf("unknown region '%s' (known: %s)", r, strings.Join(dataRegions, ", "))
+			}
+			regions = append(regions, r)
+		}
+		policies[strings.ToLower(repo)] = regions
+	}
+	return policies, nil
+}
+
+// allowedDataRegions returns the regions repo may send data to, or nil when
+// no policy covers it. A policy for the repo itself wins over "*".
+func allowedDataRegions(cfg *Config, repo string) []string {
+	if repo != "" {
+		if regions, ok := cfg.DataRegions[strings.ToLower(repo)]; ok {
+			return regions
+		}
+	}
+	return cfg.DataRegions["*"]
+}
+
+// checkRegionPolicy refuses backends that process data outside the regions
+// NEXUS_DATA_REGIONS allows for the current repository. Local backends are
+// always allowed; providers without a fixed region are refused.
+func checkRegionPolicy(cfg *Config, be Backend, git GitInfo) error {
+	allowed := allowedDataRegions(cfg, git.Repo)
+	if allowed == nil {
+		return nil
+	}
+	region := termsFor(be.Name).Residency
+	if region == regionLocal || slices.Contains(allowed, region) {
+		return nil
+	}
+	where := "this directory"
+	if git.Repo != "" {
+		where = "repository '" + git.Repo + "'"
+	}
+	reason := "processes data in " + region
+	if region == "" {
+		reason = "has no fixed data region"
+	}
+	return fmt.Errorf("%s %s, but %s is limited to %s (NEXUS_DATA_REGIONS)", be.DisplayName, reason, where, strings.Join(allowed, ", "))
+}
+
+// enforceRegionPolicy applies checkRegionPolicy before traffic is sent to
+// be. With NEXUS_DATA_REGION_MODE=warn a violation is audited and printed
+// instead of refused.
+func enforceRegionPolicy(cfg *Config, be Backend, git GitInfo) error {
+	err := checkRegionPolicy(cfg, be, git)
+	if err == nil || !cfg.DataRegionWarn {
+		return err
+	}
+	auditLog(cfg, fmt.Sprintf("REGION_WARNING: %s (data region policy)", be.Name))
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	return nil
+}
+
+// formatDataRegions describes the data region policy for status, repos
+// in name order with "*" last
+func formatDataRegions(policies map[string][]string) string {
+	repos := make([]string, 0, len(policies))
+	for repo := range policies {
+		if repo != "*" {
+			repos = append(repos, repo)
+		}
+	}
+	sort.Strings(repos)
+	if _, ok := policies["*"]; ok {
+		repos = append(repos, "*")
+	}
+	parts := make([]string, len(repos))
+	for i, repo := range repos {
+		parts[i] = repo + ": " + strings.Join(policies[repo], ", ")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// renderProviderTerms prints the data handling terms for the given backends
+func renderProviderTerms(names []string, current string) {
+	rows := [][]string{}
+	for _, name := range names {
+		be, ok := backends[name]
+		if !ok {
+			continue
+		}
+		terms := termsFor(name)
+
+		marker := " "
+		if name == current {
+			marker = styleAccent.Render(">")
+		}
+		training := terms.Training
+		switch training {
+		case trainingYes:
+			training = styleError.Render(training)
+		case trainingUnknown:
+			training = styleWarning.Render(training)
+		}
+		rows = append(rows, []string{marker, be.DisplayName, terms.Region, terms.Retention, training, truncate(terms.Notes, 40)})
+	}
+
+	t := table.New().
+		Headers("", "Provider", "Region", "Retention", "Trains", "Notes").
+		Rows(rows...).
+		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary).Padding(0, 1)
+			}
+			if col == 0 {
+				return lipgloss.NewStyle().Width(2)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		}).
+		Width(110)
+
+	fmt.Println(t.Render())
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProviderTermsCoverAllBackends(t *testing.T) {
+	for name := range backends {
+		terms, ok := providerTerms[name]
+		if !ok {
+			t.Errorf("Missing provider terms for %s", name)
+			continue
+		}
+		switch terms.Training {
+		case trainingNo, trainingYes, trainingUnknown:
+		default:
+			t.Errorf("%s has invalid training value %q", name, terms.Training)
+		}
+		if r := terms.Residency; r != "" && r != regionLocal && !slices.Contains(dataRegions, r) {
+			t.Errorf("%s has invalid residency %q", name, r)
+		}
+	}
+}
+
+func TestCheckTrainingPolicy(t *testing.T) {
+	cfg := &Config{NoTrainingRepos: []string{"payments"}}
+
+	tests := []struct {
+		name    string
+		backend string
+		repo    string
+		wantErr string
+	}{
+		{"unlisted repo", "deepseek", "blog", ""},
+		{"no training provider", "claude", "payments", ""},
+		{"training provider", "deepseek", "payments", "may train on inputs"},
+		{"unknown terms", "openrouter", "Payments", "unknown training terms"},
+		{"outside git repo", "deepseek", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTrainingPolicy(cfg, backends[tt.backend], GitInfo{Repo: tt.repo})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected launch allowed, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckTrainingPolicyWildcard(t *testing.T) {
+	cfg := &Config{NoTrainingRepos: []string{"*"}}
+
+	err := checkTrainingPolicy(cfg, backends["gemini"], GitInfo{})
+	if err == nil || !strings.Contains(err.Error(), "this directory") {
+		t.Errorf("Expected wildcard policy to apply outside a repository, got %v", err)
+	}
+	if err := checkTrainingPolicy(cfg, backends["ollama"], GitInfo{}); err != nil {
+		t.Errorf("Expected local backend allowed, got %v", err)
+	}
+}
+
+func TestParseDataRegions(t *testing.T) {
+	got, err := parseDataRegions("Payments:eu, crm:EU+US ,*:us,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatDataRegions(got) != "crm: EU, US; payments: EU; *: US" {
+		t.Errorf("Unexpected policy %q", formatDataRegions(got))
+	}
+	for _, value := range []string{"payments", ":EU", "payments:EU+XX", "payments:"} {
+		if _, err := parseDataRegions(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestCheckRegionPolicy(t *testing.T) {
+	cfg := &Config{DataRegions: map[string][]string{"payments": {"EU"}, "*": {"EU", "US"}}}
+
+	tests := []struct {
+		name    string
+		backend string
+		repo    string
+		wantErr string
+	}{
+		{"EU provider", "mistral", "payments", ""},
+		{"US provider in EU-only repo", "claude", "Payments", "processes data in US, but repository 'Payments' is limited to EU"},
+		{"local backend", "ollama", "payments", ""},
+		{"no fixed region", "openrouter", "payments", "has no fixed data region"},
+		{"wildcard allows US", "claude", "blog", ""},
+		{"wildcard refuses CN", "deepseek", "blog", "processes data in CN"},
+		{"outside git repo", "kimi", "", "this directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRegionPolicy(cfg, backends[tt.backend], GitInfo{Repo: tt.repo})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected launch allowed, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if err := checkRegionPolicy(&Config{}, backends["deepseek"], GitInfo{Repo: "payments"}); err != nil {
+		t.Errorf("Expected no policy to allow every backend, got %v", err)
+	}
+}
+
+func TestEnforceRegionPolicyWarn(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		AuditEnabled: true,
+		AuditLog:     filepath.Join(dir, "audit.log"),
+		DataRegions:  map[string][]string{"*": {"EU"}},
+	}
+	if err := enforceRegionPolicy(cfg, backends["claude"], GitInfo{}); err == nil {
+		t.Error("Expected a violation refused by default")
+	}
+
+	cfg.DataRegionWarn = true
+	if err := enforceRegionPolicy(cfg, backends["claude"], GitInfo{}); err != nil {
+		t.Errorf("Expected only a warning in warn mode, got %v", err)
+	}
+	audit, _ := os.ReadFile(cfg.AuditLog)
+	if !strings.Contains(string(audit), "REGION_WARNING: claude") {
+		t.Errorf("Expected the warning audited, got %q", audit)
+	}
+}
+
+func TestAutoCandidatesRegionPolicy(t *testing.T) {
+	cfg := &Config{
+		Keys:           map[string]string{"ANTHROPIC_API_KEY": "k", "MISTRAL_API_KEY": "k"},
+		AutoPreference: []string{"claude", "mistral", "ollama"},
+		DataRegions:    map[string][]string{"*": {"EU"}},
+	}
+	if got := strings.Join(autoCandidates(cfg), ","); got != "mistral,ollama" {
+		t.Errorf("Expected only EU and local backends, got %s", got)
+	}
+	cfg.DataRegionWarn = true
+	if got := strings.Join(autoCandidates(cfg), ","); got != "claude,mistral,ollama" {
+		t.Errorf("Expected every backend in warn mode, got %s", got)
+	}
+}
@@ -4,6 +4,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -11,6 +12,8 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,6 +25,8 @@ type AnthropicRequest struct {
 	Temperature *float64           `json:"temperature,omitempty"`
 	TopP        *float64           `json:"top_p,omitempty"`
 	Stream      bool               `json:"stream,omitempty"`
+	Tools       []json.RawMessage  `json:"tools,omitempty"`
+	ToolChoice  json.RawMessage    `json:"tool_choice,omitempty"`
 	System      interface{}        `json:"system,omitempty"` // Can be string or []AnthropicContentItem
 }
 
@@ -90,8 +95,11 @@ type AnthropicResponse struct {
 }
 
 type AnthropicContent struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`    // tool_use
+	Name  string          `json:"name,omitempty"`  // tool_use
+	Input json.RawMessage `json:"input,omitempty"` // tool_use
 }
 
 type AnthropicUsage struct {
@@ -111,8 +119,11 @@ type AnthropicStreamEvent struct {
 }
 
 type AnthropicDelta struct {
-	Type string `json:"type,omitempty"`
-	Text string `json:"text,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Text       string `json:"text,omitempty"`
+	StopReason string `json:"stop_reason,omitempty"`
+	// PartialJSON is a fragment of a tool_use block's input
+	PartialJSON string `json:"partial_json,omitempty"`
 }
 
 // OpenAIRequest represents an OpenAI API chat completions request
@@ -123,6 +134,8 @@ type OpenAIRequest struct {
 	Temperature float64         `json:"temperature,omitempty"`
 	TopP        float64         `json:"top_p,omitempty"`
 	Stream      bool            `json:"stream,omitempty"`
+	// StreamOptions asks for a final usage chunk on streamed responses
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
 }
 
 type OpenAIMessage struct {
@@ -160,6 +173,7 @@ type OpenAIStreamEvent struct {
 	Created int64          `json:"created"`
 	Model   string         `json:"model"`
 	Choices []OpenAIChoice `json:"choices"`
+	Usage   *OpenAIUsage   `json:"usage,omitempty"`
 }
 
 // OllamaProxy is the proxy server that translates Anthropic to OpenAI
@@ -167,7 +181,43 @@ type OllamaProxy struct {
 	ollamaBaseURL string
 	server        *http.Server
 	modelMap      map[string]string
-	secureClient  *http.Client // TLS-enabled client for backend connections
+	secureClient  *http.Client   // TLS-enabled client for backend connections
+	promptIndex   *PromptIndex   // Optional index of past prompts for dedupe hints
+	indexing      sync.WaitGroup // Prompt index writes still in flight
+	backendName   string
+	sessionID     string     // Session indexed prompts belong to
+	compactor     *Compactor // Optional summarization of long histories
+	compactModel  string
+	recordSummary func(model string, usage AnthropicUsage)
+	demoter       *TierDemoter      // Optional latency-based haiku tier demotion
+	upstreams     map[string]string // Additional local upstreams by name
+	routes        []ModelRoute
+	recordUsage   func(model string, usage AnthropicUsage)
+	observeModel  func(requested, resolved string)
+	logDecision   func(ProxyDecision)
+	systemPrimer  string        // Optional project context appended to the system prompt
+	backendPrompt BackendPrompt // Optional provider-specific system prefix/suffix
+	chaos         ChaosConfig   // Optional synthetic upstream degradation
+	capabilities  *BackendCapabilities
+	onNoStream    func() // Called when the upstream turns out not to stream
+	streamOff     atomic.Bool
+	warned        sync.Map // Capability warnings already printed
+	protocol      string   // Upstream protocol; chat completions when empty
+	auth          AuthStrategy
+	credential    string // Upstream key for hosted backends
+	// noStreamOptions leaves stream_options out of streamed requests, for
+	// upstreams that reject unknown fields and report usage unasked
+	noStreamOptions bool
+	fim             *FIMRoute // Optional fill-in-the-middle endpoint
+	recordFIM       func(model string, usage AnthropicUsage)
+	cooldownFile    string // Optional rate-limit cool-downs shared across instances
+	latencyBudget   time.Duration
+	recordTimeout   func(model string, usage AnthropicUsage, elapsed time.Duration)
+	sampling        SamplingDefaults  // Optional max_tokens cap and temperature/top_p defaults
+	tiers           map[string]string // Tier each upstream model serves
+	// settingsMu guards modelMap, upstreams, routes, sampling and tiers,
+	// which a config reload replaces while requests are in flight
+	settingsMu sync.RWMutex
 }
 
 // NewOllamaProxy creates a new proxy instance
@@ -197,7 +247,7 @@ func NewOllamaProxy(ollamaBaseURL string, modelMap map[string]string) *OllamaPro
 	}
 
 	secureClient := &http.Client{
-		Timeout: 10 * time.Minute,
+		Timeout: defaultLatencyBudget,
 		Transport: &http.Transport{
 			TLSClientConfig: tlsConfig,
 		},
@@ -207,7 +257,135 @@ func NewOllamaProxy(ollamaBaseURL string, modelMap map[string]string) *OllamaPro
 		ollamaBaseURL: ollamaBaseURL,
 		modelMap:      modelMap,
 		secureClient:  secureClient,
+		latencyBudget: defaultLatencyBudget,
+	}
+}
+
+// SetLatencyBudget bounds upstream requests: whole non-streaming answers, and
+// the first byte of and pauses within streamed ones
+func (p *OllamaProxy) SetLatencyBudget(budget time.Duration) {
+	p.latencyBudget = budget
+	p.secureClient.Timeout = budget
+}
+
+// SetTimeoutRecorder registers a callback invoked, instead of the usage
+// recorder, for requests that exceeded the latency budget
+func (p *OllamaProxy) SetTimeoutRecorder(record func(model string, usage AnthropicUsage, elapsed time.Duration)) {
+	p.recordTimeout = record
+}
+
+// streamingClient returns a client for streamed answers. Streams have no total
+// timeout; the budget applies to the response headers and, through a
+// stallReader, to gaps in the body.
+func (p *OllamaProxy) streamingClient() *http.Client {
+	return &http.Client{
+		Transport: wrapChaos(&http.Transport{
+			TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+			ResponseHeaderTimeout: p.latencyBudget,
+		}, p.chaos),
+	}
+}
+
+// writeTimeout answers a request whose upstream exceeded the latency budget
+func (p *OllamaProxy) writeTimeout(w http.ResponseWriter) {
+	writeTimeoutError(w, latencyBudgetError(p.upstreamBackend(), p.latencyBudget))
+}
+
+// SetSampling caps max_tokens and sets default temperature and top_p for
+// requests
+func (p *OllamaProxy) SetSampling(s SamplingDefaults) {
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	p.sampling = s
+}
+
+// SetModelMap replaces the mapping from requested to upstream model names
+func (p *OllamaProxy) SetModelMap(modelMap map[string]string) {
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	p.modelMap = modelMap
+}
+
+// SetModelTiers records which tier each upstream model serves
+func (p *OllamaProxy) SetModelTiers(tiers map[string]string) {
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	p.tiers = tiers
+}
+
+// modelTier returns the tier an upstream model serves, if known
+func (p *OllamaProxy) modelTier(model string) string {
+	p.settingsMu.RLock()
+	defer p.settingsMu.RUnlock()
+	return p.tiers[model]
+}
+
+// SetPromptIndex enables dedupe hints and indexing of prompts sent through
+// the proxy. Lookups run alongside the upstream request and indexing after
+// the answer, so neither delays it. Indexed prompts are attributed to
+// sessionID when it is set.
+func (p *OllamaProxy) SetPromptIndex(idx *PromptIndex, backend, sessionID string) {
+	p.promptIndex = idx
+	p.backendName = backend
+	p.sessionID = sessionID
+}
+
+// SetModelObserver registers a callback invoked with the model requested
+// upstream and the model the provider reports having served
+func (p *OllamaProxy) SetModelObserver(observe func(requested, resolved string)) {
+	p.observeModel = observe
+}
+
+// SetSystemPrimer appends text to the system prompt of every message request
+func (p *OllamaProxy) SetSystemPrimer(text string) {
+	p.systemPrimer = text
+}
+
+// SetBackendPrompt wraps the system prompt of every message request in the
+// backend's configured prefix and suffix
+func (p *OllamaProxy) SetBackendPrompt(bp BackendPrompt) {
+	p.backendPrompt = bp
+}
+
+// SetProtocol selects the upstream protocol: protocolOpenAIChat (the
+// default) or protocolOpenAIResponses
+func (p *OllamaProxy) SetProtocol(protocol string) {
+	p.protocol = protocol
+}
+
+// SetUpstreamAuth authenticates upstream requests with credential, for hosted
+// backends. backend names the provider in error hints.
+func (p *OllamaProxy) SetUpstreamAuth(backend string, auth AuthStrategy, credential string) {
+	p.backendName = backend
+	p.auth = auth
+	p.credential = credential
+}
+
+// SetCooldownFile shares rate limits of the backend upstream with other
+// promptops instances through path
+func (p *OllamaProxy) SetCooldownFile(path string) {
+	p.cooldownFile = path
+}
+
+// upstreamBackend is the backend the default upstream belongs to
+func (p *OllamaProxy) upstreamBackend() Backend {
+	if be, ok := backends[p.backendName]; ok {
+		return be
+	}
+	return backends["ollama"]
+}
+
+// authorize applies the upstream credential to req, if one is set and req
+// goes to the backend's own base URL. Routed local upstreams never see it.
+func (p *OllamaProxy) authorize(req *http.Request, baseURL string) error {
+	if p.credential == "" || baseURL != p.ollamaBaseURL {
+		return nil
+	}
+	auth := p.auth
+	if auth == nil {
+		auth = BearerAuth{}
 	}
+	return auth.Apply(req, p.credential)
 }
 
 // Start starts the proxy server on the given port
@@ -231,7 +409,7 @@ func (p *OllamaProxy) Start(port int) error {
 	}
 
 	p.server = &http.Server{
-		Addr:         fmt.Sprintf("localhost:%d", port),
+		Addr:         proxyAddr(port),
 		Handler:      mux,
 		TLSConfig:    tlsConfig,
 		ReadTimeout:  30 * time.Second,
@@ -239,24 +417,18 @@ func (p *OllamaProxy) Start(port int) error {
 		IdleTimeout:  120 * time.Second,
 	}
 
-	// Start server in a goroutine
-	go func() {
-		if err := p.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			fmt.Fprintf(os.Stderr, "Proxy server error: %v\n", err)
-		}
-	}()
-
-	// Wait a moment for server to be ready
-	time.Sleep(100 * time.Millisecond)
-	return nil
+	return serveProxy(p.server, mux, port, "Proxy server")
 }
 
 // Stop stops the proxy server
 func (p *OllamaProxy) Stop() error {
+	var err error
 	if p.server != nil {
-		return p.server.Close()
+		err = p.server.Close()
 	}
-	return nil
+	// Prompts answered before the stop are still indexed
+	p.indexing.Wait()
+	return err
 }
 
 func (p *OllamaProxy) handleModels(w http.ResponseWriter, r *http.Request) {
@@ -299,6 +471,62 @@ func (p *OllamaProxy) handleMessages(w http.ResponseWriter, r *http.Request) {
 
 	// Map model name
 	model := p.mapModel(anthReq.Model)
+	decision := ProxyDecision{Time: time.Now(), Requested: anthReq.Model, Stream: anthReq.Stream, Chaos: p.chaos.Enabled()}
+	p.settingsMu.RLock()
+	sampling := p.sampling
+	p.settingsMu.RUnlock()
+	if lowered := sampling.Apply(&anthReq); lowered > 0 {
+		decision.MaxTokensLowered = lowered
+		p.warnOnce("max_tokens", fmt.Sprintf("Lowering max_tokens from %d to the %d-token cap for %s", lowered, anthReq.MaxTokens, p.upstreamBackend().DisplayName))
+	}
+	if p.demoter != nil {
+		mapped := model
+		model = p.demoter.Route(model)
+		decision.Demoted = model != mapped
+	}
+
+	// Infill requests go to the FIM endpoint when one is set
+	if p.fim != nil {
+		if prefix, suffix, ok := parseFIMRequest(anthReq); ok {
+			decision.Model, decision.Upstream = p.fim.Model, fimUpstream
+			sw := &statusWriter{ResponseWriter: w}
+			usage := p.handleFIMMessage(sw, anthReq, prefix, suffix)
+			decision.TimedOut = sw.timedOut
+			p.finishMessage(decision, sw.status, p.fim.Model, usage)
+			return
+		}
+	}
+
+	// Requests wait out a rate limit another instance ran into
+	if upstream, _ := p.upstreamFor(model); upstream == defaultUpstream {
+		if left, ok := activeCooldown(p.cooldownFile, p.upstreamBackend().Name, time.Now()); ok {
+			decision.Model, decision.Upstream = model, upstream
+			writeCooldownError(w, cooldownError(p.upstreamBackend(), left))
+			p.finishMessage(decision, http.StatusTooManyRequests, model, AnthropicUsage{})
+			return
+		}
+	}
+
+	if !p.backendPrompt.Empty() {
+		decision.BackendPromptTokens = p.backendPrompt.Tokens(model)
+	}
+
+	// Responses API upstreams get their own translation, including tools.
+	// Routed local upstreams speak chat completions.
+	if upstream, baseURL := p.upstreamFor(model); p.protocol == protocolOpenAIResponses && upstream == defaultUpstream {
+		decision.Model, decision.Upstream = model, upstream
+		p.checkCapabilities(model, anthReq, nil)
+		sw := &statusWriter{ResponseWriter: w}
+		_, usage := p.handleResponses(sw, anthReq, baseURL, model)
+		decision.TimedOut = sw.timedOut
+		p.finishMessage(decision, sw.status, model, usage)
+		return
+	}
+
+	// Upstreams found not to stream get a plain request whose answer is
+	// relayed as a stream
+	upstream, baseURL := p.upstreamFor(model)
+	stream := anthReq.Stream && !(upstream == defaultUpstream && p.streamOff.Load())
 
 	// Build OpenAI request
 	openaiReq := OpenAIRequest{
@@ -306,7 +534,10 @@ func (p *OllamaProxy) handleMessages(w http.ResponseWriter, r *http.Request) {
 		MaxTokens:   anthReq.MaxTokens,
 		Temperature: 0.7,
 		TopP:        1.0,
-		Stream:      anthReq.Stream,
+		Stream:      stream,
+	}
+	if stream && !p.noStreamOptions {
+		openaiReq.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
 	}
 
 	if anthReq.Temperature != nil {
@@ -317,7 +548,7 @@ func (p *OllamaProxy) handleMessages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert messages
-	systemText := anthReq.GetSystemText()
+	systemText := p.backendPrompt.Wrap(appendSystemText(anthReq.GetSystemText(), p.systemPrimer))
 	if systemText != "" {
 		openaiReq.Messages = append(openaiReq.Messages, OpenAIMessage{
 			Role:    "system",
@@ -338,6 +569,16 @@ func (p *OllamaProxy) handleMessages(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	if p.compactor != nil {
+		before, _ := p.compactor.Stats()
+		openaiReq.Messages = p.compactor.Compact(openaiReq.Model, openaiReq.Messages)
+		after, _ := p.compactor.Stats()
+		decision.Compacted = after > before
+	}
+	if upstream == defaultUpstream {
+		p.checkCapabilities(model, anthReq, openaiReq.Messages)
+	}
+
 	// Send to Ollama
 	openaiBody, err := json.Marshal(openaiReq)
 	if err != nil {
@@ -345,36 +586,143 @@ func (p *OllamaProxy) handleMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if anthReq.Stream {
-		p.handleStreaming(w, r, openaiBody)
+	// Near-duplicate prompts are looked up while the request is forwarded, so
+	// the embedding call never delays it
+	prompt := lastUserPrompt(anthReq)
+	var dedupe chan promptDedupe
+	if p.promptIndex != nil && prompt != "" {
+		dedupe = make(chan promptDedupe, 1)
+		p.indexing.Add(1)
+		go func() {
+			embedding, similar := p.checkDuplicatePrompt(prompt)
+			dedupe <- promptDedupe{embedding: embedding, similar: similar}
+		}()
+	}
+
+	decision.Model, decision.Upstream = model, upstream
+	sw := &statusWriter{ResponseWriter: w}
+	var answer string
+	var usage AnthropicUsage
+	if stream {
+		answer, usage = p.handleStreaming(sw, r, upstream, baseURL, openaiBody, anthReq.Model, model)
 	} else {
-		p.handleNonStreaming(w, openaiBody, anthReq.Model)
+		answer, usage = p.handleNonStreaming(sw, baseURL, openaiBody, anthReq.Model, model, anthReq.Stream)
 	}
+
+	decision.TimedOut = sw.timedOut
+	if dedupe == nil {
+		p.finishMessage(decision, sw.status, model, usage)
+		return
+	}
+	// A lookup still running is not waited for; the answer is indexed once
+	// it completes
+	var result promptDedupe
+	select {
+	case result = <-dedupe:
+		decision.SimilarPrompt = result.similar
+		dedupe = nil
+	default:
+	}
+	p.finishMessage(decision, sw.status, model, usage)
+	go func() {
+		defer p.indexing.Done()
+		if dedupe != nil {
+			result = <-dedupe
+		}
+		if result.embedding != nil && answer != "" {
+			p.indexPrompt(prompt, model, answer, usage, result.embedding)
+		}
+	}()
+}
+
+// promptDedupe is the outcome of a prompt index lookup
+type promptDedupe struct {
+	embedding []float64
+	similar   bool
 }
 
-func (p *OllamaProxy) handleStreaming(w http.ResponseWriter, r *http.Request, openaiBody []byte) {
-	req, err := http.NewRequest("POST", p.ollamaBaseURL+"/chat/completions", bytes.NewReader(openaiBody))
+// checkDuplicatePrompt embeds the prompt and prints a hint if a similar prompt
+// was sent before. It returns the embedding so the prompt can be indexed, and
+// whether a similar prompt was found.
+func (p *OllamaProxy) checkDuplicatePrompt(prompt string) ([]float64, bool) {
+	embedding, err := p.promptIndex.Embed(prompt)
+	if err != nil {
+		return nil, false
+	}
+	match, score := p.promptIndex.Search(embedding)
+	if match != nil {
+		fmt.Fprintln(os.Stderr, styleMuted.Render(formatDedupeHint(match, score)))
+	}
+	return embedding, match != nil
+}
+
+// indexPrompt records a completed prompt and its answer in the prompt index
+func (p *OllamaProxy) indexPrompt(prompt, model, answer string, usage AnthropicUsage, embedding []float64) {
+	cost := 0.0
+	if be, ok := backends[p.backendName]; ok {
+		cost = float64(usage.InputTokens)*be.InputPrice/1000000 + float64(usage.OutputTokens)*be.OutputPrice/1000000
+	}
+	entry := PromptIndexEntry{
+		Timestamp: time.Now(),
+		SessionID: p.sessionID,
+		Backend:   p.backendName,
+		Model:     model,
+		Preview:   promptPreview(prompt),
+		CostUSD:   cost,
+		Embedding: embedding,
+	}
+	if err := p.promptIndex.Add(entry, prompt, answer); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to index prompt: %v\n", err)
+	}
+}
+
+// handleStreaming relays a streaming completion and returns the full text
+func (p *OllamaProxy) handleStreaming(w http.ResponseWriter, r *http.Request, upstream, baseURL string, openaiBody []byte, originalModel, upstreamModel string) (string, AnthropicUsage) {
+	req, err := http.NewRequest("POST", baseURL+"/chat/completions", bytes.NewReader(openaiBody))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", AnthropicUsage{}
 	}
 	req.Header.Set("Content-Type", "application/json")
-
-	// Use streaming-capable client with extended timeout
-	streamingClient := &http.Client{
-		Timeout: 0, // No timeout for streaming
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				MinVersion: tls.VersionTLS12,
-			},
-		},
+	if err := p.authorize(req, baseURL); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", AnthropicUsage{}
 	}
-	resp, err := streamingClient.Do(req)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req = req.WithContext(ctx)
+	start := time.Now()
+	resp, err := p.streamingClient().Do(req)
 	if err != nil {
+		if isTimeout(err) {
+			p.writeTimeout(w)
+			return "", AnthropicUsage{}
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", AnthropicUsage{}
 	}
+	p.observeLatency(start)
 	defer resp.Body.Close()
+	body := newStallReader(resp.Body, p.latencyBudget, cancel)
+
+	if resp.StatusCode != http.StatusOK {
+		p.writeUpstreamError(w, resp)
+		return "", AnthropicUsage{}
+	}
+
+	// An upstream that ignores stream answers with a single completion
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if upstream == defaultUpstream {
+			p.markNoStreaming()
+		}
+		var openaiResp OpenAIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&openaiResp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return "", AnthropicUsage{}
+		}
+		return p.writeCompletion(w, openaiResp, originalModel, upstreamModel, true)
+	}
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -384,7 +732,7 @@ func (p *OllamaProxy) handleStreaming(w http.ResponseWriter, r *http.Request, op
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
-		return
+		return "", AnthropicUsage{}
 	}
 
 	// Send message_start event
@@ -415,9 +763,11 @@ func (p *OllamaProxy) handleStreaming(w http.ResponseWriter, r *http.Request, op
 	flusher.Flush()
 
 	// Process OpenAI stream
-	scanner := bufio.NewScanner(resp.Body)
+	scanner := bufio.NewScanner(body)
 	contentIndex := 0
 	var fullContent strings.Builder
+	var usage AnthropicUsage
+	observed := false
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -434,6 +784,14 @@ func (p *OllamaProxy) handleStreaming(w http.ResponseWriter, r *http.Request, op
 		if err := json.Unmarshal([]byte(data), &streamEvent); err != nil {
 			continue
 		}
+		if !observed && streamEvent.Model != "" && p.observeModel != nil {
+			p.observeModel(upstreamModel, streamEvent.Model)
+			observed = true
+		}
+		if streamEvent.Usage != nil {
+			usage.InputTokens = streamEvent.Usage.PromptTokens
+			usage.OutputTokens = streamEvent.Usage.CompletionTokens
+		}
 
 		if len(streamEvent.Choices) > 0 && streamEvent.Choices[0].Delta != nil {
 			text := streamEvent.Choices[0].Delta.Content
@@ -452,6 +810,10 @@ func (p *OllamaProxy) handleStreaming(w http.ResponseWriter, r *http.Request, op
 			}
 		}
 	}
+	if body.Stop() {
+		writeStreamTimeout(w, latencyBudgetError(p.upstreamBackend(), p.latencyBudget))
+		return fullContent.String(), usage
+	}
 
 	// Send content_block_stop
 	blockStop := AnthropicStreamEvent{
@@ -467,27 +829,61 @@ func (p *OllamaProxy) handleStreaming(w http.ResponseWriter, r *http.Request, op
 	}
 	writeSSE(w, msgStop)
 	flusher.Flush()
+
+	return fullContent.String(), usage
 }
 
-func (p *OllamaProxy) handleNonStreaming(w http.ResponseWriter, openaiBody []byte, originalModel string) {
-	req, err := http.NewRequest("POST", p.ollamaBaseURL+"/chat/completions", bytes.NewReader(openaiBody))
+// handleNonStreaming relays a completion and returns its text and usage. With
+// stream set the answer is sent as events, for upstreams that cannot stream.
+func (p *OllamaProxy) handleNonStreaming(w http.ResponseWriter, baseURL string, openaiBody []byte, originalModel, upstreamModel string, stream bool) (string, AnthropicUsage) {
+	req, err := http.NewRequest("POST", baseURL+"/chat/completions", bytes.NewReader(openaiBody))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", AnthropicUsage{}
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if err := p.authorize(req, baseURL); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", AnthropicUsage{}
+	}
 
+	start := time.Now()
 	resp, err := p.secureClient.Do(req)
 	if err != nil {
+		if isTimeout(err) {
+			p.writeTimeout(w)
+			return "", AnthropicUsage{}
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", AnthropicUsage{}
 	}
 	defer resp.Body.Close()
+	p.observeLatency(start)
 
+	if resp.StatusCode != http.StatusOK {
+		p.writeUpstreamError(w, resp)
+		return "", AnthropicUsage{}
+	}
+
+	// The client timeout also covers reading the body, so a stall after the
+	// headers surfaces here
 	var openaiResp OpenAIResponse
 	if err := json.NewDecoder(resp.Body).Decode(&openaiResp); err != nil {
+		if isTimeout(err) {
+			p.writeTimeout(w)
+			return "", AnthropicUsage{}
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", AnthropicUsage{}
+	}
+	return p.writeCompletion(w, openaiResp, originalModel, upstreamModel, stream)
+}
+
+// writeCompletion converts an OpenAI completion into an Anthropic response,
+// or into its event sequence when stream is set
+func (p *OllamaProxy) writeCompletion(w http.ResponseWriter, openaiResp OpenAIResponse, originalModel, upstreamModel string, stream bool) (string, AnthropicUsage) {
+	if openaiResp.Model != "" && p.observeModel != nil {
+		p.observeModel(upstreamModel, openaiResp.Model)
 	}
 
 	// Convert to Anthropic response
@@ -502,8 +898,9 @@ func (p *OllamaProxy) handleNonStreaming(w http.ResponseWriter, openaiBody []byt
 		},
 	}
 
+	var content string
 	if len(openaiResp.Choices) > 0 {
-		content := openaiResp.Choices[0].Message.Content
+		content = openaiResp.Choices[0].Message.Content
 		anthResp.Content = []AnthropicContent{
 			{Type: "text", Text: content},
 		}
@@ -512,9 +909,54 @@ func (p *OllamaProxy) handleNonStreaming(w http.ResponseWriter, openaiBody []byt
 		}
 	}
 
+	if stream {
+		writeSyntheticStream(w, anthResp)
+		return content, anthResp.Usage
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(anthResp)
+	return content, anthResp.Usage
+}
+
+// writeSyntheticStream sends a complete response as the events of a streamed
+// one
+func writeSyntheticStream(w http.ResponseWriter, resp AnthropicResponse) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	start := resp
+	start.Content = []AnthropicContent{}
+	start.StopReason = ""
+	start.Usage = AnthropicUsage{InputTokens: resp.Usage.InputTokens}
+	writeSSE(w, AnthropicStreamEvent{Type: "message_start", Message: &start})
+	writeSSE(w, AnthropicStreamEvent{Type: "content_block_start", ContentBlock: &AnthropicContent{Type: "text"}})
+	for _, c := range resp.Content {
+		if c.Text != "" {
+			writeSSE(w, AnthropicStreamEvent{Type: "content_block_delta", Delta: &AnthropicDelta{Type: "text_delta", Text: c.Text}})
+		}
+	}
+	writeSSE(w, AnthropicStreamEvent{Type: "content_block_stop"})
+	writeSSE(w, AnthropicStreamEvent{
+		Type:  "message_delta",
+		Delta: &AnthropicDelta{StopReason: resp.StopReason},
+		Usage: &AnthropicUsage{OutputTokens: resp.Usage.OutputTokens},
+	})
+	writeSSE(w, AnthropicStreamEvent{Type: "message_stop"})
+}
+
+// writeUpstreamError translates an upstream error into an Anthropic error
+// response with an actionable hint, and prints the hint to the terminal.
+// Rate limits of the default upstream are shared with other instances.
+func (p *OllamaProxy) writeUpstreamError(w http.ResponseWriter, resp *http.Response) {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	pe := classifyProviderError(p.upstreamBackend(), resp.StatusCode, resp.Header, body)
+	fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] "+pe.Message))
+	if resp.Request != nil && strings.HasPrefix(resp.Request.URL.String(), p.ollamaBaseURL) {
+		noteRateLimit(p.cooldownFile, pe)
+	}
+	writeAnthropicError(w, pe)
 }
 
 func (p *OllamaProxy) handleProxy(w http.ResponseWriter, r *http.Request) {
@@ -559,6 +1001,8 @@ func (p *OllamaProxy) handleProxy(w http.ResponseWriter, r *http.Request) {
 }
 
 func (p *OllamaProxy) mapModel(model string) string {
+	p.settingsMu.RLock()
+	defer p.settingsMu.RUnlock()
 	// Check if we have a direct mapping
 	if mapped, ok := p.modelMap[model]; ok {
 		return mapped
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Local proxies answer a challenge on proxyHandshakePath before Claude Code is
+// pointed at them, so a process that bound the port first (on another
+// address family, or before our listener) cannot silently receive prompts
+// and keys
+const (
+	proxyHandshakePath   = "/_promptops/handshake"
+	proxyNonceHeader     = "X-Promptops-Nonce"
+	proxyHandshakeWait   = 2 * time.Second
+	proxyHandshakeMaxLen = 128
+)
+
+// proxyHost is the loopback address local proxies bind to and Claude Code is
+// pointed at. "localhost" can resolve to ::1 for one and 127.0.0.1 for the
+// other, leaving the address Claude Code reaches free for another process.
+const proxyHost = "127.0.0.1"
+
+// proxyAddr is the listen address of a local proxy on port
+func proxyAddr(port int) string {
+	return net.JoinHostPort(proxyHost, strconv.Itoa(port))
+}
+
+// proxyURL is the base URL Claude Code uses for a local proxy on port
+func proxyURL(port int) string {
+	return "http://" + proxyAddr(port)
+}
+
+// newProxyToken returns a random secret that never leaves the process
+func newProxyToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// proxyProof is the handshake answer for nonce: an HMAC under the token, so
+// the token itself is never sent
+func proxyProof(token, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// handshakeHandler answers handshake challenges for token
+func handshakeHandler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nonce := r.Header.Get(proxyNonceHeader)
+		if nonce == "" || len(nonce) > proxyHandshakeMaxLen {
+			http.Error(w, "missing nonce", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, proxyProof(token, nonce))
+	}
+}
+
+// serveProxy binds server's port, serves mux with the handshake endpoint
+// added, and returns once the listener at proxyURL(port) is verified to be
+// this one. A port already in use is an error rather than a message from a
+// background goroutine.
+func serveProxy(server *http.Server, mux *http.ServeMux, port int, name string) error {
+	token, err := newProxyToken()
+	if err != nil {
+		return err
+	}
+	mux.HandleFunc(proxyHandshakePath, handshakeHandler(token))
+
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("port %d is already in use by another process; refusing to send prompts and keys to it (stop that process and retry): %w", port, err)
+	}
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "%s error: %v\n", name, err)
+		}
+	}()
+	if err := verifyProxy(port, token); err != nil {
+		server.Close()
+		return err
+	}
+	return nil
+}
+
+// verifyProxy challenges the listener Claude Code will reach at
+// proxyURL(port) and fails unless it proves it holds token
+func verifyProxy(port int, token string) error {
+	nonce, err := newProxyToken()
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout:   proxyHandshakeWait,
+		Transport: &http.Transport{Proxy: nil},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequest("GET", proxyURL(port)+proxyHandshakePath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(proxyNonceHeader, nonce)
+
+	hijacked := fmt.Errorf("%s is answered by another process, not this promptops proxy; refusing to send prompts and keys to it (find it with: lsof -i :%d)", proxyAddr(port), port)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", hijacked, err)
+	}
+	defer resp.Body.Close()
+	proof, _ := io.ReadAll(io.LimitReader(resp.Body, proxyHandshakeMaxLen))
+	if resp.StatusCode != http.StatusOK || !hmac.Equal(proof, []byte(proxyProof(token, nonce))) {
+		return hijacked
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serverPort returns the port of a test server
+func serverPort(t *testing.T, s *httptest.Server) int {
+	t.Helper()
+	return s.Listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestVerifyProxy(t *testing.T) {
+	token, err := newProxyToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours := httptest.NewServer(handshakeHandler(token))
+	defer ours.Close()
+	if err := verifyProxy(serverPort(t, ours), token); err != nil {
+		t.Errorf("Expected our listener verified, got %v", err)
+	}
+
+	// A listener with another token, or one that answers everything, is not ours
+	other, _ := newProxyToken()
+	if err := verifyProxy(serverPort(t, ours), other); err == nil {
+		t.Error("Expected a listener holding another token rejected")
+	}
+	impostor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(proxyNonceHeader)))
+	}))
+	defer impostor.Close()
+	err = verifyProxy(serverPort(t, impostor), token)
+	if err == nil || !strings.Contains(err.Error(), "another process") {
+		t.Errorf("Expected an impostor rejected, got %v", err)
+	}
+}
+
+func TestHandshakeHandlerNeverSendsToken(t *testing.T) {
+	token, _ := newProxyToken()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", proxyHandshakePath, nil)
+	r.Header.Set(proxyNonceHeader, "abc")
+	handshakeHandler(token)(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != proxyProof(token, "abc") {
+		t.Errorf("Unexpected handshake answer %d %q", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), token) {
+		t.Error("Handshake answer must not contain the token")
+	}
+
+	w = httptest.NewRecorder()
+	handshakeHandler(token)(w, httptest.NewRequest("GET", proxyHandshakePath, nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a missing nonce rejected, got %d", w.Code)
+	}
+}
+
+func TestProxyStartFailsClosedOnBusyPort(t *testing.T) {
+	ln, err := net.Listen("tcp", proxyAddr(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	err = NewOllamaProxy("http://127.0.0.1:1", nil).Start(port)
+	if err == nil || !strings.Contains(err.Error(), "refusing to send prompts") {
+		t.Errorf("Expected the Ollama proxy to refuse a busy port, got %v", err)
+	}
+	err = NewGrokProxy("http://127.0.0.1:1", "key").Start(port)
+	if err == nil || !strings.Contains(err.Error(), "refusing to send prompts") {
+		t.Errorf("Expected the Grok proxy to refuse a busy port, got %v", err)
+	}
+}
+
+func TestProxyStartVerifiesListener(t *testing.T) {
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := NewGrokProxy("http://127.0.0.1:1", "key")
+	if err := proxy.Start(port); err != nil {
+		t.Fatalf("Expected the proxy to start, got %v", err)
+	}
+	defer proxy.Stop()
+
+	// The handshake endpoint is served locally, not forwarded upstream
+	req, _ := http.NewRequest("GET", proxyURL(port)+proxyHandshakePath, nil)
+	req.Header.Set(proxyNonceHeader, "n")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the handshake answered by the proxy, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "time"
+
+// OpenAIStreamOptions asks an upstream for a final usage chunk on streamed
+// responses, so the proxy can record the usage of every request
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// SetUsageRecorder registers a callback invoked with token usage after each completion
+func (p *OllamaProxy) SetUsageRecorder(record func(model string, usage AnthropicUsage)) {
+	p.recordUsage = record
+}
+
+// OmitStreamOptions stops the proxy asking for a usage chunk on streamed
+// requests, for upstreams that send one anyway and reject the field
+func (p *OllamaProxy) OmitStreamOptions() {
+	p.noStreamOptions = true
+}
+
+// finishMessage records the decision and usage of a completed message request
+func (p *OllamaProxy) finishMessage(decision ProxyDecision, status int, model string, usage AnthropicUsage) {
+	if p.logDecision != nil {
+		decision.Status = status
+		decision.DurationMS = time.Since(decision.Time).Milliseconds()
+		decision.InputTokens, decision.OutputTokens = usage.InputTokens, usage.OutputTokens
+		p.logDecision(decision)
+	}
+	if decision.TimedOut && p.recordTimeout != nil {
+		p.recordTimeout(model, usage, time.Since(decision.Time))
+		return
+	}
+	record := p.recordUsage
+	if p.fim != nil && decision.Upstream == fimUpstream {
+		record = p.recordFIM
+	}
+	if record != nil && usage.InputTokens+usage.OutputTokens > 0 {
+		record(model, usage)
+	}
+}
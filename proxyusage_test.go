@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxyRecordsStreamingUsage(t *testing.T) {
+	mockOllama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Error("Expected stream_options.include_usage on streamed requests")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintln(w, `data: {"choices":[{"delta":{"content":"hi"}}]}`)
+		fmt.Fprintln(w, `data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3}}`)
+		fmt.Fprintln(w, "data: [DONE]")
+	}))
+	defer mockOllama.Close()
+
+	var recorded AnthropicUsage
+	calls := 0
+	proxy := NewOllamaProxy(mockOllama.URL, nil)
+	proxy.SetUsageRecorder(func(model string, usage AnthropicUsage) {
+		calls++
+		recorded = usage
+	})
+
+	body, _ := json.Marshal(AnthropicRequest{
+		Model:    "llama3.2",
+		Stream:   true,
+		Messages: []AnthropicMessage{{Role: "user", Content: "hello"}},
+	})
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+
+	if calls != 1 {
+		t.Fatalf("Expected usage to be recorded once, got %d", calls)
+	}
+	if recorded.InputTokens != 12 || recorded.OutputTokens != 3 {
+		t.Errorf("Unexpected usage: %+v", recorded)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Shared rate-limit cool-down bounds
+const (
+	// defaultCooldown applies when a 429 does not say when the limit resets
+	defaultCooldown = 15 * time.Second
+	// maxCooldown caps a reset time so a bad header cannot block a provider
+	// for the rest of the day
+	maxCooldown = time.Hour
+)
+
+// Cooldown is a window in which no promptops instance sends requests to a
+// provider, set when one of them is rate limited. Cool-downs are shared
+// through RateLimitFile so concurrent terminals on the same key back off
+// together instead of compounding 429s.
+type Cooldown struct {
+	Until  time.Time `json:"until"`
+	Status int       `json:"status"`
+	PID    int       `json:"pid"` // instance that was rate limited
+}
+
+// loadCooldowns reads the shared cool-downs by backend
+func loadCooldowns(path string) map[string]Cooldown {
+	cooldowns := make(map[string]Cooldown)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cooldowns
+	}
+	if err := json.Unmarshal(data, &cooldowns); err != nil {
+		return make(map[string]Cooldown)
+	}
+	return cooldowns
+}
+
+// recordCooldown starts or extends the shared cool-down of backend. Expired
+// entries are dropped, and a longer window set by another instance is kept.
+func recordCooldown(path, backend string, status int, wait time.Duration, now time.Time) error {
+	if wait <= 0 {
+		wait = defaultCooldown
+	}
+	wait = min(wait, maxCooldown)
+	return withFileLock(path+".lock", func() error {
+		cooldowns := loadCooldowns(path)
+		for name, c := range cooldowns {
+			if !c.Until.After(now) {
+				delete(cooldowns, name)
+			}
+		}
+		until := now.Add(wait)
+		if c, ok := cooldowns[backend]; ok && c.Until.After(until) {
+			return nil
+		}
+		cooldowns[backend] = Cooldown{Until: until, Status: status, PID: os.Getpid()}
+		data, err := json.MarshalIndent(cooldowns, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path, data, 0600)
+	})
+}
+
+// activeCooldown returns the time left in backend's shared cool-down
+func activeCooldown(path, backend string, now time.Time) (time.Duration, bool) {
+	if path == "" {
+		return 0, false
+	}
+	c, ok := loadCooldowns(path)[backend]
+	if !ok || !c.Until.After(now) {
+		return 0, false
+	}
+	return c.Until.Sub(now), true
+}
+
+// cooldownError is the response to a request held back by a shared cool-down
+func cooldownError(be Backend, left time.Duration) *ProviderError {
+	return &ProviderError{
+		Backend:    be.Name,
+		StatusCode: http.StatusTooManyRequests,
+		Kind:       errKindRateLimited,
+		Message:    fmt.Sprintf("%s rate limit cool-down shared with other promptops instances - resets in %s", be.DisplayName, formatDuration(left)),
+		RetryAfter: left,
+	}
+}
+
+// writeCooldownError answers a request without contacting the provider,
+// telling Claude Code when to retry
+func writeCooldownError(w http.ResponseWriter, pe *ProviderError) {
+	secs := int(pe.RetryAfter.Round(time.Second) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+	writeAnthropicError(w, pe)
+}
+
+// noteRateLimit shares a provider's 429 with other instances
+func noteRateLimit(path string, pe *ProviderError) {
+	if path == "" || pe.Kind != errKindRateLimited || pe.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+	if err := recordCooldown(path, pe.Backend, pe.StatusCode, pe.RetryAfter, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to share rate limit cool-down: %v\n", err)
+	}
+}
+
+// renderCooldowns lists active cool-downs for doctor
+func renderCooldowns(cooldowns map[string]Cooldown, now time.Time, out io.Writer) {
+	var names []string
+	for name, c := range cooldowns {
+		if c.Until.After(now) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(out, "%s No provider is cooling down\n", styleSuccess.Render("[OK]  "))
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := cooldowns[name]
+		fmt.Fprintf(out, "%s %s rate limited (HTTP %d, pid %d) - resets in %s\n",
+			styleWarning.Render("[WAIT]"), name, c.Status, c.PID, formatDuration(c.Until.Sub(now)))
+	}
+}
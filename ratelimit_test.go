@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordCooldown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimits.json")
+	now := time.Now()
+
+	if _, ok := activeCooldown(path, "openai", now); ok {
+		t.Error("Expected no cool-down before any rate limit")
+	}
+	if err := recordCooldown(path, "openai", 429, 30*time.Second, now); err != nil {
+		t.Fatal(err)
+	}
+	if left, ok := activeCooldown(path, "openai", now); !ok || left != 30*time.Second {
+		t.Errorf("Expected 30s left, got %v %v", left, ok)
+	}
+	if _, ok := activeCooldown(path, "deepseek", now); ok {
+		t.Error("Expected other backends unaffected")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a 0600 cool-down file, got %v %v", info, err)
+	}
+
+	// A shorter window from another instance does not cut the longer one
+	recordCooldown(path, "openai", 429, 5*time.Second, now)
+	if left, _ := activeCooldown(path, "openai", now); left != 30*time.Second {
+		t.Errorf("Expected the longer window kept, got %v", left)
+	}
+
+	// Unknown and excessive reset times are bounded
+	recordCooldown(path, "mistral", 429, 0, now)
+	recordCooldown(path, "groq", 429, 48*time.Hour, now)
+	if left, _ := activeCooldown(path, "mistral", now); left != defaultCooldown {
+		t.Errorf("Expected the default cool-down, got %v", left)
+	}
+	if left, _ := activeCooldown(path, "groq", now); left != maxCooldown {
+		t.Errorf("Expected the cool-down capped, got %v", left)
+	}
+
+	// Expired entries are dropped on the next write
+	later := now.Add(2 * time.Minute)
+	recordCooldown(path, "deepseek", 429, time.Second, later)
+	cooldowns := loadCooldowns(path)
+	if _, ok := cooldowns["openai"]; ok || len(cooldowns) != 2 {
+		t.Errorf("Expected expired cool-downs dropped, got %v", cooldowns)
+	}
+}
+
+func TestProxySharesRateLimits(t *testing.T) {
+	// A keyless proxy records and reads its cool-down as Ollama
+	for name, backend := range map[string]string{"openai": "openai", "keyless_ollama": ""} {
+		t.Run(name, func(t *testing.T) {
+			requests := 0
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Retry-After", "20")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer upstream.Close()
+
+			path := filepath.Join(t.TempDir(), "ratelimits.json")
+			newProxy := func() *OllamaProxy {
+				proxy := NewOllamaProxy(upstream.URL, nil)
+				if backend != "" {
+					proxy.SetUpstreamAuth(backend, nil, "")
+				}
+				proxy.SetCooldownFile(path)
+				return proxy
+			}
+			first, second := newProxy(), newProxy()
+			var decisions []ProxyDecision
+			second.SetDecisionRecorder(func(d ProxyDecision) { decisions = append(decisions, d) })
+
+			send := func(p *OllamaProxy) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+				p.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader([]byte(body))))
+				return w
+			}
+
+			send(first)
+			if requests != 1 {
+				t.Fatalf("Expected the first request upstream, got %d", requests)
+			}
+
+			// Another instance on the same backend waits without calling upstream
+			w := send(second)
+			if requests != 1 {
+				t.Errorf("Expected the second instance held back, got %d upstream requests", requests)
+			}
+			if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+				t.Errorf("Expected a 429 with Retry-After, got %d %v", w.Code, w.Header())
+			}
+			if !strings.Contains(w.Body.String(), "shared with other promptops instances") {
+				t.Errorf("Unexpected body %s", w.Body.String())
+			}
+			if len(decisions) != 1 || decisions[0].Status != http.StatusTooManyRequests {
+				t.Errorf("Expected the held request recorded, got %+v", decisions)
+			}
+		})
+	}
+}
+
+func TestGrokProxyHonorsCooldown(t *testing.T) {
+	requests := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+
+	proxy := NewGrokProxy(upstream.URL, "xai-key")
+	proxy.SetCooldownFile(filepath.Join(t.TempDir(), "ratelimits.json"))
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		proxy.handle(w, httptest.NewRequest("POST", "/v1/messages", strings.NewReader(`{"model":"grok-4"}`)))
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected 429, got %d", w.Code)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected one upstream request, got %d", requests)
+	}
+}
+
+func TestRenderCooldowns(t *testing.T) {
+	now := time.Now()
+	var buf bytes.Buffer
+	renderCooldowns(map[string]Cooldown{"grok": {Until: now.Add(-time.Second)}}, now, &buf)
+	if !strings.Contains(buf.String(), "No provider is cooling down") {
+		t.Errorf("Expected expired cool-downs hidden, got %q", buf.String())
+	}
+	buf.Reset()
+	renderCooldowns(map[string]Cooldown{"openai": {Until: now.Add(10 * time.Second), Status: 429, PID: 42}}, now, &buf)
+	if !strings.Contains(buf.String(), "openai rate limited (HTTP 429, pid 42) - resets in 10.0s") {
+		t.Errorf("Unexpected output %q", buf.String())
+	}
+}
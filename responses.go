@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Upstream protocols of the translating proxy (Backend.Protocol)
+const (
+	// protocolOpenAIChat is OpenAI chat completions, spoken by Ollama and
+	// most OpenAI-compatible servers
+	protocolOpenAIChat = "openai-chat"
+	// protocolOpenAIResponses is OpenAI's Responses API, which the o-series
+	// and newer GPT models are served through
+	protocolOpenAIResponses = "openai-responses"
+)
+
+// ResponsesRequest is an OpenAI Responses API request. Requests are sent with
+// store off, so every turn carries the full conversation.
+type ResponsesRequest struct {
+	Model           string          `json:"model"`
+	Instructions    string          `json:"instructions,omitempty"`
+	Input           []ResponsesItem `json:"input"`
+	Tools           []ResponsesTool `json:"tools,omitempty"`
+	ToolChoice      interface{}     `json:"tool_choice,omitempty"`
+	MaxOutputTokens int             `json:"max_output_tokens,omitempty"`
+	Temperature     *float64        `json:"temperature,omitempty"`
+	TopP            *float64        `json:"top_p,omitempty"`
+	Stream          bool            `json:"stream,omitempty"`
+	Store           bool            `json:"store"`
+}
+
+// ResponsesItem is an input or output item: a message, a function call or a
+// function call's output
+type ResponsesItem struct {
+	Type      string             `json:"type"`
+	ID        string             `json:"id,omitempty"`
+	Role      string             `json:"role,omitempty"`
+	Content   []ResponsesContent `json:"content,omitempty"`
+	CallID    string             `json:"call_id,omitempty"`
+	Name      string             `json:"name,omitempty"`
+	Arguments string             `json:"arguments,omitempty"`
+	Output    *string            `json:"output,omitempty"` // set on function_call_output, even when empty
+}
+
+// ResponsesContent is a message content part: input_text, output_text,
+// input_image or refusal
+type ResponsesContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Refusal  string `json:"refusal,omitempty"`
+}
+
+// ResponsesTool is a function tool definition
+type ResponsesTool struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ResponsesResponse is a Responses API response
+type ResponsesResponse struct {
+	ID                string          `json:"id"`
+	Model             string          `json:"model"`
+	Status            string          `json:"status"` // completed, incomplete, failed
+	Output            []ResponsesItem `json:"output"`
+	Usage             *ResponsesUsage `json:"usage,omitempty"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type ResponsesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// ResponsesStreamEvent is one server-sent event of a streamed response
+type ResponsesStreamEvent struct {
+	Type        string             `json:"type"`
+	OutputIndex int                `json:"output_index"`
+	Delta       string             `json:"delta"`
+	Item        *ResponsesItem     `json:"item"`
+	Response    *ResponsesResponse `json:"response"`
+	Message     string             `json:"message"` // error events
+}
+
+// anthropicTool is a tool definition in an Anthropic request. Server tools
+// such as web search have no input schema and are not forwarded.
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// anthropicBlock is a content block of an Anthropic message
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"` // tool_result: string or blocks
+	IsError   bool            `json:"is_error"`
+	Source    *struct {
+		Type      string `json:"type"`
+		MediaType string `json:"media_type"`
+		Data      string `json:"data"`
+		URL       string `json:"url"`
+	} `json:"source"`
+}
+
+// messageBlocks returns a message's content as blocks; string content is a
+// single text block
+func messageBlocks(content interface{}) []anthropicBlock {
+	if s, ok := content.(string); ok {
+		return []anthropicBlock{{Type: "text", Text: s}}
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil
+	}
+	var blocks []anthropicBlock
+	json.Unmarshal(data, &blocks)
+	return blocks
+}
+
+// toolResultText flattens a tool_result's content to text
+func toolResultText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var blocks []anthropicBlock
+	json.Unmarshal(raw, &blocks)
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// isReasoningModel reports whether model is an o-series reasoning model,
+// which rejects sampling parameters
+func isReasoningModel(model string) bool {
+	return len(model) > 1 && model[0] == 'o' && model[1] >= '0' && model[1] <= '9'
+}
+
+// anthropicToResponses translates an Anthropic messages request into a
+// Responses API request. Tool uses become function_call items and tool
+// results function_call_output items, in conversation order.
+func anthropicToResponses(req AnthropicRequest, model, systemText string) ResponsesRequest {
+	out := ResponsesRequest{
+		Model:           model,
+		Instructions:    systemText,
+		MaxOutputTokens: req.MaxTokens,
+		Stream:          req.Stream,
+	}
+	if !isReasoningModel(model) {
+		out.Temperature, out.TopP = req.Temperature, req.TopP
+	}
+
+	for _, raw := range req.Tools {
+		var t anthropicTool
+		if json.Unmarshal(raw, &t) != nil || t.Name == "" || len(t.InputSchema) == 0 {
+			continue
+		}
+		out.Tools = append(out.Tools, ResponsesTool{Type: "function", Name: t.Name, Description: t.Description, Parameters: t.InputSchema})
+	}
+	if len(req.ToolChoice) > 0 && len(out.Tools) > 0 {
+		var choice struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		}
+		json.Unmarshal(req.ToolChoice, &choice)
+		switch choice.Type {
+		case "auto", "none":
+			out.ToolChoice = choice.Type
+		case "any":
+			out.ToolChoice = "required"
+		case "tool":
+			out.ToolChoice = map[string]string{"type": "function", "name": choice.Name}
+		}
+	}
+
+	for _, msg := range req.Messages {
+		textType := "input_text"
+		if msg.Role == "assistant" {
+			textType = "output_text"
+		}
+		var parts []ResponsesContent
+		flush := func() {
+			if len(parts) > 0 {
+				out.Input = append(out.Input, ResponsesItem{Type: "message", Role: msg.Role, Content: parts})
+				parts = nil
+			}
+		}
+		for _, b := range messageBlocks(msg.Content) {
+			switch b.Type {
+			case "text":
+				if b.Text != "" {
+					parts = append(parts, ResponsesContent{Type: textType, Text: b.Text})
+				}
+			case "image":
+				if b.Source == nil {
+					continue
+				}
+				url := b.Source.URL
+				if b.Source.Type == "base64" {
+					url = "data:" + b.Source.MediaType + ";base64," + b.Source.Data
+				}
+				parts = append(parts, ResponsesContent{Type: "input_image", ImageURL: url})
+			case "tool_use":
+				flush()
+				args := string(b.Input)
+				if args == "" || args == "null" {
+					args = "{}"
+				}
+				out.Input = append(out.Input, ResponsesItem{Type: "function_call", CallID: b.ID, Name: b.Name, Arguments: args})
+			case "tool_result":
+				flush()
+				output := toolResultText(b.Content)
+				if b.IsError {
+					output = "Error: " + output
+				}
+				out.Input = append(out.Input, ResponsesItem{Type: "function_call_output", CallID: b.ToolUseID, Output: &output})
+			}
+		}
+		flush()
+	}
+	return out
+}
+
+// responsesStopReason maps how a response ended to an Anthropic stop reason
+func responsesStopReason(resp *ResponsesResponse, calledTool bool) string {
+	switch {
+	case calledTool:
+		return "tool_use"
+	case resp != nil && resp.Status == "incomplete" && resp.IncompleteDetails != nil && resp.IncompleteDetails.Reason == "max_output_tokens":
+		return "max_tokens"
+	}
+	return "end_turn"
+}
+
+// responsesToAnthropic translates a Responses API response. Reasoning items
+// are dropped.
+func responsesToAnthropic(resp ResponsesResponse, originalModel string) AnthropicResponse {
+	out := AnthropicResponse{
+		ID:      generateID(),
+		Type:    "message",
+		Role:    "assistant",
+		Model:   originalModel,
+		Content: []AnthropicContent{},
+	}
+	if resp.Usage != nil {
+		out.Usage = AnthropicUsage{InputTokens: resp.Usage.InputTokens, OutputTokens: resp.Usage.OutputTokens}
+	}
+	calledTool := false
+	for _, item := range resp.Output {
+		switch item.Type {
+		case "message":
+			var text strings.Builder
+			for _, c := range item.Content {
+				text.WriteString(c.Text + c.Refusal)
+			}
+			if text.Len() > 0 {
+				out.Content = append(out.Content, AnthropicContent{Type: "text", Text: text.String()})
+			}
+		case "function_call":
+			calledTool = true
+			out.Content = append(out.Content, AnthropicContent{Type: "tool_use", ID: item.CallID, Name: item.Name, Input: toolInput(item.Arguments)})
+		}
+	}
+	out.StopReason = responsesStopReason(&resp, calledTool)
+	return out
+}
+
+// toolInput returns function call arguments as a JSON object
+func toolInput(arguments string) json.RawMessage {
+	if json.Valid([]byte(arguments)) && strings.HasPrefix(strings.TrimSpace(arguments), "{") {
+		return json.RawMessage(arguments)
+	}
+	return json.RawMessage("{}")
+}
+
+// handleResponses relays a message request to a Responses API upstream and
+// returns the answer text and usage
+func (p *OllamaProxy) handleResponses(w http.ResponseWriter, anthReq AnthropicRequest, baseURL, upstreamModel string) (string, AnthropicUsage) {
+	systemText := p.backendPrompt.Wrap(appendSystemText(anthReq.GetSystemText(), p.systemPrimer))
+	body, err := json.Marshal(anthropicToResponses(anthReq, upstreamModel, systemText))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", AnthropicUsage{}
+	}
+	req, err := http.NewRequest("POST", baseURL+"/responses", bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", AnthropicUsage{}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := p.authorize(req, baseURL); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", AnthropicUsage{}
+	}
+
+	client := p.secureClient
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if anthReq.Stream {
+		client = p.streamingClient()
+		req = req.WithContext(ctx)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		if isTimeout(err) {
+			p.writeTimeout(w)
+			return "", AnthropicUsage{}
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", AnthropicUsage{}
+	}
+	defer resp.Body.Close()
+	p.observeLatency(start)
+
+	if resp.StatusCode != http.StatusOK {
+		p.writeUpstreamError(w, resp)
+		return "", AnthropicUsage{}
+	}
+
+	if !anthReq.Stream {
+		var rr ResponsesResponse
+		if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+			if isTimeout(err) {
+				p.writeTimeout(w)
+				return "", AnthropicUsage{}
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return "", AnthropicUsage{}
+		}
+		if rr.Model != "" && p.observeModel != nil {
+			p.observeModel(upstreamModel, rr.Model)
+		}
+		anthResp := responsesToAnthropic(rr, anthReq.Model)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(anthResp)
+		return anthropicText(anthResp), anthResp.Usage
+	}
+	stream := newStallReader(resp.Body, p.latencyBudget, cancel)
+	text, usage := p.relayResponsesStream(w, stream, anthReq.Model, upstreamModel)
+	if stream.Stop() {
+		writeStreamTimeout(w, latencyBudgetError(p.upstreamBackend(), p.latencyBudget))
+	}
+	return text, usage
+}
+
+// anthropicText joins a response's text blocks
+func anthropicText(resp AnthropicResponse) string {
+	var text strings.Builder
+	for _, c := range resp.Content {
+		text.WriteString(c.Text)
+	}
+	return text.String()
+}
+
+// relayResponsesStream translates Responses API stream events into Anthropic
+// stream events. Each message or function call output item becomes a content
+// block, numbered in the order the items start.
+func (p *OllamaProxy) relayResponsesStream(w http.ResponseWriter, body io.Reader, originalModel, upstreamModel string) (string, AnthropicUsage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	writeSSE(w, AnthropicStreamEvent{
+		Type:    "message_start",
+		Message: &AnthropicResponse{ID: generateID(), Type: "message", Role: "assistant", Model: originalModel, Content: []AnthropicContent{}},
+	})
+	flush()
+
+	blocks := make(map[int]int) // output index -> content block index
+	var text strings.Builder
+	var usage AnthropicUsage
+	var final *ResponsesResponse
+	calledTool := false
+	startBlock := func(outputIndex int, block AnthropicContent) int {
+		index := len(blocks)
+		blocks[outputIndex] = index
+		writeSSE(w, AnthropicStreamEvent{Type: "content_block_start", Index: index, ContentBlock: &block})
+		return index
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event ResponsesStreamEvent
+		if json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event) != nil {
+			continue
+		}
+		switch event.Type {
+		case "response.created":
+			if event.Response != nil && event.Response.Model != "" && p.observeModel != nil {
+				p.observeModel(upstreamModel, event.Response.Model)
+			}
+		case "response.output_item.added":
+			if event.Item == nil {
+				continue
+			}
+			switch event.Item.Type {
+			case "message":
+				startBlock(event.OutputIndex, AnthropicContent{Type: "text"})
+			case "function_call":
+				calledTool = true
+				startBlock(event.OutputIndex, AnthropicContent{Type: "tool_use", ID: event.Item.CallID, Name: event.Item.Name, Input: json.RawMessage("{}")})
+			}
+		case "response.output_text.delta", "response.refusal.delta":
+			index, ok := blocks[event.OutputIndex]
+			if !ok {
+				index = startBlock(event.OutputIndex, AnthropicContent{Type: "text"})
+			}
+			text.WriteString(event.Delta)
+			writeSSE(w, AnthropicStreamEvent{Type: "content_block_delta", Index: index, Delta: &AnthropicDelta{Type: "text_delta", Text: event.Delta}})
+		case "response.function_call_arguments.delta":
+			if index, ok := blocks[event.OutputIndex]; ok {
+				writeSSE(w, AnthropicStreamEvent{Type: "content_block_delta", Index: index, Delta: &AnthropicDelta{Type: "input_json_delta", PartialJSON: event.Delta}})
+			}
+		case "response.output_item.done":
+			if index, ok := blocks[event.OutputIndex]; ok {
+				writeSSE(w, AnthropicStreamEvent{Type: "content_block_stop", Index: index})
+			}
+		case "response.completed", "response.incomplete":
+			final = event.Response
+		case "response.failed", "error":
+			message := event.Message
+			if event.Response != nil && event.Response.Error != nil {
+				message = event.Response.Error.Message
+			}
+			writeSSEError(w, "api_error", "Upstream response failed: "+message)
+			flush()
+			return text.String(), usage
+		}
+		flush()
+	}
+
+	if final != nil && final.Usage != nil {
+		usage = AnthropicUsage{InputTokens: final.Usage.InputTokens, OutputTokens: final.Usage.OutputTokens}
+	}
+	writeSSE(w, AnthropicStreamEvent{
+		Type:  "message_delta",
+		Delta: &AnthropicDelta{StopReason: responsesStopReason(final, calledTool)},
+		Usage: &usage,
+	})
+	writeSSE(w, AnthropicStreamEvent{Type: "message_stop"})
+	flush()
+	return text.String(), usage
+}
+
+// writeSSEError sends an Anthropic error event on an open stream
+func writeSSEError(w http.ResponseWriter, kind, message string) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"type": kind, "message": message},
+	})
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicToResponses(t *testing.T) {
+	temp := 0.5
+	var req AnthropicRequest
+	err := json.Unmarshal([]byte(`{
+		"model": "claude-sonnet",
+		"max_tokens": 1000,
+		"tools": [
+			{"name": "Read", "description": "Read a file", "input_schema": {"type": "object", "properties": {"path": {"type": "string"}}}},
+			{"type": "web_search_20250305", "name": "web_search"}
+		],
+		"tool_choice": {"type": "any"},
+		"messages": [
+			{"role": "user", "content": "Open main.go"},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "Reading it."},
+				{"type": "tool_use", "id": "call_1", "name": "Read", "input": {"path": "main.go"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "call_1", "content": [{"type": "text", "text": "package main"}]},
+				{"type": "tool_result", "tool_use_id": "call_2", "content": "", "is_error": true},
+				{"type": "text", "text": "Summarize it"}
+			]}
+		]
+	}`), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Temperature = &temp
+
+	out := anthropicToResponses(req, "gpt-4o", "Be brief")
+	if out.Instructions != "Be brief" || out.MaxOutputTokens != 1000 || out.Store {
+		t.Errorf("Unexpected request settings %+v", out)
+	}
+	if out.Temperature == nil || *out.Temperature != 0.5 {
+		t.Error("Expected temperature forwarded for a GPT model")
+	}
+	if len(out.Tools) != 1 || out.Tools[0].Name != "Read" || out.Tools[0].Type != "function" {
+		t.Errorf("Expected only the function tool, got %+v", out.Tools)
+	}
+	if out.ToolChoice != "required" {
+		t.Errorf("Expected tool_choice any to become required, got %v", out.ToolChoice)
+	}
+
+	wantTypes := []string{"message", "message", "function_call", "function_call_output", "function_call_output", "message"}
+	if len(out.Input) != len(wantTypes) {
+		t.Fatalf("Expected %d input items, got %+v", len(wantTypes), out.Input)
+	}
+	for i, want := range wantTypes {
+		if out.Input[i].Type != want {
+			t.Errorf("Item %d: expected %s, got %s", i, want, out.Input[i].Type)
+		}
+	}
+	if c := out.Input[1].Content; len(c) != 1 || c[0].Type != "output_text" {
+		t.Errorf("Expected assistant text as output_text, got %+v", c)
+	}
+	if call := out.Input[2]; call.CallID != "call_1" || call.Name != "Read" || call.Arguments != `{"path":"main.go"}` {
+		t.Errorf("Unexpected function call %+v", call)
+	}
+	if res := out.Input[3]; res.CallID != "call_1" || res.Output == nil || *res.Output != "package main" {
+		t.Errorf("Unexpected function call output %+v", res)
+	}
+	if res := out.Input[4]; res.Output == nil || *res.Output != "Error: " {
+		t.Errorf("Expected an error result marked, got %+v", res)
+	}
+
+	// An empty tool output must still be sent
+	data, _ := json.Marshal(out.Input[4])
+	if !strings.Contains(string(data), `"output":"Error: "`) {
+		t.Errorf("Unexpected encoding %s", data)
+	}
+
+	if o1 := anthropicToResponses(req, "o1", ""); o1.Temperature != nil {
+		t.Error("Expected temperature dropped for an o-series model")
+	}
+}
+
+func TestResponsesToAnthropic(t *testing.T) {
+	var resp ResponsesResponse
+	json.Unmarshal([]byte(`{
+		"id": "resp_1", "model": "gpt-4o-2024-08-06", "status": "completed",
+		"output": [
+			{"type": "reasoning", "id": "rs_1"},
+			{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "Let me check."}]},
+			{"type": "function_call", "call_id": "call_9", "name": "Bash", "arguments": "{\"command\":\"ls\"}"}
+		],
+		"usage": {"input_tokens": 120, "output_tokens": 30}
+	}`), &resp)
+
+	out := responsesToAnthropic(resp, "claude-sonnet")
+	if out.Model != "claude-sonnet" || out.StopReason != "tool_use" {
+		t.Errorf("Unexpected response %+v", out)
+	}
+	if out.Usage.InputTokens != 120 || out.Usage.OutputTokens != 30 {
+		t.Errorf("Unexpected usage %+v", out.Usage)
+	}
+	if len(out.Content) != 2 || out.Content[0].Text != "Let me check." {
+		t.Fatalf("Unexpected content %+v", out.Content)
+	}
+	if tool := out.Content[1]; tool.Type != "tool_use" || tool.ID != "call_9" || string(tool.Input) != `{"command":"ls"}` {
+		t.Errorf("Unexpected tool use %+v", tool)
+	}
+
+	resp = ResponsesResponse{Status: "incomplete"}
+	json.Unmarshal([]byte(`{"status":"incomplete","incomplete_details":{"reason":"max_output_tokens"}}`), &resp)
+	if out := responsesToAnthropic(resp, "m"); out.StopReason != "max_tokens" {
+		t.Errorf("Expected max_tokens, got %s", out.StopReason)
+	}
+}
+
+func TestProxyResponsesNonStreaming(t *testing.T) {
+	var auth string
+	var got ResponsesRequest
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/responses" {
+			t.Errorf("Expected /responses, got %s", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"gpt-4o","status":"completed","output":[{"type":"message","content":[{"type":"output_text","text":"hi"}]}],"usage":{"input_tokens":5,"output_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	proxy := NewOllamaProxy(upstream.URL, map[string]string{})
+	proxy.SetProtocol(protocolOpenAIResponses)
+	proxy.SetUpstreamAuth("openai", nil, "sk-test")
+	var recorded AnthropicUsage
+	proxy.SetUsageRecorder(func(model string, usage AnthropicUsage) { recorded = usage })
+
+	body := []byte(`{"model":"gpt-4o","max_tokens":50,"messages":[{"role":"user","content":"hello"}]}`)
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if auth != "Bearer sk-test" {
+		t.Errorf("Expected the upstream key as a bearer token, got %q", auth)
+	}
+	if got.Model != "gpt-4o" || len(got.Input) != 1 {
+		t.Errorf("Unexpected upstream request %+v", got)
+	}
+	var resp AnthropicResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Content) != 1 || resp.Content[0].Text != "hi" || resp.StopReason != "end_turn" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if recorded.InputTokens != 5 || recorded.OutputTokens != 1 {
+		t.Errorf("Expected usage recorded, got %+v", recorded)
+	}
+}
+
+func TestProxyResponsesStreaming(t *testing.T) {
+	events := []string{
+		`{"type":"response.created","response":{"id":"resp_1","model":"gpt-4o"}}`,
+		`{"type":"response.output_item.added","output_index":0,"item":{"type":"reasoning"}}`,
+		`{"type":"response.output_item.added","output_index":1,"item":{"type":"message"}}`,
+		`{"type":"response.output_text.delta","output_index":1,"delta":"Listing"}`,
+		`{"type":"response.output_item.done","output_index":1,"item":{"type":"message"}}`,
+		`{"type":"response.output_item.added","output_index":2,"item":{"type":"function_call","call_id":"call_1","name":"Bash"}}`,
+		`{"type":"response.function_call_arguments.delta","output_index":2,"delta":"{\"command\":"}`,
+		`{"type":"response.function_call_arguments.delta","output_index":2,"delta":"\"ls\"}"}`,
+		`{"type":"response.output_item.done","output_index":2,"item":{"type":"function_call"}}`,
+		`{"type":"response.completed","response":{"status":"completed","usage":{"input_tokens":40,"output_tokens":12}}}`,
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ResponsesRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Error("Expected a streaming upstream request")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			var typed struct{ Type string }
+			json.Unmarshal([]byte(e), &typed)
+			w.Write([]byte("event: " + typed.Type + "\ndata: " + e + "\n\n"))
+		}
+	}))
+	defer upstream.Close()
+
+	proxy := NewOllamaProxy(upstream.URL, map[string]string{})
+	proxy.SetProtocol(protocolOpenAIResponses)
+	body := []byte(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"list files"}]}`)
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+
+	var got []AnthropicStreamEvent
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var e AnthropicStreamEvent
+			json.Unmarshal([]byte(data), &e)
+			got = append(got, e)
+		}
+	}
+	wantTypes := []string{"message_start", "content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop", "message_delta", "message_stop"}
+	if len(got) != len(wantTypes) {
+		t.Fatalf("Expected %d events, got %d: %s", len(wantTypes), len(got), w.Body.String())
+	}
+	for i, want := range wantTypes {
+		if got[i].Type != want {
+			t.Errorf("Event %d: expected %s, got %s", i, want, got[i].Type)
+		}
+	}
+	if tool := got[4]; tool.Index != 1 || tool.ContentBlock.Type != "tool_use" || tool.ContentBlock.ID != "call_1" || tool.ContentBlock.Name != "Bash" {
+		t.Errorf("Unexpected tool block start %+v", tool.ContentBlock)
+	}
+	if got[5].Delta.Type != "input_json_delta" || got[5].Delta.PartialJSON+got[6].Delta.PartialJSON != `{"command":"ls"}` {
+		t.Errorf("Unexpected tool input deltas %+v %+v", got[5].Delta, got[6].Delta)
+	}
+	if final := got[8]; final.Delta.StopReason != "tool_use" || final.Usage.OutputTokens != 12 {
+		t.Errorf("Unexpected message_delta %+v %+v", final.Delta, final.Usage)
+	}
+}
+
+func TestProxyResponsesStreamFailure(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"type":"response.failed","response":{"status":"failed","error":{"message":"server overloaded"}}}` + "\n\n"))
+	}))
+	defer upstream.Close()
+
+	proxy := NewOllamaProxy(upstream.URL, map[string]string{})
+	proxy.SetProtocol(protocolOpenAIResponses)
+	body := []byte(`{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	w := httptest.NewRecorder()
+	proxy.handleMessages(w, httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+	if !strings.Contains(w.Body.String(), `"type":"error"`) || !strings.Contains(w.Body.String(), "server overloaded") {
+		t.Errorf("Expected an error event, got %s", w.Body.String())
+	}
+}
+
+func TestAppendUsageRecordOpusTier(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	openai := backends["openai"]
+	appendUsageRecord(cfg, UsageRecord{Backend: "openai", Model: "o1", Tier: "opus", InputTokens: 1000000, OutputTokens: 1000000})
+	appendUsageRecord(cfg, UsageRecord{Backend: "openai", Model: "gpt-4o", Tier: "sonnet", InputTokens: 1000000, OutputTokens: 1000000})
+
+	records := loadUsageRecords(cfg)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if want := openai.OpusInputPrice + openai.OpusOutputPrice; records[0].CostUSD != want {
+		t.Errorf("Expected the opus record priced at $%.2f, got $%.2f", want, records[0].CostUSD)
+	}
+	if want := openai.InputPrice + openai.OutputPrice; records[1].CostUSD != want {
+		t.Errorf("Expected the sonnet record priced at $%.2f, got $%.2f", want, records[1].CostUSD)
+	}
+}
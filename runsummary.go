@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Time limit notices and shutdown
+const (
+	runLimitWarning = 5 * time.Minute // notice this long before the limit
+	runStopGrace    = 10 * time.Second
+)
+
+// RunSummary describes one Claude Code run, from launch to exit
+type RunSummary struct {
+	Backend      string             `json:"backend"`
+	Start        time.Time          `json:"start"`
+	End          time.Time          `json:"end"`
+	Prompts      int                `json:"prompts,omitempty"` // counted by the UserPromptSubmit hook
+	Requests     int                `json:"requests"`          // upstream requests, tool calls included
+	InputTokens  int64              `json:"input_tokens"`
+	OutputTokens int64              `json:"output_tokens"`
+	CostUSD      float64            `json:"cost_usd"`
+	CostByTier   map[string]float64 `json:"cost_by_tier,omitempty"`
+	TimeLimit    string             `json:"time_limit,omitempty"`
+	TimedOut     bool               `json:"timed_out,omitempty"`
+}
+
+// Duration is how long the run lasted
+func (s RunSummary) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// parseForFlag extracts "--for d" or "--for=d" from args
+func parseForFlag(args []string) ([]string, time.Duration, error) {
+	var rest []string
+	var value string
+	found := false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--for":
+			if i+1 >= len(args) {
+				return nil, 0, errors.New("--for requires a duration such as 2h")
+			}
+			value = args[i+1]
+			found = true
+			i++
+		case strings.HasPrefix(args[i], "--for="):
+			value = strings.TrimPrefix(args[i], "--for=")
+			found = true
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	if !found {
+		return rest, 0, nil
+	}
+	d, err := parseTimeout(value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid --for value '%s': %v", value, err)
+	}
+	return rest, d, nil
+}
+
+// runTimer stops a Claude Code process once its time limit passes
+type runTimer struct {
+	expired atomic.Bool
+	timers  []*time.Timer
+}
+
+// startRunTimer warns shortly before limit and then sends SIGTERM, followed
+// by SIGKILL if the process has not exited after runStopGrace
+func startRunTimer(proc *os.Process, limit time.Duration) *runTimer {
+	t := &runTimer{}
+	notice := func(msg string) {
+		fmt.Fprintln(os.Stderr, styleWarning.Render("\n[promptops] "+msg))
+	}
+	if limit > 2*runLimitWarning {
+		t.timers = append(t.timers, time.AfterFunc(limit-runLimitWarning, func() {
+			notice(fmt.Sprintf("%s left of the %s time limit", formatElapsed(runLimitWarning), formatElapsed(limit)))
+		}))
+	}
+	t.timers = append(t.timers, time.AfterFunc(limit, func() {
+		t.expired.Store(true)
+		notice(fmt.Sprintf("Time limit of %s reached, stopping Claude Code", formatElapsed(limit)))
+		proc.Signal(syscall.SIGTERM)
+	}))
+	t.timers = append(t.timers, time.AfterFunc(limit+runStopGrace, func() {
+		proc.Kill()
+	}))
+	return t
+}
+
+// Stop cancels pending notices and reports whether the limit was reached
+func (t *runTimer) Stop() bool {
+	for _, timer := range t.timers {
+		timer.Stop()
+	}
+	return t.expired.Load()
+}
+
+// runClaudeProcess runs cmd, enforcing limit when it is positive
+func runClaudeProcess(cmd *exec.Cmd, limit time.Duration) (timedOut bool, err error) {
+	if limit <= 0 {
+		return false, cmd.Run()
+	}
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+	timer := startRunTimer(cmd.Process, limit)
+	err = cmd.Wait()
+	return timer.Stop(), err
+}
+
+// summarizeRun totals the usage recorded for a run. Records are matched by
+// session when one is active, otherwise by backend and the process pid that
+// ran the launch, so another terminal on the same backend is not counted.
+func summarizeRun(records usageSource, backend, sessionID string, pid int, start, end time.Time) RunSummary {
+	s := RunSummary{Backend: backend, Start: start, End: end, CostByTier: make(map[string]float64)}
+	records(func(r UsageRecord) {
+		if r.Timestamp.Before(start) || r.Timestamp.After(end) {
+			return
+		}
+		if sessionID != "" && r.SessionID != sessionID {
+			return
+		}
+		if sessionID == "" && (r.Backend != backend || r.PID != pid) {
+			return
+		}
+		s.Requests++
+		s.InputTokens += r.InputTokens
+		s.OutputTokens += r.OutputTokens
+		s.CostUSD += r.CostUSD
+		tier := r.Tier
+		if tier == "" {
+			tier = "unknown"
+		}
+		s.CostByTier[tier] += r.CostUSD
+	})
+	return s
+}
+
+// sessionPromptCount returns the prompts counted for a session so far, or 0
+// without one
+func sessionPromptCount(cfg *Config, sessionID string) int {
+	if sessionID == "" {
+		return 0
+	}
+	for _, s := range loadSessions(cfg) {
+		if s != nil && s.ID == sessionID {
+			return s.PromptCount
+		}
+	}
+	return 0
+}
+
+// countSessionPrompt counts a submitted prompt on the current session
+func countSessionPrompt(cfg *Config) error {
+	session := getCurrentSession(cfg)
+	if session == nil {
+		return nil
+	}
+	return updateSession(cfg, session.ID, func(s *Session) {
+		s.PromptCount++
+		s.LastActive = time.Now()
+	})
+}
+
+// formatElapsed renders a run duration as 1h05m, 12m30s or 45s
+func formatElapsed(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, sec := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, sec)
+	default:
+		return fmt.Sprintf("%ds", sec)
+	}
+}
+
+// renderRunSummary prints the exit summary of a run
+func renderRunSummary(s RunSummary, proxied bool) {
+	fmt.Println()
+	fmt.Println(styleSection.Render("SESSION SUMMARY"))
+	duration := formatElapsed(s.Duration())
+	if s.TimedOut {
+		duration += styleWarning.Render(" (time limit reached)")
+	} else if s.TimeLimit != "" {
+		duration += styleMuted.Render(" of " + s.TimeLimit)
+	}
+	fmt.Printf("  %-10s %s\n", "Duration:", duration)
+	if s.Requests == 0 {
+		if proxied {
+			fmt.Printf("  %-10s %s\n", "Usage:", "no requests recorded")
+		} else {
+			fmt.Printf("  %-10s %s\n", "Usage:", styleMuted.Render("not tracked for direct backends; see 'promptops usage'"))
+		}
+		fmt.Println()
+		return
+	}
+	if s.Prompts > 0 {
+		fmt.Printf("  %-10s %d\n", "Prompts:", s.Prompts)
+	}
+	fmt.Printf("  %-10s %d\n", "Requests:", s.Requests)
+	fmt.Printf("  %-10s %s in / %s out\n", "Tokens:", formatNumber(s.InputTokens), formatNumber(s.OutputTokens))
+	fmt.Printf("  %-10s %s\n", "Cost:", formatCurrency(s.CostUSD))
+	for _, tier := range append(append([]string{}, modelTiers...), "unknown") {
+		if cost, ok := s.CostByTier[tier]; ok {
+			fmt.Printf("    %-8s %s\n", tier, formatCurrency(cost))
+		}
+	}
+	fmt.Println()
+}
+
+// recordRunSummary stores the run on its session and adds its cost. Prompts
+// are counted by the hook as they are submitted.
+func recordRunSummary(cfg *Config, sessionID string, s RunSummary) error {
+	if sessionID == "" {
+		return nil
+	}
+	return updateSession(cfg, sessionID, func(session *Session) {
+		session.LastActive = s.End
+		session.TotalCost += s.CostUSD
+		session.LastRun = &s
+	})
+}
+
+// finishRun prints the exit summary and records it on the session and in
+// the audit log. prompts is the session's prompt count at launch.
+func finishRun(cfg *Config, be Backend, sessionID string, prompts int, start time.Time, limit time.Duration, timedOut bool) {
+	s := summarizeRun(ledgerUsage(cfg), be.Name, sessionID, os.Getpid(), start, time.Now())
+	s.Prompts = sessionPromptCount(cfg, sessionID) - prompts
+	s.TimedOut = timedOut
+	if limit > 0 {
+		s.TimeLimit = formatElapsed(limit)
+	}
+	_, proxied := launchProxyPorts[be.Name]
+	renderRunSummary(s, proxied)
+	if err := recordRunSummary(cfg, sessionID, s); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update session: %v\n", err)
+	}
+	auditLog(cfg, fmt.Sprintf("RUN_END: %s %s, %d requests, %s", be.Name, formatElapsed(s.Duration()), s.Requests, formatCurrency(s.CostUSD)))
+}
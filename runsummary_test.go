@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseForFlag(t *testing.T) {
+	rest, d, err := parseForFlag([]string{"--permission-mode", "plan", "--for", "2h"})
+	if err != nil || d != 2*time.Hour || len(rest) != 2 || rest[1] != "plan" {
+		t.Errorf("Unexpected result %v %s (err=%v)", rest, d, err)
+	}
+	if _, d, _ := parseForFlag([]string{"--for=90"}); d != 90*time.Second {
+		t.Errorf("Expected seconds, got %s", d)
+	}
+	if _, d, _ := parseForFlag(nil); d != 0 {
+		t.Errorf("Expected no limit, got %s", d)
+	}
+	for _, bad := range [][]string{{"--for"}, {"--for", "soon"}, {"--for=-1h"}} {
+		if _, _, err := parseForFlag(bad); err == nil {
+			t.Errorf("Expected error for %v", bad)
+		}
+	}
+}
+
+func TestSummarizeRun(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	end := time.Now()
+	records := []UsageRecord{
+		{Timestamp: start.Add(-time.Minute), SessionID: "s1", Backend: "ollama", CostUSD: 9},
+		{Timestamp: start.Add(time.Minute), SessionID: "s1", Backend: "ollama", Tier: "haiku", InputTokens: 100, OutputTokens: 10, CostUSD: 0.5},
+		{Timestamp: start.Add(2 * time.Minute), SessionID: "s1", Backend: "ollama", Tier: "sonnet", InputTokens: 200, OutputTokens: 20, CostUSD: 1.5},
+		{Timestamp: start.Add(3 * time.Minute), SessionID: "s1", Backend: "ollama", CostUSD: 0.25},
+		{Timestamp: start.Add(4 * time.Minute), SessionID: "s2", Backend: "ollama", CostUSD: 7},
+	}
+
+	s := summarizeRun(usageRecords(records), "ollama", "s1", 0, start, end)
+	if s.Requests != 3 || s.InputTokens != 300 || s.OutputTokens != 30 || s.CostUSD != 2.25 {
+		t.Errorf("Unexpected totals: %+v", s)
+	}
+	if s.CostByTier["haiku"] != 0.5 || s.CostByTier["sonnet"] != 1.5 || s.CostByTier["unknown"] != 0.25 {
+		t.Errorf("Unexpected cost by tier: %v", s.CostByTier)
+	}
+
+	// Without a session, records are matched by backend and launching process
+	records = []UsageRecord{
+		{Timestamp: start.Add(time.Minute), Backend: "ollama", PID: 100, CostUSD: 1},
+		{Timestamp: start.Add(2 * time.Minute), Backend: "ollama", PID: 100, CostUSD: 1},
+		{Timestamp: start.Add(3 * time.Minute), Backend: "ollama", PID: 200, CostUSD: 5},
+		{Timestamp: start.Add(4 * time.Minute), Backend: "grok", PID: 100, CostUSD: 5},
+	}
+	if s := summarizeRun(usageRecords(records), "ollama", "", 100, start, end); s.Requests != 2 || s.CostUSD != 2 {
+		t.Errorf("Expected only this launch's 2 requests, got %+v", s)
+	}
+	if s := summarizeRun(usageRecords(records), "openai", "", 100, start, end); s.Requests != 0 {
+		t.Errorf("Expected no openai requests, got %d", s.Requests)
+	}
+}
+
+func TestFormatElapsed(t *testing.T) {
+	tests := map[time.Duration]string{
+		45 * time.Second:                "45s",
+		12*time.Minute + 30*time.Second: "12m30s",
+		time.Hour + 5*time.Minute:       "1h05m",
+	}
+	for d, want := range tests {
+		if got := formatElapsed(d); got != want {
+			t.Errorf("formatElapsed(%s) = %s, want %s", d, got, want)
+		}
+	}
+}
+
+func TestRunClaudeProcessTimeLimit(t *testing.T) {
+	started := time.Now()
+	timedOut, err := runClaudeProcess(exec.Command("sleep", "30"), 200*time.Millisecond)
+	if !timedOut || err == nil {
+		t.Errorf("Expected the process stopped at the limit (timedOut=%t, err=%v)", timedOut, err)
+	}
+	if time.Since(started) > 5*time.Second {
+		t.Errorf("Expected SIGTERM to stop the process promptly, took %s", time.Since(started))
+	}
+
+	timedOut, err = runClaudeProcess(exec.Command("true"), time.Minute)
+	if timedOut || err != nil {
+		t.Errorf("Expected a normal exit (timedOut=%t, err=%v)", timedOut, err)
+	}
+}
+
+func TestRecordRunSummary(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	now := time.Now()
+	saveSessions(cfg, []*Session{{ID: "s1", Name: "bugfix", PromptCount: 2, TotalCost: 1}})
+
+	run := RunSummary{Backend: "ollama", Start: now.Add(-time.Hour), End: now, Requests: 3, CostUSD: 0.5, TimedOut: true}
+	if err := recordRunSummary(cfg, "s1", run); err != nil {
+		t.Fatal(err)
+	}
+	s := loadSessions(cfg)[0]
+	// Requests are not prompts; the hook counts those
+	if s.PromptCount != 2 || s.TotalCost != 1.5 || s.LastRun == nil || !s.LastRun.TimedOut {
+		t.Errorf("Unexpected session after run: %+v", s)
+	}
+}
+
+func TestHookCountsPrompts(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	session, err := createSession(cfg, "bugfix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if code := runHookCheck(cfg, strings.NewReader(`{"hook_event_name":"UserPromptSubmit","prompt":"fix it"}`), io.Discard); code != 0 {
+			t.Fatalf("Expected the prompt allowed, got exit code %d", code)
+		}
+	}
+	// Tool calls are not prompts
+	runHookCheck(cfg, strings.NewReader(`{"hook_event_name":"PreToolUse","tool_name":"Bash"}`), io.Discard)
+
+	if got := sessionPromptCount(cfg, session.ID); got != 2 {
+		t.Errorf("Expected 2 prompts counted, got %d", got)
+	}
+}
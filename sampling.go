@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Generation parameters the local proxies enforce, configured with
+// NEXUS_<PARAM> for every backend and NEXUS_<PARAM>_<BACKEND> for one
+const (
+	samplingMaxTokens   = "MAX_TOKENS"
+	samplingTemperature = "TEMPERATURE"
+	samplingTopP        = "TOP_P"
+)
+
+// minThinkingBudget is the smallest extended thinking budget Anthropic
+// messages APIs accept
+const minThinkingBudget = 1024
+
+// SamplingDefaults are generation parameters for a backend. Proxies lower a
+// larger max_tokens, or set a missing one, to MaxTokens so a runaway agent
+// cannot ask an expensive backend for very long answers, and use
+// Temperature and TopP for requests that set none.
+type SamplingDefaults struct {
+	MaxTokens   int
+	Temperature *float64
+	TopP        *float64
+}
+
+// Empty reports whether nothing is configured
+func (s SamplingDefaults) Empty() bool {
+	return s.MaxTokens == 0 && s.Temperature == nil && s.TopP == nil
+}
+
+// merge returns s with unset parameters taken from fallback
+func (s SamplingDefaults) merge(fallback SamplingDefaults) SamplingDefaults {
+	if s.MaxTokens == 0 {
+		s.MaxTokens = fallback.MaxTokens
+	}
+	if s.Temperature == nil {
+		s.Temperature = fallback.Temperature
+	}
+	if s.TopP == nil {
+		s.TopP = fallback.TopP
+	}
+	return s
+}
+
+// String describes the parameters, e.g. "max_tokens<=8192, temperature=0.2"
+func (s SamplingDefaults) String() string {
+	var parts []string
+	if s.MaxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("max_tokens<=%d", s.MaxTokens))
+	}
+	if s.Temperature != nil {
+		parts = append(parts, "temperature="+strconv.FormatFloat(*s.Temperature, 'g', -1, 64))
+	}
+	if s.TopP != nil {
+		parts = append(parts, "top_p="+strconv.FormatFloat(*s.TopP, 'g', -1, 64))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// maxTokens returns the max_tokens to send for a request asking for n
+func (s SamplingDefaults) maxTokens(n int) int {
+	if s.MaxTokens > 0 && (n <= 0 || n > s.MaxTokens) {
+		return s.MaxTokens
+	}
+	return n
+}
+
+// Apply enforces s on req. It returns the max_tokens the client asked for
+// when it was lowered, or 0.
+func (s SamplingDefaults) Apply(req *AnthropicRequest) int {
+	lowered := 0
+	if n := s.maxTokens(req.MaxTokens); n != req.MaxTokens {
+		if req.MaxTokens > 0 {
+			lowered = req.MaxTokens
+		}
+		req.MaxTokens = n
+	}
+	if req.Temperature == nil {
+		req.Temperature = s.Temperature
+	}
+	if req.TopP == nil {
+		req.TopP = s.TopP
+	}
+	return lowered
+}
+
+// ApplyJSON enforces s on a messages request body for proxies that forward
+// it as is, returning the new body and the max_tokens the client asked for
+// when it was lowered. An extended thinking budget is kept below the new
+// max_tokens, and thinking requests get no temperature or top_p since
+// providers reject them. Bodies that aren't JSON objects are returned
+// unchanged.
+func (s SamplingDefaults) ApplyJSON(body []byte) ([]byte, int) {
+	if s.Empty() {
+		return body, 0
+	}
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(body, &req); err != nil {
+		return body, 0
+	}
+	requested := 0
+	if raw, ok := req["max_tokens"]; ok {
+		if err := json.Unmarshal(raw, &requested); err != nil {
+			return body, 0
+		}
+	}
+
+	lowered := 0
+	if n := s.maxTokens(requested); n != requested {
+		if requested > 0 {
+			lowered = requested
+		}
+		req["max_tokens"], _ = json.Marshal(n)
+		limitThinking(req, n)
+	}
+	if _, thinking := req["thinking"]; !thinking {
+		if _, ok := req["temperature"]; !ok && s.Temperature != nil {
+			req["temperature"], _ = json.Marshal(*s.Temperature)
+		}
+		if _, ok := req["top_p"]; !ok && s.TopP != nil {
+			req["top_p"], _ = json.Marshal(*s.TopP)
+		}
+	}
+
+	out, err := json.Marshal(req)
+	if err != nil {
+		return body, 0
+	}
+	return out, lowered
+}
+
+// limitThinking lowers an extended thinking budget below maxTokens, or turns
+// thinking off when that leaves less than minThinkingBudget
+func limitThinking(req map[string]json.RawMessage, maxTokens int) {
+	var thinking map[string]json.RawMessage
+	if err := json.Unmarshal(req["thinking"], &thinking); err != nil {
+		return
+	}
+	var budget int
+	if err := json.Unmarshal(thinking["budget_tokens"], &budget); err != nil || budget < maxTokens {
+		return
+	}
+	if maxTokens-1 < minThinkingBudget {
+		delete(req, "thinking")
+		return
+	}
+	thinking["budget_tokens"], _ = json.Marshal(maxTokens - 1)
+	req["thinking"], _ = json.Marshal(thinking)
+}
+
+// parseSamplingKey splits NEXUS_MAX_TOKENS[_<BACKEND>],
+// NEXUS_TEMPERATURE[_<BACKEND>] and NEXUS_TOP_P[_<BACKEND>] into the
+// parameter and lowercase backend name. ok is false for other keys.
+func parseSamplingKey(key string) (param, backend string, ok bool) {
+	for _, p := range []string{samplingMaxTokens, samplingTemperature, samplingTopP} {
+		prefix := "NEXUS_" + p
+		if key == prefix {
+			return p, "", true
+		}
+		if name, found := strings.CutPrefix(key, prefix+"_"); found {
+			return p, strings.ToLower(name), true
+		}
+	}
+	return "", "", false
+}
+
+// setSamplingKey validates and stores one sampling parameter; backend is
+// empty for every backend
+func setSamplingKey(cfg *Config, param, backend, value string) error {
+	if _, ok := backends[backend]; backend != "" && !ok {
+		return fmt.Errorf("unknown backend '%s'", backend)
+	}
+	s := cfg.Sampling[backend]
+	switch param {
+	case samplingMaxTokens:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return errors.New("must be a positive number of tokens")
+		}
+		s.MaxTokens = n
+	case samplingTemperature:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 || v > 2 {
+			return errors.New("must be between 0 and 2")
+		}
+		s.Temperature = &v
+	case samplingTopP:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v <= 0 || v > 1 {
+			return errors.New("must be above 0 and at most 1")
+		}
+		s.TopP = &v
+	}
+	if cfg.Sampling == nil {
+		cfg.Sampling = make(map[string]SamplingDefaults)
+	}
+	cfg.Sampling[backend] = s
+	return nil
+}
+
+// samplingDefaults resolves the generation parameters for a backend: its
+// own settings, then those for every backend
+func (c *Config) samplingDefaults(backend string) SamplingDefaults {
+	return c.Sampling[backend].merge(c.Sampling[""])
+}
+
+// samplingEnv returns the variables that keep Claude Code itself under a
+// backend's max_tokens cap, which is all a backend without a local proxy
+// gets; temperature and top_p can only be set by a proxy
+func samplingEnv(cfg *Config, be Backend) []string {
+	s := cfg.samplingDefaults(be.Name)
+	if s.MaxTokens == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("CLAUDE_CODE_MAX_OUTPUT_TOKENS=%d", s.MaxTokens)}
+}
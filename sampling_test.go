@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestParseSamplingKey(t *testing.T) {
+	tests := []struct {
+		key, param, backend string
+		ok                  bool
+	}{
+		{"NEXUS_MAX_TOKENS", samplingMaxTokens, "", true},
+		{"NEXUS_MAX_TOKENS_OPENAI", samplingMaxTokens, "openai", true},
+		{"NEXUS_TEMPERATURE_OLLAMA", samplingTemperature, "ollama", true},
+		{"NEXUS_TOP_P", samplingTopP, "", true},
+		{"NEXUS_TOP_P_GROK", samplingTopP, "grok", true},
+		{"NEXUS_MAX_TOKENSX", "", "", false},
+		{"NEXUS_TIMEOUT", "", "", false},
+	}
+	for _, tt := range tests {
+		param, backend, ok := parseSamplingKey(tt.key)
+		if param != tt.param || backend != tt.backend || ok != tt.ok {
+			t.Errorf("parseSamplingKey(%s) = %q %q %v", tt.key, param, backend, ok)
+		}
+	}
+}
+
+func TestSetSamplingKey(t *testing.T) {
+	cfg := &Config{}
+	for _, kv := range [][3]string{
+		{samplingMaxTokens, "", "16000"},
+		{samplingMaxTokens, "openai", "4096"},
+		{samplingTemperature, "openai", "0.2"},
+		{samplingTopP, "", "0.9"},
+	} {
+		if err := setSamplingKey(cfg, kv[0], kv[1], kv[2]); err != nil {
+			t.Fatalf("setSamplingKey(%v) error: %v", kv, err)
+		}
+	}
+	if got := cfg.samplingDefaults("openai").String(); got != "max_tokens<=4096, temperature=0.2, top_p=0.9" {
+		t.Errorf("samplingDefaults(openai) = %s", got)
+	}
+	if got := cfg.samplingDefaults("ollama").String(); got != "max_tokens<=16000, top_p=0.9" {
+		t.Errorf("Expected the settings for every backend, got %s", got)
+	}
+
+	for _, kv := range [][3]string{
+		{samplingMaxTokens, "", "0"},
+		{samplingMaxTokens, "", "lots"},
+		{samplingTemperature, "", "2.5"},
+		{samplingTopP, "", "0"},
+		{samplingTopP, "", "1.1"},
+		{samplingMaxTokens, "nope", "100"},
+	} {
+		if err := setSamplingKey(cfg, kv[0], kv[1], kv[2]); err == nil {
+			t.Errorf("Expected an error for %v", kv)
+		}
+	}
+}
+
+func TestLoadConfigSampling(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".env.local"), []byte("NEXUS_MAX_TOKENS_OPENAI=8192\nNEXUS_TEMPERATURE=0.3\nNEXUS_TOP_P_OLLAMA=high\n"), 0600)
+	t.Setenv("NEXUS_ENV_FILE", "")
+	setGlobalOptions(t, GlobalOptions{ConfigDir: dir})
+
+	cfg := loadConfig()
+	s := cfg.samplingDefaults("openai")
+	if s.MaxTokens != 8192 || s.Temperature == nil || *s.Temperature != 0.3 || s.TopP != nil {
+		t.Errorf("Unexpected sampling defaults %s", s)
+	}
+	if cfg.samplingDefaults("ollama").TopP != nil {
+		t.Error("Expected the invalid top_p ignored")
+	}
+}
+
+func TestSamplingApply(t *testing.T) {
+	s := SamplingDefaults{MaxTokens: 8192, Temperature: floatPtr(0.2)}
+	req := AnthropicRequest{MaxTokens: 128000, TopP: floatPtr(0.5)}
+	if lowered := s.Apply(&req); lowered != 128000 || req.MaxTokens != 8192 {
+		t.Errorf("Expected max_tokens lowered, got %d (lowered %d)", req.MaxTokens, lowered)
+	}
+	if req.Temperature == nil || *req.Temperature != 0.2 || *req.TopP != 0.5 {
+		t.Errorf("Expected the default temperature and the client's top_p, got %+v", req)
+	}
+
+	// Values within the cap and set by the client are kept
+	req = AnthropicRequest{MaxTokens: 1024, Temperature: floatPtr(1)}
+	if lowered := s.Apply(&req); lowered != 0 || req.MaxTokens != 1024 || *req.Temperature != 1 {
+		t.Errorf("Expected the request unchanged, got %+v", req)
+	}
+
+	// Requests without max_tokens get the cap
+	req = AnthropicRequest{}
+	if lowered := s.Apply(&req); lowered != 0 || req.MaxTokens != 8192 {
+		t.Errorf("Expected the cap as max_tokens, got %d", req.MaxTokens)
+	}
+}
+
+func TestSamplingApplyJSON(t *testing.T) {
+	s := SamplingDefaults{MaxTokens: 8192, Temperature: floatPtr(0.2)}
+	body, lowered := s.ApplyJSON([]byte(`{"model":"grok-4","max_tokens":64000,"messages":[]}`))
+	var got map[string]interface{}
+	json.Unmarshal(body, &got)
+	if lowered != 64000 || got["max_tokens"] != 8192.0 || got["temperature"] != 0.2 || got["model"] != "grok-4" {
+		t.Errorf("Unexpected body %s (lowered %d)", body, lowered)
+	}
+
+	// Thinking budgets stay below max_tokens and get no temperature
+	body, _ = s.ApplyJSON([]byte(`{"max_tokens":32000,"thinking":{"type":"enabled","budget_tokens":16000}}`))
+	got = nil
+	json.Unmarshal(body, &got)
+	thinking, _ := got["thinking"].(map[string]interface{})
+	if thinking["budget_tokens"] != 8191.0 || thinking["type"] != "enabled" {
+		t.Errorf("Expected the thinking budget lowered, got %s", body)
+	}
+	if _, ok := got["temperature"]; ok {
+		t.Errorf("Expected no temperature with thinking, got %s", body)
+	}
+
+	small := SamplingDefaults{MaxTokens: 1000}
+	body, _ = small.ApplyJSON([]byte(`{"max_tokens":32000,"thinking":{"type":"enabled","budget_tokens":16000}}`))
+	if strings.Contains(string(body), "thinking") {
+		t.Errorf("Expected thinking turned off below the minimum budget, got %s", body)
+	}
+
+	// Client values are kept
+	in := `{"max_tokens":100,"temperature":1}`
+	if body, lowered := s.ApplyJSON([]byte(in)); lowered != 0 || !strings.Contains(string(body), `"temperature":1`) {
+		t.Errorf("Expected client values kept, got %s", body)
+	}
+	if body, _ := s.ApplyJSON([]byte("not json")); string(body) != "not json" {
+		t.Errorf("Expected a non-JSON body unchanged, got %s", body)
+	}
+}
+
+func TestOllamaProxyEnforcesSampling(t *testing.T) {
+	var sent OpenAIRequest
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		json.NewEncoder(w).Encode(OpenAIResponse{Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: "ok"}}}})
+	}))
+	defer upstream.Close()
+
+	proxy := NewOllamaProxy(upstream.URL, nil)
+	proxy.SetSampling(SamplingDefaults{MaxTokens: 4096, TopP: floatPtr(0.8)})
+	var decision ProxyDecision
+	proxy.SetDecisionRecorder(func(d ProxyDecision) { decision = d })
+
+	body := `{"model":"llama3.2","max_tokens":128000,"messages":[{"role":"user","content":"hi"}]}`
+	rec := httptest.NewRecorder()
+	proxy.handleMessages(rec, httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if sent.MaxTokens != 4096 || sent.TopP != 0.8 || sent.Temperature != 0.7 {
+		t.Errorf("Unexpected upstream request %+v", sent)
+	}
+	if decision.MaxTokensLowered != 128000 || !strings.Contains(decisionFlags(decision), "capped") {
+		t.Errorf("Expected the cap in the decision log, got %+v", decision)
+	}
+}
+
+func TestGrokProxyEnforcesSampling(t *testing.T) {
+	var sent []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"type":"message","content":[]}`))
+	}))
+	defer upstream.Close()
+
+	p := NewGrokProxy(upstream.URL, "key")
+	p.SetSampling(SamplingDefaults{MaxTokens: 2048})
+	body := []byte(`{"model":"grok-4","max_tokens":100000,"messages":[]}`)
+	p.handle(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/messages", bytes.NewReader(body)))
+	if !strings.Contains(string(sent), `"max_tokens":2048`) {
+		t.Errorf("Expected max_tokens capped upstream, got %s", sent)
+	}
+}
+
+func TestSamplingEnv(t *testing.T) {
+	cfg := &Config{}
+	if env := samplingEnv(cfg, backends["deepseek"]); env != nil {
+		t.Errorf("Expected no variables without a cap, got %v", env)
+	}
+	setSamplingKey(cfg, samplingMaxTokens, "deepseek", "8000")
+	if env := samplingEnv(cfg, backends["deepseek"]); len(env) != 1 || env[0] != "CLAUDE_CODE_MAX_OUTPUT_TOKENS=8000" {
+		t.Errorf("Unexpected variables %v", env)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// SelfTestResult is the outcome of a single self-test check
+type SelfTestResult struct {
+	Name     string
+	Passed   bool
+	Duration time.Duration
+	Err      error
+}
+
+// selfTestCase is a named check run against an isolated config
+type selfTestCase struct {
+	Name string
+	Run  func(cfg *Config) error
+}
+
+// selfTestCases lists the checks run by `promptops selftest`
+var selfTestCases = []selfTestCase{
+	{"state write", selfTestState},
+	{"session lifecycle", selfTestSessions},
+	{"budget math", selfTestBudget},
+	{"proxy non-streaming", func(cfg *Config) error { return selfTestProxy(false) }},
+	{"proxy streaming", func(cfg *Config) error { return selfTestProxy(true) }},
+	{"redaction", selfTestRedaction},
+	{"file locking", selfTestLocking},
+}
+
+// newSelfTestConfig returns a config whose files all live in dir
+func newSelfTestConfig(dir string) *Config {
+	return &Config{
+		EnvFile:            filepath.Join(dir, ".env.local"),
+		StateFile:          filepath.Join(dir, "state"),
+		AuditLog:           filepath.Join(dir, ".promptops-audit.log"),
+		UsageFile:          filepath.Join(dir, ".promptops-usage.jsonl"),
+		SessionsFile:       filepath.Join(dir, ".promptops-sessions.json"),
+		SessionFile:        filepath.Join(dir, "session"),
+		CreditsFile:        filepath.Join(dir, "credits.json"),
+		ModelsFile:         filepath.Join(dir, "models.json"),
+		ModelSnapshotsFile: filepath.Join(dir, "model-snapshots.json"),
+		DecisionsFile:      filepath.Join(dir, "decisions.jsonl"),
+		CapabilitiesFile:   filepath.Join(dir, "capabilities.json"),
+		LaunchRecordFile:   filepath.Join(dir, "last-launch.json"),
+		RateLimitFile:      filepath.Join(dir, "ratelimits.json"),
+		Keys:               make(map[string]string),
+		YoloModes:          make(map[string]bool),
+		LaunchArgs:         make(map[string][]string),
+		DailyBudget:        10.00,
+		WeeklyBudget:       50.00,
+		MonthlyBudget:      100.00,
+	}
+}
+
+// runSelfTests runs every check in its own directory under dir
+func runSelfTests(dir string, cases []selfTestCase) []SelfTestResult {
+	results := make([]SelfTestResult, 0, len(cases))
+	for i, tc := range cases {
+		caseDir := filepath.Join(dir, strconv.Itoa(i))
+		start := time.Now()
+		err := os.MkdirAll(caseDir, 0700)
+		if err == nil {
+			err = tc.Run(newSelfTestConfig(caseDir))
+		}
+		results = append(results, SelfTestResult{
+			Name:     tc.Name,
+			Passed:   err == nil,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+	return results
+}
+
+func selfTestState(cfg *Config) error {
+	if err := setCurrentBackend(cfg, "deepseek"); err != nil {
+		return err
+	}
+	if got := getCurrentBackend(cfg); got != "deepseek" {
+		return fmt.Errorf("read back %q, want deepseek", got)
+	}
+	info, err := os.Stat(cfg.StateFile)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm() != 0600 {
+		return fmt.Errorf("state file permissions %o, want 600", info.Mode().Perm())
+	}
+	return nil
+}
+
+func selfTestSessions(cfg *Config) error {
+	session, err := createSession(cfg, "selftest")
+	if err != nil {
+		return err
+	}
+	current := getCurrentSession(cfg)
+	if current == nil || current.ID != session.ID {
+		return errors.New("created session is not current")
+	}
+
+	sessions := loadSessions(cfg)
+	for _, s := range sessions {
+		if s.ID == session.ID {
+			s.Status = "closed"
+		}
+	}
+	if err := saveSessions(cfg, sessions); err != nil {
+		return err
+	}
+	reloaded := loadSessions(cfg)
+	if len(reloaded) != 1 || reloaded[0].Status != "closed" {
+		return errors.New("closed session did not persist")
+	}
+	return nil
+}
+
+func selfTestBudget(cfg *Config) error {
+	records := []UsageRecord{
+		{Timestamp: time.Now(), Backend: "claude", CostUSD: 1.25},
+		{Timestamp: time.Now().AddDate(-1, -1, 0), Backend: "claude", CostUSD: 100},
+	}
+	var buf bytes.Buffer
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if err := os.WriteFile(cfg.UsageFile, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	daily, weekly, monthly, byBackend := calculateCosts(cfg)
+	if daily != 1.25 || weekly != 1.25 || monthly != 1.25 {
+		return fmt.Errorf("daily/weekly/monthly = %.2f/%.2f/%.2f, want 1.25 each", daily, weekly, monthly)
+	}
+	if byBackend["claude"] != 101.25 {
+		return fmt.Errorf("backend total = %.2f, want 101.25", byBackend["claude"])
+	}
+	return nil
+}
+
+// startMockBackend serves an OpenAI-compatible chat completions endpoint on a
+// random local port and returns its base URL
+func startMockBackend() (string, func(), error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var req OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintln(w, `data: {"choices":[{"delta":{"content":"self"}}]}`)
+			fmt.Fprintln(w, `data: {"choices":[{"delta":{"content":"test"}}]}`)
+			fmt.Fprintln(w, "data: [DONE]")
+			return
+		}
+		json.NewEncoder(w).Encode(OpenAIResponse{
+			Choices: []OpenAIChoice{{Message: OpenAIMessage{Role: "assistant", Content: "selftest"}, FinishReason: "stop"}},
+			Usage:   OpenAIUsage{PromptTokens: 3, CompletionTokens: 1},
+		})
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go server.Serve(ln)
+	return "http://" + ln.Addr().String(), func() { server.Close() }, nil
+}
+
+// freePort returns a currently unused local TCP port
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+func selfTestProxy(stream bool) error {
+	baseURL, stopMock, err := startMockBackend()
+	if err != nil {
+		return err
+	}
+	defer stopMock()
+
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	proxy := NewOllamaProxy(baseURL, nil)
+	if err := proxy.Start(port); err != nil {
+		return err
+	}
+	defer proxy.Stop()
+
+	body, err := json.Marshal(AnthropicRequest{
+		Model:     "llama3.2",
+		MaxTokens: 16,
+		Stream:    stream,
+		Messages:  []AnthropicMessage{{Role: "user", Content: "ping"}},
+	})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(proxyURL(port)+"/v1/messages", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy returned HTTP %d", resp.StatusCode)
+	}
+
+	if !stream {
+		var anthResp AnthropicResponse
+		if err := json.NewDecoder(resp.Body).Decode(&anthResp); err != nil {
+			return err
+		}
+		if len(anthResp.Content) == 0 || anthResp.Content[0].Text != "selftest" {
+			return errors.New("unexpected translated response")
+		}
+		if anthResp.Usage.InputTokens != 3 || anthResp.Usage.OutputTokens != 1 {
+			return errors.New("usage not translated")
+		}
+		return nil
+	}
+
+	var text strings.Builder
+	sawStop := false
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxResponseSize))
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+		if event.Type == "content_block_delta" && event.Delta != nil {
+			text.WriteString(event.Delta.Text)
+		}
+		if event.Type == "message_stop" {
+			sawStop = true
+		}
+	}
+	if text.String() != "selftest" || !sawStop {
+		return fmt.Errorf("unexpected stream: %q (message_stop=%t)", text.String(), sawStop)
+	}
+	return nil
+}
+
+func selfTestRedaction(cfg *Config) error {
+	secret := "sk-ant-api03-" + strings.Repeat("x", 24)
+	if msg := sanitizeError(fmt.Errorf("request failed with key %s", secret)).Error(); strings.Contains(msg, secret) {
+		return errors.New("sanitizeError leaked an API key")
+	}
+	if masked := maskKey(secret); strings.Contains(masked, secret[4:len(secret)-4]) {
+		return errors.New("maskKey exposed the key body")
+	}
+	for _, e := range filterEnvironment([]string{"ANTHROPIC_API_KEY=" + secret, "PATH=/usr/bin"}) {
+		if strings.Contains(e, secret) {
+			return errors.New("filterEnvironment passed an API key through")
+		}
+	}
+	return nil
+}
+
+func selfTestLocking(cfg *Config) error {
+	counter := filepath.Join(filepath.Dir(cfg.StateFile), "counter")
+	lock := counter + ".lock"
+	const workers = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- withFileLock(lock, func() error {
+				data, _ := os.ReadFile(counter)
+				n, _ := strconv.Atoi(string(data))
+				return os.WriteFile(counter, []byte(strconv.Itoa(n+1)), 0600)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := os.ReadFile(counter)
+	if err != nil {
+		return err
+	}
+	if n, _ := strconv.Atoi(string(data)); n != workers {
+		return fmt.Errorf("counter = %d after %d locked increments", n, workers)
+	}
+	return nil
+}
+
+func handleSelftest() {
+	dir, err := os.MkdirTemp("", "promptops-selftest-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(dir)
+
+	results := runSelfTests(dir, selfTestCases)
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("SELF TEST"))
+
+	rows := [][]string{}
+	failed := 0
+	for _, r := range results {
+		status := styleSuccess.Render("PASS")
+		detail := ""
+		if !r.Passed {
+			status = styleError.Render("FAIL")
+			detail = truncate(sanitizeError(r.Err).Error(), 50)
+			failed++
+		}
+		rows = append(rows, []string{r.Name, status, formatDuration(r.Duration), detail})
+	}
+
+	t := table.New().
+		Headers("Check", "Result", "Time", "Detail").
+		Rows(rows...).
+		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		}).
+		Width(90)
+
+	fmt.Println(t.Render())
+	fmt.Printf("%d passed, %d failed\n", len(results)-failed, failed)
+	fmt.Println()
+
+	if failed > 0 {
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+}
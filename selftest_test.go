@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelfTestCasesPass(t *testing.T) {
+	for _, r := range runSelfTests(t.TempDir(), selfTestCases) {
+		if !r.Passed {
+			t.Errorf("self test %q failed: %v", r.Name, r.Err)
+		}
+	}
+}
+
+func TestRunSelfTestsReportsFailures(t *testing.T) {
+	cases := []selfTestCase{
+		{"passes", func(cfg *Config) error { return nil }},
+		{"fails", func(cfg *Config) error { return errors.New("boom") }},
+	}
+	results := runSelfTests(t.TempDir(), cases)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if !results[0].Passed || results[1].Passed {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	if results[1].Err == nil || results[1].Err.Error() != "boom" {
+		t.Errorf("Expected error to be kept, got %v", results[1].Err)
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// Service management defaults
+const (
+	defaultServiceReadyTimeout = 60 * time.Second
+	serviceProbeInterval       = 500 * time.Millisecond
+	serviceProbeTimeout        = 2 * time.Second
+	serviceStopTimeout         = 10 * time.Second
+)
+
+var serviceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]*$`)
+
+// ServiceDef is a companion local service, configured with
+// NEXUS_SERVICE_<NAME> (command), NEXUS_SERVICE_<NAME>_READY (probe URL)
+// and NEXUS_SERVICE_<NAME>_STOP (stop command)
+type ServiceDef struct {
+	Name    string
+	Command []string
+	Ready   string // http(s):// URL or tcp://host:port
+	Stop    []string
+}
+
+// serviceState is what services start remembers about a process
+type serviceState struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+}
+
+// setServiceKey applies a NEXUS_SERVICE_* setting, keeping services in the
+// order they first appear in the env file
+func setServiceKey(cfg *Config, key, value string) error {
+	rest := strings.TrimPrefix(key, "NEXUS_SERVICE_")
+	field := "cmd"
+	if name, ok := strings.CutSuffix(rest, "_READY"); ok {
+		rest, field = name, "ready"
+	} else if name, ok := strings.CutSuffix(rest, "_STOP"); ok {
+		rest, field = name, "stop"
+	}
+	name := strings.ToLower(rest)
+	if !serviceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid service name in %s", key)
+	}
+
+	svc, ok := cfg.Services[name]
+	if !ok {
+		svc = &ServiceDef{Name: name}
+		cfg.Services[name] = svc
+		cfg.ServiceOrder = append(cfg.ServiceOrder, name)
+	}
+	switch field {
+	case "ready":
+		value = strings.Trim(value, `"'`)
+		if err := validateProbe(value); err != nil {
+			return err
+		}
+		svc.Ready = value
+	default:
+		args, err := splitLaunchArgs(value)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return errors.New("empty command")
+		}
+		if field == "stop" {
+			svc.Stop = args
+		} else {
+			svc.Command = args
+		}
+	}
+	return nil
+}
+
+// validateProbe accepts http(s) URLs and tcp://host:port
+func validateProbe(probe string) error {
+	u, err := url.Parse(probe)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid readiness probe '%s'", probe)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return nil
+	case "tcp":
+		if u.Port() == "" {
+			return fmt.Errorf("tcp probe '%s' needs a port", probe)
+		}
+		return nil
+	}
+	return fmt.Errorf("readiness probe must be http(s):// or tcp://, got '%s'", probe)
+}
+
+// probeService reports whether a service answers its readiness probe. Any
+// HTTP response below 500 counts as ready.
+func probeService(probe string) error {
+	u, err := url.Parse(probe)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "tcp" {
+		conn, err := net.DialTimeout("tcp", u.Host, serviceProbeTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	client := *httpClient
+	client.Timeout = serviceProbeTimeout
+	resp, err := client.Get(probe)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// waitReady polls the probe until it succeeds or timeout passes
+func waitReady(probe string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := probeService(probe)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not ready after %s: %v", formatDuration(timeout), err)
+		}
+		time.Sleep(serviceProbeInterval)
+	}
+}
+
+// processAlive reports whether pid is a running process
+func processAlive(pid int) bool {
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+}
+
+func loadServiceStates(cfg *Config) map[string]serviceState {
+	states := make(map[string]serviceState)
+	data, err := os.ReadFile(cfg.ServicesFile)
+	if err != nil {
+		return states
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring unreadable services file: %v\n", err)
+		return make(map[string]serviceState)
+	}
+	return states
+}
+
+// updateServiceState records (or with a nil state, forgets) a service process
+func updateServiceState(cfg *Config, name string, state *serviceState) error {
+	return withFileLock(cfg.ServicesFile+".lock", func() error {
+		states := loadServiceStates(cfg)
+		if state == nil {
+			delete(states, name)
+		} else {
+			states[name] = *state
+		}
+		data, err := json.MarshalIndent(states, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(cfg.ServicesFile, data, 0600)
+	})
+}
+
+// serviceLogPath is where a started service's output goes
+func serviceLogPath(cfg *Config, name string) string {
+	return strings.TrimSuffix(cfg.ServicesFile, ".json") + "-" + name + ".log"
+}
+
+// startService starts svc unless it is already ready or running, then waits
+// for its readiness probe. It returns a short description of what happened.
+func startService(cfg *Config, svc *ServiceDef, timeout time.Duration) (string, error) {
+	if svc.Ready != "" && probeService(svc.Ready) == nil {
+		return "already running", nil
+	}
+	if state, ok := loadServiceStates(cfg)[svc.Name]; !ok || !processAlive(state.PID) {
+		if len(svc.Command) == 0 {
+			return "", errors.New("no command configured")
+		}
+		logFile, err := os.OpenFile(serviceLogPath(cfg, svc.Name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return "", err
+		}
+		cmd := exec.Command(svc.Command[0], svc.Command[1:]...)
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+		// Own process group, so Ctrl+C in the launching terminal leaves it running
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		err = cmd.Start()
+		logFile.Close()
+		if err != nil {
+			return "", err
+		}
+		go cmd.Wait()
+		if err := updateServiceState(cfg, svc.Name, &serviceState{PID: cmd.Process.Pid, Started: time.Now()}); err != nil {
+			return "", err
+		}
+		auditLog(cfg, fmt.Sprintf("SERVICE_START: %s (pid %d)", svc.Name, cmd.Process.Pid))
+	}
+	if svc.Ready == "" {
+		return "started (no readiness probe)", nil
+	}
+	start := time.Now()
+	if err := waitReady(svc.Ready, timeout); err != nil {
+		return "", err
+	}
+	return "ready in " + formatDuration(time.Since(start)), nil
+}
+
+// stopService runs the stop command, or terminates the started process group
+func stopService(cfg *Config, svc *ServiceDef) (string, error) {
+	state, tracked := loadServiceStates(cfg)[svc.Name]
+	defer updateServiceState(cfg, svc.Name, nil)
+
+	if len(svc.Stop) > 0 {
+		out, err := exec.Command(svc.Stop[0], svc.Stop[1:]...).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("%v: %s", err, truncate(strings.TrimSpace(string(out)), 200))
+		}
+		auditLog(cfg, fmt.Sprintf("SERVICE_STOP: %s", svc.Name))
+		return "stopped", nil
+	}
+	if !tracked || !processAlive(state.PID) {
+		return "not running", nil
+	}
+
+	syscall.Kill(-state.PID, syscall.SIGTERM)
+	deadline := time.Now().Add(serviceStopTimeout)
+	for processAlive(state.PID) && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	result := "stopped"
+	if processAlive(state.PID) {
+		syscall.Kill(-state.PID, syscall.SIGKILL)
+		result = "killed"
+	}
+	auditLog(cfg, fmt.Sprintf("SERVICE_STOP: %s (pid %d)", svc.Name, state.PID))
+	return result, nil
+}
+
+// selectServices returns the named services, or all in config order
+func selectServices(cfg *Config, names []string) ([]*ServiceDef, error) {
+	if len(names) == 0 {
+		names = cfg.ServiceOrder
+	}
+	selected := make([]*ServiceDef, 0, len(names))
+	for _, name := range names {
+		svc, ok := cfg.Services[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown service '%s'", name)
+		}
+		selected = append(selected, svc)
+	}
+	return selected, nil
+}
+
+// startServices starts services in order, stopping at the first failure so
+// later services don't come up without their dependencies
+func startServices(cfg *Config, services []*ServiceDef, timeout time.Duration) bool {
+	for _, svc := range services {
+		label := fmt.Sprintf("%-16s", svc.Name)
+		result, err := startService(cfg, svc, timeout)
+		if err != nil {
+			fmt.Printf("%s %s %v\n", styleError.Render("[FAIL]"), label, err)
+			fmt.Println(styleMuted.Render("       log: " + serviceLogPath(cfg, svc.Name)))
+			return false
+		}
+		fmt.Printf("%s %s %s\n", styleSuccess.Render("[OK]  "), label, result)
+	}
+	return true
+}
+
+func handleServicesCommand(args []string) {
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+	rest := args[1:]
+	timeout := globalOpts.Timeout
+	if timeout == 0 {
+		timeout = defaultServiceReadyTimeout
+	}
+	cfg := loadConfig()
+	if len(cfg.ServiceOrder) == 0 {
+		fmt.Println("No services configured. Define them in .env.local, e.g.:")
+		fmt.Println("  NEXUS_SERVICE_OLLAMA=ollama serve")
+		fmt.Println("  NEXUS_SERVICE_OLLAMA_READY=http://localhost:11434/api/tags")
+		return
+	}
+	services, err := selectServices(cfg, rest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "start":
+		fmt.Println()
+		fmt.Println(styleSection.Render("STARTING SERVICES"))
+		ok := startServices(cfg, services, timeout)
+		fmt.Println()
+		if !ok {
+			os.Exit(1)
+		}
+	case "stop":
+		fmt.Println()
+		fmt.Println(styleSection.Render("STOPPING SERVICES"))
+		failed := false
+		for i := len(services) - 1; i >= 0; i-- {
+			svc := services[i]
+			label := fmt.Sprintf("%-16s", svc.Name)
+			result, err := stopService(cfg, svc)
+			if err != nil {
+				fmt.Printf("%s %s %v\n", styleError.Render("[FAIL]"), label, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s %s %s\n", styleSuccess.Render("[OK]  "), label, result)
+		}
+		fmt.Println()
+		if failed {
+			os.Exit(1)
+		}
+	case "status":
+		showServicesStatus(cfg, services)
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: promptops services <start|stop|status> [name...] [--timeout 2m]")
+		os.Exit(1)
+	}
+}
+
+func showServicesStatus(cfg *Config, services []*ServiceDef) {
+	states := loadServiceStates(cfg)
+	rows := [][]string{}
+	for _, svc := range services {
+		pid := "-"
+		if state, ok := states[svc.Name]; ok && processAlive(state.PID) {
+			pid = strconv.Itoa(state.PID)
+		}
+		status := styleMuted.Render("no probe")
+		if svc.Ready != "" {
+			if err := probeService(svc.Ready); err == nil {
+				status = styleSuccess.Render("ready")
+			} else {
+				status = styleError.Render("down")
+			}
+		}
+		rows = append(rows, []string{svc.Name, status, pid, truncate(strings.Join(svc.Command, " "), 50)})
+	}
+
+	fmt.Println()
+	fmt.Println(styleSection.Render("LOCAL SERVICES"))
+	t := table.New().
+		Headers("Service", "Status", "PID", "Command").
+		Rows(rows...).
+		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+	fmt.Println(t.Render())
+	fmt.Println()
+}
+
+// autostartServices brings up configured services before a launch when
+// NEXUS_SERVICES_AUTOSTART is set
+func autostartServices(cfg *Config) {
+	if !cfg.ServicesAutostart || len(cfg.ServiceOrder) == 0 {
+		return
+	}
+	services, _ := selectServices(cfg, nil)
+	if !startServices(cfg, services, defaultServiceReadyTimeout) {
+		fmt.Fprintln(os.Stderr, "Warning: not all local services are ready")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func servicesTestConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := newSelfTestConfig(dir)
+	cfg.Services = make(map[string]*ServiceDef)
+	cfg.ServicesFile = filepath.Join(dir, "services.json")
+	return cfg
+}
+
+func TestSetServiceKey(t *testing.T) {
+	cfg := servicesTestConfig(t)
+	settings := [][2]string{
+		{"NEXUS_SERVICE_VLLM_READY", "http://localhost:8000/v1/models"},
+		{"NEXUS_SERVICE_OLLAMA", "ollama serve"},
+		{"NEXUS_SERVICE_VLLM", `docker run -d --name vllm "vllm/vllm-openai"`},
+		{"NEXUS_SERVICE_VLLM_STOP", "docker stop vllm"},
+	}
+	for _, s := range settings {
+		if err := setServiceKey(cfg, s[0], s[1]); err != nil {
+			t.Fatalf("setServiceKey(%s) error: %v", s[0], err)
+		}
+	}
+
+	if strings.Join(cfg.ServiceOrder, ",") != "vllm,ollama" {
+		t.Errorf("Expected env file order, got %v", cfg.ServiceOrder)
+	}
+	vllm := cfg.Services["vllm"]
+	if len(vllm.Command) != 6 || vllm.Command[5] != "vllm/vllm-openai" || vllm.Ready != "http://localhost:8000/v1/models" {
+		t.Errorf("Unexpected vllm service: %+v", vllm)
+	}
+	if strings.Join(vllm.Stop, " ") != "docker stop vllm" {
+		t.Errorf("Unexpected stop command: %v", vllm.Stop)
+	}
+
+	for _, bad := range [][2]string{
+		{"NEXUS_SERVICE_OLLAMA_READY", "localhost:11434"},
+		{"NEXUS_SERVICE_OLLAMA_READY", "tcp://localhost"},
+		{"NEXUS_SERVICE_OLLAMA", ""},
+		{"NEXUS_SERVICE_", "x"},
+	} {
+		if err := setServiceKey(cfg, bad[0], bad[1]); err == nil {
+			t.Errorf("Expected error for %s=%q", bad[0], bad[1])
+		}
+	}
+}
+
+func TestProbeService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if err := probeService(server.URL + "/api/tags"); err != nil {
+		t.Errorf("Expected any response below 500 to be ready: %v", err)
+	}
+	if err := probeService(server.URL + "/broken"); err == nil {
+		t.Error("Expected 503 to be not ready")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	if err := probeService("tcp://" + addr); err != nil {
+		t.Errorf("Expected tcp probe to succeed: %v", err)
+	}
+	ln.Close()
+	if err := waitReady("tcp://"+addr, 100*time.Millisecond); err == nil {
+		t.Error("Expected closed port to time out")
+	}
+}
+
+func TestStartAndStopService(t *testing.T) {
+	cfg := servicesTestConfig(t)
+	svc := &ServiceDef{Name: "sleeper", Command: []string{"sleep", "30"}}
+
+	result, err := startService(cfg, svc, time.Second)
+	if err != nil || !strings.HasPrefix(result, "started") {
+		t.Fatalf("Expected service started, got %q (err=%v)", result, err)
+	}
+	state, ok := loadServiceStates(cfg)["sleeper"]
+	if !ok || !processAlive(state.PID) {
+		t.Fatalf("Expected a running process recorded, got %+v", state)
+	}
+
+	// A second start reuses the running process
+	startService(cfg, svc, time.Second)
+	if again := loadServiceStates(cfg)["sleeper"]; again.PID != state.PID {
+		t.Errorf("Expected pid %d kept, got %d", state.PID, again.PID)
+	}
+
+	if result, err := stopService(cfg, svc); err != nil || result != "stopped" {
+		t.Errorf("Expected service stopped, got %q (err=%v)", result, err)
+	}
+	if _, ok := loadServiceStates(cfg)["sleeper"]; ok {
+		t.Error("Expected service state removed after stop")
+	}
+	if result, _ := stopService(cfg, svc); result != "not running" {
+		t.Errorf("Expected not running, got %q", result)
+	}
+}
+
+func TestStartServiceAlreadyReady(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cfg := servicesTestConfig(t)
+	svc := &ServiceDef{Name: "ollama", Command: []string{"false"}, Ready: server.URL, Stop: []string{"true"}}
+	if result, err := startService(cfg, svc, time.Second); err != nil || result != "already running" {
+		t.Errorf("Expected already running, got %q (err=%v)", result, err)
+	}
+	if len(loadServiceStates(cfg)) != 0 {
+		t.Error("Expected no process started for a ready service")
+	}
+	if result, err := stopService(cfg, svc); err != nil || result != "stopped" {
+		t.Errorf("Expected stop command to run, got %q (err=%v)", result, err)
+	}
+}
+
+func TestSelectServices(t *testing.T) {
+	cfg := servicesTestConfig(t)
+	setServiceKey(cfg, "NEXUS_SERVICE_OLLAMA", "ollama serve")
+	setServiceKey(cfg, "NEXUS_SERVICE_LITELLM", "litellm --port 4000")
+
+	all, _ := selectServices(cfg, nil)
+	if len(all) != 2 || all[0].Name != "ollama" {
+		t.Errorf("Expected all services in order, got %v", all)
+	}
+	if _, err := selectServices(cfg, []string{"vllm"}); err == nil {
+		t.Error("Expected error for an unknown service")
+	}
+}
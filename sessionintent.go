@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Session intent summaries
+const (
+	// intentTimeout bounds the summary request, which runs in a background
+	// process so the UserPromptSubmit hook does not wait for it
+	intentTimeout   = 15 * time.Second
+	intentMaxTokens = 40
+	// maxIntentPrompt is how much of the first prompt the model sees
+	maxIntentPrompt = 4000
+	maxIntentLength = 80
+	intentPrompt    = "Describe the user's request as a task in one line of at most 12 words. " +
+		"Reply with the description only, without quotes."
+)
+
+// intentModel is the local model that writes session intents: NEXUS_SUMMARY_MODEL,
+// or the Ollama haiku tier
+func intentModel(cfg *Config) string {
+	if cfg.SummaryModel != "" {
+		return cfg.SummaryModel
+	}
+	haiku, _, _ := resolveTierModels(cfg, backends["ollama"])
+	return haiku
+}
+
+// summarizeIntent asks the local Ollama server for a one-line description of
+// prompt. Nothing is sent to a hosted provider.
+func summarizeIntent(baseURL, model, prompt string) (string, error) {
+	if r := []rune(prompt); len(r) > maxIntentPrompt {
+		prompt = string(r[:maxIntentPrompt])
+	}
+	reply, err := localCompletion(baseURL, model, intentPrompt, prompt, intentMaxTokens, intentTimeout)
+	if err != nil {
+		return "", err
+	}
+	intent := cleanIntent(reply)
+	if intent == "" {
+		return "", errors.New("empty summary returned")
+	}
+	return intent, nil
+}
+
+// localCompletion sends one system and user message to the local Ollama
+// server's OpenAI-compatible API and returns the reply text
+func localCompletion(baseURL, model, system, user string, maxTokens int, timeout time.Duration) (string, error) {
+	body, err := json.Marshal(OpenAIRequest{
+		Model:     model,
+		MaxTokens: maxTokens,
+		Messages: []OpenAIMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post(strings.TrimRight(baseURL, "/")+"/chat/completions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("summary request failed: %w", sanitizeError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("summary request failed: HTTP %d", resp.StatusCode)
+	}
+	var result OpenAIResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode summary: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("empty summary returned")
+	}
+	return result.Choices[0].Message.Content, nil
+}
+
+// cleanIntent reduces a model reply to one short line
+func cleanIntent(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Trim(strings.TrimSpace(line), "\"'`*")
+		line = strings.TrimSuffix(strings.TrimSpace(line), ".")
+		if line != "" {
+			return truncate(line, maxIntentLength)
+		}
+	}
+	return ""
+}
+
+// recordSessionIntent hands prompt to start for summarizing as the current
+// session's intent when NEXUS_SESSION_SUMMARIES is on and the session has not
+// been summarized yet. Called from the UserPromptSubmit hook. The attempt is
+// recorded on the session first, so each session is summarized at most once,
+// whether or not the summary succeeds.
+func recordSessionIntent(cfg *Config, prompt string, start func(sessionID, prompt string) error) error {
+	if !cfg.SessionSummaries || strings.TrimSpace(prompt) == "" {
+		return nil
+	}
+	session := getCurrentSession(cfg)
+	if session == nil || session.Intent != "" || session.IntentAttempted {
+		return nil
+	}
+	claimed := false
+	err := updateSession(cfg, session.ID, func(s *Session) {
+		// A concurrent prompt may have claimed it first
+		if s.Intent == "" && !s.IntentAttempted {
+			s.IntentAttempted = true
+			claimed = true
+		}
+	})
+	if err != nil || !claimed {
+		return err
+	}
+	if r := []rune(prompt); len(r) > maxIntentPrompt {
+		prompt = string(r[:maxIntentPrompt])
+	}
+	return start(session.ID, prompt)
+}
+
+// startIntentSummary runs "promptops hooks intent" in the background with
+// prompt on its stdin. The hook exits without waiting for it.
+func startIntentSummary(sessionID, prompt string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "hooks", "intent", sessionID)
+	// Own process group, so the summary outlives the hook
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// The prompt is capped at maxIntentPrompt, well inside the pipe buffer
+	_, err = io.WriteString(stdin, prompt)
+	stdin.Close()
+	return err
+}
+
+// summarizeSessionIntent summarizes prompt with the local server at baseURL
+// and stores it as the intent of session sessionID. Failures are audited,
+// since nothing is watching the background process's output.
+func summarizeSessionIntent(cfg *Config, sessionID, baseURL, prompt string) error {
+	var session *Session
+	for _, s := range loadSessions(cfg) {
+		if s != nil && s.ID == sessionID {
+			session = s
+		}
+	}
+	if session == nil {
+		return fmt.Errorf("session %s not found", sessionID)
+	}
+	intent, err := summarizeIntent(baseURL, intentModel(cfg), prompt)
+	if err != nil {
+		auditLog(cfg, fmt.Sprintf("SESSION_INTENT_FAILED: %s: %v", session.Name, err))
+		return err
+	}
+	return storeSessionIntent(cfg, session, intent)
+}
+
+// storeSessionIntent sets a session's intent unless one was stored meanwhile
+func storeSessionIntent(cfg *Config, session *Session, intent string) error {
+	stored := false
+	err := updateSession(cfg, session.ID, func(s *Session) {
+		// A concurrent prompt may have won the race
+		if s.Intent == "" {
+			s.Intent = intent
+			stored = true
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if stored {
+		auditLog(cfg, fmt.Sprintf("SESSION_INTENT: %s: %s", session.Name, intent))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCleanIntent(t *testing.T) {
+	for in, want := range map[string]string{
+		"Fix the flaky login test.":                      "Fix the flaky login test",
+		"\"Add retries to the uploader\"\n\nExplanation": "Add retries to the uploader",
+		"\n  **Refactor config parsing**  ":              "Refactor config parsing",
+		"   ":                                            "",
+	} {
+		if got := cleanIntent(in); got != want {
+			t.Errorf("cleanIntent(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := cleanIntent(strings.Repeat("word ", 40)); len([]rune(got)) > maxIntentLength {
+		t.Errorf("Expected intent capped at %d characters, got %d", maxIntentLength, len(got))
+	}
+}
+
+func TestSummarizeIntent(t *testing.T) {
+	var got OpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(OpenAIResponse{Choices: []OpenAIChoice{{Message: OpenAIMessage{Content: "Speed up the CI pipeline."}}}})
+	}))
+	defer server.Close()
+
+	intent, err := summarizeIntent(server.URL+"/v1", "llama3.2", strings.Repeat("x", maxIntentPrompt+100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intent != "Speed up the CI pipeline" {
+		t.Errorf("Unexpected intent %q", intent)
+	}
+	if got.Model != "llama3.2" || len(got.Messages) != 2 || len(got.Messages[1].Content) != maxIntentPrompt {
+		t.Errorf("Unexpected summary request %+v", got)
+	}
+}
+
+func TestSummarizeIntentError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+	if _, err := summarizeIntent(server.URL, "missing", "hello"); err == nil {
+		t.Error("Expected an error for a failed summary request")
+	}
+}
+
+func TestStoreSessionIntent(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.AuditEnabled = true
+	cfg.SessionSummaries = true
+	session, err := createSession(cfg, "auth-work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storeSessionIntent(cfg, session, "Add OAuth login"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeSessionIntent(cfg, session, "Something else"); err != nil {
+		t.Fatal(err)
+	}
+	current := getCurrentSession(cfg)
+	if current == nil || current.Intent != "Add OAuth login" {
+		t.Fatalf("Expected the first intent kept, got %+v", current)
+	}
+	audit, _ := os.ReadFile(cfg.AuditLog)
+	if strings.Count(string(audit), "SESSION_INTENT: auth-work") != 1 {
+		t.Errorf("Expected one audit entry, got %q", string(audit))
+	}
+
+	// A session with an intent is not summarized again
+	start := func(string, string) error {
+		t.Error("Expected no summary started")
+		return nil
+	}
+	if err := recordSessionIntent(cfg, "another prompt", start); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecordSessionIntentFailure(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg := newSelfTestConfig(t.TempDir())
+	cfg.AuditEnabled = true
+	cfg.SessionSummaries = true
+	if _, err := createSession(cfg, "auth-work"); err != nil {
+		t.Fatal(err)
+	}
+	start := func(sessionID, prompt string) error {
+		return summarizeSessionIntent(cfg, sessionID, server.URL, prompt)
+	}
+
+	if err := recordSessionIntent(cfg, "Add OAuth login", start); err == nil {
+		t.Error("Expected the failed summary reported")
+	}
+	if err := recordSessionIntent(cfg, "Add OAuth login", start); err != nil {
+		t.Errorf("Expected no second attempt, got %v", err)
+	}
+	if hits != 1 {
+		t.Errorf("Expected one summary request, got %d", hits)
+	}
+	current := getCurrentSession(cfg)
+	if current == nil || current.Intent != "" || !current.IntentAttempted {
+		t.Errorf("Expected the attempt recorded without an intent, got %+v", current)
+	}
+	audit, _ := os.ReadFile(cfg.AuditLog)
+	if !strings.Contains(string(audit), "SESSION_INTENT_FAILED: auth-work") {
+		t.Errorf("Expected the failure audited, got %q", string(audit))
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseSessionStartArgs splits `session start` arguments into the session
+// name, an optional --backend and --haiku/--sonnet/--opus tier models
+func parseSessionStartArgs(args []string) (name, backend string, models map[string]string, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			if name != "" {
+				return "", "", nil, fmt.Errorf("unexpected argument '%s'", arg)
+			}
+			name = arg
+			continue
+		}
+		flag, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !hasValue {
+			if i+1 >= len(args) {
+				return "", "", nil, fmt.Errorf("--%s requires a value", flag)
+			}
+			value = args[i+1]
+			i++
+		}
+		switch flag {
+		case "backend":
+			backend = strings.ToLower(value)
+		case "haiku", "sonnet", "opus":
+			if err := validateModelName(value); err != nil {
+				return "", "", nil, fmt.Errorf("invalid --%s model: %w", flag, err)
+			}
+			if models == nil {
+				models = make(map[string]string)
+			}
+			models[flag] = value
+		default:
+			return "", "", nil, fmt.Errorf("unknown flag --%s", flag)
+		}
+	}
+	if name == "" {
+		return "", "", nil, fmt.Errorf("a session name is required")
+	}
+	return name, backend, models, nil
+}
+
+// sessionModel returns the current session's model for a tier of backend
+func sessionModel(cfg *Config, backend, tier string) (string, bool) {
+	m, ok := cfg.SessionModels[backend][tier]
+	return m, ok && m != ""
+}
+
+// applySessionModels loads the current session's tier models so they
+// override the global ones for the session's backend. It returns the
+// session they came from, or nil when there are none.
+func applySessionModels(cfg *Config) *Session {
+	session := getCurrentSession(cfg)
+	if session == nil || len(session.Models) == 0 || session.Status == "closed" {
+		return nil
+	}
+	if cfg.SessionModels == nil {
+		cfg.SessionModels = make(map[string]map[string]string)
+	}
+	cfg.SessionModels[session.Backend] = session.Models
+	return session
+}
+
+// formatSessionModels lists tier models in tier order, e.g. "opus o1"
+func formatSessionModels(models map[string]string) string {
+	var parts []string
+	for _, tier := range modelTiers {
+		if m, ok := models[tier]; ok {
+			parts = append(parts, tier+" "+m)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// announceSessionModels prints the session models a launch on be uses
+func announceSessionModels(session *Session, be Backend) {
+	if session == nil || session.Backend != be.Name {
+		return
+	}
+	fmt.Printf("INFO: Session '%s' models: %s\n", session.Name, formatSessionModels(session.Models))
+}
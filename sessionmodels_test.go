@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSessionStartArgs(t *testing.T) {
+	name, backend, models, err := parseSessionStartArgs([]string{"big-refactor", "--opus", "o1", "--backend", "OpenAI", "--haiku=gpt-4o-mini"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "big-refactor" || backend != "openai" {
+		t.Errorf("Unexpected name %q backend %q", name, backend)
+	}
+	if len(models) != 2 || models["opus"] != "o1" || models["haiku"] != "gpt-4o-mini" {
+		t.Errorf("Unexpected models %v", models)
+	}
+
+	if _, _, models, err := parseSessionStartArgs([]string{"plain"}); err != nil || models != nil {
+		t.Errorf("Expected a plain session, got %v %v", models, err)
+	}
+
+	invalid := [][]string{
+		{},
+		{"--opus", "o1"},
+		{"a", "b"},
+		{"a", "--opus"},
+		{"a", "--opus", "bad model;"},
+		{"a", "--fast", "x"},
+	}
+	for _, args := range invalid {
+		if _, _, _, err := parseSessionStartArgs(args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
+
+func TestSessionModelsApplyToTheirBackend(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newSelfTestConfig(dir)
+	cfg.PinnedModels = map[string]map[string]string{"openai": {"opus": "o1-2024-12-17"}}
+
+	session, err := createSession(cfg, "big-refactor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applySessionModels(cfg) != nil {
+		t.Error("Expected nothing applied for a session without models")
+	}
+
+	err = updateSession(cfg, session.ID, func(s *Session) {
+		s.Backend = "openai"
+		s.Models = map[string]string{"opus": "o3", "haiku": "gpt-4o-mini"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := applySessionModels(cfg); s == nil || s.Name != "big-refactor" {
+		t.Fatalf("Expected the session's models applied, got %+v", s)
+	}
+
+	haiku, sonnet, opus := resolveTierModels(cfg, backends["openai"])
+	if haiku != "gpt-4o-mini" || opus != "o3" || sonnet != backends["openai"].SonnetModel {
+		t.Errorf("Expected session models over pins, got %s %s %s", haiku, sonnet, opus)
+	}
+	if _, _, opus := resolveTierModels(cfg, backends["deepseek"]); opus != backends["deepseek"].OpusModel {
+		t.Errorf("Expected other backends unaffected, got %s", opus)
+	}
+	if got := formatSessionModels(map[string]string{"opus": "o3", "haiku": "gpt-4o-mini"}); got != "haiku gpt-4o-mini, opus o3" {
+		t.Errorf("Unexpected formatting %q", got)
+	}
+
+	// Claude launches take session models as tier overrides
+	cfg.SessionModels["claude"] = map[string]string{"opus": "claude-opus-4-1"}
+	env, err := buildLaunchEnv(cfg, backends["claude"], nil, "")
+	joined := strings.Join(env, "\n")
+	if err != nil || !strings.Contains(joined, "ANTHROPIC_DEFAULT_OPUS_MODEL=claude-opus-4-1") || strings.Contains(joined, "SONNET_MODEL") {
+		t.Errorf("Unexpected Claude env %v %v", env, err)
+	}
+
+	// Closed sessions no longer carry their models
+	updateSession(cfg, session.ID, func(s *Session) { s.Status = "closed" })
+	fresh := newSelfTestConfig(dir)
+	if applySessionModels(fresh) != nil {
+		t.Error("Expected a closed session's models ignored")
+	}
+}
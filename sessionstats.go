@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// contextWindowTokens is the context window Claude Code works within
+const contextWindowTokens = 200000
+
+// Context growth thresholds used by session stats
+const (
+	contextCompactPercent = 60 // suggest /compact at this share of the window
+	contextRestartPercent = 85 // suggest a fresh session at this share
+	contextGrowthTurns    = 5  // recent turns used to estimate growth per turn
+	contextWarnTurnsLeft  = 5  // warn when /compact is this many turns away
+	contextChartWidth     = 30
+	contextChartMaxTurns  = 40
+)
+
+// SessionStats summarizes the proxied requests of a session. Each request
+// resends the conversation so far, making input tokens a measure of context size.
+type SessionStats struct {
+	Turns         []UsageRecord
+	TotalCost     float64
+	TotalOutput   int64
+	PeakInput     int64
+	GrowthPerTurn float64
+}
+
+// sessionTurns returns the usage records of a session ordered by time
+func sessionTurns(records usageSource, sessionID string) []UsageRecord {
+	var turns []UsageRecord
+	records(func(r UsageRecord) {
+		if r.SessionID == sessionID {
+			turns = append(turns, r)
+		}
+	})
+	sort.SliceStable(turns, func(i, j int) bool { return turns[i].Timestamp.Before(turns[j].Timestamp) })
+	return turns
+}
+
+// computeSessionStats totals a session's turns and estimates how fast its
+// context grows over the most recent turns
+func computeSessionStats(turns []UsageRecord) SessionStats {
+	s := SessionStats{Turns: turns}
+	for _, t := range turns {
+		s.TotalCost += t.CostUSD
+		s.TotalOutput += t.OutputTokens
+		if t.InputTokens > s.PeakInput {
+			s.PeakInput = t.InputTokens
+		}
+	}
+	if n := len(turns); n > 1 {
+		span := contextGrowthTurns
+		if span > n-1 {
+			span = n - 1
+		}
+		s.GrowthPerTurn = float64(turns[n-1].InputTokens-turns[n-1-span].InputTokens) / float64(span)
+	}
+	return s
+}
+
+// LastInput returns the context size of the most recent turn
+func (s SessionStats) LastInput() int64 {
+	if len(s.Turns) == 0 {
+		return 0
+	}
+	return s.Turns[len(s.Turns)-1].InputTokens
+}
+
+// contextGrowthWarning returns advice when the context is large or growing
+// fast enough that /compact or a new session is due, or an empty string
+func contextGrowthWarning(s SessionStats) string {
+	last := s.LastInput()
+	percent := float64(last) / contextWindowTokens * 100
+	compactAt := int64(contextWindowTokens * contextCompactPercent / 100)
+
+	switch {
+	case percent >= contextRestartPercent:
+		return fmt.Sprintf("context is at %.0f%% of the window (%s tokens) - start a new session; /compact will lose detail at this size", percent, formatNumber(last))
+	case percent >= contextCompactPercent:
+		return fmt.Sprintf("context is at %.0f%% of the window (%s tokens) - run /compact to keep responses fast and cheap", percent, formatNumber(last))
+	case s.GrowthPerTurn > 0:
+		turnsLeft := int(float64(compactAt-last)/s.GrowthPerTurn) + 1
+		if turnsLeft <= contextWarnTurnsLeft {
+			return fmt.Sprintf("context grows about %s tokens per turn; /compact will be due in about %d turns", formatNumber(int64(s.GrowthPerTurn)), turnsLeft)
+		}
+	}
+	return ""
+}
+
+// renderContextChart draws one bar per turn scaled to the context window,
+// marking turns past the /compact threshold
+func renderContextChart(turns []UsageRecord) []string {
+	if len(turns) > contextChartMaxTurns {
+		turns = turns[len(turns)-contextChartMaxTurns:]
+	}
+	lines := make([]string, 0, len(turns))
+	for _, t := range turns {
+		filled := int(float64(t.InputTokens) / contextWindowTokens * contextChartWidth)
+		if filled > contextChartWidth {
+			filled = contextChartWidth
+		}
+		bar := strings.Repeat("#", filled) + strings.Repeat(".", contextChartWidth-filled)
+		if float64(t.InputTokens)/contextWindowTokens*100 >= contextCompactPercent {
+			bar = styleWarning.Render(bar)
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s %8s in %8s out  %s",
+			t.Timestamp.Format("15:04:05"), bar, formatNumber(t.InputTokens), formatNumber(t.OutputTokens), formatCurrency(t.CostUSD)))
+	}
+	return lines
+}
+
+func showSessionStats(name string) {
+	cfg := loadConfig()
+	session := findSession(cfg, name, "stats")
+
+	stats := computeSessionStats(sessionTurns(ledgerUsage(cfg), session.ID))
+
+	fmt.Println()
+	fmt.Println(styleSection.Render(fmt.Sprintf("SESSION STATS: %s", session.Name)))
+	fmt.Println()
+	if len(stats.Turns) == 0 {
+		fmt.Println(styleMuted.Render("No proxied requests recorded for this session"))
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf("Turns: %d   Cost: %s   Avg/turn: %s\n",
+		len(stats.Turns), formatCurrency(stats.TotalCost), formatCurrency(stats.TotalCost/float64(len(stats.Turns))))
+	fmt.Printf("Context: %s now, %s peak (%s window)   Output: %s\n",
+		formatNumber(stats.LastInput()), formatNumber(stats.PeakInput), formatNumber(contextWindowTokens), formatNumber(stats.TotalOutput))
+	if stats.GrowthPerTurn != 0 {
+		fmt.Printf("Growth: %+d tokens/turn over the last %d turns\n", int64(stats.GrowthPerTurn), min(contextGrowthTurns, len(stats.Turns)-1))
+	}
+	fmt.Println()
+
+	if len(stats.Turns) > contextChartMaxTurns {
+		fmt.Println(styleMuted.Render(fmt.Sprintf("Showing the last %d of %d turns", contextChartMaxTurns, len(stats.Turns))))
+	}
+	for _, line := range renderContextChart(stats.Turns) {
+		fmt.Println(line)
+	}
+	fmt.Println()
+
+	if msg := contextGrowthWarning(stats); msg != "" {
+		fmt.Fprintln(os.Stderr, styleWarning.Render("Warning: "+msg))
+		fmt.Println()
+	}
+}
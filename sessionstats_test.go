@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func growthTurns(inputs ...int64) []UsageRecord {
+	start := time.Now().Add(-time.Hour)
+	turns := make([]UsageRecord, len(inputs))
+	for i, in := range inputs {
+		turns[i] = UsageRecord{Timestamp: start.Add(time.Duration(i) * time.Minute), SessionID: "s1", InputTokens: in, OutputTokens: 100, CostUSD: 0.01}
+	}
+	return turns
+}
+
+func TestSessionTurns(t *testing.T) {
+	now := time.Now()
+	records := []UsageRecord{
+		{Timestamp: now, SessionID: "s1", InputTokens: 300},
+		{Timestamp: now.Add(-2 * time.Minute), SessionID: "s1", InputTokens: 100},
+		{Timestamp: now.Add(-time.Minute), SessionID: "other", InputTokens: 999},
+		{Timestamp: now.Add(-time.Minute), SessionID: "s1", InputTokens: 200},
+	}
+
+	turns := sessionTurns(usageRecords(records), "s1")
+	if len(turns) != 3 {
+		t.Fatalf("Expected 3 turns, got %d", len(turns))
+	}
+	for i, want := range []int64{100, 200, 300} {
+		if turns[i].InputTokens != want {
+			t.Errorf("Turn %d: expected %d input tokens, got %d", i, want, turns[i].InputTokens)
+		}
+	}
+}
+
+func TestComputeSessionStats(t *testing.T) {
+	stats := computeSessionStats(growthTurns(1000, 5000, 3000, 9000, 13000, 17000, 21000))
+
+	if stats.PeakInput != 21000 || stats.LastInput() != 21000 {
+		t.Errorf("Expected peak and last 21000, got %d and %d", stats.PeakInput, stats.LastInput())
+	}
+	if stats.TotalOutput != 700 {
+		t.Errorf("Expected 700 output tokens, got %d", stats.TotalOutput)
+	}
+	// Growth is measured over the last contextGrowthTurns turns: (21000-5000)/5
+	if stats.GrowthPerTurn != 3200 {
+		t.Errorf("Expected growth 3200 tokens/turn, got %.0f", stats.GrowthPerTurn)
+	}
+
+	if single := computeSessionStats(growthTurns(5000)); single.GrowthPerTurn != 0 {
+		t.Errorf("Expected no growth for a single turn, got %.0f", single.GrowthPerTurn)
+	}
+	if empty := computeSessionStats(nil); empty.LastInput() != 0 {
+		t.Errorf("Expected 0 last input for no turns, got %d", empty.LastInput())
+	}
+}
+
+func TestContextGrowthWarning(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs []int64
+		want   string
+	}{
+		{"small and steady", []int64{10000, 11000, 12000}, ""},
+		{"approaching compact", []int64{60000, 80000, 100000}, "/compact will be due in about"},
+		{"past compact", []int64{100000, 130000}, "run /compact"},
+		{"near the window", []int64{150000, 180000}, "start a new session"},
+		{"shrinking after compact", []int64{110000, 20000}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := contextGrowthWarning(computeSessionStats(growthTurns(tt.inputs...)))
+			if tt.want == "" && got != "" {
+				t.Errorf("Expected no warning, got %q", got)
+			}
+			if tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("Expected warning containing %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderContextChart(t *testing.T) {
+	lines := renderContextChart(growthTurns(0, 100000, 400000))
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %d", len(lines))
+	}
+	if !strings.Contains(lines[1], strings.Repeat("#", contextChartWidth/2)+strings.Repeat(".", contextChartWidth/2)) {
+		t.Errorf("Expected a half-filled bar, got %q", lines[1])
+	}
+	if !strings.Contains(lines[2], strings.Repeat("#", contextChartWidth)) {
+		t.Errorf("Expected a full bar capped at the window, got %q", lines[2])
+	}
+
+	many := make([]int64, contextChartMaxTurns+10)
+	if got := len(renderContextChart(growthTurns(many...))); got != contextChartMaxTurns {
+		t.Errorf("Expected chart capped at %d turns, got %d", contextChartMaxTurns, got)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// sessionUpdateAttempts bounds how often updateSessions reloads after
+// losing a race with another process
+const sessionUpdateAttempts = 5
+
+// errSessionsChanged is returned when the sessions file was written by
+// another process after it was loaded
+var errSessionsChanged = errors.New("sessions file changed since it was loaded")
+
+// sessionsVersion identifies the contents of the sessions file. A missing
+// file has the empty version.
+func sessionsVersion(data []byte) string {
+	if data == nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// saveSessionsIfUnchanged writes sessions only if the file still has the
+// version they were loaded at, so updates made meanwhile by another process
+// (a second terminal, a swarm, the proxy's run summary) are not overwritten
+func saveSessionsIfUnchanged(cfg *Config, sessions []*Session, version string) error {
+	return withFileLock(cfg.SessionsFile+".lock", func() error {
+		current, err := os.ReadFile(cfg.SessionsFile)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if sessionsVersion(current) != version {
+			return errSessionsChanged
+		}
+		data, err := json.MarshalIndent(sessions, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(cfg.SessionsFile, data, 0600)
+	})
+}
+
+// updateSessions applies fn to the current sessions and saves the result.
+// When another process saves in between, fn is applied again to the newer
+// sessions, so fn must only depend on its argument.
+func updateSessions(cfg *Config, fn func([]*Session) ([]*Session, error)) error {
+	for attempt := 0; attempt < sessionUpdateAttempts; attempt++ {
+		sessions, version := loadSessionsVersion(cfg)
+		updated, err := fn(sessions)
+		if err != nil {
+			return err
+		}
+		err = saveSessionsIfUnchanged(cfg, updated, version)
+		if !errors.Is(err, errSessionsChanged) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w after %d attempts", errSessionsChanged, sessionUpdateAttempts)
+}
+
+// updateSession applies fn to the session with id, if it exists
+func updateSession(cfg *Config, id string, fn func(*Session)) error {
+	return updateSessions(cfg, func(sessions []*Session) ([]*Session, error) {
+		for _, s := range sessions {
+			if s != nil && s.ID == id {
+				fn(s)
+			}
+		}
+		return sessions, nil
+	})
+}
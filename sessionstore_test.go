@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSaveSessionsIfUnchangedDetectsConflict(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	sessions, version := loadSessionsVersion(cfg)
+	if version != "" || len(sessions) != 0 {
+		t.Fatalf("Expected an empty, unversioned store, got %d sessions at %q", len(sessions), version)
+	}
+
+	// Another process saves after our load
+	if err := saveSessions(cfg, []*Session{{ID: "other", Name: "other"}}); err != nil {
+		t.Fatal(err)
+	}
+	err := saveSessionsIfUnchanged(cfg, append(sessions, &Session{ID: "mine"}), version)
+	if !errors.Is(err, errSessionsChanged) {
+		t.Fatalf("Expected errSessionsChanged, got %v", err)
+	}
+	if got := loadSessions(cfg); len(got) != 1 || got[0].ID != "other" {
+		t.Errorf("Expected the other process's save to survive, got %v", got)
+	}
+
+	sessions, version = loadSessionsVersion(cfg)
+	if err := saveSessionsIfUnchanged(cfg, append(sessions, &Session{ID: "mine"}), version); err != nil {
+		t.Fatalf("Expected save at the current version to succeed: %v", err)
+	}
+}
+
+func TestUpdateSessionsRetriesAfterConflict(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	calls := 0
+	err := updateSessions(cfg, func(sessions []*Session) ([]*Session, error) {
+		calls++
+		if calls == 1 {
+			// Simulate another process saving between load and save
+			saveSessions(cfg, append(sessions, &Session{ID: "concurrent"}))
+		}
+		return append(sessions, &Session{ID: "mine"}), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected one retry, got %d calls", calls)
+	}
+	ids := map[string]bool{}
+	for _, s := range loadSessions(cfg) {
+		ids[s.ID] = true
+	}
+	if !ids["concurrent"] || !ids["mine"] || len(ids) != 2 {
+		t.Errorf("Expected both updates kept, got %v", ids)
+	}
+}
+
+func TestUpdateSessionsConcurrent(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- updateSessions(cfg, func(sessions []*Session) ([]*Session, error) {
+				return append(sessions, &Session{ID: fmt.Sprintf("s%d", i)}), nil
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	failed := 0
+	for err := range errs {
+		// Heavy contention may exhaust retries; that must be reported, not lost
+		if err != nil {
+			if !errors.Is(err, errSessionsChanged) {
+				t.Errorf("Unexpected error %v", err)
+			}
+			failed++
+		}
+	}
+	if got := len(loadSessions(cfg)); got+failed != writers {
+		t.Errorf("Expected %d sessions, got %d (%d reported failures)", writers, got, failed)
+	}
+}
+
+func TestUpdateSessionStopsOnError(t *testing.T) {
+	cfg := newSelfTestConfig(t.TempDir())
+	saveSessions(cfg, []*Session{{ID: "a", Status: "active"}})
+	want := errors.New("stop")
+	if err := updateSessions(cfg, func([]*Session) ([]*Session, error) { return nil, want }); err != want {
+		t.Errorf("Expected fn's error, got %v", err)
+	}
+	if err := updateSession(cfg, "a", func(s *Session) { s.Status = "closed" }); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadSessions(cfg); got[0].Status != "closed" {
+		t.Errorf("Expected session closed, got %s", got[0].Status)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spend sparkline window limits
+const (
+	defaultSparklineHours = 24
+	maxSparklineHours     = 168
+)
+
+// sparkLevels are the bar heights of a sparkline, lowest first
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// parseHoursFlag returns the --hours N (or --hours=N) value in args, or
+// defaultSparklineHours when it is absent
+func parseHoursFlag(args []string) (int, error) {
+	value := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--hours":
+			if i+1 >= len(args) {
+				return 0, fmt.Errorf("--hours requires a number of hours")
+			}
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--hours="):
+			value = strings.TrimPrefix(args[i], "--hours=")
+		}
+	}
+	if value == "" {
+		return defaultSparklineHours, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > maxSparklineHours {
+		return 0, fmt.Errorf("invalid --hours value '%s': use 1 to %d", value, maxSparklineHours)
+	}
+	return n, nil
+}
+
+// startOfHour returns the start of t's hour on the clock of t's location.
+// time.Truncate aligns to UTC, which is off by the offset in zones such as
+// UTC+5:30.
+func startOfHour(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// hourlySpend buckets usage costs into the given number of hours ending with
+// the current hour, oldest first. Hours follow now's location, local time for
+// the dashboard.
+func hourlySpend(records usageSource, now time.Time, hours int) []float64 {
+	buckets := make([]float64, hours)
+	start := startOfHour(now).Add(-time.Duration(hours-1) * time.Hour)
+	records(func(r UsageRecord) {
+		if r.Timestamp.Before(start) || r.Timestamp.After(now) {
+			return
+		}
+		buckets[int(r.Timestamp.Sub(start)/time.Hour)] += r.CostUSD
+	})
+	return buckets
+}
+
+// sparkline draws values scaled to the largest. Hours without spend use the
+// lowest bar and any spend at least the second, so small costs stay visible.
+func sparkline(values []float64) string {
+	peak := 0.0
+	for _, v := range values {
+		if v > peak {
+			peak = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if v > 0 {
+			level = 1 + int(v/peak*float64(len(sparkLevels)-2)+0.5)
+		}
+		b.WriteRune(sparkLevels[level])
+	}
+	return b.String()
+}
+
+// renderSpendSparkline prints hourly spend over the window so a runaway
+// agent loop stands out as a spike
+func renderSpendSparkline(records usageSource, now time.Time, hours int) {
+	buckets := hourlySpend(records, now, hours)
+	total, peak, peakHour := 0.0, 0.0, 0
+	for i, v := range buckets {
+		total += v
+		if v > peak {
+			peak, peakHour = v, i
+		}
+	}
+
+	line := fmt.Sprintf("%s  %s  %s",
+		styleLabel.Render(fmt.Sprintf("Last %dh", hours)),
+		sparkline(buckets),
+		styleValue.Render(formatCurrency(total)))
+	if peak > 0 {
+		at := startOfHour(now).Add(-time.Duration(hours-1-peakHour) * time.Hour)
+		line += styleMuted.Render(fmt.Sprintf("  (peak %s at %s)", formatCurrency(peak), at.Local().Format("15:04")))
+	}
+	fmt.Println(line)
+}
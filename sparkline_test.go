@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHoursFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{nil, defaultSparklineHours, false},
+		{[]string{"status", "--details"}, defaultSparklineHours, false},
+		{[]string{"status", "--hours", "6"}, 6, false},
+		{[]string{"--hours=48"}, 48, false},
+		{[]string{"--hours"}, 0, true},
+		{[]string{"--hours", "0"}, 0, true},
+		{[]string{"--hours", "abc"}, 0, true},
+		{[]string{"--hours=500"}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseHoursFlag(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHoursFlag(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseHoursFlag(%v) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestHourlySpend(t *testing.T) {
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+	records := []UsageRecord{
+		{Timestamp: now.Add(-10 * time.Minute), CostUSD: 1.00},                    // current hour
+		{Timestamp: time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC), CostUSD: 0.50}, // start of current hour
+		{Timestamp: time.Date(2026, 3, 10, 12, 59, 0, 0, time.UTC), CostUSD: 0.25},
+		{Timestamp: time.Date(2026, 3, 10, 11, 59, 0, 0, time.UTC), CostUSD: 9.00}, // before the window
+		{Timestamp: now.Add(time.Hour), CostUSD: 9.00},                             // in the future
+	}
+	got := hourlySpend(usageRecords(records), now, 3)
+	want := []float64{0.25, 0, 1.50}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("hourlySpend = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestHourlySpendLocalHours(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+30*60)
+	now := time.Date(2026, 3, 10, 14, 10, 0, 0, ist)
+	records := []UsageRecord{
+		{Timestamp: time.Date(2026, 3, 10, 14, 5, 0, 0, ist), CostUSD: 1.00},  // current local hour
+		{Timestamp: time.Date(2026, 3, 10, 13, 55, 0, 0, ist), CostUSD: 0.50}, // previous local hour
+	}
+	got := hourlySpend(usageRecords(records), now, 2)
+	if got[0] != 0.50 || got[1] != 1.00 {
+		t.Errorf("Expected buckets on local hour boundaries, got %v", got)
+	}
+	if at := startOfHour(now); at.Hour() != 14 || at.Minute() != 0 {
+		t.Errorf("startOfHour = %s", at)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]float64{0, 0, 0}); got != "▁▁▁" {
+		t.Errorf("Expected a flat line without spend, got %q", got)
+	}
+	got := []rune(sparkline([]float64{0, 0.01, 5, 10}))
+	if got[0] != '▁' || got[1] != '▂' || got[3] != '█' {
+		t.Errorf("Unexpected sparkline %q", string(got))
+	}
+	if got[2] <= got[1] || got[2] >= got[3] {
+		t.Errorf("Expected the middle value between its neighbours, got %q", string(got))
+	}
+}
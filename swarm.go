@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// Swarm defaults and limits
+const (
+	defaultSwarmPort = 18100
+	maxSwarmSize     = 32
+)
+
+// swarmBackends are the backends served through a local translating proxy
+var swarmBackends = []string{"ollama"}
+
+// SwarmOptions are the parsed arguments of `swarm start`
+type SwarmOptions struct {
+	Count    int
+	Backend  string
+	BasePort int
+	JSON     bool
+}
+
+// SwarmInstance is one proxy of a swarm and the session its usage is logged to
+type SwarmInstance struct {
+	Index     int    `json:"index"`
+	Port      int    `json:"port"`
+	Endpoint  string `json:"endpoint"`
+	SessionID string `json:"session_id"`
+	Session   string `json:"session"`
+}
+
+// SwarmTotal is the aggregated usage of one swarm instance
+type SwarmTotal struct {
+	Instance     SwarmInstance
+	Requests     int
+	InputTokens  int64
+	OutputTokens int64
+	CostUSD      float64
+}
+
+// parseSwarmArgs parses `-n <count> --backend <name> --port <base> --json`
+func parseSwarmArgs(args []string, defaultBackend string) (SwarmOptions, error) {
+	opts := SwarmOptions{Count: 1, Backend: defaultBackend, BasePort: defaultSwarmPort}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return opts, fmt.Errorf("unexpected argument '%s'", arg)
+		}
+		value := ""
+		if name, v, ok := strings.Cut(arg, "="); ok {
+			arg, value = name, v
+		} else if i+1 >= len(args) {
+			return opts, fmt.Errorf("%s requires a value", arg)
+		} else {
+			value = args[i+1]
+			i++
+		}
+
+		switch arg {
+		case "-n", "--count":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxSwarmSize {
+				return opts, fmt.Errorf("invalid count '%s': must be between 1 and %d", value, maxSwarmSize)
+			}
+			opts.Count = n
+		case "--backend":
+			opts.Backend = strings.ToLower(value)
+		case "--port":
+			p, err := strconv.Atoi(value)
+			if err != nil || p < 1024 || p > 65535 {
+				return opts, fmt.Errorf("invalid port '%s'", value)
+			}
+			opts.BasePort = p
+		default:
+			return opts, fmt.Errorf("unknown flag '%s'", arg)
+		}
+	}
+
+	supported := false
+	for _, name := range swarmBackends {
+		supported = supported || name == opts.Backend
+	}
+	if !supported {
+		return opts, fmt.Errorf("backend '%s' has no local proxy; swarm supports: %s", opts.Backend, strings.Join(swarmBackends, ", "))
+	}
+	if opts.BasePort+opts.Count-1 > 65535 {
+		return opts, errors.New("port range exceeds 65535")
+	}
+	return opts, nil
+}
+
+// portAvailable reports whether a local TCP port can be bound
+func portAvailable(port int) bool {
+	ln, err := net.Listen("tcp", proxyAddr(port))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// createSwarmSessions records one session per swarm instance without
+// changing the current session
+func createSwarmSessions(cfg *Config, swarmID, backend string, count int) ([]*Session, error) {
+	now := time.Now()
+	created := make([]*Session, 0, count)
+	for i := 1; i <= count; i++ {
+		name := fmt.Sprintf("%s-%d", swarmID, i)
+		id, err := generateSessionID(name)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, &Session{
+			ID:         id,
+			Name:       name,
+			Backend:    backend,
+			StartTime:  now,
+			LastActive: now,
+			WorkingDir: getWorkingDir(),
+			Status:     "active",
+		})
+	}
+	err := updateSessions(cfg, func(sessions []*Session) ([]*Session, error) {
+		return append(sessions, created...), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save sessions: %w", err)
+	}
+	return created, nil
+}
+
+// closeSwarmSessions marks swarm sessions closed and stores their costs
+func closeSwarmSessions(cfg *Config, totals []SwarmTotal) error {
+	byID := make(map[string]SwarmTotal, len(totals))
+	for _, t := range totals {
+		byID[t.Instance.SessionID] = t
+	}
+	return updateSessions(cfg, func(sessions []*Session) ([]*Session, error) {
+		for _, s := range sessions {
+			if t, ok := byID[s.ID]; ok {
+				s.Status = "closed"
+				s.LastActive = time.Now()
+				s.PromptCount = t.Requests
+				s.TotalCost = t.CostUSD
+			}
+		}
+		return sessions, nil
+	})
+}
+
+// swarmTotals aggregates usage records per swarm instance
+func swarmTotals(records usageSource, instances []SwarmInstance) []SwarmTotal {
+	index := make(map[string]int, len(instances))
+	totals := make([]SwarmTotal, len(instances))
+	for i, inst := range instances {
+		index[inst.SessionID] = i
+		totals[i].Instance = inst
+	}
+	records(func(r UsageRecord) {
+		i, ok := index[r.SessionID]
+		if !ok {
+			return
+		}
+		totals[i].Requests++
+		totals[i].InputTokens += r.InputTokens
+		totals[i].OutputTokens += r.OutputTokens
+		totals[i].CostUSD += r.CostUSD
+	})
+	return totals
+}
+
+func handleSwarmCommand(args []string) {
+	if len(args) == 0 || args[0] != "start" {
+		fmt.Fprintln(os.Stderr, "Usage: promptops swarm start [-n <count>] [--backend ollama] [--port <base>] [--json]")
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+	opts, err := parseSwarmArgs(args[1:], swarmBackends[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts.JSON = globalOpts.JSON
+	startSwarm(cfg, opts)
+}
+
+// startSwarm runs opts.Count isolated proxies in the foreground until
+// interrupted, then prints the usage of each instance and of the swarm
+func startSwarm(cfg *Config, opts SwarmOptions) {
+	be := backends[opts.Backend]
+	git := currentGitInfo()
+	if err := checkTrainingPolicy(cfg, be, git); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := enforceRegionPolicy(cfg, be, git); err != nil {
+		auditLog(cfg, fmt.Sprintf("SWARM_BLOCKED: %s (data region policy)", be.Name))
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for i := 0; i < opts.Count; i++ {
+		if port := opts.BasePort + i; !portAvailable(port) {
+			fmt.Fprintf(os.Stderr, "Error: port %d is in use; choose another range with --port\n", port)
+			os.Exit(1)
+		}
+	}
+
+	swarmID := "swarm-" + time.Now().Format("20060102-150405")
+	sessions, err := createSwarmSessions(cfg, swarmID, be.Name, opts.Count)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	tracker := NewModelTracker(cfg)
+	primer := projectContextPrimer(cfg)
+	announceChaos(cfg)
+	instances := make([]SwarmInstance, 0, opts.Count)
+	proxies := make([]*OllamaProxy, 0, opts.Count)
+	stopAll := func() {
+		for _, p := range proxies {
+			p.Stop()
+		}
+	}
+	for i, s := range sessions {
+		port := opts.BasePort + i
+		proxy := newOllamaProxy(cfg, be, be.BaseURL, tracker, s.ID)
+		proxy.SetSystemPrimer(primer)
+		if err := proxy.Start(port); err != nil {
+			stopAll()
+			fmt.Fprintf(os.Stderr, "Error starting proxy on port %d: %v\n", port, err)
+			os.Exit(1)
+		}
+		proxies = append(proxies, proxy)
+		instances = append(instances, SwarmInstance{
+			Index:     i + 1,
+			Port:      port,
+			Endpoint:  proxyURL(port),
+			SessionID: s.ID,
+			Session:   s.Name,
+		})
+	}
+	auditLog(cfg, fmt.Sprintf("SWARM_START: %s %s x%d ports %d-%d", swarmID, be.Name, opts.Count, opts.BasePort, opts.BasePort+opts.Count-1))
+
+	if opts.JSON {
+		data, _ := json.MarshalIndent(instances, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		renderSwarmInstances(swarmID, be, instances)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	signal.Stop(sig)
+	stopAll()
+	flushUsageOnExit(cfg)
+
+	totals := swarmTotals(ledgerUsage(cfg), instances)
+	if err := closeSwarmSessions(cfg, totals); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to close swarm sessions: %v\n", err)
+	}
+	auditLog(cfg, fmt.Sprintf("SWARM_STOP: %s", swarmID))
+	fmt.Fprintln(os.Stderr)
+	renderSwarmTotals(totals)
+}
+
+func renderSwarmInstances(swarmID string, be Backend, instances []SwarmInstance) {
+	fmt.Println()
+	fmt.Println(styleSection.Render(fmt.Sprintf("SWARM: %s (%d x %s)", swarmID, len(instances), be.DisplayName)))
+
+	rows := [][]string{}
+	for _, inst := range instances {
+		rows = append(rows, []string{strconv.Itoa(inst.Index), inst.Endpoint, inst.Session})
+	}
+	t := table.New().
+		Headers("#", "ANTHROPIC_BASE_URL", "Session").
+		Rows(rows...).
+		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		}).
+		Width(80)
+
+	fmt.Println(t.Render())
+	fmt.Println(styleMuted.Render("Point each agent at one endpoint. Press Ctrl+C to stop the swarm."))
+	fmt.Println()
+}
+
+// renderSwarmTotals prints per-instance usage followed by the swarm total
+func renderSwarmTotals(totals []SwarmTotal) {
+	fmt.Println(styleSection.Render("SWARM USAGE"))
+
+	var sum SwarmTotal
+	rows := [][]string{}
+	for _, t := range totals {
+		sum.Requests += t.Requests
+		sum.InputTokens += t.InputTokens
+		sum.OutputTokens += t.OutputTokens
+		sum.CostUSD += t.CostUSD
+		rows = append(rows, []string{
+			t.Instance.Session,
+			strconv.Itoa(t.Requests),
+			formatNumber(t.InputTokens),
+			formatNumber(t.OutputTokens),
+			formatCurrency(t.CostUSD),
+		})
+	}
+	rows = append(rows, []string{"Total", strconv.Itoa(sum.Requests), formatNumber(sum.InputTokens), formatNumber(sum.OutputTokens), formatCurrency(sum.CostUSD)})
+
+	t := table.New().
+		Headers("Session", "Requests", "Input", "Output", "Cost").
+		Rows(rows...).
+		BorderStyle(lipgloss.NewStyle().Foreground(colorSubtle)).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == 0 {
+				return lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
+			}
+			if row == len(rows) {
+				return lipgloss.NewStyle().Bold(true).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		}).
+		Width(80)
+
+	fmt.Println(t.Render())
+	fmt.Println()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSwarmArgs(t *testing.T) {
+	opts, err := parseSwarmArgs([]string{"-n", "4", "--backend", "Ollama", "--port=19000"}, "ollama")
+	if err != nil {
+		t.Fatalf("parseSwarmArgs() error: %v", err)
+	}
+	if opts.Count != 4 || opts.Backend != "ollama" || opts.BasePort != 19000 {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	defaults, err := parseSwarmArgs(nil, "ollama")
+	if err != nil {
+		t.Fatalf("parseSwarmArgs() error: %v", err)
+	}
+	if defaults.Count != 1 || defaults.BasePort != defaultSwarmPort {
+		t.Errorf("Unexpected defaults: %+v", defaults)
+	}
+
+	invalid := map[string][]string{
+		"zero count":        {"-n", "0"},
+		"too many":          {"-n", "1000"},
+		"missing value":     {"-n"},
+		"unproxied backend": {"--backend", "claude"},
+		"privileged port":   {"--port", "80"},
+		"port overflow":     {"--port", "65535", "-n", "2"},
+		"unknown flag":      {"--verbose", "1"},
+		"positional":        {"4"},
+	}
+	for name, args := range invalid {
+		if _, err := parseSwarmArgs(args, "ollama"); err == nil {
+			t.Errorf("%s: expected error for %v", name, args)
+		}
+	}
+}
+
+func TestSwarmTotals(t *testing.T) {
+	instances := []SwarmInstance{{Index: 1, SessionID: "a"}, {Index: 2, SessionID: "b"}}
+	records := []UsageRecord{
+		{SessionID: "a", InputTokens: 100, OutputTokens: 10, CostUSD: 0.5},
+		{SessionID: "a", InputTokens: 200, OutputTokens: 20, CostUSD: 0.25},
+		{SessionID: "other", InputTokens: 999, CostUSD: 9},
+	}
+
+	totals := swarmTotals(usageRecords(records), instances)
+	if len(totals) != 2 {
+		t.Fatalf("Expected 2 totals, got %d", len(totals))
+	}
+	if a := totals[0]; a.Requests != 2 || a.InputTokens != 300 || a.OutputTokens != 30 || a.CostUSD != 0.75 {
+		t.Errorf("Unexpected totals for instance 1: %+v", a)
+	}
+	if b := totals[1]; b.Requests != 0 || b.Instance.Index != 2 {
+		t.Errorf("Expected an empty total for instance 2, got %+v", b)
+	}
+}
+
+func TestSwarmSessions(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		SessionsFile: filepath.Join(dir, "sessions.json"),
+		SessionFile:  filepath.Join(dir, "session"),
+	}
+
+	sessions, err := createSwarmSessions(cfg, "swarm-test", "ollama", 3)
+	if err != nil {
+		t.Fatalf("createSwarmSessions() error: %v", err)
+	}
+	if len(sessions) != 3 || sessions[2].Name != "swarm-test-3" {
+		t.Fatalf("Unexpected sessions: %+v", sessions)
+	}
+	if sessions[0].ID == sessions[1].ID {
+		t.Error("Expected distinct session IDs")
+	}
+	if getCurrentSession(cfg) != nil {
+		t.Error("Expected the current session to be unchanged")
+	}
+
+	totals := []SwarmTotal{{Instance: SwarmInstance{SessionID: sessions[0].ID}, Requests: 5, CostUSD: 1.5}}
+	if err := closeSwarmSessions(cfg, totals); err != nil {
+		t.Fatalf("closeSwarmSessions() error: %v", err)
+	}
+	for _, s := range loadSessions(cfg) {
+		if s.ID == sessions[0].ID && (s.Status != "closed" || s.PromptCount != 5 || s.TotalCost != 1.5) {
+			t.Errorf("Expected closed session with totals, got %+v", s)
+		}
+		if s.ID == sessions[1].ID && s.Status != "active" {
+			t.Errorf("Expected untouched session to stay active, got %s", s.Status)
+		}
+	}
+}
+
+func TestPortAvailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	if portAvailable(port) {
+		t.Errorf("Expected port %d in use", port)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/charmbracelet/x/term"
+)
+
+// Output width
+const (
+	// defaultOutputWidth is used when stdout is not a terminal, as in CI logs
+	defaultOutputWidth = 80
+	minOutputWidth     = 40
+	// healthLineIndent is the width of a health line ahead of its message
+	healthLineIndent = 40
+	maxHealthMessage = 200
+)
+
+// outputWidth returns the width to fit output to: COLUMNS when set, else the
+// terminal's width, else defaultOutputWidth
+func outputWidth() int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("COLUMNS"))); err == nil && n > 0 {
+		return max(n, minOutputWidth)
+	}
+	if w, _, err := term.GetSize(os.Stdout.Fd()); err == nil && w > 0 {
+		return max(w, minOutputWidth)
+	}
+	return defaultOutputWidth
+}
+
+// tableColumn is a column of a table that reflows to the output width.
+// Columns with a higher Drop are removed first; 0 is never removed.
+type tableColumn struct {
+	Header string
+	Drop   int
+}
+
+// fitTable renders rows under cols no wider than the output, removing
+// optional columns until the table fits. build returns the table for the
+// kept headers and rows, without a width; full is the width it renders at
+// when there is room. With --wide every column is kept at full width or
+// wider.
+func fitTable(cols []tableColumn, rows [][]string, full int, build func(headers []string, rows [][]string) *table.Table) string {
+	avail := outputWidth()
+	keep := make([]int, len(cols))
+	for i := range cols {
+		keep[i] = i
+	}
+	for {
+		headers := make([]string, len(keep))
+		for i, c := range keep {
+			headers[i] = cols[c].Header
+		}
+		kept := make([][]string, len(rows))
+		for r, row := range rows {
+			kept[r] = make([]string, len(keep))
+			for i, c := range keep {
+				if c < len(row) {
+					kept[r][i] = row[c]
+				}
+			}
+		}
+		t := build(headers, kept)
+		natural := lipgloss.Width(t.Render())
+		if globalOpts.Wide {
+			return t.Width(max(full, natural)).Render()
+		}
+		drop := -1
+		for i, c := range keep {
+			if cols[c].Drop > 0 && (drop < 0 || cols[c].Drop > cols[keep[drop]].Drop) {
+				drop = i
+			}
+		}
+		if natural <= avail || drop < 0 {
+			return t.Width(min(full, avail)).Render()
+		}
+		keep = append(keep[:drop], keep[drop+1:]...)
+	}
+}
+
+// truncateCell shortens table text to n characters unless --wide asks for
+// full detail
+func truncateCell(s string, n int) string {
+	if globalOpts.Wide {
+		return s
+	}
+	return truncate(s, n)
+}
+
+// wrapHealthMessage fits a health check message to the output, continuing
+// long messages on lines indented under the message column
+func wrapHealthMessage(msg string) string {
+	msg = truncate(msg, maxHealthMessage)
+	width := outputWidth() - healthLineIndent
+	if globalOpts.Wide || width <= 0 || len([]rune(msg)) <= width {
+		return msg
+	}
+	lines := strings.Split(lipgloss.NewStyle().Width(width).Render(msg), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return strings.Join(lines, "\n"+strings.Repeat(" ", healthLineIndent))
+}
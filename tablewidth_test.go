@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// setWideTables sets --wide for the length of a test
+func setWideTables(t *testing.T, wide bool) {
+	t.Helper()
+	old := globalOpts.Wide
+	globalOpts.Wide = wide
+	t.Cleanup(func() { globalOpts.Wide = old })
+}
+
+func plainTable(headers []string, rows [][]string) *table.Table {
+	return table.New().Headers(headers...).Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+}
+
+var testTableColumns = []tableColumn{{"Name", 0}, {"Details", 2}, {"Tokens", 1}, {"Cost", 0}}
+
+var testTableRows = [][]string{
+	{"deepseek", strings.Repeat("detail ", 8), "1,234,567", "$1.23"},
+	{"ollama", "local", "42", "$0.00"},
+}
+
+func TestOutputWidth(t *testing.T) {
+	t.Setenv("COLUMNS", "132")
+	if got := outputWidth(); got != 132 {
+		t.Errorf("outputWidth() = %d, want 132", got)
+	}
+	t.Setenv("COLUMNS", "10")
+	if got := outputWidth(); got != minOutputWidth {
+		t.Errorf("Expected at least %d columns, got %d", minOutputWidth, got)
+	}
+	// Test output is not a terminal
+	t.Setenv("COLUMNS", "wide")
+	if got := outputWidth(); got != defaultOutputWidth {
+		t.Errorf("Expected %d columns without a terminal, got %d", defaultOutputWidth, got)
+	}
+}
+
+func TestFitTableDropsColumns(t *testing.T) {
+	setWideTables(t, false)
+	t.Setenv("COLUMNS", "200")
+	out := fitTable(testTableColumns, testTableRows, 100, plainTable)
+	if !strings.Contains(out, "Details") || lipgloss.Width(out) != 100 {
+		t.Errorf("Expected every column at full width, got width %d:\n%s", lipgloss.Width(out), out)
+	}
+
+	// The highest Drop goes first
+	t.Setenv("COLUMNS", "50")
+	out = fitTable(testTableColumns, testTableRows, 100, plainTable)
+	if strings.Contains(out, "Details") || !strings.Contains(out, "Tokens") {
+		t.Errorf("Expected only Details dropped:\n%s", out)
+	}
+	if lipgloss.Width(out) > 50 {
+		t.Errorf("Expected at most 50 columns, got %d", lipgloss.Width(out))
+	}
+
+	// Name and cost are always kept
+	columns := []tableColumn{{"Name", 0}, {"Details", 1}, {"Cost", 0}}
+	rows := [][]string{{strings.Repeat("n", 30), strings.Repeat("d", 30), strings.Repeat("c", 30)}}
+	out = fitTable(columns, rows, 100, plainTable)
+	if strings.Contains(out, "Details") || !strings.Contains(out, "Name") || !strings.Contains(out, "Cost") {
+		t.Errorf("Expected only Details dropped:\n%s", out)
+	}
+}
+
+func TestFitTableWide(t *testing.T) {
+	setWideTables(t, true)
+	t.Setenv("COLUMNS", "40")
+	out := fitTable(testTableColumns, testTableRows, 30, plainTable)
+	if !strings.Contains(out, "Details") || !strings.Contains(out, "1,234,567") {
+		t.Errorf("Expected every column with --wide:\n%s", out)
+	}
+	if strings.Count(out, "\n") != 5 {
+		t.Errorf("Expected no wrapped cells with --wide:\n%s", out)
+	}
+}
+
+func TestTruncateCell(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	setWideTables(t, false)
+	if got := truncateCell(long, 10); got != truncate(long, 10) {
+		t.Errorf("truncateCell() = %q", got)
+	}
+	setWideTables(t, true)
+	if got := truncateCell(long, 10); got != long {
+		t.Errorf("Expected full text with --wide, got %q", got)
+	}
+}
+
+func TestWrapHealthMessage(t *testing.T) {
+	setWideTables(t, false)
+	t.Setenv("COLUMNS", "60")
+	msg := "dial tcp: lookup api.example.com: no such host, retrying with the fallback resolver"
+	got := wrapHealthMessage(msg)
+	lines := strings.Split(got, "\n")
+	if len(lines) < 2 {
+		t.Fatalf("Expected a wrapped message, got %q", got)
+	}
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, strings.Repeat(" ", healthLineIndent)) || len(line) > 60 {
+			t.Errorf("Expected continuation lines indented and within 60 columns, got %q", line)
+		}
+	}
+	if strings.Join(strings.Fields(got), " ") != msg {
+		t.Errorf("Expected no text lost, got %q", got)
+	}
+
+	if got := wrapHealthMessage("HTTP 500"); got != "HTTP 500" {
+		t.Errorf("Expected a short message unchanged, got %q", got)
+	}
+	setWideTables(t, true)
+	if got := wrapHealthMessage(msg); got != msg {
+		t.Errorf("Expected one line with --wide, got %q", got)
+	}
+}
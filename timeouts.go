@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Network timeout kinds, configured with NEXUS_<KIND>_TIMEOUT and
+// NEXUS_<KIND>_TIMEOUT_<BACKEND>
+const (
+	timeoutHealth  = "health"  // Health checks (status --check, doctor, validate, switch verification)
+	timeoutUsage   = "usage"   // Provider usage APIs
+	timeoutAPI     = "api"     // Claude Code API requests (API_TIMEOUT_MS)
+	timeoutLatency = "latency" // Proxied upstream requests (NEXUS_LATENCY_BUDGET)
+)
+
+// timeoutKeyPrefix returns the environment key of a timeout kind
+func timeoutKeyPrefix(kind string) string {
+	if kind == timeoutLatency {
+		return "NEXUS_LATENCY_BUDGET"
+	}
+	return "NEXUS_" + strings.ToUpper(kind) + "_TIMEOUT"
+}
+
+// Default network timeouts
+const (
+	defaultHealthTimeout = 5 * time.Second
+	defaultUsageTimeout  = 10 * time.Second
+)
+
+// parseTimeout accepts a Go duration ("30s", "2m") or a number of seconds
+func parseTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		if secs <= 0 {
+			return 0, errors.New("must be positive")
+		}
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("must be a duration such as 30s or 2m")
+	}
+	if d <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return d, nil
+}
+
+// parseTimeoutKey splits NEXUS_<KIND>_TIMEOUT[_<BACKEND>] and
+// NEXUS_LATENCY_BUDGET[_<BACKEND>] into the kind and lowercase backend name.
+// ok is false for other keys.
+func parseTimeoutKey(key string) (kind, backend string, ok bool) {
+	for _, k := range []string{timeoutHealth, timeoutUsage, timeoutAPI, timeoutLatency} {
+		prefix := timeoutKeyPrefix(k)
+		if key == prefix {
+			return k, "", true
+		}
+		if name, found := strings.CutPrefix(key, prefix+"_"); found {
+			return k, strings.ToLower(name), true
+		}
+	}
+	return "", "", false
+}
+
+// networkTimeout resolves a timeout: the --timeout flag wins for health and
+// usage checks, then the per-backend setting, then the global setting
+func (c *Config) networkTimeout(kind, backend string, fallback time.Duration) time.Duration {
+	if c.TimeoutOverride > 0 && (kind == timeoutHealth || kind == timeoutUsage) {
+		return c.TimeoutOverride
+	}
+	if d, ok := c.Timeouts[kind+":"+backend]; ok {
+		return d
+	}
+	if d, ok := c.Timeouts[kind]; ok {
+		return d
+	}
+	return fallback
+}
+
+func (c *Config) healthTimeout(backend string) time.Duration {
+	return c.networkTimeout(timeoutHealth, backend, defaultHealthTimeout)
+}
+
+func (c *Config) usageTimeout(backend string) time.Duration {
+	return c.networkTimeout(timeoutUsage, backend, defaultUsageTimeout)
+}
+
+func (c *Config) apiTimeout(be Backend) time.Duration {
+	return c.networkTimeout(timeoutAPI, be.Name, be.Timeout)
+}
+
+// latencyBudget resolves how long the local proxy waits on backend's upstream
+func (c *Config) latencyBudget(backend string) time.Duration {
+	return c.networkTimeout(timeoutLatency, backend, defaultLatencyBudget)
+}
+
+// hasLatencyBudget reports whether a latency budget is configured for backend
+func (c *Config) hasLatencyBudget(backend string) bool {
+	_, global := c.Timeouts[timeoutLatency]
+	_, scoped := c.Timeouts[timeoutLatency+":"+backend]
+	return global || scoped
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30s", 30 * time.Second, false},
+		{"2m", 2 * time.Minute, false},
+		{"15", 15 * time.Second, false},
+		{"1.5", 1500 * time.Millisecond, false},
+		{"0", 0, true},
+		{"-5s", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTimeout(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimeout(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseTimeout(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeoutKey(t *testing.T) {
+	tests := []struct {
+		key         string
+		wantKind    string
+		wantBackend string
+		wantOK      bool
+	}{
+		{"NEXUS_HEALTH_TIMEOUT", timeoutHealth, "", true},
+		{"NEXUS_USAGE_TIMEOUT_KIMI", timeoutUsage, "kimi", true},
+		{"NEXUS_API_TIMEOUT_OLLAMA", timeoutAPI, "ollama", true},
+		{"NEXUS_LATENCY_BUDGET_GROK", timeoutLatency, "grok", true},
+		{"NEXUS_ARGS_CLAUDE", "", "", false},
+		{"NEXUS_HEALTH_TIMEOUTS", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			kind, backend, ok := parseTimeoutKey(tt.key)
+			if kind != tt.wantKind || backend != tt.wantBackend || ok != tt.wantOK {
+				t.Errorf("parseTimeoutKey(%q) = (%q, %q, %t), want (%q, %q, %t)",
+					tt.key, kind, backend, ok, tt.wantKind, tt.wantBackend, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNetworkTimeoutPrecedence(t *testing.T) {
+	cfg := &Config{Timeouts: map[string]time.Duration{
+		timeoutHealth:           20 * time.Second,
+		timeoutHealth + ":kimi": 40 * time.Second,
+	}}
+
+	if got := cfg.healthTimeout("deepseek"); got != 20*time.Second {
+		t.Errorf("Expected global health timeout, got %v", got)
+	}
+	if got := cfg.healthTimeout("kimi"); got != 40*time.Second {
+		t.Errorf("Expected per-backend health timeout, got %v", got)
+	}
+	if got := cfg.usageTimeout("kimi"); got != defaultUsageTimeout {
+		t.Errorf("Expected default usage timeout, got %v", got)
+	}
+	if got := cfg.apiTimeout(backends["zai"]); got != defaultTimeout {
+		t.Errorf("Expected backend API timeout, got %v", got)
+	}
+
+	// --timeout wins for checks but never shortens Claude Code API requests
+	cfg.TimeoutOverride = 3 * time.Second
+	if got := cfg.healthTimeout("kimi"); got != 3*time.Second {
+		t.Errorf("Expected --timeout override, got %v", got)
+	}
+	if got := cfg.apiTimeout(backends["zai"]); got != defaultTimeout {
+		t.Errorf("Expected API timeout unaffected by --timeout, got %v", got)
+	}
+}
+
+func TestLoadConfigTimeouts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	envFile := filepath.Join(home, ".env.local")
+	t.Setenv("NEXUS_ENV_FILE", envFile)
+
+	content := "NEXUS_HEALTH_TIMEOUT=15s\n" +
+		"NEXUS_USAGE_TIMEOUT_KIMI=45\n" +
+		"NEXUS_API_TIMEOUT_BOGUS=1m\n" +
+		"NEXUS_USAGE_TIMEOUT=never\n"
+	if err := os.WriteFile(envFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := loadConfig()
+	if got := cfg.healthTimeout("claude"); got != 15*time.Second {
+		t.Errorf("health timeout = %v", got)
+	}
+	if got := cfg.usageTimeout("kimi"); got != 45*time.Second {
+		t.Errorf("kimi usage timeout = %v", got)
+	}
+	if got := cfg.usageTimeout("claude"); got != defaultUsageTimeout {
+		t.Errorf("Expected invalid global usage timeout to be ignored, got %v", got)
+	}
+	if len(cfg.Timeouts) != 2 {
+		t.Errorf("Expected 2 timeouts, got %v", cfg.Timeouts)
+	}
+}
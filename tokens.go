@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"nexus/internal/tokenizer"
+)
+
+// remoteTokenizerName is the registry name of the NEXUS_TOKENIZER_URL endpoint
+const remoteTokenizerName = "remote"
+
+// tokenizers selects a tokenizer per model for token estimates
+var tokenizers = tokenizer.NewRegistry()
+
+// countTokens returns the estimated token count of text for model. Remote
+// tokenizer failures fall back to the built-in approximation.
+func countTokens(model, text string) int {
+	n, _ := tokenizers.Count(model, text)
+	return n
+}
+
+// configureTokenizers registers the optional remote tokenize endpoint for the
+// configured model prefixes. Without prefixes it applies to models that have
+// no built-in tokenizer.
+func configureTokenizers(cfg *Config) {
+	if cfg.TokenizerURL == "" {
+		return
+	}
+	tokenizers.Register(tokenizer.NewRemote(remoteTokenizerName, cfg.TokenizerURL, ""))
+
+	if len(cfg.TokenizerModels) == 0 {
+		if err := tokenizers.SetUnmapped(remoteTokenizerName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		return
+	}
+	for _, prefix := range cfg.TokenizerModels {
+		if err := tokenizers.Map(prefix, remoteTokenizerName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
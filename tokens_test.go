@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nexus/internal/tokenizer"
+)
+
+func TestConfigureTokenizersRemote(t *testing.T) {
+	defer func() { tokenizers = tokenizer.NewRegistry() }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tokens":[1,2,3]}`))
+	}))
+	defer server.Close()
+
+	configureTokenizers(&Config{TokenizerURL: server.URL, TokenizerModels: []string{"llama"}})
+
+	if n := countTokens("llama3.3", "hello world"); n != 3 {
+		t.Errorf("Expected remote count 3, got %d", n)
+	}
+	if n := countTokens("claude-sonnet-4-5", "hello world"); n != 2 {
+		t.Errorf("Expected built-in count 2 for unmapped model, got %d", n)
+	}
+}
+
+func TestCountTokensRemoteFailure(t *testing.T) {
+	defer func() { tokenizers = tokenizer.NewRegistry() }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	configureTokenizers(&Config{TokenizerURL: server.URL})
+
+	if n := countTokens("some-local-model", "hello world"); n != 2 {
+		t.Errorf("Expected fallback estimate 2, got %d", n)
+	}
+}
+
+func TestConfigureTokenizersUnmappedOnly(t *testing.T) {
+	defer func() { tokenizers = tokenizer.NewRegistry() }()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"tokens":[1,2,3]}`))
+	}))
+	defer server.Close()
+
+	configureTokenizers(&Config{TokenizerURL: server.URL})
+
+	// Models with a built-in tokenizer never reach the endpoint
+	if n := countTokens("claude-sonnet-4-5", "hello world"); n != 2 || calls != 0 {
+		t.Errorf("Expected the built-in count 2 without a remote call, got %d after %d calls", n, calls)
+	}
+	if n := countTokens("some-local-model", "hello world"); n != 3 || calls != 1 {
+		t.Errorf("Expected the remote count 3 for an unmapped model, got %d after %d calls", n, calls)
+	}
+}
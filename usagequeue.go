@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxPendingUsage bounds the records held in memory while the usage file
+// cannot be written; the oldest are dropped beyond it
+const maxPendingUsage = 1000
+
+// usageQueue holds usage records that could not be appended to the usage
+// file so they can be retried instead of dropped
+type usageQueue struct {
+	mu      sync.Mutex
+	pending [][]byte
+	dropped int
+	warned  bool
+}
+
+// pendingUsage is the process-wide queue used by logSessionUsage
+var pendingUsage = &usageQueue{}
+
+// appendUsageLines appends JSON lines to a usage file in a single write. A
+// partial line left by an interrupted writer is ended first, so it does not
+// swallow the first new record.
+func appendUsageLines(path string, lines [][]byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// write appends a record to the usage file after any queued ones. On failure
+// everything stays queued for the next attempt.
+func (q *usageQueue) write(cfg *Config, line []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lines := append(q.pending, line)
+	if err := appendLedgerLines(cfg, cfg.UsageFile, lines); err != nil {
+		if over := len(lines) - maxPendingUsage; over > 0 {
+			lines = lines[over:]
+			q.dropped += over
+		}
+		q.pending = lines
+		return err
+	}
+	q.pending = nil
+	q.warned = false
+	return nil
+}
+
+// shouldWarn reports whether a write failure has not been reported yet, so
+// a proxy logging every request prints one warning per outage
+func (q *usageQueue) shouldWarn() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.warned {
+		return false
+	}
+	q.warned = true
+	return true
+}
+
+// drain removes and returns the queued records and the number dropped
+func (q *usageQueue) drain() ([][]byte, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lines, dropped := q.pending, q.dropped
+	q.pending, q.dropped = nil, 0
+	return lines, dropped
+}
+
+// usageSpoolPath is where queued records are kept between runs. It lives in
+// the user's cache directory, since the usage file's own directory may be the
+// unavailable one, and is keyed by usage file so environments don't mix. The
+// directory is private (0700) so no other user can plant a link at the
+// predictable name, as they could in a shared temp directory.
+func usageSpoolPath(cfg *Config) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locate cache directory: %w", err)
+	}
+	dir := filepath.Join(cache, "promptops")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("create spool directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", fmt.Errorf("secure spool directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(cfg.UsageFile))
+	return filepath.Join(dir, fmt.Sprintf("usage-spool-%s.jsonl", hex.EncodeToString(sum[:6]))), nil
+}
+
+// flushPendingUsage retries queued records on exit and spools any that still
+// cannot be written. It returns how many records were spooled.
+func flushPendingUsage(cfg *Config, q *usageQueue) (int, error) {
+	lines, dropped := q.drain()
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d usage records dropped while the usage file was unavailable\n", dropped)
+	}
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	if err := appendLedgerLines(cfg, cfg.UsageFile, lines); err == nil {
+		return 0, nil
+	}
+	spool, err := usageSpoolPath(cfg)
+	if err != nil {
+		return 0, fmt.Errorf("spool usage records: %w", err)
+	}
+	err = withFileLock(spool+".lock", func() error {
+		return appendUsageLines(spool, lines)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("spool usage records: %w", err)
+	}
+	return len(lines), nil
+}
+
+// flushUsageOnExit flushes the process-wide queue, reporting spooled records
+func flushUsageOnExit(cfg *Config) {
+	n, err := flushPendingUsage(cfg, pendingUsage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	if n > 0 {
+		spool, _ := usageSpoolPath(cfg)
+		fmt.Fprintf(os.Stderr, "Warning: usage file unavailable, %d records saved to %s\n", n, spool)
+		fmt.Fprintln(os.Stderr, "Run 'promptops flush' once it is reachable again.")
+	}
+}
+
+// flushUsageSpool moves spooled records into the usage file and returns how
+// many were written
+func flushUsageSpool(cfg *Config) (int, error) {
+	spool, err := usageSpoolPath(cfg)
+	if err != nil {
+		return 0, err
+	}
+	var written int
+	err = withFileLock(spool+".lock", func() error {
+		data, err := os.ReadFile(spool)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var lines [][]byte
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			if err := appendLedgerLines(cfg, cfg.UsageFile, lines); err != nil {
+				return fmt.Errorf("write usage file: %w", err)
+			}
+		}
+		written = len(lines)
+		return os.Remove(spool)
+	})
+	return written, err
+}
+
+func handleFlushCommand(args []string) {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: promptops flush")
+		os.Exit(1)
+	}
+	cfg := loadConfig()
+	n, err := flushUsageSpool(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if spool, err := usageSpoolPath(cfg); err == nil {
+			fmt.Fprintf(os.Stderr, "Records remain in %s\n", spool)
+		}
+		os.Exit(1)
+	}
+	if n == 0 {
+		fmt.Println("No pending usage records")
+		return
+	}
+	auditLog(cfg, fmt.Sprintf("USAGE_FLUSH: %d records", n))
+	fmt.Println(styleSuccess.Render(fmt.Sprintf("[OK] Flushed %d usage records to %s", n, cfg.UsageFile)))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUsageQueueRetriesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	q := &usageQueue{}
+	missing := filepath.Join(dir, "offline", "usage.jsonl")
+
+	if err := q.write(&Config{UsageFile: missing}, []byte(`{"n":1}`)); err == nil {
+		t.Fatal("Expected write to an unavailable path to fail")
+	}
+	if !q.shouldWarn() || q.shouldWarn() {
+		t.Error("Expected a single warning per outage")
+	}
+
+	path := filepath.Join(dir, "usage.jsonl")
+	if err := q.write(&Config{UsageFile: path}, []byte(`{"n":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "{\"n\":1}\n{\"n\":2}\n" {
+		t.Errorf("Expected queued record written first, got %q", data)
+	}
+	if lines, _ := q.drain(); len(lines) != 0 {
+		t.Errorf("Expected empty queue, got %d records", len(lines))
+	}
+}
+
+func TestUsageQueueBounded(t *testing.T) {
+	q := &usageQueue{}
+	missing := filepath.Join(t.TempDir(), "offline", "usage.jsonl")
+	for i := 0; i < maxPendingUsage+10; i++ {
+		q.write(&Config{UsageFile: missing}, []byte(`{}`))
+	}
+	lines, dropped := q.drain()
+	if len(lines) != maxPendingUsage || dropped != 10 {
+		t.Errorf("Expected %d queued and 10 dropped, got %d and %d", maxPendingUsage, len(lines), dropped)
+	}
+}
+
+func TestFlushPendingUsageSpools(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CACHE_HOME", dir)
+	cfg := newSelfTestConfig(dir)
+	cfg.UsageFile = filepath.Join(dir, "offline", "usage.jsonl")
+
+	q := &usageQueue{}
+	q.write(cfg, []byte(`{"backend":"ollama"}`))
+	n, err := flushPendingUsage(cfg, q)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 spooled record, got %d (err=%v)", n, err)
+	}
+	spool, err := usageSpoolPath(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(spool, dir) {
+		t.Errorf("Expected spool in the cache directory, got %s", spool)
+	}
+	info, err := os.Stat(spool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %o", info.Mode().Perm())
+	}
+	if info, err := os.Stat(filepath.Dir(spool)); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0700 {
+		t.Errorf("Expected spool directory permissions 0700, got %o", info.Mode().Perm())
+	}
+
+	// Still unavailable: flushing keeps the spool
+	if _, err := flushUsageSpool(cfg); err == nil {
+		t.Error("Expected flush to fail while the usage file is unavailable")
+	}
+	if _, err := os.Stat(spool); err != nil {
+		t.Errorf("Expected spool to be kept: %v", err)
+	}
+
+	// Reachable again: records move to the usage file
+	if err := os.MkdirAll(filepath.Dir(cfg.UsageFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := flushUsageSpool(cfg); err != nil || n != 1 {
+		t.Fatalf("Expected 1 flushed record, got %d (err=%v)", n, err)
+	}
+	data, _ := os.ReadFile(cfg.UsageFile)
+	if string(data) != "{\"backend\":\"ollama\"}\n" {
+		t.Errorf("Unexpected usage file contents: %q", data)
+	}
+	if _, err := os.Stat(spool); !os.IsNotExist(err) {
+		t.Error("Expected spool to be removed after flush")
+	}
+	if n, err := flushUsageSpool(cfg); err != nil || n != 0 {
+		t.Errorf("Expected nothing to flush, got %d (err=%v)", n, err)
+	}
+}
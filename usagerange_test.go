@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseUsageRange(t *testing.T) {
+	now := time.Date(2026, 10, 16, 15, 30, 0, 0, time.Local)
+
+	rest, r, err := parseUsageRange([]string{"claude", "--from", "2026-10-01", "--to=2026-10-15"}, now)
+	if err != nil || len(rest) != 1 || rest[0] != "claude" {
+		t.Fatalf("Unexpected result %v (err=%v)", rest, err)
+	}
+	if r.Label() != "2026-10-01 to 2026-10-15" {
+		t.Errorf("Unexpected label %q", r.Label())
+	}
+	if !r.Contains(time.Date(2026, 10, 15, 23, 59, 0, 0, time.Local)) || r.Contains(time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)) {
+		t.Error("Expected --to to include the whole day")
+	}
+	if q := r.Query(); q.Get("start_date") != "2026-10-01" || q.Get("end_date") != "2026-10-15" {
+		t.Errorf("Unexpected query %v", q)
+	}
+
+	_, r, err = parseUsageRange([]string{"--days", "7"}, now)
+	if err != nil || r.Label() != "2026-10-10 to now" || !r.To.IsZero() {
+		t.Errorf("Unexpected --days range %q (err=%v)", r.Label(), err)
+	}
+
+	_, r, _ = parseUsageRange(nil, now)
+	if !r.IsZero() || len(r.Query()) != 0 {
+		t.Errorf("Expected no range, got %q", r.Label())
+	}
+
+	for _, bad := range [][]string{
+		{"--from"},
+		{"--from", "yesterday"},
+		{"--days", "0"},
+		{"--days", "3", "--from", "2026-10-01"},
+		{"--from", "2026-10-15", "--to", "2026-10-01"},
+	} {
+		if _, _, err := parseUsageRange(bad, now); err == nil {
+			t.Errorf("Expected error for %v", bad)
+		}
+	}
+}
+
+func TestLocalUsage(t *testing.T) {
+	day := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	records := []UsageRecord{
+		{Timestamp: day.AddDate(0, 0, -5), Backend: "claude", InputTokens: 1000, CostUSD: 5},
+		{Timestamp: day, Backend: "claude", InputTokens: 100, OutputTokens: 50, CostUSD: 0.5},
+		{Timestamp: day.Add(time.Hour), Backend: "claude", InputTokens: 200, OutputTokens: 10, CostUSD: 0.25},
+		{Timestamp: day, Backend: "openai", InputTokens: 999, CostUSD: 9},
+	}
+	r := UsageRange{From: day.Add(-24 * time.Hour)}
+
+	u := localUsage(usageRecords(records), "claude", r)
+	if u.RequestCount != 2 || u.TotalTokens != 360 || u.TotalCost != 0.75 {
+		t.Errorf("Unexpected totals: %+v", u)
+	}
+	if u.Period != r.Label()+" (local records)" {
+		t.Errorf("Unexpected period %q", u.Period)
+	}
+}
+
+func TestFetchUsageInRange(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"total_tokens": 42}})
+	}))
+	defer srv.Close()
+
+	cfg := newSelfTestConfig(t.TempDir())
+	be := Backend{Name: "together", BaseURL: srv.URL}
+	r := UsageRange{From: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)}
+
+	// A provider without a documented range API is answered locally
+	u := fetchUsageInRange(cfg, be, "key", r)
+	if calls != 0 {
+		t.Errorf("Expected no provider call for a range, got %d", calls)
+	}
+	if u.TotalTokens != 0 || u.Period != "2026-10-01 to now (local records)" {
+		t.Errorf("Unexpected usage: %+v", u)
+	}
+
+	if usageRangeSupported(backends["claude"]) || usageRangeSupported(be) || !usageRangeSupported(backends["kimi"]) {
+		t.Error("Unexpected range support")
+	}
+}
+
+func TestFetchOpenAICompatibleUsageRange(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"total_tokens": 42}})
+	}))
+	defer srv.Close()
+
+	r := UsageRange{From: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)}
+	u := fetchOpenAICompatibleUsage(Backend{Name: "together", BaseURL: srv.URL}, "key", time.Second, r)
+	if query != "start_date=2026-10-01" {
+		t.Errorf("Expected range in query, got %q", query)
+	}
+	if u.TotalTokens != 42 {
+		t.Errorf("Unexpected usage: %+v", u)
+	}
+}
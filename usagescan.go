@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// usageReadBuffer is the read buffer of the usage ledger scanner. Longer
+// lines are assembled from several reads, so it bounds nothing but I/O size.
+const usageReadBuffer = 64 * 1024
+
+// scanUsageRecords calls fn with each record of a JSONL usage ledger, reading
+// it a line at a time so memory does not grow with the ledger. Lines that do
+// not parse are skipped, which includes a final line without a newline that
+// another process is still appending.
+func scanUsageRecords(r io.Reader, fn func(UsageRecord)) error {
+	br := bufio.NewReaderSize(r, usageReadBuffer)
+	var line []byte
+	for {
+		chunk, err := br.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var record UsageRecord
+			if json.Unmarshal(trimmed, &record) == nil {
+				fn(record)
+			}
+		}
+		line = line[:0]
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// eachUsageRecord streams the usage ledger into fn. A missing ledger has no
+// records.
+func eachUsageRecord(cfg *Config, fn func(UsageRecord)) error {
+	f, err := os.Open(cfg.UsageFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return scanUsageRecords(f, fn)
+}
+
+// usageSource streams usage records into fn. Aggregates take one so they
+// read the ledger a record at a time; tests pass records held in memory.
+type usageSource func(fn func(UsageRecord)) error
+
+// ledgerUsage streams the usage ledger
+func ledgerUsage(cfg *Config) usageSource {
+	return func(fn func(UsageRecord)) error { return eachUsageRecord(cfg, fn) }
+}
+
+// usageRecords streams records already in memory
+func usageRecords(records []UsageRecord) usageSource {
+	return func(fn func(UsageRecord)) error {
+		for _, r := range records {
+			fn(r)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanUsageRecordsToleratesPartialLines(t *testing.T) {
+	ledger := `{"backend":"claude","cost_usd":1}` + "\n" +
+		"not json\n" +
+		"\n" +
+		`{"backend":"openai","cost_usd":2}` + "\r\n" +
+		`{"backend":"grok","cost_us`
+	var got []string
+	if err := scanUsageRecords(strings.NewReader(ledger), func(r UsageRecord) { got = append(got, r.Backend) }); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "claude,openai" {
+		t.Errorf("Expected the partial trailing record skipped, got %v", got)
+	}
+
+	// A complete final record without a newline still counts
+	got = nil
+	scanUsageRecords(strings.NewReader(`{"backend":"claude"}`), func(r UsageRecord) { got = append(got, r.Backend) })
+	if len(got) != 1 {
+		t.Errorf("Expected the unterminated record read, got %v", got)
+	}
+}
+
+func TestScanUsageRecordsLongLines(t *testing.T) {
+	long := UsageRecord{Backend: "claude", Model: strings.Repeat("m", 3*usageReadBuffer), CostUSD: 0.5}
+	data, _ := json.Marshal(long)
+	ledger := string(data) + "\n" + `{"backend":"openai"}` + "\n"
+
+	var got []UsageRecord
+	scanUsageRecords(strings.NewReader(ledger), func(r UsageRecord) { got = append(got, r) })
+	if len(got) != 2 || got[0].Model != long.Model || got[1].Backend != "openai" {
+		t.Errorf("Expected lines longer than the buffer read whole, got %d records", len(got))
+	}
+}
+
+func TestAppendUsageLinesEndsTornLine(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{UsageFile: filepath.Join(dir, "usage.jsonl")}
+	os.WriteFile(cfg.UsageFile, []byte(`{"backend":"claude"}`+"\n"+`{"backend":"gro`), 0600)
+
+	if err := appendUsageLines(cfg.UsageFile, [][]byte{[]byte(`{"backend":"openai"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	records := loadUsageRecords(cfg)
+	if len(records) != 2 || records[1].Backend != "openai" {
+		t.Errorf("Expected the new record kept apart from the torn line, got %+v", records)
+	}
+}
+
+func TestLoadUsageRecordsMissingFile(t *testing.T) {
+	cfg := &Config{UsageFile: filepath.Join(t.TempDir(), "none.jsonl")}
+	if records := loadUsageRecords(cfg); records == nil || len(records) != 0 {
+		t.Errorf("Expected an empty ledger, got %v", records)
+	}
+}
+
+// writeBenchLedger writes n usage records and returns a config reading them
+func writeBenchLedger(b *testing.B, n int) *Config {
+	cfg := &Config{UsageFile: filepath.Join(b.TempDir(), "usage.jsonl")}
+	f, err := os.Create(cfg.UsageFile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		data, _ := json.Marshal(UsageRecord{
+			Timestamp:    time.Now().AddDate(0, 0, -i%30),
+			Backend:      "claude",
+			Model:        "claude-sonnet-4-5",
+			InputTokens:  1200,
+			OutputTokens: 300,
+			CostUSD:      float64(i%100) * 0.01,
+			SessionID:    "bench",
+		})
+		fmt.Fprintln(f, string(data))
+	}
+	f.Close()
+	return cfg
+}
+
+// BenchmarkScanUsageRecords compares B/op of a budget total streamed from
+// the ledger with the same total over every record loaded first
+func BenchmarkScanUsageRecords(b *testing.B) {
+	for _, n := range []int{10000, 100000} {
+		cfg := writeBenchLedger(b, n)
+		now := time.Now()
+
+		b.Run(fmt.Sprintf("stream/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				periodCostsByBackend(ledgerUsage(cfg), now)
+			}
+		})
+
+		b.Run(fmt.Sprintf("load/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				periodCostsByBackend(usageRecords(loadUsageRecords(cfg)), now)
+			}
+		})
+	}
+}
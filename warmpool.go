@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Warm pool defaults
+const (
+	defaultWarmKeepAlive = 10 * time.Minute
+	// minWarmInterval bounds how often keep-alive requests are sent
+	minWarmInterval = 30 * time.Second
+	// warmLoadTimeout allows for loading a large model from disk
+	warmLoadTimeout = 10 * time.Minute
+)
+
+// WarmPool keeps Ollama models resident while a session runs by sending
+// periodic load-only generate requests with keep_alive, so switching tiers
+// inside Claude Code doesn't wait for a model reload
+type WarmPool struct {
+	apiURL    string // Ollama native API root, e.g. http://localhost:11434
+	models    []string
+	keepAlive time.Duration
+	client    *http.Client
+	notify    func(string)
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// ollamaAPIURL returns the native API root for an OpenAI-compatible Ollama
+// base URL such as http://localhost:11434/v1
+func ollamaAPIURL(baseURL string) string {
+	return strings.TrimSuffix(strings.TrimRight(baseURL, "/"), "/v1")
+}
+
+// NewWarmPool creates a pool for the given models; duplicates and empty
+// names are dropped
+func NewWarmPool(baseURL string, models []string, keepAlive time.Duration, notify func(string)) *WarmPool {
+	if keepAlive <= 0 {
+		keepAlive = defaultWarmKeepAlive
+	}
+	var unique []string
+	seen := make(map[string]bool)
+	for _, m := range models {
+		if m != "" && !seen[m] {
+			seen[m] = true
+			unique = append(unique, m)
+		}
+	}
+	return &WarmPool{
+		apiURL:    ollamaAPIURL(baseURL),
+		models:    unique,
+		keepAlive: keepAlive,
+		client:    &http.Client{Timeout: warmLoadTimeout},
+		notify:    notify,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Models returns the models the pool keeps loaded
+func (w *WarmPool) Models() []string {
+	return w.models
+}
+
+// interval is how often each model's keep-alive is renewed: well before
+// keep_alive expires, but not more often than minWarmInterval
+func (w *WarmPool) interval() time.Duration {
+	if d := w.keepAlive / 2; d > minWarmInterval {
+		return d
+	}
+	return minWarmInterval
+}
+
+// warm loads model (if needed) and resets its keep_alive. A generate request
+// without a prompt only loads the model.
+func (w *WarmPool) warm(ctx context.Context, model string) error {
+	body, _ := json.Marshal(map[string]string{
+		"model":      model,
+		"keep_alive": fmt.Sprintf("%ds", int(w.keepAlive.Seconds())),
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", w.apiURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Start warms every model in parallel, then renews them until Stop
+func (w *WarmPool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-w.stop
+		cancel()
+	}()
+	for _, model := range w.models {
+		w.wg.Add(1)
+		go func(model string) {
+			defer w.wg.Done()
+			ticker := time.NewTicker(w.interval())
+			defer ticker.Stop()
+			failing := false
+			for {
+				err := w.warm(ctx, model)
+				if ctx.Err() != nil {
+					return
+				}
+				// Report only changes, not every failed renewal
+				if err != nil && !failing && w.notify != nil {
+					w.notify(fmt.Sprintf("Could not keep %s loaded: %s", model, sanitizeError(err)))
+				}
+				failing = err != nil
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(model)
+	}
+}
+
+// Stop ends keep-alive renewal. Models stay loaded until their keep_alive
+// expires.
+func (w *WarmPool) Stop() {
+	close(w.stop)
+	w.wg.Wait()
+}
+
+// startWarmPool keeps the proxy's haiku and sonnet tier models resident when
+// NEXUS_WARM_MODELS is set. Models routed to other local upstreams are
+// skipped. It returns nil when the pool is off.
+func startWarmPool(cfg *Config, be Backend, proxy *OllamaProxy) *WarmPool {
+	if !cfg.WarmModels {
+		return nil
+	}
+	haiku, sonnet, _ := resolveTierModels(cfg, be)
+	var models []string
+	for _, m := range []string{haiku, sonnet} {
+		if m == "" {
+			continue
+		}
+		m = proxy.mapModel(m)
+		if upstream, _ := proxy.upstreamFor(m); upstream == defaultUpstream {
+			models = append(models, m)
+		}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	pool := NewWarmPool(proxy.ollamaBaseURL, models, cfg.WarmKeepAlive, func(msg string) {
+		fmt.Fprintln(os.Stderr, styleWarning.Render("[promptops] "+msg))
+	})
+	pool.Start()
+	return pool
+}
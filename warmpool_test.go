@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOllamaAPIURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"http://localhost:11434/v1":  "http://localhost:11434",
+		"http://localhost:11434/v1/": "http://localhost:11434",
+		"http://gpu-box:11434":       "http://gpu-box:11434",
+	} {
+		if got := ollamaAPIURL(in); got != want {
+			t.Errorf("ollamaAPIURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWarmPool(t *testing.T) {
+	var mu sync.Mutex
+	var loaded []string
+	var keepAlive string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		loaded = append(loaded, body["model"])
+		keepAlive = body["keep_alive"]
+		mu.Unlock()
+		w.Write([]byte(`{"done":true,"done_reason":"load"}`))
+	}))
+	defer server.Close()
+
+	pool := NewWarmPool(server.URL+"/v1", []string{"llama3.2:latest", "codellama:latest", "llama3.2:latest", ""}, 0, nil)
+	if len(pool.Models()) != 2 {
+		t.Fatalf("Expected duplicates dropped, got %v", pool.Models())
+	}
+	if pool.interval() != defaultWarmKeepAlive/2 {
+		t.Errorf("Unexpected interval %s", pool.interval())
+	}
+
+	pool.Start()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(loaded)
+		mu.Unlock()
+		if n >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	pool.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(loaded)
+	if strings.Join(loaded, ",") != "codellama:latest,llama3.2:latest" {
+		t.Errorf("Expected both models warmed once, got %v", loaded)
+	}
+	if keepAlive != "600s" {
+		t.Errorf("Unexpected keep_alive %q", keepAlive)
+	}
+}
+
+func TestWarmPoolReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	notices := make(chan string, 4)
+	pool := NewWarmPool(server.URL, []string{"missing"}, time.Minute, func(msg string) { notices <- msg })
+	pool.Start()
+	select {
+	case msg := <-notices:
+		if !strings.Contains(msg, "missing") || !strings.Contains(msg, "HTTP 404") {
+			t.Errorf("Unexpected notice %q", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected a failure notice")
+	}
+	pool.Stop()
+}
+
+func TestStartWarmPoolSkipsRoutedModels(t *testing.T) {
+	be := backends["ollama"]
+	proxy := NewOllamaProxy("http://127.0.0.1:1/v1", nil)
+	cfg := &Config{}
+	if pool := startWarmPool(cfg, be, proxy); pool != nil {
+		t.Error("Expected no pool when disabled")
+	}
+
+	// Every tier model served by another upstream leaves nothing to warm
+	cfg.WarmModels = true
+	proxy.SetUpstreams([]LocalUpstream{{Name: "lmstudio", BaseURL: "http://127.0.0.1:1/v1"}}, []ModelRoute{{Pattern: "*", Upstream: "lmstudio"}})
+	if pool := startWarmPool(cfg, be, proxy); pool != nil {
+		pool.Stop()
+		t.Error("Expected no pool when all models are routed elsewhere")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// handleWhichCommand shows what a launch would talk to:
+// promptops which [backend]
+func handleWhichCommand(args []string) {
+	cfg := loadConfig()
+	applySessionModels(cfg)
+	name := getCurrentBackend(cfg)
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Error: No backend selected. Run 'promptops <backend>' or 'promptops which <backend>'.")
+		os.Exit(1)
+	}
+	be, ok := backends[name]
+	if !ok {
+		unknownBackendError(name)
+		os.Exit(1)
+	}
+	fmt.Println()
+	writeWhich(cfg, be, os.Stdout)
+	fmt.Println()
+}
+
+// writeWhich prints the backend's endpoint, tier models, probed capabilities
+// and the provider API versions promptops sends. Credentials are reported as set or not set.
+func writeWhich(cfg *Config, be Backend, out io.Writer) {
+	endpoint := be.BaseURL
+	if endpoint == "" {
+		endpoint = "(Anthropic default)"
+	}
+	if port, ok := launchProxyPorts[be.Name]; ok {
+		endpoint = fmt.Sprintf("%s -> %s", proxyURL(port), be.BaseURL)
+	}
+
+	haiku, sonnet, opus := resolveTierModels(cfg, be)
+	var models []string
+	for i, model := range []string{haiku, sonnet, opus} {
+		if model == "" {
+			model = "(Claude Code default)"
+		}
+		models = append(models, modelTiers[i]+"="+model)
+	}
+
+	auth := "not set"
+	if cfg.Keys[be.AuthVar] != "" {
+		auth = "set"
+	} else if be.Name == "ollama" {
+		auth = "not required"
+	}
+
+	fmt.Fprintln(out, styleSection.Render("BACKEND"))
+	fmt.Fprintf(out, "  %-10s %s (%s)\n", "Backend:", be.DisplayName, be.Name)
+	fmt.Fprintf(out, "  %-10s %s\n", "Endpoint:", endpoint)
+	fmt.Fprintf(out, "  %-10s %s\n", "Models:", strings.Join(models, ", "))
+	fmt.Fprintf(out, "  %-10s %s (%s)\n", "API key:", be.AuthVar, auth)
+	fmt.Fprintf(out, "  %-10s %s\n", "Auth:", cfg.authStrategy(be))
+	if s := cfg.samplingDefaults(be.Name); !s.Empty() {
+		fmt.Fprintf(out, "  %-10s %s\n", "Sampling:", s)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, styleSection.Render("CAPABILITIES"))
+	writeCapabilities(loadCapabilities(cfg)[be.Name], out)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, styleSection.Render("API VERSIONS"))
+	writeAPIVersions(cfg, out)
+}
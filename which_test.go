@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteWhich(t *testing.T) {
+	cfg := &Config{
+		Keys:         map[string]string{"ANTHROPIC_API_KEY": "sk-ant-secret"},
+		PinnedModels: map[string]map[string]string{"claude": {"sonnet": "claude-sonnet-4-5-20250929"}},
+	}
+	var out bytes.Buffer
+	writeWhich(cfg, backends["claude"], &out)
+	s := out.String()
+	for _, want := range []string{"Claude (claude)", "(Anthropic default)", "sonnet=claude-sonnet-4-5-20250929", "ANTHROPIC_API_KEY (set)", "anthropic-version", "2023-06-01"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected %q in output:\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, "sk-ant-secret") {
+		t.Error("API key must not be shown")
+	}
+
+	out.Reset()
+	writeWhich(&Config{}, backends["ollama"], &out)
+	if !strings.Contains(out.String(), "http://127.0.0.1:18080 -> ") {
+		t.Errorf("Expected proxy endpoint, got:\n%s", out.String())
+	}
+}